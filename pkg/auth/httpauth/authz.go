@@ -0,0 +1,92 @@
+package httpauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Requirement declares the permissions a route needs. Every field is
+// optional; an empty Requirement admits any authenticated request.
+type Requirement struct {
+	// Roles must all be held.
+	Roles []string
+	// AnyRole, if set, must share at least one role with the token.
+	AnyRole []string
+	// Scopes must all be granted (the scp claim).
+	Scopes []string
+}
+
+// PermissionError lists what an authenticated request lacked. It matches
+// ErrForbidden with errors.Is.
+type PermissionError struct {
+	MissingRoles  []string
+	MissingScopes []string
+}
+
+func (e *PermissionError) Error() string {
+	var parts []string
+	if len(e.MissingRoles) > 0 {
+		parts = append(parts, "roles "+strings.Join(e.MissingRoles, ", "))
+	}
+	if len(e.MissingScopes) > 0 {
+		parts = append(parts, "scopes "+strings.Join(e.MissingScopes, ", "))
+	}
+	return fmt.Sprintf("forbidden: missing %s", strings.Join(parts, " and "))
+}
+
+func (e *PermissionError) Unwrap() error { return ErrForbidden }
+
+// Check returns a *PermissionError unless the claims in ctx meet req, and
+// ErrMissingToken when the request was not authenticated.
+func Check(ctx context.Context, req Requirement) error {
+	claims, ok := ClaimsFrom(ctx)
+	if !ok {
+		return ErrMissingToken
+	}
+
+	var perr PermissionError
+	for _, role := range req.Roles {
+		if !slices.Contains(claims.Roles, role) {
+			perr.MissingRoles = append(perr.MissingRoles, role)
+		}
+	}
+	if len(req.AnyRole) > 0 && !slices.ContainsFunc(req.AnyRole, func(role string) bool {
+		return slices.Contains(claims.Roles, role)
+	}) {
+		perr.MissingRoles = append(perr.MissingRoles, req.AnyRole...)
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(claims.Scopes, scope) {
+			perr.MissingScopes = append(perr.MissingScopes, scope)
+		}
+	}
+	if len(perr.MissingRoles) > 0 || len(perr.MissingScopes) > 0 {
+		return &perr
+	}
+	return nil
+}
+
+// ErrorBody is the JSON body of the default error responses. It extends
+// httpx/errors.ErrorResponse with the permissions a 403 was missing. Code
+// is the ErrorCode of the error, for clients to branch on.
+type ErrorBody struct {
+	Code          string   `json:"code"`
+	Message       string   `json:"message"`
+	MissingRoles  []string `json:"missingRoles,omitempty"`
+	MissingScopes []string `json:"missingScopes,omitempty"`
+}
+
+// ErrorBodyFor builds the response body for an authentication or
+// authorization error.
+func ErrorBodyFor(err error) ErrorBody {
+	body := ErrorBody{Code: ErrorCode(err), Message: Message(err)}
+	var perr *PermissionError
+	if errors.As(err, &perr) {
+		body.MissingRoles = perr.MissingRoles
+		body.MissingScopes = perr.MissingScopes
+	}
+	return body
+}
@@ -0,0 +1,37 @@
+package httpauth
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// WithClientIP attaches ip to ctx as the jwt.ClientInfo IP, so that
+// jwt.WithLockout tracks verification failures per client. Client info that
+// already names an IP is kept, and an empty ip leaves ctx unchanged.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	info, _ := jwt.ClientInfoFrom(ctx)
+	if info.IP != "" || ip == "" {
+		return ctx
+	}
+	info.IP = ip
+	return jwt.WithClientInfo(ctx, info)
+}
+
+// RemoteIP returns the host of r.RemoteAddr, the client IP the net/http
+// middleware uses by default. Forwarding headers are ignored since any client
+// can set them; behind a trusted proxy, read them with WithIPExtractor.
+func RemoteIP(r *http.Request) string {
+	return HostIP(r.RemoteAddr)
+}
+
+// HostIP returns the host of a "host:port" address, or addr itself if it has
+// no port.
+func HostIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,208 @@
+// Package connectauth authenticates Connect RPCs with bearer JWTs. On the
+// handler side the interceptor verifies the Authorization header and puts the
+// claims, principal and raw token into the context, like httpauth does for
+// plain HTTP; on the client side it forwards the caller's token.
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/httpauth"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/principal"
+)
+
+// Option configures an Interceptor.
+type Option func(*Interceptor)
+
+// WithPublicProcedures lets the given procedures (e.g.
+// "/auth.v1.AuthService/Login") through without a token.
+func WithPublicProcedures(procedures ...string) Option {
+	return func(i *Interceptor) {
+		for _, p := range procedures {
+			i.public[p] = struct{}{}
+		}
+	}
+}
+
+// WithTelemetry records every decision in metrics and the audit trail, with
+// the procedure reported as the path.
+func WithTelemetry(t *httpauth.Telemetry) Option {
+	return func(i *Interceptor) { i.telemetry = t }
+}
+
+// WithRequirements sets the permissions individual procedures need, keyed by
+// procedure.
+func WithRequirements(reqs map[string]httpauth.Requirement) Option {
+	return func(i *Interceptor) {
+		for p, req := range reqs {
+			i.requirements[p] = req
+		}
+	}
+}
+
+// Interceptor is a connect.Interceptor that authenticates requests.
+type Interceptor struct {
+	verifier     httpauth.TokenVerifier
+	public       map[string]struct{}
+	requirements map[string]httpauth.Requirement
+	telemetry    *httpauth.Telemetry
+}
+
+var _ connect.Interceptor = (*Interceptor)(nil)
+
+// New returns an Interceptor that verifies tokens with v. Clients may pass a
+// nil verifier.
+func New(v httpauth.TokenVerifier, opts ...Option) *Interceptor {
+	i := &Interceptor{
+		verifier:     v,
+		public:       make(map[string]struct{}),
+		requirements: make(map[string]httpauth.Requirement),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// WrapUnary implements connect.Interceptor.
+func (i *Interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			forwardToken(ctx, req.Header())
+			return next(ctx, req)
+		}
+		ctx, err := i.authenticate(ctx, req.Spec().Procedure, req.Peer(), req.Header())
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+// WrapStreamingClient implements connect.Interceptor.
+func (i *Interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		forwardToken(ctx, conn.RequestHeader())
+		return conn
+	}
+}
+
+// WrapStreamingHandler implements connect.Interceptor.
+func (i *Interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		ctx, err := i.authenticate(ctx, conn.Spec().Procedure, conn.Peer(), conn.RequestHeader())
+		if err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+func (i *Interceptor) authenticate(ctx context.Context, procedure string, peer connect.Peer, header http.Header) (context.Context, error) {
+	if _, ok := i.public[procedure]; ok {
+		return ctx, nil
+	}
+
+	start := time.Now()
+	authCtx, claims, err := i.verify(ctx, peer, header)
+	i.telemetry.Observe(ctx, httpauth.StageAuthenticate, http.MethodPost, procedure, start, claims, err)
+	if err != nil {
+		return nil, Error(err)
+	}
+	if req, ok := i.requirements[procedure]; ok {
+		start = time.Now()
+		err := httpauth.Check(authCtx, req)
+		i.telemetry.Observe(ctx, httpauth.StageAuthorize, http.MethodPost, procedure, start, claims, err)
+		if err != nil {
+			return nil, Error(err)
+		}
+	}
+	return authCtx, nil
+}
+
+// verify authenticates the token in header, attaching the peer's IP for
+// jwt.WithLockout.
+func (i *Interceptor) verify(ctx context.Context, peer connect.Peer, header http.Header) (context.Context, *jwt.TokenClaims, error) {
+	token, err := httpauth.BearerToken(header.Get("Authorization"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return httpauth.Authenticate(httpauth.WithClientIP(ctx, httpauth.HostIP(peer.Addr)), i.verifier, token)
+}
+
+// forwardToken sets the Authorization header from the token in ctx unless
+// the caller already set one.
+func forwardToken(ctx context.Context, header http.Header) {
+	if header.Get("Authorization") != "" {
+		return
+	}
+	if token, ok := principal.TokenFrom(ctx); ok && token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// Code maps an authentication error to its Connect code. Failures that are
+// not the token's fault, such as an unreachable revocation store, map to
+// CodeUnavailable so clients retry instead of discarding the token.
+func Code(err error) connect.Code {
+	switch {
+	case errors.Is(err, httpauth.ErrForbidden), errors.Is(err, jwt.ErrStepUpRequired):
+		return connect.CodePermissionDenied
+	case errors.Is(err, jwt.ErrRateLimited), errors.Is(err, jwt.ErrLockedOut):
+		return connect.CodeResourceExhausted
+	case errors.Is(err, jwt.ErrInvalidToken),
+		errors.Is(err, httpauth.ErrMissingToken),
+		errors.Is(err, httpauth.ErrInvalidAuthorization):
+		return connect.CodeUnauthenticated
+	default:
+		return connect.CodeUnavailable
+	}
+}
+
+// Error converts an authentication error into a *connect.Error with a
+// client-safe message. The original error stays reachable with errors.Is.
+func Error(err error) *connect.Error {
+	code := Code(err)
+	msg := httpauth.Message(err)
+	switch code {
+	case connect.CodeUnavailable:
+		msg = "authentication unavailable"
+	case connect.CodeResourceExhausted:
+		msg = "too many token requests"
+		if errors.Is(err, jwt.ErrLockedOut) {
+			msg = jwt.ErrLockedOut.Error()
+		}
+	case connect.CodePermissionDenied:
+		if errors.Is(err, jwt.ErrStepUpRequired) {
+			msg = jwt.ErrStepUpRequired.Error()
+		}
+	}
+
+	cerr := connect.NewError(code, &maskedError{msg: msg, err: err})
+	var perr *httpauth.PermissionError
+	if errors.As(err, &perr) {
+		for _, role := range perr.MissingRoles {
+			cerr.Meta().Add("X-Missing-Role", role)
+		}
+		for _, scope := range perr.MissingScopes {
+			cerr.Meta().Add("X-Missing-Scope", scope)
+		}
+	}
+	return cerr
+}
+
+// maskedError shows msg to clients while keeping err for errors.Is.
+type maskedError struct {
+	msg string
+	err error
+}
+
+func (e *maskedError) Error() string { return e.msg }
+func (e *maskedError) Unwrap() error { return e.err }
@@ -0,0 +1,160 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/httpauth"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/principal"
+)
+
+type stubVerifier map[string]*jwt.TokenClaims
+
+func (s stubVerifier) VerifyAccessToken(_ context.Context, token string) (*jwt.TokenClaims, error) {
+	if token == "down" {
+		return nil, errors.New("revocation store unreachable")
+	}
+	if c, ok := s[token]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: unknown token", jwt.ErrTokenExpired)
+}
+
+const (
+	pingProcedure   = "/test.v1.TestService/Ping"
+	deleteProcedure = "/test.v1.TestService/Delete"
+	loginProcedure  = "/test.v1.TestService/Login"
+)
+
+func TestInterceptor(t *testing.T) {
+	userID := uuid.New()
+	server := New(stubVerifier{
+		"user":  {Subject: userID, Roles: []string{"user"}},
+		"admin": {Subject: uuid.New(), Roles: []string{"user", "admin"}},
+	},
+		WithPublicProcedures(loginProcedure),
+		WithRequirements(map[string]httpauth.Requirement{deleteProcedure: {Roles: []string{"admin"}}}),
+	)
+
+	handler := func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		res := connect.NewResponse(&emptypb.Empty{})
+		if p, ok := principal.PrincipalFrom(ctx); ok {
+			res.Header().Set("X-User-Id", p.UserID)
+		}
+		return res, nil
+	}
+	mux := http.NewServeMux()
+	for _, procedure := range []string{pingProcedure, deleteProcedure, loginProcedure} {
+		mux.Handle(procedure, connect.NewUnaryHandler(procedure, handler, connect.WithInterceptors(server)))
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	call := func(ctx context.Context, procedure string) (*connect.Response[emptypb.Empty], error) {
+		client := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL+procedure,
+			connect.WithInterceptors(New(nil)))
+		return client.CallUnary(ctx, connect.NewRequest(&emptypb.Empty{}))
+	}
+	as := func(token string) context.Context {
+		return principal.WithToken(context.Background(), token)
+	}
+
+	res, err := call(as("user"), pingProcedure)
+	if err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if got := res.Header().Get("X-User-Id"); got != userID.String() {
+		t.Errorf("handler saw user %q, want %q", got, userID)
+	}
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		procedure string
+		code      connect.Code
+	}{
+		{"missing token", context.Background(), pingProcedure, connect.CodeUnauthenticated},
+		{"expired token", as("expired"), pingProcedure, connect.CodeUnauthenticated},
+		{"store down", as("down"), pingProcedure, connect.CodeUnavailable},
+		{"missing role", as("user"), deleteProcedure, connect.CodePermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := call(tt.ctx, tt.procedure)
+			if code := connect.CodeOf(err); code != tt.code {
+				t.Errorf("code = %v, want %v (%v)", code, tt.code, err)
+			}
+		})
+	}
+
+	if _, err := call(as("admin"), deleteProcedure); err != nil {
+		t.Errorf("expected admin to pass the requirement, got %v", err)
+	}
+	if _, err := call(context.Background(), loginProcedure); err != nil {
+		t.Errorf("expected public procedure to pass without a token, got %v", err)
+	}
+}
+
+func TestError(t *testing.T) {
+	err := Error(&httpauth.PermissionError{MissingRoles: []string{"admin"}})
+	if err.Code() != connect.CodePermissionDenied || err.Meta().Get("X-Missing-Role") != "admin" {
+		t.Errorf("unexpected error %v with meta %v", err, err.Meta())
+	}
+
+	err = Error(jwt.ErrTokenRevoked)
+	if err.Message() != "invalid or expired token" {
+		t.Errorf("expected the specific cause to be masked, got %q", err.Message())
+	}
+	if !errors.Is(err, jwt.ErrTokenRevoked) {
+		t.Error("expected the cause to stay reachable with errors.Is")
+	}
+}
+
+func TestInterceptor_Lockout(t *testing.T) {
+	maker, err := jwt.NewTokenMaker(jwt.Config{
+		Secret:   "test-secret-must-be-at-least-32-bytes",
+		Issuer:   "test-issuer",
+		Audience: "test-audience",
+	}, nil, jwt.WithLockout(jwt.NewMemoryFailureStore(), jwt.LockoutPolicy{Threshold: 2}))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	access, err := maker.CreateAccessToken(context.Background(), uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	handler := func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		return connect.NewResponse(&emptypb.Empty{}), nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle(pingProcedure, connect.NewUnaryHandler(pingProcedure, handler, connect.WithInterceptors(New(maker))))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	call := func(token string) error {
+		client := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL+pingProcedure,
+			connect.WithInterceptors(New(nil)))
+		_, err := client.CallUnary(principal.WithToken(context.Background(), token), connect.NewRequest(&emptypb.Empty{}))
+		return err
+	}
+
+	forged := access.Token[:len(access.Token)-4] + "AAAA"
+	for range 2 {
+		if code := connect.CodeOf(call(forged)); code != connect.CodeUnauthenticated {
+			t.Fatalf("forged token: code %v, want %v", code, connect.CodeUnauthenticated)
+		}
+	}
+	if code := connect.CodeOf(call(access.Token)); code != connect.CodeResourceExhausted {
+		t.Errorf("locked out peer: code %v, want %v", code, connect.CodeResourceExhausted)
+	}
+}
@@ -0,0 +1,167 @@
+package httpauth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// Cookie defaults.
+const (
+	DefaultRefreshCookieName = "refresh_token"
+	DefaultRefreshCookiePath = "/auth"
+	DefaultCSRFCookieName    = "csrf_token"
+	DefaultCSRFHeader        = "X-CSRF-Token"
+)
+
+// ErrCSRFMismatch is returned when the CSRF header does not match the CSRF
+// cookie.
+var ErrCSRFMismatch = errors.New("csrf token mismatch")
+
+// CookieConfig configures RefreshCookies. Zero values select the defaults.
+type CookieConfig struct {
+	// Name of the refresh token cookie. Defaults to DefaultRefreshCookieName.
+	Name string
+	// Path restricts the refresh cookie to the refresh endpoints so it is not
+	// sent with every request. Defaults to DefaultRefreshCookiePath.
+	Path   string
+	Domain string
+	// SameSite defaults to http.SameSiteStrictMode.
+	SameSite http.SameSite
+	// AllowInsecure drops the Secure attribute. Only for local development
+	// over plain HTTP.
+	AllowInsecure bool
+
+	// CSRFCookieName and CSRFHeader name the double-submit pair. Default to
+	// DefaultCSRFCookieName and DefaultCSRFHeader.
+	CSRFCookieName string
+	CSRFHeader     string
+	// DisableCSRF turns off the double-submit check, e.g. when SameSite
+	// Strict alone is deemed sufficient.
+	DisableCSRF bool
+	// CSRFSecret enables CSRF tokens bound to the session ID, for
+	// deployments keeping access tokens in cookies; see
+	// SetSessionRefreshToken and Authenticator.RequireCSRF.
+	CSRFSecret string
+}
+
+// RefreshCookies stores refresh tokens in httpOnly cookies. Every call to
+// SetRefreshToken also issues a fresh CSRF token in a cookie readable by
+// scripts; clients echo it in the CSRF header and RefreshToken rejects
+// requests where the two differ.
+type RefreshCookies struct {
+	cfg CookieConfig
+}
+
+// NewRefreshCookies returns RefreshCookies for cfg.
+func NewRefreshCookies(cfg CookieConfig) *RefreshCookies {
+	if cfg.Name == "" {
+		cfg.Name = DefaultRefreshCookieName
+	}
+	if cfg.Path == "" {
+		cfg.Path = DefaultRefreshCookiePath
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteStrictMode
+	}
+	if cfg.CSRFCookieName == "" {
+		cfg.CSRFCookieName = DefaultCSRFCookieName
+	}
+	if cfg.CSRFHeader == "" {
+		cfg.CSRFHeader = DefaultCSRFHeader
+	}
+	return &RefreshCookies{cfg: cfg}
+}
+
+// SetRefreshToken writes token to the refresh cookie, replacing the one it
+// was rotated from, and rotates the CSRF token along with it. The CSRF cookie
+// is scoped to "/" so pages anywhere on the site can read it. It returns the
+// new CSRF token so it can also be sent in the response body.
+func (rc *RefreshCookies) SetRefreshToken(w http.ResponseWriter, token *jwt.TokenResponse) (string, error) {
+	return rc.setRefreshToken(w, token, newCSRFToken)
+}
+
+func (rc *RefreshCookies) setRefreshToken(w http.ResponseWriter, token *jwt.TokenResponse, newCSRF func() (string, error)) (string, error) {
+	http.SetCookie(w, rc.cookie(rc.cfg.Name, rc.cfg.Path, token.Token, token.ExpiresAt, true))
+	if rc.cfg.DisableCSRF {
+		return "", nil
+	}
+
+	csrf, err := newCSRF()
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, rc.cookie(rc.cfg.CSRFCookieName, "/", csrf, token.ExpiresAt, false))
+	return csrf, nil
+}
+
+// RefreshToken reads the refresh token from r after checking the CSRF
+// double-submit pair. It returns ErrMissingToken when there is no cookie and
+// ErrCSRFMismatch when the CSRF check fails.
+func (rc *RefreshCookies) RefreshToken(r *http.Request) (string, error) {
+	if err := rc.VerifyCSRF(r); err != nil {
+		return "", err
+	}
+	c, err := r.Cookie(rc.cfg.Name)
+	if err != nil || c.Value == "" {
+		return "", ErrMissingToken
+	}
+	return c.Value, nil
+}
+
+// VerifyCSRF checks that the CSRF header matches the CSRF cookie. It always
+// succeeds when CSRF is disabled.
+func (rc *RefreshCookies) VerifyCSRF(r *http.Request) error {
+	if rc.cfg.DisableCSRF {
+		return nil
+	}
+	c, err := r.Cookie(rc.cfg.CSRFCookieName)
+	if err != nil || c.Value == "" {
+		return ErrCSRFMismatch
+	}
+	header := r.Header.Get(rc.cfg.CSRFHeader)
+	if subtle.ConstantTimeCompare([]byte(header), []byte(c.Value)) != 1 {
+		return ErrCSRFMismatch
+	}
+	return nil
+}
+
+// Clear expires the refresh and CSRF cookies, e.g. on logout.
+func (rc *RefreshCookies) Clear(w http.ResponseWriter) {
+	expired := rc.cookie(rc.cfg.Name, rc.cfg.Path, "", time.Unix(0, 0), true)
+	expired.MaxAge = -1
+	http.SetCookie(w, expired)
+	if rc.cfg.DisableCSRF {
+		return
+	}
+	expired = rc.cookie(rc.cfg.CSRFCookieName, "/", "", time.Unix(0, 0), false)
+	expired.MaxAge = -1
+	http.SetCookie(w, expired)
+}
+
+func (rc *RefreshCookies) cookie(name, path, value string, expires time.Time, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   rc.cfg.Domain,
+		Expires:  expires,
+		Secure:   !rc.cfg.AllowInsecure,
+		HttpOnly: httpOnly,
+		SameSite: rc.cfg.SameSite,
+	}
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate csrf token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,159 @@
+package httpauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+func TestRefreshCookies(t *testing.T) {
+	rc := NewRefreshCookies(CookieConfig{})
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	w := httptest.NewRecorder()
+	csrf, err := rc.SetRefreshToken(w, &jwt.TokenResponse{Token: "refresh-1", ExpiresAt: expires})
+	if err != nil {
+		t.Fatalf("set refresh token: %v", err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("expected refresh and csrf cookies, got %d", len(cookies))
+	}
+	refresh, csrfCookie := cookies[0], cookies[1]
+	if refresh.Name != DefaultRefreshCookieName || refresh.Value != "refresh-1" {
+		t.Errorf("unexpected refresh cookie %v", refresh)
+	}
+	if !refresh.HttpOnly || !refresh.Secure || refresh.SameSite != http.SameSiteStrictMode || refresh.Path != DefaultRefreshCookiePath {
+		t.Errorf("refresh cookie missing hardening attributes: %v", refresh)
+	}
+	if !refresh.Expires.Equal(expires) {
+		t.Errorf("refresh cookie expires %v, want %v", refresh.Expires, expires)
+	}
+	if csrfCookie.HttpOnly || csrfCookie.Value != csrf || csrfCookie.Path != "/" {
+		t.Errorf("csrf cookie must be readable by scripts site-wide: %v", csrfCookie)
+	}
+
+	request := func(header string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/auth/refresh", nil)
+		r.AddCookie(refresh)
+		r.AddCookie(csrfCookie)
+		if header != "" {
+			r.Header.Set(DefaultCSRFHeader, header)
+		}
+		return r
+	}
+	if token, err := rc.RefreshToken(request(csrf)); err != nil || token != "refresh-1" {
+		t.Errorf("RefreshToken = %q, %v; want refresh-1", token, err)
+	}
+	if _, err := rc.RefreshToken(request("")); !errors.Is(err, ErrCSRFMismatch) {
+		t.Errorf("expected ErrCSRFMismatch without header, got %v", err)
+	}
+	if _, err := rc.RefreshToken(request("forged")); !errors.Is(err, ErrCSRFMismatch) {
+		t.Errorf("expected ErrCSRFMismatch for wrong header, got %v", err)
+	}
+
+	// Rotation replaces both cookies.
+	w = httptest.NewRecorder()
+	rotated, err := rc.SetRefreshToken(w, &jwt.TokenResponse{Token: "refresh-2", ExpiresAt: expires})
+	if err != nil {
+		t.Fatalf("set rotated refresh token: %v", err)
+	}
+	if rotated == csrf {
+		t.Error("expected the csrf token to rotate with the refresh token")
+	}
+
+	w = httptest.NewRecorder()
+	rc.Clear(w)
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge >= 0 || c.Value != "" {
+			t.Errorf("expected %s to be expired, got %v", c.Name, c)
+		}
+	}
+}
+
+func TestRefreshCookies_DisableCSRF(t *testing.T) {
+	rc := NewRefreshCookies(CookieConfig{Name: "rt", DisableCSRF: true, AllowInsecure: true})
+
+	w := httptest.NewRecorder()
+	if _, err := rc.SetRefreshToken(w, &jwt.TokenResponse{Token: "refresh", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("set refresh token: %v", err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Secure {
+		t.Fatalf("expected a single insecure cookie, got %v", cookies)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/auth/refresh", nil)
+	if _, err := rc.RefreshToken(r); !errors.Is(err, ErrMissingToken) {
+		t.Errorf("expected ErrMissingToken without cookie, got %v", err)
+	}
+	r.AddCookie(cookies[0])
+	if token, err := rc.RefreshToken(r); err != nil || token != "refresh" {
+		t.Errorf("RefreshToken = %q, %v; want refresh", token, err)
+	}
+}
+
+func TestSessionCSRF(t *testing.T) {
+	rc := NewRefreshCookies(CookieConfig{CSRFSecret: "csrf-secret-at-least-32-bytes-long"})
+	sessionID := uuid.New()
+
+	w := httptest.NewRecorder()
+	csrf, err := rc.SetSessionRefreshToken(w, &jwt.TokenResponse{Token: "refresh-1", ExpiresAt: time.Now().Add(time.Hour)}, sessionID)
+	if err != nil {
+		t.Fatalf("set refresh token: %v", err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 2 || cookies[1].Value != csrf {
+		t.Fatalf("cookies = %v", cookies)
+	}
+
+	// The double-submit check of the refresh endpoint still applies.
+	r := httptest.NewRequest(http.MethodPost, "/auth/refresh", nil)
+	r.AddCookie(cookies[0])
+	r.AddCookie(cookies[1])
+	r.Header.Set(DefaultCSRFHeader, csrf)
+	if token, err := rc.RefreshToken(r); err != nil || token != "refresh-1" {
+		t.Errorf("RefreshToken = %q, %v", token, err)
+	}
+
+	other, err := rc.SessionCSRFToken(uuid.New())
+	if err != nil {
+		t.Fatalf("csrf token: %v", err)
+	}
+	a := New(stubVerifier{"access": {Subject: uuid.New(), SessionID: sessionID}}, WithExtractor(FromCookie("access_token")))
+	h := a.Middleware(a.RequireCSRF(rc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	for _, tt := range []struct {
+		name   string
+		method string
+		header string
+		want   int
+	}{
+		{"bound token", http.MethodPost, csrf, http.StatusNoContent},
+		{"safe method", http.MethodGet, "", http.StatusNoContent},
+		{"missing", http.MethodPost, "", http.StatusForbidden},
+		{"other session", http.MethodDelete, other, http.StatusForbidden},
+		{"tampered", http.MethodPost, csrf[:len(csrf)-2] + "xx", http.StatusForbidden},
+	} {
+		r := httptest.NewRequest(tt.method, "/goals", nil)
+		r.AddCookie(&http.Cookie{Name: "access_token", Value: "access"})
+		if tt.header != "" {
+			r.Header.Set(DefaultCSRFHeader, tt.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	if _, err := NewRefreshCookies(CookieConfig{}).SessionCSRFToken(sessionID); err == nil {
+		t.Error("expected error without CSRFSecret")
+	}
+}
@@ -0,0 +1,104 @@
+package httpauth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// csrfNonceBytes is the length of the random part of session CSRF tokens,
+// which makes every issued token differ.
+const csrfNonceBytes = 16
+
+// errCSRFSecretRequired is returned by the session CSRF helpers when
+// CookieConfig.CSRFSecret is empty.
+var errCSRFSecretRequired = errors.New("session csrf tokens require CookieConfig.CSRFSecret")
+
+// SetSessionRefreshToken is SetRefreshToken with a CSRF token bound to
+// sessionID, the session of token, instead of a random one. Besides the
+// double-submit check of RefreshToken, such tokens let RequireCSRF protect
+// every request authenticated by a cookie. It requires
+// CookieConfig.CSRFSecret.
+func (rc *RefreshCookies) SetSessionRefreshToken(w http.ResponseWriter, token *jwt.TokenResponse, sessionID uuid.UUID) (string, error) {
+	if rc.cfg.CSRFSecret == "" {
+		return "", errCSRFSecretRequired
+	}
+	return rc.setRefreshToken(w, token, func() (string, error) {
+		return rc.SessionCSRFToken(sessionID)
+	})
+}
+
+// SessionCSRFToken returns a new CSRF token for sessionID, for clients
+// that receive it in a response body rather than the cookie.
+func (rc *RefreshCookies) SessionCSRFToken(sessionID uuid.UUID) (string, error) {
+	if rc.cfg.CSRFSecret == "" {
+		return "", errCSRFSecretRequired
+	}
+	nonce := make([]byte, csrfNonceBytes)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate csrf token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(nonce) + "." + rc.csrfMAC(sessionID, nonce), nil
+}
+
+// VerifySessionCSRF checks that the CSRF header of r carries a token issued
+// for sessionID. It returns ErrCSRFMismatch otherwise.
+func (rc *RefreshCookies) VerifySessionCSRF(r *http.Request, sessionID uuid.UUID) error {
+	if rc.cfg.CSRFSecret == "" {
+		return errCSRFSecretRequired
+	}
+	noncePart, mac, ok := strings.Cut(r.Header.Get(rc.cfg.CSRFHeader), ".")
+	if !ok {
+		return ErrCSRFMismatch
+	}
+	nonce, err := base64.RawURLEncoding.DecodeString(noncePart)
+	if err != nil || len(nonce) != csrfNonceBytes {
+		return ErrCSRFMismatch
+	}
+	if !hmac.Equal([]byte(mac), []byte(rc.csrfMAC(sessionID, nonce))) {
+		return ErrCSRFMismatch
+	}
+	return nil
+}
+
+func (rc *RefreshCookies) csrfMAC(sessionID uuid.UUID, nonce []byte) string {
+	h := hmac.New(sha256.New, []byte(rc.cfg.CSRFSecret))
+	h.Write(sessionID[:])
+	h.Write(nonce)
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// RequireCSRF rejects state-changing requests whose CSRF header was not
+// issued for the session of the verified token, with ErrCSRFMismatch. GET,
+// HEAD, OPTIONS and TRACE requests pass. It must run after Middleware and
+// is meant for cookie-authenticated deployments; see WithExtractor and
+// FromCookie.
+func (a *Authenticator) RequireCSRF(rc *RefreshCookies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next.ServeHTTP(w, r)
+				return
+			}
+			claims, ok := ClaimsFrom(r.Context())
+			if !ok {
+				a.onError(w, r, ErrMissingToken)
+				return
+			}
+			if err := rc.VerifySessionCSRF(r, claims.SessionID); err != nil {
+				a.onError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package httpauth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// DPoPHeader is the request header carrying DPoP proofs.
+const DPoPHeader = "DPoP"
+
+// WithDPoPProof attaches the DPoP proof of r, if it has one, to ctx so that
+// DPoP-bound tokens verify. The request URL is rebuilt from
+// X-Forwarded-Proto and X-Forwarded-Host when a proxy set them, since the
+// proof names the URL the client called.
+func WithDPoPProof(ctx context.Context, r *http.Request) context.Context {
+	proof := r.Header.Get(DPoPHeader)
+	if proof == "" {
+		return ctx
+	}
+	return jwt.WithDPoPProof(ctx, jwt.DPoPRequest{Proof: proof, Method: r.Method, URL: requestURL(r)})
+}
+
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	host := r.Host
+	if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" {
+		host = fwd
+	}
+	return scheme + "://" + host + r.URL.Path
+}
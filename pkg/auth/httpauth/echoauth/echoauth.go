@@ -0,0 +1,138 @@
+// Package echoauth adapts httpauth to Echo. Verified requests carry the same
+// claims, principal and raw token in their context as with the net/http
+// middleware, and the claims are also available through ClaimsFrom(c).
+package echoauth
+
+import (
+	"context"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/httpauth"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// ClaimsKey is the echo.Context key holding the verified *jwt.TokenClaims.
+const ClaimsKey = "auth.claims"
+
+// ErrorHandler returns the response for a rejected request. The default
+// returns an *echo.HTTPError carrying an httpauth.ErrorBody.
+type ErrorHandler func(c echo.Context, err error) error
+
+// Option configures an Authenticator.
+type Option func(*Authenticator)
+
+// WithErrorHandler replaces the default error response.
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(a *Authenticator) { a.onError = h }
+}
+
+// WithExtractor replaces httpauth.FromAuthHeader as the way tokens are found.
+func WithExtractor(e httpauth.TokenExtractor) Option {
+	return func(a *Authenticator) { a.extract = e }
+}
+
+// WithTelemetry records every decision in metrics and the audit trail. The
+// route pattern is reported as the path.
+func WithTelemetry(t *httpauth.Telemetry) Option {
+	return func(a *Authenticator) { a.telemetry = t }
+}
+
+// Authenticator builds Echo middleware around a TokenVerifier.
+type Authenticator struct {
+	verifier  httpauth.TokenVerifier
+	extract   httpauth.TokenExtractor
+	onError   ErrorHandler
+	telemetry *httpauth.Telemetry
+}
+
+// New returns an Authenticator that verifies tokens with v.
+func New(v httpauth.TokenVerifier, opts ...Option) *Authenticator {
+	a := &Authenticator{verifier: v, extract: httpauth.FromAuthHeader(), onError: defaultErrorHandler}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// ClaimsFrom returns the claims stored by Middleware.
+func ClaimsFrom(c echo.Context) (*jwt.TokenClaims, bool) {
+	claims, ok := c.Get(ClaimsKey).(*jwt.TokenClaims)
+	return claims, ok && claims != nil
+}
+
+// Middleware rejects requests without a valid bearer token.
+func (a *Authenticator) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			start := time.Now()
+			ctx, claims, err := a.verify(c)
+			a.telemetry.Observe(r.Context(), httpauth.StageAuthenticate, r.Method, c.Path(), start, claims, err)
+			if err != nil {
+				return a.onError(c, err)
+			}
+			c.SetRequest(r.WithContext(ctx))
+			c.Set(ClaimsKey, claims)
+			return next(c)
+		}
+	}
+}
+
+// Require rejects requests whose token does not meet req with a 403 listing
+// the missing permissions. It must run after Middleware.
+func (a *Authenticator) Require(req httpauth.Requirement) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			start := time.Now()
+			err := httpauth.Check(r.Context(), req)
+			claims, _ := ClaimsFrom(c)
+			a.telemetry.Observe(r.Context(), httpauth.StageAuthorize, r.Method, c.Path(), start, claims, err)
+			if err != nil {
+				return a.onError(c, err)
+			}
+			return next(c)
+		}
+	}
+}
+
+func (a *Authenticator) verify(c echo.Context) (context.Context, *jwt.TokenClaims, error) {
+	r := c.Request()
+	token, err := a.extract(httpauth.RequestSource(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx := httpauth.WithClientIP(httpauth.WithDPoPProof(r.Context(), r), clientIP(c))
+	return httpauth.Authenticate(ctx, a.verifier, token)
+}
+
+// clientIP returns c.RealIP() when the Echo instance has an IPExtractor, and
+// the peer address otherwise: without one, RealIP trusts forwarding headers
+// any client can set.
+func clientIP(c echo.Context) string {
+	if c.Echo() != nil && c.Echo().IPExtractor != nil {
+		return c.RealIP()
+	}
+	return httpauth.RemoteIP(c.Request())
+}
+
+// RequireRoles is Require with every role in roles.
+func (a *Authenticator) RequireRoles(roles ...string) echo.MiddlewareFunc {
+	return a.Require(httpauth.Requirement{Roles: roles})
+}
+
+// RequireAnyRole is Require with at least one of roles.
+func (a *Authenticator) RequireAnyRole(roles ...string) echo.MiddlewareFunc {
+	return a.Require(httpauth.Requirement{AnyRole: roles})
+}
+
+// RequireScopes is Require with every scope in scopes.
+func (a *Authenticator) RequireScopes(scopes ...string) echo.MiddlewareFunc {
+	return a.Require(httpauth.Requirement{Scopes: scopes})
+}
+
+func defaultErrorHandler(_ echo.Context, err error) error {
+	return echo.NewHTTPError(httpauth.Status(err), httpauth.ErrorBodyFor(err)).SetInternal(err)
+}
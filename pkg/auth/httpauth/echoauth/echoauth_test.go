@@ -0,0 +1,128 @@
+package echoauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/httpauth"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/principal"
+)
+
+type stubVerifier map[string]*jwt.TokenClaims
+
+func (s stubVerifier) VerifyAccessToken(_ context.Context, token string) (*jwt.TokenClaims, error) {
+	if c, ok := s[token]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: unknown token", jwt.ErrInvalidToken)
+}
+
+func TestMiddleware(t *testing.T) {
+	userID := uuid.New()
+	a := New(stubVerifier{
+		"admin": {Subject: userID, Roles: []string{"admin"}},
+		"user":  {Subject: uuid.New(), Roles: []string{"user"}},
+	})
+
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		claims, ok := ClaimsFrom(c)
+		if !ok {
+			t.Error("expected claims in echo context")
+		}
+		p, _ := principal.PrincipalFrom(c.Request().Context())
+		if p.UserID != claims.Subject.String() {
+			t.Errorf("principal %q does not match claims %q", p.UserID, claims.Subject)
+		}
+		return c.String(http.StatusOK, p.UserID)
+	}, a.Middleware(), a.RequireAnyRole("admin"))
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"invalid", "Bearer nope", http.StatusUnauthorized},
+		{"forbidden", "Bearer user", http.StatusForbidden},
+		{"ok", "Bearer admin", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(echo.HeaderAuthorization, tt.header)
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status == http.StatusOK && w.Body.String() != userID.String() {
+				t.Errorf("body = %q, want %q", w.Body.String(), userID)
+			}
+		})
+	}
+}
+
+func TestWithErrorHandler(t *testing.T) {
+	var got error
+	a := New(stubVerifier{}, WithErrorHandler(func(c echo.Context, err error) error {
+		got = err
+		return c.NoContent(http.StatusTeapot)
+	}))
+
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		t.Error("handler should not run")
+		return nil
+	}, a.Middleware())
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(echo.HeaderAuthorization, "Bearer nope")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+
+	if w.Code != http.StatusTeapot {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusTeapot)
+	}
+	if !errors.Is(got, jwt.ErrInvalidToken) {
+		t.Errorf("expected handler to receive ErrInvalidToken, got %v", got)
+	}
+}
+
+func TestRequireScopes(t *testing.T) {
+	a := New(stubVerifier{"reader": {Subject: uuid.New(), Scopes: []string{"read"}}})
+
+	e := echo.New()
+	e.DELETE("/", func(c echo.Context) error {
+		t.Error("handler should not run")
+		return nil
+	}, a.Middleware(), a.RequireScopes("read", "delete"))
+
+	r := httptest.NewRequest(http.MethodDelete, "/", nil)
+	r.Header.Set(echo.HeaderAuthorization, "Bearer reader")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	var body httpauth.ErrorBody
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body.Code != "permission_denied" || len(body.MissingScopes) != 1 || body.MissingScopes[0] != "delete" {
+		t.Errorf("unexpected body %+v", body)
+	}
+}
@@ -0,0 +1,121 @@
+package httpauth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// DefaultWebSocketTokenPrefix marks the Sec-WebSocket-Protocol entry that
+// carries the token, e.g. "bearer.<token>".
+const DefaultWebSocketTokenPrefix = "bearer."
+
+// Source exposes the parts of a request a TokenExtractor may read. It lets
+// extractors work with frameworks that do not use *http.Request.
+type Source interface {
+	Header(name string) string
+	Cookie(name string) string
+	Query(name string) string
+}
+
+// RequestSource adapts an *http.Request to Source.
+func RequestSource(r *http.Request) Source {
+	return requestSource{r}
+}
+
+type requestSource struct {
+	r *http.Request
+}
+
+func (s requestSource) Header(name string) string { return s.r.Header.Get(name) }
+func (s requestSource) Query(name string) string  { return s.r.URL.Query().Get(name) }
+
+func (s requestSource) Cookie(name string) string {
+	c, err := s.r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// TokenExtractor finds the access token in a request. It returns
+// ErrMissingToken when the request does not carry one in the place it looks.
+type TokenExtractor func(s Source) (string, error)
+
+// FromAuthHeader reads a bearer token from the Authorization header. It is
+// the default extractor of every middleware.
+func FromAuthHeader() TokenExtractor {
+	return func(s Source) (string, error) {
+		return BearerToken(s.Header("Authorization"))
+	}
+}
+
+// FromHeader reads the token from a custom header. A "Bearer " prefix is
+// accepted but not required.
+func FromHeader(name string) TokenExtractor {
+	return func(s Source) (string, error) {
+		v := s.Header(name)
+		if scheme, token, ok := strings.Cut(v, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			v = token
+		}
+		if v == "" {
+			return "", ErrMissingToken
+		}
+		return v, nil
+	}
+}
+
+// FromCookie reads the token from a cookie.
+func FromCookie(name string) TokenExtractor {
+	return func(s Source) (string, error) {
+		if v := s.Cookie(name); v != "" {
+			return v, nil
+		}
+		return "", ErrMissingToken
+	}
+}
+
+// FromQuery reads the token from a query parameter, for clients such as
+// EventSource and download links that cannot set headers. URLs end up in
+// access logs and browser history, so only use it with short-lived tokens.
+func FromQuery(param string) TokenExtractor {
+	return func(s Source) (string, error) {
+		if v := s.Query(param); v != "" {
+			return v, nil
+		}
+		return "", ErrMissingToken
+	}
+}
+
+// FromWebSocketProtocol reads the token from the Sec-WebSocket-Protocol
+// entry starting with prefix (DefaultWebSocketTokenPrefix if empty), which is
+// how browser WebSocket clients can pass credentials. The upgrader must still
+// select one of the other offered subprotocols.
+func FromWebSocketProtocol(prefix string) TokenExtractor {
+	if prefix == "" {
+		prefix = DefaultWebSocketTokenPrefix
+	}
+	return func(s Source) (string, error) {
+		for proto := range strings.SplitSeq(s.Header("Sec-WebSocket-Protocol"), ",") {
+			if token, ok := strings.CutPrefix(strings.TrimSpace(proto), prefix); ok && token != "" {
+				return token, nil
+			}
+		}
+		return "", ErrMissingToken
+	}
+}
+
+// Chain tries extractors in order and returns the first token found. An
+// extractor that finds a malformed value stops the chain with its error.
+func Chain(extractors ...TokenExtractor) TokenExtractor {
+	return func(s Source) (string, error) {
+		for _, extract := range extractors {
+			token, err := extract(s)
+			if errors.Is(err, ErrMissingToken) {
+				continue
+			}
+			return token, err
+		}
+		return "", ErrMissingToken
+	}
+}
@@ -0,0 +1,87 @@
+package httpauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+func TestExtractors(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/events?access_token=query", nil)
+	r.Header.Set("Authorization", "Bearer header")
+	r.Header.Set("X-Api-Token", "Bearer custom")
+	r.Header.Set("Sec-WebSocket-Protocol", "chat.v1, bearer.ws")
+	r.AddCookie(&http.Cookie{Name: "access_token", Value: "cookie"})
+	s := RequestSource(r)
+
+	tests := []struct {
+		name    string
+		extract TokenExtractor
+		want    string
+	}{
+		{"auth header", FromAuthHeader(), "header"},
+		{"custom header", FromHeader("X-Api-Token"), "custom"},
+		{"cookie", FromCookie("access_token"), "cookie"},
+		{"query", FromQuery("access_token"), "query"},
+		{"websocket", FromWebSocketProtocol(""), "ws"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.extract(s)
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	empty := RequestSource(httptest.NewRequest(http.MethodGet, "/", nil))
+	for _, tt := range tests {
+		if _, err := tt.extract(empty); !errors.Is(err, ErrMissingToken) {
+			t.Errorf("%s: expected ErrMissingToken, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	extract := Chain(FromAuthHeader(), FromCookie("access_token"), FromQuery("access_token"))
+
+	r := httptest.NewRequest(http.MethodGet, "/download?access_token=query", nil)
+	if got, err := extract(RequestSource(r)); err != nil || got != "query" {
+		t.Errorf("got %q, %v; want the query token", got, err)
+	}
+	r.AddCookie(&http.Cookie{Name: "access_token", Value: "cookie"})
+	if got, err := extract(RequestSource(r)); err != nil || got != "cookie" {
+		t.Errorf("got %q, %v; want the cookie to take precedence", got, err)
+	}
+	r.Header.Set("Authorization", "Basic xyz")
+	if _, err := extract(RequestSource(r)); !errors.Is(err, ErrInvalidAuthorization) {
+		t.Errorf("expected a malformed header to stop the chain, got %v", err)
+	}
+	if _, err := extract(RequestSource(httptest.NewRequest(http.MethodGet, "/", nil))); !errors.Is(err, ErrMissingToken) {
+		t.Errorf("expected ErrMissingToken, got %v", err)
+	}
+}
+
+func TestWithExtractor(t *testing.T) {
+	a := New(stubVerifier{"sse": {Subject: uuid.New()}}, WithExtractor(FromQuery("token")))
+	var claims *jwt.TokenClaims
+	h := a.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		claims, _ = ClaimsFrom(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events?token=sse", nil))
+	if w.Code != http.StatusOK || claims == nil {
+		t.Fatalf("status = %d, claims = %v; want the query token to authenticate", w.Code, claims)
+	}
+
+	if _, _, err := Authenticate(context.Background(), stubVerifier{}, ""); !errors.Is(err, ErrMissingToken) {
+		t.Errorf("expected ErrMissingToken for an empty token, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+// Package fasthttpauth adapts httpauth to fasthttp for gateways that cannot
+// use net/http. Verified requests carry the claims in the ClaimsKey user
+// value and a context with the claims, principal and raw token in
+// ContextKey, for calling downstream services with mdpropagate.
+package fasthttpauth
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/httpauth"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// User value keys set by Middleware.
+const (
+	ClaimsKey  = "auth.claims"
+	ContextKey = "auth.context"
+)
+
+// ErrorHandler writes the response for a rejected request. The default
+// responds with an httpauth.ErrorBody as JSON.
+type ErrorHandler func(ctx *fasthttp.RequestCtx, err error)
+
+// Option configures an Authenticator.
+type Option func(*Authenticator)
+
+// WithErrorHandler replaces the default error response.
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(a *Authenticator) { a.onError = h }
+}
+
+// WithExtractor replaces httpauth.FromAuthHeader as the way tokens are found.
+func WithExtractor(e httpauth.TokenExtractor) Option {
+	return func(a *Authenticator) { a.extract = e }
+}
+
+// WithTelemetry records every decision in metrics and the audit trail.
+func WithTelemetry(t *httpauth.Telemetry) Option {
+	return func(a *Authenticator) { a.telemetry = t }
+}
+
+// Authenticator builds fasthttp middleware around a TokenVerifier.
+type Authenticator struct {
+	verifier  httpauth.TokenVerifier
+	extract   httpauth.TokenExtractor
+	onError   ErrorHandler
+	telemetry *httpauth.Telemetry
+}
+
+// New returns an Authenticator that verifies tokens with v.
+func New(v httpauth.TokenVerifier, opts ...Option) *Authenticator {
+	a := &Authenticator{verifier: v, extract: httpauth.FromAuthHeader(), onError: defaultErrorHandler}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// source adapts a fasthttp request to httpauth.Source.
+type source struct {
+	ctx *fasthttp.RequestCtx
+}
+
+func (s source) Header(name string) string { return string(s.ctx.Request.Header.Peek(name)) }
+func (s source) Cookie(name string) string { return string(s.ctx.Request.Header.Cookie(name)) }
+func (s source) Query(name string) string  { return string(s.ctx.QueryArgs().Peek(name)) }
+
+// ClaimsFrom returns the claims stored by Middleware.
+func ClaimsFrom(ctx *fasthttp.RequestCtx) (*jwt.TokenClaims, bool) {
+	claims, ok := ctx.UserValue(ClaimsKey).(*jwt.TokenClaims)
+	return claims, ok && claims != nil
+}
+
+// Context returns the context carrying the claims, principal and raw token
+// stored by Middleware, or ctx itself for unauthenticated requests.
+func Context(ctx *fasthttp.RequestCtx) context.Context {
+	if c, ok := ctx.UserValue(ContextKey).(context.Context); ok {
+		return c
+	}
+	return ctx
+}
+
+// Middleware rejects requests without a valid bearer token.
+func (a *Authenticator) Middleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+		authCtx, claims, err := a.verify(ctx)
+		a.telemetry.Observe(ctx, httpauth.StageAuthenticate, string(ctx.Method()), string(ctx.Path()), start, claims, err)
+		if err != nil {
+			a.onError(ctx, err)
+			return
+		}
+		ctx.SetUserValue(ClaimsKey, claims)
+		ctx.SetUserValue(ContextKey, authCtx)
+		next(ctx)
+	}
+}
+
+func (a *Authenticator) verify(ctx *fasthttp.RequestCtx) (context.Context, *jwt.TokenClaims, error) {
+	token, err := a.extract(source{ctx})
+	if err != nil {
+		return nil, nil, err
+	}
+	return httpauth.Authenticate(httpauth.WithClientIP(ctx, ctx.RemoteIP().String()), a.verifier, token)
+}
+
+// Require rejects requests whose token does not meet req with a 403 listing
+// the missing permissions. It must run after Middleware.
+func (a *Authenticator) Require(req httpauth.Requirement) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			start := time.Now()
+			err := httpauth.Check(Context(ctx), req)
+			claims, _ := ClaimsFrom(ctx)
+			a.telemetry.Observe(ctx, httpauth.StageAuthorize, string(ctx.Method()), string(ctx.Path()), start, claims, err)
+			if err != nil {
+				a.onError(ctx, err)
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+// RequireRoles is Require with every role in roles.
+func (a *Authenticator) RequireRoles(roles ...string) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return a.Require(httpauth.Requirement{Roles: roles})
+}
+
+// RequireAnyRole is Require with at least one of roles.
+func (a *Authenticator) RequireAnyRole(roles ...string) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return a.Require(httpauth.Requirement{AnyRole: roles})
+}
+
+// RequireScopes is Require with every scope in scopes.
+func (a *Authenticator) RequireScopes(scopes ...string) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return a.Require(httpauth.Requirement{Scopes: scopes})
+}
+
+func defaultErrorHandler(ctx *fasthttp.RequestCtx, err error) {
+	body, _ := json.Marshal(httpauth.ErrorBodyFor(err))
+	ctx.SetStatusCode(httpauth.Status(err))
+	ctx.SetContentType("application/json")
+	ctx.SetBody(body)
+}
@@ -0,0 +1,100 @@
+package fasthttpauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/httpauth"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/principal"
+)
+
+type stubVerifier map[string]*jwt.TokenClaims
+
+func (s stubVerifier) VerifyAccessToken(_ context.Context, token string) (*jwt.TokenClaims, error) {
+	if c, ok := s[token]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: unknown token", jwt.ErrInvalidToken)
+}
+
+func TestMiddleware(t *testing.T) {
+	userID := uuid.New()
+	a := New(stubVerifier{
+		"admin": {Subject: userID, Roles: []string{"admin"}},
+		"user":  {Subject: uuid.New(), Roles: []string{"user"}},
+	})
+	h := a.Middleware(a.RequireRoles("admin")(func(ctx *fasthttp.RequestCtx) {
+		if _, ok := ClaimsFrom(ctx); !ok {
+			t.Error("expected claims in user values")
+		}
+		p, _ := principal.PrincipalFrom(Context(ctx))
+		ctx.SetBodyString(p.UserID)
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"missing", "", fasthttp.StatusUnauthorized},
+		{"invalid", "Bearer nope", fasthttp.StatusUnauthorized},
+		{"forbidden", "Bearer user", fasthttp.StatusForbidden},
+		{"ok", "Bearer admin", fasthttp.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ctx fasthttp.RequestCtx
+			ctx.Request.SetRequestURI("/admin")
+			if tt.header != "" {
+				ctx.Request.Header.Set(fasthttp.HeaderAuthorization, tt.header)
+			}
+			h(&ctx)
+
+			if got := ctx.Response.StatusCode(); got != tt.status {
+				t.Fatalf("status = %d, want %d", got, tt.status)
+			}
+			switch tt.status {
+			case fasthttp.StatusOK:
+				if got := string(ctx.Response.Body()); got != userID.String() {
+					t.Errorf("body = %q, want %q", got, userID)
+				}
+			case fasthttp.StatusForbidden:
+				var body httpauth.ErrorBody
+				if err := json.Unmarshal(ctx.Response.Body(), &body); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if len(body.MissingRoles) != 1 || body.MissingRoles[0] != "admin" {
+					t.Errorf("missingRoles = %v, want [admin]", body.MissingRoles)
+				}
+			}
+		})
+	}
+}
+
+func TestWithExtractor(t *testing.T) {
+	a := New(stubVerifier{"query": {Subject: uuid.New()}}, WithExtractor(httpauth.Chain(
+		httpauth.FromAuthHeader(), httpauth.FromCookie("access_token"), httpauth.FromQuery("token"),
+	)))
+	h := a.Middleware(func(ctx *fasthttp.RequestCtx) { ctx.SetStatusCode(fasthttp.StatusNoContent) })
+
+	var ctx fasthttp.RequestCtx
+	ctx.Request.SetRequestURI("/download?token=query")
+	h(&ctx)
+	if got := ctx.Response.StatusCode(); got != fasthttp.StatusNoContent {
+		t.Errorf("status = %d, want %d", got, fasthttp.StatusNoContent)
+	}
+
+	ctx = fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/download")
+	ctx.Request.Header.SetCookie("access_token", "query")
+	h(&ctx)
+	if got := ctx.Response.StatusCode(); got != fasthttp.StatusNoContent {
+		t.Errorf("status = %d, want %d for the cookie token", got, fasthttp.StatusNoContent)
+	}
+}
@@ -0,0 +1,130 @@
+// Package fiberauth adapts httpauth to Fiber. Verified requests carry the
+// same claims, principal and raw token in their user context as with the
+// net/http middleware, and the claims are also stored in Locals.
+package fiberauth
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/httpauth"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// ClaimsKey is the Locals key holding the verified *jwt.TokenClaims.
+const ClaimsKey = "auth.claims"
+
+// ErrorHandler writes the response for a rejected request. The default
+// responds with an httpauth.ErrorBody as JSON.
+type ErrorHandler func(c *fiber.Ctx, err error) error
+
+// Option configures an Authenticator.
+type Option func(*Authenticator)
+
+// WithErrorHandler replaces the default error response.
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(a *Authenticator) { a.onError = h }
+}
+
+// WithExtractor replaces httpauth.FromAuthHeader as the way tokens are found.
+func WithExtractor(e httpauth.TokenExtractor) Option {
+	return func(a *Authenticator) { a.extract = e }
+}
+
+// WithTelemetry records every decision in metrics and the audit trail. The
+// route pattern is reported as the path.
+func WithTelemetry(t *httpauth.Telemetry) Option {
+	return func(a *Authenticator) { a.telemetry = t }
+}
+
+// Authenticator builds Fiber handlers around a TokenVerifier.
+type Authenticator struct {
+	verifier  httpauth.TokenVerifier
+	extract   httpauth.TokenExtractor
+	onError   ErrorHandler
+	telemetry *httpauth.Telemetry
+}
+
+// New returns an Authenticator that verifies tokens with v.
+func New(v httpauth.TokenVerifier, opts ...Option) *Authenticator {
+	a := &Authenticator{verifier: v, extract: httpauth.FromAuthHeader(), onError: defaultErrorHandler}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// source adapts a Fiber request to httpauth.Source.
+type source struct {
+	c *fiber.Ctx
+}
+
+func (s source) Header(name string) string { return s.c.Get(name) }
+func (s source) Cookie(name string) string { return s.c.Cookies(name) }
+func (s source) Query(name string) string  { return s.c.Query(name) }
+
+// ClaimsFrom returns the claims stored by Middleware.
+func ClaimsFrom(c *fiber.Ctx) (*jwt.TokenClaims, bool) {
+	claims, ok := c.Locals(ClaimsKey).(*jwt.TokenClaims)
+	return claims, ok && claims != nil
+}
+
+// Middleware rejects requests without a valid bearer token.
+func (a *Authenticator) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		ctx, claims, err := a.verify(c)
+		a.telemetry.Observe(c.UserContext(), httpauth.StageAuthenticate, c.Method(), c.Route().Path, start, claims, err)
+		if err != nil {
+			return a.onError(c, err)
+		}
+		c.SetUserContext(ctx)
+		c.Locals(ClaimsKey, claims)
+		return c.Next()
+	}
+}
+
+// Require rejects requests whose token does not meet req with a 403 listing
+// the missing permissions. It must run after Middleware.
+func (a *Authenticator) Require(req httpauth.Requirement) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := httpauth.Check(c.UserContext(), req)
+		claims, _ := ClaimsFrom(c)
+		a.telemetry.Observe(c.UserContext(), httpauth.StageAuthorize, c.Method(), c.Route().Path, start, claims, err)
+		if err != nil {
+			return a.onError(c, err)
+		}
+		return c.Next()
+	}
+}
+
+func (a *Authenticator) verify(c *fiber.Ctx) (context.Context, *jwt.TokenClaims, error) {
+	token, err := a.extract(source{c})
+	if err != nil {
+		return nil, nil, err
+	}
+	// c.IP honors the app's ProxyHeader and trusted proxy settings.
+	return httpauth.Authenticate(httpauth.WithClientIP(c.UserContext(), c.IP()), a.verifier, token)
+}
+
+// RequireRoles is Require with every role in roles.
+func (a *Authenticator) RequireRoles(roles ...string) fiber.Handler {
+	return a.Require(httpauth.Requirement{Roles: roles})
+}
+
+// RequireAnyRole is Require with at least one of roles.
+func (a *Authenticator) RequireAnyRole(roles ...string) fiber.Handler {
+	return a.Require(httpauth.Requirement{AnyRole: roles})
+}
+
+// RequireScopes is Require with every scope in scopes.
+func (a *Authenticator) RequireScopes(scopes ...string) fiber.Handler {
+	return a.Require(httpauth.Requirement{Scopes: scopes})
+}
+
+func defaultErrorHandler(c *fiber.Ctx, err error) error {
+	return c.Status(httpauth.Status(err)).JSON(httpauth.ErrorBodyFor(err))
+}
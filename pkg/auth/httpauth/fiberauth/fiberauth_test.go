@@ -0,0 +1,131 @@
+package fiberauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/httpauth"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/principal"
+)
+
+type stubVerifier map[string]*jwt.TokenClaims
+
+func (s stubVerifier) VerifyAccessToken(_ context.Context, token string) (*jwt.TokenClaims, error) {
+	if c, ok := s[token]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: unknown token", jwt.ErrInvalidToken)
+}
+
+func TestMiddleware(t *testing.T) {
+	userID := uuid.New()
+	a := New(stubVerifier{
+		"admin": {Subject: userID, Roles: []string{"user", "admin"}},
+		"user":  {Subject: uuid.New(), Roles: []string{"user"}},
+	})
+
+	app := fiber.New()
+	app.Get("/", a.Middleware(), a.RequireRoles("user", "admin"), func(c *fiber.Ctx) error {
+		claims, ok := ClaimsFrom(c)
+		if !ok {
+			t.Error("expected claims in locals")
+		}
+		p, _ := principal.PrincipalFrom(c.UserContext())
+		if p.UserID != claims.Subject.String() {
+			t.Errorf("principal %q does not match claims %q", p.UserID, claims.Subject)
+		}
+		return c.SendString(p.UserID)
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"invalid", "Bearer nope", http.StatusUnauthorized},
+		{"forbidden", "Bearer user", http.StatusForbidden},
+		{"ok", "Bearer admin", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(fiber.HeaderAuthorization, tt.header)
+			}
+			resp, err := app.Test(r)
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", resp.StatusCode, tt.status, body)
+			}
+			if tt.status == http.StatusOK && string(body) != userID.String() {
+				t.Errorf("body = %q, want %q", body, userID)
+			}
+		})
+	}
+}
+
+func TestWithErrorHandler(t *testing.T) {
+	var got error
+	a := New(stubVerifier{}, WithErrorHandler(func(c *fiber.Ctx, err error) error {
+		got = err
+		return c.SendStatus(http.StatusTeapot)
+	}))
+
+	app := fiber.New()
+	app.Get("/", a.Middleware(), func(*fiber.Ctx) error {
+		t.Error("handler should not run")
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(fiber.HeaderAuthorization, "Bearer nope")
+	resp, err := app.Test(r)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTeapot {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusTeapot)
+	}
+	if !errors.Is(got, jwt.ErrInvalidToken) {
+		t.Errorf("expected handler to receive ErrInvalidToken, got %v", got)
+	}
+}
+
+func TestWithExtractor(t *testing.T) {
+	a := New(stubVerifier{"cookie": {Subject: uuid.New()}},
+		WithExtractor(httpauth.Chain(httpauth.FromAuthHeader(), httpauth.FromCookie("access_token"))))
+
+	app := fiber.New()
+	app.Get("/", a.Middleware(), func(c *fiber.Ctx) error {
+		return c.SendStatus(http.StatusNoContent)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "access_token", Value: "cookie"})
+	resp, err := app.Test(r)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+}
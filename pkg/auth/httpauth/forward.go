@@ -0,0 +1,57 @@
+package httpauth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Identity headers set by ForwardAuth on success.
+const (
+	HeaderUserID    = "X-User-Id"
+	HeaderUsername  = "X-Username"
+	HeaderRoles     = "X-Roles"
+	HeaderScopes    = "X-Scopes"
+	HeaderSessionID = "X-Session-Id"
+)
+
+// ForwardAuth returns a handler for reverse proxy authentication
+// subrequests, such as Traefik's forwardAuth middleware or nginx
+// auth_request. It answers 200 with the identity headers when the token in
+// the forwarded request is valid and meets req, and otherwise responds like
+// Middleware, i.e. 401 or 403 by default.
+//
+// Configure the proxy to copy the identity headers to the upstream request
+// (Traefik authResponseHeaders, nginx auth_request_set) and to drop any the
+// client sent itself, or upstreams may trust forged values.
+func (a *Authenticator) ForwardAuth(req Requirement) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := a.authenticate(r)
+		if err != nil {
+			a.rejectForward(w, r, err)
+			return
+		}
+		if err := a.authorize(r.WithContext(ctx), req); err != nil {
+			a.rejectForward(w, r, err)
+			return
+		}
+		claims, _ := ClaimsFrom(ctx)
+
+		h := w.Header()
+		h.Set(HeaderUserID, claims.Subject.String())
+		h.Set(HeaderUsername, claims.Username)
+		h.Set(HeaderRoles, strings.Join(claims.Roles, ","))
+		h.Set(HeaderScopes, strings.Join(claims.Scopes, ","))
+		h.Set(HeaderSessionID, claims.SessionID.String())
+		h.Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// rejectForward adds the bearer challenge, which proxies relay to clients on
+// 401, before handing the error to the error handler.
+func (a *Authenticator) rejectForward(w http.ResponseWriter, r *http.Request, err error) {
+	if Status(err) == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	a.onError(w, r, err)
+}
@@ -0,0 +1,60 @@
+package httpauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestForwardAuth(t *testing.T) {
+	userID, sessionID := uuid.New(), uuid.New()
+	a := New(stubVerifier{
+		"admin": {Subject: userID, SessionID: sessionID, Username: "alice", Roles: []string{"user", "admin"}, Scopes: []string{"read"}},
+		"user":  {Subject: uuid.New(), Roles: []string{"user"}},
+	})
+	h := a.ForwardAuth(Requirement{Roles: []string{"admin"}})
+
+	forward := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/auth/forward", nil)
+		r.Header.Set("X-Forwarded-Uri", "/admin/users")
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := forward("admin")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	want := map[string]string{
+		HeaderUserID:    userID.String(),
+		HeaderUsername:  "alice",
+		HeaderRoles:     "user,admin",
+		HeaderScopes:    "read",
+		HeaderSessionID: sessionID.String(),
+	}
+	for header, value := range want {
+		if got := w.Header().Get(header); got != value {
+			t.Errorf("%s = %q, want %q", header, got, value)
+		}
+	}
+
+	w = forward("nope")
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("expected 401 with a bearer challenge, got %d %v", w.Code, w.Header())
+	}
+	if w.Header().Get(HeaderUserID) != "" {
+		t.Error("expected no identity headers on rejection")
+	}
+	if w := forward("user"); w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := forward(""); w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
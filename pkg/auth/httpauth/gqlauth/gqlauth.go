@@ -0,0 +1,121 @@
+// Package gqlauth implements the @auth directive for gqlgen servers:
+//
+//	directive @auth(roles: [String!]) on FIELD_DEFINITION | OBJECT
+//
+// GraphQL endpoints usually serve public and protected fields from one URL,
+// so Middleware never rejects a request; it only records the bearer token.
+// The directive verifies it the first time a protected field is resolved and
+// puts the claims, principal and raw token into the resolver context.
+package gqlauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/httpauth"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// request holds the token of one HTTP request and its verification result,
+// shared by every field resolved for that request.
+type request struct {
+	token  string
+	once   sync.Once
+	claims *jwt.TokenClaims
+	err    error
+}
+
+type requestKey struct{}
+
+// Middleware records the token found by extract (httpauth.FromAuthHeader if
+// nil) and the client IP (httpauth.RemoteIP) for the @auth directive.
+// Requests without a token pass through.
+func Middleware(extract httpauth.TokenExtractor) func(http.Handler) http.Handler {
+	if extract == nil {
+		extract = httpauth.FromAuthHeader()
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, _ := extract(httpauth.RequestSource(r))
+			ctx := httpauth.WithClientIP(WithToken(r.Context(), token), httpauth.RemoteIP(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// WithToken records token for the @auth directive. Use it where there is no
+// HTTP request, e.g. in a websocket InitFunc for subscriptions.
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, requestKey{}, &request{token: token})
+}
+
+// Directive verifies tokens for @auth.
+type Directive struct {
+	verifier httpauth.TokenVerifier
+}
+
+// NewDirective returns a Directive that verifies tokens with v, typically the
+// jwt.TokenMaker or a jwt.Verifier.
+func NewDirective(v httpauth.TokenVerifier) *Directive {
+	return &Directive{verifier: v}
+}
+
+// Auth is the @auth implementation to assign to DirectiveRoot.Auth. The
+// field resolves only for authenticated callers holding at least one of
+// roles, or any authenticated caller when roles is empty.
+func (d *Directive) Auth(ctx context.Context, _ any, next graphql.Resolver, roles []string) (any, error) {
+	ctx, err := d.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := httpauth.Check(ctx, httpauth.Requirement{AnyRole: roles}); err != nil {
+		return nil, gqlErr(ctx, err)
+	}
+	return next(ctx)
+}
+
+// Resolve returns ctx carrying the verified claims, verifying the recorded
+// token once per request. Claims already placed by an httpauth middleware
+// are used as is.
+func (d *Directive) Resolve(ctx context.Context) (context.Context, error) {
+	if _, ok := httpauth.ClaimsFrom(ctx); ok {
+		return ctx, nil
+	}
+	req, _ := ctx.Value(requestKey{}).(*request)
+	if req == nil {
+		return nil, gqlErr(ctx, httpauth.ErrMissingToken)
+	}
+
+	req.once.Do(func() {
+		_, req.claims, req.err = httpauth.Authenticate(ctx, d.verifier, req.token)
+	})
+	if req.err != nil {
+		return nil, gqlErr(ctx, req.err)
+	}
+	return httpauth.WithIdentity(ctx, req.claims, req.token), nil
+}
+
+// gqlErr converts an httpauth error into a GraphQL error with the
+// UNAUTHENTICATED or FORBIDDEN extension code.
+func gqlErr(ctx context.Context, err error) error {
+	code := "UNAUTHENTICATED"
+	if errors.Is(err, httpauth.ErrForbidden) {
+		code = "FORBIDDEN"
+	}
+	extensions := map[string]any{"code": code}
+	var perr *httpauth.PermissionError
+	if errors.As(err, &perr) {
+		extensions["missingRoles"] = perr.MissingRoles
+	}
+	return &gqlerror.Error{
+		Err:        err,
+		Message:    httpauth.Message(err),
+		Path:       graphql.GetPath(ctx),
+		Extensions: extensions,
+	}
+}
@@ -0,0 +1,108 @@
+package gqlauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/httpauth"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/principal"
+)
+
+type countingVerifier struct {
+	tokens map[string]*jwt.TokenClaims
+	calls  int
+}
+
+func (v *countingVerifier) VerifyAccessToken(_ context.Context, token string) (*jwt.TokenClaims, error) {
+	v.calls++
+	if c, ok := v.tokens[token]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: unknown token", jwt.ErrInvalidToken)
+}
+
+// requestContext runs Middleware for a request with the given Authorization
+// header and returns the context handed to the GraphQL handler.
+func requestContext(t *testing.T, header string) context.Context {
+	t.Helper()
+	var ctx context.Context
+	h := Middleware(nil)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	}))
+	r := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	if header != "" {
+		r.Header.Set("Authorization", header)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	return ctx
+}
+
+func extensionCode(t *testing.T, err error) string {
+	t.Helper()
+	var gqlErr *gqlerror.Error
+	if !errors.As(err, &gqlErr) {
+		t.Fatalf("expected a *gqlerror.Error, got %v", err)
+	}
+	code, _ := gqlErr.Extensions["code"].(string)
+	return code
+}
+
+func TestAuth(t *testing.T) {
+	userID := uuid.New()
+	v := &countingVerifier{tokens: map[string]*jwt.TokenClaims{
+		"admin": {Subject: userID, Roles: []string{"admin"}},
+	}}
+	d := NewDirective(v)
+
+	resolved := func(ctx context.Context) (any, error) {
+		p, _ := principal.PrincipalFrom(ctx)
+		return p.UserID, nil
+	}
+
+	ctx := requestContext(t, "Bearer admin")
+	for range 2 {
+		res, err := d.Auth(ctx, nil, resolved, []string{"admin", "owner"})
+		if err != nil || res != userID.String() {
+			t.Fatalf("Auth = %v, %v; want %s", res, err, userID)
+		}
+	}
+	if v.calls != 1 {
+		t.Errorf("expected the token to be verified once per request, got %d", v.calls)
+	}
+
+	_, err := d.Auth(ctx, nil, resolved, []string{"owner"})
+	if code := extensionCode(t, err); code != "FORBIDDEN" || !errors.Is(err, httpauth.ErrForbidden) {
+		t.Errorf("expected FORBIDDEN, got %s (%v)", code, err)
+	}
+
+	for _, header := range []string{"", "Bearer nope"} {
+		_, err := d.Auth(requestContext(t, header), nil, resolved, nil)
+		if code := extensionCode(t, err); code != "UNAUTHENTICATED" {
+			t.Errorf("header %q: expected UNAUTHENTICATED, got %s (%v)", header, code, err)
+		}
+	}
+	if _, err := d.Auth(context.Background(), nil, resolved, nil); extensionCode(t, err) != "UNAUTHENTICATED" {
+		t.Errorf("expected UNAUTHENTICATED without Middleware, got %v", err)
+	}
+}
+
+func TestAuth_ClaimsFromHTTPMiddleware(t *testing.T) {
+	v := &countingVerifier{}
+	d := NewDirective(v)
+	ctx := httpauth.WithClaims(context.Background(), &jwt.TokenClaims{Roles: []string{"user"}})
+
+	if _, err := d.Auth(ctx, nil, func(context.Context) (any, error) { return nil, nil }, []string{"user"}); err != nil {
+		t.Errorf("expected existing claims to be used, got %v", err)
+	}
+	if v.calls != 0 {
+		t.Errorf("expected no verification, got %d calls", v.calls)
+	}
+}
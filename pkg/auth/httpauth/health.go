@@ -0,0 +1,89 @@
+package httpauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// DefaultHealthTimeout bounds a readiness probe when ReadinessHandler is
+// given no timeout.
+const DefaultHealthTimeout = 2 * time.Second
+
+// HealthCheck is one named readiness check.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// TokenMakerChecks returns the readiness checks of tm: "keys" signs and
+// verifies a probe token, "repository" pings the revocation repository and
+// invalidation source, and, if maxCleanupAge is positive, "cleanup" fails
+// when background cleanup has not succeeded within it.
+func TokenMakerChecks(tm *jwt.TokenMaker, maxCleanupAge time.Duration) []HealthCheck {
+	checks := []HealthCheck{
+		{Name: "keys", Check: tm.CheckKeys},
+		{Name: "repository", Check: tm.CheckRepository},
+	}
+	if maxCleanupAge > 0 {
+		checks = append(checks, HealthCheck{Name: "cleanup", Check: func(context.Context) error {
+			return tm.CheckCleanup(maxCleanupAge)
+		}})
+	}
+	return checks
+}
+
+// HealthBody is the JSON body of the health handlers. Checks maps each
+// check name to "ok" or "failing"; errors are logged, not returned, since
+// probes are often reachable from outside.
+type HealthBody struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// LivenessHandler always answers 200, for /healthz style probes: a process
+// that can serve it is alive, and restarting it would not fix a failing
+// dependency.
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeHealth(w, http.StatusOK, HealthBody{Status: "ok"})
+	})
+}
+
+// ReadinessHandler runs checks within timeout, for /readyz style probes. It
+// answers 200 when every check passes and 503 otherwise.
+func ReadinessHandler(timeout time.Duration, checks ...HealthCheck) http.Handler {
+	if timeout <= 0 {
+		timeout = DefaultHealthTimeout
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		body := HealthBody{Status: "ok", Checks: make(map[string]string, len(checks))}
+		status := http.StatusOK
+		for _, c := range checks {
+			if err := c.Check(ctx); err != nil {
+				logx.WithContext(ctx).Errorf("readiness check %s: %v", c.Name, err)
+				body.Checks[c.Name] = "failing"
+				body.Status = "unavailable"
+				status = http.StatusServiceUnavailable
+				continue
+			}
+			body.Checks[c.Name] = "ok"
+		}
+		writeHealth(w, status, body)
+	})
+}
+
+func writeHealth(w http.ResponseWriter, status int, body HealthBody) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,52 @@
+package httpauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	maker, err := jwt.NewTokenMaker(jwt.Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	probe := func(checks ...HealthCheck) (int, HealthBody) {
+		w := httptest.NewRecorder()
+		ReadinessHandler(0, checks...).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var body HealthBody
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return w.Code, body
+	}
+
+	checks := TokenMakerChecks(maker, time.Hour)
+	if code, body := probe(checks...); code != http.StatusOK || body.Checks["keys"] != "ok" || body.Checks["cleanup"] != "ok" {
+		t.Errorf("expected ready, got %d %+v", code, body)
+	}
+
+	failing := HealthCheck{Name: "db", Check: func(context.Context) error { return errors.New("connection refused") }}
+	code, body := probe(append(checks, failing)...)
+	if code != http.StatusServiceUnavailable || body.Status != "unavailable" || body.Checks["db"] != "failing" || body.Checks["repository"] != "ok" {
+		t.Errorf("expected db to fail readiness, got %d %+v", code, body)
+	}
+
+	w := httptest.NewRecorder()
+	LivenessHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("liveness status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,271 @@
+// Package httpauth authenticates HTTP requests with bearer JWTs. The
+// net/http middleware lives here; echoauth and fiberauth adapt the same
+// behavior to Echo and Fiber.
+//
+// A verified request carries the token's claims (ClaimsFrom), the principal
+// (principal.PrincipalFrom) and the raw token (principal.TokenFrom) in its
+// context, so downstream RPCs can be called with mdpropagate as usual.
+package httpauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/principal"
+)
+
+// Errors reported to the error handler. Verification failures are passed
+// through as returned by the TokenVerifier and wrap jwt.ErrInvalidToken.
+var (
+	ErrMissingToken         = errors.New("missing authorization header")
+	ErrInvalidAuthorization = errors.New("invalid authorization format")
+	ErrForbidden            = errors.New("forbidden")
+)
+
+// TokenVerifier verifies access tokens. Both jwt.TokenMaker and jwt.Verifier
+// satisfy it.
+type TokenVerifier interface {
+	VerifyAccessToken(ctx context.Context, tokenString string) (*jwt.TokenClaims, error)
+}
+
+type claimsKey struct{}
+
+// WithClaims stores verified claims in the context.
+func WithClaims(ctx context.Context, claims *jwt.TokenClaims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFrom retrieves the claims stored by the middleware.
+func ClaimsFrom(ctx context.Context) (*jwt.TokenClaims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(*jwt.TokenClaims)
+	return c, ok && c != nil
+}
+
+// BearerToken extracts the token from an Authorization header value. The
+// DPoP scheme of sender-constrained tokens is accepted as well; the proof
+// itself is checked during verification.
+func BearerToken(header string) (string, error) {
+	if header == "" {
+		return "", ErrMissingToken
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !(strings.EqualFold(scheme, "Bearer") || strings.EqualFold(scheme, "DPoP")) || token == "" {
+		return "", ErrInvalidAuthorization
+	}
+	return token, nil
+}
+
+// Authenticate verifies token and returns ctx carrying its claims, principal
+// and the raw token.
+func Authenticate(ctx context.Context, v TokenVerifier, token string) (context.Context, *jwt.TokenClaims, error) {
+	if token == "" {
+		return nil, nil, ErrMissingToken
+	}
+	claims, err := v.VerifyAccessToken(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return WithIdentity(ctx, claims, token), claims, nil
+}
+
+// WithIdentity returns ctx carrying verified claims, the principal derived
+// from them and the raw token they came from.
+func WithIdentity(ctx context.Context, claims *jwt.TokenClaims, token string) context.Context {
+	ctx = WithClaims(ctx, claims)
+	ctx = principal.WithPrincipal(ctx, principal.Principal{
+		UserID:    claims.Subject.String(),
+		Username:  claims.Username,
+		Roles:     claims.Roles,
+		SessionID: claims.SessionID.String(),
+	})
+	return principal.WithToken(ctx, token)
+}
+
+// Status maps an authentication error to its HTTP status code.
+func Status(err error) int {
+	if errors.Is(err, ErrForbidden) || errors.Is(err, ErrCSRFMismatch) {
+		return http.StatusForbidden
+	}
+	if errors.Is(err, jwt.ErrLockedOut) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusUnauthorized
+}
+
+// Message returns a client-safe description of an authentication error.
+// Verification details are not exposed.
+func Message(err error) string {
+	switch {
+	case errors.Is(err, ErrForbidden):
+		return ErrForbidden.Error()
+	case errors.Is(err, ErrCSRFMismatch):
+		return ErrCSRFMismatch.Error()
+	case errors.Is(err, ErrMissingToken):
+		return ErrMissingToken.Error()
+	case errors.Is(err, ErrInvalidAuthorization):
+		return ErrInvalidAuthorization.Error()
+	case errors.Is(err, jwt.ErrLockedOut):
+		return jwt.ErrLockedOut.Error()
+	default:
+		return "invalid or expired token"
+	}
+}
+
+// ErrorCode returns the machine-readable code of an authentication or
+// authorization error: one of jwt's Code constants for token errors,
+// "missing_token", "invalid_authorization", "permission_denied" or
+// "csrf_mismatch" for the errors of this package, and "unauthenticated"
+// for anything else.
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrForbidden):
+		return "permission_denied"
+	case errors.Is(err, ErrCSRFMismatch):
+		return "csrf_mismatch"
+	case errors.Is(err, ErrMissingToken):
+		return "missing_token"
+	case errors.Is(err, ErrInvalidAuthorization):
+		return "invalid_authorization"
+	}
+	if code := jwt.ErrorCode(err); code != "" {
+		return code
+	}
+	return "unauthenticated"
+}
+
+// WriteError writes the JSON error response for err with the status from
+// Status and the body from ErrorBodyFor.
+func WriteError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(Status(err))
+	_ = json.NewEncoder(w).Encode(ErrorBodyFor(err))
+}
+
+// ErrorHandler writes the response for a rejected request.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// Option configures an Authenticator.
+type Option func(*Authenticator)
+
+// WithErrorHandler replaces the default JSON error response.
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(a *Authenticator) { a.onError = h }
+}
+
+// WithExtractor replaces FromAuthHeader as the way tokens are found.
+func WithExtractor(e TokenExtractor) Option {
+	return func(a *Authenticator) { a.extract = e }
+}
+
+// WithTelemetry records every decision in metrics and the audit trail.
+func WithTelemetry(t *Telemetry) Option {
+	return func(a *Authenticator) { a.telemetry = t }
+}
+
+// WithIPExtractor replaces RemoteIP as the way the client IP is found, e.g.
+// to read X-Forwarded-For set by a trusted proxy. The IP is attached to the
+// verification context for jwt.WithLockout.
+func WithIPExtractor(f func(r *http.Request) string) Option {
+	return func(a *Authenticator) { a.clientIP = f }
+}
+
+// Authenticator builds net/http middleware around a TokenVerifier.
+type Authenticator struct {
+	verifier  TokenVerifier
+	extract   TokenExtractor
+	onError   ErrorHandler
+	telemetry *Telemetry
+	clientIP  func(r *http.Request) string
+
+	mu     sync.Mutex
+	routes []route
+}
+
+// New returns an Authenticator that verifies tokens with v.
+func New(v TokenVerifier, opts ...Option) *Authenticator {
+	a := &Authenticator{verifier: v, extract: FromAuthHeader(), onError: defaultErrorHandler, clientIP: RemoteIP}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Middleware rejects requests without a valid bearer token.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := a.authenticate(r)
+		if err != nil {
+			a.onError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Require rejects requests whose token does not meet req with a 403 listing
+// the missing permissions. It must run after Middleware.
+func (a *Authenticator) Require(req Requirement) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := a.authorize(r, req); err != nil {
+				a.onError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authenticate extracts and verifies the token of r and records the
+// decision.
+func (a *Authenticator) authenticate(r *http.Request) (context.Context, error) {
+	start := time.Now()
+	ctx, claims, err := a.verify(r)
+	a.telemetry.Observe(r.Context(), StageAuthenticate, r.Method, r.URL.Path, start, claims, err)
+	return ctx, err
+}
+
+func (a *Authenticator) verify(r *http.Request) (context.Context, *jwt.TokenClaims, error) {
+	token, err := a.extract(RequestSource(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx := WithClientIP(WithDPoPProof(r.Context(), r), a.clientIP(r))
+	return Authenticate(ctx, a.verifier, token)
+}
+
+// authorize checks req against the claims in r's context and records the
+// decision.
+func (a *Authenticator) authorize(r *http.Request, req Requirement) error {
+	start := time.Now()
+	err := Check(r.Context(), req)
+	claims, _ := ClaimsFrom(r.Context())
+	a.telemetry.Observe(r.Context(), StageAuthorize, r.Method, r.URL.Path, start, claims, err)
+	return err
+}
+
+// RequireRoles is Require with every role in roles.
+func (a *Authenticator) RequireRoles(roles ...string) func(http.Handler) http.Handler {
+	return a.Require(Requirement{Roles: roles})
+}
+
+// RequireAnyRole is Require with at least one of roles.
+func (a *Authenticator) RequireAnyRole(roles ...string) func(http.Handler) http.Handler {
+	return a.Require(Requirement{AnyRole: roles})
+}
+
+// RequireScopes is Require with every scope in scopes.
+func (a *Authenticator) RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return a.Require(Requirement{Scopes: scopes})
+}
+
+func defaultErrorHandler(w http.ResponseWriter, _ *http.Request, err error) {
+	WriteError(w, err)
+}
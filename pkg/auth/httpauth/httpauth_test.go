@@ -0,0 +1,253 @@
+package httpauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/principal"
+)
+
+type stubVerifier map[string]*jwt.TokenClaims
+
+func (s stubVerifier) VerifyAccessToken(_ context.Context, token string) (*jwt.TokenClaims, error) {
+	if c, ok := s[token]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: unknown token", jwt.ErrInvalidToken)
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+		err    error
+	}{
+		{"", "", ErrMissingToken},
+		{"Bearer abc", "abc", nil},
+		{"bearer abc", "abc", nil},
+		{"DPoP abc", "abc", nil},
+		{"Basic abc", "", ErrInvalidAuthorization},
+		{"Bearer", "", ErrInvalidAuthorization},
+		{"Bearer ", "", ErrInvalidAuthorization},
+	}
+	for _, tt := range tests {
+		got, err := BearerToken(tt.header)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("BearerToken(%q) = %q, %v; want %q, %v", tt.header, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	userID := uuid.New()
+	v := stubVerifier{
+		"admin": {Subject: userID, Username: "alice", Roles: []string{"user", "admin"}},
+		"user":  {Subject: uuid.New(), Username: "bob", Roles: []string{"user"}},
+	}
+	a := New(v)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := principal.PrincipalFrom(r.Context())
+		token, _ := principal.TokenFrom(r.Context())
+		if _, found := ClaimsFrom(r.Context()); !found {
+			t.Error("expected claims in context")
+		}
+		_, _ = fmt.Fprintf(w, "%s:%s", p.UserID, token)
+	})
+	h := a.Middleware(a.RequireRoles("admin")(ok))
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+		code   string
+	}{
+		{"missing", "", http.StatusUnauthorized, "missing_token"},
+		{"malformed", "Token admin", http.StatusUnauthorized, "invalid_authorization"},
+		{"invalid", "Bearer nope", http.StatusUnauthorized, "invalid_token"},
+		{"forbidden", "Bearer user", http.StatusForbidden, "permission_denied"},
+		{"ok", "Bearer admin", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.code == "" {
+				if want := userID.String() + ":admin"; w.Body.String() != want {
+					t.Errorf("body = %q, want %q", w.Body.String(), want)
+				}
+				return
+			}
+			var resp ErrorBody
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Code != tt.code {
+				t.Errorf("code = %q, want %q", resp.Code, tt.code)
+			}
+			if tt.status == http.StatusForbidden && !slices.Equal(resp.MissingRoles, []string{"admin"}) {
+				t.Errorf("missingRoles = %v, want [admin]", resp.MissingRoles)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ctx := WithClaims(context.Background(), &jwt.TokenClaims{Roles: []string{"user"}, Scopes: []string{"read"}})
+
+	if err := Check(ctx, Requirement{AnyRole: []string{"admin", "user"}, Scopes: []string{"read"}}); err != nil {
+		t.Errorf("expected requirement to be met, got %v", err)
+	}
+
+	err := Check(ctx, Requirement{Roles: []string{"admin", "user"}, Scopes: []string{"read", "write"}})
+	var perr *PermissionError
+	if !errors.As(err, &perr) || !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected a *PermissionError matching ErrForbidden, got %v", err)
+	}
+	if !slices.Equal(perr.MissingRoles, []string{"admin"}) || !slices.Equal(perr.MissingScopes, []string{"write"}) {
+		t.Errorf("unexpected missing permissions %+v", perr)
+	}
+
+	err = Check(ctx, Requirement{AnyRole: []string{"admin", "owner"}})
+	if !errors.As(err, &perr) || !slices.Equal(perr.MissingRoles, []string{"admin", "owner"}) {
+		t.Errorf("expected every any-of role to be reported missing, got %v", err)
+	}
+
+	if err := Check(context.Background(), Requirement{}); !errors.Is(err, ErrMissingToken) {
+		t.Errorf("expected ErrMissingToken without claims, got %v", err)
+	}
+}
+
+func TestWithErrorHandler(t *testing.T) {
+	var got error
+	a := New(stubVerifier{}, WithErrorHandler(func(w http.ResponseWriter, _ *http.Request, err error) {
+		got = err
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	h := a.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Error("handler should not run")
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer nope")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusTeapot {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusTeapot)
+	}
+	if !errors.Is(got, jwt.ErrInvalidToken) {
+		t.Errorf("expected handler to receive ErrInvalidToken, got %v", got)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteError(w, fmt.Errorf("verify: %w", jwt.ErrTokenExpired))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	var resp ErrorBody
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Code != jwt.CodeTokenExpired {
+		t.Errorf("code = %q, want %q", resp.Code, jwt.CodeTokenExpired)
+	}
+	if resp.Message != "invalid or expired token" {
+		t.Errorf("message = %q, verification details must not leak", resp.Message)
+	}
+}
+
+func newLockoutMaker(t *testing.T) *jwt.TokenMaker {
+	t.Helper()
+	maker, err := jwt.NewTokenMaker(jwt.Config{
+		Secret:   "test-secret-must-be-at-least-32-bytes",
+		Issuer:   "test-issuer",
+		Audience: "test-audience",
+	}, nil, jwt.WithLockout(jwt.NewMemoryFailureStore(), jwt.LockoutPolicy{Threshold: 2}))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	return maker
+}
+
+func TestMiddleware_Lockout(t *testing.T) {
+	maker := newLockoutMaker(t)
+	access, err := maker.CreateAccessToken(context.Background(), uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	forged := access.Token[:len(access.Token)-4] + "AAAA"
+
+	a := New(maker)
+	h := a.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	serve := func(remoteAddr, token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for range 2 {
+		if code := serve("203.0.113.7:1234", forged); code != http.StatusUnauthorized {
+			t.Fatalf("forged token: status %d, want 401", code)
+		}
+	}
+	// The port changes per connection; the lockout follows the IP.
+	if code := serve("203.0.113.7:5678", access.Token); code != http.StatusTooManyRequests {
+		t.Errorf("locked out client: status %d, want 429", code)
+	}
+	if code := serve("198.51.100.1:1234", access.Token); code != http.StatusOK {
+		t.Errorf("other client: status %d, want 200", code)
+	}
+}
+
+func TestWithIPExtractor(t *testing.T) {
+	var got jwt.ClientInfo
+	v := verifierFunc(func(ctx context.Context, _ string) (*jwt.TokenClaims, error) {
+		got, _ = jwt.ClientInfoFrom(ctx)
+		return &jwt.TokenClaims{Subject: uuid.New()}, nil
+	})
+	serve := func(a *Authenticator) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.2:1234"
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		req.Header.Set("Authorization", "Bearer token")
+		a.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	serve(New(v))
+	if got.IP != "10.0.0.2" {
+		t.Errorf("default client IP = %q, want the peer address", got.IP)
+	}
+	serve(New(v, WithIPExtractor(func(r *http.Request) string { return r.Header.Get("X-Forwarded-For") })))
+	if got.IP != "203.0.113.7" {
+		t.Errorf("extracted client IP = %q, want 203.0.113.7", got.IP)
+	}
+}
+
+type verifierFunc func(ctx context.Context, token string) (*jwt.TokenClaims, error)
+
+func (f verifierFunc) VerifyAccessToken(ctx context.Context, token string) (*jwt.TokenClaims, error) {
+	return f(ctx, token)
+}
@@ -0,0 +1,115 @@
+package httpauth
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// DefaultSecuritySchemeName is the securitySchemes key used by OpenAPI.
+const DefaultSecuritySchemeName = "bearerAuth"
+
+// OpenAPIScheme is an OpenAPI 3 Security Scheme Object.
+type OpenAPIScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// BearerScheme describes the access tokens a TokenMaker built from cfg
+// issues.
+func BearerScheme(cfg jwt.Config) OpenAPIScheme {
+	alg := cfg.AccessAlgorithm
+	if alg == "" {
+		alg = jwt.DefaultAlgorithm
+	}
+	desc := fmt.Sprintf("%s-signed access token (typ %s)", alg, jwt.AccessTokenTyp)
+	if cfg.Issuer != "" {
+		desc += " issued by " + cfg.Issuer
+	}
+	if cfg.Audience != "" {
+		desc += " for audience " + cfg.Audience
+	}
+	if cfg.AccessExpiryDuration > 0 {
+		desc += fmt.Sprintf(", valid for %s", cfg.AccessExpiryDuration)
+	}
+	return OpenAPIScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: desc + "."}
+}
+
+// OpenAPIOperation is the security part of an OpenAPI Operation Object.
+// Scopes are listed in the security requirement, which OpenAPI 3.1 allows
+// for non-OAuth schemes; roles are not expressible in OpenAPI and go into
+// extensions.
+type OpenAPIOperation struct {
+	Security []map[string][]string `json:"security"`
+	Roles    []string              `json:"x-required-roles,omitempty"`
+	AnyRole  []string              `json:"x-required-any-role,omitempty"`
+}
+
+// OpenAPISecurity holds the securitySchemes component and the per-operation
+// security of every route registered with Protect, ready to be merged into
+// an OpenAPI document.
+type OpenAPISecurity struct {
+	Components struct {
+		SecuritySchemes map[string]OpenAPIScheme `json:"securitySchemes"`
+	} `json:"components"`
+	Paths map[string]map[string]OpenAPIOperation `json:"paths"`
+}
+
+type route struct {
+	method, pattern string
+	req             Requirement
+}
+
+// Protect returns Middleware followed by Require(req) for one route and
+// records the route for OpenAPI, so the documented security is the enforced
+// one. pattern uses OpenAPI path templating, e.g. "/users/{id}", which is
+// also the net/http ServeMux wildcard syntax.
+func (a *Authenticator) Protect(method, pattern string, req Requirement) func(http.Handler) http.Handler {
+	a.mu.Lock()
+	a.routes = append(a.routes, route{method: method, pattern: pattern, req: req})
+	a.mu.Unlock()
+
+	require := a.Require(req)
+	return func(next http.Handler) http.Handler {
+		return a.Middleware(require(next))
+	}
+}
+
+// OpenAPI returns the security of the routes registered with Protect under
+// the scheme name (DefaultSecuritySchemeName if empty).
+func (a *Authenticator) OpenAPI(name string, scheme OpenAPIScheme) OpenAPISecurity {
+	if name == "" {
+		name = DefaultSecuritySchemeName
+	}
+
+	var doc OpenAPISecurity
+	doc.Components.SecuritySchemes = map[string]OpenAPIScheme{name: scheme}
+	doc.Paths = make(map[string]map[string]OpenAPIOperation)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, r := range a.routes {
+		ops := doc.Paths[r.pattern]
+		if ops == nil {
+			ops = make(map[string]OpenAPIOperation)
+			doc.Paths[r.pattern] = ops
+		}
+		scopes := slices.Clone(r.req.Scopes)
+		if scopes == nil {
+			scopes = []string{}
+		}
+		ops[strings.ToLower(r.method)] = OpenAPIOperation{
+			Security: []map[string][]string{{name: scopes}},
+			Roles:    r.req.Roles,
+			AnyRole:  r.req.AnyRole,
+		}
+	}
+	return doc
+}
@@ -0,0 +1,57 @@
+package httpauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+func TestOpenAPI(t *testing.T) {
+	a := New(stubVerifier{"reader": {Subject: uuid.New(), Roles: []string{"user"}, Scopes: []string{"read"}}})
+	mux := http.NewServeMux()
+	ok := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	mux.Handle("GET /users/{id}", a.Protect(http.MethodGet, "/users/{id}", Requirement{Scopes: []string{"read"}})(ok))
+	mux.Handle("DELETE /users/{id}", a.Protect(http.MethodDelete, "/users/{id}", Requirement{Roles: []string{"admin"}, Scopes: []string{"write"}})(ok))
+	mux.Handle("GET /me", a.Protect(http.MethodGet, "/me", Requirement{})(ok))
+
+	// The documented routes are enforced.
+	r := httptest.NewRequest(http.MethodDelete, "/users/1", nil)
+	r.Header.Set("Authorization", "Bearer reader")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	doc := a.OpenAPI("", BearerScheme(jwt.Config{Issuer: "growth", Audience: "api", AccessExpiryDuration: 15 * time.Minute}))
+	scheme := doc.Components.SecuritySchemes[DefaultSecuritySchemeName]
+	if scheme.Type != "http" || scheme.Scheme != "bearer" || scheme.BearerFormat != "JWT" {
+		t.Errorf("unexpected scheme %+v", scheme)
+	}
+	if !strings.Contains(scheme.Description, "HS256") || !strings.Contains(scheme.Description, "growth") {
+		t.Errorf("expected the description to reflect the config, got %q", scheme.Description)
+	}
+
+	del := doc.Paths["/users/{id}"]["delete"]
+	if got := del.Security[0][DefaultSecuritySchemeName]; len(got) != 1 || got[0] != "write" {
+		t.Errorf("delete scopes = %v, want [write]", got)
+	}
+	if len(del.Roles) != 1 || del.Roles[0] != "admin" {
+		t.Errorf("delete roles = %v, want [admin]", del.Roles)
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(raw), `"/me":{"get":{"security":[{"bearerAuth":[]}]}}`) {
+		t.Errorf("expected an empty scope list for /me, got %s", raw)
+	}
+}
@@ -0,0 +1,181 @@
+package httpauth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+const metricNamespace = "auth_middleware"
+
+// Decision stages.
+const (
+	StageAuthenticate = "authenticate"
+	StageAuthorize    = "authorize"
+)
+
+var (
+	decisionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "decisions_total",
+			Help:      "Total number of authentication and authorization decisions.",
+		},
+		[]string{"middleware", "stage", "outcome", "reason"},
+	)
+
+	decisionDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "decision_duration_seconds",
+			Help:      "Time spent extracting and verifying tokens or checking requirements, in seconds.",
+			Buckets:   []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25},
+		},
+		[]string{"middleware", "stage"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(decisionsTotal, decisionDuration)
+}
+
+// Decision describes one authentication or authorization outcome.
+type Decision struct {
+	// Stage is StageAuthenticate or StageAuthorize.
+	Stage string
+	// Method and Path identify the request; RPC adapters put the procedure
+	// in Path.
+	Method string
+	Path   string
+	// UserID and SessionID are set once the token has been verified.
+	UserID    string
+	SessionID string
+	Allowed   bool
+	// Reason is "ok" or the low-cardinality cause returned by Reason.
+	Reason   string
+	Err      error
+	Duration time.Duration
+}
+
+// Auditor receives every Decision, e.g. to write an audit log.
+type Auditor func(ctx context.Context, d Decision)
+
+// LogAuditor writes each Decision as a structured logx record; denials are
+// logged at error level so they stand out.
+func LogAuditor(ctx context.Context, d Decision) {
+	fields := []logx.LogField{
+		logx.Field("stage", d.Stage),
+		logx.Field("method", d.Method),
+		logx.Field("path", d.Path),
+		logx.Field("allowed", d.Allowed),
+		logx.Field("reason", d.Reason),
+		logx.Field("duration", d.Duration),
+	}
+	if d.UserID != "" {
+		fields = append(fields, logx.Field("user_id", d.UserID), logx.Field("session_id", d.SessionID))
+	}
+	if d.Allowed {
+		logx.WithContext(ctx).Infow("auth decision", fields...)
+		return
+	}
+	logx.WithContext(ctx).Errorw("auth decision", append(fields, logx.Field("error", d.Err.Error()))...)
+}
+
+// Telemetry records the decisions of a middleware. A nil *Telemetry records
+// nothing.
+type Telemetry struct {
+	// Name labels the Prometheus metrics, e.g. "gateway".
+	Name string
+	// DisableMetrics skips Prometheus and only calls Auditor.
+	DisableMetrics bool
+	// Auditor, if set, receives every decision.
+	Auditor Auditor
+}
+
+// Record reports d. A zero Reason is derived from d.Err.
+func (t *Telemetry) Record(ctx context.Context, d Decision) {
+	if t == nil {
+		return
+	}
+	d.Allowed = d.Err == nil
+	if d.Reason == "" {
+		d.Reason = Reason(d.Err)
+	}
+	if !t.DisableMetrics {
+		outcome := "allow"
+		if !d.Allowed {
+			outcome = "deny"
+		}
+		decisionsTotal.WithLabelValues(t.Name, d.Stage, outcome, d.Reason).Inc()
+		decisionDuration.WithLabelValues(t.Name, d.Stage).Observe(d.Duration.Seconds())
+	}
+	if t.Auditor != nil {
+		t.Auditor(ctx, d)
+	}
+}
+
+// Observe records the decision of stage for the request identified by
+// method and path, started at start. claims may be nil.
+func (t *Telemetry) Observe(ctx context.Context, stage, method, path string, start time.Time, claims *jwt.TokenClaims, err error) {
+	if t == nil {
+		return
+	}
+	d := Decision{Stage: stage, Method: method, Path: path, Err: err, Duration: time.Since(start)}
+	if claims != nil {
+		d.UserID, d.SessionID = claims.Subject.String(), claims.SessionID.String()
+	}
+	t.Record(ctx, d)
+}
+
+// Reason returns a low-cardinality label for an authentication or
+// authorization error, "ok" for nil. It is meant for metrics and audit logs
+// and must not be sent to clients.
+func Reason(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrMissingToken):
+		return "missing_token"
+	case errors.Is(err, ErrInvalidAuthorization):
+		return "malformed_header"
+	case errors.Is(err, ErrForbidden):
+		return "forbidden"
+	case errors.Is(err, ErrCSRFMismatch):
+		return "csrf_mismatch"
+	case errors.Is(err, jwt.ErrStepUpRequired):
+		return "step_up_required"
+	case errors.Is(err, jwt.ErrLockedOut):
+		return "locked_out"
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "expired"
+	case errors.Is(err, jwt.ErrTokenNotYetValid):
+		return "not_yet_valid"
+	case errors.Is(err, jwt.ErrInvalidSignature), errors.Is(err, jwt.ErrUnexpectedAlgorithm):
+		return "invalid_signature"
+	case errors.Is(err, jwt.ErrMalformedToken), errors.Is(err, jwt.ErrTokenTooLarge), errors.Is(err, jwt.ErrMissingClaims), errors.Is(err, jwt.ErrDecryptionFailed):
+		return "malformed_token"
+	case errors.Is(err, jwt.ErrInvalidIssuer), errors.Is(err, jwt.ErrInvalidAudience), errors.Is(err, jwt.ErrWrongTokenType):
+		return "wrong_audience_or_type"
+	case errors.Is(err, jwt.ErrTokenRevoked), errors.Is(err, jwt.ErrTokenRotated):
+		return "revoked"
+	case errors.Is(err, jwt.ErrSessionRevoked), errors.Is(err, jwt.ErrFamilyRevoked), errors.Is(err, jwt.ErrSessionExpired):
+		return "session_ended"
+	case errors.Is(err, jwt.ErrTokenInvalidated):
+		return "invalidated"
+	case errors.Is(err, jwt.ErrUnknownToken):
+		return "unknown_token"
+	case errors.Is(err, jwt.ErrDPoPProofRequired):
+		return "dpop_proof_required"
+	case errors.Is(err, jwt.ErrInvalidDPoPProof):
+		return "dpop_proof_invalid"
+	case errors.Is(err, jwt.ErrInvalidToken):
+		return "invalid_token"
+	default:
+		return "error"
+	}
+}
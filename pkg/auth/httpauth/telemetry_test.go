@@ -0,0 +1,78 @@
+package httpauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+func TestReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, "ok"},
+		{ErrMissingToken, "missing_token"},
+		{&PermissionError{MissingRoles: []string{"admin"}}, "forbidden"},
+		{fmt.Errorf("verify: %w", jwt.ErrTokenExpired), "expired"},
+		{jwt.ErrSessionRevoked, "session_ended"},
+		{jwt.ErrInvalidToken, "invalid_token"},
+		{fmt.Errorf("redis: connection refused"), "error"},
+	}
+	for _, tt := range tests {
+		if got := Reason(tt.err); got != tt.want {
+			t.Errorf("Reason(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestTelemetry(t *testing.T) {
+	userID := uuid.New()
+	var decisions []Decision
+	telemetry := &Telemetry{
+		Name:    "telemetry-test",
+		Auditor: func(_ context.Context, d Decision) { decisions = append(decisions, d) },
+	}
+	a := New(stubVerifier{"user": {Subject: userID, Roles: []string{"user"}}}, WithTelemetry(telemetry))
+	h := a.Middleware(a.RequireRoles("admin")(http.NotFoundHandler()))
+
+	for _, token := range []string{"", "user"} {
+		r := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	want := []struct {
+		stage, reason string
+		allowed       bool
+	}{
+		{StageAuthenticate, "missing_token", false},
+		{StageAuthenticate, "ok", true},
+		{StageAuthorize, "forbidden", false},
+	}
+	if len(decisions) != len(want) {
+		t.Fatalf("expected %d decisions, got %+v", len(want), decisions)
+	}
+	for i, w := range want {
+		d := decisions[i]
+		if d.Stage != w.stage || d.Reason != w.reason || d.Allowed != w.allowed || d.Path != "/admin" {
+			t.Errorf("decision %d = %+v, want %+v", i, d, w)
+		}
+	}
+	if decisions[2].UserID != userID.String() {
+		t.Errorf("expected the authorize decision to carry the user, got %q", decisions[2].UserID)
+	}
+
+	if got := testutil.ToFloat64(decisionsTotal.WithLabelValues("telemetry-test", StageAuthorize, "deny", "forbidden")); got != 1 {
+		t.Errorf("expected one denied authorize decision, got %v", got)
+	}
+}
@@ -0,0 +1,129 @@
+// Package twirpauth authenticates Twirp services with bearer JWTs.
+//
+// Twirp interceptors do not see HTTP headers, so authentication happens in
+// HTTP middleware around the generated server, as Twirp recommends. This
+// package provides that middleware with rejections written in Twirp's JSON
+// error format, so generated clients surface them as twirp.Error values with
+// the expected codes:
+//
+//	a := twirpauth.New(maker)
+//	handler := a.Middleware(twirpauth.Require(map[string]httpauth.Requirement{
+//		"admin.v1.AdminService/DeleteUser": {Roles: []string{"admin"}},
+//	})(server))
+package twirpauth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/httpauth"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// Twirp error codes used by this package.
+const (
+	CodeUnauthenticated   = "unauthenticated"
+	CodePermissionDenied  = "permission_denied"
+	CodeResourceExhausted = "resource_exhausted"
+	CodeUnavailable       = "unavailable"
+)
+
+// Error is the Twirp JSON error body.
+type Error struct {
+	Code string            `json:"code"`
+	Msg  string            `json:"msg"`
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+// New returns an httpauth.Authenticator whose rejections are Twirp errors.
+// opts may still replace the error handler.
+func New(v httpauth.TokenVerifier, opts ...httpauth.Option) *httpauth.Authenticator {
+	return httpauth.New(v, append([]httpauth.Option{httpauth.WithErrorHandler(WriteError)}, opts...)...)
+}
+
+// Require enforces per-method requirements keyed by "<package>.<Service>/<Method>".
+// Methods without an entry pass through. It must run after the
+// Authenticator's Middleware.
+func Require(reqs map[string]httpauth.Requirement) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if req, ok := reqs[method(r.URL.Path)]; ok {
+				if err := httpauth.Check(r.Context(), req); err != nil {
+					WriteError(w, r, err)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// method returns "<package>.<Service>/<Method>" from a Twirp route such as
+// "/twirp/admin.v1.AdminService/DeleteUser".
+func method(path string) string {
+	i := strings.LastIndexByte(path, '/')
+	if i <= 0 {
+		return ""
+	}
+	j := strings.LastIndexByte(path[:i], '/')
+	return path[j+1:]
+}
+
+// ErrorFor maps an authentication error to a Twirp error with a
+// client-safe message. Failures that are not the token's fault, such as an
+// unreachable revocation store, map to unavailable so clients retry.
+func ErrorFor(err error) Error {
+	var e Error
+	switch {
+	case errors.Is(err, jwt.ErrStepUpRequired):
+		e = Error{Code: CodePermissionDenied, Msg: jwt.ErrStepUpRequired.Error()}
+	case errors.Is(err, httpauth.ErrForbidden), errors.Is(err, httpauth.ErrCSRFMismatch):
+		e = Error{Code: CodePermissionDenied, Msg: httpauth.Message(err)}
+	case errors.Is(err, jwt.ErrRateLimited):
+		e = Error{Code: CodeResourceExhausted, Msg: "too many token requests"}
+	case errors.Is(err, jwt.ErrLockedOut):
+		e = Error{Code: CodeResourceExhausted, Msg: jwt.ErrLockedOut.Error()}
+	case errors.Is(err, jwt.ErrInvalidToken),
+		errors.Is(err, httpauth.ErrMissingToken),
+		errors.Is(err, httpauth.ErrInvalidAuthorization):
+		e = Error{Code: CodeUnauthenticated, Msg: httpauth.Message(err)}
+	default:
+		e = Error{Code: CodeUnavailable, Msg: "authentication unavailable"}
+	}
+
+	var perr *httpauth.PermissionError
+	if errors.As(err, &perr) {
+		e.Meta = make(map[string]string)
+		if len(perr.MissingRoles) > 0 {
+			e.Meta["missing_roles"] = strings.Join(perr.MissingRoles, ",")
+		}
+		if len(perr.MissingScopes) > 0 {
+			e.Meta["missing_scopes"] = strings.Join(perr.MissingScopes, ",")
+		}
+	}
+	return e
+}
+
+// WriteError is an httpauth.ErrorHandler writing Twirp errors.
+func WriteError(w http.ResponseWriter, _ *http.Request, err error) {
+	e := ErrorFor(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus(e.Code))
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+// httpStatus returns the HTTP status Twirp pairs with code.
+func httpStatus(code string) int {
+	switch code {
+	case CodeUnauthenticated:
+		return http.StatusUnauthorized
+	case CodePermissionDenied:
+		return http.StatusForbidden
+	case CodeResourceExhausted:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
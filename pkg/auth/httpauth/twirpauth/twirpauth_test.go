@@ -0,0 +1,92 @@
+package twirpauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/httpauth"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+type stubVerifier map[string]*jwt.TokenClaims
+
+func (s stubVerifier) VerifyAccessToken(_ context.Context, token string) (*jwt.TokenClaims, error) {
+	if token == "down" {
+		return nil, errors.New("revocation store unreachable")
+	}
+	if c, ok := s[token]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: unknown token", jwt.ErrInvalidToken)
+}
+
+func TestMiddleware(t *testing.T) {
+	a := New(stubVerifier{"user": {Subject: uuid.New(), Roles: []string{"user"}}})
+	server := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := a.Middleware(Require(map[string]httpauth.Requirement{
+		"admin.v1.AdminService/DeleteUser": {Roles: []string{"admin"}},
+	})(server))
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+		code   string
+	}{
+		{"ok", "/twirp/admin.v1.AdminService/ListUsers", "user", http.StatusOK, ""},
+		{"missing token", "/twirp/admin.v1.AdminService/ListUsers", "", http.StatusUnauthorized, CodeUnauthenticated},
+		{"invalid token", "/twirp/admin.v1.AdminService/ListUsers", "nope", http.StatusUnauthorized, CodeUnauthenticated},
+		{"store down", "/twirp/admin.v1.AdminService/ListUsers", "down", http.StatusServiceUnavailable, CodeUnavailable},
+		{"missing role", "/twirp/admin.v1.AdminService/DeleteUser", "user", http.StatusForbidden, CodePermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.code == "" {
+				return
+			}
+			var e Error
+			if err := json.NewDecoder(w.Body).Decode(&e); err != nil {
+				t.Fatalf("decode twirp error: %v", err)
+			}
+			if e.Code != tt.code {
+				t.Errorf("code = %q, want %q", e.Code, tt.code)
+			}
+			if tt.code == CodePermissionDenied && e.Meta["missing_roles"] != "admin" {
+				t.Errorf("meta = %v, want missing_roles=admin", e.Meta)
+			}
+		})
+	}
+}
+
+func TestMethod(t *testing.T) {
+	tests := map[string]string{
+		"/twirp/admin.v1.AdminService/DeleteUser":        "admin.v1.AdminService/DeleteUser",
+		"/api/prefix/twirp/admin.v1.AdminService/Delete": "admin.v1.AdminService/Delete",
+		"/": "",
+	}
+	for path, want := range tests {
+		if got := method(path); got != want {
+			t.Errorf("method(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
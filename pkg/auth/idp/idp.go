@@ -0,0 +1,285 @@
+// Package idp verifies access tokens issued by external identity providers
+// (Auth0, Firebase, AWS Cognito, Kubernetes service accounts or any OpenID
+// Connect issuer) and normalizes them into jwt.TokenClaims, so services can
+// accept IdP tokens through the same code path as locally issued ones.
+// Combine it with the local TokenMaker using Multi.
+package idp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// DefaultAlgorithms are the signing algorithms accepted when
+// Config.Algorithms is empty.
+var DefaultAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "EdDSA"}
+
+// Config configures a Verifier for one issuer.
+type Config struct {
+	// Issuer must equal the iss claim exactly, trailing slash included.
+	Issuer string
+	// Audience, if set, must be one of the aud values.
+	Audience string `json:",optional"`
+	// JWKSURL defaults to the jwks_uri of the issuer's discovery document.
+	JWKSURL string `json:",optional"`
+	// Algorithms defaults to DefaultAlgorithms.
+	Algorithms []string `json:",optional"`
+	// RolesClaim and UsernameClaim name the claims mapped to Roles and
+	// Username. RolesClaim may hold a string array or a space-separated
+	// string.
+	RolesClaim    string `json:",optional"`
+	UsernameClaim string `json:",optional"`
+	// Leeway defaults to jwt.DefaultLeeway.
+	Leeway time.Duration `json:",optional"`
+}
+
+// SubjectMapper maps an external subject to the local user ID, e.g. by
+// looking up a linked account. Returning an error fails verification.
+type SubjectMapper func(ctx context.Context, issuer, subject string) (uuid.UUID, error)
+
+// DeriveSubject is the default SubjectMapper. UUID subjects are kept;
+// anything else is mapped to a name-based UUID of issuer and subject, which
+// is stable but does not match any local account by itself.
+func DeriveSubject(_ context.Context, issuer, subject string) (uuid.UUID, error) {
+	return deriveID(issuer, subject), nil
+}
+
+func deriveID(issuer, s string) uuid.UUID {
+	if id, err := uuid.Parse(s); err == nil {
+		return id
+	}
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(issuer+"#"+s))
+}
+
+// Option configures a Verifier.
+type Option func(*Verifier)
+
+// WithSubjectMapper replaces DeriveSubject.
+func WithSubjectMapper(m SubjectMapper) Option {
+	return func(v *Verifier) { v.subject = m }
+}
+
+// WithKeyFunc replaces the JWKS endpoint as the source of signing keys.
+func WithKeyFunc(k jwt.KeyFunc) Option {
+	return func(v *Verifier) { v.keys = k }
+}
+
+// WithHTTPClient sets the client used to fetch discovery documents and
+// keys.
+func WithHTTPClient(c *http.Client) Option {
+	return func(v *Verifier) { v.client = c }
+}
+
+// WithClock sets the clock used for time-based claims.
+func WithClock(c jwt.Clock) Option {
+	return func(v *Verifier) { v.clock = c }
+}
+
+// WithValidator adds a check run on the raw claims of every token that
+// passed signature, issuer, audience and time validation.
+func WithValidator(f func(claims gojwt.MapClaims) error) Option {
+	return func(v *Verifier) { v.validators = append(v.validators, f) }
+}
+
+// Verifier verifies the access tokens of one external issuer. It satisfies
+// the same TokenVerifier interfaces as jwt.TokenMaker.
+type Verifier struct {
+	cfg        Config
+	keys       jwt.KeyFunc
+	clock      jwt.Clock
+	client     *http.Client
+	subject    SubjectMapper
+	validators []func(gojwt.MapClaims) error
+	// enrich, if set, completes the normalized claims with issuer-specific
+	// ones.
+	enrich func(raw gojwt.MapClaims, claims *jwt.TokenClaims)
+}
+
+// New returns a Verifier for cfg. Keys are fetched from the issuer's JWKS
+// with http.DefaultClient unless WithHTTPClient or WithKeyFunc is given.
+func New(cfg Config, opts ...Option) (*Verifier, error) {
+	if cfg.Issuer == "" {
+		return nil, fmt.Errorf("config.Issuer is required")
+	}
+	if cfg.Leeway == 0 {
+		cfg.Leeway = jwt.DefaultLeeway
+	}
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = DefaultAlgorithms
+	}
+	for _, alg := range cfg.Algorithms {
+		if strings.HasPrefix(alg, "HS") || alg == "none" {
+			return nil, fmt.Errorf("config.Algorithms: %q is not an asymmetric algorithm", alg)
+		}
+	}
+
+	v := &Verifier{cfg: cfg, clock: jwt.SystemClock{}, subject: DeriveSubject}
+	for _, opt := range opts {
+		opt(v)
+	}
+	if v.keys == nil {
+		v.keys = NewRemoteKeySet(cfg.JWKSURL, cfg.Issuer, v.client, v.clock)
+	}
+	return v, nil
+}
+
+// Issuer returns the issuer the Verifier accepts.
+func (v *Verifier) Issuer() string {
+	return v.cfg.Issuer
+}
+
+// VerifyAccessToken verifies tokenString and normalizes its claims.
+// Failures wrap jwt.ErrInvalidToken like those of jwt.TokenMaker.
+func (v *Verifier) VerifyAccessToken(ctx context.Context, tokenString string) (*jwt.TokenClaims, error) {
+	if len(tokenString) > jwt.DefaultMaxTokenLength {
+		return nil, jwt.ErrTokenTooLarge
+	}
+
+	opts := []gojwt.ParserOption{
+		gojwt.WithValidMethods(v.cfg.Algorithms),
+		gojwt.WithIssuer(v.cfg.Issuer),
+		gojwt.WithExpirationRequired(),
+		gojwt.WithLeeway(v.cfg.Leeway),
+		gojwt.WithTimeFunc(v.clock.Now),
+	}
+	if v.cfg.Audience != "" {
+		opts = append(opts, gojwt.WithAudience(v.cfg.Audience))
+	}
+	raw := gojwt.MapClaims{}
+	_, err := gojwt.ParseWithClaims(tokenString, raw, func(token *gojwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.keys.GetKey(kid, token.Method.Alg())
+	}, opts...)
+	if err != nil {
+		return nil, classify(err)
+	}
+	for _, validate := range v.validators {
+		if err := validate(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	return v.normalize(ctx, raw)
+}
+
+// normalize maps verified external claims onto jwt.TokenClaims.
+func (v *Verifier) normalize(ctx context.Context, raw gojwt.MapClaims) (*jwt.TokenClaims, error) {
+	sub, _ := raw.GetSubject()
+	if sub == "" {
+		return nil, jwt.ErrMissingClaims
+	}
+	subject, err := v.subject(ctx, v.cfg.Issuer, sub)
+	if err != nil {
+		return nil, fmt.Errorf("%w: map subject: %w", jwt.ErrInvalidToken, err)
+	}
+
+	claims := &jwt.TokenClaims{
+		Subject:   subject,
+		Issuer:    v.cfg.Issuer,
+		TokenType: jwt.AccessToken,
+		Username:  stringClaim(raw, v.cfg.UsernameClaim),
+		Roles:     stringsClaim(raw, v.cfg.RolesClaim),
+		ACR:       stringClaim(raw, "acr"),
+		AMR:       stringsClaim(raw, "amr"),
+		Scopes:    stringsClaim(raw, "scope"),
+	}
+	claims.Audience, _ = raw.GetAudience()
+	claims.IssuedAt, _ = raw.GetIssuedAt()
+	claims.ExpiresAt, _ = raw.GetExpirationTime()
+	claims.NotBefore, _ = raw.GetNotBefore()
+	if claims.NotBefore == nil {
+		claims.NotBefore = claims.IssuedAt
+	}
+	if t, ok := raw["auth_time"].(float64); ok {
+		claims.AuthTime = gojwt.NewNumericDate(time.Unix(int64(t), 0))
+	}
+	if jti := stringClaim(raw, "jti"); jti != "" {
+		claims.ID = deriveID(v.cfg.Issuer, jti)
+	}
+	if sid, err := uuid.Parse(stringClaim(raw, "sid")); err == nil {
+		claims.SessionID = sid
+	}
+	if v.enrich != nil {
+		v.enrich(raw, claims)
+	}
+	return claims, nil
+}
+
+func stringClaim(raw gojwt.MapClaims, name string) string {
+	s, _ := raw[name].(string)
+	return s
+}
+
+// stringsClaim reads a string array claim, or a space-separated string as
+// used by the OAuth scope claim.
+func stringsClaim(raw gojwt.MapClaims, name string) []string {
+	switch v := raw[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// classify maps golang-jwt errors onto the jwt package's sentinel errors.
+func classify(err error) error {
+	switch {
+	case errors.Is(err, gojwt.ErrTokenSignatureInvalid):
+		return jwt.ErrInvalidSignature
+	case errors.Is(err, gojwt.ErrTokenUnverifiable):
+		return fmt.Errorf("%w: %w", jwt.ErrInvalidSignature, err)
+	case errors.Is(err, gojwt.ErrTokenExpired):
+		return jwt.ErrTokenExpired
+	case errors.Is(err, gojwt.ErrTokenNotValidYet), errors.Is(err, gojwt.ErrTokenUsedBeforeIssued):
+		return jwt.ErrTokenNotYetValid
+	case errors.Is(err, gojwt.ErrTokenInvalidIssuer):
+		return jwt.ErrInvalidIssuer
+	case errors.Is(err, gojwt.ErrTokenInvalidAudience):
+		return jwt.ErrInvalidAudience
+	case errors.Is(err, gojwt.ErrTokenRequiredClaimMissing):
+		return jwt.ErrMissingClaims
+	case errors.Is(err, gojwt.ErrTokenMalformed):
+		return jwt.ErrMalformedToken
+	default:
+		return fmt.Errorf("%w: %w", jwt.ErrInvalidToken, err)
+	}
+}
+
+// requireClaim returns a validator demanding that claim equals want, e.g.
+// Cognito's token_use.
+func requireClaim(claim, want string) func(gojwt.MapClaims) error {
+	return func(raw gojwt.MapClaims) error {
+		if stringClaim(raw, claim) != want {
+			return fmt.Errorf("%w: %s is not %q", jwt.ErrWrongTokenType, claim, want)
+		}
+		return nil
+	}
+}
+
+// requireAnyOf returns a validator demanding that claim is one of want.
+func requireAnyOf(claim string, want []string) func(gojwt.MapClaims) error {
+	return func(raw gojwt.MapClaims) error {
+		if !slices.Contains(want, stringClaim(raw, claim)) {
+			return fmt.Errorf("%w: unexpected %s", jwt.ErrInvalidAudience, claim)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,229 @@
+package idp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+type testIdP struct {
+	*httptest.Server
+	key     *ecdsa.PrivateKey
+	kid     string
+	fetches atomic.Int32
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key, kid: "k1"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": idp.URL, "jwks_uri": idp.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.fetches.Add(1)
+		b64 := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "EC", "crv": "P-256", "kid": idp.kid, "use": "sig",
+			"x": b64(key.PublicKey.X.FillBytes(make([]byte, 32))),
+			"y": b64(key.PublicKey.Y.FillBytes(make([]byte, 32))),
+		}}})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// rs256Signer returns a fresh RSA key for the presets pinned to RS256 and a
+// function signing claims with it.
+func rs256Signer(t *testing.T) (*rsa.PublicKey, func(gojwt.MapClaims) string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &key.PublicKey, func(claims gojwt.MapClaims) string {
+		s, err := gojwt.NewWithClaims(gojwt.SigningMethodRS256, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+}
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (idp *testIdP) sign(t *testing.T, kid string, claims gojwt.MapClaims) string {
+	t.Helper()
+	token := gojwt.NewWithClaims(gojwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(idp.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestOIDCVerifier(t *testing.T) {
+	idp := newTestIdP(t)
+	now := time.Now()
+	clock := &fakeClock{now: now}
+	v, err := OIDC(idp.URL, "api", WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	base := func() gojwt.MapClaims {
+		return gojwt.MapClaims{
+			"iss": idp.URL, "sub": "user-42", "aud": "api",
+			"iat": now.Unix(), "exp": now.Add(time.Hour).Unix(),
+			"preferred_username": "alice", "roles": []string{"admin"}, "scope": "read write",
+		}
+	}
+
+	claims, err := v.VerifyAccessToken(ctx, idp.sign(t, "k1", base()))
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	want, _ := DeriveSubject(ctx, idp.URL, "user-42")
+	if claims.Subject != want || claims.Username != "alice" || claims.TokenType != jwt.AccessToken {
+		t.Errorf("claims = %+v", claims)
+	}
+	if len(claims.Roles) != 1 || claims.Roles[0] != "admin" || len(claims.Scopes) != 2 {
+		t.Errorf("roles/scopes = %v/%v", claims.Roles, claims.Scopes)
+	}
+	if claims.NotBefore == nil || claims.ExpiresAt == nil {
+		t.Error("expected nbf and exp to be set")
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(gojwt.MapClaims)
+		kid    string
+		err    error
+	}{
+		{"audience", func(c gojwt.MapClaims) { c["aud"] = "other" }, "k1", jwt.ErrInvalidAudience},
+		{"issuer", func(c gojwt.MapClaims) { c["iss"] = "https://evil.example.com" }, "k1", jwt.ErrInvalidIssuer},
+		{"expired", func(c gojwt.MapClaims) { c["exp"] = now.Add(-time.Hour).Unix() }, "k1", jwt.ErrTokenExpired},
+		{"no exp", func(c gojwt.MapClaims) { delete(c, "exp") }, "k1", jwt.ErrMissingClaims},
+		{"unknown kid", func(gojwt.MapClaims) {}, "k2", jwt.ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := base()
+			tt.mutate(c)
+			if _, err := v.VerifyAccessToken(ctx, idp.sign(t, tt.kid, c)); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	// Unknown kids refetch the JWKS at most once per
+	// DefaultMinRefreshInterval.
+	if n := idp.fetches.Load(); n != 1 {
+		t.Errorf("jwks fetched %d times, want 1", n)
+	}
+	clock.now = now.Add(DefaultMinRefreshInterval + time.Second)
+	if _, err := v.VerifyAccessToken(ctx, idp.sign(t, "k3", base())); err == nil {
+		t.Fatal("expected unknown kid to fail")
+	}
+	if n := idp.fetches.Load(); n != 2 {
+		t.Errorf("jwks fetched %d times, want 2", n)
+	}
+}
+
+func TestCognito(t *testing.T) {
+	pub, sign := rs256Signer(t)
+	v, err := Cognito("eu-west-1", "eu-west-1_abc", []string{"app"}, WithKeyFunc(&jwt.StaticKeyFunc{Key: pub}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	userID := uuid.New()
+	claims := func(tokenUse, clientID string) gojwt.MapClaims {
+		return gojwt.MapClaims{
+			"iss": "https://cognito-idp.eu-west-1.amazonaws.com/eu-west-1_abc", "sub": userID.String(),
+			"token_use": tokenUse, "client_id": clientID, "username": "alice",
+			"cognito:groups": []string{"admins"}, "iat": now.Unix(), "exp": now.Add(time.Hour).Unix(),
+		}
+	}
+	ctx := context.Background()
+
+	got, err := v.VerifyAccessToken(ctx, sign(claims("access", "app")))
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if got.Subject != userID || got.Username != "alice" || len(got.Roles) != 1 || got.Roles[0] != "admins" {
+		t.Errorf("claims = %+v", got)
+	}
+	if _, err := v.VerifyAccessToken(ctx, sign(claims("id", "app"))); !errors.Is(err, jwt.ErrWrongTokenType) {
+		t.Errorf("id token: err = %v, want ErrWrongTokenType", err)
+	}
+	if _, err := v.VerifyAccessToken(ctx, sign(claims("access", "other"))); !errors.Is(err, jwt.ErrInvalidAudience) {
+		t.Errorf("other client: err = %v, want ErrInvalidAudience", err)
+	}
+}
+
+func TestMulti(t *testing.T) {
+	local, err := jwt.NewTokenMaker(jwt.Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "growth",
+		Audience:             "growth-api",
+		AccessExpiryDuration: time.Minute,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, sign := rs256Signer(t)
+	auth0, err := Auth0("tenant.auth0.com", "growth-api", "https://growth/roles", WithKeyFunc(&jwt.StaticKeyFunc{Key: pub}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMulti(local, auth0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	localToken, err := local.CreateAccessToken(ctx, uuid.New(), "bob", nil, uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err := m.VerifyAccessToken(ctx, localToken.Token); err != nil || c.Username != "bob" {
+		t.Errorf("local token: claims %+v, err %v", c, err)
+	}
+
+	now := time.Now()
+	external := sign(gojwt.MapClaims{
+		"iss": "https://tenant.auth0.com/", "sub": "auth0|123", "aud": []string{"growth-api"},
+		"iat": now.Unix(), "exp": now.Add(time.Hour).Unix(), "https://growth/roles": []string{"coach"},
+	})
+	if c, err := m.VerifyAccessToken(ctx, external); err != nil || len(c.Roles) != 1 || c.Roles[0] != "coach" {
+		t.Errorf("auth0 token: claims %+v, err %v", c, err)
+	}
+
+	if _, err := NewMulti(nil, auth0, auth0); err == nil {
+		t.Error("expected an error for duplicate issuers")
+	}
+}
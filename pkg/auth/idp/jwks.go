@@ -0,0 +1,143 @@
+package idp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+const (
+	// DefaultKeyCacheTTL is how long fetched keys are used before the JWKS is
+	// fetched again.
+	DefaultKeyCacheTTL = time.Hour
+	// DefaultMinRefreshInterval limits refetches triggered by unknown kids,
+	// so tokens with random kids cannot make every request hit the IdP.
+	DefaultMinRefreshInterval = time.Minute
+	// maxJWKSBytes bounds the discovery and JWKS responses read.
+	maxJWKSBytes = 1 << 20
+	fetchTimeout = 10 * time.Second
+)
+
+// RemoteKeySet is a jwt.KeyFunc backed by an IdP's JWKS endpoint. Keys are
+// cached and refetched after DefaultKeyCacheTTL, or sooner when a token names
+// a kid the cache does not know, which is how IdPs roll keys.
+type RemoteKeySet struct {
+	// jwksURL is empty until discovered from issuer.
+	jwksURL string
+	issuer  string
+	client  *http.Client
+	clock   jwt.Clock
+
+	mu          sync.Mutex
+	keys        map[string]any
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+// NewRemoteKeySet returns a key set fetching jwksURL. With an empty
+// jwksURL, the jwks_uri of issuer's OpenID discovery document is used.
+func NewRemoteKeySet(jwksURL, issuer string, client *http.Client, clock jwt.Clock) *RemoteKeySet {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if clock == nil {
+		clock = jwt.SystemClock{}
+	}
+	return &RemoteKeySet{jwksURL: jwksURL, issuer: issuer, client: client, clock: clock}
+}
+
+// GetKey implements jwt.KeyFunc.
+func (s *RemoteKeySet) GetKey(kid, _ string) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	key, ok := s.keys[kid]
+	stale := now.Sub(s.fetchedAt) > DefaultKeyCacheTTL
+	if ok && !stale {
+		return key, nil
+	}
+	if !stale && now.Sub(s.lastAttempt) < DefaultMinRefreshInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	s.lastAttempt = now
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	keys, err := s.fetch(ctx)
+	if err != nil {
+		// Keep serving cached keys while the IdP is unreachable.
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+	s.keys, s.fetchedAt = keys, now
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+func (s *RemoteKeySet) fetch(ctx context.Context) (map[string]any, error) {
+	if s.jwksURL == "" {
+		var doc struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := s.getJSON(ctx, strings.TrimSuffix(s.issuer, "/")+"/.well-known/openid-configuration", &doc); err != nil {
+			return nil, fmt.Errorf("discover jwks: %w", err)
+		}
+		if doc.Issuer != s.issuer {
+			return nil, fmt.Errorf("discover jwks: document issuer %q does not match %q", doc.Issuer, s.issuer)
+		}
+		if doc.JWKSURI == "" {
+			return nil, fmt.Errorf("discover jwks: document has no jwks_uri")
+		}
+		s.jwksURL = doc.JWKSURI
+	}
+
+	var set struct {
+		Keys []map[string]any `json:"keys"`
+	}
+	if err := s.getJSON(ctx, s.jwksURL, &set); err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if use, _ := k["use"].(string); use != "" && use != "sig" {
+			continue
+		}
+		kid, _ := k["kid"].(string)
+		key, err := jwt.ParseJWK(k)
+		if err != nil {
+			// Skip key types we cannot use rather than fail the whole set.
+			continue
+		}
+		keys[kid] = key
+	}
+	return keys, nil
+}
+
+func (s *RemoteKeySet) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, res.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, maxJWKSBytes)).Decode(v)
+}
@@ -0,0 +1,314 @@
+package idp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// In-cluster defaults.
+const (
+	DefaultKubernetesIssuer    = "https://kubernetes.default.svc.cluster.local"
+	DefaultKubernetesAPIServer = "https://kubernetes.default.svc"
+	ServiceAccountTokenFile    = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	ServiceAccountCAFile       = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	tokenReviewPath            = "/apis/authentication.k8s.io/v1/tokenreviews"
+	serviceAccountPrefix       = "system:serviceaccount:"
+)
+
+// ErrNotServiceAccount is returned for cluster tokens that do not belong to
+// a service account.
+var ErrNotServiceAccount = fmt.Errorf("%w: not a service account token", jwt.ErrInvalidToken)
+
+// KubernetesConfig configures the verification of projected service account
+// tokens.
+type KubernetesConfig struct {
+	// Issuer is the cluster's --service-account-issuer, default
+	// DefaultKubernetesIssuer.
+	Issuer string `json:",optional"`
+	// Audience is the audience the pods request in their projected token
+	// volume, e.g. the name of this service. It is required so tokens meant
+	// for the API server are refused.
+	Audience string
+	// JWKSURL defaults to the jwks_uri of the issuer's discovery document.
+	JWKSURL string `json:",optional"`
+	// TokenFile and CAFile authenticate this pod to the API server for
+	// discovery and TokenReview; they default to the mounted service
+	// account.
+	TokenFile string `json:",optional"`
+	CAFile    string `json:",optional"`
+}
+
+func (c *KubernetesConfig) setDefaults() {
+	if c.Issuer == "" {
+		c.Issuer = DefaultKubernetesIssuer
+	}
+	if c.TokenFile == "" {
+		c.TokenFile = ServiceAccountTokenFile
+	}
+	if c.CAFile == "" {
+		c.CAFile = ServiceAccountCAFile
+	}
+}
+
+// Kubernetes returns a Verifier for projected service account tokens,
+// checked offline against the keys the cluster publishes through its OIDC
+// discovery endpoint. The username is the service account's
+// "system:serviceaccount:<namespace>:<name>" and its roles are the groups
+// Kubernetes assigns, "system:serviceaccounts" and
+// "system:serviceaccounts:<namespace>", so policies match those of
+// TokenReviewer. Tokens stay valid until they expire even if their pod is
+// deleted; use TokenReviewer where that matters.
+func Kubernetes(cfg KubernetesConfig, opts ...Option) (*Verifier, error) {
+	if cfg.Audience == "" {
+		return nil, fmt.Errorf("kubernetes token audience is required")
+	}
+	cfg.setDefaults()
+	client, err := InClusterClient(cfg.TokenFile, cfg.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]Option{WithHTTPClient(client), WithValidator(requireServiceAccount)}, opts...)
+	v, err := New(Config{
+		Issuer:        cfg.Issuer,
+		Audience:      cfg.Audience,
+		JWKSURL:       cfg.JWKSURL,
+		Algorithms:    []string{"RS256", "ES256"},
+		UsernameClaim: "sub",
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	v.enrich = func(raw gojwt.MapClaims, claims *jwt.TokenClaims) {
+		claims.Roles = serviceAccountGroups(claims.Username)
+	}
+	return v, nil
+}
+
+func requireServiceAccount(raw gojwt.MapClaims) error {
+	if _, ok := raw["kubernetes.io"].(map[string]any); !ok || !strings.HasPrefix(stringClaim(raw, "sub"), serviceAccountPrefix) {
+		return ErrNotServiceAccount
+	}
+	return nil
+}
+
+// serviceAccountGroups returns the groups Kubernetes puts service account
+// username in.
+func serviceAccountGroups(username string) []string {
+	ns, _, ok := strings.Cut(strings.TrimPrefix(username, serviceAccountPrefix), ":")
+	if !ok {
+		return nil
+	}
+	return []string{"system:serviceaccounts", "system:serviceaccounts:" + ns}
+}
+
+// TokenReviewConfig configures a TokenReviewer.
+type TokenReviewConfig struct {
+	// APIServer defaults to DefaultKubernetesAPIServer.
+	APIServer string `json:",optional"`
+	// Issuer is the cluster's --service-account-issuer, default
+	// DefaultKubernetesIssuer. It becomes the claims' issuer and keys the
+	// derived subjects, so they equal those of the Kubernetes verifier.
+	Issuer string `json:",optional"`
+	// Audiences are sent with each review; the API server refuses tokens
+	// not issued for one of them. Empty means the API server's own
+	// audience.
+	Audiences []string `json:",optional"`
+	TokenFile string   `json:",optional"`
+	CAFile    string   `json:",optional"`
+}
+
+// TokenReviewer verifies service account tokens by asking the API server
+// through the TokenReview API. Unlike the offline Kubernetes verifier it
+// refuses tokens whose pod or service account was deleted, at the cost of
+// one API call per verification. This pod's service account needs the
+// system:auth-delegator cluster role.
+type TokenReviewer struct {
+	cfg TokenReviewConfig
+	// v carries the options and normalizes the claims of reviewed tokens.
+	v *Verifier
+}
+
+// NewTokenReviewer returns a TokenReviewer for cfg. WithSubjectMapper,
+// WithValidator, WithHTTPClient and WithClock apply as for a Verifier.
+func NewTokenReviewer(cfg TokenReviewConfig, opts ...Option) (*TokenReviewer, error) {
+	if cfg.APIServer == "" {
+		cfg.APIServer = DefaultKubernetesAPIServer
+	}
+	if cfg.Issuer == "" {
+		cfg.Issuer = DefaultKubernetesIssuer
+	}
+	if cfg.TokenFile == "" {
+		cfg.TokenFile = ServiceAccountTokenFile
+	}
+	if cfg.CAFile == "" {
+		cfg.CAFile = ServiceAccountCAFile
+	}
+
+	v := &Verifier{cfg: Config{Issuer: cfg.Issuer}, clock: jwt.SystemClock{}, subject: DeriveSubject}
+	for _, opt := range opts {
+		opt(v)
+	}
+	if v.client == nil {
+		client, err := InClusterClient(cfg.TokenFile, cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		v.client = client
+	}
+	return &TokenReviewer{cfg: cfg, v: v}, nil
+}
+
+// Issuer returns the issuer the TokenReviewer accepts.
+func (r *TokenReviewer) Issuer() string {
+	return r.cfg.Issuer
+}
+
+type tokenReview struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Spec       tokenReviewSpec   `json:"spec"`
+	Status     tokenReviewStatus `json:"status"`
+}
+
+type tokenReviewSpec struct {
+	Token     string   `json:"token"`
+	Audiences []string `json:"audiences,omitempty"`
+}
+
+type tokenReviewStatus struct {
+	Authenticated bool     `json:"authenticated"`
+	Audiences     []string `json:"audiences"`
+	Error         string   `json:"error"`
+	User          struct {
+		Username string   `json:"username"`
+		Groups   []string `json:"groups"`
+	} `json:"user"`
+}
+
+// VerifyAccessToken asks the API server to authenticate tokenString and
+// normalizes the user it reports. Failures wrap jwt.ErrInvalidToken;
+// errors reaching the API server do not.
+func (r *TokenReviewer) VerifyAccessToken(ctx context.Context, tokenString string) (*jwt.TokenClaims, error) {
+	if len(tokenString) > jwt.DefaultMaxTokenLength {
+		return nil, jwt.ErrTokenTooLarge
+	}
+	// The token is parsed unverified only to be normalized; the API server
+	// is what authenticates it.
+	raw := gojwt.MapClaims{}
+	if _, _, err := gojwt.NewParser().ParseUnverified(tokenString, raw); err != nil {
+		return nil, jwt.ErrMalformedToken
+	}
+
+	status, err := r.review(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if !status.Authenticated {
+		return nil, fmt.Errorf("%w: token review: %s", jwt.ErrInvalidToken, status.Error)
+	}
+	if !strings.HasPrefix(status.User.Username, serviceAccountPrefix) {
+		return nil, ErrNotServiceAccount
+	}
+	for _, validate := range r.v.validators {
+		if err := validate(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	raw["sub"] = status.User.Username
+	claims, err := r.v.normalize(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	claims.Issuer = r.cfg.Issuer
+	claims.Username = status.User.Username
+	claims.Roles = status.User.Groups
+	if len(status.Audiences) > 0 {
+		claims.Audience = status.Audiences
+	}
+	return claims, nil
+}
+
+func (r *TokenReviewer) review(ctx context.Context, token string) (*tokenReviewStatus, error) {
+	body, err := json.Marshal(tokenReview{
+		APIVersion: "authentication.k8s.io/v1",
+		Kind:       "TokenReview",
+		Spec:       tokenReviewSpec{Token: token, Audiences: r.cfg.Audiences},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(r.cfg.APIServer, "/")+tokenReviewPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := r.v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token review: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token review: status %d", res.StatusCode)
+	}
+	var review tokenReview
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxJWKSBytes)).Decode(&review); err != nil {
+		return nil, fmt.Errorf("token review: %w", err)
+	}
+	return &review.Status, nil
+}
+
+// InClusterClient returns an HTTP client for the API server that trusts
+// caFile and sends the token in tokenFile, reread on every request because
+// the kubelet rotates it. Outside a cluster, where the files are missing,
+// it falls back to the system roots and sends no token, which suffices for
+// clusters serving discovery anonymously.
+func InClusterClient(tokenFile, caFile string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	ca, err := os.ReadFile(caFile)
+	switch {
+	case err == nil:
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates in %s", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+	return &http.Client{Transport: &bearerFileTransport{file: tokenFile, next: transport}}, nil
+}
+
+type bearerFileTransport struct {
+	file string
+	next http.RoundTripper
+}
+
+func (t *bearerFileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := os.ReadFile(t.file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return t.next.RoundTrip(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,146 @@
+package idp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+func serviceAccountClaims(sub string) gojwt.MapClaims {
+	now := time.Now()
+	return gojwt.MapClaims{
+		"iss": DefaultKubernetesIssuer,
+		"sub": sub,
+		"aud": []string{"billing"},
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+		"kubernetes.io": map[string]any{
+			"namespace":      "payments",
+			"serviceaccount": map[string]any{"name": "worker", "uid": "5f2b8a5e-9c1d-4f57-8d0e-3c1a2b4c5d6e"},
+		},
+	}
+}
+
+func TestKubernetes(t *testing.T) {
+	ctx := context.Background()
+	idp := newTestIdP(t)
+	dir := t.TempDir()
+	v, err := Kubernetes(KubernetesConfig{
+		Audience:  "billing",
+		TokenFile: filepath.Join(dir, "token"),
+		CAFile:    filepath.Join(dir, "ca.crt"),
+	}, WithKeyFunc(&jwt.StaticKeyFunc{Key: &idp.key.PublicKey}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := v.VerifyAccessToken(ctx, idp.sign(t, "k1", serviceAccountClaims("system:serviceaccount:payments:worker")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Username != "system:serviceaccount:payments:worker" {
+		t.Errorf("username = %q", claims.Username)
+	}
+	if want := []string{"system:serviceaccounts", "system:serviceaccounts:payments"}; !slices.Equal(claims.Roles, want) {
+		t.Errorf("roles = %v, want %v", claims.Roles, want)
+	}
+	if claims.Subject != deriveID(DefaultKubernetesIssuer, "system:serviceaccount:payments:worker") {
+		t.Errorf("subject = %s", claims.Subject)
+	}
+
+	apiServer := serviceAccountClaims("system:serviceaccount:payments:worker")
+	apiServer["aud"] = []string{DefaultKubernetesAPIServer}
+	if _, err := v.VerifyAccessToken(ctx, idp.sign(t, "k1", apiServer)); !errors.Is(err, jwt.ErrInvalidAudience) {
+		t.Errorf("api server audience: err = %v", err)
+	}
+	user := serviceAccountClaims("alice")
+	delete(user, "kubernetes.io")
+	if _, err := v.VerifyAccessToken(ctx, idp.sign(t, "k1", user)); !errors.Is(err, ErrNotServiceAccount) {
+		t.Errorf("non service account: err = %v", err)
+	}
+}
+
+func TestTokenReviewer(t *testing.T) {
+	ctx := context.Background()
+	idp := newTestIdP(t)
+	valid := idp.sign(t, "k1", serviceAccountClaims("system:serviceaccount:payments:worker"))
+
+	var reviews []tokenReview
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != tokenReviewPath || r.Header.Get("Authorization") != "Bearer own-token" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		var review tokenReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			t.Error(err)
+		}
+		reviews = append(reviews, review)
+		if review.Spec.Token == valid {
+			review.Status.Authenticated = true
+			review.Status.Audiences = review.Spec.Audiences
+			review.Status.User.Username = "system:serviceaccount:payments:worker"
+			review.Status.User.Groups = []string{"system:serviceaccounts", "system:serviceaccounts:payments", "system:authenticated"}
+		} else {
+			review.Status.Error = "token has been invalidated"
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(review)
+	}))
+	t.Cleanup(api.Close)
+
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("own-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := InClusterClient(tokenFile, filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewTokenReviewer(TokenReviewConfig{APIServer: api.URL, Audiences: []string{"billing"}}, WithHTTPClient(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := r.VerifyAccessToken(ctx, valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reviews) != 1 || !slices.Equal(reviews[0].Spec.Audiences, []string{"billing"}) {
+		t.Errorf("reviews = %+v", reviews)
+	}
+	if claims.Username != "system:serviceaccount:payments:worker" || !slices.Contains(claims.Roles, "system:authenticated") {
+		t.Errorf("claims = %+v", claims)
+	}
+	if claims.Subject != deriveID(DefaultKubernetesIssuer, "system:serviceaccount:payments:worker") {
+		t.Errorf("subject = %s, want the one the offline verifier derives", claims.Subject)
+	}
+
+	revoked := idp.sign(t, "k1", serviceAccountClaims("system:serviceaccount:payments:old"))
+	if _, err := r.VerifyAccessToken(ctx, revoked); !errors.Is(err, jwt.ErrInvalidToken) {
+		t.Errorf("unauthenticated: err = %v", err)
+	}
+	if _, err := r.VerifyAccessToken(ctx, "not-a-jwt"); !errors.Is(err, jwt.ErrMalformedToken) {
+		t.Errorf("malformed: err = %v", err)
+	}
+
+	m, err := NewMulti(nil, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.VerifyAccessToken(ctx, valid); err != nil {
+		t.Errorf("multi: %v", err)
+	}
+}
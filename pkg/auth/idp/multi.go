@@ -0,0 +1,63 @@
+package idp
+
+import (
+	"context"
+	"fmt"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// TokenVerifier verifies access tokens. jwt.TokenMaker, jwt.Verifier,
+// Verifier and Multi satisfy it.
+type TokenVerifier interface {
+	VerifyAccessToken(ctx context.Context, tokenString string) (*jwt.TokenClaims, error)
+}
+
+// IssuerVerifier is a TokenVerifier for the tokens of a single issuer, such
+// as Verifier and TokenReviewer.
+type IssuerVerifier interface {
+	TokenVerifier
+	Issuer() string
+}
+
+// Multi routes each token to the verifier of its iss claim, and every
+// other token, including opaque and encrypted local tokens, to a fallback
+// verifier. The iss claim is read without verification only to pick the
+// verifier, which then checks it again.
+type Multi struct {
+	fallback TokenVerifier
+	issuers  map[string]IssuerVerifier
+}
+
+// NewMulti returns a Multi over verifiers with fallback, usually the local
+// jwt.TokenMaker or jwt.Verifier. fallback may be nil to accept IdP tokens
+// only.
+func NewMulti(fallback TokenVerifier, verifiers ...IssuerVerifier) (*Multi, error) {
+	m := &Multi{fallback: fallback, issuers: make(map[string]IssuerVerifier, len(verifiers))}
+	for _, v := range verifiers {
+		if _, dup := m.issuers[v.Issuer()]; dup {
+			return nil, fmt.Errorf("duplicate verifier for issuer %q", v.Issuer())
+		}
+		m.issuers[v.Issuer()] = v
+	}
+	return m, nil
+}
+
+// VerifyAccessToken implements TokenVerifier.
+func (m *Multi) VerifyAccessToken(ctx context.Context, tokenString string) (*jwt.TokenClaims, error) {
+	if len(tokenString) > jwt.DefaultMaxTokenLength {
+		return nil, jwt.ErrTokenTooLarge
+	}
+	var unverified gojwt.RegisteredClaims
+	if _, _, err := gojwt.NewParser().ParseUnverified(tokenString, &unverified); err == nil {
+		if v, ok := m.issuers[unverified.Issuer]; ok {
+			return v.VerifyAccessToken(ctx, tokenString)
+		}
+	}
+	if m.fallback == nil {
+		return nil, jwt.ErrInvalidIssuer
+	}
+	return m.fallback.VerifyAccessToken(ctx, tokenString)
+}
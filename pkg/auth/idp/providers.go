@@ -0,0 +1,81 @@
+package idp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Auth0 returns a Verifier for access tokens of the Auth0 tenant at domain
+// (e.g. "example.eu.auth0.com") issued for the API identifier audience.
+// Auth0 only puts roles in tokens through a namespaced custom claim added
+// by an Action, so rolesClaim names it, e.g. "https://example.com/roles".
+func Auth0(domain, audience, rolesClaim string, opts ...Option) (*Verifier, error) {
+	if domain == "" || audience == "" {
+		return nil, fmt.Errorf("auth0 domain and audience are required")
+	}
+	issuer := "https://" + strings.TrimSuffix(domain, "/") + "/"
+	return New(Config{
+		Issuer:        issuer,
+		Audience:      audience,
+		JWKSURL:       issuer + ".well-known/jwks.json",
+		Algorithms:    []string{"RS256"},
+		RolesClaim:    rolesClaim,
+		UsernameClaim: "email",
+	}, opts...)
+}
+
+// FirebaseJWKSURL serves the keys of Firebase ID tokens.
+const FirebaseJWKSURL = "https://www.googleapis.com/service_accounts/v1/jwk/securetoken@system.gserviceaccount.com"
+
+// Firebase returns a Verifier for Firebase Authentication ID tokens of
+// projectID, which Firebase clients send as bearer tokens. Roles are read
+// from the "roles" custom claim set with the Admin SDK.
+func Firebase(projectID string, opts ...Option) (*Verifier, error) {
+	if projectID == "" {
+		return nil, fmt.Errorf("firebase project id is required")
+	}
+	return New(Config{
+		Issuer:        "https://securetoken.google.com/" + projectID,
+		Audience:      projectID,
+		JWKSURL:       FirebaseJWKSURL,
+		Algorithms:    []string{"RS256"},
+		RolesClaim:    "roles",
+		UsernameClaim: "email",
+	}, opts...)
+}
+
+// Cognito returns a Verifier for access tokens of the AWS Cognito user pool
+// userPoolID in region. Cognito access tokens carry no aud; client_id must
+// be one of clientIDs instead, and token_use must be "access" so ID tokens
+// are refused. Groups become roles.
+func Cognito(region, userPoolID string, clientIDs []string, opts ...Option) (*Verifier, error) {
+	if region == "" || userPoolID == "" {
+		return nil, fmt.Errorf("cognito region and user pool id are required")
+	}
+	if len(clientIDs) == 0 {
+		return nil, fmt.Errorf("at least one cognito app client id is required")
+	}
+	issuer := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, userPoolID)
+	opts = append([]Option{
+		WithValidator(requireClaim("token_use", "access")),
+		WithValidator(requireAnyOf("client_id", clientIDs)),
+	}, opts...)
+	return New(Config{
+		Issuer:        issuer,
+		JWKSURL:       issuer + "/.well-known/jwks.json",
+		Algorithms:    []string{"RS256"},
+		RolesClaim:    "cognito:groups",
+		UsernameClaim: "username",
+	}, opts...)
+}
+
+// OIDC returns a Verifier for any OpenID Connect issuer whose JWKS is found
+// through discovery. It is New with the standard claim names.
+func OIDC(issuer, audience string, opts ...Option) (*Verifier, error) {
+	return New(Config{
+		Issuer:        issuer,
+		Audience:      audience,
+		RolesClaim:    "roles",
+		UsernameClaim: "preferred_username",
+	}, opts...)
+}
@@ -0,0 +1,303 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// ActionTokenTyp is the typ header of action tokens, so they cannot be
+// confused with access or refresh tokens even when signed with the same
+// secret.
+const ActionTokenTyp = "action+jwt"
+
+// DefaultActionTokenExpiry is used when ActionTokenConfig.ExpiryDuration
+// is zero.
+const DefaultActionTokenExpiry = time.Hour
+
+// Common action token purposes.
+const (
+	PurposeEmailVerification   = "email_verification"
+	PurposeAccountConfirmation = "account_confirmation"
+)
+
+// ActionToken is the TokenType consumed action tokens are recorded under in
+// the revocation repository, keyed by their jti, so the records never mix
+// with access or refresh token revocations.
+const ActionToken TokenType = "action"
+
+// ActionClaims are the claims of a single-use action token.
+type ActionClaims struct {
+	ID      uuid.UUID `json:"jti"`
+	Subject uuid.UUID `json:"sub"`
+	// Purpose is what the token may be used for, e.g.
+	// PurposeEmailVerification.
+	Purpose string `json:"prp"`
+	// CredentialHash binds password reset tokens to the credential version
+	// they were issued for; see CreatePasswordResetToken.
+	CredentialHash string `json:"crh,omitempty"`
+	// Email and RedirectURL are set on magic link tokens; see
+	// CreateMagicLink.
+	Email       string `json:"eml,omitempty"`
+	RedirectURL string `json:"rdr,omitempty"`
+	// Inviter, Tenant and Roles are set on invitation tokens; see
+	// CreateInvitation.
+	Inviter uuid.UUID `json:"inv,omitempty"`
+	Tenant  string    `json:"tnt,omitempty"`
+	Roles   []string  `json:"rls,omitempty"`
+	// Approver, Operation, Resource and Amount are set on approval tokens;
+	// see CreateApprovalToken.
+	Approver  uuid.UUID        `json:"apr,omitempty"`
+	Operation string           `json:"opn,omitempty"`
+	Resource  string           `json:"res,omitempty"`
+	Amount    string           `json:"amt,omitempty"`
+	Issuer    string           `json:"iss"`
+	Audience  []string         `json:"aud"`
+	IssuedAt  *jwt.NumericDate `json:"iat"`
+	ExpiresAt *jwt.NumericDate `json:"exp"`
+	NotBefore *jwt.NumericDate `json:"nbf"`
+}
+
+func (c *ActionClaims) GetExpirationTime() (*jwt.NumericDate, error) { return c.ExpiresAt, nil }
+func (c *ActionClaims) GetIssuedAt() (*jwt.NumericDate, error)       { return c.IssuedAt, nil }
+func (c *ActionClaims) GetNotBefore() (*jwt.NumericDate, error)      { return c.NotBefore, nil }
+func (c *ActionClaims) GetIssuer() (string, error)                   { return c.Issuer, nil }
+func (c *ActionClaims) GetAudience() (jwt.ClaimStrings, error)       { return c.Audience, nil }
+func (c *ActionClaims) GetSubject() (string, error)                  { return c.Subject.String(), nil }
+
+// ActionTokenConfig configures an ActionTokenMaker.
+type ActionTokenConfig struct {
+	// Secret should differ from the access token secret. Like
+	// Config.Secret it must decode to at least MinSecretLength bytes.
+	Secret         string         `json:",optional" secret:"true"`
+	SecretEncoding SecretEncoding `json:",optional"`
+	Issuer         string         `json:",optional"`
+	Audience       string         `json:",optional"`
+	Algorithm      string         `json:",optional"`
+	// ExpiryDuration defaults to DefaultActionTokenExpiry and is the
+	// longest lifetime CreateActionToken accepts.
+	ExpiryDuration time.Duration `json:",optional"`
+}
+
+// ActionTokenMaker issues single-use tokens for links sent by email, such as
+// email verification and account confirmation. A token is bound to one
+// purpose and is consumed by its first successful VerifyActionToken.
+type ActionTokenMaker struct {
+	secret   []byte
+	issuer   string
+	audience string
+	method   *jwt.SigningMethodHMAC
+	parser   *jwt.Parser
+	expiry   time.Duration
+	repo     RevocationRepository
+	clock    Clock
+	audit    ApprovalAuditor
+}
+
+// NewActionTokenMaker returns an ActionTokenMaker recording consumed tokens
+// in repo, which is required and must accept ActionToken records.
+// Consumption is atomic when repo implements AtomicConsumeRepository;
+// otherwise two concurrent verifications of the same token may both
+// succeed. Of the options, only WithClock and WithApprovalAuditor apply.
+func NewActionTokenMaker(cfg ActionTokenConfig, repo RevocationRepository, opts ...Option) (*ActionTokenMaker, error) {
+	if cfg.Secret == "" {
+		return nil, fmt.Errorf("config.Secret is required")
+	}
+	secret, err := decodeSecret(cfg.Secret, cfg.SecretEncoding)
+	if err != nil {
+		return nil, fmt.Errorf("config.Secret: %w", err)
+	}
+	if err := validateIssuer(cfg.Issuer); err != nil {
+		return nil, fmt.Errorf("config.Issuer %w", err)
+	}
+	if cfg.Audience == "" {
+		return nil, fmt.Errorf("config.Audience is required")
+	}
+	if repo == nil {
+		return nil, fmt.Errorf("action tokens require a revocation repository")
+	}
+	method, err := resolveSymmetricMethod("Algorithm", cfg.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ExpiryDuration < 0 {
+		return nil, fmt.Errorf("config.ExpiryDuration must not be negative")
+	}
+	if cfg.ExpiryDuration == 0 {
+		cfg.ExpiryDuration = DefaultActionTokenExpiry
+	}
+	o := makerOptions{clock: SystemClock{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	issuer := NormalizeIssuer(cfg.Issuer)
+	// Parsers are safe for concurrent use, so one is built per maker.
+	parser := newParser(method.Alg(), o.clock,
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(cfg.Audience),
+		jwt.WithExpirationRequired(),
+	)
+	return &ActionTokenMaker{
+		secret:   secret,
+		issuer:   issuer,
+		audience: cfg.Audience,
+		method:   method,
+		parser:   parser,
+		expiry:   cfg.ExpiryDuration,
+		repo:     repo,
+		clock:    o.clock,
+		audit:    o.approvalAudit,
+	}, nil
+}
+
+// CreateActionToken issues a token letting userID perform purpose once
+// within expiry, or the configured expiry if zero. Password reset, magic
+// link and invitation tokens have their own constructors.
+func (m *ActionTokenMaker) CreateActionToken(_ context.Context, userID uuid.UUID, purpose string, expiry time.Duration) (*TokenResponse, error) {
+	switch purpose {
+	case PurposePasswordReset:
+		return nil, fmt.Errorf("use CreatePasswordResetToken for password reset tokens")
+	case PurposeMagicLink:
+		return nil, fmt.Errorf("use CreateMagicLink for magic link tokens")
+	case PurposeInvitation:
+		return nil, fmt.Errorf("use CreateInvitation for invitation tokens")
+	case PurposeApproval:
+		return nil, fmt.Errorf("use CreateApprovalToken for approval tokens")
+	}
+	return m.create(ActionClaims{Subject: userID, Purpose: purpose}, expiry)
+}
+
+func (m *ActionTokenMaker) create(claims ActionClaims, expiry time.Duration) (*TokenResponse, error) {
+	if claims.Subject == uuid.Nil && claims.Email == "" {
+		return nil, fmt.Errorf("user id is required")
+	}
+	if claims.Purpose == "" {
+		return nil, fmt.Errorf("action token purpose is required")
+	}
+	if expiry == 0 {
+		expiry = m.expiry
+	}
+	if expiry < 0 || expiry > m.expiry {
+		return nil, fmt.Errorf("action token expiry must be between 0 and %s", m.expiry)
+	}
+
+	now := m.clock.Now()
+	if claims.ID == uuid.Nil {
+		claims.ID = uuid.New()
+	}
+	claims.Issuer = m.issuer
+	claims.Audience = []string{m.audience}
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(expiry))
+
+	token := jwt.NewWithClaims(m.method, &claims)
+	token.Header["typ"] = ActionTokenTyp
+	signed, err := token.SignedString(m.secret)
+	if err != nil {
+		return nil, fmt.Errorf("sign token: %w", err)
+	}
+	return &TokenResponse{Token: signed, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
+
+// VerifyActionToken verifies that tokenString was issued for purpose and
+// consumes it, so every later call returns ErrTokenConsumed. Tokens that
+// fail verification are not consumed. Password reset and approval tokens
+// are only accepted by VerifyPasswordResetToken and VerifyApprovalToken,
+// which check what they are bound to.
+func (m *ActionTokenMaker) VerifyActionToken(ctx context.Context, tokenString, purpose string) (*ActionClaims, error) {
+	if purpose == PurposePasswordReset || purpose == PurposeApproval {
+		return nil, ErrWrongPurpose
+	}
+	claims, err := m.parse(tokenString, purpose)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.consume(ctx, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (m *ActionTokenMaker) parse(tokenString, purpose string) (*ActionClaims, error) {
+	if len(tokenString) > DefaultMaxTokenLength {
+		return nil, ErrTokenTooLarge
+	}
+	alg := m.method.Alg()
+	token, err := m.parser.ParseWithClaims(tokenString, &ActionClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if err := checkAlgHeader(token, alg); err != nil {
+			return nil, err
+		}
+		if typ, _ := token.Header["typ"].(string); typ != ActionTokenTyp {
+			return nil, ErrWrongTokenType
+		}
+		return m.secret, nil
+	})
+	if err != nil {
+		switch {
+		case token != nil && checkAlgHeader(token, alg) != nil:
+			return nil, ErrUnexpectedAlgorithm
+		case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+			return nil, ErrInvalidIssuer
+		case errors.Is(err, jwt.ErrTokenInvalidAudience):
+			return nil, ErrInvalidAudience
+		case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+			return nil, ErrMissingClaims
+		}
+		return nil, classifyParseError(err)
+	}
+
+	claims, ok := token.Claims.(*ActionClaims)
+	if !ok || claims.ID == uuid.Nil || (claims.Subject == uuid.Nil && claims.Email == "") {
+		return nil, ErrMalformedToken
+	}
+	if claims.Purpose != purpose {
+		return nil, ErrWrongPurpose
+	}
+	return claims, nil
+}
+
+// consume records claims' token as used until it expires.
+func (m *ActionTokenMaker) consume(ctx context.Context, claims *ActionClaims) error {
+	ttl := claims.ExpiresAt.Sub(m.clock.Now()) + DefaultLeeway
+	return consumeOnce(ctx, m.repo, ActionToken, claims.ID.String(), ttl)
+}
+
+// consumeOnce records key as used in repo for ttl, or returns
+// ErrTokenConsumed if it already was; see MarkTokenConsumed.
+func consumeOnce(ctx context.Context, repo RevocationRepository, tokenType TokenType, key string, ttl time.Duration) error {
+	fresh, err := MarkTokenConsumed(ctx, repo, tokenType, key, ttl)
+	if err != nil {
+		return fmt.Errorf("consume token: %w", err)
+	}
+	if !fresh {
+		return ErrTokenConsumed
+	}
+	return nil
+}
+
+// MarkTokenConsumed records token as used and reports whether this call
+// recorded it, atomically when repo implements AtomicConsumeRepository, or
+// for refresh tokens AtomicRotationRepository. Otherwise it falls back to a
+// lookup followed by a revoke, which is not race-free.
+func MarkTokenConsumed(ctx context.Context, repo RevocationRepository, tokenType TokenType, token string, ttl time.Duration) (bool, error) {
+	if atomic, ok := repo.(AtomicConsumeRepository); ok {
+		return atomic.MarkTokenConsumed(ctx, tokenType, token, ttl)
+	}
+	if atomic, ok := repo.(AtomicRotationRepository); ok && tokenType == RefreshToken {
+		return atomic.MarkTokenRotated(ctx, token, ttl)
+	}
+
+	used, err := repo.IsTokenRevoked(ctx, tokenType, token)
+	if err != nil || used {
+		return false, err
+	}
+	if err := repo.MarkTokenRevoke(ctx, tokenType, token, ttl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,364 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newActionTestMaker(t *testing.T, repo RevocationRepository, clock Clock) *ActionTokenMaker {
+	t.Helper()
+	m, err := NewActionTokenMaker(ActionTokenConfig{
+		Secret:         "action-secret-must-be-at-least-32-bytes",
+		Issuer:         "test-issuer",
+		Audience:       "test-audience",
+		ExpiryDuration: 24 * time.Hour,
+	}, repo, WithClock(clock))
+	if err != nil {
+		t.Fatalf("create action token maker: %v", err)
+	}
+	return m
+}
+
+func TestActionToken(t *testing.T) {
+	ctx := context.Background()
+	for name, repo := range map[string]RevocationRepository{
+		"check then mark": newMockRevocationRepo(),
+		"atomic":          &atomicRepo{countingRepo: &countingRepo{mockRevocationRepo: newMockRevocationRepo()}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := newActionTestMaker(t, repo, newFakeClock())
+			userID := uuid.New()
+			res, err := m.CreateActionToken(ctx, userID, PurposeEmailVerification, time.Hour)
+			if err != nil {
+				t.Fatalf("create: %v", err)
+			}
+
+			if _, err := m.VerifyActionToken(ctx, res.Token, PurposeAccountConfirmation); !errors.Is(err, ErrWrongPurpose) {
+				t.Errorf("wrong purpose: err = %v, want ErrWrongPurpose", err)
+			}
+			claims, err := m.VerifyActionToken(ctx, res.Token, PurposeEmailVerification)
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if claims.Subject != userID || claims.Purpose != PurposeEmailVerification {
+				t.Errorf("claims = %+v", claims)
+			}
+			if _, err := m.VerifyActionToken(ctx, res.Token, PurposeEmailVerification); !errors.Is(err, ErrTokenConsumed) {
+				t.Errorf("second use: err = %v, want ErrTokenConsumed", err)
+			}
+		})
+	}
+}
+
+func TestActionToken_Rejects(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	m := newActionTestMaker(t, newMockRevocationRepo(), clock)
+	userID := uuid.New()
+
+	if _, err := m.CreateActionToken(ctx, userID, PurposeEmailVerification, 48*time.Hour); err == nil {
+		t.Error("expected expiry beyond the configured maximum to be rejected")
+	}
+	if _, err := m.CreateActionToken(ctx, userID, "", 0); err == nil {
+		t.Error("expected empty purpose to be rejected")
+	}
+
+	res, err := m.CreateActionToken(ctx, userID, PurposeEmailVerification, 0)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !res.ExpiresAt.Equal(clock.Now().Add(24 * time.Hour)) {
+		t.Errorf("expires at %v, want the configured expiry", res.ExpiresAt)
+	}
+
+	maker := newSessionTestMaker(t, nil)
+	access, err := maker.CreateAccessToken(ctx, userID, "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if _, err := m.VerifyActionToken(ctx, access.Token, PurposeEmailVerification); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("access token: err = %v, want ErrInvalidToken", err)
+	}
+
+	clock.Advance(24*time.Hour + 2*DefaultLeeway)
+	if _, err := m.VerifyActionToken(ctx, res.Token, PurposeEmailVerification); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expired: err = %v, want ErrTokenExpired", err)
+	}
+
+	secret := "action-secret-must-be-at-least-32-bytes"
+	if _, err := NewActionTokenMaker(ActionTokenConfig{Secret: secret, Issuer: "i", Audience: "a"}, nil); err == nil {
+		t.Error("expected missing repository to be rejected")
+	}
+	for name, cfg := range map[string]ActionTokenConfig{
+		"short secret":   {Secret: "too-short", Issuer: "test-issuer", Audience: "a"},
+		"invalid issuer": {Secret: secret, Issuer: "https://user@auth.example.com", Audience: "a"},
+	} {
+		if _, err := NewActionTokenMaker(cfg, newMockRevocationRepo()); err == nil {
+			t.Errorf("%s: expected the config to be rejected", name)
+		}
+	}
+}
+
+// typedRepo records the token type of every revocation.
+type typedRepo struct {
+	revoked map[TokenType][]string
+}
+
+func (r *typedRepo) MarkTokenRevoke(_ context.Context, tokenType TokenType, token string, _ time.Duration) error {
+	r.revoked[tokenType] = append(r.revoked[tokenType], token)
+	return nil
+}
+
+func (r *typedRepo) IsTokenRevoked(_ context.Context, tokenType TokenType, token string) (bool, error) {
+	return slices.Contains(r.revoked[tokenType], token), nil
+}
+
+func TestActionToken_ConsumedUnderOwnType(t *testing.T) {
+	ctx := context.Background()
+	repo := &typedRepo{revoked: make(map[TokenType][]string)}
+	m, err := NewActionTokenMaker(ActionTokenConfig{
+		Secret:   "action-secret-must-be-at-least-32-bytes",
+		Issuer:   "https://auth.example.com/",
+		Audience: "test-audience",
+	}, repo, WithClock(newFakeClock()))
+	if err != nil {
+		t.Fatalf("create action token maker: %v", err)
+	}
+	res, err := m.CreateActionToken(ctx, uuid.New(), PurposeEmailVerification, 0)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	claims, err := m.VerifyActionToken(ctx, res.Token, PurposeEmailVerification)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.Issuer != "https://auth.example.com" {
+		t.Errorf("issuer = %q, want it normalized", claims.Issuer)
+	}
+	if got := repo.revoked[ActionToken]; !slices.Equal(got, []string{claims.ID.String()}) {
+		t.Errorf("action records = %v, want the token's jti", got)
+	}
+	if len(repo.revoked[RefreshToken]) != 0 {
+		t.Errorf("expected no refresh token records, got %v", repo.revoked[RefreshToken])
+	}
+}
+
+func TestPasswordResetToken(t *testing.T) {
+	ctx := context.Background()
+	m := newActionTestMaker(t, newMockRevocationRepo(), newFakeClock())
+	userID := uuid.New()
+	const oldHash = "$2a$10$old"
+
+	res, err := m.CreatePasswordResetToken(ctx, userID, oldHash, time.Hour)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := m.VerifyActionToken(ctx, res.Token, PurposePasswordReset); !errors.Is(err, ErrWrongPurpose) {
+		t.Errorf("generic verify: err = %v, want ErrWrongPurpose", err)
+	}
+
+	claims, err := m.ParsePasswordResetToken(res.Token)
+	if err != nil || claims.Subject != userID {
+		t.Fatalf("parse: %+v, %v", claims, err)
+	}
+	if _, err := m.VerifyPasswordResetToken(ctx, res.Token, "$2a$10$new"); !errors.Is(err, ErrCredentialChanged) {
+		t.Errorf("changed password: err = %v, want ErrCredentialChanged", err)
+	}
+	if _, err := m.VerifyPasswordResetToken(ctx, res.Token, oldHash); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if _, err := m.VerifyPasswordResetToken(ctx, res.Token, oldHash); !errors.Is(err, ErrTokenConsumed) {
+		t.Errorf("second use: err = %v, want ErrTokenConsumed", err)
+	}
+
+	other, err := m.CreatePasswordResetToken(ctx, uuid.New(), oldHash, 0)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := m.VerifyPasswordResetToken(ctx, other.Token, oldHash); err != nil {
+		t.Errorf("same credential version of another user: %v", err)
+	}
+	if _, err := m.CreateActionToken(ctx, userID, PurposePasswordReset, 0); err == nil {
+		t.Error("expected CreateActionToken to refuse the password reset purpose")
+	}
+}
+
+func TestMagicLink(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	m := newActionTestMaker(t, newMockRevocationRepo(), clock)
+
+	link, res, err := m.CreateMagicLink(ctx, "https://app.example.com/auth/magic?lang=en", MagicLinkRequest{
+		Email:       " Alice@Example.com ",
+		RedirectURL: "/goals/42",
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !res.ExpiresAt.Equal(clock.Now().Add(DefaultMagicLinkExpiry)) {
+		t.Errorf("expires at %v, want %v", res.ExpiresAt, clock.Now().Add(DefaultMagicLinkExpiry))
+	}
+	if !strings.HasPrefix(link, "https://app.example.com/auth/magic?") || !strings.Contains(link, "lang=en") {
+		t.Errorf("link = %q", link)
+	}
+
+	token, err := MagicLinkToken(link)
+	if err != nil || token != res.Token {
+		t.Fatalf("MagicLinkToken = %q, %v", token, err)
+	}
+	claims, err := m.VerifyMagicLink(ctx, token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.Email != "alice@example.com" || claims.RedirectURL != "/goals/42" || claims.Subject != uuid.Nil {
+		t.Errorf("claims = %+v", claims)
+	}
+	if _, err := m.VerifyMagicLink(ctx, token); !errors.Is(err, ErrTokenConsumed) {
+		t.Errorf("second use: err = %v, want ErrTokenConsumed", err)
+	}
+
+	verification, err := m.CreateActionToken(ctx, uuid.New(), PurposeEmailVerification, 0)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := m.VerifyMagicLink(ctx, verification.Token); !errors.Is(err, ErrWrongPurpose) {
+		t.Errorf("other purpose: err = %v, want ErrWrongPurpose", err)
+	}
+
+	for _, redirect := range []string{"https://evil.example/", "//evil.example", "/\\evil.example", "javascript:alert(1)"} {
+		if _, _, err := m.CreateMagicLink(ctx, "https://app.example.com/auth/magic", MagicLinkRequest{Email: "a@example.com", RedirectURL: redirect}); err == nil {
+			t.Errorf("redirect %q accepted", redirect)
+		}
+	}
+	if _, _, err := m.CreateMagicLink(ctx, "https://app.example.com/auth/magic", MagicLinkRequest{Email: "a@example.com", RedirectURL: "https://app.example.com/home"}); err != nil {
+		t.Errorf("same-origin redirect: %v", err)
+	}
+	if _, _, err := m.CreateMagicLink(ctx, "/auth/magic", MagicLinkRequest{Email: "a@example.com"}); err == nil {
+		t.Error("relative base URL accepted")
+	}
+	if _, err := MagicLinkToken("https://app.example.com/auth/magic"); !errors.Is(err, ErrMalformedToken) {
+		t.Errorf("link without token: err = %v", err)
+	}
+}
+
+func TestInvitation(t *testing.T) {
+	ctx := context.Background()
+	m := newActionTestMaker(t, newMockRevocationRepo(), newFakeClock())
+	inviter := uuid.New()
+
+	res, err := m.CreateInvitation(ctx, InvitationRequest{
+		Inviter: inviter,
+		Tenant:  "acme",
+		Email:   "Bob@Example.com",
+		Roles:   []string{"member", "billing"},
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	preview, err := m.ParseInvitation(res.Token)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if preview.Inviter != inviter || preview.Tenant != "acme" || preview.Email != "bob@example.com" {
+		t.Errorf("claims = %+v", preview)
+	}
+	claims, err := m.VerifyInvitation(ctx, res.Token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !slices.Equal(claims.Roles, []string{"member", "billing"}) || claims.Subject != uuid.Nil {
+		t.Errorf("claims = %+v", claims)
+	}
+	if _, err := m.VerifyInvitation(ctx, res.Token); !errors.Is(err, ErrTokenConsumed) {
+		t.Errorf("second use: err = %v, want ErrTokenConsumed", err)
+	}
+	if _, err := m.VerifyActionToken(ctx, res.Token, PurposeEmailVerification); !errors.Is(err, ErrWrongPurpose) {
+		t.Errorf("other purpose: err = %v, want ErrWrongPurpose", err)
+	}
+
+	for name, req := range map[string]InvitationRequest{
+		"no inviter": {Tenant: "acme", Email: "bob@example.com"},
+		"no tenant":  {Inviter: inviter, Email: "bob@example.com"},
+		"no email":   {Inviter: inviter, Tenant: "acme"},
+	} {
+		if _, err := m.CreateInvitation(ctx, req); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := m.CreateActionToken(ctx, uuid.New(), PurposeInvitation, 0); err == nil {
+		t.Error("expected CreateActionToken to refuse the invitation purpose")
+	}
+}
+
+func TestApprovalToken(t *testing.T) {
+	ctx := context.Background()
+	var events []ApprovalEvent
+	m, err := NewActionTokenMaker(ActionTokenConfig{
+		Secret:   "action-secret-must-be-at-least-32-bytes",
+		Issuer:   "test-issuer",
+		Audience: "test-audience",
+	}, newMockRevocationRepo(), WithClock(newFakeClock()), WithApprovalAuditor(func(_ context.Context, ev ApprovalEvent) {
+		events = append(events, ev)
+	}))
+	if err != nil {
+		t.Fatalf("create action token maker: %v", err)
+	}
+	requester, approver := uuid.New(), uuid.New()
+	action := ApprovedAction{Operation: "payout", Resource: "account/42", Amount: "12500 EUR"}
+
+	res, err := m.CreateApprovalToken(ctx, ApprovalRequest{ApprovedAction: action, Requester: requester, Approver: approver})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := m.VerifyActionToken(ctx, res.Token, PurposeApproval); !errors.Is(err, ErrWrongPurpose) {
+		t.Errorf("generic verify: err = %v, want ErrWrongPurpose", err)
+	}
+
+	larger := action
+	larger.Amount = "99900 EUR"
+	if _, err := m.VerifyApprovalToken(ctx, res.Token, requester, larger); !errors.Is(err, ErrApprovalMismatch) {
+		t.Errorf("other amount: err = %v, want ErrApprovalMismatch", err)
+	}
+	if _, err := m.VerifyApprovalToken(ctx, res.Token, approver, action); !errors.Is(err, ErrApprovalMismatch) {
+		t.Errorf("other requester: err = %v, want ErrApprovalMismatch", err)
+	}
+	claims, err := m.VerifyApprovalToken(ctx, res.Token, requester, action)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.Approver != approver {
+		t.Errorf("approver = %v, want %v", claims.Approver, approver)
+	}
+	if _, err := m.VerifyApprovalToken(ctx, res.Token, requester, action); !errors.Is(err, ErrTokenConsumed) {
+		t.Errorf("second use: err = %v, want ErrTokenConsumed", err)
+	}
+
+	var stages []string
+	for _, ev := range events {
+		stages = append(stages, ev.Stage)
+		if ev.TokenID != claims.ID || ev.Approver != approver {
+			t.Errorf("event = %+v", ev)
+		}
+	}
+	want := []string{ApprovalIssued, ApprovalRejected, ApprovalRejected, ApprovalExecuted, ApprovalRejected}
+	if !slices.Equal(stages, want) {
+		t.Errorf("stages = %v, want %v", stages, want)
+	}
+	if !errors.Is(events[4].Err, ErrTokenConsumed) || events[3].Action != action {
+		t.Errorf("events = %+v", events)
+	}
+
+	if _, err := m.CreateApprovalToken(ctx, ApprovalRequest{ApprovedAction: action, Requester: requester, Approver: requester}); err == nil {
+		t.Error("expected self-approval to be rejected")
+	}
+	if _, err := m.CreateApprovalToken(ctx, ApprovalRequest{Requester: requester, Approver: approver}); err == nil {
+		t.Error("expected missing operation to be rejected")
+	}
+}
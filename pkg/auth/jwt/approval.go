@@ -0,0 +1,134 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PurposeApproval is the purpose of approval tokens.
+const PurposeApproval = "approval"
+
+// Approval audit stages; see ApprovalEvent.
+const (
+	ApprovalIssued   = "issued"
+	ApprovalExecuted = "executed"
+	ApprovalRejected = "rejected"
+)
+
+// ApprovedAction is the sensitive operation an approval token authorizes.
+// Its fields are compared exactly, so format amounts consistently, e.g. in
+// minor units with the currency: "12500 EUR".
+type ApprovedAction struct {
+	// Operation names the action, e.g. "payout", and is required.
+	Operation string
+	Resource  string
+	Amount    string
+}
+
+// ApprovalRequest describes an approval to issue.
+type ApprovalRequest struct {
+	ApprovedAction
+	// Requester is the user who will execute the action, and Approver the
+	// one who approved it. They must differ.
+	Requester uuid.UUID
+	Approver  uuid.UUID
+	// Expiry defaults to the configured expiry.
+	Expiry time.Duration
+}
+
+// ApprovalEvent describes an approval token being issued, executed or
+// rejected.
+type ApprovalEvent struct {
+	// Stage is ApprovalIssued, ApprovalExecuted or ApprovalRejected.
+	Stage     string
+	TokenID   uuid.UUID
+	Requester uuid.UUID
+	Approver  uuid.UUID
+	Action    ApprovedAction
+	// Err is why the token was rejected.
+	Err error
+}
+
+// ApprovalAuditor records approval events, typically in an audit log. It
+// runs synchronously, so it should return quickly. Rejections are only
+// reported for tokens with a valid signature.
+type ApprovalAuditor func(ctx context.Context, ev ApprovalEvent)
+
+// WithApprovalAuditor sets the callback an ActionTokenMaker reports approval
+// events to.
+func WithApprovalAuditor(a ApprovalAuditor) Option {
+	return func(o *makerOptions) { o.approvalAudit = a }
+}
+
+// CreateApprovalToken issues a token recording that req.Approver approved
+// req.Requester performing req.ApprovedAction once, for two-person approval
+// of sensitive operations. Checking that the approver may approve it is up
+// to the caller.
+func (m *ActionTokenMaker) CreateApprovalToken(ctx context.Context, req ApprovalRequest) (*TokenResponse, error) {
+	if req.Operation == "" {
+		return nil, fmt.Errorf("approval operation is required")
+	}
+	if req.Approver == uuid.Nil {
+		return nil, fmt.Errorf("approval approver is required")
+	}
+	if req.Approver == req.Requester {
+		return nil, fmt.Errorf("approver must differ from the requester")
+	}
+	claims := ActionClaims{
+		ID:        uuid.New(),
+		Subject:   req.Requester,
+		Purpose:   PurposeApproval,
+		Approver:  req.Approver,
+		Operation: req.Operation,
+		Resource:  req.Resource,
+		Amount:    req.Amount,
+	}
+	res, err := m.create(claims, req.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	m.reportApproval(ctx, ApprovalIssued, &claims, nil)
+	return res, nil
+}
+
+// VerifyApprovalToken verifies that tokenString approves requester
+// performing action and consumes it, right before the action is executed.
+// It returns ErrApprovalMismatch when the token approves anything else.
+func (m *ActionTokenMaker) VerifyApprovalToken(ctx context.Context, tokenString string, requester uuid.UUID, action ApprovedAction) (*ActionClaims, error) {
+	claims, err := m.parse(tokenString, PurposeApproval)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject != requester || claims.Operation != action.Operation ||
+		claims.Resource != action.Resource || claims.Amount != action.Amount {
+		m.reportApproval(ctx, ApprovalRejected, claims, ErrApprovalMismatch)
+		return nil, ErrApprovalMismatch
+	}
+	if err := m.consume(ctx, claims); err != nil {
+		m.reportApproval(ctx, ApprovalRejected, claims, err)
+		return nil, err
+	}
+	m.reportApproval(ctx, ApprovalExecuted, claims, nil)
+	return claims, nil
+}
+
+func (m *ActionTokenMaker) reportApproval(ctx context.Context, stage string, claims *ActionClaims, err error) {
+	if m.audit == nil {
+		return
+	}
+	m.audit(ctx, ApprovalEvent{
+		Stage:     stage,
+		TokenID:   claims.ID,
+		Requester: claims.Subject,
+		Approver:  claims.Approver,
+		Action: ApprovedAction{
+			Operation: claims.Operation,
+			Resource:  claims.Resource,
+			Amount:    claims.Amount,
+		},
+		Err: err,
+	})
+}
@@ -0,0 +1,71 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BatchRevocationRepository is an optional extension of RevocationRepository
+// for bulk operations such as logout-all and revocation audits, where one
+// round trip per token is too slow.
+//
+// AreTokensRevoked returns one result per input token, in order.
+type BatchRevocationRepository interface {
+	MarkTokensRevoked(ctx context.Context, tokenType TokenType, tokens []string, ttl time.Duration) error
+	AreTokensRevoked(ctx context.Context, tokenType TokenType, tokens []string) ([]bool, error)
+}
+
+// MarkTokensRevoked revokes tokens in one batch when repo implements
+// BatchRevocationRepository and falls back to one MarkTokenRevoke per token
+// otherwise.
+func MarkTokensRevoked(ctx context.Context, repo RevocationRepository, tokenType TokenType, tokens []string, ttl time.Duration) error {
+	if repo == nil {
+		return ErrRevocationDisabled
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	if batch, ok := repo.(BatchRevocationRepository); ok {
+		return batch.MarkTokensRevoked(ctx, tokenType, tokens, ttl)
+	}
+	for _, token := range tokens {
+		if err := repo.MarkTokenRevoke(ctx, tokenType, token, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AreTokensRevoked reports the revocation state of each token, using a single
+// batch lookup when repo implements BatchRevocationRepository.
+func AreTokensRevoked(ctx context.Context, repo RevocationRepository, tokenType TokenType, tokens []string) ([]bool, error) {
+	if repo == nil {
+		return nil, ErrRevocationDisabled
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	if batch, ok := repo.(BatchRevocationRepository); ok {
+		revoked, err := batch.AreTokensRevoked(ctx, tokenType, tokens)
+		if err != nil {
+			return nil, err
+		}
+		if len(revoked) != len(tokens) {
+			return nil, fmt.Errorf("check revocation: got %d results for %d tokens", len(revoked), len(tokens))
+		}
+		return revoked, nil
+	}
+
+	revoked := make([]bool, len(tokens))
+	for i, token := range tokens {
+		r, err := repo.IsTokenRevoked(ctx, tokenType, token)
+		if err != nil {
+			return nil, err
+		}
+		revoked[i] = r
+	}
+	return revoked, nil
+}
@@ -0,0 +1,62 @@
+package jwt
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// batchRepo adds BatchRevocationRepository to countingRepo and counts batch calls.
+type batchRepo struct {
+	*countingRepo
+	batches int
+}
+
+func (r *batchRepo) MarkTokensRevoked(ctx context.Context, tokenType TokenType, tokens []string, ttl time.Duration) error {
+	r.batches++
+	for _, token := range tokens {
+		_ = r.MarkTokenRevoke(ctx, tokenType, token, ttl)
+	}
+	return nil
+}
+
+func (r *batchRepo) AreTokensRevoked(_ context.Context, _ TokenType, tokens []string) ([]bool, error) {
+	r.batches++
+	revoked := make([]bool, len(tokens))
+	for i, token := range tokens {
+		_, revoked[i] = r.revoked[token]
+	}
+	return revoked, nil
+}
+
+func TestBatchRevocation(t *testing.T) {
+	ctx := context.Background()
+	tokens := []string{"a", "b", "c"}
+
+	counting := &countingRepo{mockRevocationRepo: newMockRevocationRepo()}
+	batch := &batchRepo{countingRepo: &countingRepo{mockRevocationRepo: newMockRevocationRepo()}}
+	for _, repo := range []RevocationRepository{counting, batch} {
+		if err := MarkTokensRevoked(ctx, repo, AccessToken, tokens[:2], time.Minute); err != nil {
+			t.Fatalf("mark tokens revoked: %v", err)
+		}
+		revoked, err := AreTokensRevoked(ctx, repo, AccessToken, tokens)
+		if err != nil {
+			t.Fatalf("are tokens revoked: %v", err)
+		}
+		if want := []bool{true, true, false}; !slices.Equal(revoked, want) {
+			t.Errorf("%T: expected %v, got %v", repo, want, revoked)
+		}
+	}
+
+	if counting.lookups != len(tokens) {
+		t.Errorf("expected fallback to look up each token, got %d lookups", counting.lookups)
+	}
+	if batch.batches != 2 || batch.lookups != 0 {
+		t.Errorf("expected 2 batch calls and no single lookups, got %d and %d", batch.batches, batch.lookups)
+	}
+
+	if _, err := AreTokensRevoked(ctx, nil, AccessToken, tokens); err != ErrRevocationDisabled {
+		t.Errorf("expected ErrRevocationDisabled without a repository, got %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package jwt
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Maker issues and verifies access tokens. TokenMaker and BrancaMaker
+// satisfy it.
+type Maker interface {
+	CreateAccessToken(ctx context.Context, userID uuid.UUID, username string, roles []string, sessionID uuid.UUID) (*TokenResponse, error)
+	VerifyAccessToken(ctx context.Context, tokenString string) (*TokenClaims, error)
+}
+
+var (
+	_ Maker = (*TokenMaker)(nil)
+	_ Maker = (*BrancaMaker)(nil)
+)
+
+const (
+	brancaVersion     = 0xBA
+	brancaHeaderBytes = 1 + 4 + chacha20poly1305.NonceSizeX
+	// brancaPayloadVersion versions the binary claims layout.
+	brancaPayloadVersion = 1
+	base62Alphabet       = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// BrancaConfig configures a BrancaMaker.
+type BrancaConfig struct {
+	// Key is the 32-byte XChaCha20-Poly1305 key, base64 encoded.
+	Key string `json:",optional" secret:"true"`
+	// Issuer and Audience are not stored in the tokens, which only this key
+	// can produce, and are set on the verified claims.
+	Issuer   string `json:",optional"`
+	Audience string `json:",optional"`
+	// ExpiryDuration is the lifetime of the tokens.
+	ExpiryDuration time.Duration `json:",optional"`
+	// Leeway defaults to DefaultLeeway if zero.
+	Leeway time.Duration `json:",optional"`
+	// Clock defaults to SystemClock if nil.
+	Clock Clock `json:"-"`
+}
+
+// BrancaMaker issues Branca tokens (https://branca.io): XChaCha20-Poly1305
+// encrypted and authenticated, base62 encoded, with the claims in a compact
+// binary layout instead of JSON. They are meant for internal tokens, where
+// only services holding the key read them, and are much smaller than an
+// equivalent JWT. Revocation, sessions and the other TokenMaker features are
+// not supported.
+type BrancaMaker struct {
+	aead     cipher.AEAD
+	issuer   string
+	audience string
+	expiry   time.Duration
+	leeway   time.Duration
+	clock    Clock
+}
+
+// NewBrancaMaker returns a BrancaMaker for cfg.
+func NewBrancaMaker(cfg BrancaConfig) (*BrancaMaker, error) {
+	if cfg.Key == "" {
+		return nil, fmt.Errorf("config.Key is required")
+	}
+	key, err := decodeEncryptionKey(cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("config.Key: %w", err)
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("config.Key: must be %d bytes", chacha20poly1305.KeySize)
+	}
+	if cfg.ExpiryDuration <= 0 {
+		return nil, fmt.Errorf("config.ExpiryDuration must be positive")
+	}
+	if cfg.Leeway == 0 {
+		cfg.Leeway = DefaultLeeway
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = SystemClock{}
+	}
+	return &BrancaMaker{
+		aead:     aead,
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		expiry:   cfg.ExpiryDuration,
+		leeway:   cfg.Leeway,
+		clock:    cfg.Clock,
+	}, nil
+}
+
+// CreateAccessToken issues a Branca access token.
+func (m *BrancaMaker) CreateAccessToken(_ context.Context, userID uuid.UUID, username string, roles []string, sessionID uuid.UUID) (*TokenResponse, error) {
+	now := m.clock.Now()
+	expiresAt := now.Add(m.expiry)
+	payload := encodeBrancaClaims(uuid.New(), userID, sessionID, expiresAt, username, roles)
+
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return &TokenResponse{Token: m.seal(uint32(now.Unix()), nonce, payload), ExpiresAt: expiresAt}, nil
+}
+
+// VerifyAccessToken decrypts and authenticates tokenString and checks its
+// lifetime.
+func (m *BrancaMaker) VerifyAccessToken(_ context.Context, tokenString string) (*TokenClaims, error) {
+	if len(tokenString) > DefaultMaxTokenLength {
+		return nil, ErrTokenTooLarge
+	}
+	issuedAt, payload, err := m.open(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := decodeBrancaClaims(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.clock.Now()
+	iat := time.Unix(int64(issuedAt), 0)
+	if iat.After(now.Add(m.leeway)) {
+		return nil, ErrTokenNotYetValid
+	}
+	if !now.Before(claims.ExpiresAt.Add(m.leeway)) {
+		return nil, ErrTokenExpired
+	}
+	claims.IssuedAt = jwt.NewNumericDate(iat)
+	claims.NotBefore = claims.IssuedAt
+	claims.Issuer = m.issuer
+	if m.audience != "" {
+		claims.Audience = []string{m.audience}
+	}
+	return claims, nil
+}
+
+// seal returns the base62 Branca token of payload.
+func (m *BrancaMaker) seal(timestamp uint32, nonce, payload []byte) string {
+	header := make([]byte, brancaHeaderBytes, brancaHeaderBytes+len(payload)+m.aead.Overhead())
+	header[0] = brancaVersion
+	binary.BigEndian.PutUint32(header[1:5], timestamp)
+	copy(header[5:], nonce)
+	return base62Encode(m.aead.Seal(header, nonce, payload, header))
+}
+
+// open decodes a Branca token and returns its timestamp and payload.
+func (m *BrancaMaker) open(token string) (uint32, []byte, error) {
+	raw, ok := base62Decode(token)
+	if !ok || len(raw) < brancaHeaderBytes+m.aead.Overhead() || raw[0] != brancaVersion {
+		return 0, nil, ErrMalformedToken
+	}
+	header := raw[:brancaHeaderBytes]
+	payload, err := m.aead.Open(nil, header[5:], raw[brancaHeaderBytes:], header)
+	if err != nil {
+		return 0, nil, ErrInvalidSignature
+	}
+	return binary.BigEndian.Uint32(header[1:5]), payload, nil
+}
+
+// encodeBrancaClaims lays the claims out as: version, jti, sub, sid, exp
+// (int64 seconds), then the username and roles as uvarint length-prefixed
+// strings.
+func encodeBrancaClaims(id, subject, sessionID uuid.UUID, expiresAt time.Time, username string, roles []string) []byte {
+	b := make([]byte, 0, 1+3*16+8+1+len(username)+8*len(roles))
+	b = append(b, brancaPayloadVersion)
+	b = append(b, id[:]...)
+	b = append(b, subject[:]...)
+	b = append(b, sessionID[:]...)
+	b = binary.BigEndian.AppendUint64(b, uint64(expiresAt.Unix()))
+	b = binary.AppendUvarint(b, uint64(len(username)))
+	b = append(b, username...)
+	b = binary.AppendUvarint(b, uint64(len(roles)))
+	for _, r := range roles {
+		b = binary.AppendUvarint(b, uint64(len(r)))
+		b = append(b, r...)
+	}
+	return b
+}
+
+func decodeBrancaClaims(b []byte) (*TokenClaims, error) {
+	const fixed = 1 + 3*16 + 8
+	if len(b) < fixed || b[0] != brancaPayloadVersion {
+		return nil, ErrMalformedToken
+	}
+	claims := &TokenClaims{TokenType: AccessToken}
+	copy(claims.ID[:], b[1:17])
+	copy(claims.Subject[:], b[17:33])
+	copy(claims.SessionID[:], b[33:49])
+	claims.ExpiresAt = jwt.NewNumericDate(time.Unix(int64(binary.BigEndian.Uint64(b[49:57])), 0))
+	b = b[fixed:]
+
+	next := func() (string, error) {
+		n, k := binary.Uvarint(b)
+		if k <= 0 || n > uint64(len(b)-k) {
+			return "", ErrMalformedToken
+		}
+		s := string(b[k : k+int(n)])
+		b = b[k+int(n):]
+		return s, nil
+	}
+	var err error
+	if claims.Username, err = next(); err != nil {
+		return nil, err
+	}
+	count, k := binary.Uvarint(b)
+	if k <= 0 || count > uint64(len(b)) {
+		return nil, ErrMalformedToken
+	}
+	b = b[k:]
+	for range count {
+		role, err := next()
+		if err != nil {
+			return nil, err
+		}
+		claims.Roles = append(claims.Roles, role)
+	}
+	if len(b) != 0 {
+		return nil, ErrMalformedToken
+	}
+	return claims, nil
+}
+
+func base62Encode(b []byte) string {
+	n := new(big.Int).SetBytes(b)
+	if n.Sign() == 0 {
+		return "0"
+	}
+	var out []byte
+	base, rem := big.NewInt(62), new(big.Int)
+	for n.Sign() > 0 {
+		n.QuoRem(n, base, rem)
+		out = append(out, base62Alphabet[rem.Int64()])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+// base62Decode reverses base62Encode. Leading zero bytes are not
+// preserved, which Branca tokens never have since they start with 0xBA.
+func base62Decode(s string) ([]byte, bool) {
+	if s == "" {
+		return nil, false
+	}
+	n, base := new(big.Int), big.NewInt(62)
+	for i := 0; i < len(s); i++ {
+		d := strings.IndexByte(base62Alphabet, s[i])
+		if d < 0 {
+			return nil, false
+		}
+		n.Mul(n, base).Add(n, big.NewInt(int64(d)))
+	}
+	return n.Bytes(), true
+}
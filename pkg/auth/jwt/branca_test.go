@@ -0,0 +1,117 @@
+package jwt
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestBrancaMaker(t *testing.T, clock Clock) *BrancaMaker {
+	t.Helper()
+	m, err := NewBrancaMaker(BrancaConfig{
+		Key:            base64.StdEncoding.EncodeToString([]byte("supersecretkeyyoushouldnotcommit")),
+		Issuer:         "test-issuer",
+		Audience:       "internal",
+		ExpiryDuration: time.Minute,
+		Clock:          clock,
+	})
+	if err != nil {
+		t.Fatalf("create branca maker: %v", err)
+	}
+	return m
+}
+
+func TestBrancaMaker(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	var m Maker = newTestBrancaMaker(t, clock)
+
+	userID, sessionID := uuid.New(), uuid.New()
+	res, err := m.CreateAccessToken(ctx, userID, "alice", []string{"user", "admin"}, sessionID)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if strings.Trim(res.Token, base62Alphabet) != "" {
+		t.Errorf("token %q is not base62", res.Token)
+	}
+
+	claims, err := m.VerifyAccessToken(ctx, res.Token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.Subject != userID || claims.SessionID != sessionID || claims.Username != "alice" || !slices.Equal(claims.Roles, []string{"user", "admin"}) {
+		t.Errorf("claims = %+v", claims)
+	}
+	if claims.Issuer != "test-issuer" || !slices.Equal(claims.Audience, []string{"internal"}) || claims.TokenType != AccessToken {
+		t.Errorf("claims = %+v", claims)
+	}
+	if !claims.IssuedAt.Equal(clock.Now()) || !claims.ExpiresAt.Equal(res.ExpiresAt) {
+		t.Errorf("iat = %v, exp = %v", claims.IssuedAt, claims.ExpiresAt)
+	}
+
+	jwtMaker := newSessionTestMaker(t, nil)
+	jwtRes, err := jwtMaker.CreateAccessToken(ctx, userID, "alice", []string{"user", "admin"}, sessionID)
+	if err != nil {
+		t.Fatalf("create jwt: %v", err)
+	}
+	if len(res.Token) >= len(jwtRes.Token) {
+		t.Errorf("branca token (%d bytes) not shorter than jwt (%d bytes)", len(res.Token), len(jwtRes.Token))
+	}
+
+	clock.Advance(time.Minute + DefaultLeeway)
+	if _, err := m.VerifyAccessToken(ctx, res.Token); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expired: err = %v, want ErrTokenExpired", err)
+	}
+}
+
+func TestBrancaMaker_Rejects(t *testing.T) {
+	ctx := context.Background()
+	m := newTestBrancaMaker(t, newFakeClock())
+	res, err := m.CreateAccessToken(ctx, uuid.New(), "", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	other, err := NewBrancaMaker(BrancaConfig{
+		Key:            base64.StdEncoding.EncodeToString([]byte("anothersecretkeyof32bytesxxxxxxx")),
+		ExpiryDuration: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("create branca maker: %v", err)
+	}
+	if _, err := other.VerifyAccessToken(ctx, res.Token); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("other key: err = %v, want ErrInvalidSignature", err)
+	}
+
+	tampered := []byte(res.Token)
+	tampered[len(tampered)-5] ^= 'a' ^ 'b'
+	for name, token := range map[string]string{
+		"tampered":   string(tampered),
+		"not base62": "not-base62!",
+		"jwt":        "eyJhbGciOiJIUzI1NiJ9.e30.sig",
+		"empty":      "",
+	} {
+		if _, err := m.VerifyAccessToken(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidToken", name, err)
+		}
+	}
+
+	if _, err := NewBrancaMaker(BrancaConfig{Key: base64.StdEncoding.EncodeToString([]byte("short")), ExpiryDuration: time.Minute}); err == nil {
+		t.Error("expected error for short key")
+	}
+}
+
+func TestBase62(t *testing.T) {
+	for _, b := range [][]byte{{0xBA}, {0xBA, 0, 0, 1}, []byte("\xbaHello world!")} {
+		got, ok := base62Decode(base62Encode(b))
+		if !ok || string(got) != string(b) {
+			t.Errorf("round trip of %x = %x", b, got)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// Cleaner is an optional extension of RevocationRepository for stores without
+// native expiry, which keep expired entries until they are deleted.
+type Cleaner interface {
+	// CleanupExpired deletes expired entries and returns how many were removed.
+	CleanupExpired(ctx context.Context) (int64, error)
+}
+
+// CleanupExpired cleans up v if it implements Cleaner and returns 0 otherwise.
+// Repository decorators use it to forward cleanup to the store they wrap.
+func CleanupExpired(ctx context.Context, v any) (int64, error) {
+	if c, ok := v.(Cleaner); ok {
+		return c.CleanupExpired(ctx)
+	}
+	return 0, nil
+}
+
+// CleanupNow deletes expired revocations from the repository immediately and
+// returns how many were removed, for cron jobs and admin tools. Rotated
+// refresh tokens are stored as refresh revocations and are included in the
+// count. Stores that expire entries natively, such as Redis, report zero.
+// Every run is recorded in Stats.
+func (tm *TokenMaker) CleanupNow(ctx context.Context) (int64, error) {
+	if tm.repo == nil {
+		return 0, ErrRevocationDisabled
+	}
+
+	start := tm.clock.Now()
+	n, err := CleanupExpired(ctx, tm.repo)
+	tm.stats.recordCleanup(CleanupRun{Start: start, Duration: tm.clock.Now().Sub(start), Removed: n, Err: err})
+	if err != nil {
+		return n, fmt.Errorf("cleanup revocations: %w", err)
+	}
+	return n, nil
+}
+
+// CleanupSchedule decides when background cleanup runs. Its method set
+// matches the Schedule interface of github.com/robfig/cron/v3, so a parsed
+// cron expression can be passed to WithCleanupSchedule as is.
+type CleanupSchedule interface {
+	// Next returns the next run time after t, or the zero time to stop.
+	Next(t time.Time) time.Time
+}
+
+// everySchedule runs cleanup at a fixed interval.
+type everySchedule time.Duration
+
+func (d everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(d))
+}
+
+// WithCleanupInterval runs CleanupNow every interval in a background
+// goroutine until Close is called, for stores that implement Cleaner. Without
+// a cleanup option the TokenMaker starts no goroutines, and cleanup is left
+// to the caller.
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(o *makerOptions) {
+		o.cleanupSchedule = nil
+		if interval > 0 {
+			o.cleanupSchedule = everySchedule(interval)
+		}
+	}
+}
+
+// WithCleanupSchedule is WithCleanupInterval with runs at the times given by
+// s, such as a cron expression.
+func WithCleanupSchedule(s CleanupSchedule) Option {
+	return func(o *makerOptions) { o.cleanupSchedule = s }
+}
+
+// WithCleanupJitter delays every background cleanup run by a random duration
+// below jitter, so instances sharing a store do not all clean it at once.
+func WithCleanupJitter(jitter time.Duration) Option {
+	return func(o *makerOptions) { o.cleanupJitter = jitter }
+}
+
+// WithoutBackgroundCleanup never starts the cleanup goroutine, whatever other
+// options say. Use it when an external job scheduler calls CleanupNow; see
+// also Config.DisableBackgroundCleanup.
+func WithoutBackgroundCleanup() Option {
+	return func(o *makerOptions) { o.cleanupDisabled = true }
+}
+
+// startCleanup launches the background cleanup loop if one is configured and
+// the repository needs it.
+func (tm *TokenMaker) startCleanup(o makerOptions) {
+	if o.cleanupDisabled || o.cleanupSchedule == nil {
+		return
+	}
+	if _, ok := tm.repo.(Cleaner); !ok {
+		return
+	}
+
+	after := time.After
+	if c, ok := tm.clock.(TimerClock); ok {
+		after = c.After
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tm.stopCleanup = cancel
+	tm.cleanupDone = make(chan struct{})
+	go func() {
+		defer close(tm.cleanupDone)
+		for {
+			now := tm.clock.Now()
+			next := o.cleanupSchedule.Next(now)
+			if next.IsZero() {
+				return
+			}
+			delay := next.Sub(now)
+			if o.cleanupJitter > 0 {
+				delay += rand.N(o.cleanupJitter)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-after(delay):
+			}
+			start := tm.clock.Now()
+			n, err := tm.CleanupNow(ctx)
+			switch {
+			case err != nil && ctx.Err() == nil:
+				logx.WithContext(ctx).Errorf("jwt: %v", err)
+			case err == nil:
+				logx.WithContext(ctx).Infof("jwt: cleanup removed %d expired revocations in %s", n, tm.clock.Now().Sub(start))
+			}
+		}
+	}()
+}
+
+// Close stops background cleanup and waits for a run in progress to finish.
+// It does not close the repository, which the caller owns. Close is safe to
+// call more than once; the TokenMaker stays usable for issuing and verifying
+// tokens afterwards.
+func (tm *TokenMaker) Close() error {
+	tm.closeOnce.Do(func() {
+		if tm.stopCleanup != nil {
+			tm.stopCleanup()
+			<-tm.cleanupDone
+		}
+	})
+	return nil
+}
@@ -0,0 +1,148 @@
+package jwt
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// cleanerRepo adds Cleaner to mockRevocationRepo.
+type cleanerRepo struct {
+	*mockRevocationRepo
+}
+
+func (r *cleanerRepo) CleanupExpired(context.Context) (int64, error) {
+	n := int64(len(r.revoked))
+	clear(r.revoked)
+	return n, nil
+}
+
+func TestCleanupNow(t *testing.T) {
+	repo := &cleanerRepo{mockRevocationRepo: newMockRevocationRepo()}
+	maker := newSessionTestMaker(t, repo)
+	ctx := context.Background()
+
+	for _, token := range []string{"a", "b"} {
+		if err := repo.MarkTokenRevoke(ctx, AccessToken, token, time.Minute); err != nil {
+			t.Fatalf("mark: %v", err)
+		}
+	}
+	if n, err := maker.CleanupNow(ctx); err != nil || n != 2 {
+		t.Errorf("expected 2 entries removed, got %d, %v", n, err)
+	}
+
+	// Stores without a cleanup hook have nothing to remove.
+	plain := newSessionTestMaker(t, newMockRevocationRepo())
+	if n, err := plain.CleanupNow(ctx); err != nil || n != 0 {
+		t.Errorf("expected no-op cleanup, got %d, %v", n, err)
+	}
+}
+
+// countingCleaner counts CleanupExpired calls.
+type countingCleaner struct {
+	*mockRevocationRepo
+	calls atomic.Int64
+}
+
+func (r *countingCleaner) CleanupExpired(context.Context) (int64, error) {
+	r.calls.Add(1)
+	return 0, nil
+}
+
+func TestWithCleanupInterval(t *testing.T) {
+	repo := &countingCleaner{mockRevocationRepo: newMockRevocationRepo()}
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:   "test-secret-must-be-at-least-32-bytes",
+		Issuer:   "test-issuer",
+		Audience: "test-audience",
+	}, repo, WithClock(clock), WithCleanupInterval(time.Minute))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	if d := <-clock.waiting; d != time.Minute {
+		t.Fatalf("expected cleanup to wait a minute, got %v", d)
+	}
+	clock.Advance(59 * time.Second)
+	if clock.pending() != 1 {
+		t.Fatal("expected cleanup not to run before the interval")
+	}
+	clock.Advance(time.Second)
+	// The loop waits on its next timer only after the run finished.
+	<-clock.waiting
+	if n := repo.calls.Load(); n != 1 {
+		t.Errorf("expected 1 cleanup run, got %d", n)
+	}
+
+	if err := maker.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := maker.Close(); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+	clock.Advance(time.Hour)
+	if n := repo.calls.Load(); n != 1 {
+		t.Errorf("expected cleanup to stop after Close, got %d more runs", n-1)
+	}
+}
+
+// limitedSchedule runs immediately a fixed number of times, then stops.
+type limitedSchedule struct {
+	runs atomic.Int64
+}
+
+func (s *limitedSchedule) Next(t time.Time) time.Time {
+	if s.runs.Add(1) > 2 {
+		return time.Time{}
+	}
+	return t
+}
+
+func TestWithCleanupSchedule(t *testing.T) {
+	repo := &countingCleaner{mockRevocationRepo: newMockRevocationRepo()}
+	cfg := Config{
+		Secret:   "test-secret-must-be-at-least-32-bytes",
+		Issuer:   "test-issuer",
+		Audience: "test-audience",
+	}
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(cfg, repo, WithClock(clock), WithCleanupSchedule(&limitedSchedule{}), WithCleanupJitter(time.Millisecond))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	// Every run is delayed by at most the jitter.
+	for i := 0; i < 2; i++ {
+		if d := <-clock.waiting; d < 0 || d >= time.Millisecond {
+			t.Errorf("expected a delay below the jitter, got %v", d)
+		}
+		clock.Advance(time.Millisecond)
+	}
+	// The loop exits on its own once the schedule returns the zero time.
+	<-maker.cleanupDone
+	if n := repo.calls.Load(); n != 2 {
+		t.Errorf("expected 2 cleanup runs, got %d", n)
+	}
+	if err := maker.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	disabled, err := NewTokenMaker(cfg, repo, WithCleanupInterval(time.Millisecond), WithoutBackgroundCleanup())
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	if disabled.cleanupDone != nil {
+		t.Error("expected no cleanup goroutine with WithoutBackgroundCleanup")
+	}
+
+	cfg.DisableBackgroundCleanup = true
+	disabled, err = NewTokenMaker(cfg, repo, WithCleanupInterval(time.Millisecond))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	if disabled.cleanupDone != nil {
+		t.Error("expected no cleanup goroutine with Config.DisableBackgroundCleanup")
+	}
+}
@@ -0,0 +1,37 @@
+package jwt
+
+import "time"
+
+// Clock supplies the current time. Inject a fake implementation in tests to
+// freeze or advance time instead of sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+// TimerClock is an optional extension of Clock that also drives the timers of
+// background work such as cleanup, so a fake clock can fire them. Clocks
+// without it get wall-clock timers.
+type TimerClock interface {
+	Clock
+	// After returns a channel that receives the time once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the default Clock backed by time.Now.
+type SystemClock struct{}
+
+// Now returns the current wall-clock time.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// After implements TimerClock with time.After.
+func (SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// WithClock overrides the time source used for issuing and verifying tokens,
+// and for scheduling background cleanup if c implements TimerClock.
+func WithClock(c Clock) Option {
+	return func(o *makerOptions) { o.clock = c }
+}
@@ -0,0 +1,238 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// RedactedValue replaces secrets in Config.Redacted and DiffConfigs.
+const RedactedValue = "[redacted]"
+
+// Redacted returns a copy of the config that is safe to print or log:
+// fields tagged secret:"true", the Secret and EncryptionKey, are replaced
+// with RedactedValue if set.
+func (c Config) Redacted() Config {
+	v := reflect.ValueOf(&c).Elem()
+	for _, field := range reflect.VisibleFields(v.Type()) {
+		if f := v.FieldByIndex(field.Index); isSecretField(field) && !f.IsZero() {
+			f.SetString(RedactedValue)
+		}
+	}
+	return c
+}
+
+// ConfigChange is a Config field that differs between two configs.
+type ConfigChange struct {
+	Field string
+	// Old and New are the formatted values, or RedactedValue for secrets
+	// that are set.
+	Old string
+	New string
+}
+
+func (c ConfigChange) String() string {
+	return c.Field + ": " + c.Old + " -> " + c.New
+}
+
+// DiffConfigs returns the fields that differ between a and b, in
+// declaration order, e.g. to log what a Reload changes. A changed secret
+// is reported without its values.
+func DiffConfigs(a, b Config) []ConfigChange {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	var changes []ConfigChange
+	for _, field := range reflect.VisibleFields(va.Type()) {
+		fa, fb := va.FieldByIndex(field.Index), vb.FieldByIndex(field.Index)
+		if reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			continue
+		}
+		changes = append(changes, ConfigChange{
+			Field: field.Name,
+			Old:   formatConfigValue(field, fa),
+			New:   formatConfigValue(field, fb),
+		})
+	}
+	return changes
+}
+
+func isSecretField(field reflect.StructField) bool {
+	return field.Tag.Get("secret") == "true" && field.Type.Kind() == reflect.String
+}
+
+func formatConfigValue(field reflect.StructField, v reflect.Value) string {
+	if isSecretField(field) && !v.IsZero() {
+		return RedactedValue
+	}
+	return fmt.Sprintf("%+v", v.Interface())
+}
+
+// ProductionConfig returns an opinionated Config for production, and for
+// staging, which should reject what production would:
+//
+//   - access tokens live 15 minutes, so revocations lapse quickly even when
+//     a verifier runs offline;
+//   - refresh sessions rotate within 7 days, expire after 3 idle days and
+//     end after 30 days (90 with WithRememberMe);
+//   - both token types are pinned to HS512 and tokens must carry their
+//     at+jwt / rt+jwt typ header;
+//   - revocations are keyed by jti, and issuance is limited to one token
+//     per second per user with bursts of 10.
+//
+// Adjust the returned Config before passing it to NewTokenMaker if a
+// default does not fit.
+func ProductionConfig(secret, issuer, audience string) Config {
+	return Config{
+		Secret:                      secret,
+		Issuer:                      issuer,
+		Audience:                    audience,
+		AccessExpiryDuration:        15 * time.Minute,
+		RefreshExpiryDuration:       7 * 24 * time.Hour,
+		RefreshMaxLifetimeExpiry:    30 * 24 * time.Hour,
+		RefreshIdleTimeout:          3 * 24 * time.Hour,
+		RememberMeExpiryDuration:    30 * 24 * time.Hour,
+		RememberMeMaxLifetimeExpiry: 90 * 24 * time.Hour,
+		RepoCheckOrder:              CheckSignatureFirst,
+		AccessAlgorithm:             "HS512",
+		RefreshAlgorithm:            "HS512",
+		StrictTypHeader:             true,
+		RevocationKey:               RevocationKeyID,
+		IssuanceRateLimit:           1,
+		IssuanceBurst:               10,
+	}
+}
+
+// DevelopmentConfig returns a Config for local development: hour-long
+// access tokens and 30-day refresh tokens without idle timeout or rate
+// limit, the default HS256 and lenient typ headers. It is not meant for
+// any shared environment.
+func DevelopmentConfig(secret, issuer, audience string) Config {
+	return Config{
+		Secret:                secret,
+		Issuer:                issuer,
+		Audience:              audience,
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: 30 * 24 * time.Hour,
+	}
+}
+
+// ValidateConfig reports every problem NewTokenMaker would reject cfg and
+// repo for, joined with errors.Join, so a configuration can be fixed in one
+// pass instead of one error per restart. It returns nil if NewTokenMaker
+// would accept them.
+func ValidateConfig(cfg Config, repo RevocationRepository) error {
+	return validateConfig(cfg, repo, true)
+}
+
+// validateConfig checks cfg and repo. With all set it returns every problem
+// as a multi-error, otherwise only the first.
+func validateConfig(cfg Config, repo RevocationRepository, all bool) error {
+	var problems []error
+	add := func(err error) {
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	switch cfg.SecretEncoding {
+	case "", SecretEncodingRaw, SecretEncodingBase64, SecretEncodingHex:
+		if cfg.Secret == "" {
+			add(fmt.Errorf("config.Secret is required"))
+		} else if _, err := decodeSecret(cfg.Secret, cfg.SecretEncoding); err != nil {
+			add(fmt.Errorf("config.Secret: %w", err))
+		}
+	default:
+		add(fmt.Errorf("config.SecretEncoding %q is invalid", cfg.SecretEncoding))
+	}
+	if err := validateIssuer(cfg.Issuer); err != nil {
+		add(fmt.Errorf("config.Issuer %w", err))
+	}
+	if cfg.Audience == "" {
+		add(fmt.Errorf("config.Audience is required"))
+	}
+
+	switch cfg.RepoCheckOrder {
+	case "", CheckSignatureFirst, CheckRepositoryFirst:
+	default:
+		add(fmt.Errorf("config.RepoCheckOrder %q is invalid", cfg.RepoCheckOrder))
+	}
+	switch cfg.RevocationKey {
+	case "", RevocationKeyToken, RevocationKeyID:
+	default:
+		add(fmt.Errorf("config.RevocationKey %q is invalid", cfg.RevocationKey))
+	}
+	switch cfg.TokenFormat {
+	case "", TokenFormatJWT:
+	case TokenFormatOpaque:
+		if _, ok := repo.(OpaqueTokenRepository); !ok {
+			add(fmt.Errorf("config.TokenFormat %q requires a repository implementing OpaqueTokenRepository", cfg.TokenFormat))
+		}
+	default:
+		add(fmt.Errorf("config.TokenFormat %q is invalid", cfg.TokenFormat))
+	}
+
+	if cfg.EncryptionKey != "" {
+		key, err := decodeEncryptionKey(cfg.EncryptionKey)
+		if err == nil {
+			_, err = newTokenCipher(key)
+		}
+		if err != nil {
+			add(fmt.Errorf("config.EncryptionKey: %w", err))
+		}
+	}
+
+	for _, f := range []struct {
+		name     string
+		negative bool
+	}{
+		{"AccessExpiryDuration", cfg.AccessExpiryDuration < 0},
+		{"RefreshExpiryDuration", cfg.RefreshExpiryDuration < 0},
+		{"RefreshMaxLifetimeExpiry", cfg.RefreshMaxLifetimeExpiry < 0},
+		{"RefreshIdleTimeout", cfg.RefreshIdleTimeout < 0},
+		{"IssuanceRateLimit", cfg.IssuanceRateLimit < 0},
+		{"RememberMeExpiryDuration", cfg.RememberMeExpiryDuration < 0},
+		{"RememberMeMaxLifetimeExpiry", cfg.RememberMeMaxLifetimeExpiry < 0},
+		{"MaxTokenLength", cfg.MaxTokenLength < 0},
+		{"RepositoryTimeout", cfg.RepositoryTimeout < 0},
+		{"IssuanceBurst", cfg.IssuanceBurst < 0},
+		{"ElevatedExpiryDuration", cfg.ElevatedExpiryDuration < 0},
+		{"MFAProofMaxAge", cfg.MFAProofMaxAge < 0},
+		{"MFAChallengeExpiryDuration", cfg.MFAChallengeExpiryDuration < 0},
+		{"ServiceExpiryDuration", cfg.ServiceExpiryDuration < 0},
+		{"PairingExpiryDuration", cfg.PairingExpiryDuration < 0},
+		{"SecurityFailureThreshold", cfg.SecurityFailureThreshold < 0},
+		{"SecurityFailureWindow", cfg.SecurityFailureWindow < 0},
+	} {
+		if f.negative {
+			add(fmt.Errorf("config.%s must not be negative", f.name))
+		}
+	}
+
+	for i, p := range cfg.AccessExpiryPolicies {
+		if p.Role == "" && p.ClientID == "" {
+			add(fmt.Errorf("config.AccessExpiryPolicies[%d] needs a Role or ClientID", i))
+		}
+		if p.Expiry <= 0 {
+			add(fmt.Errorf("config.AccessExpiryPolicies[%d].Expiry must be positive", i))
+		}
+	}
+
+	if cfg.MinExpiryOverride < 0 || cfg.MaxExpiryOverride < 0 {
+		add(fmt.Errorf("config.MinExpiryOverride and config.MaxExpiryOverride must not be negative"))
+	} else if cfg.MaxExpiryOverride > 0 && cfg.MinExpiryOverride > cfg.MaxExpiryOverride {
+		add(fmt.Errorf("config.MinExpiryOverride must not exceed config.MaxExpiryOverride"))
+	}
+
+	_, err := resolveSymmetricMethod("AccessAlgorithm", cfg.AccessAlgorithm)
+	add(err)
+	_, err = resolveSymmetricMethod("RefreshAlgorithm", cfg.RefreshAlgorithm)
+	add(err)
+
+	if len(problems) == 0 {
+		return nil
+	}
+	if !all {
+		return problems[0]
+	}
+	return errors.Join(problems...)
+}
@@ -0,0 +1,138 @@
+package jwt
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateConfig(t *testing.T) {
+	cfg := Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}
+	if err := ValidateConfig(cfg, newMockRevocationRepo()); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+
+	cfg.Issuer = ""
+	cfg.RevocationKey = "bogus"
+	cfg.RefreshIdleTimeout = -time.Second
+	cfg.AccessAlgorithm = "RS256"
+	cfg.EncryptionKey = "not base64!"
+
+	err := ValidateConfig(cfg, newMockRevocationRepo())
+	if err == nil {
+		t.Fatal("invalid config accepted")
+	}
+	for _, want := range []string{
+		"config.Issuer is required",
+		`config.RevocationKey "bogus" is invalid`,
+		"config.EncryptionKey: encryption key is not valid base64",
+		"config.RefreshIdleTimeout must not be negative",
+		`config.AccessAlgorithm "RS256" is not a supported HMAC algorithm`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("report %q lacks %q", err, want)
+		}
+	}
+	if got := len(err.(interface{ Unwrap() []error }).Unwrap()); got != 5 {
+		t.Errorf("got %d problems, want 5", got)
+	}
+
+	if _, err := NewTokenMaker(cfg, newMockRevocationRepo()); err == nil || err.Error() != "config.Issuer is required" {
+		t.Errorf("NewTokenMaker error = %v, want only the first problem", err)
+	}
+}
+
+func TestValidateConfig_Negative(t *testing.T) {
+	cfg := Config{
+		Secret:                     "test-secret-must-be-at-least-32-bytes",
+		Issuer:                     "test-issuer",
+		Audience:                   "test-audience",
+		AccessExpiryDuration:       time.Hour,
+		IssuanceBurst:              -1,
+		ElevatedExpiryDuration:     -time.Second,
+		MFAProofMaxAge:             -time.Second,
+		MFAChallengeExpiryDuration: -time.Second,
+		ServiceExpiryDuration:      -time.Second,
+		PairingExpiryDuration:      -time.Second,
+		SecurityFailureThreshold:   -1,
+		SecurityFailureWindow:      -time.Second,
+	}
+	err := ValidateConfig(cfg, newMockRevocationRepo())
+	if err == nil {
+		t.Fatal("negative settings accepted")
+	}
+	for _, field := range []string{
+		"IssuanceBurst",
+		"ElevatedExpiryDuration",
+		"MFAProofMaxAge",
+		"MFAChallengeExpiryDuration",
+		"ServiceExpiryDuration",
+		"PairingExpiryDuration",
+		"SecurityFailureThreshold",
+		"SecurityFailureWindow",
+	} {
+		if want := "config." + field + " must not be negative"; !strings.Contains(err.Error(), want) {
+			t.Errorf("report %q lacks %q", err, want)
+		}
+	}
+}
+
+func TestPresets(t *testing.T) {
+	const secret = "test-secret-must-be-at-least-32-bytes"
+	for name, cfg := range map[string]Config{
+		"production":  ProductionConfig(secret, "test-issuer", "test-audience"),
+		"development": DevelopmentConfig(secret, "test-issuer", "test-audience"),
+	} {
+		if err := ValidateConfig(cfg, newMockRevocationRepo()); err != nil {
+			t.Errorf("%s preset rejected: %v", name, err)
+		}
+	}
+
+	prod, dev := ProductionConfig(secret, "i", "a"), DevelopmentConfig(secret, "i", "a")
+	if prod.AccessExpiryDuration >= dev.AccessExpiryDuration {
+		t.Errorf("production access expiry %s not shorter than development %s", prod.AccessExpiryDuration, dev.AccessExpiryDuration)
+	}
+	if prod.AccessAlgorithm == DefaultAlgorithm || !prod.StrictTypHeader {
+		t.Error("production preset does not tighten algorithms and typ headers")
+	}
+}
+
+func TestRedactedAndDiffConfigs(t *testing.T) {
+	a := ProductionConfig("test-secret-must-be-at-least-32-bytes", "test-issuer", "test-audience")
+	a.EncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZg=="
+
+	r := a.Redacted()
+	if r.Secret != RedactedValue || r.EncryptionKey != RedactedValue {
+		t.Errorf("secrets not redacted: %q, %q", r.Secret, r.EncryptionKey)
+	}
+	if r.Issuer != a.Issuer || r.AccessExpiryDuration != a.AccessExpiryDuration {
+		t.Error("Redacted changed non-secret fields")
+	}
+	if a.Secret == RedactedValue {
+		t.Error("Redacted modified the original config")
+	}
+
+	b := a
+	b.Secret = "rotated-secret-must-be-at-least-32-bytes"
+	b.AccessExpiryDuration = 10 * time.Minute
+	changes := DiffConfigs(a, b)
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"Secret: [redacted] -> [redacted]",
+		"AccessExpiryDuration: 15m0s -> 10m0s",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("DiffConfigs = %q, want %q", got, want)
+	}
+	if len(DiffConfigs(a, a)) != 0 {
+		t.Error("expected no changes between equal configs")
+	}
+}
@@ -0,0 +1,103 @@
+package jwt
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WithDevice binds the session to a registered device, typically an install
+// ID generated by the client. The device is recorded in the did claim, kept
+// by every rotation, and stored with the session so that ListDevices and
+// RevokeDevice can find it.
+func WithDevice(deviceID uuid.UUID) RefreshOption {
+	return func(o *refreshOptions) { o.deviceID = deviceID }
+}
+
+// DeviceInfo summarizes the active sessions of one device.
+type DeviceInfo struct {
+	ID uuid.UUID `json:"id"`
+	// Name is the device name reported by its most recent session.
+	Name       string      `json:"name,omitempty"`
+	Sessions   []uuid.UUID `json:"sessions"`
+	FirstSeen  time.Time   `json:"first_seen"`
+	LastSeenAt time.Time   `json:"last_seen_at"`
+}
+
+// ListDevices returns the devices with active sessions for userID, most
+// recently used first. Sessions started without WithDevice are left out.
+func (tm *TokenMaker) ListDevices(ctx context.Context, userID uuid.UUID) ([]DeviceInfo, error) {
+	sessions, err := tm.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list devices: %w", err)
+	}
+
+	// Sessions arrive most recently used first, so the first session seen for
+	// a device carries its latest name.
+	var devices []DeviceInfo
+	index := make(map[uuid.UUID]int)
+	for _, s := range sessions {
+		if s.DeviceID == uuid.Nil {
+			continue
+		}
+		i, ok := index[s.DeviceID]
+		if !ok {
+			index[s.DeviceID] = len(devices)
+			devices = append(devices, DeviceInfo{
+				ID:         s.DeviceID,
+				Name:       s.DeviceName,
+				FirstSeen:  s.CreatedAt,
+				LastSeenAt: s.LastSeenAt,
+			})
+			i = len(devices) - 1
+		}
+		d := &devices[i]
+		d.Sessions = append(d.Sessions, s.ID)
+		if s.CreatedAt.Before(d.FirstSeen) {
+			d.FirstSeen = s.CreatedAt
+		}
+	}
+	slices.SortStableFunc(devices, func(a, b DeviceInfo) int {
+		return cmp.Compare(b.LastSeenAt.UnixNano(), a.LastSeenAt.UnixNano())
+	})
+	return devices, nil
+}
+
+// RevokeDevice revokes every session of userID bound to deviceID, and with
+// them all of their access and refresh tokens. It needs a SessionStore to
+// find the sessions and a SessionRevocationRepository to revoke them, and
+// returns how many sessions were revoked.
+func (tm *TokenMaker) RevokeDevice(ctx context.Context, userID, deviceID uuid.UUID) (int, error) {
+	if deviceID == uuid.Nil {
+		return 0, fmt.Errorf("device id is required")
+	}
+
+	sessions, err := tm.ListSessions(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("revoke device: %w", err)
+	}
+
+	var (
+		revoked int
+		errs    []error
+	)
+	for _, s := range sessions {
+		if s.DeviceID != deviceID {
+			continue
+		}
+		if err := tm.RevokeSession(ctx, s.ID); err != nil {
+			errs = append(errs, fmt.Errorf("revoke session %s: %w", s.ID, err))
+			continue
+		}
+		revoked++
+	}
+	if err := errors.Join(errs...); err != nil {
+		return revoked, fmt.Errorf("revoke device: %w", err)
+	}
+	return revoked, nil
+}
@@ -0,0 +1,94 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRevokeDevice(t *testing.T) {
+	repo := &sessionRepo{mockRevocationRepo: newMockRevocationRepo(), sessions: make(map[uuid.UUID]struct{})}
+	store := &mapSessionStore{sessions: make(map[uuid.UUID]SessionInfo)}
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: 24 * time.Hour,
+	}, repo, WithSessionStore(store), WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+
+	userID, phone, laptop := uuid.New(), uuid.New(), uuid.New()
+	phoneCtx := WithClientInfo(ctx, ClientInfo{DeviceName: "phone"})
+	phoneSession := uuid.New()
+	phoneAccess, err := maker.CreateAccessToken(ctx, userID, "alice", nil, phoneSession)
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	phoneRefresh, err := maker.CreateRefreshToken(phoneCtx, userID, "alice", nil, phoneSession, WithDevice(phone))
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	clock.Advance(time.Minute)
+	if _, err := maker.CreateRefreshToken(phoneCtx, userID, "alice", nil, uuid.New(), WithDevice(phone)); err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	clock.Advance(time.Minute)
+	laptopRefresh, err := maker.CreateRefreshToken(ctx, userID, "alice", nil, uuid.New(), WithDevice(laptop))
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if _, err := maker.CreateRefreshToken(ctx, userID, "alice", nil, uuid.New()); err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+
+	// The device survives rotation, which makes the phone the most recently
+	// used device.
+	clock.Advance(time.Minute)
+	phoneRefresh, err = maker.RotateRefreshToken(ctx, phoneRefresh.Token)
+	if err != nil {
+		t.Fatalf("rotate refresh token: %v", err)
+	}
+	claims, err := maker.VerifyRefreshToken(ctx, phoneRefresh.Token)
+	if err != nil {
+		t.Fatalf("verify refresh token: %v", err)
+	}
+	if claims.DeviceID != phone {
+		t.Errorf("expected rotated token to keep device %v, got %v", phone, claims.DeviceID)
+	}
+
+	devices, err := maker.ListDevices(ctx, userID)
+	if err != nil {
+		t.Fatalf("list devices: %v", err)
+	}
+	if len(devices) != 2 || devices[0].ID != phone || devices[1].ID != laptop {
+		t.Fatalf("expected phone then laptop, got %+v", devices)
+	}
+	if devices[0].Name != "phone" || len(devices[0].Sessions) != 2 {
+		t.Errorf("unexpected phone device %+v", devices[0])
+	}
+
+	n, err := maker.RevokeDevice(ctx, userID, phone)
+	if err != nil {
+		t.Fatalf("revoke device: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 sessions revoked, got %d", n)
+	}
+	if _, err := maker.VerifyRefreshToken(ctx, phoneRefresh.Token); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("expected ErrSessionRevoked for the device's refresh token, got %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, phoneAccess.Token); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("expected ErrSessionRevoked for the device's access token, got %v", err)
+	}
+	if _, err := maker.VerifyRefreshToken(ctx, laptopRefresh.Token); err != nil {
+		t.Errorf("expected another device to stay signed in, got %v", err)
+	}
+}
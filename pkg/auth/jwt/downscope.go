@@ -0,0 +1,67 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// DownscopeAccessToken derives a token from a valid access token for handing
+// to a less trusted subsystem. The new token has the same user, session and
+// roles, expires no later than the original and records its jti in
+// DerivedFrom. Its scopes and audience default to the original's; narrower
+// values must be subsets of them, except that the audience may also be
+// narrowed to Config.DownscopeAudiences. Scopes cannot be added to an
+// unscoped token, since its scope checks would then pass.
+func (tm *TokenMaker) DownscopeAccessToken(ctx context.Context, accessToken string, narrowerScopes, narrowerAudience []string) (*TokenResponse, error) {
+	claims, err := tm.VerifyAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify access token: %w", err)
+	}
+
+	scopes := claims.Scopes
+	if len(narrowerScopes) > 0 {
+		for _, scope := range narrowerScopes {
+			if !slices.Contains(claims.Scopes, scope) {
+				return nil, fmt.Errorf("%w: scope %q", ErrPrivilegeEscalation, scope)
+			}
+		}
+		scopes = slices.Clone(narrowerScopes)
+	}
+	audience := claims.Audience
+	if len(narrowerAudience) > 0 {
+		for _, aud := range narrowerAudience {
+			if !slices.Contains(claims.Audience, aud) && !slices.Contains(tm.policy().downscopeAuds, aud) {
+				return nil, fmt.Errorf("%w: audience %q", ErrPrivilegeEscalation, aud)
+			}
+		}
+		audience = slices.Clone(narrowerAudience)
+	}
+	if err := tm.allowIssue(ctx, claims.Subject); err != nil {
+		return nil, err
+	}
+
+	now := tm.clock.Now()
+	return tm.signAccessToken(ctx, TokenClaims{
+		ID:           uuid.New(),
+		Subject:      claims.Subject,
+		SessionID:    claims.SessionID,
+		Username:     claims.Username,
+		Roles:        claims.Roles,
+		Issuer:       tm.issuer,
+		Audience:     audience,
+		IssuedAt:     jwt.NewNumericDate(now),
+		ExpiresAt:    claims.ExpiresAt,
+		NotBefore:    jwt.NewNumericDate(now),
+		TokenType:    AccessToken,
+		AuthTime:     claims.AuthTime,
+		ACR:          claims.ACR,
+		AMR:          claims.AMR,
+		Scopes:       scopes,
+		Confirmation: claims.Confirmation,
+		DerivedFrom:  claims.ID,
+	})
+}
@@ -0,0 +1,72 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestDownscopeAccessToken(t *testing.T) {
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+		DownscopeAudiences:   []string{"reports-service"},
+	}, newMockRevocationRepo(), WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+
+	access, err := maker.CreateAccessToken(ctx, uuid.New(), "alice", []string{"user"}, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if _, err := maker.DownscopeAccessToken(ctx, access.Token, []string{"reports:read"}, nil); !errors.Is(err, ErrPrivilegeEscalation) {
+		t.Errorf("scopes on unscoped token: err = %v, want ErrPrivilegeEscalation", err)
+	}
+
+	scoped, err := maker.ElevateToken(ctx, access.Token, MFAProof{Method: "otp", VerifiedAt: clock.Now()}, "reports:read", "payments")
+	if err != nil {
+		t.Fatalf("elevate: %v", err)
+	}
+	original, err := maker.VerifyAccessToken(ctx, scoped.Token)
+	if err != nil {
+		t.Fatalf("verify original: %v", err)
+	}
+
+	narrow, err := maker.DownscopeAccessToken(ctx, scoped.Token, []string{"reports:read"}, nil)
+	if err != nil {
+		t.Fatalf("downscope: %v", err)
+	}
+	if narrow.ExpiresAt.After(original.ExpiresAt.Time) {
+		t.Errorf("downscoped token expires at %v, after the original %v", narrow.ExpiresAt, original.ExpiresAt)
+	}
+	claims, err := maker.VerifyAccessToken(ctx, narrow.Token)
+	if err != nil {
+		t.Fatalf("verify downscoped: %v", err)
+	}
+	if !slices.Equal(claims.Scopes, []string{"reports:read"}) || claims.DerivedFrom != original.ID || claims.SessionID != original.SessionID {
+		t.Errorf("claims = %+v", claims)
+	}
+	if _, err := maker.DownscopeAccessToken(ctx, narrow.Token, []string{"payments"}, nil); !errors.Is(err, ErrPrivilegeEscalation) {
+		t.Errorf("widening a downscoped token: err = %v, want ErrPrivilegeEscalation", err)
+	}
+
+	sub, err := maker.DownscopeAccessToken(ctx, scoped.Token, []string{"reports:read"}, []string{"reports-service"})
+	if err != nil {
+		t.Fatalf("downscope to subsystem: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, sub.Token); !errors.Is(err, ErrInvalidAudience) {
+		t.Errorf("subsystem token at the main audience: err = %v, want ErrInvalidAudience", err)
+	}
+	if _, err := maker.DownscopeAccessToken(ctx, scoped.Token, nil, []string{"admin-service"}); !errors.Is(err, ErrPrivilegeEscalation) {
+		t.Errorf("unknown audience: err = %v, want ErrPrivilegeEscalation", err)
+	}
+}
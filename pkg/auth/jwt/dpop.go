@@ -0,0 +1,196 @@
+package jwt
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DPoPTyp is the typ header of DPoP proofs (RFC 9449).
+const DPoPTyp = "dpop+jwt"
+
+// DefaultDPoPProofMaxAge is how old a DPoP proof's iat may be.
+const DefaultDPoPProofMaxAge = time.Minute
+
+// dpopAlgorithms are the asymmetric algorithms accepted for DPoP proofs.
+var dpopAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// Confirmation is the cnf claim of a sender-constrained token.
+type Confirmation struct {
+	// JKT is the RFC 7638 SHA-256 thumbprint of the DPoP key the token is
+	// bound to.
+	JKT string `json:"jkt,omitempty"`
+}
+
+type dpopKeyKey struct{}
+
+// WithDPoPKey binds the tokens issued with ctx to the DPoP key with
+// thumbprint jkt, usually DPoPProof.JKT of the proof sent to the token
+// endpoint. CreateAccessToken and CreateRefreshToken read it; rotation and
+// elevation keep the binding of the token they start from.
+func WithDPoPKey(ctx context.Context, jkt string) context.Context {
+	return context.WithValue(ctx, dpopKeyKey{}, jkt)
+}
+
+// confirmationFrom returns the cnf claim for tokens issued with ctx.
+func confirmationFrom(ctx context.Context) *Confirmation {
+	if jkt, _ := ctx.Value(dpopKeyKey{}).(string); jkt != "" {
+		return &Confirmation{JKT: jkt}
+	}
+	return nil
+}
+
+// DPoPRequest is the request a DPoP proof was sent with.
+type DPoPRequest struct {
+	// Proof is the value of the DPoP header.
+	Proof string
+	// Method and URL are the HTTP method and the absolute request URL; query
+	// and fragment are ignored.
+	Method string
+	URL    string
+	// Nonce, if set, is the server-provided nonce the proof must carry.
+	Nonce string
+}
+
+type dpopRequestKey struct{}
+
+// WithDPoPProof attaches the DPoP proof of the current request to ctx.
+// Verifying a DPoP-bound token requires one.
+func WithDPoPProof(ctx context.Context, req DPoPRequest) context.Context {
+	return context.WithValue(ctx, dpopRequestKey{}, req)
+}
+
+// DPoPProof is a verified DPoP proof.
+type DPoPProof struct {
+	ID       string
+	IssuedAt time.Time
+	// JKT is the thumbprint of the key that signed the proof.
+	JKT string
+}
+
+type dpopClaims struct {
+	jwt.RegisteredClaims
+	HTM   string `json:"htm"`
+	HTU   string `json:"htu"`
+	ATH   string `json:"ath,omitempty"`
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// DPoPReplayCache remembers the jti of DPoP proofs so each is accepted only
+// once. Without one, a captured proof can be replayed for the same method
+// and URL until it is DefaultDPoPProofMaxAge old.
+type DPoPReplayCache interface {
+	// MarkDPoPProofUsed records jti for ttl and reports whether it was new.
+	MarkDPoPProofUsed(ctx context.Context, jti string, ttl time.Duration) (bool, error)
+}
+
+// WithDPoPReplayCache rejects DPoP proofs whose jti was seen before.
+func WithDPoPReplayCache(c DPoPReplayCache) Option {
+	return func(o *makerOptions) { o.dpopReplay = c }
+}
+
+// VerifyDPoPProof verifies the proof in req at now: its signature by the
+// embedded public key, its typ, method, URL, age and nonce, and, when
+// accessToken is not empty, that it was made for that token (ath). It
+// returns ErrInvalidDPoPProof or an error wrapping it.
+func VerifyDPoPProof(req DPoPRequest, accessToken string, now time.Time) (*DPoPProof, error) {
+	if req.Proof == "" {
+		return nil, ErrDPoPProofRequired
+	}
+	if len(req.Proof) > DefaultMaxTokenLength {
+		return nil, ErrInvalidDPoPProof
+	}
+
+	var jkt string
+	claims := &dpopClaims{}
+	_, err := jwt.ParseWithClaims(req.Proof, claims, func(token *jwt.Token) (any, error) {
+		if typ, _ := token.Header["typ"].(string); typ != DPoPTyp {
+			return nil, fmt.Errorf("%w: typ %q", ErrInvalidDPoPProof, typ)
+		}
+		jwk, ok := token.Header["jwk"].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: missing jwk header", ErrInvalidDPoPProof)
+		}
+		key, thumbprint, err := parsePublicJWK(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidDPoPProof, err)
+		}
+		jkt = thumbprint
+		return key, nil
+	}, jwt.WithValidMethods(dpopAlgorithms), jwt.WithIssuedAt(), jwt.WithLeeway(DefaultLeeway), jwt.WithTimeFunc(func() time.Time { return now }))
+	if err != nil {
+		if errors.Is(err, ErrInvalidDPoPProof) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDPoPProof, err)
+	}
+
+	switch {
+	case claims.ID == "":
+		return nil, fmt.Errorf("%w: missing jti", ErrInvalidDPoPProof)
+	case claims.IssuedAt == nil || now.Sub(claims.IssuedAt.Time) > DefaultDPoPProofMaxAge+DefaultLeeway:
+		return nil, fmt.Errorf("%w: stale or missing iat", ErrInvalidDPoPProof)
+	case claims.HTM != req.Method:
+		return nil, fmt.Errorf("%w: htm mismatch", ErrInvalidDPoPProof)
+	case stripQuery(claims.HTU) != stripQuery(req.URL):
+		return nil, fmt.Errorf("%w: htu mismatch", ErrInvalidDPoPProof)
+	case req.Nonce != "" && subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(req.Nonce)) != 1:
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidDPoPProof)
+	}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		if subtle.ConstantTimeCompare([]byte(claims.ATH), []byte(base64.RawURLEncoding.EncodeToString(sum[:]))) != 1 {
+			return nil, fmt.Errorf("%w: ath mismatch", ErrInvalidDPoPProof)
+		}
+	}
+
+	return &DPoPProof{ID: claims.ID, IssuedAt: claims.IssuedAt.Time, JKT: jkt}, nil
+}
+
+// checkDPoP enforces the cnf binding of verified claims against the proof
+// in ctx. Access tokens must be presented with a proof made for them.
+func checkDPoP(ctx context.Context, replay DPoPReplayCache, tokenString string, claims *TokenClaims, now time.Time) error {
+	if claims.Confirmation == nil || claims.Confirmation.JKT == "" {
+		return nil
+	}
+	req, ok := ctx.Value(dpopRequestKey{}).(DPoPRequest)
+	if !ok {
+		return ErrDPoPProofRequired
+	}
+
+	var ath string
+	if claims.TokenType == AccessToken {
+		ath = tokenString
+	}
+	proof, err := VerifyDPoPProof(req, ath, now)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(proof.JKT), []byte(claims.Confirmation.JKT)) != 1 {
+		return fmt.Errorf("%w: key mismatch", ErrInvalidDPoPProof)
+	}
+	if replay != nil {
+		fresh, err := replay.MarkDPoPProofUsed(ctx, proof.ID, DefaultDPoPProofMaxAge+2*DefaultLeeway)
+		if err != nil {
+			return fmt.Errorf("check dpop replay: %w", err)
+		}
+		if !fresh {
+			return fmt.Errorf("%w: replayed", ErrInvalidDPoPProof)
+		}
+	}
+	return nil
+}
+
+func stripQuery(u string) string {
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		return u[:i]
+	}
+	return u
+}
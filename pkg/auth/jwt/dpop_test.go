@@ -0,0 +1,171 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// dpopClient holds a DPoP key and signs proofs with it.
+type dpopClient struct {
+	key *ecdsa.PrivateKey
+	jwk map[string]any
+	jkt string
+}
+
+func newDPoPClient(t *testing.T) *dpopClient {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwk := map[string]any{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   b64(key.PublicKey.X.FillBytes(make([]byte, 32))),
+		"y":   b64(key.PublicKey.Y.FillBytes(make([]byte, 32))),
+	}
+	jkt, err := JWKThumbprint(jwk)
+	if err != nil {
+		t.Fatalf("thumbprint: %v", err)
+	}
+	return &dpopClient{key: key, jwk: jwk, jkt: jkt}
+}
+
+func (c *dpopClient) proof(t *testing.T, method, url, accessToken string, iat time.Time) string {
+	t.Helper()
+	claims := jwt.MapClaims{"jti": uuid.NewString(), "htm": method, "htu": url, "iat": iat.Unix()}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = DPoPTyp
+	token.Header["jwk"] = c.jwk
+	proof, err := token.SignedString(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proof
+}
+
+func TestVerifyDPoPProof(t *testing.T) {
+	client := newDPoPClient(t)
+	now := time.Unix(1_700_000_000, 0)
+	const url = "https://api.example.com/orders"
+
+	proof, err := VerifyDPoPProof(DPoPRequest{Proof: client.proof(t, "POST", url, "at", now), Method: "POST", URL: url + "?page=2"}, "at", now)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if proof.JKT != client.jkt {
+		t.Errorf("jkt = %q, want %q", proof.JKT, client.jkt)
+	}
+
+	tests := []struct {
+		name  string
+		req   DPoPRequest
+		token string
+	}{
+		{"method", DPoPRequest{Proof: client.proof(t, "GET", url, "at", now), Method: "POST", URL: url}, "at"},
+		{"url", DPoPRequest{Proof: client.proof(t, "POST", url+"/1", "at", now), Method: "POST", URL: url}, "at"},
+		{"stale", DPoPRequest{Proof: client.proof(t, "POST", url, "at", now.Add(-5*time.Minute)), Method: "POST", URL: url}, "at"},
+		{"future", DPoPRequest{Proof: client.proof(t, "POST", url, "at", now.Add(5*time.Minute)), Method: "POST", URL: url}, "at"},
+		{"ath", DPoPRequest{Proof: client.proof(t, "POST", url, "other", now), Method: "POST", URL: url}, "at"},
+		{"nonce", DPoPRequest{Proof: client.proof(t, "POST", url, "at", now), Method: "POST", URL: url, Nonce: "n"}, "at"},
+		{"garbage", DPoPRequest{Proof: "not.a.jwt", Method: "POST", URL: url}, "at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyDPoPProof(tt.req, tt.token, now); !errors.Is(err, ErrInvalidDPoPProof) {
+				t.Errorf("err = %v, want ErrInvalidDPoPProof", err)
+			}
+		})
+	}
+
+	withPrivate := map[string]any{"kty": "EC", "crv": "P-256", "x": client.jwk["x"], "y": client.jwk["y"], "d": "AAAA"}
+	if _, err := JWKThumbprint(withPrivate); err == nil {
+		t.Error("expected a jwk with private members to be rejected")
+	}
+}
+
+func TestDPoPBoundTokens(t *testing.T) {
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Minute,
+		RefreshExpiryDuration: time.Hour,
+	}, newMockRevocationRepo(), WithClock(clock), WithDPoPReplayCache(newReplayCache()))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	client, thief := newDPoPClient(t), newDPoPClient(t)
+	const url = "https://api.example.com/me"
+
+	ctx := WithDPoPKey(context.Background(), client.jkt)
+	access, err := maker.CreateAccessToken(ctx, uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	bg := context.Background()
+	if _, err := maker.VerifyAccessToken(bg, access.Token); !errors.Is(err, ErrDPoPProofRequired) {
+		t.Errorf("verify without proof: err = %v, want ErrDPoPProofRequired", err)
+	}
+
+	stolen := WithDPoPProof(bg, DPoPRequest{Proof: thief.proof(t, "GET", url, access.Token, clock.Now()), Method: "GET", URL: url})
+	if _, err := maker.VerifyAccessToken(stolen, access.Token); !errors.Is(err, ErrInvalidDPoPProof) {
+		t.Errorf("verify with another key: err = %v, want ErrInvalidDPoPProof", err)
+	}
+
+	req := DPoPRequest{Proof: client.proof(t, "GET", url, access.Token, clock.Now()), Method: "GET", URL: url}
+	claims, err := maker.VerifyAccessToken(WithDPoPProof(bg, req), access.Token)
+	if err != nil {
+		t.Fatalf("verify with proof: %v", err)
+	}
+	if claims.Confirmation == nil || claims.Confirmation.JKT != client.jkt {
+		t.Errorf("cnf = %+v, want jkt %s", claims.Confirmation, client.jkt)
+	}
+	if _, err := maker.VerifyAccessToken(WithDPoPProof(bg, req), access.Token); !errors.Is(err, ErrInvalidDPoPProof) {
+		t.Errorf("verify with replayed proof: err = %v, want ErrInvalidDPoPProof", err)
+	}
+
+	// Refresh tokens stay bound across rotation; their proofs carry no ath.
+	refresh, err := maker.CreateRefreshToken(ctx, uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	const tokenURL = "https://api.example.com/auth/refresh"
+	rotateCtx := WithDPoPProof(bg, DPoPRequest{Proof: client.proof(t, "POST", tokenURL, "", clock.Now()), Method: "POST", URL: tokenURL})
+	rotated, err := maker.RotateRefreshToken(rotateCtx, refresh.Token)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if _, err := maker.VerifyRefreshToken(bg, rotated.Token); !errors.Is(err, ErrDPoPProofRequired) {
+		t.Errorf("verify rotated token without proof: err = %v, want ErrDPoPProofRequired", err)
+	}
+}
+
+type replayCache map[string]bool
+
+func newReplayCache() replayCache { return make(replayCache) }
+
+func (c replayCache) MarkDPoPProofUsed(_ context.Context, jti string, _ time.Duration) (bool, error) {
+	if c[jti] {
+		return false, nil
+	}
+	c[jti] = true
+	return true, nil
+}
@@ -0,0 +1,283 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned for all token verification failures to prevent
+// information leakage about which specific check failed.
+//
+// Every verification error below wraps ErrInvalidToken, so callers that only
+// care about "valid or not" keep using errors.Is(err, ErrInvalidToken), while
+// logging and metrics can branch on the specific cause. Never echo the
+// specific error to clients; send its ErrorCode instead.
+var ErrInvalidToken = fmt.Errorf("invalid token")
+
+// Sentinel verification errors. All of them satisfy errors.Is(err, ErrInvalidToken).
+var (
+	// ErrTokenTooLarge is returned when the token exceeds the configured
+	// maximum length and is rejected before parsing.
+	ErrTokenTooLarge = fmt.Errorf("%w: token too large", ErrInvalidToken)
+
+	// ErrMalformedToken is returned when the token cannot be decoded.
+	ErrMalformedToken = fmt.Errorf("%w: malformed token", ErrInvalidToken)
+
+	// ErrInvalidSignature is returned when the signature does not match.
+	ErrInvalidSignature = fmt.Errorf("%w: invalid signature", ErrInvalidToken)
+
+	// ErrUnexpectedAlgorithm is returned when the token is signed with an
+	// algorithm the verifier does not accept.
+	ErrUnexpectedAlgorithm = fmt.Errorf("%w: unexpected signing algorithm", ErrInvalidToken)
+
+	// ErrTokenExpired is returned when exp is in the past (beyond leeway).
+	ErrTokenExpired = fmt.Errorf("%w: token expired", ErrInvalidToken)
+
+	// ErrTokenNotYetValid is returned when nbf is in the future (beyond leeway).
+	ErrTokenNotYetValid = fmt.Errorf("%w: token not yet valid", ErrInvalidToken)
+
+	// ErrMissingClaims is returned when required claims (exp, nbf, iat) are absent.
+	ErrMissingClaims = fmt.Errorf("%w: missing required claims", ErrInvalidToken)
+
+	// ErrInvalidIssuer is returned when iss does not match the configured issuer.
+	ErrInvalidIssuer = fmt.Errorf("%w: invalid issuer", ErrInvalidToken)
+
+	// ErrInvalidAudience is returned when aud does not contain the configured audience.
+	ErrInvalidAudience = fmt.Errorf("%w: invalid audience", ErrInvalidToken)
+
+	// ErrWrongTokenType is returned when a refresh token is presented where an
+	// access token is expected, or vice versa.
+	ErrWrongTokenType = fmt.Errorf("%w: wrong token type", ErrInvalidToken)
+
+	// ErrTokenRevoked is returned when the token was explicitly revoked.
+	ErrTokenRevoked = fmt.Errorf("%w: token revoked", ErrInvalidToken)
+
+	// ErrTokenRotated is returned when a refresh token that was already
+	// exchanged by RotateRefreshToken is presented again.
+	ErrTokenRotated = fmt.Errorf("%w: token rotated", ErrInvalidToken)
+
+	// ErrSessionRevoked is returned when the token's session was revoked with
+	// RevokeSession.
+	ErrSessionRevoked = fmt.Errorf("%w: session revoked", ErrInvalidToken)
+
+	// ErrFamilyRevoked is returned when the refresh token's rotation family
+	// was revoked, either with RevokeFamily or because one of its tokens was
+	// replayed after rotation.
+	ErrFamilyRevoked = fmt.Errorf("%w: token family revoked", ErrInvalidToken)
+
+	// ErrSessionExpired is returned when a refresh token's session exceeded
+	// Config.RefreshMaxLifetimeExpiry or sat idle past Config.RefreshIdleTimeout.
+	ErrSessionExpired = fmt.Errorf("%w: session expired", ErrInvalidToken)
+
+	// ErrTokenInvalidated is returned when the token was issued before a
+	// global or per-user "not issued before" cutoff, including one recorded by
+	// RevokeAllUserTokens.
+	ErrTokenInvalidated = fmt.Errorf("%w: token issued before invalidation cutoff", ErrInvalidToken)
+
+	// ErrUnknownToken is returned in opaque mode when the repository holds
+	// no claims for the token, and for personal access tokens missing from
+	// their store: it expired, was revoked or never existed.
+	ErrUnknownToken = fmt.Errorf("%w: unknown token", ErrInvalidToken)
+
+	// ErrUnknownTenant is returned by a MakerRegistry when neither the
+	// token nor the context names a registered tenant.
+	ErrUnknownTenant = fmt.Errorf("%w: unknown tenant", ErrInvalidToken)
+
+	// ErrDecryptionFailed is returned when an encrypted token does not
+	// decrypt with the configured key; see Config.EncryptionKey.
+	ErrDecryptionFailed = fmt.Errorf("%w: token decryption failed", ErrInvalidToken)
+
+	// ErrDPoPProofRequired is returned when a DPoP-bound token is presented
+	// without a DPoP proof; see WithDPoPProof.
+	ErrDPoPProofRequired = fmt.Errorf("%w: dpop proof required", ErrInvalidToken)
+
+	// ErrInvalidDPoPProof is returned when the DPoP proof is invalid, was
+	// made for another request or token, or is signed by another key than
+	// the one the token is bound to.
+	ErrInvalidDPoPProof = fmt.Errorf("%w: invalid dpop proof", ErrInvalidToken)
+
+	// ErrWrongPurpose is returned when an action token is presented for
+	// another purpose than it was issued for.
+	ErrWrongPurpose = fmt.Errorf("%w: wrong token purpose", ErrInvalidToken)
+
+	// ErrTokenConsumed is returned when a single-use action token is
+	// presented again after its first successful verification.
+	ErrTokenConsumed = fmt.Errorf("%w: token already used", ErrInvalidToken)
+
+	// ErrCredentialChanged is returned when a password reset token is
+	// presented after the user's password changed.
+	ErrCredentialChanged = fmt.Errorf("%w: credential changed", ErrInvalidToken)
+
+	// ErrApprovalMismatch is returned when an approval token is presented
+	// for another operation, resource, amount or requester than approved.
+	ErrApprovalMismatch = fmt.Errorf("%w: approval does not match the action", ErrInvalidToken)
+)
+
+// ErrRevocationDisabled is returned by revocation APIs when the maker has no
+// RevocationRepository. It is a configuration error, not a verification failure.
+var ErrRevocationDisabled = errors.New("revocation not enabled")
+
+// ErrRateLimited is returned by the token issuing APIs when the user has
+// exhausted its issuance budget; see Config.IssuanceRateLimit. It is not a
+// verification failure.
+var ErrRateLimited = errors.New("token issuance rate limited")
+
+// ErrLockedOut is returned by verification while the client IP or the
+// token's subject is locked out after repeated failures; see WithLockout.
+// It is not a verification failure: the token itself may be valid.
+var ErrLockedOut = errors.New("too many failed verifications")
+
+// ErrExpiryOutOfBounds is returned by CreateAccessToken when the expiry set
+// with WithExpiry lies outside Config.MinExpiryOverride and
+// Config.MaxExpiryOverride.
+var ErrExpiryOutOfBounds = errors.New("token expiry override out of bounds")
+
+// ErrStaleMFAProof is returned by ElevateToken when the MFA proof is missing,
+// older than Config.MFAProofMaxAge or dated in the future.
+var ErrStaleMFAProof = errors.New("mfa proof missing or too old")
+
+// ErrStepUpRequired is returned when a valid access token lacks the elevation
+// an action requires. Callers should ask the user for a second factor and
+// call ElevateToken rather than treat the token as invalid.
+var ErrStepUpRequired = errors.New("step-up authentication required")
+
+// ErrPrivilegeEscalation is returned by DownscopeAccessToken when the
+// requested scopes or audience are not covered by the original token.
+var ErrPrivilegeEscalation = errors.New("downscoped token would exceed the original")
+
+// Stable machine-readable error codes returned by ErrorCode. Clients may
+// branch on them; unlike the error messages they never change.
+const (
+	CodeInvalidToken        = "invalid_token"
+	CodeTokenTooLarge       = "token_too_large"
+	CodeMalformedToken      = "malformed_token"
+	CodeInvalidSignature    = "invalid_signature"
+	CodeUnexpectedAlgorithm = "unexpected_algorithm"
+	CodeTokenExpired        = "token_expired"
+	CodeTokenNotYetValid    = "token_not_yet_valid"
+	CodeMissingClaims       = "missing_claims"
+	CodeInvalidIssuer       = "invalid_issuer"
+	CodeInvalidAudience     = "invalid_audience"
+	CodeWrongTokenType      = "wrong_token_type"
+	CodeTokenRevoked        = "token_revoked"
+	CodeTokenRotated        = "token_rotated"
+	CodeSessionRevoked      = "session_revoked"
+	CodeFamilyRevoked       = "token_family_revoked"
+	CodeSessionExpired      = "session_expired"
+	CodeTokenInvalidated    = "token_invalidated"
+	CodeUnknownToken        = "unknown_token"
+	CodeUnknownTenant       = "unknown_tenant"
+	CodeDecryptionFailed    = "decryption_failed"
+	CodeDPoPProofRequired   = "dpop_proof_required"
+	CodeInvalidDPoPProof    = "invalid_dpop_proof"
+	CodeWrongPurpose        = "wrong_purpose"
+	CodeTokenConsumed       = "token_consumed"
+	CodeCredentialChanged   = "credential_changed"
+	CodeApprovalMismatch    = "approval_mismatch"
+
+	CodeRevocationDisabled  = "revocation_disabled"
+	CodeRateLimited         = "rate_limited"
+	CodeLockedOut           = "locked_out"
+	CodeExpiryOutOfBounds   = "expiry_out_of_bounds"
+	CodeStaleMFAProof       = "stale_mfa_proof"
+	CodeStepUpRequired      = "step_up_required"
+	CodePrivilegeEscalation = "privilege_escalation"
+	CodePATNotFound         = "personal_access_token_not_found"
+	CodePairingNotFound     = "pairing_not_found"
+	CodePairingPending      = "pairing_pending"
+	CodePairingClaimed      = "pairing_claimed"
+	CodePairingsDisabled    = "pairings_disabled"
+	CodeInvalidWebhook      = "invalid_webhook_signature"
+	CodeWebhookTimestamp    = "webhook_timestamp_out_of_tolerance"
+	CodeWebhookReplayed     = "webhook_replayed"
+)
+
+// errorCodes lists the sentinel errors with their codes. Errors wrapping
+// another sentinel come before it so the most specific code wins.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrTokenTooLarge, CodeTokenTooLarge},
+	{ErrMalformedToken, CodeMalformedToken},
+	{ErrInvalidSignature, CodeInvalidSignature},
+	{ErrUnexpectedAlgorithm, CodeUnexpectedAlgorithm},
+	{ErrTokenExpired, CodeTokenExpired},
+	{ErrTokenNotYetValid, CodeTokenNotYetValid},
+	{ErrMissingClaims, CodeMissingClaims},
+	{ErrInvalidIssuer, CodeInvalidIssuer},
+	{ErrInvalidAudience, CodeInvalidAudience},
+	{ErrWrongTokenType, CodeWrongTokenType},
+	{ErrTokenRevoked, CodeTokenRevoked},
+	{ErrTokenRotated, CodeTokenRotated},
+	{ErrSessionRevoked, CodeSessionRevoked},
+	{ErrFamilyRevoked, CodeFamilyRevoked},
+	{ErrSessionExpired, CodeSessionExpired},
+	{ErrTokenInvalidated, CodeTokenInvalidated},
+	{ErrUnknownToken, CodeUnknownToken},
+	{ErrUnknownTenant, CodeUnknownTenant},
+	{ErrDecryptionFailed, CodeDecryptionFailed},
+	{ErrDPoPProofRequired, CodeDPoPProofRequired},
+	{ErrInvalidDPoPProof, CodeInvalidDPoPProof},
+	{ErrWrongPurpose, CodeWrongPurpose},
+	{ErrTokenConsumed, CodeTokenConsumed},
+	{ErrCredentialChanged, CodeCredentialChanged},
+	{ErrApprovalMismatch, CodeApprovalMismatch},
+	{ErrInvalidToken, CodeInvalidToken},
+
+	{ErrRevocationDisabled, CodeRevocationDisabled},
+	{ErrRateLimited, CodeRateLimited},
+	{ErrLockedOut, CodeLockedOut},
+	{ErrExpiryOutOfBounds, CodeExpiryOutOfBounds},
+	{ErrStaleMFAProof, CodeStaleMFAProof},
+	{ErrStepUpRequired, CodeStepUpRequired},
+	{ErrPrivilegeEscalation, CodePrivilegeEscalation},
+	{ErrPersonalAccessTokenNotFound, CodePATNotFound},
+	{ErrPairingNotFound, CodePairingNotFound},
+	{ErrPairingPending, CodePairingPending},
+	{ErrPairingClaimed, CodePairingClaimed},
+	{ErrPairingsDisabled, CodePairingsDisabled},
+	{ErrWebhookTimestamp, CodeWebhookTimestamp},
+	{ErrWebhookReplayed, CodeWebhookReplayed},
+	{ErrInvalidWebhook, CodeInvalidWebhook},
+}
+
+// ErrorCode returns the stable code of the package error err wraps, or ""
+// if err is nil or not one of the package's errors. Unlike the specific
+// error itself, the code is safe to send to clients.
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return ""
+}
+
+// classifyParseError maps errors from the underlying jwt library onto the
+// package's sentinel errors.
+func classifyParseError(err error) error {
+	switch {
+	case errors.Is(err, ErrUnexpectedAlgorithm):
+		return ErrUnexpectedAlgorithm
+	case errors.Is(err, ErrWrongTokenType):
+		return ErrWrongTokenType
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return ErrInvalidSignature
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet):
+		return ErrTokenNotYetValid
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return ErrMalformedToken
+	case errors.Is(err, ErrMissingClaims):
+		return ErrMissingClaims
+	default:
+		return ErrInvalidToken
+	}
+}
@@ -0,0 +1,160 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestVerify_TypedErrors(t *testing.T) {
+	repo := newMockRevocationRepo()
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: time.Hour,
+	}, repo)
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	other, err := NewTokenMaker(Config{
+		Secret:   "another-secret-must-be-at-least-32-bytes",
+		Issuer:   "test-issuer",
+		Audience: "test-audience",
+	}, nil)
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := context.Background()
+	access, err := maker.CreateAccessToken(ctx, uuid.New(), "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	refresh, err := maker.CreateRefreshToken(ctx, uuid.New(), "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	foreign, err := other.CreateAccessToken(ctx, uuid.New(), "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	_, err = maker.VerifyAccessToken(ctx, strings.Repeat("a", DefaultMaxTokenLength+1))
+	assertErr(t, err, ErrTokenTooLarge)
+
+	_, err = maker.VerifyAccessToken(ctx, "not-a-jwt")
+	assertErr(t, err, ErrMalformedToken)
+
+	_, err = maker.VerifyAccessToken(ctx, foreign.Token)
+	assertErr(t, err, ErrInvalidSignature)
+
+	_, err = maker.VerifyAccessToken(ctx, refresh.Token)
+	assertErr(t, err, ErrWrongTokenType)
+
+	if _, err := maker.RotateRefreshToken(ctx, refresh.Token); err != nil {
+		t.Fatalf("rotate refresh token: %v", err)
+	}
+	_, err = maker.VerifyRefreshToken(ctx, refresh.Token)
+	assertErr(t, err, ErrTokenRotated)
+
+	if err := maker.RevokeAccessToken(ctx, access.Token); err != nil {
+		t.Fatalf("revoke access token: %v", err)
+	}
+	_, err = maker.VerifyAccessToken(ctx, access.Token)
+	assertErr(t, err, ErrTokenRevoked)
+}
+
+func assertErr(t *testing.T, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Errorf("expected %v, got %v", target, err)
+	}
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected %v to wrap ErrInvalidToken", err)
+	}
+}
+
+func TestVerify_HeaderPinning(t *testing.T) {
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: time.Hour,
+		RefreshAlgorithm:      "HS512",
+		StrictTypHeader:       true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := context.Background()
+	refresh, err := maker.CreateRefreshToken(ctx, uuid.New(), "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if _, err := maker.VerifyRefreshToken(ctx, refresh.Token); err != nil {
+		t.Fatalf("verify refresh token: %v", err)
+	}
+
+	// A refresh token presented as an access token fails on the pinned alg.
+	_, err = maker.VerifyAccessToken(ctx, refresh.Token)
+	assertErr(t, err, ErrUnexpectedAlgorithm)
+
+	// A correctly signed token with the legacy typ header is rejected in strict mode.
+	legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, &TokenClaims{
+		ID:        uuid.New(),
+		Subject:   uuid.New(),
+		Issuer:    "test-issuer",
+		Audience:  []string{"test-audience"},
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		NotBefore: jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		TokenType: AccessToken,
+	})
+	legacyString, err := legacy.SignedString(maker.secret())
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	_, err = maker.VerifyAccessToken(ctx, legacyString)
+	assertErr(t, err, ErrWrongTokenType)
+
+	if _, err := NewTokenMaker(Config{
+		Secret:          "test-secret-must-be-at-least-32-bytes",
+		Issuer:          "test-issuer",
+		Audience:        "test-audience",
+		AccessAlgorithm: "none",
+	}, nil); err == nil {
+		t.Error("expected unsupported algorithm to be rejected")
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{errors.New("boom"), ""},
+		{ErrTokenExpired, CodeTokenExpired},
+		{fmt.Errorf("verify: %w", ErrTokenRevoked), CodeTokenRevoked},
+		{ErrInvalidSignature, CodeInvalidSignature},
+		{fmt.Errorf("%w: unknown token", ErrInvalidToken), CodeInvalidToken},
+		{ErrWebhookReplayed, CodeWebhookReplayed},
+		{ErrInvalidWebhook, CodeInvalidWebhook},
+		{ErrRateLimited, CodeRateLimited},
+	}
+	for _, tt := range tests {
+		if got := ErrorCode(tt.err); got != tt.want {
+			t.Errorf("ErrorCode(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FamilyRevocationRepository is an optional extension of RevocationRepository
+// that revokes a refresh token family: the token issued by CreateRefreshToken
+// and every token rotated from it, which all carry the same fam claim. One
+// record covers the whole chain, so descendants are revoked without tracking
+// them individually.
+//
+// Decorators that cannot reach a family-capable store return an error
+// wrapping errors.ErrUnsupported; the TokenMaker then skips the family check.
+type FamilyRevocationRepository interface {
+	MarkFamilyRevoked(ctx context.Context, familyID uuid.UUID, ttl time.Duration) error
+	IsFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error)
+}
+
+// RevokeFamily revokes every refresh token of familyID. RotateRefreshToken
+// keeps the family ID and rejects revoked families, so the record is kept for
+// the refresh token lifetime plus leeway only.
+func (tm *TokenMaker) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	if tm.repo == nil {
+		return ErrRevocationDisabled
+	}
+	families, ok := tm.repo.(FamilyRevocationRepository)
+	if !ok {
+		return fmt.Errorf("revoke family: %w", errors.ErrUnsupported)
+	}
+	if familyID == uuid.Nil {
+		return fmt.Errorf("family id is required")
+	}
+
+	rctx, cancel := tm.repoContext(ctx)
+	defer cancel()
+	if err := families.MarkFamilyRevoked(rctx, familyID, tm.policy().refreshExpiry+DefaultLeeway); err != nil {
+		return err
+	}
+	tm.stats.recordFamilyRevoked()
+	return nil
+}
+
+// checkFamilyRevoked rejects refresh tokens whose family was revoked.
+func (tm *TokenMaker) checkFamilyRevoked(ctx context.Context, claims *TokenClaims) error {
+	families, ok := tm.repo.(FamilyRevocationRepository)
+	if !ok || claims.FamilyID == uuid.Nil {
+		return nil
+	}
+
+	rctx, cancel := tm.repoContext(ctx)
+	revoked, err := families.IsFamilyRevoked(rctx, claims.FamilyID)
+	cancel()
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("check family revocation: %w", err)
+	}
+	if revoked {
+		return ErrFamilyRevoked
+	}
+	return nil
+}
+
+// ReuseEvent describes a refresh token that was presented again after
+// rotation.
+type ReuseEvent struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	FamilyID  uuid.UUID
+	TokenID   uuid.UUID
+	// Err reports a failure to revoke the family or session; nil means the
+	// stores that support it recorded the revocation.
+	Err error
+}
+
+// ReuseHandler is notified of refresh token reuse, typically to raise a
+// security alert or notify the user. It runs synchronously inside
+// RotateRefreshToken after the family and session were revoked, so it should
+// return quickly.
+type ReuseHandler func(ctx context.Context, ev ReuseEvent)
+
+// WithReuseHandler sets the callback invoked when RotateRefreshToken detects a
+// replayed refresh token.
+func WithReuseHandler(h ReuseHandler) Option {
+	return func(o *makerOptions) { o.reuseHandler = h }
+}
+
+// replayDetected handles a refresh token presented again after rotation and
+// returns ErrTokenRotated. Either the legitimate client or an attacker holds a
+// stolen copy, and the server cannot tell which, so the token's family and
+// session are revoked, including the tokens issued to whoever rotated first.
+// The signature is verified before the token's claims are trusted.
+func (tm *TokenMaker) replayDetected(ctx context.Context, tokenString string) error {
+	signed, err := tm.resolve(ctx, RefreshToken, tokenString)
+	if err != nil {
+		return ErrTokenRotated
+	}
+	_, claims, err := tm.parseToken(signed, RefreshToken)
+	if err != nil {
+		return ErrTokenRotated
+	}
+
+	var errs []error
+	if claims.FamilyID != uuid.Nil {
+		if err := tm.RevokeFamily(ctx, claims.FamilyID); err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			errs = append(errs, fmt.Errorf("revoke family: %w", err))
+		}
+	}
+	if claims.SessionID != uuid.Nil {
+		if err := tm.RevokeSession(ctx, claims.SessionID); err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			errs = append(errs, fmt.Errorf("revoke session: %w", err))
+		}
+	}
+	revokeErr := errors.Join(errs...)
+
+	if tm.reuseHandler != nil {
+		tm.reuseHandler(ctx, ReuseEvent{
+			UserID:    claims.Subject,
+			SessionID: claims.SessionID,
+			FamilyID:  claims.FamilyID,
+			TokenID:   claims.ID,
+			Err:       revokeErr,
+		})
+	}
+	tm.reportReuse(ctx, claims)
+	if revokeErr != nil {
+		return errors.Join(ErrTokenRotated, revokeErr)
+	}
+	return ErrTokenRotated
+}
@@ -0,0 +1,151 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// familyRepo adds FamilyRevocationRepository to mockRevocationRepo.
+type familyRepo struct {
+	*mockRevocationRepo
+	families map[uuid.UUID]struct{}
+}
+
+func (r *familyRepo) MarkFamilyRevoked(_ context.Context, familyID uuid.UUID, _ time.Duration) error {
+	r.families[familyID] = struct{}{}
+	return nil
+}
+
+func (r *familyRepo) IsFamilyRevoked(_ context.Context, familyID uuid.UUID) (bool, error) {
+	_, ok := r.families[familyID]
+	return ok, nil
+}
+
+func TestRotateRefreshToken_ReplayRevokesFamily(t *testing.T) {
+	repo := &familyRepo{mockRevocationRepo: newMockRevocationRepo(), families: make(map[uuid.UUID]struct{})}
+	maker := newSessionTestMaker(t, repo)
+	ctx := context.Background()
+
+	first, err := maker.CreateRefreshToken(ctx, uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	second, err := maker.RotateRefreshToken(ctx, first.Token)
+	if err != nil {
+		t.Fatalf("rotate refresh token: %v", err)
+	}
+	third, err := maker.RotateRefreshToken(ctx, second.Token)
+	if err != nil {
+		t.Fatalf("rotate refresh token: %v", err)
+	}
+
+	firstClaims, err := maker.verifyToken(first.Token, RefreshToken)
+	if err != nil {
+		t.Fatalf("parse refresh token: %v", err)
+	}
+	thirdClaims, err := maker.VerifyRefreshToken(ctx, third.Token)
+	if err != nil {
+		t.Fatalf("verify refresh token: %v", err)
+	}
+	if firstClaims.FamilyID == uuid.Nil || thirdClaims.FamilyID != firstClaims.FamilyID {
+		t.Fatalf("expected rotations to keep family %v, got %v", firstClaims.FamilyID, thirdClaims.FamilyID)
+	}
+
+	// Replaying the first token revokes every descendant.
+	if _, err := maker.RotateRefreshToken(ctx, first.Token); !errors.Is(err, ErrTokenRotated) {
+		t.Fatalf("expected ErrTokenRotated on replay, got %v", err)
+	}
+	if _, err := maker.VerifyRefreshToken(ctx, third.Token); !errors.Is(err, ErrFamilyRevoked) {
+		t.Errorf("expected ErrFamilyRevoked for the latest token, got %v", err)
+	}
+
+	// Other families are unaffected.
+	other, err := maker.CreateRefreshToken(ctx, uuid.New(), "bob", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if _, err := maker.RotateRefreshToken(ctx, other.Token); err != nil {
+		t.Errorf("expected another family to rotate, got %v", err)
+	}
+}
+
+func TestRevokeFamily_Unsupported(t *testing.T) {
+	maker := newSessionTestMaker(t, newMockRevocationRepo())
+
+	err := maker.RevokeFamily(context.Background(), uuid.New())
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected errors.ErrUnsupported, got %v", err)
+	}
+}
+
+// familySessionRepo supports both family and session revocation.
+type familySessionRepo struct {
+	*sessionRepo
+	families *familyRepo
+}
+
+func (r *familySessionRepo) MarkFamilyRevoked(ctx context.Context, familyID uuid.UUID, ttl time.Duration) error {
+	return r.families.MarkFamilyRevoked(ctx, familyID, ttl)
+}
+
+func (r *familySessionRepo) IsFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error) {
+	return r.families.IsFamilyRevoked(ctx, familyID)
+}
+
+func TestRotateRefreshToken_ReuseHandler(t *testing.T) {
+	base := newMockRevocationRepo()
+	repo := &familySessionRepo{
+		sessionRepo: &sessionRepo{mockRevocationRepo: base, sessions: make(map[uuid.UUID]struct{})},
+		families:    &familyRepo{mockRevocationRepo: base, families: make(map[uuid.UUID]struct{})},
+	}
+	var events []ReuseEvent
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: 24 * time.Hour,
+	}, repo, WithReuseHandler(func(_ context.Context, ev ReuseEvent) {
+		events = append(events, ev)
+	}))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+
+	userID, sessionID := uuid.New(), uuid.New()
+	access, err := maker.CreateAccessToken(ctx, userID, "alice", nil, sessionID)
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	refresh, err := maker.CreateRefreshToken(ctx, userID, "alice", nil, sessionID)
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if _, err := maker.RotateRefreshToken(ctx, refresh.Token); err != nil {
+		t.Fatalf("rotate refresh token: %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no reuse events, got %d", len(events))
+	}
+
+	if _, err := maker.RotateRefreshToken(ctx, refresh.Token); !errors.Is(err, ErrTokenRotated) {
+		t.Fatalf("expected ErrTokenRotated on replay, got %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected one reuse event, got %d", len(events))
+	}
+	ev := events[0]
+	if ev.UserID != userID || ev.SessionID != sessionID || ev.FamilyID == uuid.Nil || ev.Err != nil {
+		t.Errorf("unexpected reuse event %+v", ev)
+	}
+
+	// The session is revoked as well, taking its access tokens with it.
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("expected ErrSessionRevoked for the session's access token, got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package jwt
+
+import (
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWT typ header values pinned per token type (RFC 9068 style). A token whose
+// header names the other type is rejected before its signature is checked.
+const (
+	AccessTokenTyp  = "at+jwt"
+	RefreshTokenTyp = "rt+jwt"
+
+	// legacyTyp is the generic header written by tokens issued before typ
+	// pinning. It is accepted unless StrictTypHeader is set.
+	legacyTyp = "JWT"
+)
+
+// DefaultAlgorithm is the signing algorithm used when none is configured.
+const DefaultAlgorithm = "HS256"
+
+// symmetricMethods lists the algorithms a TokenMaker can be pinned to.
+var symmetricMethods = map[string]*jwt.SigningMethodHMAC{
+	"HS256": jwt.SigningMethodHS256,
+	"HS384": jwt.SigningMethodHS384,
+	"HS512": jwt.SigningMethodHS512,
+}
+
+// typHeader returns the pinned typ header for tokenType.
+func typHeader(tokenType TokenType) string {
+	if tokenType == RefreshToken {
+		return RefreshTokenTyp
+	}
+	return AccessTokenTyp
+}
+
+// checkTypHeader rejects tokens whose typ header does not match expectedType.
+// A missing or generic "JWT" header is tolerated only when strict is false.
+func checkTypHeader(token *jwt.Token, expectedType TokenType, strict bool) error {
+	typ, _ := token.Header["typ"].(string)
+	switch typ {
+	case typHeader(expectedType):
+		return nil
+	case "", legacyTyp:
+		if strict {
+			return ErrWrongTokenType
+		}
+		return nil
+	default:
+		return ErrWrongTokenType
+	}
+}
+
+// checkAlgHeader rejects tokens not signed with exactly alg.
+func checkAlgHeader(token *jwt.Token, alg string) error {
+	if token.Method == nil || token.Method.Alg() != alg {
+		return ErrUnexpectedAlgorithm
+	}
+	return nil
+}
+
+// resolveSymmetricMethod validates a configured algorithm name.
+func resolveSymmetricMethod(field, alg string) (*jwt.SigningMethodHMAC, error) {
+	if alg == "" {
+		alg = DefaultAlgorithm
+	}
+	method, ok := symmetricMethods[alg]
+	if !ok {
+		return nil, fmt.Errorf("config.%s %q is not a supported HMAC algorithm", field, alg)
+	}
+	return method, nil
+}
@@ -0,0 +1,94 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Pinger is an optional extension of RevocationRepository and
+// InvalidationSource that reports whether the backing store is reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Ping pings v if it implements Pinger and returns nil otherwise. Repository
+// decorators use it to forward health checks to the store they wrap.
+func Ping(ctx context.Context, v any) error {
+	if p, ok := v.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// ErrCleanupStale is returned by CheckCleanup when background cleanup has
+// not succeeded recently.
+var ErrCleanupStale = errors.New("revocation cleanup stale")
+
+// HealthCheck verifies that the maker can sign and verify tokens with its
+// key material and that its repository and invalidation source are reachable,
+// for readiness probes. Stores that do not implement Pinger are assumed
+// healthy.
+func (tm *TokenMaker) HealthCheck(ctx context.Context) error {
+	if err := tm.CheckKeys(ctx); err != nil {
+		return fmt.Errorf("health check: %w", err)
+	}
+	if err := tm.CheckRepository(ctx); err != nil {
+		return fmt.Errorf("health check: %w", err)
+	}
+	return nil
+}
+
+// CheckKeys signs and verifies a probe token to make sure the key material
+// is usable.
+func (tm *TokenMaker) CheckKeys(ctx context.Context) error {
+	probe, err := tm.CreateAccessToken(ctx, uuid.Nil, "", nil, uuid.Nil)
+	if err != nil {
+		return err
+	}
+	signed, err := tm.resolve(ctx, AccessToken, probe.Token)
+	if err != nil {
+		return fmt.Errorf("resolve probe token: %w", err)
+	}
+	if tm.opaque != nil {
+		_ = tm.opaque.DeleteOpaqueToken(ctx, AccessToken, HashToken(probe.Token))
+	}
+	if _, _, err := tm.parseToken(signed, AccessToken); err != nil {
+		return fmt.Errorf("verify probe token: %w", err)
+	}
+	return nil
+}
+
+// CheckRepository pings the revocation repository and the invalidation
+// source.
+func (tm *TokenMaker) CheckRepository(ctx context.Context) error {
+	var errs []error
+	if err := Ping(ctx, tm.repo); err != nil {
+		errs = append(errs, fmt.Errorf("revocation repository: %w", err))
+	}
+	if err := Ping(ctx, tm.invalidation); err != nil {
+		errs = append(errs, fmt.Errorf("invalidation source: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// CheckCleanup returns ErrCleanupStale when background cleanup is running
+// but no run succeeded within maxAge, counting from when the maker was
+// created. Without background cleanup it always succeeds.
+func (tm *TokenMaker) CheckCleanup(maxAge time.Duration) error {
+	if tm.stopCleanup == nil {
+		return nil
+	}
+	stats := tm.stats.snapshot()
+	last := stats.LastSuccessfulCleanup
+	if last.IsZero() {
+		last = stats.Since
+	}
+	if age := tm.clock.Now().Sub(last); age > maxAge {
+		return fmt.Errorf("%w: last success %s ago", ErrCleanupStale, age.Truncate(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// pingRepo adds Pinger to mockRevocationRepo.
+type pingRepo struct {
+	*mockRevocationRepo
+	err error
+}
+
+func (r *pingRepo) Ping(context.Context) error {
+	return r.err
+}
+
+func TestHealthCheck(t *testing.T) {
+	repo := &pingRepo{mockRevocationRepo: newMockRevocationRepo()}
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, repo)
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := maker.HealthCheck(ctx); err != nil {
+		t.Errorf("expected healthy maker, got %v", err)
+	}
+
+	repo.err = errors.New("connection refused")
+	if err := maker.HealthCheck(ctx); !errors.Is(err, repo.err) {
+		t.Errorf("expected repository ping error, got %v", err)
+	}
+}
+
+func TestCheckCleanup(t *testing.T) {
+	clock := newFakeClock()
+	repo := &cleanerRepo{mockRevocationRepo: newMockRevocationRepo()}
+	maker, err := NewTokenMaker(Config{
+		Secret:   "test-secret-must-be-at-least-32-bytes",
+		Issuer:   "test-issuer",
+		Audience: "test-audience",
+	}, repo, WithClock(clock), WithCleanupInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	t.Cleanup(func() { _ = maker.Close() })
+
+	if err := maker.CheckCleanup(time.Hour); err != nil {
+		t.Errorf("expected fresh maker to pass, got %v", err)
+	}
+	clock.Advance(2 * time.Hour)
+	if err := maker.CheckCleanup(time.Hour); !errors.Is(err, ErrCleanupStale) {
+		t.Errorf("expected ErrCleanupStale, got %v", err)
+	}
+	if _, err := maker.CleanupNow(context.Background()); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if err := maker.CheckCleanup(time.Hour); err != nil {
+		t.Errorf("expected check to pass after a cleanup, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// staticInvalidation is a fixed-cutoff InvalidationSource for testing.
+type staticInvalidation struct {
+	global time.Time
+	users  map[uuid.UUID]time.Time
+}
+
+func (s *staticInvalidation) GlobalNotIssuedBefore(context.Context) (time.Time, error) {
+	return s.global, nil
+}
+
+func (s *staticInvalidation) UserNotIssuedBefore(_ context.Context, userID uuid.UUID) (time.Time, error) {
+	return s.users[userID], nil
+}
+
+func TestVerifyAccessToken_NotIssuedBefore(t *testing.T) {
+	src := &staticInvalidation{users: make(map[uuid.UUID]time.Time)}
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, nil, WithInvalidationSource(src), WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := context.Background()
+	userID := uuid.New()
+	otherID := uuid.New()
+	tok, err := maker.CreateAccessToken(ctx, userID, "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	other, err := maker.CreateAccessToken(ctx, otherID, "other", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	if _, err := maker.VerifyAccessToken(ctx, tok.Token); err != nil {
+		t.Fatalf("expected token to verify before invalidation, got: %v", err)
+	}
+
+	// A cutoff in the same second as issuance must not reject the token.
+	src.users[userID] = clock.Now().Add(500 * time.Millisecond)
+	if _, err := maker.VerifyAccessToken(ctx, tok.Token); err != nil {
+		t.Fatalf("expected token issued in the cutoff second to verify, got: %v", err)
+	}
+
+	src.users[userID] = clock.Now().Add(2 * time.Second)
+	if _, err := maker.VerifyAccessToken(ctx, tok.Token); !errors.Is(err, ErrTokenInvalidated) {
+		t.Errorf("expected token issued before the user cutoff to be rejected, got: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, other.Token); err != nil {
+		t.Errorf("expected other user's token to be unaffected, got: %v", err)
+	}
+
+	src.global = clock.Now().Add(2 * time.Second)
+	if _, err := maker.VerifyAccessToken(ctx, other.Token); !errors.Is(err, ErrTokenInvalidated) {
+		t.Errorf("expected token issued before the global cutoff to be rejected, got: %v", err)
+	}
+}
+
+func TestVerifyAccessToken_ConfigNotIssuedBefore(t *testing.T) {
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+		NotIssuedBefore:      time.Now().Add(time.Minute).Unix(),
+	}, nil)
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	tok, err := maker.CreateAccessToken(context.Background(), uuid.New(), "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(context.Background(), tok.Token); err == nil {
+		t.Error("expected token issued before the configured cutoff to be rejected")
+	}
+}
+
+// stuckInvalidation blocks every call until its context is done.
+type stuckInvalidation struct{}
+
+func (stuckInvalidation) GlobalNotIssuedBefore(ctx context.Context) (time.Time, error) {
+	<-ctx.Done()
+	return time.Time{}, ctx.Err()
+}
+
+func (stuckInvalidation) UserNotIssuedBefore(ctx context.Context, _ uuid.UUID) (time.Time, error) {
+	<-ctx.Done()
+	return time.Time{}, ctx.Err()
+}
+
+func (stuckInvalidation) SetUserNotIssuedBefore(ctx context.Context, _ uuid.UUID, _ time.Time) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestInvalidationSource_RepositoryTimeout(t *testing.T) {
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+		RepositoryTimeout:    20 * time.Millisecond,
+	}, nil, WithInvalidationSource(stuckInvalidation{}))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+	userID := uuid.New()
+	access, err := maker.CreateAccessToken(ctx, userID, "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	service, err := maker.CreateServiceToken(ctx, "billing", []string{"read"})
+	if err != nil {
+		t.Fatalf("create service token: %v", err)
+	}
+
+	for name, call := range map[string]func() error{
+		"verify access token": func() error {
+			_, err := maker.VerifyAccessToken(ctx, access.Token)
+			return err
+		},
+		"verify service token": func() error {
+			_, err := maker.VerifyServiceToken(ctx, service.Token)
+			return err
+		},
+		"revoke all user tokens": func() error {
+			return maker.RevokeAllUserTokens(ctx, userID)
+		},
+	} {
+		start := time.Now()
+		if err := call(); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: expected context.DeadlineExceeded, got %v", name, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s took %v despite the repository timeout", name, elapsed)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package jwt
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// NormalizeIssuer trims surrounding whitespace and trailing slashes, so
+// "https://auth.example.com/" and "https://auth.example.com" name the same
+// issuer. Configured issuers and the iss claim of verified tokens are both
+// normalized before they are compared.
+func NormalizeIssuer(issuer string) string {
+	return strings.TrimRight(strings.TrimSpace(issuer), "/")
+}
+
+// validateIssuer checks that issuer, normalized, is an http or https URL
+// with a host and no query or fragment, or a bare hostname such as
+// "growth-auth" or "auth.example.com".
+func validateIssuer(issuer string) error {
+	issuer = NormalizeIssuer(issuer)
+	if issuer == "" {
+		return errors.New("is required")
+	}
+	if !strings.Contains(issuer, "://") {
+		if !isHostname(issuer) {
+			return errors.New("must be an http(s) URL or a hostname")
+		}
+		return nil
+	}
+
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return errors.New("is not a valid URL")
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return errors.New("must use the http or https scheme")
+	}
+	if u.Host == "" || u.User != nil {
+		return errors.New("must have a host and no user info")
+	}
+	if u.RawQuery != "" || u.Fragment != "" || strings.HasSuffix(issuer, "?") || strings.HasSuffix(issuer, "#") {
+		return errors.New("must not have a query or fragment")
+	}
+	return nil
+}
+
+// isHostname reports whether s is a DNS hostname: dot-separated labels of
+// letters, digits and inner hyphens, at most 63 bytes each.
+func isHostname(s string) bool {
+	if len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
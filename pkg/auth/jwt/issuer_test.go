@@ -0,0 +1,68 @@
+package jwt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestValidateIssuer(t *testing.T) {
+	for issuer, valid := range map[string]bool{
+		"growth-auth":                   true,
+		"auth.example.com":              true,
+		"https://auth.example.com":      true,
+		"https://auth.example.com/":     true,
+		"https://auth.example.com/t/42": true,
+		"http://localhost:8080":         true,
+		"":                              false,
+		" / ":                           false,
+		"-auth":                         false,
+		"auth example":                  false,
+		"ftp://auth.example.com":        false,
+		"https://":                      false,
+		"https://user@auth.example.com": false,
+		"https://auth.example.com?a=1":  false,
+		"https://auth.example.com#x":    false,
+	} {
+		if err := validateIssuer(issuer); (err == nil) != valid {
+			t.Errorf("validateIssuer(%q) = %v, want valid %v", issuer, err, valid)
+		}
+	}
+}
+
+func TestIssuerNormalization(t *testing.T) {
+	cfg := Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "https://auth.example.com/",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}
+	issuing, err := NewTokenMaker(cfg, newMockRevocationRepo())
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+	token, err := issuing.CreateAccessToken(ctx, uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	claims, err := issuing.VerifyAccessToken(ctx, token.Token)
+	if err != nil {
+		t.Fatalf("verify access token: %v", err)
+	}
+	if claims.Issuer != "https://auth.example.com" {
+		t.Errorf("iss = %q, want the normalized issuer", claims.Issuer)
+	}
+
+	// A service configured without the trailing slash accepts the token.
+	cfg.Issuer = "https://auth.example.com"
+	verifying, err := NewTokenMaker(cfg, newMockRevocationRepo())
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	if _, err := verifying.VerifyAccessToken(ctx, token.Token); err != nil {
+		t.Errorf("verify with the unslashed issuer: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package jwt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JWE parameters of encrypted tokens: the signed JWT is the payload of a
+// compact JWE (RFC 7516) using direct encryption with a shared AES key.
+const (
+	jweAlg = "dir"
+	jweCty = "JWT"
+)
+
+// jweEncodings maps AES key sizes to their JWE "enc" names.
+var jweEncodings = map[int]string{
+	16: "A128GCM",
+	24: "A192GCM",
+	32: "A256GCM",
+}
+
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Cty string `json:"cty"`
+}
+
+// tokenCipher nests signed tokens in JWEs so clients and intermediaries
+// cannot read their claims.
+type tokenCipher struct {
+	aead   cipher.AEAD
+	enc    string
+	header string // base64url protected header, also the AAD
+}
+
+func newTokenCipher(key []byte) (*tokenCipher, error) {
+	enc, ok := jweEncodings[len(key)]
+	if !ok {
+		return nil, fmt.Errorf("encryption key must be 16, 24 or 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	header, err := json.Marshal(jweHeader{Alg: jweAlg, Enc: enc, Cty: jweCty})
+	if err != nil {
+		return nil, err
+	}
+	return &tokenCipher{aead: aead, enc: enc, header: base64.RawURLEncoding.EncodeToString(header)}, nil
+}
+
+// encrypt returns the compact JWE carrying signed.
+func (c *tokenCipher) encrypt(signed string) (string, error) {
+	iv := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", fmt.Errorf("generate iv: %w", err)
+	}
+	sealed := c.aead.Seal(nil, iv, []byte(signed), []byte(c.header))
+	ciphertext, tag := sealed[:len(sealed)-c.aead.Overhead()], sealed[len(sealed)-c.aead.Overhead():]
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	// The encrypted key part is empty with direct encryption.
+	return c.header + ".." + b64(iv) + "." + b64(ciphertext) + "." + b64(tag), nil
+}
+
+// decrypt returns the signed token inside the compact JWE token. Anything
+// that is not a JWE this cipher produced is rejected, including plain
+// signed tokens.
+func (c *tokenCipher) decrypt(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 || parts[1] != "" {
+		return "", ErrMalformedToken
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrMalformedToken
+	}
+	var header jweHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return "", ErrMalformedToken
+	}
+	if header.Alg != jweAlg || header.Enc != c.enc {
+		return "", ErrUnexpectedAlgorithm
+	}
+
+	iv, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(iv) != c.aead.NonceSize() {
+		return "", ErrMalformedToken
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", ErrMalformedToken
+	}
+	tag, err := base64.RawURLEncoding.DecodeString(parts[4])
+	if err != nil || len(tag) != c.aead.Overhead() {
+		return "", ErrMalformedToken
+	}
+
+	signed, err := c.aead.Open(nil, iv, append(ciphertext, tag...), []byte(parts[0]))
+	if err != nil {
+		return "", ErrDecryptionFailed
+	}
+	return string(signed), nil
+}
+
+// decodeEncryptionKey decodes a base64 (standard or URL alphabet, padded or
+// not) encryption key from configuration.
+func decodeEncryptionKey(s string) ([]byte, error) {
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if key, err := enc.DecodeString(s); err == nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("encryption key is not valid base64")
+}
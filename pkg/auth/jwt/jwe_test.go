@@ -0,0 +1,173 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var testEncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+
+func newEncryptingMaker(t *testing.T, repo RevocationRepository, format TokenFormat) *TokenMaker {
+	t.Helper()
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Minute,
+		RefreshExpiryDuration: time.Hour,
+		TokenFormat:           format,
+		EncryptionKey:         base64.StdEncoding.EncodeToString(testEncryptionKey),
+	}, repo)
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	return maker
+}
+
+func TestEncryptedTokens(t *testing.T) {
+	ctx := context.Background()
+	maker := newEncryptingMaker(t, newMockRevocationRepo(), "")
+
+	resp, err := maker.CreateAccessToken(ctx, uuid.New(), "alice", []string{"admin"}, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	parts := strings.Split(resp.Token, ".")
+	if len(parts) != 5 || parts[1] != "" {
+		t.Fatalf("expected a compact dir JWE, got %q", resp.Token)
+	}
+	header, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	if string(header) != `{"alg":"dir","enc":"A256GCM","cty":"JWT"}` {
+		t.Errorf("header = %s", header)
+	}
+	ciphertext, _ := base64.RawURLEncoding.DecodeString(parts[3])
+	if strings.Contains(string(ciphertext), "alice") {
+		t.Error("claims are readable from the encrypted token")
+	}
+
+	claims, err := maker.VerifyAccessToken(ctx, resp.Token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.Username != "alice" {
+		t.Errorf("username = %q, want alice", claims.Username)
+	}
+
+	// A plain signed token is not accepted once encryption is on.
+	plain, err := maker.cipher.decrypt(resp.Token)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, plain); !errors.Is(err, ErrMalformedToken) {
+		t.Errorf("verify plain token: err = %v, want ErrMalformedToken", err)
+	}
+
+	// Tampering with the ciphertext breaks the authentication tag.
+	parts[3] = base64.RawURLEncoding.EncodeToString(append(ciphertext[:len(ciphertext)-1], ciphertext[len(ciphertext)-1]^1))
+	if _, err := maker.VerifyAccessToken(ctx, strings.Join(parts, ".")); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("verify tampered token: err = %v, want ErrDecryptionFailed", err)
+	}
+
+	if err := maker.RevokeAccessToken(ctx, resp.Token); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, resp.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("verify revoked token: err = %v, want ErrTokenRevoked", err)
+	}
+}
+
+func TestEncryptedRefreshRotation(t *testing.T) {
+	ctx := context.Background()
+	for _, format := range []TokenFormat{TokenFormatJWT, TokenFormatOpaque} {
+		t.Run(string(format), func(t *testing.T) {
+			maker := newEncryptingMaker(t, newOpaqueRepo(), format)
+			first, err := maker.CreateRefreshToken(ctx, uuid.New(), "alice", nil, uuid.New())
+			if err != nil {
+				t.Fatalf("create refresh token: %v", err)
+			}
+			second, err := maker.RotateRefreshToken(ctx, first.Token)
+			if err != nil {
+				t.Fatalf("rotate: %v", err)
+			}
+			if _, err := maker.VerifyRefreshToken(ctx, second.Token); err != nil {
+				t.Errorf("verify rotated token: %v", err)
+			}
+			if _, err := maker.RotateRefreshToken(ctx, first.Token); !errors.Is(err, ErrTokenRotated) {
+				t.Errorf("replay: err = %v, want ErrTokenRotated", err)
+			}
+		})
+	}
+}
+
+func TestEncryptionKeyConfig(t *testing.T) {
+	cfg := Config{
+		Secret:        "test-secret-must-be-at-least-32-bytes",
+		Issuer:        "test-issuer",
+		Audience:      "test-audience",
+		EncryptionKey: base64.StdEncoding.EncodeToString([]byte("too short")),
+	}
+	if _, err := NewTokenMaker(cfg, nil); err == nil {
+		t.Error("expected an error for a 9 byte key")
+	}
+	cfg.EncryptionKey = "not base64!"
+	if _, err := NewTokenMaker(cfg, nil); err == nil {
+		t.Error("expected an error for a key that is not base64")
+	}
+}
+
+func TestVerifierDecryptsTokens(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, &TokenClaims{
+		ID:        uuid.New(),
+		Subject:   uuid.New(),
+		Issuer:    "test-issuer",
+		Audience:  []string{"test-audience"},
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		TokenType: AccessToken,
+	})
+	token.Header["typ"] = AccessTokenTyp
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc, err := newTokenCipher(testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := tc.encrypt(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewVerifier(VerifierConfig{
+		Issuer:        "test-issuer",
+		Audience:      "test-audience",
+		KeyFunc:       &StaticKeyFunc{Key: &key.PublicKey},
+		EncryptionKey: testEncryptionKey,
+	})
+	if err != nil {
+		t.Fatalf("create verifier: %v", err)
+	}
+	if _, err := v.VerifyAccessToken(context.Background(), encrypted); err != nil {
+		t.Errorf("verify encrypted token: %v", err)
+	}
+	if _, err := v.VerifyAccessToken(context.Background(), signed); !errors.Is(err, ErrMalformedToken) {
+		t.Errorf("verify plain token: err = %v, want ErrMalformedToken", err)
+	}
+}
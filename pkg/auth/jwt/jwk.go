@@ -0,0 +1,113 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JWKThumbprint returns the RFC 7638 SHA-256 thumbprint of a public JWK.
+func JWKThumbprint(jwk map[string]any) (string, error) {
+	_, jkt, err := parsePublicJWK(jwk)
+	return jkt, err
+}
+
+// ParseJWK decodes an RSA, EC or Ed25519 public JWK (RFC 7517) into a key
+// usable with KeyFunc.
+func ParseJWK(jwk map[string]any) (any, error) {
+	key, _, err := parsePublicJWK(jwk)
+	return key, err
+}
+
+// parsePublicJWK decodes an RSA, EC or Ed25519 public JWK and computes its
+// thumbprint. JWKs carrying private key members are rejected.
+func parsePublicJWK(jwk map[string]any) (any, string, error) {
+	if _, ok := jwk["d"]; ok {
+		return nil, "", errors.New("jwk contains a private key")
+	}
+	str := func(name string) string { s, _ := jwk[name].(string); return s }
+	b64 := func(name string) ([]byte, error) {
+		b, err := base64.RawURLEncoding.DecodeString(str(name))
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("jwk member %q is invalid", name)
+		}
+		return b, nil
+	}
+
+	var (
+		key     any
+		members []string
+	)
+	switch kty := str("kty"); kty {
+	case "RSA":
+		n, err := b64("n")
+		if err != nil {
+			return nil, "", err
+		}
+		e, err := b64("e")
+		if err != nil {
+			return nil, "", err
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.N.BitLen() < 2048 {
+			return nil, "", errors.New("rsa key smaller than 2048 bits")
+		}
+		key, members = pub, []string{"e", "kty", "n"}
+	case "EC":
+		var curve elliptic.Curve
+		switch str("crv") {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, "", fmt.Errorf("unsupported curve %q", str("crv"))
+		}
+		x, err := b64("x")
+		if err != nil {
+			return nil, "", err
+		}
+		y, err := b64("y")
+		if err != nil {
+			return nil, "", err
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, "", errors.New("ec point is not on the curve")
+		}
+		key, members = pub, []string{"crv", "kty", "x", "y"}
+	case "OKP":
+		if str("crv") != "Ed25519" {
+			return nil, "", fmt.Errorf("unsupported curve %q", str("crv"))
+		}
+		x, err := b64("x")
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, "", errors.New(`jwk member "x" is invalid`)
+		}
+		key, members = ed25519.PublicKey(x), []string{"crv", "kty", "x"}
+	default:
+		return nil, "", fmt.Errorf("unsupported key type %q", kty)
+	}
+
+	// The thumbprint input is the required members in lexicographic order
+	// without whitespace; json.Marshal sorts map keys.
+	required := make(map[string]string, len(members))
+	for _, m := range members {
+		required[m] = str(m)
+	}
+	canonical, err := json.Marshal(required)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return key, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
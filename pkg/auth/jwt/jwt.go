@@ -2,7 +2,11 @@ package jwt
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -12,9 +16,14 @@ import (
 // DefaultLeeway is the clock skew tolerance for time-based claims.
 const DefaultLeeway = 30 * time.Second
 
-// ErrInvalidToken is returned for all token verification failures to prevent
-// information leakage about which specific check failed.
-var ErrInvalidToken = fmt.Errorf("invalid token")
+// DefaultMaxTokenLength is the maximum accepted token size in bytes. Tokens
+// issued by this package are well under 1KB; the limit only exists to reject
+// adversarial multi-megabyte Authorization headers before any parsing.
+const DefaultMaxTokenLength = 8 << 10
+
+// DefaultRepositoryTimeout bounds a repository call made without a
+// deadline; see Config.RepositoryTimeout.
+const DefaultRepositoryTimeout = 5 * time.Second
 
 type TokenType string
 
@@ -35,6 +44,27 @@ type TokenClaims struct {
 	ExpiresAt *jwt.NumericDate `json:"exp"`
 	NotBefore *jwt.NumericDate `json:"nbf"`
 	TokenType TokenType        `json:"typ"`
+	// AuthTime is when the session was authenticated. Refresh tokens carry
+	// it across rotations so the session's maximum lifetime can be enforced;
+	// elevated access tokens carry the time of the second factor.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	// ACR, AMR and Scopes are set on access tokens issued by ElevateToken.
+	ACR    string   `json:"acr,omitempty"`
+	AMR    []string `json:"amr,omitempty"`
+	Scopes []string `json:"scp,omitempty"`
+	// RememberMe marks refresh tokens issued with WithRememberMe.
+	RememberMe bool `json:"rmb,omitempty"`
+	// FamilyID identifies a refresh token rotation chain; see
+	// FamilyRevocationRepository.
+	FamilyID uuid.UUID `json:"fam,omitempty"`
+	// DeviceID is the device a refresh token's session is bound to; see
+	// WithDevice.
+	DeviceID uuid.UUID `json:"did,omitempty"`
+	// Confirmation binds the token to a DPoP key; see WithDPoPKey.
+	Confirmation *Confirmation `json:"cnf,omitempty"`
+	// DerivedFrom is the jti of the token a downscoped access token was
+	// derived from; see DownscopeAccessToken.
+	DerivedFrom uuid.UUID `json:"drv,omitempty"`
 }
 
 func (c *TokenClaims) GetExpirationTime() (*jwt.NumericDate, error) {
@@ -61,258 +91,674 @@ func (c *TokenClaims) GetAudience() (jwt.ClaimStrings, error) {
 	return c.Audience, nil
 }
 
+// Validate implements jwt.ClaimsValidator, so the parser rejects tokens
+// without a jti or typ while decoding them, before the issuer, audience and
+// type checks run. The subject may be nil, as in health check probes.
+func (c *TokenClaims) Validate() error {
+	if c.ID == uuid.Nil || c.TokenType == "" {
+		return ErrMissingClaims
+	}
+	return nil
+}
+
 type TokenResponse struct {
 	Token     string
 	ExpiresAt time.Time
 }
 
+// TokenPair is an access and refresh token starting a new session, as
+// issued by CompleteMFAChallenge and PollPairing.
+type TokenPair struct {
+	Access  *TokenResponse
+	Refresh *TokenResponse
+	// SessionID is the session the tokens belong to.
+	SessionID uuid.UUID
+}
+
 type RevocationRepository interface {
 	MarkTokenRevoke(ctx context.Context, tokenType TokenType, token string, ttl time.Duration) error
 	IsTokenRevoked(ctx context.Context, tokenType TokenType, token string) (bool, error)
 }
 
+// AtomicRotationRepository is an optional extension of RevocationRepository.
+// MarkTokenRotated revokes a refresh token only if it is not revoked yet and
+// reports whether this call performed the rotation, so two concurrent
+// RotateRefreshToken calls with the same token cannot both succeed. The
+// TokenMaker passes it the token's rotation marker key rather than its
+// revocation key, so rotated tokens can be told from revoked ones.
+type AtomicRotationRepository interface {
+	MarkTokenRotated(ctx context.Context, token string, ttl time.Duration) (bool, error)
+}
+
+// AtomicConsumeRepository is an optional extension of RevocationRepository
+// for single-use tokens such as action tokens. MarkTokenConsumed records
+// token of tokenType only if it is not recorded yet and reports whether this
+// call recorded it, so a single-use token cannot be used twice, even by
+// concurrent requests. See MarkTokenConsumed.
+type AtomicConsumeRepository interface {
+	MarkTokenConsumed(ctx context.Context, tokenType TokenType, token string, ttl time.Duration) (bool, error)
+}
+
 type TokenMaker struct {
-	secret        string
-	issuer        string
-	audience      string
-	accessExpiry  time.Duration
-	refreshExpiry time.Duration
-	repo          RevocationRepository
+	// secrets holds the shared secret, replaced by ReloadSecret and Reload.
+	secrets        *atomic.Pointer[secretState]
+	secretProvider SecretProvider
+	// conf holds the reloadable part of the configuration and fixed the
+	// rest as configured; see Reload. reloadMu serializes reloads.
+	conf           atomic.Pointer[policy]
+	fixed          fixedConfig
+	reloadMu       sync.Mutex
+	issuer         string
+	repo           RevocationRepository
+	invalidation   InvalidationSource
+	clock          Clock
+	repoCheckOrder RepoCheckOrder
+	revokeBy       RevocationKey
+	storeRawTokens bool
+	sessions       SessionStore
+	reuseHandler   ReuseHandler
+	stopCleanup    context.CancelFunc
+	cleanupDone    chan struct{}
+	closeOnce      sync.Once
+	limiter        IssuanceLimiter
+	pairings       PairingStore
+	securityEvents SecurityEventHandler
+	failures       *failureCounter
+	// opaque is set in opaque mode and is the repository holding the claims.
+	opaque OpaqueTokenRepository
+	// cipher is set when tokens are encrypted.
+	cipher     *tokenCipher
+	dpopReplay DPoPReplayCache
+	pats       PersonalAccessTokenStore
+	patScopes  []string
+	stats      *statsRecorder
+	// traceHandler is set with WithDebugTracing.
+	traceHandler TraceHandler
+	lockout      *lockout
+	// slowThreshold and slowHandler are set with WithSlowOperationWarnings.
+	slowThreshold time.Duration
+	slowHandler   SlowOperationHandler
 }
 
 type Config struct {
-	Secret                string        `json:",optional" secret:"true"`
-	Issuer                string        `json:",optional"`
-	Audience              string        `json:",optional"`
-	AccessExpiryDuration  time.Duration `json:",optional"`
-	RefreshExpiryDuration time.Duration `json:",optional"`
+	// Secret is the shared HMAC secret, at least MinSecretLength bytes
+	// once decoded with SecretEncoding.
+	Secret string `json:",optional" secret:"true"`
+	// SecretEncoding is "raw" (default), "base64" or "hex".
+	SecretEncoding        SecretEncoding `json:",optional"`
+	Issuer                string         `json:",optional"`
+	Audience              string         `json:",optional"`
+	AccessExpiryDuration  time.Duration  `json:",optional"`
+	RefreshExpiryDuration time.Duration  `json:",optional"`
+	// AccessExpiryPolicies override AccessExpiryDuration per role or
+	// client, e.g. 10 minutes for "admin" tokens. The shortest matching
+	// policy wins; see ExpiryPolicy.
+	AccessExpiryPolicies []ExpiryPolicy `json:",optional"`
+	// MinExpiryOverride and MaxExpiryOverride bound the per-token expiry
+	// set with WithExpiry. Overrides are rejected while MaxExpiryOverride is
+	// zero.
+	MinExpiryOverride time.Duration `json:",optional"`
+	MaxExpiryOverride time.Duration `json:",optional"`
+	// RefreshMaxLifetimeExpiry turns refresh into a sliding window: each
+	// rotation issues a token valid for RefreshExpiryDuration again, but
+	// never past the session's login plus this duration, after which the
+	// user has to log in again. Zero keeps sessions alive for as long as
+	// they keep rotating.
+	RefreshMaxLifetimeExpiry time.Duration `json:",optional"`
+	// RefreshIdleTimeout expires a session whose refresh token was not
+	// rotated within it, even if RefreshExpiryDuration is longer. Zero
+	// disables the idle check.
+	RefreshIdleTimeout time.Duration `json:",optional"`
+	// RememberMeExpiryDuration and RememberMeMaxLifetimeExpiry replace
+	// RefreshExpiryDuration and RefreshMaxLifetimeExpiry for sessions
+	// started with WithRememberMe. RefreshIdleTimeout does not apply to
+	// them, since surviving inactivity is the point of "keep me signed in".
+	RememberMeExpiryDuration    time.Duration `json:",optional"`
+	RememberMeMaxLifetimeExpiry time.Duration `json:",optional"`
+	// NotIssuedBefore is a Unix timestamp (seconds). Tokens issued before it are
+	// rejected; bump it after a security incident to invalidate every token.
+	NotIssuedBefore int64 `json:",optional"`
+	// RepoCheckOrder is "signature_first" (default) or "repository_first".
+	RepoCheckOrder RepoCheckOrder `json:",optional"`
+	// MaxTokenLength in bytes; defaults to DefaultMaxTokenLength if zero.
+	MaxTokenLength int `json:",optional"`
+	// RepositoryTimeout bounds each revocation repository call whose
+	// context has no deadline, so a stuck backend cannot hang verification
+	// or rotation; defaults to DefaultRepositoryTimeout.
+	RepositoryTimeout time.Duration `json:",optional"`
+	// DisableBackgroundCleanup never starts the cleanup goroutine, whatever
+	// the cleanup options say, for deployments that run CleanupNow from an
+	// external scheduler such as a cron or Kubernetes Job, or that cannot
+	// keep goroutines alive between requests. Equivalent to
+	// WithoutBackgroundCleanup.
+	DisableBackgroundCleanup bool `json:",optional"`
+	// AccessAlgorithm and RefreshAlgorithm pin the HMAC algorithm per token
+	// type (HS256, HS384 or HS512); both default to HS256.
+	AccessAlgorithm  string `json:",optional"`
+	RefreshAlgorithm string `json:",optional"`
+	// StrictTypHeader rejects tokens carrying the generic "JWT" typ header
+	// instead of the per-type at+jwt / rt+jwt values. Enable it once every
+	// token issued before typ pinning has expired.
+	StrictTypHeader bool `json:",optional"`
+	// RevocationKey is "token" (default) or "jti". Switching modes does not
+	// carry over existing repository entries, so revocations made before the
+	// switch stop being enforced.
+	RevocationKey RevocationKey `json:",optional"`
+	// StoreRawTokens stores full token strings instead of their SHA-256
+	// hashes in token mode. Hashing keeps a repository dump from leaking
+	// usable credentials; set this only while revocations written by older
+	// versions, which stored raw tokens, are still unexpired.
+	StoreRawTokens bool `json:",optional"`
+	// IssuanceRateLimit is the sustained number of tokens per second a
+	// single user may be issued, with bursts of up to IssuanceBurst. Access
+	// and refresh tokens count separately, so a login uses two. Zero
+	// disables the limit.
+	IssuanceRateLimit float64 `json:",optional"`
+	IssuanceBurst     int     `json:",optional"`
+	// ElevatedExpiryDuration is the lifetime of tokens issued by
+	// ElevateToken; defaults to DefaultElevatedExpiry.
+	ElevatedExpiryDuration time.Duration `json:",optional"`
+	// MFAProofMaxAge is how recent the MFA proof given to ElevateToken must
+	// be; defaults to DefaultMFAProofMaxAge.
+	MFAProofMaxAge time.Duration `json:",optional"`
+	// MFAChallengeExpiryDuration is the lifetime of tokens issued by
+	// CreateMFAChallenge; defaults to DefaultMFAChallengeExpiry.
+	MFAChallengeExpiryDuration time.Duration `json:",optional"`
+	// DownscopeAudiences are the audiences, besides the token's own, that
+	// DownscopeAccessToken may narrow a token to: subsystems that accept
+	// this service's tokens but should not get its full ones.
+	DownscopeAudiences []string `json:",optional"`
+	// ServiceExpiryDuration is the lifetime of tokens issued by
+	// CreateServiceToken; defaults to DefaultServiceTokenExpiry.
+	ServiceExpiryDuration time.Duration `json:",optional"`
+	// PairingExpiryDuration is how long a pairing from CreatePairing can be
+	// claimed and exchanged; defaults to DefaultPairingExpiry.
+	PairingExpiryDuration time.Duration `json:",optional"`
+	// SecurityFailureThreshold failed verifications of one user's tokens
+	// within SecurityFailureWindow raise SecurityRepeatedFailures; they
+	// default to DefaultFailureThreshold and DefaultFailureWindow. Only
+	// used with WithSecurityEvents.
+	SecurityFailureThreshold int           `json:",optional"`
+	SecurityFailureWindow    time.Duration `json:",optional"`
+	// TokenFormat is "jwt" (default) or "opaque"; see TokenFormatOpaque.
+	TokenFormat TokenFormat `json:",optional"`
+	// EncryptionKey, if set, is a base64-encoded 16, 24 or 32 byte AES key.
+	// Signed tokens are then nested in a JWE (dir, A128GCM to A256GCM) so
+	// clients and intermediaries cannot read their claims, and only
+	// encrypted tokens are accepted: enabling it invalidates every token
+	// issued before. Verifiers need the same key; see
+	// VerifierConfig.EncryptionKey.
+	EncryptionKey string `json:",optional" secret:"true"`
+}
+
+// Option configures a TokenMaker at construction time.
+type Option func(*makerOptions)
+
+type makerOptions struct {
+	invalidation    InvalidationSource
+	clock           Clock
+	sessions        SessionStore
+	reuseHandler    ReuseHandler
+	cleanupSchedule CleanupSchedule
+	cleanupJitter   time.Duration
+	cleanupDisabled bool
+	limiter         IssuanceLimiter
+	dpopReplay      DPoPReplayCache
+	pats            PersonalAccessTokenStore
+	patScopes       []string
+	approvalAudit   ApprovalAuditor
+	pairings        PairingStore
+	securityEvents  SecurityEventHandler
+	traceHandler    TraceHandler
+	lockout         *lockout
+	slowThreshold   time.Duration
+	slowHandler     SlowOperationHandler
+	tokenKeys       map[TokenType]TokenKey
+	secretProvider  SecretProvider
+}
+
+// WithInvalidationSource sets the lookup for global and per-user
+// "not issued before" cutoffs.
+func WithInvalidationSource(s InvalidationSource) Option {
+	return func(o *makerOptions) { o.invalidation = s }
 }
 
-func NewTokenMaker(cfg Config, repo RevocationRepository) (*TokenMaker, error) {
-	if cfg.Secret == "" {
-		return nil, fmt.Errorf("config.Secret is required")
+func NewTokenMaker(cfg Config, repo RevocationRepository, opts ...Option) (*TokenMaker, error) {
+	o := makerOptions{clock: SystemClock{}}
+	for _, opt := range opts {
+		opt(&o)
 	}
-	if cfg.Issuer == "" {
-		return nil, fmt.Errorf("config.Issuer is required")
+	if o.secretProvider != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultSecretFetchTimeout)
+		secret, err := fetchSecret(ctx, o.secretProvider)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Secret = secret
 	}
-	if cfg.Audience == "" {
-		return nil, fmt.Errorf("config.Audience is required")
+
+	if err := validateConfig(cfg, repo, false); err != nil {
+		return nil, err
 	}
 
-	return &TokenMaker{
-		secret:        cfg.Secret,
-		issuer:        cfg.Issuer,
-		audience:      cfg.Audience,
-		accessExpiry:  cfg.AccessExpiryDuration,
-		refreshExpiry: cfg.RefreshExpiryDuration,
-		repo:          repo,
-	}, nil
-}
+	repoCheckOrder := cfg.RepoCheckOrder
+	if repoCheckOrder == "" {
+		repoCheckOrder = CheckSignatureFirst
+	}
+	revokeBy := cfg.RevocationKey
+	if revokeBy == "" {
+		revokeBy = RevocationKeyToken
+	}
+	var opaque OpaqueTokenRepository
+	if cfg.TokenFormat == TokenFormatOpaque {
+		opaque = repo.(OpaqueTokenRepository)
+	}
+
+	var tc *tokenCipher
+	if cfg.EncryptionKey != "" {
+		key, err := decodeEncryptionKey(cfg.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("config.EncryptionKey: %w", err)
+		}
+		if tc, err = newTokenCipher(key); err != nil {
+			return nil, fmt.Errorf("config.EncryptionKey: %w", err)
+		}
+	}
 
-func (tm *TokenMaker) CreateAccessToken(_ context.Context, userID uuid.UUID, username string, roles []string, sessionID uuid.UUID) (*TokenResponse, error) {
-	now := time.Now()
-	expiresAt := now.Add(tm.accessExpiry)
-
-	claims := TokenClaims{
-		ID:        uuid.New(),
-		Subject:   userID,
-		SessionID: sessionID,
-		Username:  username,
-		Roles:     roles,
-		Issuer:    tm.issuer,
-		Audience:  []string{tm.audience},
-		IssuedAt:  jwt.NewNumericDate(now),
-		ExpiresAt: jwt.NewNumericDate(expiresAt),
-		NotBefore: jwt.NewNumericDate(now),
-		TokenType: AccessToken,
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims)
-	tokenString, err := token.SignedString([]byte(tm.secret))
+	p, err := newPolicy(cfg, o.tokenKeys, o.clock)
 	if err != nil {
-		return nil, fmt.Errorf("sign token: %w", err)
+		return nil, err
+	}
+	secret, err := decodeSecret(cfg.Secret, cfg.SecretEncoding)
+	if err != nil {
+		return nil, fmt.Errorf("config.Secret: %w", err)
 	}
 
-	return &TokenResponse{
-		Token:     tokenString,
-		ExpiresAt: expiresAt,
-	}, nil
+	var failures *failureCounter
+	if o.securityEvents != nil {
+		threshold, window := cfg.SecurityFailureThreshold, cfg.SecurityFailureWindow
+		if threshold <= 0 {
+			threshold = DefaultFailureThreshold
+		}
+		if window <= 0 {
+			window = DefaultFailureWindow
+		}
+		failures = newFailureCounter(threshold, window)
+	}
+
+	limiter := o.limiter
+	if limiter == nil && cfg.IssuanceRateLimit > 0 {
+		limiter = newBucketLimiter(cfg.IssuanceRateLimit, cfg.IssuanceBurst, o.clock)
+	}
+
+	tm := &TokenMaker{
+		secrets:        newSecrets(secret),
+		secretProvider: o.secretProvider,
+		fixed:          fixedFields(cfg),
+		issuer:         NormalizeIssuer(cfg.Issuer),
+		repo:           repo,
+		invalidation:   o.invalidation,
+		clock:          o.clock,
+		repoCheckOrder: repoCheckOrder,
+		revokeBy:       revokeBy,
+		storeRawTokens: cfg.StoreRawTokens,
+		sessions:       o.sessions,
+		reuseHandler:   o.reuseHandler,
+		limiter:        limiter,
+		pairings:       o.pairings,
+		securityEvents: o.securityEvents,
+		failures:       failures,
+		opaque:         opaque,
+		cipher:         tc,
+		dpopReplay:     o.dpopReplay,
+		pats:           o.pats,
+		patScopes:      o.patScopes,
+		stats:          newStatsRecorder(o.clock.Now()),
+		traceHandler:   o.traceHandler,
+		lockout:        o.lockout,
+		slowThreshold:  o.slowThreshold,
+		slowHandler:    o.slowHandler,
+	}
+	tm.conf.Store(p)
+	if o.lockout != nil {
+		if store, ok := o.lockout.store.(*MemoryFailureStore); ok {
+			store.useClock(o.clock)
+		}
+	}
+	if cfg.DisableBackgroundCleanup {
+		o.cleanupDisabled = true
+	}
+	tm.startCleanup(o)
+	return tm, nil
 }
 
-func (tm *TokenMaker) CreateRefreshToken(_ context.Context, userID uuid.UUID, username string, roles []string, sessionID uuid.UUID) (*TokenResponse, error) {
-	now := time.Now()
-	expiresAt := now.Add(tm.refreshExpiry)
-
-	claims := TokenClaims{
-		ID:        uuid.New(),
-		Subject:   userID,
-		SessionID: sessionID,
-		Username:  username,
-		Roles:     roles,
-		Issuer:    tm.issuer,
-		Audience:  []string{tm.audience},
-		IssuedAt:  jwt.NewNumericDate(now),
-		ExpiresAt: jwt.NewNumericDate(expiresAt),
-		NotBefore: jwt.NewNumericDate(now),
-		TokenType: RefreshToken,
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims)
-	tokenString, err := token.SignedString([]byte(tm.secret))
+func (tm *TokenMaker) CreateAccessToken(ctx context.Context, userID uuid.UUID, username string, roles []string, sessionID uuid.UUID) (*TokenResponse, error) {
+	if err := tm.allowIssue(ctx, userID); err != nil {
+		return nil, err
+	}
+	expiry, err := tm.accessExpiryFor(ctx, roles)
+	if err != nil {
+		return nil, err
+	}
+	now := tm.clock.Now()
+	return tm.signAccessToken(ctx, TokenClaims{
+		ID:           uuid.New(),
+		Subject:      userID,
+		SessionID:    sessionID,
+		Username:     username,
+		Roles:        roles,
+		Issuer:       tm.issuer,
+		Audience:     []string{tm.policy().audience},
+		IssuedAt:     jwt.NewNumericDate(now),
+		ExpiresAt:    jwt.NewNumericDate(now.Add(expiry)),
+		NotBefore:    jwt.NewNumericDate(now),
+		TokenType:    AccessToken,
+		Confirmation: confirmationFrom(ctx),
+	})
+}
+
+func (tm *TokenMaker) signAccessToken(ctx context.Context, claims TokenClaims) (*TokenResponse, error) {
+	token := tm.policy().accessKey.newToken(&claims, AccessTokenTyp)
+	tokenString, err := tm.signToken(ctx, AccessToken, token, tm.signingKey(tm.policy().accessKey))
 	if err != nil {
 		return nil, fmt.Errorf("sign token: %w", err)
 	}
 
-	return &TokenResponse{
-		Token:     tokenString,
-		ExpiresAt: expiresAt,
-	}, nil
+	return tm.issue(ctx, AccessToken, tokenString, claims.ExpiresAt.Time)
 }
 
-func (tm *TokenMaker) VerifyAccessToken(ctx context.Context, tokenString string) (*TokenClaims, error) {
-	claims, err := tm.verifyToken(tokenString, AccessToken)
-	if err != nil {
+// CreateRefreshToken issues a refresh token starting a session. With a
+// SessionStore configured, the session is recorded together with any
+// ClientInfo attached to ctx.
+func (tm *TokenMaker) CreateRefreshToken(ctx context.Context, userID uuid.UUID, username string, roles []string, sessionID uuid.UUID, opts ...RefreshOption) (*TokenResponse, error) {
+	var o refreshOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := tm.allowIssue(ctx, userID); err != nil {
 		return nil, err
 	}
 
-	if tm.repo != nil {
-		revoked, err := tm.repo.IsTokenRevoked(ctx, AccessToken, tokenString)
-		if err != nil {
-			return nil, fmt.Errorf("check revocation: %w", err)
-		}
-		if revoked {
-			return nil, fmt.Errorf("token revoked")
-		}
+	now := tm.clock.Now()
+	resp, err := tm.createRefreshToken(ctx, TokenClaims{
+		Subject:      userID,
+		SessionID:    sessionID,
+		Username:     username,
+		Roles:        roles,
+		AuthTime:     jwt.NewNumericDate(now),
+		RememberMe:   o.rememberMe,
+		FamilyID:     uuid.New(),
+		DeviceID:     o.deviceID,
+		Confirmation: confirmationFrom(ctx),
+	}, now)
+	if err != nil {
+		return nil, err
 	}
-
-	return claims, nil
+	if err := tm.saveSession(ctx, userID, sessionID, o.deviceID, now, resp.ExpiresAt.Sub(now)); err != nil {
+		return nil, err
+	}
+	return resp, nil
 }
 
-func (tm *TokenMaker) VerifyRefreshToken(ctx context.Context, tokenString string) (*TokenClaims, error) {
-	claims, err := tm.verifyToken(tokenString, RefreshToken)
+// createRefreshToken signs a refresh token issued at now. claims carries the
+// session state (subject, session, family, device, profile and auth_time);
+// the remaining registered claims are filled in here.
+func (tm *TokenMaker) createRefreshToken(ctx context.Context, claims TokenClaims, now time.Time) (*TokenResponse, error) {
+	expiresAt := tm.profileFor(claims.RememberMe).refreshExpiresAt(claims.AuthTime.Time, now)
+
+	claims.ID = uuid.New()
+	claims.Issuer = tm.issuer
+	claims.Audience = []string{tm.policy().audience}
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(expiresAt)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.TokenType = RefreshToken
+
+	token := tm.policy().refreshKey.newToken(&claims, RefreshTokenTyp)
+	tokenString, err := tm.signToken(ctx, RefreshToken, token, tm.signingKey(tm.policy().refreshKey))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("sign token: %w", err)
 	}
 
-	if tm.repo != nil {
-		revoked, err := tm.repo.IsTokenRevoked(ctx, RefreshToken, tokenString)
-		if err != nil {
-			return nil, fmt.Errorf("check revocation: %w", err)
-		}
-		if revoked {
-			return nil, fmt.Errorf("token revoked")
-		}
+	return tm.issue(ctx, RefreshToken, tokenString, expiresAt)
+}
+
+func (tm *TokenMaker) VerifyAccessToken(ctx context.Context, tokenString string) (*TokenClaims, error) {
+	if tm.pats != nil && strings.HasPrefix(tokenString, PersonalAccessTokenPrefix) {
+		return tm.verifyPersonalAccessToken(ctx, tokenString)
 	}
+	_, claims, err := tm.verify(ctx, tokenString, AccessToken, !IsOfflineVerification(ctx))
+	return claims, err
+}
 
-	return claims, nil
+func (tm *TokenMaker) VerifyRefreshToken(ctx context.Context, tokenString string) (*TokenClaims, error) {
+	_, claims, err := tm.verify(ctx, tokenString, RefreshToken, !IsOfflineVerification(ctx))
+	return claims, err
 }
 
 func (tm *TokenMaker) verifyToken(tokenString string, expectedType TokenType) (*TokenClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
+	_, claims, err := tm.parseToken(tokenString, expectedType)
+	return claims, err
+}
+
+// parseToken verifies the signature and claims of tokenString and returns the
+// parsed token alongside its typed claims.
+func (tm *TokenMaker) parseToken(tokenString string, expectedType TokenType) (*jwt.Token, *TokenClaims, error) {
+	p := tm.policy()
+	key, parser := p.accessKey, p.accessParser
+	if expectedType == RefreshToken {
+		key, parser = p.refreshKey, p.refreshParser
+	}
+	alg := key.method.Alg()
+	token, err := parser.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if err := checkAlgHeader(token, alg); err != nil {
+			return nil, err
+		}
+		if err := checkTypHeader(token, expectedType, p.strictTyp); err != nil {
+			return nil, err
 		}
-		return []byte(tm.secret), nil
-	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithLeeway(DefaultLeeway))
-	if err != nil || !token.Valid {
-		return nil, ErrInvalidToken
+		return tm.verificationKey(key), nil
+	})
+	if err != nil {
+		// WithValidMethods rejects a mismatched alg before the keyfunc runs;
+		// report it as an algorithm error rather than a bad signature.
+		if token != nil && checkAlgHeader(token, alg) != nil {
+			return nil, nil, ErrUnexpectedAlgorithm
+		}
+		return nil, nil, classifyParseError(err)
+	}
+	if !token.Valid {
+		return nil, nil, ErrInvalidToken
 	}
 
 	claims, ok := token.Claims.(*TokenClaims)
 	if !ok {
-		return nil, ErrInvalidToken
+		return nil, nil, ErrMalformedToken
 	}
 
-	now := time.Now()
-	if err := validateClaims(claims, tm.issuer, tm.audience, expectedType, now); err != nil {
-		return nil, ErrInvalidToken
+	now := tm.clock.Now()
+	if err := validateClaims(claims, tm.issuer, p.audience, expectedType, now); err != nil {
+		return nil, nil, err
+	}
+	if err := tm.checkSessionLifetime(claims, now); err != nil {
+		return nil, nil, err
 	}
 
-	return claims, nil
+	return token, claims, nil
 }
 
 func (tm *TokenMaker) RevokeAccessToken(ctx context.Context, tokenString string) error {
 	if tm.repo == nil {
-		return fmt.Errorf("revocation not enabled")
+		return ErrRevocationDisabled
+	}
+	if len(tokenString) > tm.policy().maxTokenLength {
+		return ErrTokenTooLarge
+	}
+	// An opaque token is revoked by deleting the claims it refers to.
+	if tm.opaque != nil {
+		return tm.opaque.DeleteOpaqueToken(ctx, AccessToken, HashToken(tokenString))
+	}
+
+	signed, err := tm.resolve(ctx, AccessToken, tokenString)
+	if err != nil {
+		return err
 	}
 
 	// Parse token without claims validation to allow revocation of expired tokens.
 	// Signature and algorithm are still verified; issuer/audience/type are checked manually below.
-	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
+	p := tm.policy()
+	alg := p.accessKey.method.Alg()
+	token, err := p.revokeParser.ParseWithClaims(signed, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if err := checkAlgHeader(token, alg); err != nil {
+			return nil, err
+		}
+		if err := checkTypHeader(token, AccessToken, p.strictTyp); err != nil {
+			return nil, err
 		}
-		return []byte(tm.secret), nil
-	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithoutClaimsValidation())
-	if err != nil || !token.Valid {
+		return tm.verificationKey(p.accessKey), nil
+	})
+	if err != nil {
+		if token != nil && checkAlgHeader(token, alg) != nil {
+			return ErrUnexpectedAlgorithm
+		}
+		return classifyParseError(err)
+	}
+	if !token.Valid {
 		return ErrInvalidToken
 	}
 
 	claims, ok := token.Claims.(*TokenClaims)
 	if !ok {
-		return ErrInvalidToken
+		return ErrMalformedToken
 	}
 
 	// Validate issuer and audience (but not time)
-	if claims.Issuer != tm.issuer {
-		return ErrInvalidToken
+	if NormalizeIssuer(claims.Issuer) != tm.issuer {
+		return ErrInvalidIssuer
 	}
 
 	validAudience := false
 	for _, aud := range claims.Audience {
-		if aud == tm.audience {
+		if aud == p.audience {
 			validAudience = true
 			break
 		}
 	}
 	if !validAudience {
-		return ErrInvalidToken
+		return ErrInvalidAudience
 	}
 
 	if claims.TokenType != AccessToken {
-		return ErrInvalidToken
+		return ErrWrongTokenType
 	}
 
 	if claims.ExpiresAt == nil {
-		return ErrInvalidToken
+		return ErrMissingClaims
 	}
 
 	// Use the token's expiry time, capped at a minimum to prevent replay attacks
-	ttl := time.Until(claims.ExpiresAt.Time)
+	ttl := claims.ExpiresAt.Sub(tm.clock.Now())
 	if ttl < time.Minute {
 		ttl = time.Minute
 	}
 
-	return tm.repo.MarkTokenRevoke(ctx, AccessToken, tokenString, ttl)
+	key, err := tm.revocationKey(tokenString, claims)
+	if err != nil {
+		return err
+	}
+	rctx, cancel := tm.repoContext(ctx)
+	defer cancel()
+	if err := tm.repo.MarkTokenRevoke(rctx, AccessToken, key, ttl); err != nil {
+		return err
+	}
+	tm.stats.recordRevoked(AccessToken)
+	return nil
 }
 
 func (tm *TokenMaker) RotateRefreshToken(ctx context.Context, oldToken string) (*TokenResponse, error) {
-	oldClaims, err := tm.VerifyRefreshToken(ctx, oldToken)
+	// An atomic rotation reports an already-rotated token itself, so the
+	// separate rotation marker lookup would only add a round trip.
+	_, atomicRotation := tm.repo.(AtomicRotationRepository)
+	_, oldClaims, err := tm.verifyWith(ctx, oldToken, RefreshToken, !atomicRotation, true)
+	if errors.Is(err, ErrTokenRotated) {
+		err = tm.replayDetected(ctx, oldToken)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("verify old token: %w", err)
 	}
+	// Checked before the old token is spent, so a throttled client can retry
+	// with it.
+	if err := tm.allowIssue(ctx, oldClaims.Subject); err != nil {
+		return nil, err
+	}
 
 	if tm.repo != nil && oldClaims.ExpiresAt != nil {
-		ttl := time.Until(oldClaims.ExpiresAt.Time)
-		if ttl > 0 {
-			if err := tm.repo.MarkTokenRevoke(ctx, RefreshToken, oldToken, ttl); err != nil {
+		key, err := tm.revocationKey(oldToken, oldClaims)
+		if err != nil {
+			return nil, fmt.Errorf("verify old token: %w", err)
+		}
+		ttl := oldClaims.ExpiresAt.Sub(tm.clock.Now())
+		if atomic, ok := tm.repo.(AtomicRotationRepository); ok {
+			// The token is still accepted for DefaultLeeway after exp, and this
+			// call is the only replay check, so the marker must outlive it.
+			if ttl < DefaultLeeway {
+				ttl = DefaultLeeway
+			}
+			rctx, cancel := tm.repoContext(ctx)
+			rotated, err := atomic.MarkTokenRotated(rctx, rotationKey(key), ttl)
+			cancel()
+			if err != nil {
+				return nil, fmt.Errorf("revoke old token: %w", err)
+			}
+			if !rotated {
+				return nil, fmt.Errorf("verify old token: %w", tm.replayDetected(ctx, oldToken))
+			}
+		} else if ttl > 0 {
+			rctx, cancel := tm.repoContext(ctx)
+			err := tm.repo.MarkTokenRevoke(rctx, RefreshToken, rotationKey(key), ttl)
+			cancel()
+			if err != nil {
 				return nil, fmt.Errorf("revoke old token: %w", err)
 			}
 		}
 	}
 
-	return tm.CreateRefreshToken(ctx, oldClaims.Subject, oldClaims.Username, oldClaims.Roles, oldClaims.SessionID)
+	// Tokens issued before family tracking start a family on first rotation.
+	familyID := oldClaims.FamilyID
+	if familyID == uuid.Nil {
+		familyID = uuid.New()
+	}
+	now := tm.clock.Now()
+	resp, err := tm.createRefreshToken(ctx, TokenClaims{
+		Subject:      oldClaims.Subject,
+		SessionID:    oldClaims.SessionID,
+		Username:     oldClaims.Username,
+		Roles:        oldClaims.Roles,
+		AuthTime:     jwt.NewNumericDate(sessionAuthTime(oldClaims)),
+		RememberMe:   oldClaims.RememberMe,
+		FamilyID:     familyID,
+		DeviceID:     oldClaims.DeviceID,
+		Confirmation: oldClaims.Confirmation,
+	}, now)
+	if err != nil {
+		return nil, err
+	}
+	tm.stats.recordRotated()
+	// The old token is already spent, so failing here would sign the user
+	// out; session metadata is best effort.
+	_ = tm.touchSession(ctx, oldClaims.Subject, oldClaims.SessionID, now, resp.ExpiresAt.Sub(now))
+	return resp, nil
 }
 
-// Note: This JWT package does not spawn any background goroutines.
-// All operations (CreateAccessToken, CreateRefreshToken, VerifyAccessToken, etc.) are synchronous.
-// No shutdown hooks are needed for cleanup.
+// Note: All operations (CreateAccessToken, CreateRefreshToken, VerifyAccessToken, etc.) are synchronous.
+// The only background goroutine is the opt-in cleanup loop (WithCleanupInterval, WithCleanupSchedule); stop it with Close.
 
 // validateClaims performs common claim validation for both TokenMaker and Verifier.
-// It returns ErrInvalidToken for any failure to prevent information leakage.
+// Every failure wraps ErrInvalidToken; see errors.go.
 func validateClaims(claims *TokenClaims, issuer, audience string, expectedType TokenType, now time.Time) error {
-	if claims.Issuer != issuer {
-		return ErrInvalidToken
+	if NormalizeIssuer(claims.Issuer) != issuer {
+		return ErrInvalidIssuer
 	}
 
 	validAudience := false
@@ -323,21 +769,21 @@ func validateClaims(claims *TokenClaims, issuer, audience string, expectedType T
 		}
 	}
 	if !validAudience {
-		return ErrInvalidToken
+		return ErrInvalidAudience
 	}
 
 	if claims.TokenType != expectedType {
-		return ErrInvalidToken
+		return ErrWrongTokenType
 	}
 
 	if claims.NotBefore == nil || claims.ExpiresAt == nil {
-		return ErrInvalidToken
+		return ErrMissingClaims
 	}
 	if now.Before(claims.NotBefore.Add(-DefaultLeeway)) {
-		return ErrInvalidToken
+		return ErrTokenNotYetValid
 	}
 	if now.After(claims.ExpiresAt.Add(DefaultLeeway)) {
-		return ErrInvalidToken
+		return ErrTokenExpired
 	}
 
 	return nil
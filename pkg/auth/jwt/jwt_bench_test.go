@@ -0,0 +1,130 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// benchAlgorithms returns the access token key of every supported
+// algorithm. HMAC algorithms use Config.Secret.
+func benchAlgorithms(b *testing.B) map[string]*TokenKey {
+	b.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		b.Fatalf("generate RSA key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		b.Fatalf("generate Ed25519 key: %v", err)
+	}
+	algs := map[string]*TokenKey{
+		"HS256": nil,
+		"HS384": nil,
+		"HS512": nil,
+		"RS256": {Algorithm: "RS256", Key: rsaKey},
+		"PS256": {Algorithm: "PS256", Key: rsaKey},
+		"EdDSA": {Algorithm: "EdDSA", Key: edKey},
+	}
+	for alg, curve := range map[string]elliptic.Curve{"ES256": elliptic.P256(), "ES384": elliptic.P384(), "ES512": elliptic.P521()} {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			b.Fatalf("generate %s key: %v", alg, err)
+		}
+		algs[alg] = &TokenKey{Algorithm: alg, Key: key}
+	}
+	return algs
+}
+
+// newBenchMaker returns a maker signing access tokens with alg, without a
+// revocation repository so only signing and parsing are measured.
+func newBenchMaker(b *testing.B, alg string, key *TokenKey) *TokenMaker {
+	b.Helper()
+	cfg := Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}
+	var opts []Option
+	if key == nil {
+		cfg.AccessAlgorithm = alg
+	} else {
+		opts = append(opts, WithTokenKey(AccessToken, *key))
+	}
+	maker, err := NewTokenMaker(cfg, nil, opts...)
+	if err != nil {
+		b.Fatalf("create %s token maker: %v", alg, err)
+	}
+	return maker
+}
+
+func BenchmarkCreateAccessToken(b *testing.B) {
+	ctx := context.Background()
+	userID, sessionID := uuid.New(), uuid.New()
+	for alg, key := range benchAlgorithms(b) {
+		b.Run(alg, func(b *testing.B) {
+			maker := newBenchMaker(b, alg, key)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := maker.CreateAccessToken(ctx, userID, "alice", []string{"user"}, sessionID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkVerifyAccessToken(b *testing.B) {
+	ctx := context.Background()
+	for alg, key := range benchAlgorithms(b) {
+		b.Run(alg, func(b *testing.B) {
+			maker := newBenchMaker(b, alg, key)
+			token, err := maker.CreateAccessToken(ctx, uuid.New(), "alice", []string{"user"}, uuid.New())
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := maker.VerifyAccessToken(ctx, token.Token); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkVerifyAccessToken_Revocation measures the revocation lookup
+// added to verification; repository packages benchmark their own backends.
+func BenchmarkVerifyAccessToken_Revocation(b *testing.B) {
+	ctx := context.Background()
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, newMockRevocationRepo())
+	if err != nil {
+		b.Fatal(err)
+	}
+	token, err := maker.CreateAccessToken(ctx, uuid.New(), "alice", []string{"user"}, uuid.New())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := maker.VerifyAccessToken(ctx, token.Token); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package jwt
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"testing"
 	"time"
 
@@ -28,6 +30,70 @@ func (r *mockRevocationRepo) IsTokenRevoked(_ context.Context, _ TokenType, toke
 	return ok, nil
 }
 
+// fakeClock is a manually advanced TimerClock for testing. Advance fires the
+// timers it passes; every After call is also sent on waiting, so a test can
+// wait for a background loop to block on its next timer.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []fakeTimer
+	waiting chan time.Duration
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1_700_000_000, 0), waiting: make(chan time.Duration, 16)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.fire()
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := fakeTimer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, timer)
+	c.fire()
+	select {
+	case c.waiting <- d:
+	default:
+	}
+	return timer.c
+}
+
+// pending returns the number of timers that have not fired.
+func (c *fakeClock) pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func (c *fakeClock) fire() {
+	timers := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			timers = append(timers, timer)
+			continue
+		}
+		timer.c <- c.now
+	}
+	c.timers = timers
+}
+
 // Test that validateClaims handles nil time fields without panicking.
 func TestValidateClaims_NilTimeFields(t *testing.T) {
 	claims := &TokenClaims{
@@ -67,7 +133,7 @@ func TestParseTokenWithoutTimeClaims(t *testing.T) {
 		"aud": []string{"test-audience"},
 		"typ": "access",
 	})
-	tokenString, err := token.SignedString([]byte(maker.secret))
+	tokenString, err := token.SignedString(maker.secret())
 	if err != nil {
 		t.Fatalf("sign token: %v", err)
 	}
@@ -79,29 +145,71 @@ func TestParseTokenWithoutTimeClaims(t *testing.T) {
 	}
 }
 
+// Test that the parser rejects tokens without a jti or typ through
+// TokenClaims.Validate.
+func TestParseTokenWithoutRequiredClaims(t *testing.T) {
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	now := clock.Now()
+	for _, missing := range []string{"jti", "typ"} {
+		claims := jwt.MapClaims{
+			"jti": uuid.New().String(),
+			"sub": uuid.New().String(),
+			"sid": uuid.New().String(),
+			"iss": "test-issuer",
+			"aud": []string{"test-audience"},
+			"typ": "access",
+			"iat": now.Unix(),
+			"nbf": now.Unix(),
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		delete(claims, missing)
+		tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(maker.secret())
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+		if _, err := maker.verifyToken(tokenString, AccessToken); !errors.Is(err, ErrMissingClaims) {
+			t.Errorf("without %s: expected ErrMissingClaims, got %v", missing, err)
+		}
+	}
+}
+
 // Test that RevokeAccessToken can revoke an expired token (the bug it previously
 // failed at because jwt.ParseWithClaims validated time by default).
 func TestRevokeAccessToken_ExpiredToken(t *testing.T) {
 	repo := newMockRevocationRepo()
+	clock := newFakeClock()
 	maker, err := NewTokenMaker(Config{
 		Secret:                "test-secret-must-be-at-least-32-bytes",
 		Issuer:                "test-issuer",
 		Audience:              "test-audience",
-		AccessExpiryDuration:  time.Millisecond,
+		AccessExpiryDuration:  time.Minute,
 		RefreshExpiryDuration: time.Hour,
-	}, repo)
+	}, repo, WithClock(clock))
 	if err != nil {
 		t.Fatalf("create token maker: %v", err)
 	}
 
-	// Create a token that expires almost immediately
+	// Create a token that expires after a minute
 	tokenResp, err := maker.CreateAccessToken(context.Background(), uuid.New(), "test", []string{"user"}, uuid.New())
 	if err != nil {
 		t.Fatalf("create access token: %v", err)
 	}
 
-	// Wait for the token to expire
-	time.Sleep(5 * time.Millisecond)
+	// Move past expiry and leeway
+	clock.Advance(time.Minute + 2*DefaultLeeway)
+	if _, err := maker.VerifyAccessToken(context.Background(), tokenResp.Token); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("expected token to be expired, got: %v", err)
+	}
 
 	// RevokeAccessToken should succeed even though the token is expired
 	err = maker.RevokeAccessToken(context.Background(), tokenResp.Token)
@@ -109,8 +217,8 @@ func TestRevokeAccessToken_ExpiredToken(t *testing.T) {
 		t.Fatalf("expected RevokeAccessToken to succeed for expired token, got: %v", err)
 	}
 
-	// Verify the token was actually revoked
-	revoked, err := repo.IsTokenRevoked(context.Background(), AccessToken, tokenResp.Token)
+	// Verify the token was actually revoked, under its hash
+	revoked, err := repo.IsTokenRevoked(context.Background(), AccessToken, HashToken(tokenResp.Token))
 	if err != nil {
 		t.Fatalf("IsTokenRevoked failed: %v", err)
 	}
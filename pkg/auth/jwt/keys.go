@@ -0,0 +1,171 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenKey signs and verifies one token type; see WithTokenKey.
+type TokenKey struct {
+	// Algorithm is HS256, HS384, HS512, ES256, ES384, ES512, RS256, RS384,
+	// RS512, PS256, PS384, PS512 or EdDSA.
+	Algorithm string
+	// ID, if set, is written to the kid header so verifiers can select the
+	// key from a JWKS.
+	ID string
+	// Key is a []byte secret for HMAC algorithms, and otherwise the private
+	// key: *ecdsa.PrivateKey on the algorithm's curve, *rsa.PrivateKey of
+	// at least 2048 bits, or ed25519.PrivateKey.
+	Key any
+}
+
+// WithTokenKey signs and verifies tokens of tokenType, AccessToken or
+// RefreshToken, with key instead of Config.Secret and the type's Config
+// algorithm. The two types can so be configured independently, e.g. ES256
+// access tokens that other services verify with PublicKey and a Verifier,
+// and HS512 refresh tokens only this maker can verify. Other token types
+// always use Config.Secret.
+func WithTokenKey(tokenType TokenType, key TokenKey) Option {
+	return func(o *makerOptions) {
+		if o.tokenKeys == nil {
+			o.tokenKeys = make(map[TokenType]TokenKey)
+		}
+		o.tokenKeys[tokenType] = key
+	}
+}
+
+// asymmetricMethods lists the asymmetric algorithms WithTokenKey accepts.
+var asymmetricMethods = map[string]jwt.SigningMethod{
+	"ES256": jwt.SigningMethodES256,
+	"ES384": jwt.SigningMethodES384,
+	"ES512": jwt.SigningMethodES512,
+	"RS256": jwt.SigningMethodRS256,
+	"RS384": jwt.SigningMethodRS384,
+	"RS512": jwt.SigningMethodRS512,
+	"PS256": jwt.SigningMethodPS256,
+	"PS384": jwt.SigningMethodPS384,
+	"PS512": jwt.SigningMethodPS512,
+	"EdDSA": jwt.SigningMethodEdDSA,
+}
+
+// tokenKey is the resolved signing configuration of a token type. A shared
+// key signs with the TokenMaker's secret, which ReloadSecret may replace.
+type tokenKey struct {
+	method jwt.SigningMethod
+	id     string
+	shared bool
+	sign   any
+	verify any
+}
+
+// secretKey returns the tokenKey signing with the shared secret.
+func secretKey(method *jwt.SigningMethodHMAC) tokenKey {
+	return tokenKey{method: method, shared: true}
+}
+
+// newTokenKey validates k and checks that its key fits its algorithm.
+func newTokenKey(k TokenKey) (tokenKey, error) {
+	if method, ok := symmetricMethods[k.Algorithm]; ok {
+		secret, ok := k.Key.([]byte)
+		if !ok || len(secret) == 0 {
+			return tokenKey{}, fmt.Errorf("%s requires a non-empty []byte key", k.Algorithm)
+		}
+		return tokenKey{method: method, id: k.ID, sign: secret, verify: secret}, nil
+	}
+	method, ok := asymmetricMethods[k.Algorithm]
+	if !ok {
+		return tokenKey{}, fmt.Errorf("algorithm %q is not supported", k.Algorithm)
+	}
+
+	var pub crypto.PublicKey
+	switch key := k.Key.(type) {
+	case *ecdsa.PrivateKey:
+		m, ok := method.(*jwt.SigningMethodECDSA)
+		if !ok || key.Curve.Params().BitSize != m.CurveBits {
+			return tokenKey{}, fmt.Errorf("%s does not match the %s key", k.Algorithm, key.Curve.Params().Name)
+		}
+		pub = &key.PublicKey
+	case *rsa.PrivateKey:
+		switch method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		default:
+			return tokenKey{}, fmt.Errorf("%s does not match an RSA key", k.Algorithm)
+		}
+		if key.N.BitLen() < 2048 {
+			return tokenKey{}, fmt.Errorf("RSA keys must be at least 2048 bits")
+		}
+		pub = &key.PublicKey
+	case ed25519.PrivateKey:
+		if method != jwt.SigningMethodEdDSA {
+			return tokenKey{}, fmt.Errorf("%s does not match an Ed25519 key", k.Algorithm)
+		}
+		pub = key.Public()
+	default:
+		return tokenKey{}, fmt.Errorf("%s does not accept a %T key", k.Algorithm, k.Key)
+	}
+	return tokenKey{method: method, id: k.ID, sign: k.Key, verify: pub}, nil
+}
+
+// newToken returns an unsigned token of claims with the typ header and, if
+// the key has an ID, the kid header set.
+func (k tokenKey) newToken(claims jwt.Claims, typ string) *jwt.Token {
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["typ"] = typ
+	if k.id != "" {
+		token.Header["kid"] = k.id
+	}
+	return token
+}
+
+// unverifiedParser reads claims without verifying them.
+var unverifiedParser = jwt.NewParser()
+
+// newParser returns a parser accepting only alg and checking times against
+// clock with DefaultLeeway, plus opts.
+func newParser(alg string, clock Clock, opts ...jwt.ParserOption) *jwt.Parser {
+	return jwt.NewParser(append([]jwt.ParserOption{
+		jwt.WithValidMethods([]string{alg}),
+		jwt.WithLeeway(DefaultLeeway),
+		jwt.WithTimeFunc(clock.Now),
+	}, opts...)...)
+}
+
+// signingKey returns the key k signs with.
+func (tm *TokenMaker) signingKey(k tokenKey) any {
+	if k.shared {
+		return tm.secret()
+	}
+	return k.sign
+}
+
+// verificationKey returns the key or key set k verifies with.
+func (tm *TokenMaker) verificationKey(k tokenKey) any {
+	if k.shared {
+		return tm.secretVerificationKey()
+	}
+	return k.verify
+}
+
+// keyFor returns the signing configuration of tokenType.
+func (tm *TokenMaker) keyFor(tokenType TokenType) tokenKey {
+	if tokenType == RefreshToken {
+		return tm.policy().refreshKey
+	}
+	return tm.policy().accessKey
+}
+
+// PublicKey returns the public key verifying tokens of tokenType, for a
+// Verifier's StaticKeyFunc or a JWKS, and false if the type is signed with
+// a shared secret.
+func (tm *TokenMaker) PublicKey(tokenType TokenType) (crypto.PublicKey, bool) {
+	k := tm.keyFor(tokenType)
+	if _, ok := k.verify.([]byte); ok || k.shared {
+		return nil, false
+	}
+	return k.verify, true
+}
@@ -0,0 +1,109 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWithTokenKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	cfg := Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: 24 * time.Hour,
+	}
+	maker, err := NewTokenMaker(cfg, newMockRevocationRepo(),
+		WithTokenKey(AccessToken, TokenKey{Algorithm: "ES256", ID: "access-1", Key: ecKey}),
+		WithTokenKey(RefreshToken, TokenKey{Algorithm: "HS512", Key: []byte("refresh-secret-must-be-at-least-32-bytes")}),
+	)
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := context.Background()
+	userID := uuid.New()
+	access, err := maker.CreateAccessToken(ctx, userID, "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	refresh, err := maker.CreateRefreshToken(ctx, userID, "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); err != nil {
+		t.Errorf("verify access token: %v", err)
+	}
+	if _, err := maker.VerifyRefreshToken(ctx, refresh.Token); err != nil {
+		t.Errorf("verify refresh token: %v", err)
+	}
+
+	pub, ok := maker.PublicKey(AccessToken)
+	if !ok {
+		t.Fatal("no public key for ES256 access tokens")
+	}
+	if _, ok := maker.PublicKey(RefreshToken); ok {
+		t.Error("public key returned for HMAC refresh tokens")
+	}
+	verifier, err := NewVerifier(VerifierConfig{
+		Issuer:     cfg.Issuer,
+		Audience:   cfg.Audience,
+		KeyFunc:    &StaticKeyFunc{Key: pub},
+		Algorithms: []string{"ES256"},
+	})
+	if err != nil {
+		t.Fatalf("create verifier: %v", err)
+	}
+	if claims, err := verifier.VerifyAccessToken(ctx, access.Token); err != nil || claims.Subject != userID {
+		t.Errorf("verifier rejected access token: %v", err)
+	}
+
+	// A token signed with the shared secret no longer verifies.
+	legacy, err := NewTokenMaker(cfg, newMockRevocationRepo())
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	hs, err := legacy.CreateAccessToken(ctx, userID, "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, hs.Token); !errors.Is(err, ErrUnexpectedAlgorithm) {
+		t.Errorf("HS256 access token: got %v, want %v", err, ErrUnexpectedAlgorithm)
+	}
+}
+
+func TestWithTokenKey_Invalid(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	cfg := Config{Secret: "test-secret-must-be-at-least-32-bytes", Issuer: "i", Audience: "a"}
+	for name, tt := range map[string]struct {
+		tokenType TokenType
+		key       TokenKey
+		want      string
+	}{
+		"curve mismatch": {AccessToken, TokenKey{Algorithm: "ES256", Key: ecKey}, "does not match"},
+		"secret for ES":  {AccessToken, TokenKey{Algorithm: "ES384", Key: []byte("secret")}, "does not accept"},
+		"empty secret":   {RefreshToken, TokenKey{Algorithm: "HS256"}, "non-empty"},
+		"unknown alg":    {AccessToken, TokenKey{Algorithm: "none", Key: ecKey}, "not supported"},
+		"other type":     {MFAChallenge, TokenKey{Algorithm: "ES384", Key: ecKey}, "cannot have their own key"},
+	} {
+		_, err := NewTokenMaker(cfg, nil, WithTokenKey(tt.tokenType, tt.key))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want error containing %q", name, err, tt.want)
+		}
+	}
+}
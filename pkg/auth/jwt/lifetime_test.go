@@ -0,0 +1,245 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newLifetimeTestMaker(t *testing.T, clock Clock, maxLifetime, idle time.Duration) *TokenMaker {
+	t.Helper()
+	maker, err := NewTokenMaker(Config{
+		Secret:                   "test-secret-must-be-at-least-32-bytes",
+		Issuer:                   "test-issuer",
+		Audience:                 "test-audience",
+		AccessExpiryDuration:     time.Hour,
+		RefreshExpiryDuration:    24 * time.Hour,
+		RefreshMaxLifetimeExpiry: maxLifetime,
+		RefreshIdleTimeout:       idle,
+	}, newMockRevocationRepo(), WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	return maker
+}
+
+func TestRotateRefreshToken_SlidingExpiry(t *testing.T) {
+	clock := newFakeClock()
+	maker := newLifetimeTestMaker(t, clock, 36*time.Hour, 0)
+	ctx := context.Background()
+	login := clock.Now()
+
+	refresh, err := maker.CreateRefreshToken(ctx, uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if want := login.Add(24 * time.Hour); !refresh.ExpiresAt.Equal(want) {
+		t.Errorf("expected first token to expire at %v, got %v", want, refresh.ExpiresAt)
+	}
+
+	// Rotating extends the session, but only up to the maximum lifetime.
+	clock.Advance(20 * time.Hour)
+	refresh, err = maker.RotateRefreshToken(ctx, refresh.Token)
+	if err != nil {
+		t.Fatalf("rotate refresh token: %v", err)
+	}
+	if want := login.Add(36 * time.Hour); !refresh.ExpiresAt.Equal(want) {
+		t.Errorf("expected rotated token to be capped at %v, got %v", want, refresh.ExpiresAt)
+	}
+
+	clock.Advance(17 * time.Hour)
+	if _, err := maker.RotateRefreshToken(ctx, refresh.Token); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expected ErrTokenExpired past the maximum lifetime, got %v", err)
+	}
+}
+
+func TestRotateRefreshToken_IdleTimeout(t *testing.T) {
+	clock := newFakeClock()
+	maker := newLifetimeTestMaker(t, clock, 0, 2*time.Hour)
+	ctx := context.Background()
+
+	refresh, err := maker.CreateRefreshToken(ctx, uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if want := clock.Now().Add(2 * time.Hour); !refresh.ExpiresAt.Equal(want) {
+		t.Errorf("expected token to expire after the idle timeout at %v, got %v", want, refresh.ExpiresAt)
+	}
+
+	// An active session keeps going past the idle timeout.
+	for range 3 {
+		clock.Advance(time.Hour)
+		refresh, err = maker.RotateRefreshToken(ctx, refresh.Token)
+		if err != nil {
+			t.Fatalf("rotate refresh token: %v", err)
+		}
+	}
+
+	clock.Advance(3 * time.Hour)
+	if _, err := maker.VerifyRefreshToken(ctx, refresh.Token); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expected ErrTokenExpired for an idle session, got %v", err)
+	}
+}
+
+func TestVerifyRefreshToken_LifetimeTightened(t *testing.T) {
+	clock := newFakeClock()
+	ctx := context.Background()
+	old := newLifetimeTestMaker(t, clock, 0, 0)
+	refresh, err := old.CreateRefreshToken(ctx, uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+
+	clock.Advance(3 * time.Hour)
+	for _, tc := range []struct {
+		name              string
+		maxLifetime, idle time.Duration
+	}{
+		{name: "idle", idle: 2 * time.Hour},
+		{name: "max lifetime", maxLifetime: 2 * time.Hour},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			maker := newLifetimeTestMaker(t, clock, tc.maxLifetime, tc.idle)
+			if _, err := maker.VerifyRefreshToken(ctx, refresh.Token); !errors.Is(err, ErrSessionExpired) {
+				t.Errorf("expected ErrSessionExpired, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCreateRefreshToken_RememberMe(t *testing.T) {
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:                      "test-secret-must-be-at-least-32-bytes",
+		Issuer:                      "test-issuer",
+		Audience:                    "test-audience",
+		AccessExpiryDuration:        time.Hour,
+		RefreshExpiryDuration:       24 * time.Hour,
+		RefreshIdleTimeout:          2 * time.Hour,
+		RememberMeExpiryDuration:    30 * 24 * time.Hour,
+		RememberMeMaxLifetimeExpiry: 90 * 24 * time.Hour,
+	}, newMockRevocationRepo(), WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+	login := clock.Now()
+
+	standard, err := maker.CreateRefreshToken(ctx, uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if want := login.Add(2 * time.Hour); !standard.ExpiresAt.Equal(want) {
+		t.Errorf("expected standard token to expire at %v, got %v", want, standard.ExpiresAt)
+	}
+
+	remember, err := maker.CreateRefreshToken(ctx, uuid.New(), "alice", nil, uuid.New(), WithRememberMe())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if want := login.Add(30 * 24 * time.Hour); !remember.ExpiresAt.Equal(want) {
+		t.Errorf("expected remember-me token to expire at %v, got %v", want, remember.ExpiresAt)
+	}
+
+	// The profile survives rotation, and the idle timeout does not apply.
+	clock.Advance(3 * 24 * time.Hour)
+	remember, err = maker.RotateRefreshToken(ctx, remember.Token)
+	if err != nil {
+		t.Fatalf("rotate refresh token: %v", err)
+	}
+	if want := clock.Now().Add(30 * 24 * time.Hour); !remember.ExpiresAt.Equal(want) {
+		t.Errorf("expected rotated remember-me token to expire at %v, got %v", want, remember.ExpiresAt)
+	}
+	if _, err := maker.RotateRefreshToken(ctx, standard.Token); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expected ErrTokenExpired for the idle standard session, got %v", err)
+	}
+}
+
+func TestCreateAccessToken_ExpiryPolicies(t *testing.T) {
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: 30 * time.Minute,
+		AccessExpiryPolicies: []ExpiryPolicy{
+			{Role: "admin", Expiry: 10 * time.Minute},
+			{ClientID: "kiosk", Expiry: 2 * time.Hour},
+			{Role: "auditor", ClientID: "cli", Expiry: 5 * time.Minute},
+		},
+	}, newMockRevocationRepo(), WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	kiosk := WithClientInfo(context.Background(), ClientInfo{ClientID: "kiosk"})
+	cli := WithClientInfo(context.Background(), ClientInfo{ClientID: "cli"})
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		roles []string
+		want  time.Duration
+	}{
+		{"default", context.Background(), []string{"user"}, 30 * time.Minute},
+		{"role", context.Background(), []string{"user", "admin"}, 10 * time.Minute},
+		{"client", kiosk, nil, 2 * time.Hour},
+		{"shortest match wins", kiosk, []string{"admin"}, 10 * time.Minute},
+		{"role and client", cli, []string{"auditor"}, 5 * time.Minute},
+		{"role without client", context.Background(), []string{"auditor"}, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		access, err := maker.CreateAccessToken(tt.ctx, uuid.New(), "alice", tt.roles, uuid.New())
+		if err != nil {
+			t.Fatalf("%s: create access token: %v", tt.name, err)
+		}
+		if want := clock.Now().Add(tt.want); !access.ExpiresAt.Equal(want) {
+			t.Errorf("%s: expected expiry %v, got %v", tt.name, want, access.ExpiresAt)
+		}
+	}
+	if maker.policy().maxAccessExpiry != 2*time.Hour {
+		t.Errorf("expected revocation records sized for 2h, got %v", maker.policy().maxAccessExpiry)
+	}
+}
+
+func TestCreateAccessToken_WithExpiry(t *testing.T) {
+	clock := newFakeClock()
+	newMaker := func(minOverride, maxOverride time.Duration) *TokenMaker {
+		maker, err := NewTokenMaker(Config{
+			Secret:               "test-secret-must-be-at-least-32-bytes",
+			Issuer:               "test-issuer",
+			Audience:             "test-audience",
+			AccessExpiryDuration: 30 * time.Minute,
+			AccessExpiryPolicies: []ExpiryPolicy{{Role: "admin", Expiry: 10 * time.Minute}},
+			MinExpiryOverride:    minOverride,
+			MaxExpiryOverride:    maxOverride,
+		}, newMockRevocationRepo(), WithClock(clock))
+		if err != nil {
+			t.Fatalf("create token maker: %v", err)
+		}
+		return maker
+	}
+	maker := newMaker(time.Minute, 8*time.Hour)
+
+	for _, expiry := range []time.Duration{2 * time.Minute, 8 * time.Hour} {
+		access, err := maker.CreateAccessToken(WithExpiry(context.Background(), expiry), uuid.New(), "alice", []string{"admin"}, uuid.New())
+		if err != nil {
+			t.Fatalf("create access token for %v: %v", expiry, err)
+		}
+		if want := clock.Now().Add(expiry); !access.ExpiresAt.Equal(want) {
+			t.Errorf("expected override to expire at %v, got %v", want, access.ExpiresAt)
+		}
+	}
+	for _, expiry := range []time.Duration{30 * time.Second, 9 * time.Hour, 0} {
+		if _, err := maker.CreateAccessToken(WithExpiry(context.Background(), expiry), uuid.New(), "alice", nil, uuid.New()); !errors.Is(err, ErrExpiryOutOfBounds) {
+			t.Errorf("expiry %v: expected ErrExpiryOutOfBounds, got %v", expiry, err)
+		}
+	}
+
+	disabled := newMaker(0, 0)
+	if _, err := disabled.CreateAccessToken(WithExpiry(context.Background(), time.Minute), uuid.New(), "alice", nil, uuid.New()); !errors.Is(err, ErrExpiryOutOfBounds) {
+		t.Errorf("expected overrides to be rejected without bounds, got %v", err)
+	}
+}
@@ -0,0 +1,320 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// Lockout defaults.
+const (
+	DefaultLockoutThreshold = 10
+	DefaultLockoutWindow    = 15 * time.Minute
+	DefaultLockoutDuration  = 15 * time.Minute
+)
+
+// FailureStore tracks consecutive verification failures and lockouts per
+// key. Keys are "ip:<address>" for clients whose ClientInfo is attached to
+// the context and "sub:<user id>" for the subjects of authentic tokens.
+// Implementations must be safe for concurrent use; share one
+// between instances to enforce lockouts across them.
+type FailureStore interface {
+	// RecordFailure counts one more failure of key and returns the
+	// consecutive failures so far. The count restarts when the previous
+	// failure is older than window.
+	RecordFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	// ResetFailures clears key's failures after a successful verification.
+	// It runs on every success, so it must be cheap.
+	ResetFailures(ctx context.Context, key string) error
+	// Lock rejects key until until and restarts its failure count, so the
+	// key is locked out again after Threshold more failures.
+	Lock(ctx context.Context, key string, until time.Time) error
+	// LockedUntil returns when key's lockout ends, or the zero time.
+	LockedUntil(ctx context.Context, key string) (time.Time, error)
+}
+
+// LockoutPolicy configures WithLockout. Zero values select the defaults.
+type LockoutPolicy struct {
+	// Threshold consecutive failures within Window lock the key out for
+	// Duration.
+	Threshold int
+	Window    time.Duration
+	Duration  time.Duration
+	// DisableAutoLock only reports lockouts to OnLockout, leaving the
+	// decision to the host.
+	DisableAutoLock bool
+	// LockSubjects locks out subjects too, rejecting their tokens from any
+	// client. Subject lockouts are otherwise only reported to OnLockout,
+	// since anyone replaying a stolen, revoked token could lock its owner
+	// out.
+	LockSubjects bool
+	// OnLockout, if set, is called when a key reaches Threshold.
+	OnLockout func(ctx context.Context, ev LockoutEvent)
+}
+
+// LockoutEvent describes a key reaching the lockout threshold.
+type LockoutEvent struct {
+	Key string
+	// UserID is set for subject lockouts, IP for client lockouts and for
+	// subject lockouts whose last failure came with a client IP.
+	UserID   uuid.UUID
+	IP       string
+	Failures int
+	// Until is when the lockout ends, zero with DisableAutoLock.
+	Until time.Time
+	Err   error
+}
+
+// WithLockout tracks consecutive verification failures per client IP and
+// per subject in store and, by default, rejects further verifications from
+// an IP that reached policy.Threshold with ErrLockedOut for
+// policy.Duration.
+//
+// Client IPs count only failures a legitimate client does not cause: bad
+// signatures, unexpected algorithms and malformed tokens, which suggest
+// guessed or forged tokens. Subjects count failures of authentic tokens
+// that were revoked, rotated or invalidated, which suggest a stolen token
+// being replayed; they are locked out only with policy.LockSubjects.
+// Expired tokens count for neither, and a successful verification resets
+// both counts. The subject is only known once the signature is verified, so
+// with CheckRepositoryFirst tokens rejected as revoked before that do not
+// count. Store errors are logged and never block verification.
+//
+// The client IP is read from the ClientInfo attached to the context with
+// WithClientInfo; the httpauth middlewares attach it for every request.
+// Verifications without one are not tracked by IP.
+func WithLockout(store FailureStore, policy LockoutPolicy) Option {
+	return func(o *makerOptions) {
+		if policy.Threshold <= 0 {
+			policy.Threshold = DefaultLockoutThreshold
+		}
+		if policy.Window <= 0 {
+			policy.Window = DefaultLockoutWindow
+		}
+		if policy.Duration <= 0 {
+			policy.Duration = DefaultLockoutDuration
+		}
+		o.lockout = &lockout{store: store, policy: policy}
+	}
+}
+
+// lockout is the failure tracking configured by WithLockout.
+type lockout struct {
+	store  FailureStore
+	policy LockoutPolicy
+}
+
+func ipKey(ip string) string             { return "ip:" + ip }
+func subjectKey(userID uuid.UUID) string { return "sub:" + userID.String() }
+
+// clientIP returns the IP of the ClientInfo attached to ctx, if any.
+func clientIP(ctx context.Context) string {
+	info, _ := ClientInfoFrom(ctx)
+	return info.IP
+}
+
+// checkLocked returns ErrLockedOut if key is locked out.
+func (l *lockout) checkLocked(ctx context.Context, key string, now time.Time) error {
+	if l == nil {
+		return nil
+	}
+	until, err := l.store.LockedUntil(ctx, key)
+	if err != nil {
+		logx.WithContext(ctx).Errorf("jwt: check lockout: %v", err)
+		return nil
+	}
+	if now.Before(until) {
+		return ErrLockedOut
+	}
+	return nil
+}
+
+// recordFailure counts a failed verification against key and reports or
+// locks it out once it reaches the threshold. ev describes the key.
+func (l *lockout) recordFailure(ctx context.Context, key string, ev LockoutEvent, lock bool, now time.Time) {
+	n, err := l.store.RecordFailure(ctx, key, l.policy.Window)
+	if err != nil {
+		logx.WithContext(ctx).Errorf("jwt: record verification failure: %v", err)
+		return
+	}
+	if n != l.policy.Threshold {
+		return
+	}
+	ev.Key, ev.Failures = key, n
+	if lock {
+		ev.Until = now.Add(l.policy.Duration)
+		if err := l.store.Lock(ctx, key, ev.Until); err != nil {
+			logx.WithContext(ctx).Errorf("jwt: lock out %s: %v", key, err)
+		}
+	}
+	if l.policy.OnLockout != nil {
+		l.policy.OnLockout(ctx, ev)
+	}
+}
+
+// recordSuccess resets the consecutive failures of key.
+func (l *lockout) recordSuccess(ctx context.Context, key string) {
+	if err := l.store.ResetFailures(ctx, key); err != nil {
+		logx.WithContext(ctx).Errorf("jwt: reset verification failures: %v", err)
+	}
+}
+
+// lockSubjects reports whether subjects reaching the threshold are locked
+// out rather than only reported.
+func (l *lockout) lockSubjects() bool {
+	return l.policy.LockSubjects && !l.policy.DisableAutoLock
+}
+
+// recordLockout updates the failure tracking of the client IP and, once the
+// token is known to be authentic, its subject with the outcome of a
+// verification. claims is nil unless the signature checked out. See
+// WithLockout for the failures each key counts.
+func (tm *TokenMaker) recordLockout(ctx context.Context, claims *TokenClaims, err error) {
+	if tm.lockout == nil {
+		return
+	}
+	ip := clientIP(ctx)
+	var userID uuid.UUID
+	if claims != nil {
+		userID = claims.Subject
+	}
+	now := tm.clock.Now()
+	switch {
+	case err == nil:
+		if ip != "" {
+			tm.lockout.recordSuccess(ctx, ipKey(ip))
+		}
+		if userID != uuid.Nil {
+			tm.lockout.recordSuccess(ctx, subjectKey(userID))
+		}
+	case isForgeryFailure(err):
+		if ip != "" {
+			ev := LockoutEvent{IP: ip, Err: err}
+			tm.lockout.recordFailure(ctx, ipKey(ip), ev, !tm.lockout.policy.DisableAutoLock, now)
+		}
+	case isReplayFailure(err):
+		if userID != uuid.Nil {
+			ev := LockoutEvent{UserID: userID, IP: ip, Err: err}
+			tm.lockout.recordFailure(ctx, subjectKey(userID), ev, tm.lockout.lockSubjects(), now)
+		}
+	}
+}
+
+// isForgeryFailure reports whether err means the token was not issued by
+// this maker, as opposed to an authentic token that is no longer valid.
+func isForgeryFailure(err error) bool {
+	return errors.Is(err, ErrInvalidSignature) ||
+		errors.Is(err, ErrUnexpectedAlgorithm) ||
+		errors.Is(err, ErrMalformedToken)
+}
+
+// isReplayFailure reports whether err means an authentic token was used
+// after it was revoked, rotated or invalidated.
+func isReplayFailure(err error) bool {
+	return errors.Is(err, ErrTokenRevoked) ||
+		errors.Is(err, ErrTokenRotated) ||
+		errors.Is(err, ErrSessionRevoked) ||
+		errors.Is(err, ErrFamilyRevoked) ||
+		errors.Is(err, ErrTokenInvalidated)
+}
+
+// MemoryFailureStore is an in-process FailureStore for single-instance
+// deployments. Entries of keys that neither failed nor were locked out
+// recently are dropped as the store grows. It reads the time from the clock
+// of the TokenMaker it is first configured on with WithLockout, or the
+// system clock until then.
+type MemoryFailureStore struct {
+	mu      sync.Mutex
+	clock   Clock
+	entries map[string]failureEntry
+}
+
+type failureEntry struct {
+	count       int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// NewMemoryFailureStore returns an empty MemoryFailureStore.
+func NewMemoryFailureStore() *MemoryFailureStore {
+	return &MemoryFailureStore{entries: make(map[string]failureEntry)}
+}
+
+// useClock sets the store's clock unless it already has one.
+func (s *MemoryFailureStore) useClock(c Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clock == nil {
+		s.clock = c
+	}
+}
+
+// now returns the current time of the store's clock. s.mu must be held.
+func (s *MemoryFailureStore) now() time.Time {
+	if s.clock == nil {
+		return SystemClock{}.Now()
+	}
+	return s.clock.Now()
+}
+
+func (s *MemoryFailureStore) RecordFailure(_ context.Context, key string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	e, ok := s.entries[key]
+	if !ok && len(s.entries) >= maxTrackedFailures {
+		s.sweep(now, window)
+	}
+	if now.Sub(e.lastFailure) >= window {
+		e.count = 0
+	}
+	e.count++
+	e.lastFailure = now
+	s.entries[key] = e
+	return e.count, nil
+}
+
+func (s *MemoryFailureStore) ResetFailures(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if e.lockedUntil.IsZero() {
+		delete(s.entries, key)
+		return nil
+	}
+	e.count = 0
+	s.entries[key] = e
+	return nil
+}
+
+func (s *MemoryFailureStore) Lock(_ context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entries[key]
+	e.count = 0
+	e.lockedUntil = until
+	s.entries[key] = e
+	return nil
+}
+
+func (s *MemoryFailureStore) LockedUntil(_ context.Context, key string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[key].lockedUntil, nil
+}
+
+// sweep drops entries whose failure window and lockout are over.
+func (s *MemoryFailureStore) sweep(now time.Time, window time.Duration) {
+	for key, e := range s.entries {
+		if now.Sub(e.lastFailure) >= window && !now.Before(e.lockedUntil) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWithLockout(t *testing.T) {
+	var events []LockoutEvent
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, newMockRevocationRepo(), WithLockout(NewMemoryFailureStore(), LockoutPolicy{
+		Threshold: 3,
+		OnLockout: func(_ context.Context, ev LockoutEvent) { events = append(events, ev) },
+	}))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := WithClientInfo(context.Background(), ClientInfo{IP: "203.0.113.7"})
+	other := WithClientInfo(context.Background(), ClientInfo{IP: "198.51.100.1"})
+	access, err := maker.CreateAccessToken(ctx, uuid.New(), "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	// A success in between restarts the count.
+	for _, token := range []string{"guess-1", "guess-2", access.Token, "guess-3", "guess-4"} {
+		_, _ = maker.VerifyAccessToken(ctx, token)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no lockout after a success, got %+v", events)
+	}
+
+	if _, err := maker.VerifyAccessToken(ctx, "guess-5"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected invalid token, got %v", err)
+	}
+	if len(events) != 1 || events[0].Key != "ip:203.0.113.7" || events[0].Until.IsZero() {
+		t.Fatalf("expected the IP to be locked out, got %+v", events)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); !errors.Is(err, ErrLockedOut) {
+		t.Errorf("expected ErrLockedOut from the locked IP, got %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(other, access.Token); err != nil {
+		t.Errorf("expected other clients to be unaffected, got %v", err)
+	}
+}
+
+func TestWithLockout_RevokedTokenReplay(t *testing.T) {
+	var events []LockoutEvent
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, newMockRevocationRepo(), WithClock(clock), WithLockout(NewMemoryFailureStore(), LockoutPolicy{
+		Threshold: 2,
+		OnLockout: func(_ context.Context, ev LockoutEvent) { events = append(events, ev) },
+	}))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := WithClientInfo(context.Background(), ClientInfo{IP: "203.0.113.7"})
+	userID := uuid.New()
+	revoked, err := maker.CreateAccessToken(ctx, userID, "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	expired, err := maker.CreateAccessToken(ctx, userID, "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if err := maker.RevokeAccessToken(ctx, revoked.Token); err != nil {
+		t.Fatalf("revoke access token: %v", err)
+	}
+
+	// Replaying a revoked token is not a forgery: it is reported against
+	// the subject but locks neither the user nor their client out.
+	for range 3 {
+		if _, err := maker.VerifyAccessToken(ctx, revoked.Token); !errors.Is(err, ErrTokenRevoked) {
+			t.Fatalf("expected ErrTokenRevoked, got %v", err)
+		}
+	}
+	clock.Advance(2 * time.Hour)
+	for range 3 {
+		if _, err := maker.VerifyAccessToken(ctx, expired.Token); !errors.Is(err, ErrTokenExpired) {
+			t.Fatalf("expected ErrTokenExpired, got %v", err)
+		}
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected one subject report, got %+v", events)
+	}
+	if ev := events[0]; ev.Key != "sub:"+userID.String() || ev.UserID != userID ||
+		ev.IP != "203.0.113.7" || ev.Failures != 2 || !ev.Until.IsZero() || !errors.Is(ev.Err, ErrTokenRevoked) {
+		t.Errorf("expected a report-only subject event, got %+v", ev)
+	}
+
+	fresh, err := maker.CreateAccessToken(ctx, userID, "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, fresh.Token); err != nil {
+		t.Errorf("expected the subject not to be locked out, got %v", err)
+	}
+}
+
+func TestWithLockout_ForgedSignature(t *testing.T) {
+	var events []LockoutEvent
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, newMockRevocationRepo(), WithLockout(NewMemoryFailureStore(), LockoutPolicy{
+		Threshold: 2,
+		OnLockout: func(_ context.Context, ev LockoutEvent) { events = append(events, ev) },
+	}))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := WithClientInfo(context.Background(), ClientInfo{IP: "203.0.113.7"})
+	access, err := maker.CreateAccessToken(ctx, uuid.New(), "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	forged := access.Token[:len(access.Token)-4] + "AAAA"
+	for range 2 {
+		if _, err := maker.VerifyAccessToken(ctx, forged); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("expected ErrInvalidSignature, got %v", err)
+		}
+	}
+	if len(events) != 1 || events[0].Key != "ip:203.0.113.7" {
+		t.Fatalf("expected the IP to be locked out, got %+v", events)
+	}
+}
+
+func TestWithLockout_LockSubjects(t *testing.T) {
+	var events []LockoutEvent
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, newMockRevocationRepo(), WithLockout(NewMemoryFailureStore(), LockoutPolicy{
+		Threshold:    2,
+		LockSubjects: true,
+		OnLockout:    func(_ context.Context, ev LockoutEvent) { events = append(events, ev) },
+	}))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := WithClientInfo(context.Background(), ClientInfo{IP: "203.0.113.7"})
+	other := WithClientInfo(context.Background(), ClientInfo{IP: "198.51.100.1"})
+	userID := uuid.New()
+	revoked, err := maker.CreateAccessToken(ctx, userID, "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	fresh, err := maker.CreateAccessToken(ctx, userID, "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	bystander, err := maker.CreateAccessToken(ctx, uuid.New(), "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if err := maker.RevokeAccessToken(ctx, revoked.Token); err != nil {
+		t.Fatalf("revoke access token: %v", err)
+	}
+
+	// A success in between restarts the count.
+	for _, token := range []string{revoked.Token, fresh.Token, revoked.Token} {
+		_, _ = maker.VerifyAccessToken(ctx, token)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no lockout after a success, got %+v", events)
+	}
+
+	if _, err := maker.VerifyAccessToken(ctx, revoked.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected ErrTokenRevoked, got %v", err)
+	}
+	if len(events) != 1 || events[0].UserID != userID || events[0].Until.IsZero() {
+		t.Fatalf("expected the subject to be locked out, got %+v", events)
+	}
+	if _, err := maker.VerifyAccessToken(other, fresh.Token); !errors.Is(err, ErrLockedOut) {
+		t.Errorf("expected ErrLockedOut for the subject from any client, got %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, bystander.Token); err != nil {
+		t.Errorf("expected other subjects on the client to be unaffected, got %v", err)
+	}
+}
+
+func TestWithLockout_Expiry(t *testing.T) {
+	var events []LockoutEvent
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:                   "test-secret-must-be-at-least-32-bytes",
+		Issuer:                   "test-issuer",
+		Audience:                 "test-audience",
+		AccessExpiryDuration:     24 * time.Hour,
+		DisableBackgroundCleanup: true,
+	}, newMockRevocationRepo(), WithClock(clock), WithLockout(NewMemoryFailureStore(), LockoutPolicy{
+		Threshold: 2,
+		Window:    time.Minute,
+		Duration:  time.Hour,
+		OnLockout: func(_ context.Context, ev LockoutEvent) { events = append(events, ev) },
+	}))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := WithClientInfo(context.Background(), ClientInfo{IP: "203.0.113.7"})
+	access, err := maker.CreateAccessToken(ctx, uuid.New(), "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	// Failures further apart than the window do not add up.
+	_, _ = maker.VerifyAccessToken(ctx, "guess-1")
+	clock.Advance(time.Minute)
+	_, _ = maker.VerifyAccessToken(ctx, "guess-2")
+	if len(events) != 0 {
+		t.Fatalf("expected no lockout across windows, got %+v", events)
+	}
+
+	_, _ = maker.VerifyAccessToken(ctx, "guess-3")
+	if len(events) != 1 || !events[0].Until.Equal(clock.Now().Add(time.Hour)) {
+		t.Fatalf("expected a lockout for the policy duration, got %+v", events)
+	}
+	clock.Advance(time.Hour - time.Second)
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); !errors.Is(err, ErrLockedOut) {
+		t.Errorf("expected ErrLockedOut before the lockout ends, got %v", err)
+	}
+	clock.Advance(time.Second)
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); err != nil {
+		t.Errorf("expected the lockout to end, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// MFAChallenge is the token type of MFA challenge tokens.
+const MFAChallenge TokenType = "mfa_challenge"
+
+// MFAChallengeTyp is the typ header of MFA challenge tokens.
+const MFAChallengeTyp = "mfa+jwt"
+
+// DefaultMFAChallengeExpiry is the lifetime of MFA challenge tokens when
+// Config.MFAChallengeExpiryDuration is zero.
+const DefaultMFAChallengeExpiry = 5 * time.Minute
+
+// mfaChallengeAudienceSuffix is appended to the configured audience, so
+// challenge tokens are rejected by every verifier of access tokens.
+const mfaChallengeAudienceSuffix = ":mfa"
+
+// mfaConsumedPrefix namespaces completed challenges among the refresh token
+// revocations of the repository.
+const mfaConsumedPrefix = "mfa:"
+
+// CreateMFAChallenge issues a challenge token after userID passed primary
+// authentication but still has to present a second factor. It carries no
+// roles and is only accepted by VerifyMFAChallenge and CompleteMFAChallenge,
+// never as an access token.
+func (tm *TokenMaker) CreateMFAChallenge(ctx context.Context, userID uuid.UUID, username string) (*TokenResponse, error) {
+	if err := tm.allowIssue(ctx, userID); err != nil {
+		return nil, err
+	}
+	now := tm.clock.Now()
+	claims := TokenClaims{
+		ID:        uuid.New(),
+		Subject:   userID,
+		SessionID: uuid.New(),
+		Username:  username,
+		Issuer:    tm.issuer,
+		Audience:  []string{tm.policy().audience + mfaChallengeAudienceSuffix},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(tm.policy().challengeExpiry)),
+		NotBefore: jwt.NewNumericDate(now),
+		TokenType: MFAChallenge,
+	}
+
+	token := jwt.NewWithClaims(tm.policy().accessMethod, &claims)
+	token.Header["typ"] = MFAChallengeTyp
+	signed, err := tm.signToken(ctx, MFAChallenge, token, tm.secret())
+	if err != nil {
+		return nil, fmt.Errorf("sign token: %w", err)
+	}
+	if tm.cipher != nil {
+		if signed, err = tm.cipher.encrypt(signed); err != nil {
+			return nil, fmt.Errorf("encrypt token: %w", err)
+		}
+	}
+	tm.stats.recordIssued(MFAChallenge)
+	return &TokenResponse{Token: signed, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
+
+// VerifyMFAChallenge verifies a challenge token without completing it, to
+// find the user whose second factor to check.
+func (tm *TokenMaker) VerifyMFAChallenge(ctx context.Context, tokenString string) (*TokenClaims, error) {
+	if len(tokenString) > tm.policy().maxTokenLength {
+		return nil, ErrTokenTooLarge
+	}
+	signed := tokenString
+	if tm.cipher != nil {
+		var err error
+		if signed, err = tm.cipher.decrypt(tokenString); err != nil {
+			return nil, err
+		}
+	}
+
+	p := tm.policy()
+	alg := p.accessMethod.Alg()
+	token, err := p.challengeParser.ParseWithClaims(signed, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if err := checkAlgHeader(token, alg); err != nil {
+			return nil, err
+		}
+		if typ, _ := token.Header["typ"].(string); typ != MFAChallengeTyp {
+			return nil, ErrWrongTokenType
+		}
+		return tm.secretVerificationKey(), nil
+	})
+	if err != nil {
+		if token != nil && checkAlgHeader(token, alg) != nil {
+			return nil, ErrUnexpectedAlgorithm
+		}
+		return nil, classifyParseError(err)
+	}
+	claims, ok := token.Claims.(*TokenClaims)
+	if !ok {
+		return nil, ErrMalformedToken
+	}
+	if err := validateClaims(claims, tm.issuer, p.audience+mfaChallengeAudienceSuffix, MFAChallenge, tm.clock.Now()); err != nil {
+		return nil, err
+	}
+	if err := tm.checkInvalidation(ctx, claims); err != nil {
+		return nil, err
+	}
+	if err := tm.checkUserRevoked(ctx, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// CompleteMFAChallenge exchanges a challenge token and the proof of the
+// second factor, verified by the caller after the challenge was issued, for
+// an access and refresh token of a new session with the user's roles. The
+// challenge can be completed once; without a revocation repository it can
+// be replayed until it expires.
+func (tm *TokenMaker) CompleteMFAChallenge(ctx context.Context, challengeToken string, proof MFAProof, roles []string, opts ...RefreshOption) (*TokenPair, error) {
+	claims, err := tm.VerifyMFAChallenge(ctx, challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	now := tm.clock.Now()
+	if proof.VerifiedAt.IsZero() || proof.VerifiedAt.After(now.Add(DefaultLeeway)) ||
+		proof.VerifiedAt.Before(claims.IssuedAt.Add(-DefaultLeeway)) || now.Sub(proof.VerifiedAt) > tm.policy().mfaProofMaxAge {
+		return nil, ErrStaleMFAProof
+	}
+	if tm.repo != nil {
+		ttl := claims.ExpiresAt.Sub(now) + DefaultLeeway
+		rctx, cancel := tm.repoContext(ctx)
+		err := consumeOnce(rctx, tm.repo, RefreshToken, mfaConsumedPrefix+claims.ID.String(), ttl)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	refresh, err := tm.CreateRefreshToken(ctx, claims.Subject, claims.Username, roles, claims.SessionID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create refresh token: %w", err)
+	}
+	access, err := tm.CreateAccessToken(ctx, claims.Subject, claims.Username, roles, claims.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("create access token: %w", err)
+	}
+	return &TokenPair{Access: access, Refresh: refresh, SessionID: claims.SessionID}, nil
+}
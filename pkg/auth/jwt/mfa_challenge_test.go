@@ -0,0 +1,82 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMFAChallenge(t *testing.T) {
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: 24 * time.Hour,
+	}, newMockRevocationRepo(), WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+	userID := uuid.New()
+
+	challenge, err := maker.CreateMFAChallenge(ctx, userID, "alice")
+	if err != nil {
+		t.Fatalf("create challenge: %v", err)
+	}
+	if want := clock.Now().Add(DefaultMFAChallengeExpiry); !challenge.ExpiresAt.Equal(want) {
+		t.Errorf("expires at %v, want %v", challenge.ExpiresAt, want)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, challenge.Token); !errors.Is(err, ErrWrongTokenType) {
+		t.Errorf("challenge as access token: err = %v, want ErrWrongTokenType", err)
+	}
+	claims, err := maker.VerifyMFAChallenge(ctx, challenge.Token)
+	if err != nil {
+		t.Fatalf("verify challenge: %v", err)
+	}
+	if claims.Subject != userID || len(claims.Roles) != 0 || slices.Contains(claims.Audience, "test-audience") {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	stale := MFAProof{Method: "otp", VerifiedAt: clock.Now().Add(-time.Minute)}
+	if _, err := maker.CompleteMFAChallenge(ctx, challenge.Token, stale, nil); !errors.Is(err, ErrStaleMFAProof) {
+		t.Errorf("proof before challenge: err = %v, want ErrStaleMFAProof", err)
+	}
+
+	clock.Advance(time.Minute)
+	proof := MFAProof{Method: "otp", VerifiedAt: clock.Now()}
+	tokens, err := maker.CompleteMFAChallenge(ctx, challenge.Token, proof, []string{"user"})
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	access, err := maker.VerifyAccessToken(ctx, tokens.Access.Token)
+	if err != nil {
+		t.Fatalf("verify access token: %v", err)
+	}
+	if access.Subject != userID || access.SessionID != tokens.SessionID || !slices.Equal(access.Roles, []string{"user"}) {
+		t.Errorf("access claims = %+v", access)
+	}
+	if _, err := maker.VerifyRefreshToken(ctx, tokens.Refresh.Token); err != nil {
+		t.Errorf("verify refresh token: %v", err)
+	}
+	if _, err := maker.CompleteMFAChallenge(ctx, challenge.Token, proof, []string{"user"}); !errors.Is(err, ErrTokenConsumed) {
+		t.Errorf("second completion: err = %v, want ErrTokenConsumed", err)
+	}
+
+	expired, err := maker.CreateMFAChallenge(ctx, userID, "alice")
+	if err != nil {
+		t.Fatalf("create challenge: %v", err)
+	}
+	clock.Advance(DefaultMFAChallengeExpiry + 2*DefaultLeeway)
+	if _, err := maker.VerifyMFAChallenge(ctx, expired.Token); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expired: err = %v, want ErrTokenExpired", err)
+	}
+	if _, err := maker.VerifyMFAChallenge(ctx, tokens.Access.Token); !errors.Is(err, ErrWrongTokenType) {
+		t.Errorf("access token as challenge: err = %v, want ErrWrongTokenType", err)
+	}
+}
@@ -0,0 +1,92 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// TokenFormat selects what the TokenMaker hands out to clients.
+type TokenFormat string
+
+const (
+	// TokenFormatJWT issues self-contained signed JWTs. Default.
+	TokenFormatJWT TokenFormat = "jwt"
+	// TokenFormatOpaque issues random references instead. The signed claims
+	// stay in the repository, which must implement OpaqueTokenRepository, so
+	// clients cannot read them, tokens are a fixed 43 bytes, and deleting the
+	// entry revokes the token at once. Every verification resolves the
+	// reference, so WithOfflineVerification still costs one repository round
+	// trip, and the asymmetric Verifier cannot verify opaque tokens.
+	TokenFormatOpaque TokenFormat = "opaque"
+)
+
+// opaqueTokenBytes is the entropy of an opaque token reference.
+const opaqueTokenBytes = 32
+
+// OpaqueTokenRepository is an optional extension of RevocationRepository
+// required by TokenFormatOpaque. It maps the SHA-256 hash of an opaque token
+// (see HashToken) to the signed claims it stands for, so a repository dump
+// does not leak usable tokens.
+type OpaqueTokenRepository interface {
+	SaveOpaqueToken(ctx context.Context, tokenType TokenType, key, claims string, ttl time.Duration) error
+	// LoadOpaqueToken returns "" and no error when key is unknown or expired.
+	LoadOpaqueToken(ctx context.Context, tokenType TokenType, key string) (string, error)
+	DeleteOpaqueToken(ctx context.Context, tokenType TokenType, key string) error
+}
+
+// issue returns signed to the client, encrypted if Config.EncryptionKey is
+// set. In opaque mode the result is stored instead and a fresh reference to
+// it returned; the entry outlives expiresAt by DefaultLeeway, as long as the
+// token is accepted.
+func (tm *TokenMaker) issue(ctx context.Context, tokenType TokenType, signed string, expiresAt time.Time) (*TokenResponse, error) {
+	if tm.cipher != nil {
+		var err error
+		if signed, err = tm.cipher.encrypt(signed); err != nil {
+			return nil, fmt.Errorf("encrypt token: %w", err)
+		}
+	}
+	if tm.opaque == nil {
+		tm.stats.recordIssued(tokenType)
+		return &TokenResponse{Token: signed, ExpiresAt: expiresAt}, nil
+	}
+
+	b := make([]byte, opaqueTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("generate opaque token: %w", err)
+	}
+	ref := base64.RawURLEncoding.EncodeToString(b)
+	ttl := expiresAt.Sub(tm.clock.Now()) + DefaultLeeway
+	rctx, cancel := tm.repoContext(ctx)
+	defer cancel()
+	if err := tm.opaque.SaveOpaqueToken(rctx, tokenType, HashToken(ref), signed, ttl); err != nil {
+		return nil, fmt.Errorf("store opaque token: %w", err)
+	}
+	tm.stats.recordIssued(tokenType)
+	return &TokenResponse{Token: ref, ExpiresAt: expiresAt}, nil
+}
+
+// resolve returns the signed token tokenString stands for, undoing issue:
+// the reference is looked up in opaque mode and the result decrypted when
+// tokens are encrypted.
+func (tm *TokenMaker) resolve(ctx context.Context, tokenType TokenType, tokenString string) (string, error) {
+	signed := tokenString
+	if tm.opaque != nil {
+		rctx, cancel := tm.repoContext(ctx)
+		var err error
+		signed, err = tm.opaque.LoadOpaqueToken(rctx, tokenType, HashToken(tokenString))
+		cancel()
+		if err != nil {
+			return "", fmt.Errorf("resolve opaque token: %w", err)
+		}
+		if signed == "" {
+			return "", ErrUnknownToken
+		}
+	}
+	if tm.cipher != nil {
+		return tm.cipher.decrypt(signed)
+	}
+	return signed, nil
+}
@@ -0,0 +1,138 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// opaqueRepo is a mockRevocationRepo that also stores opaque tokens.
+type opaqueRepo struct {
+	*mockRevocationRepo
+	tokens map[string]string
+}
+
+func newOpaqueRepo() *opaqueRepo {
+	return &opaqueRepo{mockRevocationRepo: newMockRevocationRepo(), tokens: make(map[string]string)}
+}
+
+func (r *opaqueRepo) SaveOpaqueToken(_ context.Context, tokenType TokenType, key, claims string, _ time.Duration) error {
+	r.tokens[string(tokenType)+":"+key] = claims
+	return nil
+}
+
+func (r *opaqueRepo) LoadOpaqueToken(_ context.Context, tokenType TokenType, key string) (string, error) {
+	return r.tokens[string(tokenType)+":"+key], nil
+}
+
+func (r *opaqueRepo) DeleteOpaqueToken(_ context.Context, tokenType TokenType, key string) error {
+	delete(r.tokens, string(tokenType)+":"+key)
+	return nil
+}
+
+func newOpaqueMaker(t *testing.T, repo RevocationRepository) *TokenMaker {
+	t.Helper()
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Minute,
+		RefreshExpiryDuration: time.Hour,
+		TokenFormat:           TokenFormatOpaque,
+	}, repo)
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	return maker
+}
+
+func TestOpaqueAccessToken(t *testing.T) {
+	ctx := context.Background()
+	repo := newOpaqueRepo()
+	maker := newOpaqueMaker(t, repo)
+
+	userID := uuid.New()
+	resp, err := maker.CreateAccessToken(ctx, userID, "alice", []string{"admin"}, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if strings.Contains(resp.Token, ".") || strings.Contains(resp.Token, "alice") || len(resp.Token) != 43 {
+		t.Fatalf("token %q does not look opaque", resp.Token)
+	}
+
+	claims, err := maker.VerifyAccessToken(WithOfflineVerification(ctx), resp.Token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.Subject != userID || claims.Username != "alice" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// The stored JWT itself is not accepted in place of the reference.
+	signed := repo.tokens[string(AccessToken)+":"+HashToken(resp.Token)]
+	if _, err := maker.VerifyAccessToken(ctx, signed); !errors.Is(err, ErrUnknownToken) {
+		t.Errorf("verify stored jwt: err = %v, want ErrUnknownToken", err)
+	}
+	// Nor is an access reference as a refresh token.
+	if _, err := maker.VerifyRefreshToken(ctx, resp.Token); !errors.Is(err, ErrUnknownToken) {
+		t.Errorf("verify as refresh: err = %v, want ErrUnknownToken", err)
+	}
+
+	if err := maker.RevokeAccessToken(ctx, resp.Token); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, resp.Token); !errors.Is(err, ErrUnknownToken) {
+		t.Errorf("verify after revoke: err = %v, want ErrUnknownToken", err)
+	}
+}
+
+func TestOpaqueRefreshRotation(t *testing.T) {
+	ctx := context.Background()
+	maker := newOpaqueMaker(t, newOpaqueRepo())
+
+	first, err := maker.CreateRefreshToken(ctx, uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	second, err := maker.RotateRefreshToken(ctx, first.Token)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if _, err := maker.VerifyRefreshToken(ctx, second.Token); err != nil {
+		t.Errorf("verify rotated token: %v", err)
+	}
+	if _, err := maker.RotateRefreshToken(ctx, first.Token); !errors.Is(err, ErrTokenRotated) {
+		t.Errorf("replay: err = %v, want ErrTokenRotated", err)
+	}
+}
+
+func TestOpaqueRequiresRepository(t *testing.T) {
+	cfg := Config{
+		Secret:      "test-secret-must-be-at-least-32-bytes",
+		Issuer:      "test-issuer",
+		Audience:    "test-audience",
+		TokenFormat: TokenFormatOpaque,
+	}
+	if _, err := NewTokenMaker(cfg, newMockRevocationRepo()); err == nil {
+		t.Error("expected an error for a repository without opaque token support")
+	}
+	cfg.TokenFormat = "paseto"
+	if _, err := NewTokenMaker(cfg, newOpaqueRepo()); err == nil {
+		t.Error("expected an error for an unknown token format")
+	}
+}
+
+func TestOpaqueHealthCheck(t *testing.T) {
+	repo := newOpaqueRepo()
+	maker := newOpaqueMaker(t, repo)
+	if err := maker.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("health check: %v", err)
+	}
+	if len(repo.tokens) != 0 {
+		t.Errorf("health check left %d opaque tokens behind", len(repo.tokens))
+	}
+}
@@ -0,0 +1,170 @@
+package jwt
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultPairingExpiry is how long a pairing lives when
+// Config.PairingExpiryDuration is zero.
+const DefaultPairingExpiry = 2 * time.Minute
+
+var (
+	// ErrPairingNotFound is returned for pairings that do not exist, expired
+	// or were already exchanged.
+	ErrPairingNotFound = errors.New("pairing not found or expired")
+	// ErrPairingPending is returned by PollPairing until the pairing is
+	// claimed; the new device should keep polling.
+	ErrPairingPending = errors.New("pairing not claimed yet")
+	// ErrPairingClaimed is returned when a pairing is claimed twice.
+	ErrPairingClaimed = errors.New("pairing already claimed")
+	// ErrPairingsDisabled is returned by the pairing APIs when the maker has
+	// no PairingStore.
+	ErrPairingsDisabled = errors.New("device pairing not enabled")
+)
+
+// PairingClaim is the signed in user a pairing was claimed by, whom the new
+// device is signed in as.
+type PairingClaim struct {
+	UserID   uuid.UUID
+	Username string
+	Roles    []string
+}
+
+// PairingStore keeps the state of pairings between CreatePairing and
+// PollPairing.
+type PairingStore interface {
+	// SavePairing records a new, unclaimed pairing for ttl.
+	SavePairing(ctx context.Context, id uuid.UUID, ttl time.Duration) error
+	// ClaimPairing attaches claim to the unclaimed pairing id. It returns
+	// ErrPairingClaimed if the pairing was claimed before and
+	// ErrPairingNotFound if it does not exist; implementations must do so
+	// atomically, so that only one claim succeeds.
+	ClaimPairing(ctx context.Context, id uuid.UUID, claim PairingClaim) error
+	// TakePairing removes a claimed pairing and returns its claim. It
+	// returns ErrPairingPending, keeping the pairing, while it is unclaimed
+	// and ErrPairingNotFound if it does not exist.
+	TakePairing(ctx context.Context, id uuid.UUID) (*PairingClaim, error)
+}
+
+// WithPairingStore enables device pairing with state kept in s.
+func WithPairingStore(s PairingStore) Option {
+	return func(o *makerOptions) { o.pairings = s }
+}
+
+// Pairing is a pending device pairing for QR code login.
+type Pairing struct {
+	ID uuid.UUID
+	// ClaimToken is shown to the user as a QR code, typically within a URL,
+	// and scanned by a device they are signed in on.
+	ClaimToken string
+	// PollToken stays on the device being paired, which exchanges it for
+	// tokens with PollPairing. It must not be shown in the QR code, or
+	// whoever scans it could sign in as the claiming user.
+	PollToken string
+	ExpiresAt time.Time
+}
+
+// CreatePairing starts pairing a new device, which shows ClaimToken and
+// polls with PollToken.
+func (tm *TokenMaker) CreatePairing(ctx context.Context) (*Pairing, error) {
+	if tm.pairings == nil {
+		return nil, ErrPairingsDisabled
+	}
+	id := uuid.New()
+	if err := tm.pairings.SavePairing(ctx, id, tm.policy().pairingExpiry); err != nil {
+		return nil, fmt.Errorf("save pairing: %w", err)
+	}
+	return &Pairing{
+		ID:         id,
+		ClaimToken: tm.pairingToken("claim", id),
+		PollToken:  tm.pairingToken("poll", id),
+		ExpiresAt:  tm.clock.Now().Add(tm.policy().pairingExpiry),
+	}, nil
+}
+
+// ClaimPairing signs the device of claimToken in as claim, the verified
+// user of the device that scanned it. Confirm with the user first: a
+// pairing QR code shown by an attacker would otherwise sign the attacker's
+// device in.
+func (tm *TokenMaker) ClaimPairing(ctx context.Context, claimToken string, claim PairingClaim) error {
+	if tm.pairings == nil {
+		return ErrPairingsDisabled
+	}
+	if claim.UserID == uuid.Nil {
+		return fmt.Errorf("pairing claim user id is required")
+	}
+	id, err := tm.parsePairingToken("claim", claimToken)
+	if err != nil {
+		return err
+	}
+	return tm.pairings.ClaimPairing(ctx, id, claim)
+}
+
+// PollPairing exchanges pollToken for an access and refresh token of a new
+// session once the pairing was claimed, and returns ErrPairingPending
+// before. A pairing is exchanged only once.
+func (tm *TokenMaker) PollPairing(ctx context.Context, pollToken string, opts ...RefreshOption) (*TokenPair, error) {
+	if tm.pairings == nil {
+		return nil, ErrPairingsDisabled
+	}
+	id, err := tm.parsePairingToken("poll", pollToken)
+	if err != nil {
+		return nil, err
+	}
+	claim, err := tm.pairings.TakePairing(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID := uuid.New()
+	refresh, err := tm.CreateRefreshToken(ctx, claim.UserID, claim.Username, claim.Roles, sessionID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create refresh token: %w", err)
+	}
+	access, err := tm.CreateAccessToken(ctx, claim.UserID, claim.Username, claim.Roles, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("create access token: %w", err)
+	}
+	return &TokenPair{Access: access, Refresh: refresh, SessionID: sessionID}, nil
+}
+
+// pairingToken returns "<id>.<MAC>", keyed with the signing secret and the
+// token's role, so the claim token cannot be used to poll.
+func (tm *TokenMaker) pairingToken(role string, id uuid.UUID) string {
+	return id.String() + "." + pairingMAC(tm.secret(), role, id)
+}
+
+func pairingMAC(secret []byte, role string, id uuid.UUID) string {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte("pairing " + role + " "))
+	h.Write(id[:])
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (tm *TokenMaker) parsePairingToken(role, token string) (uuid.UUID, error) {
+	idPart, mac, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, ErrMalformedToken
+	}
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return uuid.Nil, ErrMalformedToken
+	}
+	// Tokens issued before the last ReloadSecret carry the previous MAC.
+	s := tm.secrets.Load()
+	for _, secret := range [][]byte{s.current, s.previous} {
+		if secret != nil && hmac.Equal([]byte(mac), []byte(pairingMAC(secret, role, id))) {
+			return id, nil
+		}
+	}
+	return uuid.Nil, ErrInvalidSignature
+}
@@ -0,0 +1,107 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// mapPairingStore is a PairingStore without expiry.
+type mapPairingStore map[uuid.UUID]*PairingClaim
+
+func (s mapPairingStore) SavePairing(_ context.Context, id uuid.UUID, _ time.Duration) error {
+	s[id] = nil
+	return nil
+}
+
+func (s mapPairingStore) ClaimPairing(_ context.Context, id uuid.UUID, claim PairingClaim) error {
+	c, ok := s[id]
+	if !ok {
+		return ErrPairingNotFound
+	}
+	if c != nil {
+		return ErrPairingClaimed
+	}
+	s[id] = &claim
+	return nil
+}
+
+func (s mapPairingStore) TakePairing(_ context.Context, id uuid.UUID) (*PairingClaim, error) {
+	c, ok := s[id]
+	if !ok {
+		return nil, ErrPairingNotFound
+	}
+	if c == nil {
+		return nil, ErrPairingPending
+	}
+	delete(s, id)
+	return c, nil
+}
+
+func TestPairing(t *testing.T) {
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: 24 * time.Hour,
+	}, nil, WithClock(clock), WithPairingStore(mapPairingStore{}))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+
+	p, err := maker.CreatePairing(ctx)
+	if err != nil {
+		t.Fatalf("create pairing: %v", err)
+	}
+	if want := clock.Now().Add(DefaultPairingExpiry); !p.ExpiresAt.Equal(want) {
+		t.Errorf("expires at %v, want %v", p.ExpiresAt, want)
+	}
+	if _, err := maker.PollPairing(ctx, p.PollToken); !errors.Is(err, ErrPairingPending) {
+		t.Errorf("poll before claim: err = %v, want ErrPairingPending", err)
+	}
+	if _, err := maker.PollPairing(ctx, p.ClaimToken); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("poll with claim token: err = %v, want ErrInvalidSignature", err)
+	}
+
+	userID := uuid.New()
+	claim := PairingClaim{UserID: userID, Username: "alice", Roles: []string{"user"}}
+	if err := maker.ClaimPairing(ctx, p.PollToken, claim); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("claim with poll token: err = %v, want ErrInvalidSignature", err)
+	}
+	if err := maker.ClaimPairing(ctx, p.ClaimToken, claim); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if err := maker.ClaimPairing(ctx, p.ClaimToken, PairingClaim{UserID: uuid.New()}); !errors.Is(err, ErrPairingClaimed) {
+		t.Errorf("second claim: err = %v, want ErrPairingClaimed", err)
+	}
+
+	pair, err := maker.PollPairing(ctx, p.PollToken)
+	if err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	claims, err := maker.VerifyAccessToken(ctx, pair.Access.Token)
+	if err != nil {
+		t.Fatalf("verify access token: %v", err)
+	}
+	if claims.Subject != userID || claims.SessionID != pair.SessionID || !slices.Equal(claims.Roles, []string{"user"}) {
+		t.Errorf("claims = %+v", claims)
+	}
+	if _, err := maker.PollPairing(ctx, p.PollToken); !errors.Is(err, ErrPairingNotFound) {
+		t.Errorf("second exchange: err = %v, want ErrPairingNotFound", err)
+	}
+	if _, err := maker.PollPairing(ctx, "not-a-pairing-token"); !errors.Is(err, ErrMalformedToken) {
+		t.Errorf("malformed: err = %v, want ErrMalformedToken", err)
+	}
+
+	plain := newSessionTestMaker(t, nil)
+	if _, err := plain.CreatePairing(ctx); !errors.Is(err, ErrPairingsDisabled) {
+		t.Errorf("without store: err = %v, want ErrPairingsDisabled", err)
+	}
+}
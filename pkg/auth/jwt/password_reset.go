@@ -0,0 +1,68 @@
+package jwt
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PurposePasswordReset is the purpose of password reset tokens.
+const PurposePasswordReset = "password_reset"
+
+// CreatePasswordResetToken issues a single-use password reset token for
+// userID bound to credentialVersion, anything that changes with the
+// password: the stored password hash, a credential version counter or the
+// time of the last change. The token stops working as soon as the password
+// changes, also when changed by other means than this token, so an older
+// reset link left in a mailbox cannot be used afterwards.
+func (m *ActionTokenMaker) CreatePasswordResetToken(_ context.Context, userID uuid.UUID, credentialVersion string, expiry time.Duration) (*TokenResponse, error) {
+	if credentialVersion == "" {
+		return nil, fmt.Errorf("credential version is required")
+	}
+	return m.create(ActionClaims{
+		Subject:        userID,
+		Purpose:        PurposePasswordReset,
+		CredentialHash: m.credentialHash(userID, credentialVersion),
+	}, expiry)
+}
+
+// VerifyPasswordResetToken verifies a token from CreatePasswordResetToken
+// against the user's current credentialVersion and consumes it. It returns
+// ErrCredentialChanged when the password changed since the token was
+// issued, or when credentialVersion belongs to another user than
+// claims.Subject; load the user from ParsePasswordResetToken's claims.
+func (m *ActionTokenMaker) VerifyPasswordResetToken(ctx context.Context, tokenString, credentialVersion string) (*ActionClaims, error) {
+	claims, err := m.parse(tokenString, PurposePasswordReset)
+	if err != nil {
+		return nil, err
+	}
+	want := m.credentialHash(claims.Subject, credentialVersion)
+	if !hmac.Equal([]byte(claims.CredentialHash), []byte(want)) {
+		return nil, ErrCredentialChanged
+	}
+	if err := m.consume(ctx, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// ParsePasswordResetToken verifies a password reset token without checking
+// the credential version or consuming it, to find the user it was issued
+// for before calling VerifyPasswordResetToken.
+func (m *ActionTokenMaker) ParsePasswordResetToken(tokenString string) (*ActionClaims, error) {
+	return m.parse(tokenString, PurposePasswordReset)
+}
+
+// credentialHash keys the hash with the signing secret, so the readable
+// token payload reveals nothing about the stored password hash.
+func (m *ActionTokenMaker) credentialHash(userID uuid.UUID, credentialVersion string) string {
+	h := hmac.New(sha256.New, m.secret)
+	h.Write(userID[:])
+	h.Write([]byte(credentialVersion))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,225 @@
+package jwt
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token, so
+// VerifyAccessToken can tell them from JWTs and secret scanners can find
+// leaked ones.
+const PersonalAccessTokenPrefix = "gsp_"
+
+// personalAccessTokenBytes is the entropy of a personal access token.
+const personalAccessTokenBytes = 32
+
+// ErrPersonalAccessTokenNotFound is returned by RevokePersonalAccessToken
+// for tokens that do not exist or belong to another user.
+var ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+
+// PersonalAccessToken describes a user-created token for integrations. The
+// secret itself is only returned once, by CreatePersonalAccessToken.
+type PersonalAccessToken struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+	Name   string    `json:"name"`
+	Scopes []string  `json:"scopes"`
+	// Username and Roles are copied into the claims of every request.
+	Username  string    `json:"username,omitempty"`
+	Roles     []string  `json:"roles,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is zero for tokens that never expire.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// PersonalAccessTokenStore keeps personal access tokens under the SHA-256
+// hash of their secret (see HashToken), so a store dump does not leak
+// usable tokens.
+type PersonalAccessTokenStore interface {
+	SavePersonalAccessToken(ctx context.Context, key string, pat PersonalAccessToken) error
+	// LoadPersonalAccessToken returns nil and no error when key is unknown.
+	LoadPersonalAccessToken(ctx context.Context, key string) (*PersonalAccessToken, error)
+	// ListPersonalAccessTokens returns the tokens of userID in any order,
+	// expired ones included.
+	ListPersonalAccessTokens(ctx context.Context, userID uuid.UUID) ([]PersonalAccessToken, error)
+	// DeletePersonalAccessToken reports whether the token existed and
+	// belonged to userID.
+	DeletePersonalAccessToken(ctx context.Context, userID, id uuid.UUID) (bool, error)
+}
+
+// WithPersonalAccessTokens enables personal access tokens stored in s.
+// Tokens may only be granted scopes from allowed; an empty list allows any.
+func WithPersonalAccessTokens(s PersonalAccessTokenStore, allowed ...string) Option {
+	return func(o *makerOptions) { o.pats, o.patScopes = s, allowed }
+}
+
+// PersonalAccessTokenRequest describes the token to create.
+type PersonalAccessTokenRequest struct {
+	UserID   uuid.UUID
+	Username string
+	Roles    []string
+	// Name tells the user's tokens apart, e.g. "CI deploy".
+	Name string
+	// Scopes are required; requests are limited to them.
+	Scopes []string
+	// ExpiresIn of zero creates a token that never expires.
+	ExpiresIn time.Duration
+}
+
+// CreatePersonalAccessToken creates a token for req.UserID and returns its
+// secret, which is not stored and cannot be shown again, along with its
+// description. VerifyAccessToken accepts the secret like an access token
+// carrying the granted scopes.
+func (tm *TokenMaker) CreatePersonalAccessToken(ctx context.Context, req PersonalAccessTokenRequest) (string, *PersonalAccessToken, error) {
+	if tm.pats == nil {
+		return "", nil, fmt.Errorf("create personal access token: %w", errors.ErrUnsupported)
+	}
+	if req.UserID == uuid.Nil {
+		return "", nil, fmt.Errorf("user id is required")
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return "", nil, fmt.Errorf("personal access token name is required")
+	}
+	if req.ExpiresIn < 0 {
+		return "", nil, fmt.Errorf("personal access token expiry must not be negative")
+	}
+	scopes, err := tm.patScopesFor(req.Scopes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	b := make([]byte, personalAccessTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, fmt.Errorf("generate personal access token: %w", err)
+	}
+	secret := PersonalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	now := tm.clock.Now()
+	pat := PersonalAccessToken{
+		ID:        uuid.New(),
+		UserID:    req.UserID,
+		Name:      req.Name,
+		Scopes:    scopes,
+		Username:  req.Username,
+		Roles:     req.Roles,
+		CreatedAt: now,
+	}
+	if req.ExpiresIn > 0 {
+		pat.ExpiresAt = now.Add(req.ExpiresIn)
+	}
+	if err := tm.pats.SavePersonalAccessToken(ctx, HashToken(secret), pat); err != nil {
+		return "", nil, fmt.Errorf("store personal access token: %w", err)
+	}
+	return secret, &pat, nil
+}
+
+// patScopesFor validates and deduplicates requested scopes.
+func (tm *TokenMaker) patScopesFor(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return nil, fmt.Errorf("personal access tokens need at least one scope")
+	}
+	scopes := make([]string, 0, len(requested))
+	for _, s := range requested {
+		if s == "" || strings.ContainsAny(s, " \t\n") {
+			return nil, fmt.Errorf("invalid scope %q", s)
+		}
+		if len(tm.patScopes) > 0 && !slices.Contains(tm.patScopes, s) {
+			return nil, fmt.Errorf("scope %q cannot be granted to personal access tokens", s)
+		}
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes, nil
+}
+
+// ListPersonalAccessTokens returns the unexpired tokens of userID, newest
+// first.
+func (tm *TokenMaker) ListPersonalAccessTokens(ctx context.Context, userID uuid.UUID) ([]PersonalAccessToken, error) {
+	if tm.pats == nil {
+		return nil, fmt.Errorf("list personal access tokens: %w", errors.ErrUnsupported)
+	}
+	all, err := tm.pats.ListPersonalAccessTokens(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list personal access tokens: %w", err)
+	}
+
+	now := tm.clock.Now()
+	active := all[:0]
+	for _, pat := range all {
+		if pat.ExpiresAt.IsZero() || now.Before(pat.ExpiresAt) {
+			active = append(active, pat)
+		}
+	}
+	slices.SortStableFunc(active, func(a, b PersonalAccessToken) int {
+		return cmp.Compare(b.CreatedAt.UnixNano(), a.CreatedAt.UnixNano())
+	})
+	return active, nil
+}
+
+// RevokePersonalAccessToken deletes the token id of userID, which stops
+// working at once. It returns ErrPersonalAccessTokenNotFound when userID has
+// no such token.
+func (tm *TokenMaker) RevokePersonalAccessToken(ctx context.Context, userID, id uuid.UUID) error {
+	if tm.pats == nil {
+		return fmt.Errorf("revoke personal access token: %w", errors.ErrUnsupported)
+	}
+	ok, err := tm.pats.DeletePersonalAccessToken(ctx, userID, id)
+	if err != nil {
+		return fmt.Errorf("revoke personal access token: %w", err)
+	}
+	if !ok {
+		return ErrPersonalAccessTokenNotFound
+	}
+	return nil
+}
+
+// verifyPersonalAccessToken looks secret up and returns the claims of the
+// token it stands for. Personal access tokens are not bound to a session,
+// so session revocation does not affect them, but user revocation and
+// invalidation cutoffs do.
+func (tm *TokenMaker) verifyPersonalAccessToken(ctx context.Context, secret string) (*TokenClaims, error) {
+	pat, err := tm.pats.LoadPersonalAccessToken(ctx, HashToken(secret))
+	if err != nil {
+		return nil, fmt.Errorf("load personal access token: %w", err)
+	}
+	if pat == nil {
+		return nil, ErrUnknownToken
+	}
+	if !pat.ExpiresAt.IsZero() && !tm.clock.Now().Before(pat.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	claims := &TokenClaims{
+		ID:        pat.ID,
+		Subject:   pat.UserID,
+		Username:  pat.Username,
+		Roles:     pat.Roles,
+		Issuer:    tm.issuer,
+		Audience:  []string{tm.policy().audience},
+		IssuedAt:  jwt.NewNumericDate(pat.CreatedAt),
+		NotBefore: jwt.NewNumericDate(pat.CreatedAt),
+		TokenType: AccessToken,
+		Scopes:    pat.Scopes,
+	}
+	if !pat.ExpiresAt.IsZero() {
+		claims.ExpiresAt = jwt.NewNumericDate(pat.ExpiresAt)
+	}
+	if err := tm.checkUserRevoked(ctx, claims); err != nil {
+		return nil, err
+	}
+	if err := tm.checkInvalidation(ctx, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
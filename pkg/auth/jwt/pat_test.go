@@ -0,0 +1,171 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// mapPATStore is a PersonalAccessTokenStore keyed by secret hash.
+type mapPATStore struct {
+	pats map[string]PersonalAccessToken
+}
+
+func (s *mapPATStore) SavePersonalAccessToken(_ context.Context, key string, pat PersonalAccessToken) error {
+	s.pats[key] = pat
+	return nil
+}
+
+func (s *mapPATStore) LoadPersonalAccessToken(_ context.Context, key string) (*PersonalAccessToken, error) {
+	pat, ok := s.pats[key]
+	if !ok {
+		return nil, nil
+	}
+	return &pat, nil
+}
+
+func (s *mapPATStore) ListPersonalAccessTokens(_ context.Context, userID uuid.UUID) ([]PersonalAccessToken, error) {
+	var out []PersonalAccessToken
+	for _, pat := range s.pats {
+		if pat.UserID == userID {
+			out = append(out, pat)
+		}
+	}
+	return out, nil
+}
+
+func (s *mapPATStore) DeletePersonalAccessToken(_ context.Context, userID, id uuid.UUID) (bool, error) {
+	for key, pat := range s.pats {
+		if pat.ID == id && pat.UserID == userID {
+			delete(s.pats, key)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestPersonalAccessTokens(t *testing.T) {
+	ctx := context.Background()
+	store := &mapPATStore{pats: make(map[string]PersonalAccessToken)}
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: 24 * time.Hour,
+	}, newMockRevocationRepo(), WithPersonalAccessTokens(store, "repo:read", "repo:write", "issues:read"), WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	userID := uuid.New()
+	ci, ciInfo, err := maker.CreatePersonalAccessToken(ctx, PersonalAccessTokenRequest{
+		UserID:   userID,
+		Username: "alice",
+		Name:     "CI deploy",
+		Scopes:   []string{"repo:read", "repo:write", "repo:read"},
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !strings.HasPrefix(ci, PersonalAccessTokenPrefix) {
+		t.Errorf("token %q lacks prefix", ci)
+	}
+	if _, ok := store.pats[HashToken(ci)]; !ok || len(store.pats) != 1 {
+		t.Error("token not stored under its hash")
+	}
+
+	claims, err := maker.VerifyAccessToken(ctx, ci)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.Subject != userID || claims.ID != ciInfo.ID || claims.Username != "alice" || claims.ExpiresAt != nil {
+		t.Errorf("claims = %+v", claims)
+	}
+	if !slices.Equal(claims.Scopes, []string{"repo:read", "repo:write"}) {
+		t.Errorf("scopes = %v", claims.Scopes)
+	}
+
+	clock.Advance(time.Second)
+	short, _, err := maker.CreatePersonalAccessToken(ctx, PersonalAccessTokenRequest{
+		UserID:    userID,
+		Name:      "script",
+		Scopes:    []string{"issues:read"},
+		ExpiresIn: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("create expiring: %v", err)
+	}
+	list, err := maker.ListPersonalAccessTokens(ctx, userID)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "script" || list[1].Name != "CI deploy" {
+		t.Errorf("list = %+v", list)
+	}
+
+	clock.Advance(time.Hour)
+	if _, err := maker.VerifyAccessToken(ctx, short); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expired: err = %v, want ErrTokenExpired", err)
+	}
+	if list, _ := maker.ListPersonalAccessTokens(ctx, userID); len(list) != 1 {
+		t.Errorf("list after expiry = %+v", list)
+	}
+
+	if err := maker.RevokePersonalAccessToken(ctx, uuid.New(), ciInfo.ID); !errors.Is(err, ErrPersonalAccessTokenNotFound) {
+		t.Errorf("revoke other user's token: err = %v", err)
+	}
+	if err := maker.RevokePersonalAccessToken(ctx, userID, ciInfo.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, ci); !errors.Is(err, ErrUnknownToken) {
+		t.Errorf("revoked: err = %v, want ErrUnknownToken", err)
+	}
+}
+
+func TestCreatePersonalAccessToken_Validation(t *testing.T) {
+	ctx := context.Background()
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: 24 * time.Hour,
+	}, nil, WithPersonalAccessTokens(&mapPATStore{pats: make(map[string]PersonalAccessToken)}, "repo:read"))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	userID := uuid.New()
+	for name, req := range map[string]PersonalAccessTokenRequest{
+		"no user":          {Name: "x", Scopes: []string{"repo:read"}},
+		"no name":          {UserID: userID, Scopes: []string{"repo:read"}},
+		"no scopes":        {UserID: userID, Name: "x"},
+		"disallowed scope": {UserID: userID, Name: "x", Scopes: []string{"admin"}},
+		"negative expiry":  {UserID: userID, Name: "x", Scopes: []string{"repo:read"}, ExpiresIn: -time.Hour},
+	} {
+		if _, _, err := maker.CreatePersonalAccessToken(ctx, req); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	plain, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: 24 * time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	if _, _, err := plain.CreatePersonalAccessToken(ctx, PersonalAccessTokenRequest{UserID: userID, Name: "x", Scopes: []string{"a"}}); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("without store: err = %v, want ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,114 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IssuanceLimiter throttles token issuance per user, so a compromised
+// credential or a runaway client cannot mint tokens without bound.
+type IssuanceLimiter interface {
+	// Allow reports whether userID may be issued one more token now.
+	Allow(ctx context.Context, userID uuid.UUID) (bool, error)
+}
+
+// WithIssuanceLimiter sets the limiter consulted before every token is
+// issued, replacing the in-process token bucket configured by
+// Config.IssuanceRateLimit. Use it to share limits between instances.
+func WithIssuanceLimiter(l IssuanceLimiter) Option {
+	return func(o *makerOptions) { o.limiter = l }
+}
+
+// minBucketSweep is the bucket count below which idle buckets are not swept.
+const minBucketSweep = 1024
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// bucketLimiter is an in-process IssuanceLimiter with one token bucket per
+// user. Buckets that have refilled completely carry no state and are dropped
+// when the map grows.
+type bucketLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	clock   Clock
+	buckets map[uuid.UUID]*bucket
+	sweepAt int
+}
+
+func newBucketLimiter(rate float64, burst int, clock Clock) *bucketLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &bucketLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		clock:   clock,
+		buckets: make(map[uuid.UUID]*bucket),
+		sweepAt: minBucketSweep,
+	}
+}
+
+func (l *bucketLimiter) Allow(_ context.Context, userID uuid.UUID) (bool, error) {
+	now := l.clock.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[userID]
+	if !ok {
+		if len(l.buckets) >= l.sweepAt {
+			l.sweep(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[userID] = b
+	}
+	l.refill(b, now)
+	if b.tokens < 1 {
+		return false, nil
+	}
+	b.tokens--
+	return true, nil
+}
+
+func (l *bucketLimiter) refill(b *bucket, now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(l.burst, b.tokens+elapsed.Seconds()*l.rate)
+		b.last = now
+	}
+}
+
+// sweep drops full buckets and moves the next sweep out so that its cost is
+// amortized over the inserts in between.
+func (l *bucketLimiter) sweep(now time.Time) {
+	for id, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= l.burst {
+			delete(l.buckets, id)
+		}
+	}
+	l.sweepAt = max(minBucketSweep, 2*len(l.buckets))
+}
+
+// allowIssue returns ErrRateLimited when userID has exhausted its issuance
+// budget. Tokens without a subject are not limited.
+func (tm *TokenMaker) allowIssue(ctx context.Context, userID uuid.UUID) error {
+	if tm.limiter == nil || userID == uuid.Nil {
+		return nil
+	}
+
+	ok, err := tm.limiter.Allow(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("check issuance limit: %w", err)
+	}
+	if !ok {
+		return ErrRateLimited
+	}
+	return nil
+}
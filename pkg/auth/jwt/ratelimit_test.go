@@ -0,0 +1,72 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestIssuanceRateLimit(t *testing.T) {
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: 24 * time.Hour,
+		IssuanceRateLimit:     1,
+		IssuanceBurst:         3,
+	}, newMockRevocationRepo(), WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+	userID, sessionID := uuid.New(), uuid.New()
+
+	refresh, err := maker.CreateRefreshToken(ctx, userID, "alice", nil, sessionID)
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	for i := range 2 {
+		if _, err := maker.CreateAccessToken(ctx, userID, "alice", nil, sessionID); err != nil {
+			t.Fatalf("create access token %d: %v", i, err)
+		}
+	}
+	if _, err := maker.CreateAccessToken(ctx, userID, "alice", nil, sessionID); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited once the burst is spent, got %v", err)
+	}
+	if _, err := maker.CreateAccessToken(ctx, uuid.New(), "bob", nil, uuid.New()); err != nil {
+		t.Errorf("expected another user to be unaffected, got %v", err)
+	}
+
+	// A throttled rotation leaves the old refresh token usable.
+	if _, err := maker.RotateRefreshToken(ctx, refresh.Token); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited on rotation, got %v", err)
+	}
+	clock.Advance(time.Second)
+	if _, err := maker.RotateRefreshToken(ctx, refresh.Token); err != nil {
+		t.Errorf("expected rotation to succeed after refill, got %v", err)
+	}
+}
+
+func TestBucketLimiter_Sweep(t *testing.T) {
+	clock := newFakeClock()
+	l := newBucketLimiter(1, 1, clock)
+	ctx := context.Background()
+
+	for range minBucketSweep {
+		if ok, _ := l.Allow(ctx, uuid.New()); !ok {
+			t.Fatal("expected first token for a new user to be allowed")
+		}
+	}
+	clock.Advance(time.Second)
+	if ok, _ := l.Allow(ctx, uuid.New()); !ok {
+		t.Fatal("expected first token for a new user to be allowed")
+	}
+	if n := len(l.buckets); n != 1 {
+		t.Errorf("expected refilled buckets to be swept, got %d buckets", n)
+	}
+}
@@ -0,0 +1,203 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// MakerRegistry holds one TokenMaker per tenant, each with its own keys,
+// issuer and lifetimes, and routes every call to the right one: by the
+// token's issuer when the token names one, and otherwise by the tenant
+// attached to the context with WithTenant. Tenants must have distinct
+// issuers so their tokens can be told apart.
+type MakerRegistry struct {
+	mu       sync.RWMutex
+	makers   map[string]*TokenMaker
+	byIssuer map[string]string
+}
+
+// NewMakerRegistry returns an empty registry.
+func NewMakerRegistry() *MakerRegistry {
+	return &MakerRegistry{
+		makers:   make(map[string]*TokenMaker),
+		byIssuer: make(map[string]string),
+	}
+}
+
+type tenantKey struct{}
+
+// WithTenant attaches the tenant a request is for to ctx, e.g. from its
+// host name or a header, so MakerRegistry can pick the tenant's maker.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom returns the tenant attached by WithTenant.
+func TenantFrom(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// Register adds the maker of tenant. It fails if the tenant or the maker's
+// issuer is already registered.
+func (r *MakerRegistry) Register(tenant string, tm *TokenMaker) error {
+	if tenant == "" {
+		return errors.New("tenant is required")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.makers[tenant]; ok {
+		return fmt.Errorf("tenant %q is already registered", tenant)
+	}
+	if other, ok := r.byIssuer[tm.issuer]; ok {
+		return fmt.Errorf("issuer %q is already used by tenant %q", tm.issuer, other)
+	}
+	r.makers[tenant] = tm
+	r.byIssuer[tm.issuer] = tenant
+	return nil
+}
+
+// Remove removes the maker of tenant and closes it.
+func (r *MakerRegistry) Remove(tenant string) error {
+	r.mu.Lock()
+	tm, ok := r.makers[tenant]
+	if ok {
+		delete(r.makers, tenant)
+		delete(r.byIssuer, tm.issuer)
+	}
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("remove tenant %q: %w", tenant, ErrUnknownTenant)
+	}
+	return tm.Close()
+}
+
+// Tenants returns the registered tenants, sorted.
+func (r *MakerRegistry) Tenants() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Sorted(maps.Keys(r.makers))
+}
+
+// Maker returns the maker of tenant.
+func (r *MakerRegistry) Maker(tenant string) (*TokenMaker, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tm, ok := r.makers[tenant]
+	return tm, ok
+}
+
+// MakerFor returns the maker of the tenant attached to ctx.
+func (r *MakerRegistry) MakerFor(ctx context.Context) (*TokenMaker, error) {
+	tenant, ok := TenantFrom(ctx)
+	if !ok {
+		return nil, ErrUnknownTenant
+	}
+	tm, ok := r.Maker(tenant)
+	if !ok {
+		return nil, ErrUnknownTenant
+	}
+	return tm, nil
+}
+
+// MakerForToken returns the maker of the tenant that issued tokenString,
+// found by its iss claim. Opaque and encrypted tokens do not reveal their
+// issuer, so for them the tenant attached to ctx is used. A token issued
+// by a different tenant than the one attached to ctx is rejected with
+// ErrInvalidIssuer, so one tenant's tokens cannot be replayed against
+// another. The token is not verified; call the maker's methods for that.
+func (r *MakerRegistry) MakerForToken(ctx context.Context, tokenString string) (*TokenMaker, error) {
+	claims := &TokenClaims{}
+	if _, _, err := unverifiedParser.ParseUnverified(tokenString, claims); err != nil || claims.Issuer == "" {
+		return r.MakerFor(ctx)
+	}
+
+	r.mu.RLock()
+	tenant, ok := r.byIssuer[NormalizeIssuer(claims.Issuer)]
+	tm := r.makers[tenant]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownTenant
+	}
+	if want, ok := TenantFrom(ctx); ok && want != tenant {
+		return nil, ErrInvalidIssuer
+	}
+	return tm, nil
+}
+
+// CreateAccessToken issues an access token with the maker of the tenant
+// attached to ctx.
+func (r *MakerRegistry) CreateAccessToken(ctx context.Context, userID uuid.UUID, username string, roles []string, sessionID uuid.UUID) (*TokenResponse, error) {
+	tm, err := r.MakerFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tm.CreateAccessToken(ctx, userID, username, roles, sessionID)
+}
+
+// CreateRefreshToken issues a refresh token with the maker of the tenant
+// attached to ctx.
+func (r *MakerRegistry) CreateRefreshToken(ctx context.Context, userID uuid.UUID, username string, roles []string, sessionID uuid.UUID, opts ...RefreshOption) (*TokenResponse, error) {
+	tm, err := r.MakerFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tm.CreateRefreshToken(ctx, userID, username, roles, sessionID, opts...)
+}
+
+// VerifyAccessToken verifies tokenString with its tenant's maker; see
+// MakerForToken.
+func (r *MakerRegistry) VerifyAccessToken(ctx context.Context, tokenString string) (*TokenClaims, error) {
+	tm, err := r.MakerForToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	return tm.VerifyAccessToken(ctx, tokenString)
+}
+
+// VerifyRefreshToken verifies tokenString with its tenant's maker; see
+// MakerForToken.
+func (r *MakerRegistry) VerifyRefreshToken(ctx context.Context, tokenString string) (*TokenClaims, error) {
+	tm, err := r.MakerForToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	return tm.VerifyRefreshToken(ctx, tokenString)
+}
+
+// RotateRefreshToken rotates oldToken with its tenant's maker; see
+// MakerForToken.
+func (r *MakerRegistry) RotateRefreshToken(ctx context.Context, oldToken string) (*TokenResponse, error) {
+	tm, err := r.MakerForToken(ctx, oldToken)
+	if err != nil {
+		return nil, err
+	}
+	return tm.RotateRefreshToken(ctx, oldToken)
+}
+
+// RevokeAccessToken revokes tokenString with its tenant's maker; see
+// MakerForToken.
+func (r *MakerRegistry) RevokeAccessToken(ctx context.Context, tokenString string) error {
+	tm, err := r.MakerForToken(ctx, tokenString)
+	if err != nil {
+		return err
+	}
+	return tm.RevokeAccessToken(ctx, tokenString)
+}
+
+// Close closes every registered maker.
+func (r *MakerRegistry) Close() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var errs []error
+	for _, tm := range r.makers {
+		errs = append(errs, tm.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,72 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTenantMaker(t *testing.T, issuer string) *TokenMaker {
+	t.Helper()
+	maker, err := NewTokenMaker(Config{
+		Secret:               "secret-of-" + issuer + "-at-least-32-bytes",
+		Issuer:               issuer,
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, newMockRevocationRepo())
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	return maker
+}
+
+func TestMakerRegistry(t *testing.T) {
+	r := NewMakerRegistry()
+	for tenant, issuer := range map[string]string{"acme": "https://acme.example.com", "globex": "https://globex.example.com"} {
+		if err := r.Register(tenant, newTenantMaker(t, issuer)); err != nil {
+			t.Fatalf("register %s: %v", tenant, err)
+		}
+	}
+	if err := r.Register("acme", newTenantMaker(t, "https://other.example.com")); err == nil {
+		t.Error("expected a duplicate tenant to be rejected")
+	}
+	if err := r.Register("initech", newTenantMaker(t, "https://acme.example.com")); err == nil {
+		t.Error("expected a duplicate issuer to be rejected")
+	}
+
+	acme := WithTenant(context.Background(), "acme")
+	token, err := r.CreateAccessToken(acme, uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	// The token's issuer selects the tenant without a tenant in ctx.
+	claims, err := r.VerifyAccessToken(context.Background(), token.Token)
+	if err != nil {
+		t.Fatalf("verify access token: %v", err)
+	}
+	if claims.Issuer != "https://acme.example.com" {
+		t.Errorf("iss = %q, want acme's issuer", claims.Issuer)
+	}
+
+	globex := WithTenant(context.Background(), "globex")
+	if _, err := r.VerifyAccessToken(globex, token.Token); !errors.Is(err, ErrInvalidIssuer) {
+		t.Errorf("expected ErrInvalidIssuer for another tenant's token, got %v", err)
+	}
+	if _, err := r.CreateAccessToken(context.Background(), uuid.New(), "alice", nil, uuid.New()); !errors.Is(err, ErrUnknownTenant) {
+		t.Errorf("expected ErrUnknownTenant without a tenant, got %v", err)
+	}
+
+	if err := r.Remove("acme"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := r.VerifyAccessToken(context.Background(), token.Token); !errors.Is(err, ErrUnknownTenant) {
+		t.Errorf("expected ErrUnknownTenant after removal, got %v", err)
+	}
+	if got := r.Tenants(); len(got) != 1 || got[0] != "globex" {
+		t.Errorf("tenants = %v, want [globex]", got)
+	}
+}
@@ -0,0 +1,267 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// policy is the part of the configuration Reload can replace: lifetimes,
+// audiences, limits and signing keys. It is never modified once stored, so
+// a request reading it sees either the old or the new configuration as a
+// whole.
+type policy struct {
+	// tokenKeys are the WithTokenKey keys, kept by Reload.
+	tokenKeys      map[TokenType]TokenKey
+	secretEncoding SecretEncoding
+	audience       string
+	accessExpiry   time.Duration
+	// accessPolicies override accessExpiry, and maxAccessExpiry is the
+	// longest lifetime of a single access token.
+	accessPolicies  []ExpiryPolicy
+	maxAccessExpiry time.Duration
+	// minOverride and maxOverride bound WithExpiry.
+	minOverride time.Duration
+	maxOverride time.Duration
+	// refreshExpiry is the longest lifetime of a single refresh token
+	// across both profiles, used to size revocation records.
+	refreshExpiry   time.Duration
+	standard        refreshProfile
+	remember        refreshProfile
+	notIssuedBefore time.Time
+	maxTokenLength  int
+	repoTimeout     time.Duration
+	// accessMethod signs the tokens that always use the shared secret, such
+	// as MFA challenges and service tokens.
+	accessMethod *jwt.SigningMethodHMAC
+	// accessKey and refreshKey sign access and refresh tokens; see
+	// WithTokenKey.
+	accessKey  tokenKey
+	refreshKey tokenKey
+	// Parsers are safe for concurrent use, so they are built once rather
+	// than on every verification. accessParser and refreshParser verify
+	// tokens signed with accessKey and refreshKey, revokeParser access
+	// tokens without claims validation, and challengeParser and
+	// serviceParser MFA challenges and service tokens.
+	accessParser    *jwt.Parser
+	refreshParser   *jwt.Parser
+	revokeParser    *jwt.Parser
+	challengeParser *jwt.Parser
+	serviceParser   *jwt.Parser
+	strictTyp       bool
+	elevatedExpiry  time.Duration
+	mfaProofMaxAge  time.Duration
+	challengeExpiry time.Duration
+	serviceExpiry   time.Duration
+	downscopeAuds   []string
+	pairingExpiry   time.Duration
+}
+
+// newPolicy builds the policy of the validated cfg with the WithTokenKey
+// keys, verifying token times against clock.
+func newPolicy(cfg Config, keys map[TokenType]TokenKey, clock Clock) (*policy, error) {
+	maxTokenLength := cfg.MaxTokenLength
+	if maxTokenLength == 0 {
+		maxTokenLength = DefaultMaxTokenLength
+	}
+
+	repoTimeout := cfg.RepositoryTimeout
+	if repoTimeout == 0 {
+		repoTimeout = DefaultRepositoryTimeout
+	}
+
+	accessMethod, err := resolveSymmetricMethod("AccessAlgorithm", cfg.AccessAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	refreshMethod, err := resolveSymmetricMethod("RefreshAlgorithm", cfg.RefreshAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	accessKey, refreshKey := secretKey(accessMethod), secretKey(refreshMethod)
+	for tokenType, k := range keys {
+		var target *tokenKey
+		switch tokenType {
+		case AccessToken:
+			target = &accessKey
+		case RefreshToken:
+			target = &refreshKey
+		default:
+			return nil, fmt.Errorf("%s tokens cannot have their own key", tokenType)
+		}
+		if *target, err = newTokenKey(k); err != nil {
+			return nil, fmt.Errorf("%s token key: %w", tokenType, err)
+		}
+	}
+
+	elevatedExpiry := cfg.ElevatedExpiryDuration
+	if elevatedExpiry <= 0 {
+		elevatedExpiry = DefaultElevatedExpiry
+	}
+	mfaProofMaxAge := cfg.MFAProofMaxAge
+	if mfaProofMaxAge <= 0 {
+		mfaProofMaxAge = DefaultMFAProofMaxAge
+	}
+	challengeExpiry := cfg.MFAChallengeExpiryDuration
+	if challengeExpiry <= 0 {
+		challengeExpiry = DefaultMFAChallengeExpiry
+	}
+	serviceExpiry := cfg.ServiceExpiryDuration
+	if serviceExpiry <= 0 {
+		serviceExpiry = DefaultServiceTokenExpiry
+	}
+	pairingExpiry := cfg.PairingExpiryDuration
+	if pairingExpiry <= 0 {
+		pairingExpiry = DefaultPairingExpiry
+	}
+
+	maxAccessExpiry := max(cfg.AccessExpiryDuration, cfg.MaxExpiryOverride)
+	for _, p := range cfg.AccessExpiryPolicies {
+		maxAccessExpiry = max(maxAccessExpiry, p.Expiry)
+	}
+
+	var notIssuedBefore time.Time
+	if cfg.NotIssuedBefore > 0 {
+		notIssuedBefore = time.Unix(cfg.NotIssuedBefore, 0)
+	}
+
+	return &policy{
+		tokenKeys:       keys,
+		secretEncoding:  cfg.SecretEncoding,
+		audience:        cfg.Audience,
+		accessExpiry:    cfg.AccessExpiryDuration,
+		accessPolicies:  cfg.AccessExpiryPolicies,
+		maxAccessExpiry: maxAccessExpiry,
+		minOverride:     cfg.MinExpiryOverride,
+		maxOverride:     cfg.MaxExpiryOverride,
+		refreshExpiry:   max(cfg.RefreshExpiryDuration, cfg.RememberMeExpiryDuration),
+		standard: refreshProfile{
+			expiry:      cfg.RefreshExpiryDuration,
+			maxLifetime: cfg.RefreshMaxLifetimeExpiry,
+			idle:        cfg.RefreshIdleTimeout,
+		},
+		remember: refreshProfile{
+			expiry:      cfg.RememberMeExpiryDuration,
+			maxLifetime: cfg.RememberMeMaxLifetimeExpiry,
+		},
+		notIssuedBefore: notIssuedBefore,
+		maxTokenLength:  maxTokenLength,
+		repoTimeout:     repoTimeout,
+		accessMethod:    accessMethod,
+		accessKey:       accessKey,
+		refreshKey:      refreshKey,
+		accessParser:    newParser(accessKey.method.Alg(), clock),
+		refreshParser:   newParser(refreshKey.method.Alg(), clock),
+		revokeParser:    newParser(accessKey.method.Alg(), clock, jwt.WithoutClaimsValidation()),
+		challengeParser: newParser(accessMethod.Alg(), clock),
+		serviceParser: newParser(accessMethod.Alg(), clock,
+			jwt.WithIssuer(NormalizeIssuer(cfg.Issuer)),
+			jwt.WithAudience(cfg.Audience),
+			jwt.WithExpirationRequired(),
+		),
+		strictTyp:       cfg.StrictTypHeader,
+		elevatedExpiry:  elevatedExpiry,
+		mfaProofMaxAge:  mfaProofMaxAge,
+		challengeExpiry: challengeExpiry,
+		serviceExpiry:   serviceExpiry,
+		downscopeAuds:   cfg.DownscopeAudiences,
+		pairingExpiry:   pairingExpiry,
+	}, nil
+}
+
+// policy returns the configuration in effect.
+func (tm *TokenMaker) policy() *policy {
+	return tm.conf.Load()
+}
+
+// fixedConfig holds the Config fields Reload cannot change, because tokens
+// already issued, stored records or running components depend on them.
+type fixedConfig struct {
+	Issuer                   string
+	RepoCheckOrder           RepoCheckOrder
+	RevocationKey            RevocationKey
+	StoreRawTokens           bool
+	TokenFormat              TokenFormat
+	EncryptionKey            string
+	IssuanceRateLimit        float64
+	IssuanceBurst            int
+	SecurityFailureThreshold int
+	SecurityFailureWindow    time.Duration
+	DisableBackgroundCleanup bool
+}
+
+func fixedFields(cfg Config) fixedConfig {
+	return fixedConfig{
+		Issuer:                   NormalizeIssuer(cfg.Issuer),
+		RepoCheckOrder:           cfg.RepoCheckOrder,
+		RevocationKey:            cfg.RevocationKey,
+		StoreRawTokens:           cfg.StoreRawTokens,
+		TokenFormat:              cfg.TokenFormat,
+		EncryptionKey:            cfg.EncryptionKey,
+		IssuanceRateLimit:        cfg.IssuanceRateLimit,
+		IssuanceBurst:            cfg.IssuanceBurst,
+		SecurityFailureThreshold: cfg.SecurityFailureThreshold,
+		SecurityFailureWindow:    cfg.SecurityFailureWindow,
+		DisableBackgroundCleanup: cfg.DisableBackgroundCleanup,
+	}
+}
+
+// Reload validates cfg and, if it is valid, switches the maker to it
+// without dropping in-flight requests: each request runs entirely under
+// either the old or the new configuration. Lifetimes, audiences,
+// algorithms, limits and the secret can change; Issuer, RepoCheckOrder,
+// RevocationKey, StoreRawTokens, TokenFormat, EncryptionKey,
+// IssuanceRateLimit, IssuanceBurst, the SecurityFailure settings and
+// DisableBackgroundCleanup cannot, and Reload fails if they differ from the
+// maker's Config. opts are WithTokenKey options replacing the keys of their
+// token types; other options are ignored, and keys not replaced are kept.
+//
+// With a SecretProvider the secret is fetched again instead of taken from
+// cfg. A changed secret is rotated as by ReloadSecret, so tokens signed
+// with the replaced one keep verifying until the next rotation.
+func (tm *TokenMaker) Reload(ctx context.Context, cfg Config, opts ...Option) error {
+	if fixedFields(cfg) != tm.fixed {
+		return errors.New("reload: only lifetimes, audiences, algorithms, limits, keys and the secret can change at runtime")
+	}
+	var o makerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	tm.reloadMu.Lock()
+	defer tm.reloadMu.Unlock()
+
+	keys := maps.Clone(tm.policy().tokenKeys)
+	if keys == nil {
+		keys = make(map[TokenType]TokenKey, len(o.tokenKeys))
+	}
+	maps.Copy(keys, o.tokenKeys)
+
+	if tm.secretProvider != nil {
+		secret, err := fetchSecret(ctx, tm.secretProvider)
+		if err != nil {
+			return fmt.Errorf("reload: %w", err)
+		}
+		cfg.Secret = secret
+	}
+	if err := validateConfig(cfg, tm.repo, false); err != nil {
+		return fmt.Errorf("reload: %w", err)
+	}
+	p, err := newPolicy(cfg, keys, tm.clock)
+	if err != nil {
+		return fmt.Errorf("reload: %w", err)
+	}
+	secret, err := decodeSecret(cfg.Secret, cfg.SecretEncoding)
+	if err != nil {
+		return fmt.Errorf("reload: config.Secret: %w", err)
+	}
+
+	tm.rotateSecret(secret)
+	tm.conf.Store(p)
+	return nil
+}
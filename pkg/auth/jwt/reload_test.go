@@ -0,0 +1,94 @@
+package jwt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestReload(t *testing.T) {
+	clock := newFakeClock()
+	cfg := Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}
+	maker, err := NewTokenMaker(cfg, newMockRevocationRepo(), WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+	before, err := maker.CreateAccessToken(ctx, uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	next := cfg
+	next.Secret = "reloaded-secret-must-be-at-least-32-bytes"
+	next.AccessExpiryDuration = 10 * time.Minute
+	if err := maker.Reload(ctx, next); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	after, err := maker.CreateAccessToken(ctx, uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if got := after.ExpiresAt.Sub(clock.Now()); got != 10*time.Minute {
+		t.Errorf("expected the reloaded 10m expiry, got %v", got)
+	}
+	for name, token := range map[string]string{"before": before.Token, "after": after.Token} {
+		if _, err := maker.VerifyAccessToken(ctx, token); err != nil {
+			t.Errorf("verify token issued %s the reload: %v", name, err)
+		}
+	}
+
+	// An invalid or non-reloadable config leaves the maker unchanged.
+	invalid := next
+	invalid.AccessExpiryDuration = -time.Minute
+	if err := maker.Reload(ctx, invalid); err == nil {
+		t.Error("expected an invalid config to be rejected")
+	}
+	fixed := next
+	fixed.Issuer = "other-issuer"
+	if err := maker.Reload(ctx, fixed); err == nil {
+		t.Error("expected an Issuer change to be rejected")
+	}
+	if got := maker.policy().accessExpiry; got != 10*time.Minute {
+		t.Errorf("expected the 10m expiry to survive failed reloads, got %v", got)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, after.Token); err != nil {
+		t.Errorf("verify after failed reloads: %v", err)
+	}
+}
+
+func TestReload_KeepsTokenKeys(t *testing.T) {
+	cfg := Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}
+	refreshSecret := []byte("refresh-secret-must-be-at-least-32-bytes")
+	maker, err := NewTokenMaker(cfg, newMockRevocationRepo(),
+		WithTokenKey(RefreshToken, TokenKey{Algorithm: "HS512", Key: refreshSecret}))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	next := cfg
+	next.AccessExpiryDuration = 30 * time.Minute
+	if err := maker.Reload(context.Background(), next); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if k := maker.keyFor(RefreshToken); k.shared || k.method.Alg() != "HS512" {
+		t.Errorf("expected the refresh token key to be kept, got %s shared=%v", k.method.Alg(), k.shared)
+	}
+
+	err = maker.Reload(context.Background(), next, WithTokenKey(ServiceToken, TokenKey{Algorithm: "HS256", Key: refreshSecret}))
+	if err == nil {
+		t.Error("expected a key for an unsupported token type to be rejected")
+	}
+}
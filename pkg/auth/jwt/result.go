@@ -0,0 +1,103 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ExpiringSoonThreshold is the remaining lifetime below which a verified token
+// carries WarningExpiringSoon.
+const ExpiringSoonThreshold = 60 * time.Second
+
+// nearMaxLifetimeRatio is the fraction of a token's total lifetime after which
+// it carries WarningNearMaxLifetime.
+const nearMaxLifetimeRatio = 0.9
+
+// VerificationWarning is a non-fatal observation about a token that passed
+// verification. Clients can use warnings to refresh proactively.
+type VerificationWarning string
+
+const (
+	// WarningExpiringSoon means the token expires within ExpiringSoonThreshold.
+	WarningExpiringSoon VerificationWarning = "expiring_soon"
+	// WarningNearMaxLifetime means the token has used most of its lifetime.
+	WarningNearMaxLifetime VerificationWarning = "near_max_lifetime"
+	// WarningWithinLeeway means the token is past exp (or before nbf) and was
+	// only accepted because of the clock skew leeway.
+	WarningWithinLeeway VerificationWarning = "within_leeway"
+)
+
+// VerificationResult is the detailed outcome of a successful verification.
+type VerificationResult struct {
+	Claims *TokenClaims
+	// KeyID is the kid header of the token; empty for symmetric tokens
+	// issued by TokenMaker.
+	KeyID string
+	// Algorithm is the alg header of the token.
+	Algorithm string
+	// RemainingLifetime is the time until exp; negative within the leeway.
+	RemainingLifetime time.Duration
+	Warnings          []VerificationWarning
+}
+
+// HasWarning reports whether w was raised during verification.
+func (r *VerificationResult) HasWarning(w VerificationWarning) bool {
+	for _, got := range r.Warnings {
+		if got == w {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyAccessTokenDetailed behaves like VerifyAccessToken but also reports the
+// key ID used, the remaining lifetime, and non-fatal warnings.
+func (tm *TokenMaker) VerifyAccessTokenDetailed(ctx context.Context, tokenString string) (*VerificationResult, error) {
+	token, claims, err := tm.verify(ctx, tokenString, AccessToken, !IsOfflineVerification(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return newVerificationResult(token, claims, tm.clock.Now()), nil
+}
+
+// VerifyAccessTokenDetailed behaves like VerifyAccessToken but also reports the
+// key ID used, the remaining lifetime, and non-fatal warnings.
+func (v *Verifier) VerifyAccessTokenDetailed(ctx context.Context, tokenString string) (*VerificationResult, error) {
+	token, claims, err := v.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDPoP(ctx, v.replay, tokenString, claims, v.clock.Now()); err != nil {
+		return nil, err
+	}
+
+	return newVerificationResult(token, claims, v.clock.Now()), nil
+}
+
+func newVerificationResult(token *jwt.Token, claims *TokenClaims, now time.Time) *VerificationResult {
+	res := &VerificationResult{
+		Claims: claims,
+	}
+	res.KeyID, _ = token.Header["kid"].(string)
+	res.Algorithm, _ = token.Header["alg"].(string)
+
+	// validateClaims guarantees ExpiresAt and NotBefore are set.
+	res.RemainingLifetime = claims.ExpiresAt.Sub(now)
+	if res.RemainingLifetime < 0 || now.Before(claims.NotBefore.Time) {
+		res.Warnings = append(res.Warnings, WarningWithinLeeway)
+	}
+	if res.RemainingLifetime < ExpiringSoonThreshold {
+		res.Warnings = append(res.Warnings, WarningExpiringSoon)
+	}
+	if claims.IssuedAt != nil {
+		total := claims.ExpiresAt.Sub(claims.IssuedAt.Time)
+		if total > 0 && float64(now.Sub(claims.IssuedAt.Time)) >= nearMaxLifetimeRatio*float64(total) {
+			res.Warnings = append(res.Warnings, WarningNearMaxLifetime)
+		}
+	}
+
+	return res
+}
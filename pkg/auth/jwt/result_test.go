@@ -0,0 +1,60 @@
+package jwt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestVerifyAccessTokenDetailed_Warnings(t *testing.T) {
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: 10 * time.Minute,
+	}, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := context.Background()
+	tok, err := maker.CreateAccessToken(ctx, uuid.New(), "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	res, err := maker.VerifyAccessTokenDetailed(ctx, tok.Token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if res.RemainingLifetime != 10*time.Minute {
+		t.Errorf("expected 10m remaining, got %v", res.RemainingLifetime)
+	}
+	if res.Algorithm != "HS256" {
+		t.Errorf("expected HS256, got %q", res.Algorithm)
+	}
+	if len(res.Warnings) != 0 {
+		t.Errorf("expected no warnings for fresh token, got %v", res.Warnings)
+	}
+
+	clock.Advance(9*time.Minute + 30*time.Second)
+	res, err = maker.VerifyAccessTokenDetailed(ctx, tok.Token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !res.HasWarning(WarningExpiringSoon) || !res.HasWarning(WarningNearMaxLifetime) {
+		t.Errorf("expected expiring_soon and near_max_lifetime, got %v", res.Warnings)
+	}
+
+	clock.Advance(45 * time.Second)
+	res, err = maker.VerifyAccessTokenDetailed(ctx, tok.Token)
+	if err != nil {
+		t.Fatalf("verify within leeway: %v", err)
+	}
+	if !res.HasWarning(WarningWithinLeeway) {
+		t.Errorf("expected within_leeway, got %v", res.Warnings)
+	}
+}
@@ -0,0 +1,107 @@
+package jwt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevocationKey selects what the TokenMaker stores in its RevocationRepository.
+type RevocationKey string
+
+const (
+	// RevocationKeyToken stores the token's SHA-256 hash (see HashToken), or
+	// the raw token when Config.StoreRawTokens is set. Default.
+	RevocationKeyToken RevocationKey = "token"
+	// RevocationKeyID stores the token's jti instead. Entries are a fixed 36
+	// bytes regardless of token size, and a token can be revoked with
+	// RevokeTokenByID from an ID recorded in logs, without the token itself.
+	RevocationKeyID RevocationKey = "jti"
+)
+
+// HashToken returns the hex-encoded SHA-256 of token, the key under which the
+// TokenMaker stores token-keyed revocations. Use it to look up or revoke a
+// token in the repository directly.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// revocationKey returns the repository key for a verified token.
+func (tm *TokenMaker) revocationKey(tokenString string, claims *TokenClaims) (string, error) {
+	if tm.revokeBy != RevocationKeyID {
+		return tm.tokenKey(tokenString), nil
+	}
+	if claims.ID == uuid.Nil {
+		return "", ErrMissingClaims
+	}
+	return claims.ID.String(), nil
+}
+
+// rotationKey returns the repository key of the marker RotateRefreshToken
+// leaves for the refresh token with key. It is kept apart from the token's
+// revocation so a replayed, already-rotated token can be told from one
+// revoked by RevokeBatch, an import or an administrator.
+func rotationKey(key string) string {
+	return "rotated:" + key
+}
+
+// unverifiedRevocationKey returns the repository key before the signature is
+// checked, for CheckRepositoryFirst. signed is the JWT tokenString resolves
+// to; see resolve. The jti is read without verification; a forged jti can at
+// worst make a lookup hit, never make a token valid.
+func (tm *TokenMaker) unverifiedRevocationKey(tokenString, signed string) (string, error) {
+	if tm.revokeBy != RevocationKeyID {
+		return tm.tokenKey(tokenString), nil
+	}
+
+	claims := &TokenClaims{}
+	if _, _, err := unverifiedParser.ParseUnverified(signed, claims); err != nil {
+		return "", ErrMalformedToken
+	}
+	return tm.revocationKey(tokenString, claims)
+}
+
+func (tm *TokenMaker) tokenKey(tokenString string) string {
+	if tm.storeRawTokens {
+		return tokenString
+	}
+	return HashToken(tokenString)
+}
+
+// RevokeTokenByID revokes the token of tokenType whose jti is id. It requires
+// Config.RevocationKey "jti". As the token's expiry is unknown, the entry is
+// kept for the full lifetime of that token type plus leeway.
+func (tm *TokenMaker) RevokeTokenByID(ctx context.Context, tokenType TokenType, id uuid.UUID) error {
+	if tm.repo == nil {
+		return ErrRevocationDisabled
+	}
+	if tm.revokeBy != RevocationKeyID {
+		return fmt.Errorf("revoke by id requires config.RevocationKey %q", RevocationKeyID)
+	}
+	if id == uuid.Nil {
+		return fmt.Errorf("token id is required")
+	}
+
+	var ttl time.Duration
+	switch tokenType {
+	case AccessToken:
+		ttl = tm.policy().maxAccessExpiry
+	case RefreshToken:
+		ttl = tm.policy().refreshExpiry
+	default:
+		return fmt.Errorf("invalid token type: %v", tokenType)
+	}
+
+	rctx, cancel := tm.repoContext(ctx)
+	defer cancel()
+	if err := tm.repo.MarkTokenRevoke(rctx, tokenType, id.String(), ttl+DefaultLeeway); err != nil {
+		return err
+	}
+	tm.stats.recordRevoked(tokenType)
+	return nil
+}
@@ -0,0 +1,118 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRevocationKeyID(t *testing.T) {
+	for _, order := range []RepoCheckOrder{CheckSignatureFirst, CheckRepositoryFirst} {
+		repo := newMockRevocationRepo()
+		maker, err := NewTokenMaker(Config{
+			Secret:                "test-secret-must-be-at-least-32-bytes",
+			Issuer:                "test-issuer",
+			Audience:              "test-audience",
+			AccessExpiryDuration:  time.Hour,
+			RefreshExpiryDuration: time.Hour,
+			RepoCheckOrder:        order,
+			RevocationKey:         RevocationKeyID,
+		}, repo)
+		if err != nil {
+			t.Fatalf("create token maker: %v", err)
+		}
+
+		ctx := context.Background()
+		tok, err := maker.CreateAccessToken(ctx, uuid.New(), "test", nil, uuid.New())
+		if err != nil {
+			t.Fatalf("create access token: %v", err)
+		}
+		claims, err := maker.VerifyAccessToken(ctx, tok.Token)
+		if err != nil {
+			t.Fatalf("verify: %v", err)
+		}
+
+		if err := maker.RevokeTokenByID(ctx, AccessToken, claims.ID); err != nil {
+			t.Fatalf("revoke by id: %v", err)
+		}
+		if _, ok := repo.revoked[claims.ID.String()]; !ok || len(repo.revoked) != 1 {
+			t.Errorf("order %q: expected only the jti to be stored, got %v", order, repo.revoked)
+		}
+		if _, err := maker.VerifyAccessToken(ctx, tok.Token); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("order %q: expected ErrTokenRevoked, got %v", order, err)
+		}
+
+		refresh, err := maker.CreateRefreshToken(ctx, uuid.New(), "test", nil, uuid.New())
+		if err != nil {
+			t.Fatalf("create refresh token: %v", err)
+		}
+		if _, err := maker.RotateRefreshToken(ctx, refresh.Token); err != nil {
+			t.Fatalf("rotate: %v", err)
+		}
+		if _, err := maker.RotateRefreshToken(ctx, refresh.Token); !errors.Is(err, ErrTokenRotated) {
+			t.Errorf("order %q: expected ErrTokenRotated on replay, got %v", order, err)
+		}
+	}
+}
+
+func TestRevokeTokenByID_RequiresIDMode(t *testing.T) {
+	maker, err := NewTokenMaker(Config{
+		Secret:   "test-secret-must-be-at-least-32-bytes",
+		Issuer:   "test-issuer",
+		Audience: "test-audience",
+	}, newMockRevocationRepo())
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	if err := maker.RevokeTokenByID(context.Background(), AccessToken, uuid.New()); err == nil {
+		t.Error("expected revoke by id to fail in token mode")
+	}
+
+	if _, err := NewTokenMaker(Config{
+		Secret:        "test-secret-must-be-at-least-32-bytes",
+		Issuer:        "test-issuer",
+		Audience:      "test-audience",
+		RevocationKey: "sha",
+	}, nil); err == nil {
+		t.Error("expected invalid RevocationKey to be rejected")
+	}
+}
+
+func TestRevocationKeyToken_Hashing(t *testing.T) {
+	for _, raw := range []bool{false, true} {
+		repo := newMockRevocationRepo()
+		maker, err := NewTokenMaker(Config{
+			Secret:               "test-secret-must-be-at-least-32-bytes",
+			Issuer:               "test-issuer",
+			Audience:             "test-audience",
+			AccessExpiryDuration: time.Hour,
+			StoreRawTokens:       raw,
+		}, repo)
+		if err != nil {
+			t.Fatalf("create token maker: %v", err)
+		}
+
+		ctx := context.Background()
+		tok, err := maker.CreateAccessToken(ctx, uuid.New(), "test", nil, uuid.New())
+		if err != nil {
+			t.Fatalf("create access token: %v", err)
+		}
+		if err := maker.RevokeAccessToken(ctx, tok.Token); err != nil {
+			t.Fatalf("revoke: %v", err)
+		}
+
+		want := HashToken(tok.Token)
+		if raw {
+			want = tok.Token
+		}
+		if _, ok := repo.revoked[want]; !ok || len(repo.revoked) != 1 {
+			t.Errorf("raw=%v: expected repository key %q, got %v", raw, want, repo.revoked)
+		}
+		if _, err := maker.VerifyAccessToken(ctx, tok.Token); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("raw=%v: expected ErrTokenRevoked, got %v", raw, err)
+		}
+	}
+}
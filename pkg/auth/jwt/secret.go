@@ -0,0 +1,161 @@
+package jwt
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultSecretFetchTimeout bounds the initial SecretProvider fetch in
+// NewTokenMaker.
+const DefaultSecretFetchTimeout = 10 * time.Second
+
+// MinSecretLength is the minimum length in bytes of the decoded shared
+// secret, the output size of HS256.
+const MinSecretLength = 32
+
+// SecretEncoding is how Config.Secret is encoded.
+type SecretEncoding string
+
+const (
+	// SecretEncodingRaw, the default, uses the secret string's bytes.
+	SecretEncodingRaw SecretEncoding = "raw"
+	// SecretEncodingBase64 decodes standard or URL-safe base64, padded or
+	// not.
+	SecretEncodingBase64 SecretEncoding = "base64"
+	// SecretEncodingHex decodes hexadecimal.
+	SecretEncodingHex SecretEncoding = "hex"
+)
+
+// decodeSecret decodes secret and checks it is at least MinSecretLength
+// bytes long.
+func decodeSecret(secret string, enc SecretEncoding) ([]byte, error) {
+	var (
+		key []byte
+		err error
+	)
+	switch enc {
+	case "", SecretEncodingRaw:
+		key = []byte(secret)
+	case SecretEncodingBase64:
+		for _, e := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if key, err = e.DecodeString(secret); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, errors.New("secret is not valid base64")
+		}
+	case SecretEncodingHex:
+		if key, err = hex.DecodeString(secret); err != nil {
+			return nil, errors.New("secret is not valid hex")
+		}
+	default:
+		return nil, fmt.Errorf("secret encoding %q is invalid", enc)
+	}
+	if len(key) < MinSecretLength {
+		return nil, fmt.Errorf("secret must be at least %d bytes, got %d", MinSecretLength, len(key))
+	}
+	return key, nil
+}
+
+// SecretProvider supplies the shared HMAC secret, e.g. from AWS Secrets
+// Manager or Vault, so it does not have to live in a config file. The
+// secret is decoded with Config.SecretEncoding.
+type SecretProvider interface {
+	Secret(ctx context.Context) (string, error)
+}
+
+// SecretProviderFunc adapts a function to SecretProvider.
+type SecretProviderFunc func(ctx context.Context) (string, error)
+
+func (f SecretProviderFunc) Secret(ctx context.Context) (string, error) { return f(ctx) }
+
+// WithSecretProvider fetches the shared secret from p when the maker is
+// created, replacing Config.Secret, and again on every ReloadSecret. Since
+// Config.Secret may then be empty, validate such configs with
+// NewTokenMaker rather than ValidateConfig.
+func WithSecretProvider(p SecretProvider) Option {
+	return func(o *makerOptions) { o.secretProvider = p }
+}
+
+// secretState is the shared secret in use and the one it replaced, which
+// keeps verifying tokens issued before the last ReloadSecret.
+type secretState struct {
+	current  []byte
+	previous []byte
+}
+
+// fetchSecret returns the secret from p, or an error if it is empty.
+func fetchSecret(ctx context.Context, p SecretProvider) (string, error) {
+	secret, err := p.Secret(ctx)
+	if err != nil {
+		return "", fmt.Errorf("fetch secret: %w", err)
+	}
+	if secret == "" {
+		return "", errors.New("fetch secret: provider returned an empty secret")
+	}
+	return secret, nil
+}
+
+// ReloadSecret fetches the shared secret from the SecretProvider again,
+// e.g. when notified of a rotation. New tokens are signed with the fetched
+// secret; tokens signed with the replaced one keep verifying until the next
+// reload, so run reloads at least the longest token lifetime apart. It is a
+// no-op when the secret did not change.
+func (tm *TokenMaker) ReloadSecret(ctx context.Context) error {
+	if tm.secretProvider == nil {
+		return errors.New("no secret provider configured")
+	}
+	secret, err := fetchSecret(ctx, tm.secretProvider)
+	if err != nil {
+		return err
+	}
+	tm.reloadMu.Lock()
+	defer tm.reloadMu.Unlock()
+	key, err := decodeSecret(secret, tm.policy().secretEncoding)
+	if err != nil {
+		return fmt.Errorf("fetch secret: %w", err)
+	}
+	tm.rotateSecret(key)
+	return nil
+}
+
+// rotateSecret makes key the current secret, keeping the replaced one for
+// verification, unless key is already current. The caller holds reloadMu.
+func (tm *TokenMaker) rotateSecret(key []byte) {
+	old := tm.secrets.Load()
+	if hmac.Equal(old.current, key) {
+		return
+	}
+	tm.secrets.Store(&secretState{current: key, previous: old.current})
+}
+
+// secret returns the secret new tokens are signed with.
+func (tm *TokenMaker) secret() []byte {
+	return tm.secrets.Load().current
+}
+
+// secretVerificationKey returns the key verifying tokens signed with the
+// shared secret: the current secret and, after a reload, the previous one.
+func (tm *TokenMaker) secretVerificationKey() any {
+	s := tm.secrets.Load()
+	if s.previous == nil {
+		return s.current
+	}
+	return jwt.VerificationKeySet{Keys: []jwt.VerificationKey{s.current, s.previous}}
+}
+
+// newSecrets returns the initial secrets holder.
+func newSecrets(secret []byte) *atomic.Pointer[secretState] {
+	p := new(atomic.Pointer[secretState])
+	p.Store(&secretState{current: secret})
+	return p
+}
@@ -0,0 +1,120 @@
+package jwt
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWithSecretProvider(t *testing.T) {
+	secret := "provided-secret-must-be-at-least-32-bytes"
+	provider := SecretProviderFunc(func(context.Context) (string, error) { return secret, nil })
+	maker, err := NewTokenMaker(Config{
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, newMockRevocationRepo(), WithSecretProvider(provider))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := context.Background()
+	before, err := maker.CreateAccessToken(ctx, uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	// After a rotation, new tokens use the new secret and old ones keep
+	// verifying until the next one.
+	secret = "rotated-secret-must-be-at-least-32-bytes"
+	if err := maker.ReloadSecret(ctx); err != nil {
+		t.Fatalf("reload secret: %v", err)
+	}
+	if string(maker.secret()) != secret {
+		t.Errorf("expected the rotated secret in use")
+	}
+	after, err := maker.CreateAccessToken(ctx, uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	for name, token := range map[string]string{"before": before.Token, "after": after.Token} {
+		if _, err := maker.VerifyAccessToken(ctx, token); err != nil {
+			t.Errorf("verify token issued %s the rotation: %v", name, err)
+		}
+	}
+
+	secret = "second-rotation-must-be-at-least-32-bytes"
+	if err := maker.ReloadSecret(ctx); err != nil {
+		t.Fatalf("reload secret: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, before.Token); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature two rotations later, got %v", err)
+	}
+}
+
+func TestWithSecretProvider_Errors(t *testing.T) {
+	cfg := Config{Issuer: "test-issuer", Audience: "test-audience"}
+	for name, provider := range map[string]SecretProviderFunc{
+		"error": func(context.Context) (string, error) { return "", errors.New("vault sealed") },
+		"empty": func(context.Context) (string, error) { return "", nil },
+	} {
+		if _, err := NewTokenMaker(cfg, nil, WithSecretProvider(provider)); err == nil {
+			t.Errorf("%s: expected NewTokenMaker to fail", name)
+		}
+	}
+
+	maker, err := NewTokenMaker(Config{Secret: "test-secret-must-be-at-least-32-bytes", Issuer: "i", Audience: "a"}, nil)
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	if err := maker.ReloadSecret(context.Background()); err == nil {
+		t.Error("expected ReloadSecret to fail without a provider")
+	}
+}
+
+func TestSecretEncoding(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	tests := []struct {
+		name    string
+		secret  string
+		enc     SecretEncoding
+		wantErr bool
+	}{
+		{"raw", string(key), "", false},
+		{"base64", base64.StdEncoding.EncodeToString(key), SecretEncodingBase64, false},
+		{"base64url unpadded", base64.RawURLEncoding.EncodeToString(key), SecretEncodingBase64, false},
+		{"hex", hex.EncodeToString(key), SecretEncodingHex, false},
+		{"short raw", "too-short", SecretEncodingRaw, true},
+		// Hex of 16 bytes is 32 characters: long enough raw, too short decoded.
+		{"short hex", hex.EncodeToString(key[:16]), SecretEncodingHex, true},
+		{"bad hex", "zz" + hex.EncodeToString(key), SecretEncodingHex, true},
+		{"bad base64", "not base64!" + string(key), SecretEncodingBase64, true},
+		{"unknown encoding", string(key), "rot13", true},
+	}
+	for _, tt := range tests {
+		maker, err := NewTokenMaker(Config{
+			Secret:         tt.secret,
+			SecretEncoding: tt.enc,
+			Issuer:         "test-issuer",
+			Audience:       "test-audience",
+		}, nil)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: create token maker: %v", tt.name, err)
+			continue
+		}
+		if string(maker.secret()) != string(key) {
+			t.Errorf("%s: decoded secret %q, want %q", tt.name, maker.secret(), key)
+		}
+	}
+}
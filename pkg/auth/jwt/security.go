@@ -0,0 +1,150 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Security event defaults.
+const (
+	// DefaultFailureThreshold is how many failed verifications of one
+	// user's tokens within DefaultFailureWindow raise
+	// SecurityRepeatedFailures.
+	DefaultFailureThreshold = 5
+	DefaultFailureWindow    = 5 * time.Minute
+)
+
+// maxTrackedFailures bounds the per-user failure counters; expired counters
+// are dropped once it is reached.
+const maxTrackedFailures = 10_000
+
+// SecurityEventKind classifies a SecurityEvent.
+type SecurityEventKind string
+
+const (
+	// SecurityTokenReuse is raised when a rotated refresh token is presented
+	// again; see ReuseHandler.
+	SecurityTokenReuse SecurityEventKind = "token_reuse"
+	// SecurityInvalidatedToken is raised when a token issued before an
+	// invalidation cutoff is presented, e.g. one from before a password
+	// change.
+	SecurityInvalidatedToken SecurityEventKind = "invalidated_token"
+	// SecurityRepeatedFailures is raised when a user's tokens failed
+	// verification the threshold number of times within the window.
+	SecurityRepeatedFailures SecurityEventKind = "repeated_failures"
+)
+
+// SecurityEvent describes a suspicious use of a token.
+type SecurityEvent struct {
+	Kind      SecurityEventKind
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	TokenID   uuid.UUID
+	TokenType TokenType
+	// Failures is the number of failures within the window for
+	// SecurityRepeatedFailures.
+	Failures int
+	// Err is the verification error that raised the event.
+	Err error
+	At  time.Time
+}
+
+// SecurityEventHandler receives security events, typically to forward them
+// to SOC tooling. It runs synchronously inside verification, so it should
+// return quickly, e.g. by handing the event to a buffered channel.
+type SecurityEventHandler func(ctx context.Context, ev SecurityEvent)
+
+// WithSecurityEvents reports security events to h. Only failures of tokens
+// with a valid signature are attributed to a user and counted towards
+// SecurityRepeatedFailures; see Config.SecurityFailureThreshold.
+func WithSecurityEvents(h SecurityEventHandler) Option {
+	return func(o *makerOptions) { o.securityEvents = h }
+}
+
+// failureCounter counts verification failures per user in fixed windows.
+type failureCounter struct {
+	threshold int
+	window    time.Duration
+
+	mu    sync.Mutex
+	users map[uuid.UUID]failureWindow
+}
+
+type failureWindow struct {
+	start time.Time
+	count int
+}
+
+func newFailureCounter(threshold int, window time.Duration) *failureCounter {
+	return &failureCounter{threshold: threshold, window: window, users: make(map[uuid.UUID]failureWindow)}
+}
+
+// add records a failure of userID at now and returns the failures in the
+// current window if they just reached the threshold, or zero.
+func (c *failureCounter) add(userID uuid.UUID, now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w, ok := c.users[userID]
+	if !ok || now.Sub(w.start) >= c.window {
+		if !ok && len(c.users) >= maxTrackedFailures {
+			for id, other := range c.users {
+				if now.Sub(other.start) >= c.window {
+					delete(c.users, id)
+				}
+			}
+		}
+		w = failureWindow{start: now}
+	}
+	w.count++
+	c.users[userID] = w
+	if w.count == c.threshold {
+		return w.count
+	}
+	return 0
+}
+
+// reportFailure raises the security events for claims' token failing
+// verification with err.
+func (tm *TokenMaker) reportFailure(ctx context.Context, tokenType TokenType, claims *TokenClaims, err error) {
+	if tm.securityEvents == nil || claims.Subject == uuid.Nil {
+		return
+	}
+	now := tm.clock.Now()
+	ev := SecurityEvent{
+		UserID:    claims.Subject,
+		SessionID: claims.SessionID,
+		TokenID:   claims.ID,
+		TokenType: tokenType,
+		Err:       err,
+		At:        now,
+	}
+	if errors.Is(err, ErrTokenInvalidated) {
+		ev.Kind = SecurityInvalidatedToken
+		tm.securityEvents(ctx, ev)
+	}
+	if n := tm.failures.add(claims.Subject, now); n > 0 {
+		ev.Kind = SecurityRepeatedFailures
+		ev.Failures = n
+		tm.securityEvents(ctx, ev)
+	}
+}
+
+// reportReuse raises SecurityTokenReuse for a replayed refresh token.
+func (tm *TokenMaker) reportReuse(ctx context.Context, claims *TokenClaims) {
+	if tm.securityEvents == nil {
+		return
+	}
+	tm.securityEvents(ctx, SecurityEvent{
+		Kind:      SecurityTokenReuse,
+		UserID:    claims.Subject,
+		SessionID: claims.SessionID,
+		TokenID:   claims.ID,
+		TokenType: RefreshToken,
+		Err:       ErrTokenRotated,
+		At:        tm.clock.Now(),
+	})
+}
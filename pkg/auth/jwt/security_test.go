@@ -0,0 +1,81 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSecurityEvents(t *testing.T) {
+	clock := newFakeClock()
+	src := &staticInvalidation{users: make(map[uuid.UUID]time.Time)}
+	var events []SecurityEvent
+	maker, err := NewTokenMaker(Config{
+		Secret:                   "test-secret-must-be-at-least-32-bytes",
+		Issuer:                   "test-issuer",
+		Audience:                 "test-audience",
+		AccessExpiryDuration:     time.Hour,
+		RefreshExpiryDuration:    24 * time.Hour,
+		SecurityFailureThreshold: 3,
+	}, newMockRevocationRepo(), WithClock(clock), WithInvalidationSource(src), WithSecurityEvents(func(_ context.Context, ev SecurityEvent) {
+		events = append(events, ev)
+	}))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+	kinds := func() []SecurityEventKind {
+		var out []SecurityEventKind
+		for _, ev := range events {
+			out = append(out, ev.Kind)
+		}
+		events = nil
+		return out
+	}
+
+	userID, sessionID := uuid.New(), uuid.New()
+	refresh, err := maker.CreateRefreshToken(ctx, userID, "alice", nil, sessionID)
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if _, err := maker.RotateRefreshToken(ctx, refresh.Token); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if _, err := maker.RotateRefreshToken(ctx, refresh.Token); !errors.Is(err, ErrTokenRotated) {
+		t.Fatalf("replay: err = %v, want ErrTokenRotated", err)
+	}
+	if got := kinds(); len(got) != 1 || got[0] != SecurityTokenReuse {
+		t.Errorf("after replay: events = %v, want [token_reuse]", got)
+	}
+
+	other := uuid.New()
+	access, err := maker.CreateAccessToken(ctx, other, "bob", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	clock.Advance(time.Second)
+	src.users[other] = clock.Now()
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); !errors.Is(err, ErrTokenInvalidated) {
+		t.Fatalf("verify: err = %v, want ErrTokenInvalidated", err)
+	}
+	if got := kinds(); len(got) != 1 || got[0] != SecurityInvalidatedToken {
+		t.Errorf("after invalidation: events = %v, want [invalidated_token]", got)
+	}
+
+	maker.VerifyAccessToken(ctx, access.Token)
+	maker.VerifyAccessToken(ctx, access.Token)
+	if len(events) != 3 || events[2].Kind != SecurityRepeatedFailures || events[2].Failures != 3 || events[2].UserID != other {
+		t.Errorf("events = %+v, want repeated_failures after 3 failures", events)
+	}
+	events = nil
+
+	if _, err := maker.VerifyAccessToken(ctx, "garbage"); err == nil {
+		t.Fatal("expected garbage to be rejected")
+	}
+	if len(events) != 0 {
+		t.Errorf("unattributable failure raised %+v", events)
+	}
+}
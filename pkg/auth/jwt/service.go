@@ -0,0 +1,167 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// ServiceToken is the token type of service-to-service tokens.
+const ServiceToken TokenType = "service"
+
+// ServiceTokenTyp is the typ header of service tokens.
+const ServiceTokenTyp = "svc+jwt"
+
+// DefaultServiceTokenExpiry is the lifetime of service tokens when
+// Config.ServiceExpiryDuration is zero.
+const DefaultServiceTokenExpiry = 10 * time.Minute
+
+// ServiceClaims are the claims of a token an internal service presents to
+// another. Unlike TokenClaims they name a service, not a user, and carry no
+// session, username or roles.
+type ServiceClaims struct {
+	ID uuid.UUID `json:"jti"`
+	// Service identifies the calling service, e.g. "billing-worker".
+	Service   string           `json:"sub"`
+	Scopes    []string         `json:"scp,omitempty"`
+	Issuer    string           `json:"iss"`
+	Audience  []string         `json:"aud"`
+	IssuedAt  *jwt.NumericDate `json:"iat"`
+	ExpiresAt *jwt.NumericDate `json:"exp"`
+	NotBefore *jwt.NumericDate `json:"nbf"`
+	TokenType TokenType        `json:"typ"`
+}
+
+func (c *ServiceClaims) GetExpirationTime() (*jwt.NumericDate, error) { return c.ExpiresAt, nil }
+func (c *ServiceClaims) GetIssuedAt() (*jwt.NumericDate, error)       { return c.IssuedAt, nil }
+func (c *ServiceClaims) GetNotBefore() (*jwt.NumericDate, error)      { return c.NotBefore, nil }
+func (c *ServiceClaims) GetIssuer() (string, error)                   { return c.Issuer, nil }
+func (c *ServiceClaims) GetAudience() (jwt.ClaimStrings, error)       { return c.Audience, nil }
+func (c *ServiceClaims) GetSubject() (string, error)                  { return c.Service, nil }
+
+// HasScopes reports whether the token was granted every scope in scopes.
+func (c *ServiceClaims) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateServiceToken issues a token for serviceID limited to scopes, valid
+// for Config.ServiceExpiryDuration. Service tokens are only accepted by
+// VerifyServiceToken, and user tokens never are.
+func (tm *TokenMaker) CreateServiceToken(ctx context.Context, serviceID string, scopes []string) (*TokenResponse, error) {
+	if serviceID == "" {
+		return nil, fmt.Errorf("service id is required")
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("service token scopes are required")
+	}
+	now := tm.clock.Now()
+	claims := ServiceClaims{
+		ID:        uuid.New(),
+		Service:   serviceID,
+		Scopes:    scopes,
+		Issuer:    tm.issuer,
+		Audience:  []string{tm.policy().audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(tm.policy().serviceExpiry)),
+		NotBefore: jwt.NewNumericDate(now),
+		TokenType: ServiceToken,
+	}
+
+	token := jwt.NewWithClaims(tm.policy().accessMethod, &claims)
+	token.Header["typ"] = ServiceTokenTyp
+	signed, err := tm.signToken(ctx, ServiceToken, token, tm.secret())
+	if err != nil {
+		return nil, fmt.Errorf("sign token: %w", err)
+	}
+	if tm.cipher != nil {
+		if signed, err = tm.cipher.encrypt(signed); err != nil {
+			return nil, fmt.Errorf("encrypt token: %w", err)
+		}
+	}
+	tm.stats.recordIssued(ServiceToken)
+	return &TokenResponse{Token: signed, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
+
+// VerifyServiceToken verifies a token from CreateServiceToken. Service
+// tokens are short-lived and not revocable one by one; the global
+// invalidation cutoffs still apply.
+func (tm *TokenMaker) VerifyServiceToken(ctx context.Context, tokenString string) (*ServiceClaims, error) {
+	if len(tokenString) > tm.policy().maxTokenLength {
+		return nil, ErrTokenTooLarge
+	}
+	signed := tokenString
+	if tm.cipher != nil {
+		var err error
+		if signed, err = tm.cipher.decrypt(tokenString); err != nil {
+			return nil, err
+		}
+	}
+
+	p := tm.policy()
+	alg := p.accessMethod.Alg()
+	token, err := p.serviceParser.ParseWithClaims(signed, &ServiceClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if err := checkAlgHeader(token, alg); err != nil {
+			return nil, err
+		}
+		if typ, _ := token.Header["typ"].(string); typ != ServiceTokenTyp {
+			return nil, ErrWrongTokenType
+		}
+		return tm.secretVerificationKey(), nil
+	})
+	if err != nil {
+		switch {
+		case token != nil && checkAlgHeader(token, alg) != nil:
+			return nil, ErrUnexpectedAlgorithm
+		case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+			return nil, ErrInvalidIssuer
+		case errors.Is(err, jwt.ErrTokenInvalidAudience):
+			return nil, ErrInvalidAudience
+		case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+			return nil, ErrMissingClaims
+		}
+		return nil, classifyParseError(err)
+	}
+
+	claims, ok := token.Claims.(*ServiceClaims)
+	if !ok || claims.Service == "" || claims.IssuedAt == nil {
+		return nil, ErrMalformedToken
+	}
+	if claims.TokenType != ServiceToken {
+		return nil, ErrWrongTokenType
+	}
+	if err := tm.checkGlobalInvalidation(ctx, claims.IssuedAt.Time); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkGlobalInvalidation rejects tokens issued at iat before the static
+// config cutoff or the global cutoff of the InvalidationSource.
+func (tm *TokenMaker) checkGlobalInvalidation(ctx context.Context, iat time.Time) error {
+	if issuedBeforeCutoff(iat, tm.policy().notIssuedBefore) {
+		return ErrTokenInvalidated
+	}
+	if tm.invalidation == nil {
+		return nil
+	}
+	rctx, cancel := tm.repoContext(ctx)
+	defer cancel()
+	global, err := tm.invalidation.GlobalNotIssuedBefore(rctx)
+	if err != nil {
+		return fmt.Errorf("check invalidation: %w", err)
+	}
+	if issuedBeforeCutoff(iat, global) {
+		return ErrTokenInvalidated
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestServiceToken(t *testing.T) {
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+
+	res, err := maker.CreateServiceToken(ctx, "billing-worker", []string{"goals:read", "users:read"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if want := clock.Now().Add(DefaultServiceTokenExpiry); !res.ExpiresAt.Equal(want) {
+		t.Errorf("expires at %v, want %v", res.ExpiresAt, want)
+	}
+	claims, err := maker.VerifyServiceToken(ctx, res.Token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.Service != "billing-worker" || !claims.HasScopes("goals:read", "users:read") || claims.HasScopes("goals:write") {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	if _, err := maker.VerifyAccessToken(ctx, res.Token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("service token as access token: err = %v, want ErrInvalidToken", err)
+	}
+	access, err := maker.CreateAccessToken(ctx, uuid.New(), "alice", []string{"admin"}, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if _, err := maker.VerifyServiceToken(ctx, access.Token); !errors.Is(err, ErrWrongTokenType) {
+		t.Errorf("access token as service token: err = %v, want ErrWrongTokenType", err)
+	}
+
+	if _, err := maker.CreateServiceToken(ctx, "", []string{"goals:read"}); err == nil {
+		t.Error("expected missing service id to be rejected")
+	}
+	if _, err := maker.CreateServiceToken(ctx, "billing-worker", nil); err == nil {
+		t.Error("expected missing scopes to be rejected")
+	}
+
+	clock.Advance(DefaultServiceTokenExpiry + 2*DefaultLeeway)
+	if _, err := maker.VerifyServiceToken(ctx, res.Token); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expired: err = %v, want ErrTokenExpired", err)
+	}
+}
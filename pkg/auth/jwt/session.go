@@ -0,0 +1,69 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SessionRevocationRepository is an optional extension of
+// RevocationRepository that revokes every token of a session (sid claim) in
+// one operation, including refresh tokens the caller never saw.
+//
+// Decorators that cannot reach a session-capable store return an error
+// wrapping errors.ErrUnsupported; the TokenMaker then skips the session check.
+type SessionRevocationRepository interface {
+	MarkSessionRevoked(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error
+	IsSessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error)
+}
+
+// RevokeSession revokes every access and refresh token of sessionID. Since
+// RotateRefreshToken keeps the session ID, the revocation is kept for the
+// refresh token lifetime plus leeway, which also covers any token rotated
+// from the session before the call.
+func (tm *TokenMaker) RevokeSession(ctx context.Context, sessionID uuid.UUID) error {
+	if tm.repo == nil {
+		return ErrRevocationDisabled
+	}
+	sessions, ok := tm.repo.(SessionRevocationRepository)
+	if !ok {
+		return fmt.Errorf("revoke session: %w", errors.ErrUnsupported)
+	}
+	if sessionID == uuid.Nil {
+		return fmt.Errorf("session id is required")
+	}
+
+	ttl := max(tm.policy().maxAccessExpiry, tm.policy().refreshExpiry) + DefaultLeeway
+	rctx, cancel := tm.repoContext(ctx)
+	defer cancel()
+	if err := sessions.MarkSessionRevoked(rctx, sessionID, ttl); err != nil {
+		return err
+	}
+	tm.stats.recordSessionRevoked()
+	return nil
+}
+
+// checkSessionRevoked rejects tokens whose session was revoked.
+func (tm *TokenMaker) checkSessionRevoked(ctx context.Context, claims *TokenClaims) error {
+	sessions, ok := tm.repo.(SessionRevocationRepository)
+	if !ok || claims.SessionID == uuid.Nil {
+		return nil
+	}
+
+	rctx, cancel := tm.repoContext(ctx)
+	revoked, err := sessions.IsSessionRevoked(rctx, claims.SessionID)
+	cancel()
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("check session revocation: %w", err)
+	}
+	if revoked {
+		return ErrSessionRevoked
+	}
+	return nil
+}
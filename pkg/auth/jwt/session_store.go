@@ -0,0 +1,128 @@
+package jwt
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ClientInfo is optional client metadata recorded with a session.
+type ClientInfo struct {
+	IP         string `json:"ip,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+	DeviceName string `json:"device_name,omitempty"`
+	// ClientID identifies the application, e.g. an OAuth client, for
+	// Config.AccessExpiryPolicies.
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// SessionInfo describes a session for "manage your devices" UIs.
+type SessionInfo struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+	// DeviceID is the device bound with WithDevice, if any.
+	DeviceID uuid.UUID `json:"device_id,omitempty"`
+	ClientInfo
+	CreatedAt time.Time `json:"created_at"`
+	// LastSeenAt is the time of the last refresh token rotation.
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// SessionStore records sessions and lists them per user. Entries only need
+// to live as long as the session's refresh token.
+type SessionStore interface {
+	// SaveSession stores info, replacing any earlier record of the session.
+	SaveSession(ctx context.Context, info SessionInfo, ttl time.Duration) error
+	// TouchSession sets LastSeenAt and extends the record to ttl. It is a
+	// no-op when the session is unknown.
+	TouchSession(ctx context.Context, userID, sessionID uuid.UUID, seenAt time.Time, ttl time.Duration) error
+	// ListSessions returns the unexpired sessions of userID in any order.
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]SessionInfo, error)
+}
+
+// WithSessionStore records a session with CreateRefreshToken and keeps it
+// current on every RotateRefreshToken, enabling ListSessions.
+func WithSessionStore(s SessionStore) Option {
+	return func(o *makerOptions) { o.sessions = s }
+}
+
+type clientInfoKey struct{}
+
+// WithClientInfo attaches client metadata to ctx. CreateRefreshToken records
+// it with the session when a SessionStore is configured.
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+// ClientInfoFrom returns the client metadata attached by WithClientInfo.
+func ClientInfoFrom(ctx context.Context) (ClientInfo, bool) {
+	info, ok := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info, ok
+}
+
+// ListSessions returns the active sessions of userID, most recently used
+// first. Sessions revoked with RevokeSession are left out.
+func (tm *TokenMaker) ListSessions(ctx context.Context, userID uuid.UUID) ([]SessionInfo, error) {
+	if tm.sessions == nil {
+		return nil, fmt.Errorf("list sessions: %w", errors.ErrUnsupported)
+	}
+
+	all, err := tm.sessions.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+
+	active := all[:0]
+	for _, s := range all {
+		err := tm.checkSessionRevoked(ctx, &TokenClaims{SessionID: s.ID})
+		if errors.Is(err, ErrSessionRevoked) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list sessions: %w", err)
+		}
+		active = append(active, s)
+	}
+	slices.SortFunc(active, func(a, b SessionInfo) int {
+		return cmp.Compare(b.LastSeenAt.UnixNano(), a.LastSeenAt.UnixNano())
+	})
+	return active, nil
+}
+
+// saveSession records a new session.
+func (tm *TokenMaker) saveSession(ctx context.Context, userID, sessionID, deviceID uuid.UUID, now time.Time, ttl time.Duration) error {
+	if tm.sessions == nil || sessionID == uuid.Nil {
+		return nil
+	}
+
+	client, _ := ClientInfoFrom(ctx)
+	info := SessionInfo{
+		ID:         sessionID,
+		UserID:     userID,
+		DeviceID:   deviceID,
+		ClientInfo: client,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	if err := tm.sessions.SaveSession(ctx, info, ttl); err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	return nil
+}
+
+// touchSession marks a session as used by a refresh token rotation.
+func (tm *TokenMaker) touchSession(ctx context.Context, userID, sessionID uuid.UUID, now time.Time, ttl time.Duration) error {
+	if tm.sessions == nil || sessionID == uuid.Nil {
+		return nil
+	}
+
+	if err := tm.sessions.TouchSession(ctx, userID, sessionID, now, ttl); err != nil {
+		return fmt.Errorf("touch session: %w", err)
+	}
+	return nil
+}
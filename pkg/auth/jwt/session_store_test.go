@@ -0,0 +1,104 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// mapSessionStore is a SessionStore that ignores TTLs.
+type mapSessionStore struct {
+	sessions map[uuid.UUID]SessionInfo
+}
+
+func (s *mapSessionStore) SaveSession(_ context.Context, info SessionInfo, _ time.Duration) error {
+	s.sessions[info.ID] = info
+	return nil
+}
+
+func (s *mapSessionStore) TouchSession(_ context.Context, _, sessionID uuid.UUID, seenAt time.Time, _ time.Duration) error {
+	info, ok := s.sessions[sessionID]
+	if ok {
+		info.LastSeenAt = seenAt
+		s.sessions[sessionID] = info
+	}
+	return nil
+}
+
+func (s *mapSessionStore) ListSessions(_ context.Context, userID uuid.UUID) ([]SessionInfo, error) {
+	var out []SessionInfo
+	for _, info := range s.sessions {
+		if info.UserID == userID {
+			out = append(out, info)
+		}
+	}
+	return out, nil
+}
+
+func TestListSessions(t *testing.T) {
+	repo := &sessionRepo{mockRevocationRepo: newMockRevocationRepo(), sessions: make(map[uuid.UUID]struct{})}
+	store := &mapSessionStore{sessions: make(map[uuid.UUID]SessionInfo)}
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: 24 * time.Hour,
+	}, repo, WithSessionStore(store), WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	userID := uuid.New()
+	phone, laptop, tablet := uuid.New(), uuid.New(), uuid.New()
+	ctx := WithClientInfo(context.Background(), ClientInfo{IP: "10.0.0.1", UserAgent: "app/1.0", DeviceName: "phone"})
+	phoneToken, err := maker.CreateRefreshToken(ctx, userID, "alice", nil, phone)
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	clock.Advance(time.Minute)
+	if _, err := maker.CreateRefreshToken(context.Background(), userID, "alice", nil, laptop); err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if _, err := maker.CreateRefreshToken(context.Background(), userID, "alice", nil, tablet); err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := maker.RotateRefreshToken(context.Background(), phoneToken.Token); err != nil {
+		t.Fatalf("rotate refresh token: %v", err)
+	}
+	if err := maker.RevokeSession(context.Background(), tablet); err != nil {
+		t.Fatalf("revoke session: %v", err)
+	}
+
+	sessions, err := maker.ListSessions(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 active sessions, got %d", len(sessions))
+	}
+	if sessions[0].ID != phone || sessions[1].ID != laptop {
+		t.Errorf("expected phone then laptop, got %v then %v", sessions[0].ID, sessions[1].ID)
+	}
+	if sessions[0].DeviceName != "phone" || sessions[0].IP != "10.0.0.1" || sessions[0].UserAgent != "app/1.0" {
+		t.Errorf("unexpected client info %+v", sessions[0].ClientInfo)
+	}
+	if !sessions[0].LastSeenAt.After(sessions[0].CreatedAt) {
+		t.Errorf("expected rotation to update LastSeenAt, got created %v last seen %v", sessions[0].CreatedAt, sessions[0].LastSeenAt)
+	}
+}
+
+func TestListSessions_Unsupported(t *testing.T) {
+	maker := newSessionTestMaker(t, newMockRevocationRepo())
+
+	_, err := maker.ListSessions(context.Background(), uuid.New())
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected errors.ErrUnsupported, got %v", err)
+	}
+}
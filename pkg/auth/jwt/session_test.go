@@ -0,0 +1,87 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sessionRepo adds SessionRevocationRepository to mockRevocationRepo.
+type sessionRepo struct {
+	*mockRevocationRepo
+	sessions map[uuid.UUID]struct{}
+}
+
+func (r *sessionRepo) MarkSessionRevoked(_ context.Context, sessionID uuid.UUID, _ time.Duration) error {
+	r.sessions[sessionID] = struct{}{}
+	return nil
+}
+
+func (r *sessionRepo) IsSessionRevoked(_ context.Context, sessionID uuid.UUID) (bool, error) {
+	_, ok := r.sessions[sessionID]
+	return ok, nil
+}
+
+func newSessionTestMaker(t *testing.T, repo RevocationRepository) *TokenMaker {
+	t.Helper()
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: 24 * time.Hour,
+	}, repo)
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	return maker
+}
+
+func TestRevokeSession(t *testing.T) {
+	repo := &sessionRepo{mockRevocationRepo: newMockRevocationRepo(), sessions: make(map[uuid.UUID]struct{})}
+	maker := newSessionTestMaker(t, repo)
+	ctx := context.Background()
+
+	userID, revokedSID, otherSID := uuid.New(), uuid.New(), uuid.New()
+	access, err := maker.CreateAccessToken(ctx, userID, "alice", nil, revokedSID)
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	refresh, err := maker.CreateRefreshToken(ctx, userID, "alice", nil, revokedSID)
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	other, err := maker.CreateAccessToken(ctx, userID, "alice", nil, otherSID)
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	if err := maker.RevokeSession(ctx, revokedSID); err != nil {
+		t.Fatalf("revoke session: %v", err)
+	}
+
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("expected ErrSessionRevoked for access token, got %v", err)
+	}
+	if _, err := maker.VerifyRefreshToken(ctx, refresh.Token); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("expected ErrSessionRevoked for refresh token, got %v", err)
+	}
+	if _, err := maker.RotateRefreshToken(ctx, refresh.Token); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("expected rotation to fail with ErrSessionRevoked, got %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, other.Token); err != nil {
+		t.Errorf("expected token of another session to stay valid, got %v", err)
+	}
+}
+
+func TestRevokeSession_Unsupported(t *testing.T) {
+	maker := newSessionTestMaker(t, newMockRevocationRepo())
+
+	err := maker.RevokeSession(context.Background(), uuid.New())
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected errors.ErrUnsupported, got %v", err)
+	}
+}
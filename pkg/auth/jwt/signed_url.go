@@ -0,0 +1,159 @@
+package jwt
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignedURLParam is the query parameter carrying the token of a signed URL.
+const SignedURLParam = "sig"
+
+// DefaultSignedURLMaxExpiry is used when SignedURLConfig.MaxExpiry is zero.
+const DefaultSignedURLMaxExpiry = time.Hour
+
+// SignedURLConfig configures a URLSigner.
+type SignedURLConfig struct {
+	// Secret should differ from the access token secret.
+	Secret string `json:",optional" secret:"true"`
+	// Algorithm is HS256 (default), HS384 or HS512.
+	Algorithm string `json:",optional"`
+	// MaxExpiry defaults to DefaultSignedURLMaxExpiry and is the longest
+	// lifetime the signer accepts.
+	MaxExpiry time.Duration `json:",optional"`
+	// Clock defaults to SystemClock if nil.
+	Clock Clock `json:"-"`
+}
+
+// URLSigner mints short-lived tokens granting one request, such as a file
+// download or a media stream, to whoever holds the URL. A token is bound to
+// the request method and path, and, when embedded with SignURL, to the rest
+// of the query string. Tokens are "<unix expiry>.<MAC>" rather than JWTs to
+// keep URLs short, and expire to the second, without leeway.
+type URLSigner struct {
+	secret    []byte
+	alg       string
+	maxExpiry time.Duration
+	clock     Clock
+}
+
+// NewURLSigner returns a URLSigner for cfg.
+func NewURLSigner(cfg SignedURLConfig) (*URLSigner, error) {
+	if cfg.Secret == "" {
+		return nil, fmt.Errorf("config.Secret is required")
+	}
+	method, err := resolveSymmetricMethod("Algorithm", cfg.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxExpiry < 0 {
+		return nil, fmt.Errorf("config.MaxExpiry must not be negative")
+	}
+	if cfg.MaxExpiry == 0 {
+		cfg.MaxExpiry = DefaultSignedURLMaxExpiry
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = SystemClock{}
+	}
+	return &URLSigner{
+		secret:    []byte(cfg.Secret),
+		alg:       method.Alg(),
+		maxExpiry: cfg.MaxExpiry,
+		clock:     cfg.Clock,
+	}, nil
+}
+
+// CreateToken returns a token for a method request to path, valid for
+// expiry, which is rounded up to whole seconds.
+func (s *URLSigner) CreateToken(method, path string, expiry time.Duration) (*TokenResponse, error) {
+	return s.create(method, path, "", expiry)
+}
+
+// VerifyToken checks a token from CreateToken against the method and path
+// of the request presenting it.
+func (s *URLSigner) VerifyToken(method, path, token string) error {
+	return s.verify(method, path, "", token)
+}
+
+// SignURL returns rawURL with a token for a method request added as
+// SignedURLParam. The token covers the path and every other query
+// parameter, so none can be changed, added or removed.
+func (s *URLSigner) SignURL(method, rawURL string, expiry time.Duration) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("parse signed url: %w", err)
+	}
+	q := u.Query()
+	q.Del(SignedURLParam)
+	res, err := s.create(method, u.EscapedPath(), q.Encode(), expiry)
+	if err != nil {
+		return "", err
+	}
+	q.Set(SignedURLParam, res.Token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// VerifyURL checks a URL from SignURL as requested with method, typically
+// r.Method and r.URL of the incoming request.
+func (s *URLSigner) VerifyURL(method string, u *url.URL) error {
+	q := u.Query()
+	token := q.Get(SignedURLParam)
+	if token == "" {
+		return ErrMalformedToken
+	}
+	q.Del(SignedURLParam)
+	return s.verify(method, u.EscapedPath(), q.Encode(), token)
+}
+
+func (s *URLSigner) create(method, path, query string, expiry time.Duration) (*TokenResponse, error) {
+	if method == "" || path == "" {
+		return nil, fmt.Errorf("signed url method and path are required")
+	}
+	if expiry <= 0 || expiry > s.maxExpiry {
+		return nil, fmt.Errorf("signed url expiry must be between 0 and %s", s.maxExpiry)
+	}
+	exp := s.clock.Now().Add(expiry + time.Second - 1).Unix()
+	token := strconv.FormatInt(exp, 10) + "." + s.mac(method, path, query, exp)
+	return &TokenResponse{Token: token, ExpiresAt: time.Unix(exp, 0)}, nil
+}
+
+func (s *URLSigner) verify(method, path, query, token string) error {
+	if len(token) > DefaultMaxTokenLength {
+		return ErrTokenTooLarge
+	}
+	expPart, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrMalformedToken
+	}
+	exp, err := strconv.ParseInt(expPart, 10, 64)
+	if err != nil || exp <= 0 {
+		return ErrMalformedToken
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.mac(method, path, query, exp))) {
+		return ErrInvalidSignature
+	}
+	// The expiry is signed, so it is only trusted once the MAC matched.
+	if s.clock.Now().Unix() >= exp {
+		return ErrTokenExpired
+	}
+	return nil
+}
+
+// mac returns the base64url HMAC of the method, path, query and expiry,
+// separated by newlines, which none of them can contain unescaped.
+func (s *URLSigner) mac(method, path, query string, exp int64) string {
+	h := hmac.New(symmetricMethods[s.alg].Hash.New, s.secret)
+	h.Write([]byte(strings.ToUpper(method)))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(path))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(query))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(strconv.FormatInt(exp, 10)))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
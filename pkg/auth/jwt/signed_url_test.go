@@ -0,0 +1,104 @@
+package jwt
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newTestURLSigner(t *testing.T, clock Clock) *URLSigner {
+	t.Helper()
+	s, err := NewURLSigner(SignedURLConfig{Secret: "signed-url-secret-at-least-32-bytes", MaxExpiry: 10 * time.Minute, Clock: clock})
+	if err != nil {
+		t.Fatalf("create signer: %v", err)
+	}
+	return s
+}
+
+func TestSignedURL(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestURLSigner(t, clock)
+
+	signed, err := s.SignURL("GET", "https://cdn.example.com/files/report%201.pdf?disposition=inline", 30*time.Second)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("parse %q: %v", signed, err)
+	}
+	if u.Query().Get("disposition") != "inline" {
+		t.Errorf("signed url %q lost its query", signed)
+	}
+	if err := s.VerifyURL("GET", u); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	tamper := func(f func(u *url.URL)) *url.URL {
+		c := *u
+		f(&c)
+		return &c
+	}
+	tests := []struct {
+		name   string
+		method string
+		url    *url.URL
+		err    error
+	}{
+		{"other method", "DELETE", u, ErrInvalidSignature},
+		{"other path", "GET", tamper(func(u *url.URL) { u.Path, u.RawPath = "/files/other.pdf", "" }), ErrInvalidSignature},
+		{"changed param", "GET", tamper(func(u *url.URL) {
+			u.RawQuery = "disposition=attachment&" + SignedURLParam + "=" + u.Query().Get(SignedURLParam)
+		}), ErrInvalidSignature},
+		{"no token", "GET", tamper(func(u *url.URL) { u.RawQuery = "disposition=inline" }), ErrMalformedToken},
+	}
+	for _, tt := range tests {
+		if err := s.VerifyURL(tt.method, tt.url); !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	clock.Advance(29 * time.Second)
+	if err := s.VerifyURL("GET", u); err != nil {
+		t.Errorf("before expiry: %v", err)
+	}
+	clock.Advance(time.Second)
+	if err := s.VerifyURL("GET", u); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expired: err = %v, want ErrTokenExpired", err)
+	}
+}
+
+func TestSignedURLToken(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestURLSigner(t, clock)
+
+	res, err := s.CreateToken("GET", "/media/42/stream", 1500*time.Millisecond)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !res.ExpiresAt.Equal(clock.Now().Add(2 * time.Second)) {
+		t.Errorf("expires at %v, want expiry rounded up to the second", res.ExpiresAt)
+	}
+	if err := s.VerifyToken("get", "/media/42/stream", res.Token); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err := s.VerifyToken("GET", "/media/43/stream", res.Token); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("other path: err = %v, want ErrInvalidSignature", err)
+	}
+	for _, token := range []string{"", "1700000002", "soon.sig", "-1.sig"} {
+		if err := s.VerifyToken("GET", "/media/42/stream", token); !errors.Is(err, ErrMalformedToken) {
+			t.Errorf("token %q: err = %v, want ErrMalformedToken", token, err)
+		}
+	}
+
+	if _, err := s.CreateToken("GET", "/media/42/stream", time.Hour); err == nil {
+		t.Error("expected expiry beyond the maximum to be rejected")
+	}
+	if _, err := s.CreateToken("GET", "/media/42/stream", 0); err == nil {
+		t.Error("expected zero expiry to be rejected")
+	}
+	if _, err := NewURLSigner(SignedURLConfig{}); err == nil {
+		t.Error("expected missing secret to be rejected")
+	}
+}
@@ -0,0 +1,71 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// OpSign is the SlowOperation.Operation of token signing.
+const OpSign = "sign"
+
+// SlowOperation describes a call that took longer than its threshold.
+type SlowOperation struct {
+	// Operation names the call, OpSign or a repository operation such as
+	// "is_revoked".
+	Operation string
+	// Backend is the repository backend, or a signer's key ID; "" when
+	// there is nothing to tell apart.
+	Backend   string
+	TokenType TokenType
+	Duration  time.Duration
+	Threshold time.Duration
+}
+
+// SlowOperationHandler receives slow operations; see
+// WithSlowOperationWarnings.
+type SlowOperationHandler func(ctx context.Context, op SlowOperation)
+
+// WithSlowOperationWarnings reports token signing that takes longer than
+// threshold to h, or logs it at slow level if h is nil, to catch CPU
+// starvation or oversized keys early. Repository calls are reported by
+// wrapping the repository with the revocation/metrics package and its
+// WithSlowOperationWarnings option.
+func WithSlowOperationWarnings(threshold time.Duration, h SlowOperationHandler) Option {
+	return func(o *makerOptions) {
+		o.slowThreshold, o.slowHandler = threshold, h
+	}
+}
+
+// ReportSlow passes op to h, or logs it if h is nil, when op.Duration
+// exceeds op.Threshold. A threshold of zero or less reports nothing.
+func ReportSlow(ctx context.Context, h SlowOperationHandler, op SlowOperation) {
+	if op.Threshold <= 0 || op.Duration <= op.Threshold {
+		return
+	}
+	if h != nil {
+		h(ctx, op)
+		return
+	}
+	logx.WithContext(ctx).WithDuration(op.Duration).Sloww("jwt: slow operation",
+		logx.Field("operation", op.Operation),
+		logx.Field("backend", op.Backend),
+		logx.Field("token_type", op.TokenType),
+		logx.Field("threshold", op.Threshold),
+	)
+}
+
+// signToken signs token with key, reporting slow signing.
+func (tm *TokenMaker) signToken(ctx context.Context, tokenType TokenType, token *jwt.Token, key any) (string, error) {
+	start := time.Now()
+	signed, err := token.SignedString(key)
+	ReportSlow(ctx, tm.slowHandler, SlowOperation{
+		Operation: OpSign,
+		TokenType: tokenType,
+		Duration:  time.Since(start),
+		Threshold: tm.slowThreshold,
+	})
+	return signed, err
+}
@@ -0,0 +1,56 @@
+package jwt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWithSlowOperationWarnings(t *testing.T) {
+	var slow []SlowOperation
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, newMockRevocationRepo(), WithSlowOperationWarnings(time.Nanosecond, func(_ context.Context, op SlowOperation) {
+		slow = append(slow, op)
+	}))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := maker.CreateAccessToken(ctx, uuid.New(), "alice", nil, uuid.New()); err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if _, err := maker.CreateRefreshToken(ctx, uuid.New(), "alice", nil, uuid.New()); err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if len(slow) != 2 {
+		t.Fatalf("got %d slow operations, want 2", len(slow))
+	}
+	for i, typ := range []TokenType{AccessToken, RefreshToken} {
+		if slow[i].Operation != OpSign || slow[i].TokenType != typ || slow[i].Threshold != time.Nanosecond {
+			t.Errorf("slow operation %d = %+v, want %s of %s tokens", i, slow[i], OpSign, typ)
+		}
+	}
+}
+
+func TestReportSlow(t *testing.T) {
+	var calls int
+	h := func(context.Context, SlowOperation) { calls++ }
+	ctx := context.Background()
+
+	ReportSlow(ctx, h, SlowOperation{Duration: time.Second})
+	ReportSlow(ctx, h, SlowOperation{Duration: time.Second, Threshold: time.Second})
+	if calls != 0 {
+		t.Errorf("reported %d operations within or without a threshold", calls)
+	}
+	ReportSlow(ctx, h, SlowOperation{Duration: 2 * time.Second, Threshold: time.Second})
+	if calls != 1 {
+		t.Errorf("reported %d operations, want 1", calls)
+	}
+}
@@ -0,0 +1,192 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// cleanupHistory is how many CleanupNow runs Stats reports.
+const cleanupHistory = 16
+
+// RevocationCounter is an optional extension of RevocationRepository that
+// counts unexpired token revocations per token type. Rotated refresh tokens
+// are refresh revocations; session, family and user revocations are not
+// counted.
+type RevocationCounter interface {
+	CountRevoked(ctx context.Context) (map[TokenType]int64, error)
+}
+
+// revocationScanner is the method set of migrate.Scanner, used to count
+// revocations of stores that can enumerate but not count them.
+type revocationScanner interface {
+	ScanRevoked(ctx context.Context, fn func(tokenType TokenType, token string, ttl time.Duration) error) error
+}
+
+// CountRevoked counts the revocations of v if it implements
+// RevocationCounter, or by enumerating them if it has a ScanRevoked method
+// like migrate.Scanner. It returns errors.ErrUnsupported otherwise.
+// Repository decorators use it to forward counting to the store they wrap.
+func CountRevoked(ctx context.Context, v any) (map[TokenType]int64, error) {
+	switch r := v.(type) {
+	case RevocationCounter:
+		return r.CountRevoked(ctx)
+	case revocationScanner:
+		counts := make(map[TokenType]int64)
+		err := r.ScanRevoked(ctx, func(tokenType TokenType, _ string, _ time.Duration) error {
+			counts[tokenType]++
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return counts, nil
+	default:
+		return nil, errors.ErrUnsupported
+	}
+}
+
+// CleanupRun is the outcome of one CleanupNow call.
+type CleanupRun struct {
+	Start    time.Time
+	Duration time.Duration
+	Removed  int64
+	Err      error
+}
+
+// Stats is a snapshot of a TokenMaker's activity, as returned by
+// TokenMaker.Stats. Counters start at zero when the maker is created and
+// cover this instance only.
+type Stats struct {
+	// Since is when the counters started.
+	Since time.Time
+	// Issued counts issued tokens per type.
+	Issued map[TokenType]int64
+	// Revoked counts tokens revoked through this maker per type, by
+	// RevokeAccessToken, RevokeTokenByID and the like.
+	Revoked map[TokenType]int64
+	// Rotated counts successful RotateRefreshToken calls.
+	Rotated int64
+	// SessionsRevoked and FamiliesRevoked count RevokeSession and
+	// RevokeFamily calls.
+	SessionsRevoked int64
+	FamiliesRevoked int64
+	// ActiveRevocations counts the unexpired revocations in the repository
+	// per type, across every instance sharing it; see CountRevoked. It is
+	// nil when the repository cannot count them.
+	ActiveRevocations map[TokenType]int64
+	// Cleanups lists the most recent CleanupNow runs, oldest first.
+	Cleanups []CleanupRun
+	// CleanupRemoved totals the entries removed by every CleanupNow run,
+	// and LastSuccessfulCleanup is when the latest run without an error
+	// started, zero if none did. A stale LastSuccessfulCleanup means
+	// expired revocations are piling up in the store.
+	CleanupRemoved        int64
+	LastSuccessfulCleanup time.Time
+}
+
+// statsRecorder keeps the counters reported by Stats. It is safe for
+// concurrent use.
+type statsRecorder struct {
+	mu              sync.Mutex
+	since           time.Time
+	issued          map[TokenType]int64
+	revoked         map[TokenType]int64
+	rotated         int64
+	sessionsRevoked int64
+	familiesRevoked int64
+	cleanups        []CleanupRun
+	cleanupRemoved  int64
+	lastCleanupOK   time.Time
+}
+
+func newStatsRecorder(since time.Time) *statsRecorder {
+	return &statsRecorder{
+		since:   since,
+		issued:  make(map[TokenType]int64),
+		revoked: make(map[TokenType]int64),
+	}
+}
+
+func (s *statsRecorder) recordIssued(tokenType TokenType) {
+	s.mu.Lock()
+	s.issued[tokenType]++
+	s.mu.Unlock()
+}
+
+func (s *statsRecorder) recordRevoked(tokenType TokenType) {
+	s.mu.Lock()
+	s.revoked[tokenType]++
+	s.mu.Unlock()
+}
+
+func (s *statsRecorder) recordRotated() {
+	s.mu.Lock()
+	s.rotated++
+	s.mu.Unlock()
+}
+
+func (s *statsRecorder) recordSessionRevoked() {
+	s.mu.Lock()
+	s.sessionsRevoked++
+	s.mu.Unlock()
+}
+
+func (s *statsRecorder) recordFamilyRevoked() {
+	s.mu.Lock()
+	s.familiesRevoked++
+	s.mu.Unlock()
+}
+
+func (s *statsRecorder) recordCleanup(run CleanupRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.cleanups) == cleanupHistory {
+		s.cleanups = slices.Delete(s.cleanups, 0, 1)
+	}
+	s.cleanups = append(s.cleanups, run)
+	s.cleanupRemoved += run.Removed
+	if run.Err == nil {
+		s.lastCleanupOK = run.Start
+	}
+}
+
+func (s *statsRecorder) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{
+		Since:                 s.since,
+		Issued:                maps.Clone(s.issued),
+		Revoked:               maps.Clone(s.revoked),
+		Rotated:               s.rotated,
+		SessionsRevoked:       s.sessionsRevoked,
+		FamiliesRevoked:       s.familiesRevoked,
+		Cleanups:              slices.Clone(s.cleanups),
+		CleanupRemoved:        s.cleanupRemoved,
+		LastSuccessfulCleanup: s.lastCleanupOK,
+	}
+}
+
+// Stats reports the maker's issuance, revocation, rotation and cleanup
+// counters together with the revocations active in its repository, for
+// admin endpoints and reports. The counters are always returned; an error
+// means only the repository count failed.
+func (tm *TokenMaker) Stats(ctx context.Context) (Stats, error) {
+	stats := tm.stats.snapshot()
+	if tm.repo == nil {
+		return stats, nil
+	}
+	active, err := CountRevoked(ctx, tm.repo)
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+	case err != nil:
+		return stats, fmt.Errorf("count revocations: %w", err)
+	default:
+		stats.ActiveRevocations = active
+	}
+	return stats, nil
+}
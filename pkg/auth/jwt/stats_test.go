@@ -0,0 +1,102 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// scanningRepo adds a ScanRevoked method to mockRevocationRepo, reporting
+// every entry as an access token revocation.
+type scanningRepo struct {
+	*mockRevocationRepo
+}
+
+func (r *scanningRepo) ScanRevoked(_ context.Context, fn func(tokenType TokenType, token string, ttl time.Duration) error) error {
+	for token := range r.revoked {
+		if err := fn(AccessToken, token, time.Minute); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestStats(t *testing.T) {
+	repo := &scanningRepo{mockRevocationRepo: newMockRevocationRepo()}
+	maker := newSessionTestMaker(t, repo)
+	ctx := context.Background()
+
+	userID, sessionID := uuid.New(), uuid.New()
+	access, err := maker.CreateAccessToken(ctx, userID, "test", nil, sessionID)
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	refresh, err := maker.CreateRefreshToken(ctx, userID, "test", nil, sessionID)
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if _, err := maker.RotateRefreshToken(ctx, refresh.Token); err != nil {
+		t.Fatalf("rotate refresh token: %v", err)
+	}
+	if err := maker.RevokeAccessToken(ctx, access.Token); err != nil {
+		t.Fatalf("revoke access token: %v", err)
+	}
+	if _, err := maker.CleanupNow(ctx); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+
+	stats, err := maker.Stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.Issued[AccessToken] != 1 || stats.Issued[RefreshToken] != 2 {
+		t.Errorf("issued = %v, want 1 access and 2 refresh", stats.Issued)
+	}
+	if stats.Revoked[AccessToken] != 1 || stats.Rotated != 1 {
+		t.Errorf("revoked = %v, rotated = %d; want 1 and 1", stats.Revoked, stats.Rotated)
+	}
+	// The rotated refresh token and the revoked access token.
+	if stats.ActiveRevocations[AccessToken] != 2 {
+		t.Errorf("active revocations = %v, want 2", stats.ActiveRevocations)
+	}
+	if len(stats.Cleanups) != 1 || stats.Cleanups[0].Err != nil {
+		t.Errorf("cleanups = %+v, want one successful run", stats.Cleanups)
+	}
+
+	// Stores that can neither count nor enumerate report no active count.
+	plain := newSessionTestMaker(t, newMockRevocationRepo())
+	stats, err = plain.Stats(ctx)
+	if err != nil || stats.ActiveRevocations != nil {
+		t.Errorf("expected no active revocation count, got %v, %v", stats.ActiveRevocations, err)
+	}
+	if _, err := CountRevoked(ctx, newMockRevocationRepo()); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestStats_CleanupHistory(t *testing.T) {
+	repo := &cleanerRepo{mockRevocationRepo: newMockRevocationRepo()}
+	maker := newSessionTestMaker(t, repo)
+	ctx := context.Background()
+	if err := repo.MarkTokenRevoke(ctx, AccessToken, "a", time.Minute); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	for range cleanupHistory + 3 {
+		if _, err := maker.CleanupNow(ctx); err != nil {
+			t.Fatalf("cleanup: %v", err)
+		}
+	}
+	stats, err := maker.Stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if len(stats.Cleanups) != cleanupHistory {
+		t.Errorf("expected %d cleanup runs, got %d", cleanupHistory, len(stats.Cleanups))
+	}
+	if stats.CleanupRemoved != 1 || stats.LastSuccessfulCleanup.IsZero() {
+		t.Errorf("expected 1 entry removed and a last successful cleanup, got %d at %v", stats.CleanupRemoved, stats.LastSuccessfulCleanup)
+	}
+}
@@ -0,0 +1,110 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevocationQuery names the records verifying one token looks up. Empty
+// fields are not looked up.
+type RevocationQuery struct {
+	TokenType TokenType
+	// Token is the token's repository key; see Config.RevocationKey.
+	Token string
+	// RotationKey is the key of the marker RotateRefreshToken leaves on a
+	// rotated refresh token, stored as a RefreshToken record.
+	RotationKey string
+	SessionID   uuid.UUID
+	FamilyID    uuid.UUID
+	UserID      uuid.UUID
+}
+
+// RevocationStatus answers a RevocationQuery.
+type RevocationStatus struct {
+	TokenRevoked   bool
+	TokenRotated   bool
+	SessionRevoked bool
+	FamilyRevoked  bool
+	// UserRevokedBefore is the user's revocation cutoff, or the zero time
+	// if there is none.
+	UserRevokedBefore time.Time
+}
+
+// RevocationStatusRepository is an optional extension of
+// RevocationRepository that answers every lookup of a verification in one
+// round trip, instead of one each for the token, its rotation marker, its
+// session, family and user. A repository implementing it must also
+// implement SessionRevocationRepository, FamilyRevocationRepository and
+// UserRevocationRepository.
+//
+// Decorators that cannot reach a status-capable store return an error
+// wrapping errors.ErrUnsupported; the TokenMaker then looks the records up
+// one by one.
+type RevocationStatusRepository interface {
+	RevocationStatus(ctx context.Context, q RevocationQuery) (RevocationStatus, error)
+}
+
+// revocationQuery returns the lookups verifying claims needs. key is the
+// token's repository key, or empty when it was already checked.
+func (tm *TokenMaker) revocationQuery(tokenType TokenType, key string, checkRotation bool, claims *TokenClaims) (RevocationQuery, error) {
+	q := RevocationQuery{TokenType: tokenType, Token: key}
+	if key != "" && tokenType == RefreshToken && checkRotation {
+		q.RotationKey = rotationKey(key)
+	}
+	if _, ok := tm.repo.(SessionRevocationRepository); ok {
+		q.SessionID = claims.SessionID
+	}
+	if _, ok := tm.repo.(FamilyRevocationRepository); ok {
+		q.FamilyID = claims.FamilyID
+	}
+	if _, ok := tm.repo.(UserRevocationRepository); ok && claims.Subject != uuid.Nil {
+		if claims.IssuedAt == nil {
+			return RevocationQuery{}, ErrMissingClaims
+		}
+		q.UserID = claims.Subject
+	}
+	return q, nil
+}
+
+// checkRevocationStatus runs the token, rotation, session, family and user
+// checks in one round trip when the repository implements
+// RevocationStatusRepository. It reports false if it did not, so the caller
+// checks them one by one.
+func (tm *TokenMaker) checkRevocationStatus(ctx context.Context, tokenType TokenType, key string, checkRotation bool, claims *TokenClaims) (bool, error) {
+	statuses, ok := tm.repo.(RevocationStatusRepository)
+	if !ok {
+		return false, nil
+	}
+	q, err := tm.revocationQuery(tokenType, key, checkRotation, claims)
+	if err != nil {
+		return true, err
+	}
+
+	rctx, cancel := tm.repoContext(ctx)
+	status, err := statuses.RevocationStatus(rctx, q)
+	cancel()
+	if errors.Is(err, errors.ErrUnsupported) {
+		return false, nil
+	}
+	if err != nil {
+		return true, fmt.Errorf("check revocation: %w", err)
+	}
+
+	switch {
+	case status.TokenRevoked:
+		return true, ErrTokenRevoked
+	case status.TokenRotated:
+		return true, ErrTokenRotated
+	case status.SessionRevoked:
+		return true, ErrSessionRevoked
+	case status.FamilyRevoked:
+		return true, ErrFamilyRevoked
+	case q.UserID != uuid.Nil && issuedBeforeCutoff(claims.IssuedAt.Time, status.UserRevokedBefore):
+		return true, ErrTokenInvalidated
+	}
+	return true, nil
+}
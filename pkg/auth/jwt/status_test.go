@@ -0,0 +1,190 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// statusRepo adds RevocationStatusRepository, and the session, family and
+// user extensions it requires, to countingRepo.
+type statusRepo struct {
+	*countingRepo
+	sessions    map[uuid.UUID]struct{}
+	families    map[uuid.UUID]struct{}
+	users       map[uuid.UUID]time.Time
+	queries     []RevocationQuery
+	unsupported bool
+}
+
+func newStatusRepo() *statusRepo {
+	return &statusRepo{
+		countingRepo: &countingRepo{mockRevocationRepo: newMockRevocationRepo()},
+		sessions:     make(map[uuid.UUID]struct{}),
+		families:     make(map[uuid.UUID]struct{}),
+		users:        make(map[uuid.UUID]time.Time),
+	}
+}
+
+func (r *statusRepo) MarkSessionRevoked(_ context.Context, sessionID uuid.UUID, _ time.Duration) error {
+	r.sessions[sessionID] = struct{}{}
+	return nil
+}
+
+func (r *statusRepo) IsSessionRevoked(_ context.Context, sessionID uuid.UUID) (bool, error) {
+	r.lookups++
+	_, ok := r.sessions[sessionID]
+	return ok, nil
+}
+
+func (r *statusRepo) MarkFamilyRevoked(_ context.Context, familyID uuid.UUID, _ time.Duration) error {
+	r.families[familyID] = struct{}{}
+	return nil
+}
+
+func (r *statusRepo) IsFamilyRevoked(_ context.Context, familyID uuid.UUID) (bool, error) {
+	r.lookups++
+	_, ok := r.families[familyID]
+	return ok, nil
+}
+
+func (r *statusRepo) MarkUserRevoked(_ context.Context, userID uuid.UUID, before time.Time, _ time.Duration) error {
+	r.users[userID] = before
+	return nil
+}
+
+func (r *statusRepo) UserRevokedBefore(_ context.Context, userID uuid.UUID) (time.Time, error) {
+	r.lookups++
+	return r.users[userID], nil
+}
+
+func (r *statusRepo) RevocationStatus(_ context.Context, q RevocationQuery) (RevocationStatus, error) {
+	if r.unsupported {
+		return RevocationStatus{}, fmt.Errorf("check revocation status: %w", errors.ErrUnsupported)
+	}
+	r.queries = append(r.queries, q)
+	_, revoked := r.revoked[q.Token]
+	_, rotated := r.revoked[q.RotationKey]
+	_, session := r.sessions[q.SessionID]
+	_, family := r.families[q.FamilyID]
+	return RevocationStatus{
+		TokenRevoked:      q.Token != "" && revoked,
+		TokenRotated:      q.RotationKey != "" && rotated,
+		SessionRevoked:    session,
+		FamilyRevoked:     family,
+		UserRevokedBefore: r.users[q.UserID],
+	}, nil
+}
+
+func newStatusTestMaker(t *testing.T, repo RevocationRepository, clock Clock) *TokenMaker {
+	t.Helper()
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: 24 * time.Hour,
+	}, repo, WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	return maker
+}
+
+func TestRevocationStatus_SingleRoundTrip(t *testing.T) {
+	repo := newStatusRepo()
+	clock := newFakeClock()
+	maker := newStatusTestMaker(t, repo, clock)
+	ctx := context.Background()
+
+	userID, sessionID := uuid.New(), uuid.New()
+	access, err := maker.CreateAccessToken(ctx, userID, "alice", nil, sessionID)
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if repo.lookups != 0 || len(repo.queries) != 1 {
+		t.Fatalf("expected one status query and no single lookups, got %d queries and %d lookups", len(repo.queries), repo.lookups)
+	}
+	q := repo.queries[0]
+	if q.Token == "" || q.RotationKey != "" || q.SessionID != sessionID || q.FamilyID != uuid.Nil || q.UserID != userID {
+		t.Errorf("unexpected access token query %+v", q)
+	}
+
+	refresh, err := maker.CreateRefreshToken(ctx, userID, "alice", nil, sessionID)
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	refreshClaims, err := maker.VerifyRefreshToken(ctx, refresh.Token)
+	if err != nil {
+		t.Fatalf("verify refresh token: %v", err)
+	}
+	if q := repo.queries[len(repo.queries)-1]; q.RotationKey == "" || q.FamilyID != refreshClaims.FamilyID {
+		t.Errorf("expected the refresh token query to include the rotation marker and family, got %+v", q)
+	}
+
+	if err := maker.RevokeFamily(ctx, refreshClaims.FamilyID); err != nil {
+		t.Fatalf("revoke family: %v", err)
+	}
+	if _, err := maker.VerifyRefreshToken(ctx, refresh.Token); !errors.Is(err, ErrFamilyRevoked) {
+		t.Errorf("expected ErrFamilyRevoked, got %v", err)
+	}
+
+	clock.Advance(2 * time.Second)
+	if err := maker.RevokeAllUserTokens(ctx, userID); err != nil {
+		t.Fatalf("revoke user tokens: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); !errors.Is(err, ErrTokenInvalidated) {
+		t.Errorf("expected ErrTokenInvalidated, got %v", err)
+	}
+
+	if err := maker.RevokeSession(ctx, sessionID); err != nil {
+		t.Fatalf("revoke session: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("expected ErrSessionRevoked, got %v", err)
+	}
+
+	if err := maker.RevokeAccessToken(ctx, access.Token); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("expected ErrTokenRevoked, got %v", err)
+	}
+	if repo.lookups != 0 {
+		t.Errorf("expected no single lookups, got %d", repo.lookups)
+	}
+}
+
+func TestRevocationStatus_UnsupportedFallsBack(t *testing.T) {
+	repo := newStatusRepo()
+	repo.unsupported = true
+	maker := newStatusTestMaker(t, repo, newFakeClock())
+	ctx := context.Background()
+
+	sessionID := uuid.New()
+	access, err := maker.CreateAccessToken(ctx, uuid.New(), "alice", nil, sessionID)
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	// Token, session and user; access tokens carry no family.
+	if repo.lookups != 3 {
+		t.Errorf("expected 3 single lookups, got %d", repo.lookups)
+	}
+
+	if err := maker.RevokeSession(ctx, sessionID); err != nil {
+		t.Fatalf("revoke session: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("expected ErrSessionRevoked, got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// ACRStepUp is the acr value of access tokens issued by ElevateToken.
+const ACRStepUp = "mfa"
+
+const (
+	// DefaultElevatedExpiry is the lifetime of an elevated token when
+	// Config.ElevatedExpiryDuration is zero.
+	DefaultElevatedExpiry = 5 * time.Minute
+	// DefaultMFAProofMaxAge is how recent an MFA proof must be when
+	// Config.MFAProofMaxAge is zero.
+	DefaultMFAProofMaxAge = 5 * time.Minute
+)
+
+// MFAProof records a second factor the caller has just verified, such as a
+// TOTP code or a WebAuthn assertion. The TokenMaker does not check the factor
+// itself; it only enforces that the proof is recent.
+type MFAProof struct {
+	// Method is the amr value of the factor, e.g. "otp" or "hwk".
+	Method string
+	// VerifiedAt is when the factor was verified.
+	VerifiedAt time.Time
+}
+
+// ElevationRequirement describes the elevation an action needs.
+type ElevationRequirement struct {
+	// ACR is the required acr value; empty defaults to ACRStepUp.
+	ACR string
+	// Scopes must all be present in the token.
+	Scopes []string
+	// MaxAge, if set, limits how long ago the second factor may have been
+	// verified.
+	MaxAge time.Duration
+}
+
+// ElevateToken exchanges a valid access token and a recent MFA proof for a
+// short-lived access token of the same session with acr ACRStepUp and the
+// given extra scopes. The elevated token expires after
+// Config.ElevatedExpiryDuration, and never after the token it replaces.
+func (tm *TokenMaker) ElevateToken(ctx context.Context, accessToken string, proof MFAProof, scopes ...string) (*TokenResponse, error) {
+	claims, err := tm.VerifyAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify access token: %w", err)
+	}
+
+	now := tm.clock.Now()
+	if proof.VerifiedAt.IsZero() || proof.VerifiedAt.After(now.Add(DefaultLeeway)) || now.Sub(proof.VerifiedAt) > tm.policy().mfaProofMaxAge {
+		return nil, ErrStaleMFAProof
+	}
+	if err := tm.allowIssue(ctx, claims.Subject); err != nil {
+		return nil, err
+	}
+
+	expiresAt := now.Add(tm.policy().elevatedExpiry)
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(expiresAt) {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	var amr []string
+	if proof.Method != "" {
+		amr = []string{proof.Method}
+	}
+
+	return tm.signAccessToken(ctx, TokenClaims{
+		ID:           uuid.New(),
+		Subject:      claims.Subject,
+		SessionID:    claims.SessionID,
+		Username:     claims.Username,
+		Roles:        claims.Roles,
+		Issuer:       tm.issuer,
+		Audience:     []string{tm.policy().audience},
+		IssuedAt:     jwt.NewNumericDate(now),
+		ExpiresAt:    jwt.NewNumericDate(expiresAt),
+		NotBefore:    jwt.NewNumericDate(now),
+		TokenType:    AccessToken,
+		AuthTime:     jwt.NewNumericDate(proof.VerifiedAt),
+		ACR:          ACRStepUp,
+		AMR:          amr,
+		Scopes:       mergeScopes(claims.Scopes, scopes),
+		Confirmation: claims.Confirmation,
+	})
+}
+
+// VerifyElevatedToken is VerifyAccessToken followed by RequireElevation. It
+// returns ErrStepUpRequired for valid tokens that are not elevated enough.
+func (tm *TokenMaker) VerifyElevatedToken(ctx context.Context, tokenString string, req ElevationRequirement) (*TokenClaims, error) {
+	claims, err := tm.VerifyAccessToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if err := claims.RequireElevation(req, tm.clock.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// RequireElevation returns ErrStepUpRequired unless the verified claims meet
+// req at now. It works with claims from both TokenMaker and Verifier.
+func (c *TokenClaims) RequireElevation(req ElevationRequirement, now time.Time) error {
+	acr := req.ACR
+	if acr == "" {
+		acr = ACRStepUp
+	}
+	if c.ACR != acr {
+		return ErrStepUpRequired
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			return ErrStepUpRequired
+		}
+	}
+	if req.MaxAge > 0 && (c.AuthTime == nil || now.Sub(c.AuthTime.Time) > req.MaxAge) {
+		return ErrStepUpRequired
+	}
+	return nil
+}
+
+func mergeScopes(have, extra []string) []string {
+	merged := slices.Clone(have)
+	for _, scope := range extra {
+		if !slices.Contains(merged, scope) {
+			merged = append(merged, scope)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,64 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestElevateToken(t *testing.T) {
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, newMockRevocationRepo(), WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+	sessionID := uuid.New()
+	req := ElevationRequirement{Scopes: []string{"payments"}, MaxAge: 10 * time.Minute}
+
+	access, err := maker.CreateAccessToken(ctx, uuid.New(), "alice", []string{"user"}, sessionID)
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if _, err := maker.VerifyElevatedToken(ctx, access.Token, req); !errors.Is(err, ErrStepUpRequired) {
+		t.Errorf("expected ErrStepUpRequired for a normal token, got %v", err)
+	}
+
+	stale := MFAProof{Method: "otp", VerifiedAt: clock.Now().Add(-time.Hour)}
+	if _, err := maker.ElevateToken(ctx, access.Token, stale, "payments"); !errors.Is(err, ErrStaleMFAProof) {
+		t.Errorf("expected ErrStaleMFAProof, got %v", err)
+	}
+
+	proof := MFAProof{Method: "otp", VerifiedAt: clock.Now()}
+	elevated, err := maker.ElevateToken(ctx, access.Token, proof, "payments")
+	if err != nil {
+		t.Fatalf("elevate token: %v", err)
+	}
+	if want := clock.Now().Add(DefaultElevatedExpiry); !elevated.ExpiresAt.Equal(want) {
+		t.Errorf("expected elevated token to expire at %v, got %v", want, elevated.ExpiresAt)
+	}
+	claims, err := maker.VerifyElevatedToken(ctx, elevated.Token, req)
+	if err != nil {
+		t.Fatalf("verify elevated token: %v", err)
+	}
+	if claims.SessionID != sessionID || claims.ACR != ACRStepUp || !slices.Equal(claims.AMR, []string{"otp"}) {
+		t.Errorf("unexpected elevated claims %+v", claims)
+	}
+
+	if _, err := maker.VerifyElevatedToken(ctx, elevated.Token, ElevationRequirement{Scopes: []string{"admin"}}); !errors.Is(err, ErrStepUpRequired) {
+		t.Errorf("expected ErrStepUpRequired for a missing scope, got %v", err)
+	}
+	clock.Advance(4 * time.Minute)
+	if _, err := maker.VerifyElevatedToken(ctx, elevated.Token, ElevationRequirement{MaxAge: 2 * time.Minute}); !errors.Is(err, ErrStepUpRequired) {
+		t.Errorf("expected ErrStepUpRequired for an old second factor, got %v", err)
+	}
+}
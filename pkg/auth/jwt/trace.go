@@ -0,0 +1,154 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// traceHashPrefix is how many hex characters of a token's SHA-256 hash a
+// VerificationTrace keeps: enough to match a token against repository keys
+// and client reports, far too few to recover it.
+const traceHashPrefix = 12
+
+// Verification steps recorded in VerificationTrace.Steps.
+const (
+	StepSize               = "size"
+	StepLockout            = "lockout"
+	StepResolve            = "resolve"
+	StepRevocationFirst    = "revocation_repository_first"
+	StepSignature          = "signature_and_claims"
+	StepDPoP               = "dpop"
+	StepStaticInvalidation = "static_invalidation"
+	StepRevocation         = "revocation"
+	StepSession            = "session"
+	StepFamily             = "family"
+	StepUser               = "user_cutoff"
+	StepInvalidation       = "invalidation_source"
+)
+
+// VerificationTrace describes how one verification reached its decision.
+// It never holds the token itself.
+type VerificationTrace struct {
+	TokenType TokenType
+	// TokenHash is a prefix of HashToken of the presented token.
+	TokenHash string
+	// Steps lists the checks in the order they ran. When the token was
+	// rejected, the last one is the check that failed.
+	Steps []string
+	// Claims is a snapshot of the registered claims, set once the
+	// signature checked out. Usernames are left out.
+	Claims map[string]any
+	// Err is the verification error, nil if the token was accepted, and
+	// Code its ErrorCode.
+	Err      error
+	Code     string
+	Duration time.Duration
+}
+
+// TraceHandler receives verification traces; see WithDebugTracing.
+type TraceHandler func(ctx context.Context, trace VerificationTrace)
+
+// WithDebugTracing records a VerificationTrace of every verification and
+// passes it to h, or logs it if h is nil. It is meant for diagnosing why
+// tokens are rejected and adds allocations to every verification, so turn
+// it on only while investigating.
+func WithDebugTracing(h TraceHandler) Option {
+	return func(o *makerOptions) {
+		if h == nil {
+			h = logTrace
+		}
+		o.traceHandler = h
+	}
+}
+
+// verifyTrace accumulates a VerificationTrace. A nil *verifyTrace records
+// nothing, so verification code can call it unconditionally.
+type verifyTrace struct {
+	VerificationTrace
+	start time.Time
+}
+
+// newTrace starts a trace of tokenString's verification, or returns nil when
+// tracing is off.
+func (tm *TokenMaker) newTrace(tokenType TokenType, tokenString string) *verifyTrace {
+	if tm.traceHandler == nil {
+		return nil
+	}
+	return &verifyTrace{
+		VerificationTrace: VerificationTrace{TokenType: tokenType, TokenHash: HashToken(tokenString)[:traceHashPrefix]},
+		start:             time.Now(),
+	}
+}
+
+func (t *verifyTrace) step(name string) {
+	if t != nil {
+		t.Steps = append(t.Steps, name)
+	}
+}
+
+func (t *verifyTrace) claims(c *TokenClaims) {
+	if t != nil {
+		t.Claims = claimSnapshot(c)
+	}
+}
+
+// finish completes the trace with the decision and hands it to h.
+func (t *verifyTrace) finish(ctx context.Context, h TraceHandler, err error) {
+	if t == nil {
+		return
+	}
+	t.Err, t.Code = err, ErrorCode(err)
+	t.Duration = time.Since(t.start)
+	h(ctx, t.VerificationTrace)
+}
+
+// claimSnapshot returns the claims worth logging: identifiers, validity
+// window, binding and grants, but no usernames.
+func claimSnapshot(c *TokenClaims) map[string]any {
+	snap := map[string]any{
+		"jti": c.ID.String(),
+		"sub": c.Subject.String(),
+		"sid": c.SessionID.String(),
+		"iss": c.Issuer,
+		"aud": c.Audience,
+		"typ": c.TokenType,
+	}
+	for name, date := range map[string]*jwt.NumericDate{"iat": c.IssuedAt, "nbf": c.NotBefore, "exp": c.ExpiresAt, "auth_time": c.AuthTime} {
+		if date != nil {
+			snap[name] = date.Time.UTC().Format(time.RFC3339)
+		}
+	}
+	if len(c.Roles) > 0 {
+		snap["rls"] = c.Roles
+	}
+	if len(c.Scopes) > 0 {
+		snap["scp"] = c.Scopes
+	}
+	if c.FamilyID != uuid.Nil {
+		snap["fam"] = c.FamilyID.String()
+	}
+	if c.Confirmation != nil {
+		snap["cnf"] = c.Confirmation.JKT
+	}
+	return snap
+}
+
+func logTrace(ctx context.Context, t VerificationTrace) {
+	decision := "accepted"
+	if t.Err != nil {
+		decision = "rejected"
+	}
+	logx.WithContext(ctx).Infow("jwt: verification trace",
+		logx.Field("decision", decision),
+		logx.Field("code", t.Code),
+		logx.Field("token_type", t.TokenType),
+		logx.Field("token_hash", t.TokenHash),
+		logx.Field("steps", t.Steps),
+		logx.Field("claims", t.Claims),
+		logx.Field("duration", t.Duration),
+	)
+}
@@ -0,0 +1,66 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWithDebugTracing(t *testing.T) {
+	var traces []VerificationTrace
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, newMockRevocationRepo(), WithDebugTracing(func(_ context.Context, tr VerificationTrace) {
+		traces = append(traces, tr)
+	}))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := context.Background()
+	userID := uuid.New()
+	access, err := maker.CreateAccessToken(ctx, userID, "alice", []string{"admin"}, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); err != nil {
+		t.Fatalf("verify access token: %v", err)
+	}
+	if err := maker.RevokeAccessToken(ctx, access.Token); err != nil {
+		t.Fatalf("revoke access token: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); err == nil {
+		t.Fatal("expected revoked token to be rejected")
+	}
+
+	if len(traces) != 2 {
+		t.Fatalf("expected 2 traces, got %d", len(traces))
+	}
+	accepted, rejected := traces[0], traces[1]
+	if accepted.Err != nil || accepted.Steps[len(accepted.Steps)-1] != StepInvalidation {
+		t.Errorf("expected accepted trace to run every check, got %+v", accepted)
+	}
+	if rejected.Code != CodeTokenRevoked || rejected.Steps[len(rejected.Steps)-1] != StepRevocation {
+		t.Errorf("expected rejection at the revocation step, got %+v", rejected)
+	}
+	if rejected.Claims["sub"] != userID.String() || !slices.Equal(rejected.Claims["rls"].([]string), []string{"admin"}) {
+		t.Errorf("unexpected claim snapshot %v", rejected.Claims)
+	}
+
+	// Neither the token nor the username may appear anywhere in a trace.
+	dump := fmt.Sprintf("%+v", traces)
+	if strings.Contains(dump, access.Token) || strings.Contains(dump, "alice") {
+		t.Errorf("trace leaks the token or username: %s", dump)
+	}
+	if !strings.HasPrefix(HashToken(access.Token), rejected.TokenHash) || len(rejected.TokenHash) != traceHashPrefix {
+		t.Errorf("unexpected token hash %q", rejected.TokenHash)
+	}
+}
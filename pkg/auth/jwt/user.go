@@ -0,0 +1,90 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserRevocationRepository is an optional extension of RevocationRepository
+// that revokes every token of a user (sub claim) issued before a cutoff, for
+// password changes and account compromise response. Tokens issued after the
+// cutoff, such as those from the login that follows, stay valid.
+//
+// Decorators that cannot reach a user-capable store return an error wrapping
+// errors.ErrUnsupported; the TokenMaker then skips the user check.
+type UserRevocationRepository interface {
+	// MarkUserRevoked records that userID's tokens issued before before are
+	// revoked, keeping the record for ttl.
+	MarkUserRevoked(ctx context.Context, userID uuid.UUID, before time.Time, ttl time.Duration) error
+	// UserRevokedBefore returns the cutoff recorded for userID, or the zero
+	// time if there is none.
+	UserRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error)
+}
+
+// UserInvalidator is an optional extension of InvalidationSource that
+// records per-user "not issued before" cutoffs.
+type UserInvalidator interface {
+	SetUserNotIssuedBefore(ctx context.Context, userID uuid.UUID, t time.Time) error
+}
+
+// RevokeAllUserTokens revokes every access and refresh token issued to userID
+// so far. It records the cutoff in the repository when it implements
+// UserRevocationRepository, keeping it for the longest token lifetime plus
+// leeway, and otherwise in the invalidation source when it implements
+// UserInvalidator. iat has second precision, so tokens minted in the same
+// second as the call remain valid (see issuedBeforeCutoff).
+func (tm *TokenMaker) RevokeAllUserTokens(ctx context.Context, userID uuid.UUID) error {
+	if userID == uuid.Nil {
+		return fmt.Errorf("user id is required")
+	}
+	now := tm.clock.Now()
+
+	if users, ok := tm.repo.(UserRevocationRepository); ok {
+		ttl := max(tm.policy().maxAccessExpiry, tm.policy().refreshExpiry) + DefaultLeeway
+		rctx, cancel := tm.repoContext(ctx)
+		err := users.MarkUserRevoked(rctx, userID, now, ttl)
+		cancel()
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+	}
+	if invalidator, ok := tm.invalidation.(UserInvalidator); ok {
+		rctx, cancel := tm.repoContext(ctx)
+		defer cancel()
+		return invalidator.SetUserNotIssuedBefore(rctx, userID, now)
+	}
+
+	if tm.repo == nil {
+		return ErrRevocationDisabled
+	}
+	return fmt.Errorf("revoke user tokens: %w", errors.ErrUnsupported)
+}
+
+// checkUserRevoked rejects tokens issued before their user's revocation cutoff.
+func (tm *TokenMaker) checkUserRevoked(ctx context.Context, claims *TokenClaims) error {
+	users, ok := tm.repo.(UserRevocationRepository)
+	if !ok || claims.Subject == uuid.Nil {
+		return nil
+	}
+	if claims.IssuedAt == nil {
+		return ErrMissingClaims
+	}
+
+	rctx, cancel := tm.repoContext(ctx)
+	before, err := users.UserRevokedBefore(rctx, claims.Subject)
+	cancel()
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("check user revocation: %w", err)
+	}
+	if issuedBeforeCutoff(claims.IssuedAt.Time, before) {
+		return ErrTokenInvalidated
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// userRepo adds UserRevocationRepository to mockRevocationRepo.
+type userRepo struct {
+	*mockRevocationRepo
+	cutoffs map[uuid.UUID]time.Time
+}
+
+func (r *userRepo) MarkUserRevoked(_ context.Context, userID uuid.UUID, before time.Time, _ time.Duration) error {
+	r.cutoffs[userID] = before
+	return nil
+}
+
+func (r *userRepo) UserRevokedBefore(_ context.Context, userID uuid.UUID) (time.Time, error) {
+	return r.cutoffs[userID], nil
+}
+
+func TestRevokeAllUserTokens(t *testing.T) {
+	repo := &userRepo{mockRevocationRepo: newMockRevocationRepo(), cutoffs: make(map[uuid.UUID]time.Time)}
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: 24 * time.Hour,
+	}, repo, WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+
+	userID, otherID := uuid.New(), uuid.New()
+	access, err := maker.CreateAccessToken(ctx, userID, "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	refresh, err := maker.CreateRefreshToken(ctx, userID, "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	other, err := maker.CreateAccessToken(ctx, otherID, "bob", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if err := maker.RevokeAllUserTokens(ctx, userID); err != nil {
+		t.Fatalf("revoke user tokens: %v", err)
+	}
+
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); !errors.Is(err, ErrTokenInvalidated) {
+		t.Errorf("expected ErrTokenInvalidated for access token, got %v", err)
+	}
+	if _, err := maker.VerifyRefreshToken(ctx, refresh.Token); !errors.Is(err, ErrTokenInvalidated) {
+		t.Errorf("expected ErrTokenInvalidated for refresh token, got %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, other.Token); err != nil {
+		t.Errorf("expected another user's token to stay valid, got %v", err)
+	}
+
+	// A login after the revocation is unaffected.
+	clock.Advance(time.Second)
+	fresh, err := maker.CreateAccessToken(ctx, userID, "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, fresh.Token); err != nil {
+		t.Errorf("expected token issued after revocation to be valid, got %v", err)
+	}
+}
+
+func TestRevokeAllUserTokens_Unsupported(t *testing.T) {
+	maker := newSessionTestMaker(t, newMockRevocationRepo())
+
+	err := maker.RevokeAllUserTokens(context.Background(), uuid.New())
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected errors.ErrUnsupported, got %v", err)
+	}
+}
+
+// settableInvalidation adds UserInvalidator to staticInvalidation.
+type settableInvalidation struct {
+	staticInvalidation
+}
+
+func (s *settableInvalidation) SetUserNotIssuedBefore(_ context.Context, userID uuid.UUID, t time.Time) error {
+	s.users[userID] = t
+	return nil
+}
+
+func TestRevokeAllUserTokens_InvalidationSource(t *testing.T) {
+	src := &settableInvalidation{staticInvalidation{users: make(map[uuid.UUID]time.Time)}}
+	clock := newFakeClock()
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+	}, nil, WithInvalidationSource(src), WithClock(clock))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+
+	userID := uuid.New()
+	access, err := maker.CreateAccessToken(ctx, userID, "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if err := maker.RevokeAllUserTokens(ctx, userID); err != nil {
+		t.Fatalf("revoke user tokens: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, access.Token); !errors.Is(err, ErrTokenInvalidated) {
+		t.Errorf("expected ErrTokenInvalidated, got %v", err)
+	}
+}
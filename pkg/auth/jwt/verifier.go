@@ -3,6 +3,7 @@ package jwt
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -36,7 +37,13 @@ type Verifier struct {
 	issuer    string
 	audience  string
 	keyFunc   KeyFunc
-	leeway    time.Duration
+	parser    *jwt.Parser
+	clock     Clock
+	maxLength int
+	algs      []string
+	strictTyp bool
+	cipher    *tokenCipher
+	replay    DPoPReplayCache
 }
 
 // VerifierConfig holds configuration for the asymmetric token verifier.
@@ -46,12 +53,27 @@ type VerifierConfig struct {
 	KeyFunc  KeyFunc
 	// Leeway defaults to DefaultLeeway if zero.
 	Leeway time.Duration
+	// Clock defaults to SystemClock if nil.
+	Clock Clock
+	// MaxTokenLength in bytes; defaults to DefaultMaxTokenLength if zero.
+	MaxTokenLength int
+	// Algorithms pins the accepted alg headers (e.g. ["ES256"]). When empty,
+	// any algorithm KeyFunc returns a key for is accepted.
+	Algorithms []string
+	// StrictTypHeader rejects tokens carrying the generic "JWT" typ header.
+	StrictTypHeader bool
+	// EncryptionKey is the AES key of Config.EncryptionKey, decoded. When
+	// set, only encrypted tokens are accepted.
+	EncryptionKey []byte
+	// DPoPReplayCache, if set, rejects replayed DPoP proofs; see
+	// WithDPoPReplayCache.
+	DPoPReplayCache DPoPReplayCache
 }
 
 // NewVerifier creates an asymmetric token verifier.
 func NewVerifier(cfg VerifierConfig) (*Verifier, error) {
-	if cfg.Issuer == "" {
-		return nil, fmt.Errorf("verifier issuer is required")
+	if err := validateIssuer(cfg.Issuer); err != nil {
+		return nil, fmt.Errorf("verifier issuer %w", err)
 	}
 	if cfg.Audience == "" {
 		return nil, fmt.Errorf("verifier audience is required")
@@ -63,39 +85,96 @@ func NewVerifier(cfg VerifierConfig) (*Verifier, error) {
 	if leeway == 0 {
 		leeway = DefaultLeeway
 	}
+	clock := cfg.Clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	maxLength := cfg.MaxTokenLength
+	if maxLength < 0 {
+		return nil, fmt.Errorf("verifier maxTokenLength must not be negative")
+	}
+	if maxLength == 0 {
+		maxLength = DefaultMaxTokenLength
+	}
+	var tc *tokenCipher
+	if len(cfg.EncryptionKey) > 0 {
+		var err error
+		if tc, err = newTokenCipher(cfg.EncryptionKey); err != nil {
+			return nil, fmt.Errorf("verifier encryptionKey: %w", err)
+		}
+	}
 	return &Verifier{
-		issuer:   cfg.Issuer,
-		audience: cfg.Audience,
-		keyFunc:  cfg.KeyFunc,
-		leeway:   leeway,
+		issuer:    NormalizeIssuer(cfg.Issuer),
+		audience:  cfg.Audience,
+		keyFunc:   cfg.KeyFunc,
+		parser:    jwt.NewParser(jwt.WithLeeway(leeway), jwt.WithTimeFunc(clock.Now)),
+		clock:     clock,
+		maxLength: maxLength,
+		algs:      cfg.Algorithms,
+		strictTyp: cfg.StrictTypHeader,
+		cipher:    tc,
+		replay:    cfg.DPoPReplayCache,
 	}, nil
 }
 
 // VerifyAccessToken validates an access token using the configured public key(s).
-func (v *Verifier) VerifyAccessToken(_ context.Context, tokenString string) (*TokenClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
+// DPoP-bound tokens also need the proof attached with WithDPoPProof.
+func (v *Verifier) VerifyAccessToken(ctx context.Context, tokenString string) (*TokenClaims, error) {
+	_, claims, err := v.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDPoP(ctx, v.replay, tokenString, claims, v.clock.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// parseToken verifies the signature and claims of tokenString and returns the
+// parsed token alongside its typed claims.
+func (v *Verifier) parseToken(tokenString string) (*jwt.Token, *TokenClaims, error) {
+	if len(tokenString) > v.maxLength {
+		return nil, nil, ErrTokenTooLarge
+	}
+	if v.cipher != nil {
+		var err error
+		if tokenString, err = v.cipher.decrypt(tokenString); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	token, err := v.parser.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
 		alg, ok := token.Header["alg"].(string)
 		if !ok {
-			return nil, ErrInvalidToken
+			return nil, ErrMalformedToken
+		}
+		if len(v.algs) > 0 && !slices.Contains(v.algs, alg) {
+			return nil, ErrUnexpectedAlgorithm
+		}
+		if err := checkTypHeader(token, AccessToken, v.strictTyp); err != nil {
+			return nil, err
 		}
 		kid, _ := token.Header["kid"].(string)
 		return v.keyFunc.GetKey(kid, alg)
-	}, jwt.WithLeeway(v.leeway))
-	if err != nil || !token.Valid {
-		return nil, ErrInvalidToken
+	})
+	if err != nil {
+		return nil, nil, classifyParseError(err)
+	}
+	if !token.Valid {
+		return nil, nil, ErrInvalidToken
 	}
 
 	claims, ok := token.Claims.(*TokenClaims)
 	if !ok {
-		return nil, ErrInvalidToken
+		return nil, nil, ErrMalformedToken
 	}
 
-	now := time.Now()
+	now := v.clock.Now()
 	if err := validateClaims(claims, v.issuer, v.audience, AccessToken, now); err != nil {
-		return nil, ErrInvalidToken
+		return nil, nil, err
 	}
 
-	return claims, nil
+	return token, claims, nil
 }
 
 // MustVerifyAccessToken is a convenience wrapper that returns an error if verification fails.
@@ -0,0 +1,200 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RepoCheckOrder controls whether the revocation repository is consulted
+// before or after the token signature is verified.
+type RepoCheckOrder string
+
+const (
+	// CheckSignatureFirst verifies the signature and claims before touching the
+	// repository, so garbage tokens never cause repository lookups. Default.
+	CheckSignatureFirst RepoCheckOrder = "signature_first"
+	// CheckRepositoryFirst consults the revocation repository before parsing.
+	// Only useful when the repository is local and cheaper than verification.
+	CheckRepositoryFirst RepoCheckOrder = "repository_first"
+)
+
+type offlineKey struct{}
+
+// WithOfflineVerification marks ctx so that verification skips every
+// repository round trip (revocation and invalidation-source lookups).
+// Signature, claims and the static Config.NotIssuedBefore cutoff are still
+// enforced. Use it only on hot paths that tolerate eventual revocation.
+//
+// RotateRefreshToken ignores the flag: rotation must always see the repository.
+func WithOfflineVerification(ctx context.Context) context.Context {
+	return context.WithValue(ctx, offlineKey{}, true)
+}
+
+// IsOfflineVerification reports whether ctx was marked by WithOfflineVerification.
+func IsOfflineVerification(ctx context.Context) bool {
+	offline, _ := ctx.Value(offlineKey{}).(bool)
+	return offline
+}
+
+// verify runs signature, claim and repository checks in the configured order.
+// checkRepo=false skips all repository round trips.
+func (tm *TokenMaker) verify(ctx context.Context, tokenString string, tokenType TokenType, checkRepo bool) (*jwt.Token, *TokenClaims, error) {
+	return tm.verifyWith(ctx, tokenString, tokenType, checkRepo, checkRepo)
+}
+
+// verifyWith is verify with the rotation marker lookup of refresh tokens
+// controlled separately from the other repository lookups.
+// RotateRefreshToken skips it when the repository reports an already-rotated
+// token as part of an atomic rotation.
+func (tm *TokenMaker) verifyWith(ctx context.Context, tokenString string, tokenType TokenType, checkRotation, checkRepo bool) (_ *jwt.Token, _ *TokenClaims, err error) {
+	// parsed is set once the token is known to be authentic, so later
+	// failures can be attributed to its subject.
+	var parsed *TokenClaims
+	trace := tm.newTrace(tokenType, tokenString)
+	defer func() {
+		if err != nil && parsed != nil {
+			tm.reportFailure(ctx, tokenType, parsed, err)
+		}
+		tm.recordLockout(ctx, parsed, err)
+		trace.finish(ctx, tm.traceHandler, err)
+	}()
+
+	trace.step(StepSize)
+	if len(tokenString) > tm.policy().maxTokenLength {
+		return nil, nil, ErrTokenTooLarge
+	}
+	if ip := clientIP(ctx); tm.lockout != nil && ip != "" {
+		trace.step(StepLockout)
+		if err := tm.lockout.checkLocked(ctx, ipKey(ip), tm.clock.Now()); err != nil {
+			return nil, nil, err
+		}
+	}
+	trace.step(StepResolve)
+	signed, err := tm.resolve(ctx, tokenType, tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if checkRepo && tm.repoCheckOrder == CheckRepositoryFirst {
+		trace.step(StepRevocationFirst)
+		key, err := tm.unverifiedRevocationKey(tokenString, signed)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := tm.checkRevoked(ctx, tokenType, key, checkRotation); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	trace.step(StepSignature)
+	token, claims, err := tm.parseToken(signed, tokenType)
+	if err != nil {
+		return nil, nil, err
+	}
+	parsed = claims
+	trace.claims(claims)
+	if tm.lockout != nil && tm.lockout.lockSubjects() {
+		trace.step(StepLockout)
+		if err := tm.lockout.checkLocked(ctx, subjectKey(claims.Subject), tm.clock.Now()); err != nil {
+			return nil, nil, err
+		}
+	}
+	trace.step(StepDPoP)
+	if err := checkDPoP(ctx, tm.dpopReplay, tokenString, claims, tm.clock.Now()); err != nil {
+		return nil, nil, err
+	}
+
+	if !checkRepo {
+		trace.step(StepStaticInvalidation)
+		if err := tm.checkStaticInvalidation(claims); err != nil {
+			return nil, nil, err
+		}
+		return token, claims, nil
+	}
+
+	// key stays empty when the token was already checked above.
+	var key string
+	if tm.repoCheckOrder != CheckRepositoryFirst {
+		if key, err = tm.revocationKey(tokenString, claims); err != nil {
+			return nil, nil, err
+		}
+	}
+	trace.step(StepRevocation)
+	if done, err := tm.checkRevocationStatus(ctx, tokenType, key, checkRotation, claims); done {
+		if err != nil {
+			return nil, nil, err
+		}
+		trace.step(StepInvalidation)
+		if err := tm.checkInvalidation(ctx, claims); err != nil {
+			return nil, nil, err
+		}
+		return token, claims, nil
+	}
+	if key != "" {
+		if err := tm.checkRevoked(ctx, tokenType, key, checkRotation); err != nil {
+			return nil, nil, err
+		}
+	}
+	trace.step(StepSession)
+	if err := tm.checkSessionRevoked(ctx, claims); err != nil {
+		return nil, nil, err
+	}
+	trace.step(StepFamily)
+	if err := tm.checkFamilyRevoked(ctx, claims); err != nil {
+		return nil, nil, err
+	}
+	trace.step(StepUser)
+	if err := tm.checkUserRevoked(ctx, claims); err != nil {
+		return nil, nil, err
+	}
+	trace.step(StepInvalidation)
+	if err := tm.checkInvalidation(ctx, claims); err != nil {
+		return nil, nil, err
+	}
+
+	return token, claims, nil
+}
+
+// repoContext returns ctx bounded by the repository timeout, unless the
+// caller already set a deadline.
+func (tm *TokenMaker) repoContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, tm.policy().repoTimeout)
+}
+
+// checkRevoked rejects tokens that were revoked individually and, with
+// checkRotation, refresh tokens that were already rotated. key is the
+// token's repository key; see revocationKey.
+func (tm *TokenMaker) checkRevoked(ctx context.Context, tokenType TokenType, key string, checkRotation bool) error {
+	if tm.repo == nil {
+		return nil
+	}
+
+	rctx, cancel := tm.repoContext(ctx)
+	defer cancel()
+	revoked, err := tm.repo.IsTokenRevoked(rctx, tokenType, key)
+	if err != nil {
+		return fmt.Errorf("check revocation: %w", err)
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	if tokenType != RefreshToken || !checkRotation {
+		return nil
+	}
+
+	// Only RotateRefreshToken leaves this marker, so a token carrying it is
+	// a replayed, already-rotated one.
+	rotated, err := tm.repo.IsTokenRevoked(rctx, RefreshToken, rotationKey(key))
+	if err != nil {
+		return fmt.Errorf("check rotation: %w", err)
+	}
+	if rotated {
+		return ErrTokenRotated
+	}
+	return nil
+}
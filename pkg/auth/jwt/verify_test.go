@@ -0,0 +1,211 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// countingRepo counts IsTokenRevoked lookups.
+type countingRepo struct {
+	*mockRevocationRepo
+	lookups int
+}
+
+func (r *countingRepo) IsTokenRevoked(ctx context.Context, tokenType TokenType, token string) (bool, error) {
+	r.lookups++
+	return r.mockRevocationRepo.IsTokenRevoked(ctx, tokenType, token)
+}
+
+func TestVerify_RepoCheckOrderAndOffline(t *testing.T) {
+	for _, tc := range []struct {
+		order          RepoCheckOrder
+		garbageLookups int
+	}{
+		{order: "", garbageLookups: 0},
+		{order: CheckRepositoryFirst, garbageLookups: 1},
+	} {
+		repo := &countingRepo{mockRevocationRepo: newMockRevocationRepo()}
+		maker, err := NewTokenMaker(Config{
+			Secret:               "test-secret-must-be-at-least-32-bytes",
+			Issuer:               "test-issuer",
+			Audience:             "test-audience",
+			AccessExpiryDuration: time.Hour,
+			RepoCheckOrder:       tc.order,
+		}, repo)
+		if err != nil {
+			t.Fatalf("create token maker: %v", err)
+		}
+
+		ctx := context.Background()
+		if _, err := maker.VerifyAccessToken(ctx, "garbage"); err == nil {
+			t.Fatal("expected garbage token to be rejected")
+		}
+		if repo.lookups != tc.garbageLookups {
+			t.Errorf("order %q: expected %d lookups for garbage token, got %d", tc.order, tc.garbageLookups, repo.lookups)
+		}
+
+		tok, err := maker.CreateAccessToken(ctx, uuid.New(), "test", nil, uuid.New())
+		if err != nil {
+			t.Fatalf("create access token: %v", err)
+		}
+		if err := maker.RevokeAccessToken(ctx, tok.Token); err != nil {
+			t.Fatalf("revoke: %v", err)
+		}
+		if _, err := maker.VerifyAccessToken(ctx, tok.Token); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("order %q: expected ErrTokenRevoked, got %v", tc.order, err)
+		}
+
+		repo.lookups = 0
+		if _, err := maker.VerifyAccessToken(WithOfflineVerification(ctx), tok.Token); err != nil {
+			t.Errorf("order %q: expected offline verification to skip revocation, got %v", tc.order, err)
+		}
+		if repo.lookups != 0 {
+			t.Errorf("order %q: expected no lookups offline, got %d", tc.order, repo.lookups)
+		}
+	}
+}
+
+func TestNewTokenMaker_InvalidRepoCheckOrder(t *testing.T) {
+	_, err := NewTokenMaker(Config{
+		Secret:         "test-secret-must-be-at-least-32-bytes",
+		Issuer:         "test-issuer",
+		Audience:       "test-audience",
+		RepoCheckOrder: "sideways",
+	}, nil)
+	if err == nil {
+		t.Error("expected invalid RepoCheckOrder to be rejected")
+	}
+}
+
+// atomicRepo adds AtomicRotationRepository to countingRepo.
+type atomicRepo struct {
+	*countingRepo
+}
+
+func (r *atomicRepo) MarkTokenRotated(_ context.Context, token string, _ time.Duration) (bool, error) {
+	if _, ok := r.revoked[token]; ok {
+		return false, nil
+	}
+	r.revoked[token] = struct{}{}
+	return true, nil
+}
+
+func (r *atomicRepo) MarkTokenConsumed(ctx context.Context, _ TokenType, token string, ttl time.Duration) (bool, error) {
+	return r.MarkTokenRotated(ctx, token, ttl)
+}
+
+func TestRotateRefreshToken_AtomicSkipsLookup(t *testing.T) {
+	repo := &atomicRepo{countingRepo: &countingRepo{mockRevocationRepo: newMockRevocationRepo()}}
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		RefreshExpiryDuration: time.Hour,
+	}, repo)
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := context.Background()
+	tok, err := maker.CreateRefreshToken(ctx, uuid.New(), "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if _, err := maker.RotateRefreshToken(ctx, tok.Token); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if _, err := maker.RotateRefreshToken(ctx, tok.Token); !errors.Is(err, ErrTokenRotated) {
+		t.Errorf("expected ErrTokenRotated on replay, got %v", err)
+	}
+	// Each rotation still checks whether the token was revoked, but the
+	// rotation marker itself is only consulted through MarkTokenRotated.
+	if repo.lookups != 2 {
+		t.Errorf("expected one revocation lookup per rotation with atomic rotation, got %d", repo.lookups)
+	}
+}
+
+func TestVerifyRefreshToken_RevokedIsNotRotated(t *testing.T) {
+	repo := newMockRevocationRepo()
+	maker, err := NewTokenMaker(Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		RefreshExpiryDuration: time.Hour,
+	}, repo)
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	ctx := context.Background()
+	revoked, err := maker.CreateRefreshToken(ctx, uuid.New(), "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if err := MarkTokensRevoked(ctx, repo, RefreshToken, []string{HashToken(revoked.Token)}, time.Hour); err != nil {
+		t.Fatalf("revoke refresh token: %v", err)
+	}
+	if _, err := maker.VerifyRefreshToken(ctx, revoked.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("expected ErrTokenRevoked for a revoked refresh token, got %v", err)
+	}
+	if _, err := maker.RotateRefreshToken(ctx, revoked.Token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("expected rotating a revoked refresh token to fail with ErrTokenRevoked, got %v", err)
+	}
+
+	rotated, err := maker.CreateRefreshToken(ctx, uuid.New(), "test", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	if _, err := maker.RotateRefreshToken(ctx, rotated.Token); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if _, err := maker.VerifyRefreshToken(ctx, rotated.Token); !errors.Is(err, ErrTokenRotated) {
+		t.Errorf("expected ErrTokenRotated for a rotated refresh token, got %v", err)
+	}
+}
+
+// stuckRepo blocks every lookup until its context is done.
+type stuckRepo struct {
+	*mockRevocationRepo
+}
+
+func (stuckRepo) IsTokenRevoked(ctx context.Context, _ TokenType, _ string) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func TestVerify_RepositoryTimeout(t *testing.T) {
+	maker, err := NewTokenMaker(Config{
+		Secret:               "test-secret-must-be-at-least-32-bytes",
+		Issuer:               "test-issuer",
+		Audience:             "test-audience",
+		AccessExpiryDuration: time.Hour,
+		RepositoryTimeout:    20 * time.Millisecond,
+	}, stuckRepo{newMockRevocationRepo()})
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+	ctx := context.Background()
+	token, err := maker.CreateAccessToken(ctx, uuid.New(), "alice", nil, uuid.New())
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := maker.VerifyAccessToken(ctx, token.Token); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("verification took %v despite the repository timeout", elapsed)
+	}
+
+	// A caller's own deadline takes precedence.
+	ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	if _, err := maker.VerifyAccessToken(ctx, token.Token); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
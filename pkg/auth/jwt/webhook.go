@@ -0,0 +1,229 @@
+package jwt
+
+import (
+	"context"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhookSignatureHeader carries the signature of a webhook delivery as
+// "t=<unix seconds>,v1=<hex HMAC>". Several v1 entries may be present while
+// an endpoint secret is rotated; one matching is enough.
+const WebhookSignatureHeader = "X-Webhook-Signature"
+
+// DefaultWebhookTolerance is how far a delivery's timestamp may be from the
+// receiver's clock.
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// webhookSecretBytes is the length of derived endpoint secrets.
+const webhookSecretBytes = 32
+
+// ErrInvalidWebhook is returned for all webhook verification failures. The
+// errors below wrap it.
+var ErrInvalidWebhook = errors.New("invalid webhook signature")
+
+var (
+	// ErrWebhookTimestamp is returned when the signature's timestamp is
+	// missing or outside the tolerance.
+	ErrWebhookTimestamp = fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidWebhook)
+
+	// ErrWebhookReplayed is returned when a delivery was already accepted.
+	ErrWebhookReplayed = fmt.Errorf("%w: delivery replayed", ErrInvalidWebhook)
+)
+
+// WebhookReplayCache remembers accepted deliveries so each is accepted only
+// once. Without one, a captured delivery can be replayed until it falls out
+// of the tolerance.
+type WebhookReplayCache interface {
+	// MarkWebhookSeen records id for ttl and reports whether it was new.
+	MarkWebhookSeen(ctx context.Context, id string, ttl time.Duration) (bool, error)
+}
+
+// WebhookConfig configures webhook signing and verification.
+type WebhookConfig struct {
+	// Secret is the master secret endpoint secrets are derived from, or,
+	// for NewWebhookVerifier, the endpoint secret itself as returned by
+	// EndpointSecret.
+	Secret string `json:",optional" secret:"true"`
+	// Algorithm is HS256 (default), HS384 or HS512.
+	Algorithm string `json:",optional"`
+	// Tolerance defaults to DefaultWebhookTolerance.
+	Tolerance time.Duration `json:",optional"`
+	// Clock defaults to SystemClock if nil.
+	Clock Clock `json:"-"`
+	// ReplayCache, if set, rejects deliveries seen before.
+	ReplayCache WebhookReplayCache `json:"-"`
+}
+
+// webhookMAC signs and checks deliveries with one key.
+type webhookMAC struct {
+	alg       string
+	tolerance time.Duration
+	clock     Clock
+	replay    WebhookReplayCache
+}
+
+func newWebhookMAC(cfg WebhookConfig) (webhookMAC, error) {
+	if cfg.Secret == "" {
+		return webhookMAC{}, fmt.Errorf("config.Secret is required")
+	}
+	method, err := resolveSymmetricMethod("Algorithm", cfg.Algorithm)
+	if err != nil {
+		return webhookMAC{}, err
+	}
+	if cfg.Tolerance < 0 {
+		return webhookMAC{}, fmt.Errorf("config.Tolerance must not be negative")
+	}
+	if cfg.Tolerance == 0 {
+		cfg.Tolerance = DefaultWebhookTolerance
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = SystemClock{}
+	}
+	return webhookMAC{alg: method.Alg(), tolerance: cfg.Tolerance, clock: cfg.Clock, replay: cfg.ReplayCache}, nil
+}
+
+// mac returns the hex HMAC of "<timestamp>.<payload>".
+func (w webhookMAC) mac(key []byte, ts int64, payload []byte) string {
+	h := hmac.New(symmetricMethods[w.alg].Hash.New, key)
+	h.Write([]byte(strconv.FormatInt(ts, 10)))
+	h.Write([]byte{'.'})
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (w webhookMAC) sign(key []byte, payload []byte) string {
+	ts := w.clock.Now().Unix()
+	return fmt.Sprintf("t=%d,v1=%s", ts, w.mac(key, ts, payload))
+}
+
+func (w webhookMAC) verify(ctx context.Context, key []byte, header string, payload []byte) error {
+	var (
+		ts   int64
+		sigs []string
+	)
+	for part := range strings.SplitSeq(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts, _ = strconv.ParseInt(v, 10, 64)
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	if ts <= 0 {
+		return ErrWebhookTimestamp
+	}
+	if len(sigs) == 0 {
+		return fmt.Errorf("%w: no v1 signature", ErrInvalidWebhook)
+	}
+
+	want := w.mac(key, ts, payload)
+	var match string
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), []byte(want)) {
+			match = sig
+			break
+		}
+	}
+	if match == "" {
+		return ErrInvalidWebhook
+	}
+	// The timestamp is signed, so it is only trusted once the MAC matched.
+	if d := w.clock.Now().Sub(time.Unix(ts, 0)); d > w.tolerance || d < -w.tolerance {
+		return ErrWebhookTimestamp
+	}
+
+	if w.replay != nil {
+		fresh, err := w.replay.MarkWebhookSeen(ctx, match, 2*w.tolerance)
+		if err != nil {
+			return fmt.Errorf("check webhook replay: %w", err)
+		}
+		if !fresh {
+			return ErrWebhookReplayed
+		}
+	}
+	return nil
+}
+
+// WebhookSigner signs outgoing webhook deliveries. Each endpoint gets its
+// own secret, derived from the master secret with HKDF, so endpoints cannot
+// forge each other's deliveries and no per-endpoint secret has to be
+// stored.
+type WebhookSigner struct {
+	master []byte
+	mac    webhookMAC
+}
+
+// NewWebhookSigner returns a signer deriving endpoint secrets from
+// cfg.Secret.
+func NewWebhookSigner(cfg WebhookConfig) (*WebhookSigner, error) {
+	mac, err := newWebhookMAC(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookSigner{master: []byte(cfg.Secret), mac: mac}, nil
+}
+
+func (s *WebhookSigner) endpointKey(endpointID string) []byte {
+	key, err := hkdf.Key(sha256.New, s.master, nil, "webhook endpoint "+endpointID, webhookSecretBytes)
+	if err != nil {
+		// Only possible for lengths beyond 255 hash blocks.
+		panic(err)
+	}
+	return key
+}
+
+// EndpointSecret returns the secret of endpointID to hand to its owner, who
+// verifies deliveries with NewWebhookVerifier. Changing the master secret
+// changes every endpoint secret.
+func (s *WebhookSigner) EndpointSecret(endpointID string) string {
+	return base64.RawURLEncoding.EncodeToString(s.endpointKey(endpointID))
+}
+
+// Sign returns the WebhookSignatureHeader value for delivering payload to
+// endpointID now.
+func (s *WebhookSigner) Sign(endpointID string, payload []byte) string {
+	return s.mac.sign(s.endpointKey(endpointID), payload)
+}
+
+// Verify checks a delivery to endpointID, for receivers that hold the master
+// secret themselves.
+func (s *WebhookSigner) Verify(ctx context.Context, endpointID, header string, payload []byte) error {
+	return s.mac.verify(ctx, s.endpointKey(endpointID), header, payload)
+}
+
+// WebhookVerifier checks the deliveries of one endpoint with its secret.
+type WebhookVerifier struct {
+	key []byte
+	mac webhookMAC
+}
+
+// NewWebhookVerifier returns a verifier for the endpoint secret in
+// cfg.Secret.
+func NewWebhookVerifier(cfg WebhookConfig) (*WebhookVerifier, error) {
+	mac, err := newWebhookMAC(cfg)
+	if err != nil {
+		return nil, err
+	}
+	key, err := decodeEncryptionKey(cfg.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("config.Secret: %w", err)
+	}
+	return &WebhookVerifier{key: key, mac: mac}, nil
+}
+
+// Verify checks header, the WebhookSignatureHeader of a delivery, against
+// its raw body payload: the MAC, the timestamp tolerance and, with a
+// ReplayCache, that the delivery is new. Failures wrap ErrInvalidWebhook.
+func (v *WebhookVerifier) Verify(ctx context.Context, header string, payload []byte) error {
+	return v.mac.verify(ctx, v.key, header, payload)
+}
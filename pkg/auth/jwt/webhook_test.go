@@ -0,0 +1,93 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mapWebhookReplayCache map[string]bool
+
+func (c mapWebhookReplayCache) MarkWebhookSeen(_ context.Context, id string, _ time.Duration) (bool, error) {
+	if c[id] {
+		return false, nil
+	}
+	c[id] = true
+	return true, nil
+}
+
+func TestWebhookSigning(t *testing.T) {
+	ctx := context.Background()
+	clock := newFakeClock()
+	signer, err := NewWebhookSigner(WebhookConfig{Secret: "webhook-master-secret-at-least-32-bytes", Clock: clock})
+	if err != nil {
+		t.Fatalf("create signer: %v", err)
+	}
+	if signer.EndpointSecret("ep1") == signer.EndpointSecret("ep2") {
+		t.Fatal("endpoints share a secret")
+	}
+
+	verifier, err := NewWebhookVerifier(WebhookConfig{
+		Secret:      signer.EndpointSecret("ep1"),
+		Clock:       clock,
+		ReplayCache: mapWebhookReplayCache{},
+	})
+	if err != nil {
+		t.Fatalf("create verifier: %v", err)
+	}
+
+	payload := []byte(`{"event":"goal.completed"}`)
+	header := signer.Sign("ep1", payload)
+	if !strings.HasPrefix(header, "t=1700000000,v1=") {
+		t.Errorf("header = %q", header)
+	}
+	if err := signer.Verify(ctx, "ep1", header, payload); err != nil {
+		t.Errorf("signer verify: %v", err)
+	}
+	if err := verifier.Verify(ctx, header, payload); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err := verifier.Verify(ctx, header, payload); !errors.Is(err, ErrWebhookReplayed) {
+		t.Errorf("replay: err = %v, want ErrWebhookReplayed", err)
+	}
+
+	tests := []struct {
+		name    string
+		header  string
+		payload []byte
+		err     error
+	}{
+		{"tampered payload", signer.Sign("ep1", payload), []byte(`{"event":"other"}`), ErrInvalidWebhook},
+		{"other endpoint", signer.Sign("ep2", payload), payload, ErrInvalidWebhook},
+		{"no timestamp", "v1=abc", payload, ErrWebhookTimestamp},
+		{"no signature", "t=1700000000", payload, ErrInvalidWebhook},
+		{"rotated secret", "t=1700000000,v1=00," + strings.TrimPrefix(signer.Sign("ep1", []byte("x")), "t=1700000000,"), []byte("x"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifier.Verify(ctx, tt.header, tt.payload); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	old := signer.Sign("ep1", []byte("late"))
+	clock.Advance(DefaultWebhookTolerance + time.Second)
+	if err := verifier.Verify(ctx, old, []byte("late")); !errors.Is(err, ErrWebhookTimestamp) {
+		t.Errorf("stale: err = %v, want ErrWebhookTimestamp", err)
+	}
+}
+
+func TestNewWebhookSigner_Validation(t *testing.T) {
+	if _, err := NewWebhookSigner(WebhookConfig{}); err == nil {
+		t.Error("expected error without secret")
+	}
+	if _, err := NewWebhookSigner(WebhookConfig{Secret: "s", Algorithm: "RS256"}); err == nil {
+		t.Error("expected error for non-HMAC algorithm")
+	}
+	if _, err := NewWebhookVerifier(WebhookConfig{Secret: "not base64!"}); err == nil {
+		t.Error("expected error for undecodable endpoint secret")
+	}
+}
@@ -14,8 +14,8 @@ import (
 )
 
 // TokenVerifier is the interface required by PrincipalFromMetadata to verify
-// the propagated JWT. Implementations must return ErrInvalidToken for any
-// verification failure.
+// the propagated JWT. Implementations must return an error wrapping
+// jwt.ErrInvalidToken for any verification failure.
 type TokenVerifier interface {
 	VerifyAccessToken(ctx context.Context, tokenString string) (*jwt.TokenClaims, error)
 }
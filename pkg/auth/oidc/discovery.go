@@ -0,0 +1,168 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// Well-known paths, relative to the issuer.
+const (
+	DiscoveryPath = "/.well-known/openid-configuration"
+	JWKSPath      = "/.well-known/jwks.json"
+)
+
+// Discovery is the OpenID Provider Metadata document of OpenID Connect
+// Discovery 1.0 section 3, limited to what Provider supports.
+type Discovery struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                    string   `json:"token_endpoint,omitempty"`
+	UserInfoEndpoint                 string   `json:"userinfo_endpoint,omitempty"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                  []string `json:"scopes_supported,omitempty"`
+	ClaimsSupported                  []string `json:"claims_supported,omitempty"`
+}
+
+// JWK is a public JSON Web Key (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP.
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+var defaultClaims = []string{
+	"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "acr", "amr", "azp",
+	"at_hash", "sid", "preferred_username", "name", "email", "email_verified",
+}
+
+// Discovery returns the provider metadata.
+func (p *Provider) Discovery() Discovery {
+	issuer := strings.TrimSuffix(p.cfg.Issuer, "/")
+	jwksURI := p.cfg.JWKSURI
+	if jwksURI == "" {
+		jwksURI = issuer + JWKSPath
+	}
+	scopes := p.cfg.ScopesSupported
+	if scopes == nil {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	claims := p.cfg.ClaimsSupported
+	if claims == nil {
+		claims = defaultClaims
+	}
+
+	var algs []string
+	seen := make(map[string]bool)
+	for _, k := range p.keys {
+		if alg := k.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+
+	return Discovery{
+		Issuer:                           p.cfg.Issuer,
+		AuthorizationEndpoint:            p.cfg.AuthorizationEndpoint,
+		TokenEndpoint:                    p.cfg.TokenEndpoint,
+		UserInfoEndpoint:                 p.cfg.UserInfoEndpoint,
+		JWKSURI:                          jwksURI,
+		ResponseTypesSupported:           []string{"code", "id_token", "code id_token"},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: algs,
+		ScopesSupported:                  scopes,
+		ClaimsSupported:                  claims,
+	}
+}
+
+// JWKS returns the public halves of every configured key.
+func (p *Provider) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0, len(p.keys))}
+	for _, k := range p.keys {
+		set.Keys = append(set.Keys, publicJWK(k))
+	}
+	return set
+}
+
+func publicJWK(s signer) JWK {
+	jwk := JWK{KeyID: s.id, Use: "sig", Algorithm: s.method.Alg()}
+	switch pub := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = b64(pub)
+	}
+	return jwk
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DiscoveryHandler serves the discovery document.
+func (p *Provider) DiscoveryHandler() http.Handler {
+	return jsonHandler(p.Discovery())
+}
+
+// JWKSHandler serves the JWKS.
+func (p *Provider) JWKSHandler() http.Handler {
+	return jsonHandler(p.JWKS())
+}
+
+// Handler serves DiscoveryPath and JWKSPath and responds 404 to anything
+// else, for mounting at the issuer's root.
+func (p *Provider) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET "+DiscoveryPath, p.DiscoveryHandler())
+	mux.Handle("GET "+JWKSPath, p.JWKSHandler())
+	return mux
+}
+
+// jsonHandler serves v, which is encoded once since keys and metadata only
+// change with a new Provider.
+func jsonHandler(v any) http.Handler {
+	body, err := json.Marshal(v)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			logx.WithContext(r.Context()).Errorf("oidc: encode %T: %v", v, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		w.Write(body)
+	})
+}
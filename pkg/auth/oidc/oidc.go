@@ -0,0 +1,296 @@
+// Package oidc issues OpenID Connect ID tokens and serves the discovery
+// document and JWKS, enough to back a minimal OpenID Provider for internal
+// apps. Access and refresh tokens stay with jwt.TokenMaker; ID tokens are
+// signed with an asymmetric key so relying parties can verify them from the
+// published JWKS without sharing a secret.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// DefaultIDTokenExpiry is the lifetime of ID tokens when
+// Config.IDTokenExpiry is zero.
+const DefaultIDTokenExpiry = 5 * time.Minute
+
+// ErrUnsupportedKey is returned for signing keys other than RSA, ECDSA
+// (P-256, P-384, P-521) and Ed25519.
+var ErrUnsupportedKey = errors.New("oidc: unsupported signing key")
+
+// Config configures a Provider.
+type Config struct {
+	// Issuer is the iss claim and the base of the discovery URL. It must be
+	// an https URL without query or fragment, e.g. "https://auth.example.com".
+	Issuer string
+	// IDTokenExpiry defaults to DefaultIDTokenExpiry.
+	IDTokenExpiry time.Duration `json:",optional"`
+	// AuthorizationEndpoint, TokenEndpoint and UserInfoEndpoint are published
+	// in the discovery document. JWKSURI defaults to the issuer plus JWKSPath.
+	AuthorizationEndpoint string `json:",optional"`
+	TokenEndpoint         string `json:",optional"`
+	UserInfoEndpoint      string `json:",optional"`
+	JWKSURI               string `json:",optional"`
+	// ScopesSupported and ClaimsSupported default to the scopes and claims
+	// IssueIDToken can fill.
+	ScopesSupported []string `json:",optional"`
+	ClaimsSupported []string `json:",optional"`
+}
+
+// SigningKey is a private key used to sign ID tokens. ID is published as
+// the JWK kid and written to the token header.
+type SigningKey struct {
+	ID  string
+	Key crypto.Signer
+}
+
+type signer struct {
+	id     string
+	key    crypto.Signer
+	method gojwt.SigningMethod
+	hash   func() hash.Hash
+}
+
+// Provider issues ID tokens and describes itself to relying parties.
+type Provider struct {
+	cfg    Config
+	expiry time.Duration
+	clock  jwt.Clock
+	// keys[0] signs; the rest are only published, so tokens signed before a
+	// rotation keep verifying until they expire.
+	keys          []signer
+	slowThreshold time.Duration
+	slowHandler   jwt.SlowOperationHandler
+}
+
+// Option configures a Provider.
+type Option func(*Provider)
+
+// WithClock sets the clock used for iat and exp.
+func WithClock(c jwt.Clock) Option {
+	return func(p *Provider) { p.clock = c }
+}
+
+// WithSlowOperationWarnings reports ID token signing that takes longer than
+// threshold to h, or logs it if h is nil, to catch oversized RSA keys. The
+// report's Backend is the signing key ID.
+func WithSlowOperationWarnings(threshold time.Duration, h jwt.SlowOperationHandler) Option {
+	return func(p *Provider) { p.slowThreshold, p.slowHandler = threshold, h }
+}
+
+// NewProvider returns a Provider signing with keys[0] and publishing every
+// key in keys, so a new key can be added ahead of a rotation and the old one
+// kept after it.
+func NewProvider(cfg Config, keys []SigningKey, opts ...Option) (*Provider, error) {
+	if cfg.Issuer == "" {
+		return nil, fmt.Errorf("config.Issuer is required")
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one signing key is required")
+	}
+	if cfg.IDTokenExpiry < 0 {
+		return nil, fmt.Errorf("config.IDTokenExpiry must not be negative")
+	}
+
+	p := &Provider{cfg: cfg, expiry: cfg.IDTokenExpiry, clock: jwt.SystemClock{}}
+	if p.expiry == 0 {
+		p.expiry = DefaultIDTokenExpiry
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if k.ID == "" {
+			return nil, fmt.Errorf("signing key id is required")
+		}
+		if seen[k.ID] {
+			return nil, fmt.Errorf("duplicate signing key id %q", k.ID)
+		}
+		seen[k.ID] = true
+		s, err := newSigner(k)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", k.ID, err)
+		}
+		p.keys = append(p.keys, s)
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+func newSigner(k SigningKey) (signer, error) {
+	s := signer{id: k.ID, key: k.Key}
+	switch key := k.Key.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < 2048 {
+			return signer{}, fmt.Errorf("%w: RSA keys must be at least 2048 bits", ErrUnsupportedKey)
+		}
+		s.method, s.hash = gojwt.SigningMethodRS256, sha256.New
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			s.method, s.hash = gojwt.SigningMethodES256, sha256.New
+		case elliptic.P384():
+			s.method, s.hash = gojwt.SigningMethodES384, sha512.New384
+		case elliptic.P521():
+			s.method, s.hash = gojwt.SigningMethodES512, sha512.New
+		default:
+			return signer{}, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, key.Curve.Params().Name)
+		}
+	case ed25519.PrivateKey:
+		s.method, s.hash = gojwt.SigningMethodEdDSA, sha512.New
+	default:
+		return signer{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, k.Key)
+	}
+	return s, nil
+}
+
+// IDTokenClaims are the claims of an ID token, using the names of OpenID
+// Connect Core 1.0 section 2 and the standard profile claims of section 5.1.
+type IDTokenClaims struct {
+	Issuer    string             `json:"iss"`
+	Subject   string             `json:"sub"`
+	Audience  gojwt.ClaimStrings `json:"aud"`
+	ExpiresAt *gojwt.NumericDate `json:"exp"`
+	IssuedAt  *gojwt.NumericDate `json:"iat"`
+	AuthTime  *gojwt.NumericDate `json:"auth_time,omitempty"`
+	Nonce     string             `json:"nonce,omitempty"`
+	ACR       string             `json:"acr,omitempty"`
+	AMR       []string           `json:"amr,omitempty"`
+	// AuthorizedParty is set when the token has more than one audience.
+	AuthorizedParty string `json:"azp,omitempty"`
+	AccessTokenHash string `json:"at_hash,omitempty"`
+	SessionID       string `json:"sid,omitempty"`
+
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Name              string `json:"name,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
+
+func (c *IDTokenClaims) GetExpirationTime() (*gojwt.NumericDate, error) { return c.ExpiresAt, nil }
+func (c *IDTokenClaims) GetIssuedAt() (*gojwt.NumericDate, error)       { return c.IssuedAt, nil }
+func (c *IDTokenClaims) GetNotBefore() (*gojwt.NumericDate, error)      { return nil, nil }
+func (c *IDTokenClaims) GetIssuer() (string, error)                     { return c.Issuer, nil }
+func (c *IDTokenClaims) GetSubject() (string, error)                    { return c.Subject, nil }
+func (c *IDTokenClaims) GetAudience() (gojwt.ClaimStrings, error)       { return c.Audience, nil }
+
+// IDTokenRequest describes the authentication an ID token asserts.
+type IDTokenRequest struct {
+	Subject   uuid.UUID
+	SessionID uuid.UUID
+	// ClientID is the relying party the token is issued to; Audience adds
+	// further audiences, in which case azp is set to ClientID.
+	ClientID string
+	Audience []string
+	// Nonce is copied from the authentication request, if it had one.
+	Nonce string
+	// AuthTime is when the user authenticated.
+	AuthTime time.Time
+	ACR      string
+	AMR      []string
+	// AccessToken, if set, is the access token issued alongside; its hash is
+	// written to at_hash.
+	AccessToken string
+
+	Username      string
+	Name          string
+	Email         string
+	EmailVerified bool
+}
+
+// IssueIDToken signs an ID token for req.
+func (p *Provider) IssueIDToken(ctx context.Context, req IDTokenRequest) (*jwt.TokenResponse, error) {
+	if req.Subject == uuid.Nil {
+		return nil, fmt.Errorf("subject is required")
+	}
+	if req.ClientID == "" {
+		return nil, fmt.Errorf("client id is required")
+	}
+
+	s := p.keys[0]
+	now := p.clock.Now()
+	claims := IDTokenClaims{
+		Issuer:            p.cfg.Issuer,
+		Subject:           req.Subject.String(),
+		Audience:          append([]string{req.ClientID}, req.Audience...),
+		ExpiresAt:         gojwt.NewNumericDate(now.Add(p.expiry)),
+		IssuedAt:          gojwt.NewNumericDate(now),
+		Nonce:             req.Nonce,
+		ACR:               req.ACR,
+		AMR:               req.AMR,
+		PreferredUsername: req.Username,
+		Name:              req.Name,
+		Email:             req.Email,
+	}
+	if !req.AuthTime.IsZero() {
+		claims.AuthTime = gojwt.NewNumericDate(req.AuthTime)
+	}
+	if len(claims.Audience) > 1 {
+		claims.AuthorizedParty = req.ClientID
+	}
+	if req.SessionID != uuid.Nil {
+		claims.SessionID = req.SessionID.String()
+	}
+	if req.Email != "" {
+		claims.EmailVerified = &req.EmailVerified
+	}
+	if req.AccessToken != "" {
+		claims.AccessTokenHash = tokenHash(s.hash, req.AccessToken)
+	}
+
+	token := gojwt.NewWithClaims(s.method, &claims)
+	token.Header["kid"] = s.id
+	start := time.Now()
+	signed, err := token.SignedString(s.key)
+	jwt.ReportSlow(ctx, p.slowHandler, jwt.SlowOperation{
+		Operation: jwt.OpSign,
+		Backend:   s.id,
+		Duration:  time.Since(start),
+		Threshold: p.slowThreshold,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sign id token: %w", err)
+	}
+	return &jwt.TokenResponse{Token: signed, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
+
+// AccessTokenHash returns the at_hash of accessToken for an ID token signed
+// with alg: the base64url-encoded left half of the hash alg uses. EdDSA
+// uses SHA-512, as Ed25519 does internally.
+func AccessTokenHash(alg, accessToken string) (string, error) {
+	var h func() hash.Hash
+	switch alg {
+	case "RS256", "ES256", "PS256":
+		h = sha256.New
+	case "RS384", "ES384", "PS384":
+		h = sha512.New384
+	case "RS512", "ES512", "PS512", "EdDSA":
+		h = sha512.New
+	default:
+		return "", fmt.Errorf("oidc: no at_hash function for alg %q", alg)
+	}
+	return tokenHash(h, accessToken), nil
+}
+
+func tokenHash(h func() hash.Hash, token string) string {
+	d := h()
+	d.Write([]byte(token))
+	sum := d.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
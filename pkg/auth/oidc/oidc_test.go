@@ -0,0 +1,217 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type fixedClock struct{ t time.Time }
+
+func (c fixedClock) Now() time.Time { return c.t }
+
+func TestIssueIDToken(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		key  SigningKey
+		pub  any
+	}{
+		{"ES256", SigningKey{ID: "ec", Key: ecKey}, &ecKey.PublicKey},
+		{"EdDSA", SigningKey{ID: "ed", Key: edKey}, edKey.Public()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now().Truncate(time.Second)
+			p, err := NewProvider(Config{Issuer: "https://auth.example.com"}, []SigningKey{tt.key}, WithClock(fixedClock{now}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			userID, sessionID := uuid.New(), uuid.New()
+			resp, err := p.IssueIDToken(context.Background(), IDTokenRequest{
+				Subject:       userID,
+				SessionID:     sessionID,
+				ClientID:      "admin-console",
+				Nonce:         "n-0S6_WzA2Mj",
+				AuthTime:      now.Add(-time.Minute),
+				AccessToken:   "access-token",
+				Username:      "jane",
+				Email:         "jane@example.com",
+				EmailVerified: true,
+			})
+			if err != nil {
+				t.Fatalf("IssueIDToken: %v", err)
+			}
+			if !resp.ExpiresAt.Equal(now.Add(DefaultIDTokenExpiry)) {
+				t.Errorf("ExpiresAt = %v, want %v", resp.ExpiresAt, now.Add(DefaultIDTokenExpiry))
+			}
+
+			var claims IDTokenClaims
+			token, err := gojwt.ParseWithClaims(resp.Token, &claims, func(*gojwt.Token) (any, error) { return tt.pub, nil },
+				gojwt.WithTimeFunc(func() time.Time { return now }))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if kid := token.Header["kid"]; kid != tt.key.ID {
+				t.Errorf("kid = %v, want %s", kid, tt.key.ID)
+			}
+			wantHash, _ := AccessTokenHash(tt.name, "access-token")
+			switch {
+			case claims.Issuer != "https://auth.example.com":
+				t.Errorf("iss = %q", claims.Issuer)
+			case claims.Subject != userID.String() || claims.SessionID != sessionID.String():
+				t.Errorf("sub/sid = %q/%q", claims.Subject, claims.SessionID)
+			case len(claims.Audience) != 1 || claims.Audience[0] != "admin-console" || claims.AuthorizedParty != "":
+				t.Errorf("aud/azp = %v/%q", claims.Audience, claims.AuthorizedParty)
+			case claims.Nonce != "n-0S6_WzA2Mj":
+				t.Errorf("nonce = %q", claims.Nonce)
+			case claims.AccessTokenHash != wantHash:
+				t.Errorf("at_hash = %q, want %q", claims.AccessTokenHash, wantHash)
+			case claims.AuthTime == nil || !claims.AuthTime.Equal(now.Add(-time.Minute)):
+				t.Errorf("auth_time = %v", claims.AuthTime)
+			case claims.EmailVerified == nil || !*claims.EmailVerified || claims.PreferredUsername != "jane":
+				t.Errorf("profile claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestIssueIDTokenAuthorizedParty(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	p, err := NewProvider(Config{Issuer: "https://auth.example.com"}, []SigningKey{{ID: "k", Key: key}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := p.IssueIDToken(context.Background(), IDTokenRequest{Subject: uuid.New(), ClientID: "web", Audience: []string{"api"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims IDTokenClaims
+	if _, _, err := gojwt.NewParser().ParseUnverified(resp.Token, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.AuthorizedParty != "web" || len(claims.Audience) != 2 {
+		t.Errorf("aud/azp = %v/%q, want [web api]/web", claims.Audience, claims.AuthorizedParty)
+	}
+}
+
+func TestAccessTokenHash(t *testing.T) {
+	got, err := AccessTokenHash("RS256", "jHkWEdUXMU1BwAsC4vtUsZwnNeEWk_bIYq6JrkuZsbM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "AfEzU5i_RmqWMftaDyhSYg"; got != want {
+		t.Errorf("AccessTokenHash = %q, want %q", got, want)
+	}
+	if _, err := AccessTokenHash("HS256", "x"); err == nil {
+		t.Error("expected an error for HS256")
+	}
+}
+
+func TestNewProviderRejectsKeys(t *testing.T) {
+	small, _ := rsa.GenerateKey(rand.Reader, 1024)
+	p224, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	_, ed, _ := ed25519.GenerateKey(rand.Reader)
+	cfg := Config{Issuer: "https://auth.example.com"}
+
+	for name, keys := range map[string][]SigningKey{
+		"rsa1024":   {{ID: "a", Key: small}},
+		"p224":      {{ID: "a", Key: p224}},
+		"no kid":    {{Key: ed}},
+		"duplicate": {{ID: "a", Key: ed}, {ID: "a", Key: ed}},
+		"none":      nil,
+	} {
+		if _, err := NewProvider(cfg, keys); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := NewProvider(cfg, []SigningKey{{ID: "a", Key: p224}}); !errors.Is(err, ErrUnsupportedKey) {
+		t.Errorf("p224: err = %v, want ErrUnsupportedKey", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p, err := NewProvider(Config{
+		Issuer:                "https://auth.example.com",
+		AuthorizationEndpoint: "https://auth.example.com/authorize",
+	}, []SigningKey{{ID: "new", Key: rsaKey}, {ID: "old", Key: ecKey}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(p.Handler())
+	defer srv.Close()
+
+	var doc Discovery
+	getJSON(t, srv.URL+DiscoveryPath, &doc)
+	if doc.Issuer != "https://auth.example.com" || doc.JWKSURI != "https://auth.example.com"+JWKSPath {
+		t.Errorf("issuer/jwks_uri = %q/%q", doc.Issuer, doc.JWKSURI)
+	}
+	if len(doc.IDTokenSigningAlgValuesSupported) != 2 || doc.IDTokenSigningAlgValuesSupported[0] != "RS256" {
+		t.Errorf("id_token_signing_alg_values_supported = %v, want [RS256 ES256]", doc.IDTokenSigningAlgValuesSupported)
+	}
+
+	var set JWKS
+	getJSON(t, srv.URL+JWKSPath, &set)
+	if len(set.Keys) != 2 {
+		t.Fatalf("got %d keys, want 2", len(set.Keys))
+	}
+	// A token signed with the current key verifies against the published JWK.
+	k := set.Keys[0]
+	if k.KeyType != "RSA" || k.KeyID != "new" || k.Algorithm != "RS256" {
+		t.Fatalf("jwk = %+v", k)
+	}
+	n, _ := base64.RawURLEncoding.DecodeString(k.N)
+	e, _ := base64.RawURLEncoding.DecodeString(k.E)
+	pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	resp, err := p.IssueIDToken(context.Background(), IDTokenRequest{Subject: uuid.New(), ClientID: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gojwt.Parse(resp.Token, func(*gojwt.Token) (any, error) { return pub, nil }); err != nil {
+		t.Errorf("verify with published key: %v", err)
+	}
+	if ec := set.Keys[1]; ec.KeyType != "EC" || ec.Curve != "P-256" || len(ec.X) != 43 || len(ec.Y) != 43 {
+		t.Errorf("ec jwk = %+v", ec)
+	}
+}
+
+func getJSON(t *testing.T, url string, v any) {
+	t.Helper()
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: status %d", url, res.StatusCode)
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,247 @@
+// Package bloom puts an in-process bloom filter in front of a
+// jwt.RevocationRepository so the common "not revoked" answer needs no
+// network hop; only filter hits reach the repository.
+//
+// The filter is fed by revocations written through this repository and
+// rebuilt from the underlying store by Rebuild, which also picks up
+// revocations made by other instances. Until the next rebuild such a
+// revocation is not seen here, so the rebuild interval bounds revocation
+// latency across instances. The underlying repository must implement Lister.
+package bloom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// Lister enumerates unexpired revocations of a token type.
+type Lister interface {
+	ListRevoked(ctx context.Context, tokenType jwt.TokenType, fn func(token string) error) error
+}
+
+// Repository is a bloom-filtered jwt.RevocationRepository.
+type Repository struct {
+	repo     jwt.RevocationRepository
+	lister   Lister
+	expected uint64
+	fpRate   float64
+
+	mu      sync.RWMutex
+	current *filter
+	// pending receives writes while Rebuild is filling a replacement filter.
+	pending *filter
+	count   uint64
+}
+
+// NewRepository wraps repo with a filter sized for expectedEntries
+// revocations at falsePositiveRate. The filter is empty until Rebuild runs;
+// call it before serving traffic.
+func NewRepository(repo jwt.RevocationRepository, expectedEntries uint64, falsePositiveRate float64) (*Repository, error) {
+	if repo == nil {
+		return nil, fmt.Errorf("repository cannot be nil")
+	}
+	lister, ok := repo.(Lister)
+	if !ok {
+		return nil, fmt.Errorf("repository %T does not implement bloom.Lister", repo)
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, fmt.Errorf("false positive rate must be in (0, 1)")
+	}
+
+	return &Repository{
+		repo:     repo,
+		lister:   lister,
+		expected: expectedEntries,
+		fpRate:   falsePositiveRate,
+		current:  newFilter(expectedEntries, falsePositiveRate),
+	}, nil
+}
+
+func (r *Repository) MarkTokenRevoke(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) error {
+	if err := r.repo.MarkTokenRevoke(ctx, tokenType, token, ttl); err != nil {
+		return err
+	}
+	r.add(filterKey(tokenType, token))
+	return nil
+}
+
+func (r *Repository) IsTokenRevoked(ctx context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	r.mu.RLock()
+	hit := r.current.test(filterKey(tokenType, token))
+	r.mu.RUnlock()
+	if !hit {
+		return false, nil
+	}
+	return r.repo.IsTokenRevoked(ctx, tokenType, token)
+}
+
+// MarkTokenRotated delegates to the underlying repository, which must
+// implement jwt.AtomicRotationRepository.
+func (r *Repository) MarkTokenRotated(ctx context.Context, token string, ttl time.Duration) (bool, error) {
+	atomic, ok := r.repo.(jwt.AtomicRotationRepository)
+	if !ok {
+		return false, fmt.Errorf("mark token rotated: %T does not support atomic rotation", r.repo)
+	}
+	rotated, err := atomic.MarkTokenRotated(ctx, token, ttl)
+	if err != nil {
+		return false, err
+	}
+	r.add(filterKey(jwt.RefreshToken, token))
+	return rotated, nil
+}
+
+// MarkTokenConsumed delegates to the underlying repository, which must
+// implement jwt.AtomicConsumeRepository.
+func (r *Repository) MarkTokenConsumed(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	atomic, ok := r.repo.(jwt.AtomicConsumeRepository)
+	if !ok {
+		return false, fmt.Errorf("mark token consumed: %T does not support atomic consumption", r.repo)
+	}
+	consumed, err := atomic.MarkTokenConsumed(ctx, tokenType, token, ttl)
+	if err != nil {
+		return false, err
+	}
+	r.add(filterKey(tokenType, token))
+	return consumed, nil
+}
+
+// MarkSessionRevoked forwards to the wrapped repository; session checks
+// bypass the filter.
+func (r *Repository) MarkSessionRevoked(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error {
+	sessions, ok := r.repo.(jwt.SessionRevocationRepository)
+	if !ok {
+		return fmt.Errorf("mark session revoked: %w", errors.ErrUnsupported)
+	}
+	return sessions.MarkSessionRevoked(ctx, sessionID, ttl)
+}
+
+// IsSessionRevoked forwards to the wrapped repository.
+func (r *Repository) IsSessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	sessions, ok := r.repo.(jwt.SessionRevocationRepository)
+	if !ok {
+		return false, fmt.Errorf("check session revocation: %w", errors.ErrUnsupported)
+	}
+	return sessions.IsSessionRevoked(ctx, sessionID)
+}
+
+// MarkFamilyRevoked forwards to the wrapped repository; family checks
+// bypass the filter.
+func (r *Repository) MarkFamilyRevoked(ctx context.Context, familyID uuid.UUID, ttl time.Duration) error {
+	families, ok := r.repo.(jwt.FamilyRevocationRepository)
+	if !ok {
+		return fmt.Errorf("mark family revoked: %w", errors.ErrUnsupported)
+	}
+	return families.MarkFamilyRevoked(ctx, familyID, ttl)
+}
+
+// IsFamilyRevoked forwards to the wrapped repository.
+func (r *Repository) IsFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error) {
+	families, ok := r.repo.(jwt.FamilyRevocationRepository)
+	if !ok {
+		return false, fmt.Errorf("check family revocation: %w", errors.ErrUnsupported)
+	}
+	return families.IsFamilyRevoked(ctx, familyID)
+}
+
+// MarkUserRevoked forwards to the wrapped repository.
+func (r *Repository) MarkUserRevoked(ctx context.Context, userID uuid.UUID, before time.Time, ttl time.Duration) error {
+	users, ok := r.repo.(jwt.UserRevocationRepository)
+	if !ok {
+		return fmt.Errorf("mark user revoked: %w", errors.ErrUnsupported)
+	}
+	return users.MarkUserRevoked(ctx, userID, before, ttl)
+}
+
+// UserRevokedBefore forwards to the wrapped repository.
+func (r *Repository) UserRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	users, ok := r.repo.(jwt.UserRevocationRepository)
+	if !ok {
+		return time.Time{}, fmt.Errorf("check user revocation: %w", errors.ErrUnsupported)
+	}
+	return users.UserRevokedBefore(ctx, userID)
+}
+
+// Rebuild replaces the filter with one built from the underlying store,
+// dropping expired revocations and resizing if the store outgrew the filter.
+// Revocations written while it runs are kept.
+func (r *Repository) Rebuild(ctx context.Context) error {
+	r.mu.Lock()
+	size := max(r.expected, 2*r.count)
+	next := newFilter(size, r.fpRate)
+	r.pending = next
+	r.mu.Unlock()
+
+	var count uint64
+	for _, tokenType := range []jwt.TokenType{jwt.AccessToken, jwt.RefreshToken, jwt.ActionToken} {
+		err := r.lister.ListRevoked(ctx, tokenType, func(token string) error {
+			r.add(filterKey(tokenType, token))
+			count++
+			return nil
+		})
+		if err != nil {
+			r.mu.Lock()
+			r.pending = nil
+			r.mu.Unlock()
+			return fmt.Errorf("rebuild revocation filter: %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	r.current, r.pending, r.count = next, nil, count
+	r.mu.Unlock()
+	return nil
+}
+
+// Run calls Rebuild every interval until ctx is done. Failed rebuilds are
+// logged and the previous filter stays in place.
+func (r *Repository) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Rebuild(ctx); err != nil {
+				logx.WithContext(ctx).Errorf("bloom: %v", err)
+			}
+		}
+	}
+}
+
+// CleanupExpired implements jwt.Cleaner by cleaning up the wrapped repository.
+func (r *Repository) CleanupExpired(ctx context.Context) (int64, error) {
+	return jwt.CleanupExpired(ctx, r.repo)
+}
+
+// Ping implements jwt.Pinger by pinging the wrapped repository.
+func (r *Repository) Ping(ctx context.Context) error {
+	return jwt.Ping(ctx, r.repo)
+}
+
+// CountRevoked implements jwt.RevocationCounter by counting the wrapped
+// repository.
+func (r *Repository) CountRevoked(ctx context.Context) (map[jwt.TokenType]int64, error) {
+	return jwt.CountRevoked(ctx, r.repo)
+}
+
+func (r *Repository) add(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current.add(key)
+	if r.pending != nil {
+		r.pending.add(key)
+	}
+}
+
+func filterKey(tokenType jwt.TokenType, token string) string {
+	return string(tokenType) + ":" + token
+}
@@ -0,0 +1,16 @@
+package bloom
+
+import (
+	"testing"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/internal/benchmark"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/memory"
+)
+
+func BenchmarkRepository(b *testing.B) {
+	r, err := NewRepository(memory.NewRepository(0), 4*benchmark.Entries, 0.01)
+	if err != nil {
+		b.Fatalf("create repository: %v", err)
+	}
+	benchmark.Repository(b, r)
+}
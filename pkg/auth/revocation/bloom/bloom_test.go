@@ -0,0 +1,65 @@
+package bloom
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/memory"
+)
+
+// countingRepo counts IsTokenRevoked lookups on the wrapped repository.
+type countingRepo struct {
+	*memory.Repository
+	lookups int
+}
+
+func (r *countingRepo) IsTokenRevoked(ctx context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	r.lookups++
+	return r.Repository.IsTokenRevoked(ctx, tokenType, token)
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	store := &countingRepo{Repository: memory.NewRepository(0)}
+	// Revoked before the filter existed, e.g. by another instance.
+	if err := store.MarkTokenRevoke(ctx, jwt.AccessToken, "old", time.Hour); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+
+	r, err := NewRepository(store, 1000, 0.001)
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	if err := r.Rebuild(ctx); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	if err := r.MarkTokenRevoke(ctx, jwt.AccessToken, "new", time.Hour); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+
+	for _, token := range []string{"old", "new"} {
+		if revoked, _ := r.IsTokenRevoked(ctx, jwt.AccessToken, token); !revoked {
+			t.Errorf("expected %q to be revoked", token)
+		}
+	}
+
+	store.lookups = 0
+	for i := range 1000 {
+		if revoked, _ := r.IsTokenRevoked(ctx, jwt.AccessToken, fmt.Sprintf("valid-%d", i)); revoked {
+			t.Fatal("expected unrevoked token to pass")
+		}
+	}
+	if store.lookups > 10 {
+		t.Errorf("expected the filter to answer most misses, got %d repository lookups", store.lookups)
+	}
+}
+
+func TestNewRepository_RequiresLister(t *testing.T) {
+	var repo struct{ jwt.RevocationRepository }
+	if _, err := NewRepository(repo, 1000, 0.01); err == nil {
+		t.Error("expected a repository without ListRevoked to be rejected")
+	}
+}
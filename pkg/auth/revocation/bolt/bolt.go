@@ -0,0 +1,238 @@
+// Package bolt implements jwt.RevocationRepository on an embedded bbolt
+// database, giving durable revocation and rotation state without any external
+// service for edge and on-prem single-node deployments.
+//
+// Each token type has its own bucket mapping the token to its expiry (Unix
+// milliseconds, big-endian). Reads ignore expired entries; call CleanupExpired
+// periodically to delete them. bbolt never shrinks its file, so after large
+// cleanups use CompactTo to write a compacted copy and swap it in on restart.
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"go.etcd.io/bbolt"
+)
+
+// compactTxMaxSize bounds the size of each transaction used by CompactTo.
+const compactTxMaxSize = 64 << 20
+
+var buckets = map[jwt.TokenType][]byte{
+	jwt.AccessToken:  []byte("revoked_access"),
+	jwt.RefreshToken: []byte("revoked_refresh"),
+	jwt.ActionToken:  []byte("consumed_action"),
+}
+
+// Repository is a bbolt-backed jwt.RevocationRepository.
+type Repository struct {
+	db    *bbolt.DB
+	clock jwt.Clock
+}
+
+// Option configures a Repository.
+type Option func(*Repository)
+
+// WithClock computes and checks expiries against c instead of the system
+// clock.
+func WithClock(c jwt.Clock) Option {
+	return func(r *Repository) { r.clock = c }
+}
+
+// NewRepository creates the buckets if needed and returns the repository. The
+// caller owns db and must close it.
+func NewRepository(db *bbolt.DB, opts ...Option) (*Repository, error) {
+	if db == nil {
+		return nil, fmt.Errorf("bolt db cannot be nil")
+	}
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bolt create buckets: %w", err)
+	}
+
+	r := &Repository{db: db, clock: jwt.SystemClock{}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+// MarkTokenRevoke stores the revocation. bbolt has no context support; the
+// write is bounded by the database's own locking.
+func (r *Repository) MarkTokenRevoke(_ context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) error {
+	name, err := bucketFor(tokenType)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := r.clock.Now().Add(ttl).UnixMilli()
+	err = r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(name)
+		// Never shorten an existing revocation.
+		if existing, ok := decodeExpiry(b.Get([]byte(token))); ok && existing > expiresAt {
+			return nil
+		}
+		return b.Put([]byte(token), encodeExpiry(expiresAt))
+	})
+	if err != nil {
+		return fmt.Errorf("mark token revoked: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) IsTokenRevoked(_ context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	name, err := bucketFor(tokenType)
+	if err != nil {
+		return false, err
+	}
+
+	var revoked bool
+	err = r.db.View(func(tx *bbolt.Tx) error {
+		expiresAt, ok := decodeExpiry(tx.Bucket(name).Get([]byte(token)))
+		revoked = ok && expiresAt > r.clock.Now().UnixMilli()
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("check revocation: %w", err)
+	}
+	return revoked, nil
+}
+
+// MarkTokenRotated atomically revokes a refresh token if it is not revoked yet.
+// bbolt serializes write transactions, so the check and the write cannot race.
+func (r *Repository) MarkTokenRotated(_ context.Context, token string, ttl time.Duration) (bool, error) {
+	rotated, err := r.markOnce(buckets[jwt.RefreshToken], token, ttl)
+	if err != nil {
+		return false, fmt.Errorf("mark token rotated: %w", err)
+	}
+	return rotated, nil
+}
+
+// MarkTokenConsumed implements jwt.AtomicConsumeRepository.
+func (r *Repository) MarkTokenConsumed(_ context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	name, err := bucketFor(tokenType)
+	if err != nil {
+		return false, err
+	}
+	consumed, err := r.markOnce(name, token, ttl)
+	if err != nil {
+		return false, fmt.Errorf("mark token consumed: %w", err)
+	}
+	return consumed, nil
+}
+
+// markOnce stores token in bucket name unless an unexpired entry exists and
+// reports whether it did.
+func (r *Repository) markOnce(name []byte, token string, ttl time.Duration) (bool, error) {
+	now := r.clock.Now()
+	var marked bool
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(name)
+		if expiresAt, ok := decodeExpiry(b.Get([]byte(token))); ok && expiresAt > now.UnixMilli() {
+			return nil
+		}
+		marked = true
+		return b.Put([]byte(token), encodeExpiry(now.Add(ttl).UnixMilli()))
+	})
+	return marked, err
+}
+
+// CleanupExpired deletes expired revocations and returns how many were removed.
+func (r *Repository) CleanupExpired(_ context.Context) (int64, error) {
+	now := r.clock.Now().UnixMilli()
+	var removed int64
+	err := r.db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range buckets {
+			b := tx.Bucket(name)
+			// Collect first: deleting while iterating a bbolt cursor skips keys.
+			var expired [][]byte
+			err := b.ForEach(func(k, v []byte) error {
+				if expiresAt, ok := decodeExpiry(v); !ok || expiresAt <= now {
+					expired = append(expired, append([]byte(nil), k...))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, k := range expired {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+			removed += int64(len(expired))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cleanup expired revocations: %w", err)
+	}
+	return removed, nil
+}
+
+// ScanRevoked calls fn for every unexpired revocation with its remaining
+// lifetime. It implements migrate.Scanner. fn runs inside a read transaction
+// and must not write to this repository.
+func (r *Repository) ScanRevoked(_ context.Context, fn func(tokenType jwt.TokenType, token string, ttl time.Duration) error) error {
+	now := r.clock.Now()
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		for tokenType, name := range buckets {
+			err := tx.Bucket(name).ForEach(func(k, v []byte) error {
+				expiresAt, ok := decodeExpiry(v)
+				if !ok || expiresAt <= now.UnixMilli() {
+					return nil
+				}
+				return fn(tokenType, string(k), time.UnixMilli(expiresAt).Sub(now))
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("scan revocations: %w", err)
+	}
+	return nil
+}
+
+// CompactTo copies every bucket into dst, producing a file without the free
+// pages left behind by deletions. dst must be a freshly opened, empty database.
+func (r *Repository) CompactTo(dst *bbolt.DB) error {
+	if err := bbolt.Compact(dst, r.db, compactTxMaxSize); err != nil {
+		return fmt.Errorf("bolt compact: %w", err)
+	}
+	return nil
+}
+
+func bucketFor(tokenType jwt.TokenType) ([]byte, error) {
+	name, ok := buckets[tokenType]
+	if !ok {
+		return nil, fmt.Errorf("invalid token type: %v", tokenType)
+	}
+	return name, nil
+}
+
+func encodeExpiry(unixMilli int64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(unixMilli))
+	return buf
+}
+
+func decodeExpiry(v []byte) (int64, bool) {
+	if len(v) != 8 {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(v)), true
+}
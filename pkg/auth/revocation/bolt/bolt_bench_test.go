@@ -0,0 +1,23 @@
+package bolt
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/internal/benchmark"
+)
+
+func BenchmarkRepository(b *testing.B) {
+	db, err := bbolt.Open(filepath.Join(b.TempDir(), "revocations.db"), 0o600, nil)
+	if err != nil {
+		b.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	r, err := NewRepository(db)
+	if err != nil {
+		b.Fatalf("create repository: %v", err)
+	}
+	benchmark.Repository(b, r)
+}
@@ -0,0 +1,270 @@
+// Package breaker wraps a jwt.RevocationRepository with a circuit breaker and
+// an explicit policy for what verification does while the store is down.
+//
+// With FailClosed, lookups return the error (ErrServiceUnavailable while the
+// breaker is open) and the TokenMaker rejects the token. With FailOpen,
+// IsTokenRevoked reports "not revoked" instead, logs the failure and calls the
+// OnFailOpen hook, so an outage degrades to accepting tokens that may have
+// been revoked rather than to rejecting every request.
+//
+// Writes always fail closed: reporting a revocation or rotation that did not
+// happen would silently leave a token usable.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/zeromicro/go-zero/core/breaker"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// ErrServiceUnavailable is returned while the breaker is open.
+var ErrServiceUnavailable = breaker.ErrServiceUnavailable
+
+// Policy controls IsTokenRevoked while the repository is failing.
+type Policy string
+
+const (
+	// FailClosed returns the error, rejecting the token. Default.
+	FailClosed Policy = "fail_closed"
+	// FailOpen treats the token as not revoked.
+	FailOpen Policy = "fail_open"
+)
+
+// Option configures a Repository.
+type Option func(*Repository)
+
+// WithOnFailOpen sets a hook called whenever a lookup fails open, for example
+// to emit a security event or metric.
+func WithOnFailOpen(fn func(ctx context.Context, tokenType jwt.TokenType, err error)) Option {
+	return func(r *Repository) { r.onFailOpen = fn }
+}
+
+// Repository is a circuit-breaking jwt.RevocationRepository.
+type Repository struct {
+	repo       jwt.RevocationRepository
+	brk        breaker.Breaker
+	policy     Policy
+	onFailOpen func(ctx context.Context, tokenType jwt.TokenType, err error)
+}
+
+// NewRepository wraps repo with a breaker named name (used in go-zero's
+// breaker logs) and the given policy; an empty policy means FailClosed.
+func NewRepository(repo jwt.RevocationRepository, name string, policy Policy, opts ...Option) (*Repository, error) {
+	if repo == nil {
+		return nil, fmt.Errorf("repository cannot be nil")
+	}
+	switch policy {
+	case "":
+		policy = FailClosed
+	case FailClosed, FailOpen:
+	default:
+		return nil, fmt.Errorf("invalid breaker policy: %q", policy)
+	}
+
+	r := &Repository{
+		repo:   repo,
+		brk:    breaker.NewBreaker(breaker.WithName("revocation:" + name)),
+		policy: policy,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+func (r *Repository) MarkTokenRevoke(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) error {
+	return r.brk.DoWithAcceptableCtx(ctx, func() error {
+		return r.repo.MarkTokenRevoke(ctx, tokenType, token, ttl)
+	}, acceptable)
+}
+
+func (r *Repository) IsTokenRevoked(ctx context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	var revoked bool
+	err := r.brk.DoWithAcceptableCtx(ctx, func() error {
+		var err error
+		revoked, err = r.repo.IsTokenRevoked(ctx, tokenType, token)
+		return err
+	}, acceptable)
+	if err == nil {
+		return revoked, nil
+	}
+
+	return false, r.failOpen(ctx, tokenType, err)
+}
+
+// failOpen applies the policy to a failed lookup, returning the error to
+// report: err itself when failing closed, nil when failing open.
+func (r *Repository) failOpen(ctx context.Context, tokenType jwt.TokenType, err error) error {
+	if r.policy != FailOpen || ctx.Err() != nil {
+		return err
+	}
+	logx.WithContext(ctx).Errorf("revocation check failed open: %v", err)
+	if r.onFailOpen != nil {
+		r.onFailOpen(ctx, tokenType, err)
+	}
+	return nil
+}
+
+// MarkTokenRotated delegates to the wrapped repository, atomically when it
+// implements jwt.AtomicRotationRepository. Otherwise it falls back to a lookup
+// followed by a revoke, as the TokenMaker itself would. It always fails
+// closed.
+func (r *Repository) MarkTokenRotated(ctx context.Context, token string, ttl time.Duration) (bool, error) {
+	var rotated bool
+	err := r.brk.DoWithAcceptableCtx(ctx, func() error {
+		if atomic, ok := r.repo.(jwt.AtomicRotationRepository); ok {
+			var err error
+			rotated, err = atomic.MarkTokenRotated(ctx, token, ttl)
+			return err
+		}
+
+		revoked, err := r.repo.IsTokenRevoked(ctx, jwt.RefreshToken, token)
+		if err != nil || revoked {
+			return err
+		}
+		if err := r.repo.MarkTokenRevoke(ctx, jwt.RefreshToken, token, ttl); err != nil {
+			return err
+		}
+		rotated = true
+		return nil
+	}, acceptable)
+	if err != nil {
+		return false, err
+	}
+	return rotated, nil
+}
+
+// MarkTokenConsumed delegates to the wrapped repository through the breaker;
+// see jwt.MarkTokenConsumed. It always fails closed.
+func (r *Repository) MarkTokenConsumed(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	var consumed bool
+	err := r.brk.DoWithAcceptableCtx(ctx, func() error {
+		var err error
+		consumed, err = jwt.MarkTokenConsumed(ctx, r.repo, tokenType, token, ttl)
+		return err
+	}, acceptable)
+	if err != nil {
+		return false, err
+	}
+	return consumed, nil
+}
+
+// MarkSessionRevoked forwards to the wrapped repository through the breaker.
+// It always fails closed.
+func (r *Repository) MarkSessionRevoked(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error {
+	sessions, ok := r.repo.(jwt.SessionRevocationRepository)
+	if !ok {
+		return fmt.Errorf("mark session revoked: %w", errors.ErrUnsupported)
+	}
+	return r.brk.DoWithAcceptableCtx(ctx, func() error {
+		return sessions.MarkSessionRevoked(ctx, sessionID, ttl)
+	}, acceptable)
+}
+
+// IsSessionRevoked forwards to the wrapped repository through the breaker,
+// applying the same policy as IsTokenRevoked.
+func (r *Repository) IsSessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	sessions, ok := r.repo.(jwt.SessionRevocationRepository)
+	if !ok {
+		return false, fmt.Errorf("check session revocation: %w", errors.ErrUnsupported)
+	}
+	var revoked bool
+	err := r.brk.DoWithAcceptableCtx(ctx, func() error {
+		var err error
+		revoked, err = sessions.IsSessionRevoked(ctx, sessionID)
+		return err
+	}, acceptable)
+	if err == nil {
+		return revoked, nil
+	}
+	return false, r.failOpen(ctx, "", err)
+}
+
+// MarkFamilyRevoked forwards to the wrapped repository through the breaker.
+// It always fails closed.
+func (r *Repository) MarkFamilyRevoked(ctx context.Context, familyID uuid.UUID, ttl time.Duration) error {
+	families, ok := r.repo.(jwt.FamilyRevocationRepository)
+	if !ok {
+		return fmt.Errorf("mark family revoked: %w", errors.ErrUnsupported)
+	}
+	return r.brk.DoWithAcceptableCtx(ctx, func() error {
+		return families.MarkFamilyRevoked(ctx, familyID, ttl)
+	}, acceptable)
+}
+
+// IsFamilyRevoked forwards to the wrapped repository through the breaker,
+// applying the same policy as IsTokenRevoked.
+func (r *Repository) IsFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error) {
+	families, ok := r.repo.(jwt.FamilyRevocationRepository)
+	if !ok {
+		return false, fmt.Errorf("check family revocation: %w", errors.ErrUnsupported)
+	}
+	var revoked bool
+	err := r.brk.DoWithAcceptableCtx(ctx, func() error {
+		var err error
+		revoked, err = families.IsFamilyRevoked(ctx, familyID)
+		return err
+	}, acceptable)
+	if err == nil {
+		return revoked, nil
+	}
+	return false, r.failOpen(ctx, "", err)
+}
+
+// MarkUserRevoked forwards to the wrapped repository through the breaker. It
+// always fails closed.
+func (r *Repository) MarkUserRevoked(ctx context.Context, userID uuid.UUID, before time.Time, ttl time.Duration) error {
+	users, ok := r.repo.(jwt.UserRevocationRepository)
+	if !ok {
+		return fmt.Errorf("mark user revoked: %w", errors.ErrUnsupported)
+	}
+	return r.brk.DoWithAcceptableCtx(ctx, func() error {
+		return users.MarkUserRevoked(ctx, userID, before, ttl)
+	}, acceptable)
+}
+
+// UserRevokedBefore forwards to the wrapped repository through the breaker,
+// applying the same policy as IsTokenRevoked. Failing open reports no cutoff.
+func (r *Repository) UserRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	users, ok := r.repo.(jwt.UserRevocationRepository)
+	if !ok {
+		return time.Time{}, fmt.Errorf("check user revocation: %w", errors.ErrUnsupported)
+	}
+	var before time.Time
+	err := r.brk.DoWithAcceptableCtx(ctx, func() error {
+		var err error
+		before, err = users.UserRevokedBefore(ctx, userID)
+		return err
+	}, acceptable)
+	if err == nil {
+		return before, nil
+	}
+	return time.Time{}, r.failOpen(ctx, "", err)
+}
+
+// CleanupExpired implements jwt.Cleaner by cleaning up the wrapped repository.
+func (r *Repository) CleanupExpired(ctx context.Context) (int64, error) {
+	return jwt.CleanupExpired(ctx, r.repo)
+}
+
+// Ping implements jwt.Pinger by pinging the wrapped repository.
+func (r *Repository) Ping(ctx context.Context) error {
+	return jwt.Ping(ctx, r.repo)
+}
+
+// CountRevoked implements jwt.RevocationCounter by counting the wrapped
+// repository.
+func (r *Repository) CountRevoked(ctx context.Context) (map[jwt.TokenType]int64, error) {
+	return jwt.CountRevoked(ctx, r.repo)
+}
+
+// acceptable keeps cancellations by the caller from tripping the breaker.
+func acceptable(err error) bool {
+	return err == nil || errors.Is(err, context.Canceled)
+}
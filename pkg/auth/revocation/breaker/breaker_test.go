@@ -0,0 +1,59 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+var errDown = errors.New("connection refused")
+
+// downRepo fails every call.
+type downRepo struct{}
+
+func (downRepo) MarkTokenRevoke(context.Context, jwt.TokenType, string, time.Duration) error {
+	return errDown
+}
+
+func (downRepo) IsTokenRevoked(context.Context, jwt.TokenType, string) (bool, error) {
+	return false, errDown
+}
+
+func TestRepository_Policy(t *testing.T) {
+	ctx := context.Background()
+
+	closed, err := NewRepository(downRepo{}, "closed_test", "")
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	if _, err := closed.IsTokenRevoked(ctx, jwt.AccessToken, "t"); err == nil {
+		t.Error("expected fail-closed lookup to return the error")
+	}
+
+	var events int
+	open, err := NewRepository(downRepo{}, "open_test", FailOpen, WithOnFailOpen(func(context.Context, jwt.TokenType, error) {
+		events++
+	}))
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	if revoked, err := open.IsTokenRevoked(ctx, jwt.AccessToken, "t"); err != nil || revoked {
+		t.Errorf("expected fail-open lookup to report not revoked, got %v, %v", revoked, err)
+	}
+	if events != 1 {
+		t.Errorf("expected 1 fail-open event, got %d", events)
+	}
+	if err := open.MarkTokenRevoke(ctx, jwt.AccessToken, "t", time.Minute); err == nil {
+		t.Error("expected writes to fail closed")
+	}
+	if rotated, err := open.MarkTokenRotated(ctx, "t", time.Minute); err == nil || rotated {
+		t.Errorf("expected rotation to fail closed, got %v, %v", rotated, err)
+	}
+
+	if _, err := NewRepository(downRepo{}, "bad_test", "fail_sometimes"); err == nil {
+		t.Error("expected invalid policy to be rejected")
+	}
+}
@@ -0,0 +1,266 @@
+// Package cached layers a local revocation cache in front of a remote
+// jwt.RevocationRepository, answering most IsTokenRevoked calls in process
+// to cut verification latency for Redis-backed deployments.
+//
+// Positive answers are kept in the local repository (typically
+// pkg/auth/revocation/memory) and negative answers in a bounded in-process
+// cache, both for the configured TTL. Writes go to the remote store first and
+// then to the local one. A revocation made through another instance therefore
+// takes up to the TTL to be seen here; keep it short (a few seconds), or
+// configure a Notifier so that every write is broadcast and other instances
+// Evict their stale answers within milliseconds (see
+// pkg/auth/revocation/pubsub).
+package cached
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/zeromicro/go-zero/core/collection"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// maxNegativeEntries bounds the "not revoked" cache.
+const maxNegativeEntries = 100_000
+
+// Notifier broadcasts revocations written through this instance so that other
+// instances can Evict their cached answers.
+type Notifier interface {
+	NotifyRevoked(ctx context.Context, tokenType jwt.TokenType, token string) error
+}
+
+// Option configures a Repository.
+type Option func(*Repository)
+
+// WithNotifier broadcasts every revocation and rotation through n after it
+// was written to the remote store.
+func WithNotifier(n Notifier) Option {
+	return func(r *Repository) { r.notifier = n }
+}
+
+// Repository is a two-tier jwt.RevocationRepository.
+type Repository struct {
+	local    jwt.RevocationRepository
+	remote   jwt.RevocationRepository
+	negative *collection.Cache
+	ttl      time.Duration
+	notifier Notifier
+}
+
+// NewRepository returns a repository caching remote's answers in local for ttl.
+func NewRepository(local, remote jwt.RevocationRepository, ttl time.Duration, opts ...Option) (*Repository, error) {
+	if local == nil || remote == nil {
+		return nil, fmt.Errorf("local and remote repositories are required")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("cache ttl must be positive")
+	}
+
+	negative, err := collection.NewCache(ttl, collection.WithLimit(maxNegativeEntries), collection.WithName("revocation-negative"))
+	if err != nil {
+		return nil, fmt.Errorf("create negative cache: %w", err)
+	}
+
+	r := &Repository{
+		local:    local,
+		remote:   remote,
+		negative: negative,
+		ttl:      ttl,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+func (r *Repository) MarkTokenRevoke(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) error {
+	if err := r.remote.MarkTokenRevoke(ctx, tokenType, token, ttl); err != nil {
+		return err
+	}
+	r.negative.Del(cacheKey(tokenType, token))
+	r.cachePositive(ctx, tokenType, token, ttl)
+	r.notify(ctx, tokenType, token)
+	return nil
+}
+
+func (r *Repository) IsTokenRevoked(ctx context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	if revoked, err := r.local.IsTokenRevoked(ctx, tokenType, token); err == nil && revoked {
+		return true, nil
+	}
+	key := cacheKey(tokenType, token)
+	if _, ok := r.negative.Get(key); ok {
+		return false, nil
+	}
+
+	revoked, err := r.remote.IsTokenRevoked(ctx, tokenType, token)
+	if err != nil {
+		return false, err
+	}
+	if revoked {
+		r.cachePositive(ctx, tokenType, token, r.ttl)
+	} else {
+		r.negative.Set(key, struct{}{})
+	}
+	return revoked, nil
+}
+
+// MarkTokenRotated delegates to the remote repository, atomically when it
+// implements jwt.AtomicRotationRepository. Otherwise it falls back to a remote
+// lookup followed by a revoke, which is not race-free.
+func (r *Repository) MarkTokenRotated(ctx context.Context, token string, ttl time.Duration) (bool, error) {
+	var rotated bool
+	if atomic, ok := r.remote.(jwt.AtomicRotationRepository); ok {
+		var err error
+		if rotated, err = atomic.MarkTokenRotated(ctx, token, ttl); err != nil {
+			return false, err
+		}
+	} else {
+		revoked, err := r.remote.IsTokenRevoked(ctx, jwt.RefreshToken, token)
+		if err != nil {
+			return false, err
+		}
+		if !revoked {
+			if err := r.remote.MarkTokenRevoke(ctx, jwt.RefreshToken, token, ttl); err != nil {
+				return false, err
+			}
+		}
+		rotated = !revoked
+	}
+
+	r.negative.Del(cacheKey(jwt.RefreshToken, token))
+	r.cachePositive(ctx, jwt.RefreshToken, token, ttl)
+	if rotated {
+		r.notify(ctx, jwt.RefreshToken, token)
+	}
+	return rotated, nil
+}
+
+// MarkTokenConsumed delegates to the remote repository; see
+// jwt.MarkTokenConsumed.
+func (r *Repository) MarkTokenConsumed(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	consumed, err := jwt.MarkTokenConsumed(ctx, r.remote, tokenType, token, ttl)
+	if err != nil {
+		return false, err
+	}
+
+	r.negative.Del(cacheKey(tokenType, token))
+	r.cachePositive(ctx, tokenType, token, ttl)
+	if consumed {
+		r.notify(ctx, tokenType, token)
+	}
+	return consumed, nil
+}
+
+// Evict drops the cached "not revoked" answer for token, so the next check
+// goes to the remote store. Call it when another instance reports a write.
+func (r *Repository) Evict(tokenType jwt.TokenType, token string) {
+	r.negative.Del(cacheKey(tokenType, token))
+}
+
+// MarkSessionRevoked forwards to the remote repository.
+func (r *Repository) MarkSessionRevoked(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error {
+	sessions, ok := r.remote.(jwt.SessionRevocationRepository)
+	if !ok {
+		return fmt.Errorf("mark session revoked: %w", errors.ErrUnsupported)
+	}
+	return sessions.MarkSessionRevoked(ctx, sessionID, ttl)
+}
+
+// IsSessionRevoked forwards to the remote repository; session answers are not
+// cached, so a revoked session is seen immediately.
+func (r *Repository) IsSessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	sessions, ok := r.remote.(jwt.SessionRevocationRepository)
+	if !ok {
+		return false, fmt.Errorf("check session revocation: %w", errors.ErrUnsupported)
+	}
+	return sessions.IsSessionRevoked(ctx, sessionID)
+}
+
+// MarkFamilyRevoked forwards to the remote repository.
+func (r *Repository) MarkFamilyRevoked(ctx context.Context, familyID uuid.UUID, ttl time.Duration) error {
+	families, ok := r.remote.(jwt.FamilyRevocationRepository)
+	if !ok {
+		return fmt.Errorf("mark family revoked: %w", errors.ErrUnsupported)
+	}
+	return families.MarkFamilyRevoked(ctx, familyID, ttl)
+}
+
+// IsFamilyRevoked forwards to the remote repository; family answers are not
+// cached, so a revoked family is seen immediately.
+func (r *Repository) IsFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error) {
+	families, ok := r.remote.(jwt.FamilyRevocationRepository)
+	if !ok {
+		return false, fmt.Errorf("check family revocation: %w", errors.ErrUnsupported)
+	}
+	return families.IsFamilyRevoked(ctx, familyID)
+}
+
+// MarkUserRevoked forwards to the remote repository.
+func (r *Repository) MarkUserRevoked(ctx context.Context, userID uuid.UUID, before time.Time, ttl time.Duration) error {
+	users, ok := r.remote.(jwt.UserRevocationRepository)
+	if !ok {
+		return fmt.Errorf("mark user revoked: %w", errors.ErrUnsupported)
+	}
+	return users.MarkUserRevoked(ctx, userID, before, ttl)
+}
+
+// UserRevokedBefore forwards to the remote repository; cutoffs are not
+// cached.
+func (r *Repository) UserRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	users, ok := r.remote.(jwt.UserRevocationRepository)
+	if !ok {
+		return time.Time{}, fmt.Errorf("check user revocation: %w", errors.ErrUnsupported)
+	}
+	return users.UserRevokedBefore(ctx, userID)
+}
+
+// cachePositive records a known revocation locally. Failures only cost a
+// later remote lookup, so they are logged rather than returned.
+func (r *Repository) cachePositive(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) {
+	if err := r.local.MarkTokenRevoke(ctx, tokenType, token, ttl); err != nil {
+		logx.WithContext(ctx).Errorf("cache revocation locally: %v", err)
+	}
+}
+
+// notify broadcasts a write. The revocation is already durable, and without
+// the broadcast other instances only lag by the TTL, so failures are logged.
+func (r *Repository) notify(ctx context.Context, tokenType jwt.TokenType, token string) {
+	if r.notifier == nil {
+		return
+	}
+	if err := r.notifier.NotifyRevoked(ctx, tokenType, token); err != nil {
+		logx.WithContext(ctx).Errorf("broadcast revocation: %v", err)
+	}
+}
+
+// CleanupExpired implements jwt.Cleaner by cleaning up both tiers.
+func (r *Repository) CleanupExpired(ctx context.Context) (int64, error) {
+	local, err := jwt.CleanupExpired(ctx, r.local)
+	if err != nil {
+		return local, err
+	}
+	remote, err := jwt.CleanupExpired(ctx, r.remote)
+	return local + remote, err
+}
+
+// Ping implements jwt.Pinger by pinging both tiers.
+func (r *Repository) Ping(ctx context.Context) error {
+	if err := jwt.Ping(ctx, r.local); err != nil {
+		return err
+	}
+	return jwt.Ping(ctx, r.remote)
+}
+
+// CountRevoked implements jwt.RevocationCounter by counting the remote tier,
+// which holds every revocation; the local tier only caches some of them.
+func (r *Repository) CountRevoked(ctx context.Context) (map[jwt.TokenType]int64, error) {
+	return jwt.CountRevoked(ctx, r.remote)
+}
+
+func cacheKey(tokenType jwt.TokenType, token string) string {
+	return string(tokenType) + ":" + token
+}
@@ -0,0 +1,17 @@
+package cached
+
+import (
+	"testing"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/internal/benchmark"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/memory"
+)
+
+func BenchmarkRepository(b *testing.B) {
+	r, err := NewRepository(memory.NewRepository(0), memory.NewRepository(0), time.Minute)
+	if err != nil {
+		b.Fatalf("create repository: %v", err)
+	}
+	benchmark.Repository(b, r)
+}
@@ -0,0 +1,107 @@
+package cached
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/memory"
+)
+
+// countingRepo counts IsTokenRevoked lookups on the wrapped repository.
+type countingRepo struct {
+	*memory.Repository
+	lookups int
+}
+
+func (r *countingRepo) IsTokenRevoked(ctx context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	r.lookups++
+	return r.Repository.IsTokenRevoked(ctx, tokenType, token)
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	remote := &countingRepo{Repository: memory.NewRepository(0)}
+	r, err := NewRepository(memory.NewRepository(0), remote, time.Minute)
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+
+	for range 3 {
+		if revoked, err := r.IsTokenRevoked(ctx, jwt.AccessToken, "a"); err != nil || revoked {
+			t.Fatalf("expected token not revoked, got %v, %v", revoked, err)
+		}
+	}
+	if remote.lookups != 1 {
+		t.Errorf("expected negative answer to be cached, got %d remote lookups", remote.lookups)
+	}
+
+	if err := r.MarkTokenRevoke(ctx, jwt.AccessToken, "a", time.Hour); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if revoked, _ := remote.Repository.IsTokenRevoked(ctx, jwt.AccessToken, "a"); !revoked {
+		t.Error("expected revocation to be written through to the remote store")
+	}
+	if revoked, _ := r.IsTokenRevoked(ctx, jwt.AccessToken, "a"); !revoked {
+		t.Error("expected write to invalidate the negative cache")
+	}
+	if remote.lookups != 1 {
+		t.Errorf("expected positive answer from the local tier, got %d remote lookups", remote.lookups)
+	}
+
+	if rotated, _ := r.MarkTokenRotated(ctx, "r", time.Hour); !rotated {
+		t.Error("expected first rotation to succeed")
+	}
+	if rotated, _ := r.MarkTokenRotated(ctx, "r", time.Hour); rotated {
+		t.Error("expected second rotation to fail")
+	}
+}
+
+// recordingNotifier records broadcast revocations.
+type recordingNotifier struct {
+	tokens []string
+}
+
+func (n *recordingNotifier) NotifyRevoked(_ context.Context, _ jwt.TokenType, token string) error {
+	n.tokens = append(n.tokens, token)
+	return nil
+}
+
+func TestRepository_NotifyAndEvict(t *testing.T) {
+	ctx := context.Background()
+	remote := memory.NewRepository(0)
+	notifier := &recordingNotifier{}
+	r, err := NewRepository(memory.NewRepository(0), remote, time.Minute, WithNotifier(notifier))
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+
+	if err := r.MarkTokenRevoke(ctx, jwt.AccessToken, "a", time.Hour); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if _, err := r.MarkTokenRotated(ctx, "r", time.Hour); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if _, err := r.MarkTokenRotated(ctx, "r", time.Hour); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if len(notifier.tokens) != 2 {
+		t.Errorf("expected revoke and first rotation to be broadcast, got %v", notifier.tokens)
+	}
+
+	// Revoked through another instance: stale until evicted.
+	if revoked, _ := r.IsTokenRevoked(ctx, jwt.AccessToken, "b"); revoked {
+		t.Fatal("expected token not revoked")
+	}
+	if err := remote.MarkTokenRevoke(ctx, jwt.AccessToken, "b", time.Hour); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if revoked, _ := r.IsTokenRevoked(ctx, jwt.AccessToken, "b"); revoked {
+		t.Fatal("expected cached negative answer before eviction")
+	}
+	r.Evict(jwt.AccessToken, "b")
+	if revoked, _ := r.IsTokenRevoked(ctx, jwt.AccessToken, "b"); !revoked {
+		t.Error("expected revocation to be seen after eviction")
+	}
+}
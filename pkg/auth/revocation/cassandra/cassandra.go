@@ -0,0 +1,164 @@
+// Package cassandra implements jwt.RevocationRepository on Apache Cassandra or
+// ScyllaDB, for deployments with very high write volumes or multi-DC
+// requirements.
+//
+// Revocations are written with a per-row TTL so the cluster expires them
+// without a cleanup job. Refresh token rotation uses a lightweight transaction
+// (INSERT ... IF NOT EXISTS), making RotateRefreshToken race-free across
+// replicas and data centers (see jwt.AtomicRotationRepository).
+package cassandra
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// DefaultTable is the table used when none is configured.
+const DefaultTable = "revoked_tokens"
+
+// identifierRe restricts table names to plain CQL identifiers, optionally
+// keyspace-qualified, since they are interpolated into statements.
+var identifierRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Repository is a Cassandra-backed jwt.RevocationRepository.
+type Repository struct {
+	session *gocql.Session
+	queries queries
+}
+
+// queries holds the CQL statements for one table.
+type queries struct {
+	schema   string
+	mark     string
+	check    string
+	markOnce string
+}
+
+func newQueries(table string) queries {
+	return queries{
+		schema: `CREATE TABLE IF NOT EXISTS ` + table + ` (
+		token_type text,
+		token      text,
+		revoked    boolean,
+		PRIMARY KEY ((token_type, token))
+	)`,
+		mark:     `INSERT INTO ` + table + ` (token_type, token, revoked) VALUES (?, ?, true) USING TTL ?`,
+		check:    `SELECT revoked FROM ` + table + ` WHERE token_type = ? AND token = ?`,
+		markOnce: `INSERT INTO ` + table + ` (token_type, token, revoked) VALUES (?, ?, true) IF NOT EXISTS USING TTL ?`,
+	}
+}
+
+// NewRepository returns a repository using table, which may be
+// keyspace-qualified. An empty table defaults to DefaultTable in the
+// session's keyspace. The caller owns session and must close it.
+func NewRepository(session *gocql.Session, table string) (*Repository, error) {
+	if session == nil {
+		return nil, fmt.Errorf("cassandra session cannot be nil")
+	}
+	if table == "" {
+		table = DefaultTable
+	}
+	if !identifierRe.MatchString(table) {
+		return nil, fmt.Errorf("invalid cassandra table name: %q", table)
+	}
+
+	return &Repository{
+		session: session,
+		queries: newQueries(table),
+	}, nil
+}
+
+// CreateSchema creates the revocation table if it does not exist. Production
+// clusters usually manage schema out of band; this is for development and CI.
+func (r *Repository) CreateSchema(ctx context.Context) error {
+	if err := r.session.Query(r.queries.schema).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("cassandra create schema: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) MarkTokenRevoke(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) error {
+	if err := validateTokenType(tokenType); err != nil {
+		return err
+	}
+
+	err := r.session.Query(r.queries.mark, string(tokenType), token, ttlSeconds(ttl)).WithContext(ctx).Exec()
+	if err != nil {
+		return fmt.Errorf("mark token revoked: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) IsTokenRevoked(ctx context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	if err := validateTokenType(tokenType); err != nil {
+		return false, err
+	}
+
+	var revoked bool
+	err := r.session.Query(r.queries.check, string(tokenType), token).WithContext(ctx).Scan(&revoked)
+	if err == gocql.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("check revocation: %w", err)
+	}
+	return revoked, nil
+}
+
+// MarkTokenRotated atomically revokes a refresh token if it is not revoked yet
+// using a lightweight transaction.
+func (r *Repository) MarkTokenRotated(ctx context.Context, token string, ttl time.Duration) (bool, error) {
+	rotated, err := r.markOnce(ctx, jwt.RefreshToken, token, ttl)
+	if err != nil {
+		return false, fmt.Errorf("mark token rotated: %w", err)
+	}
+	return rotated, nil
+}
+
+// MarkTokenConsumed implements jwt.AtomicConsumeRepository.
+func (r *Repository) MarkTokenConsumed(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	if err := validateTokenType(tokenType); err != nil {
+		return false, err
+	}
+	consumed, err := r.markOnce(ctx, tokenType, token, ttl)
+	if err != nil {
+		return false, fmt.Errorf("mark token consumed: %w", err)
+	}
+	return consumed, nil
+}
+
+// markOnce inserts the row of token unless it exists and reports whether it
+// did.
+func (r *Repository) markOnce(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	existing := make(map[string]interface{})
+	return r.session.Query(r.queries.markOnce, string(tokenType), token, ttlSeconds(ttl)).WithContext(ctx).MapScanCAS(existing)
+}
+
+// ttlSeconds rounds ttl up to whole seconds so a row never expires before the
+// token. Cassandra treats a TTL of zero as "never expire", so it is floored at
+// one second.
+func ttlSeconds(ttl time.Duration) int {
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	return int((ttl + time.Second - 1) / time.Second)
+}
+
+// Ping implements jwt.Pinger.
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.session.Query(`SELECT release_version FROM system.local`).WithContext(ctx).Exec()
+}
+
+func validateTokenType(tokenType jwt.TokenType) error {
+	switch tokenType {
+	case jwt.AccessToken, jwt.RefreshToken, jwt.ActionToken:
+		return nil
+	default:
+		return fmt.Errorf("invalid token type: %v", tokenType)
+	}
+}
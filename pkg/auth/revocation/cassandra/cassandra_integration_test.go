@@ -0,0 +1,81 @@
+//go:build integration
+
+// Integration tests against a real Cassandra or ScyllaDB cluster. They are
+// skipped unless CASSANDRA_HOSTS names one, with a "revocation_test" keyspace:
+//
+//	CASSANDRA_HOSTS=localhost:9042 go test -tags=integration ./pkg/auth/revocation/cassandra/...
+package cassandra
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+func newIntegrationRepository(t *testing.T) (*Repository, *gocql.Session) {
+	t.Helper()
+	hosts := os.Getenv("CASSANDRA_HOSTS")
+	if hosts == "" {
+		t.Skip("CASSANDRA_HOSTS is not set")
+	}
+	cluster := gocql.NewCluster(strings.Split(hosts, ",")...)
+	cluster.Keyspace = "revocation_test"
+	cluster.Timeout = 10 * time.Second
+	session, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(session.Close)
+
+	repo, err := NewRepository(session, "")
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	if err := repo.CreateSchema(context.Background()); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	return repo, session
+}
+
+func TestIntegration_Repository(t *testing.T) {
+	repo, session := newIntegrationRepository(t)
+	ctx := context.Background()
+	token := "it-" + gocql.TimeUUID().String()
+
+	if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, token, time.Minute); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if revoked, err := repo.IsTokenRevoked(ctx, jwt.AccessToken, token); err != nil || !revoked {
+		t.Errorf("expected token to be revoked, got %v, %v", revoked, err)
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.RefreshToken, token); revoked {
+		t.Error("expected revocation to be scoped to the token type")
+	}
+
+	var ttl int
+	if err := session.Query(`SELECT TTL(revoked) FROM `+DefaultTable+` WHERE token_type = ? AND token = ?`,
+		string(jwt.AccessToken), token).WithContext(ctx).Scan(&ttl); err != nil {
+		t.Fatalf("read ttl: %v", err)
+	}
+	if ttl <= 0 || ttl > 60 {
+		t.Errorf("expected a TTL of at most 60s, got %d", ttl)
+	}
+
+	if rotated, err := repo.MarkTokenRotated(ctx, token, time.Minute); err != nil || !rotated {
+		t.Fatalf("first rotation = %v, %v, want true", rotated, err)
+	}
+	if rotated, _ := repo.MarkTokenRotated(ctx, token, time.Minute); rotated {
+		t.Error("expected second rotation to fail")
+	}
+	if consumed, _ := repo.MarkTokenConsumed(ctx, jwt.ActionToken, token, time.Minute); !consumed {
+		t.Error("expected consumption to be independent of rotation")
+	}
+	if err := repo.Ping(ctx); err != nil {
+		t.Errorf("ping: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package cassandra
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+func TestQueries(t *testing.T) {
+	q := newQueries("auth.revocations")
+	for name, tc := range map[string]struct {
+		stmt string
+		want []string
+	}{
+		"schema":   {q.schema, []string{"CREATE TABLE IF NOT EXISTS auth.revocations (", "PRIMARY KEY ((token_type, token))"}},
+		"mark":     {q.mark, []string{"INSERT INTO auth.revocations (token_type, token, revoked) VALUES (?, ?, true) USING TTL ?"}},
+		"check":    {q.check, []string{"SELECT revoked FROM auth.revocations WHERE token_type = ? AND token = ?"}},
+		"markOnce": {q.markOnce, []string{"INSERT INTO auth.revocations", "IF NOT EXISTS USING TTL ?"}},
+	} {
+		for _, want := range tc.want {
+			if !strings.Contains(tc.stmt, want) {
+				t.Errorf("%s: expected %q in %q", name, want, tc.stmt)
+			}
+		}
+	}
+	// The lightweight transaction must come before USING TTL, or Cassandra
+	// rejects the statement.
+	if strings.Index(q.markOnce, "IF NOT EXISTS") > strings.Index(q.markOnce, "USING TTL") {
+		t.Errorf("expected IF NOT EXISTS before USING TTL in %q", q.markOnce)
+	}
+}
+
+func TestTTLSeconds(t *testing.T) {
+	for _, tc := range []struct {
+		ttl  time.Duration
+		want int
+	}{
+		{-time.Minute, 1},
+		{0, 1},
+		{time.Millisecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+		{time.Hour, 3600},
+	} {
+		if got := ttlSeconds(tc.ttl); got != tc.want {
+			t.Errorf("ttlSeconds(%v) = %d, want %d", tc.ttl, got, tc.want)
+		}
+	}
+}
+
+func TestNewRepository(t *testing.T) {
+	if _, err := NewRepository(nil, ""); err == nil {
+		t.Error("expected a nil session to be rejected")
+	}
+	session := &gocql.Session{}
+	repo, err := NewRepository(session, "")
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	if !strings.Contains(repo.queries.check, "FROM "+DefaultTable+" ") {
+		t.Errorf("expected the default table, got %q", repo.queries.check)
+	}
+	for _, table := range []string{"revoked tokens", "a.b.c", "x; DROP TABLE users"} {
+		if _, err := NewRepository(session, table); err == nil {
+			t.Errorf("expected table name %q to be rejected", table)
+		}
+	}
+	if err := repo.MarkTokenRevoke(context.Background(), "bogus", "a", time.Hour); err == nil {
+		t.Error("expected an unknown token type to be rejected")
+	}
+}
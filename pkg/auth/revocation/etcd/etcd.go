@@ -0,0 +1,154 @@
+// Package etcd implements jwt.RevocationRepository on etcd, for
+// Kubernetes-native deployments that already run an etcd cluster.
+//
+// Every revocation is stored under its own lease so etcd expires it without a
+// cleanup job. Refresh token rotation uses a transaction that only succeeds if
+// the key does not exist yet, making RotateRefreshToken race-free across
+// replicas (see jwt.AtomicRotationRepository).
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	// DefaultPrefix is the key prefix used when none is configured.
+	DefaultPrefix = "/growth/revoked/"
+
+	// minLeaseTTL is etcd's effective minimum lease TTL; shorter grants are
+	// silently extended by the server.
+	minLeaseTTL = 5 * time.Second
+)
+
+// Repository is an etcd-backed jwt.RevocationRepository.
+type Repository struct {
+	client *clientv3.Client
+	prefix string
+}
+
+// NewRepository returns a repository storing keys under prefix. An empty
+// prefix defaults to DefaultPrefix. The caller owns client and must close it.
+func NewRepository(client *clientv3.Client, prefix string) (*Repository, error) {
+	if client == nil {
+		return nil, fmt.Errorf("etcd client cannot be nil")
+	}
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+
+	return &Repository{
+		client: client,
+		prefix: prefix,
+	}, nil
+}
+
+func (r *Repository) MarkTokenRevoke(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) error {
+	key, err := r.key(tokenType, token)
+	if err != nil {
+		return err
+	}
+
+	lease, err := r.grant(ctx, ttl)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.client.Put(ctx, key, "1", clientv3.WithLease(lease)); err != nil {
+		return fmt.Errorf("mark token revoked: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) IsTokenRevoked(ctx context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	key, err := r.key(tokenType, token)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := r.client.Get(ctx, key, clientv3.WithCountOnly())
+	if err != nil {
+		return false, fmt.Errorf("check revocation: %w", err)
+	}
+	return resp.Count > 0, nil
+}
+
+// MarkTokenRotated atomically revokes a refresh token if it is not revoked yet.
+func (r *Repository) MarkTokenRotated(ctx context.Context, token string, ttl time.Duration) (bool, error) {
+	rotated, err := r.markOnce(ctx, jwt.RefreshToken, token, ttl)
+	if err != nil {
+		return false, fmt.Errorf("mark token rotated: %w", err)
+	}
+	return rotated, nil
+}
+
+// MarkTokenConsumed implements jwt.AtomicConsumeRepository.
+func (r *Repository) MarkTokenConsumed(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	consumed, err := r.markOnce(ctx, tokenType, token, ttl)
+	if err != nil {
+		return false, fmt.Errorf("mark token consumed: %w", err)
+	}
+	return consumed, nil
+}
+
+// markOnce puts the key of token unless it exists and reports whether it
+// did.
+func (r *Repository) markOnce(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	key, err := r.key(tokenType, token)
+	if err != nil {
+		return false, err
+	}
+
+	lease, err := r.grant(ctx, ttl)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := r.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, "1", clientv3.WithLease(lease))).
+		Commit()
+	if err != nil {
+		return false, err
+	}
+	if !resp.Succeeded {
+		// Already marked: release the unused lease instead of letting it
+		// linger until expiry.
+		_, _ = r.client.Revoke(ctx, lease)
+	}
+	return resp.Succeeded, nil
+}
+
+func (r *Repository) grant(ctx context.Context, ttl time.Duration) (clientv3.LeaseID, error) {
+	if ttl < minLeaseTTL {
+		ttl = minLeaseTTL
+	}
+	// Round up so the lease never expires before the token does.
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+
+	lease, err := r.client.Grant(ctx, seconds)
+	if err != nil {
+		return 0, fmt.Errorf("grant lease: %w", err)
+	}
+	return lease.ID, nil
+}
+
+func (r *Repository) key(tokenType jwt.TokenType, token string) (string, error) {
+	switch tokenType {
+	case jwt.AccessToken, jwt.RefreshToken, jwt.ActionToken:
+		return r.prefix + string(tokenType) + "/" + token, nil
+	default:
+		return "", fmt.Errorf("invalid token type: %v", tokenType)
+	}
+}
+
+// Ping implements jwt.Pinger with a count-only read of the revocation prefix,
+// which needs a quorum like every other operation here.
+func (r *Repository) Ping(ctx context.Context) error {
+	_, err := r.client.Get(ctx, r.prefix, clientv3.WithPrefix(), clientv3.WithCountOnly(), clientv3.WithLimit(1))
+	return err
+}
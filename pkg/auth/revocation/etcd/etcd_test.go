@@ -0,0 +1,230 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// fakeEtcd implements the parts of clientv3.KV and clientv3.Lease the
+// repository uses. Every key is attached to the lease granted last, since
+// the repository grants a lease right before each write; expire drops a
+// lease's keys as the server would.
+type fakeEtcd struct {
+	clientv3.KV
+	clientv3.Lease
+
+	keys    map[string]clientv3.LeaseID
+	grants  map[clientv3.LeaseID]int64
+	revoked []clientv3.LeaseID
+	lastID  clientv3.LeaseID
+	err     error
+}
+
+func newFakeEtcd() *fakeEtcd {
+	return &fakeEtcd{keys: make(map[string]clientv3.LeaseID), grants: make(map[clientv3.LeaseID]int64)}
+}
+
+func (f *fakeEtcd) client() *clientv3.Client {
+	return &clientv3.Client{KV: f, Lease: f}
+}
+
+func (f *fakeEtcd) expire(id clientv3.LeaseID) {
+	for key, lease := range f.keys {
+		if lease == id {
+			delete(f.keys, key)
+		}
+	}
+	delete(f.grants, id)
+}
+
+func (f *fakeEtcd) Put(_ context.Context, key, _ string, _ ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.keys[key] = f.lastID
+	return &clientv3.PutResponse{}, nil
+}
+
+func (f *fakeEtcd) Get(_ context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	op := clientv3.OpGet(key, opts...)
+	var count int64
+	if end := string(op.RangeBytes()); end != "" {
+		for k := range f.keys {
+			if k >= key && k < end {
+				count++
+			}
+		}
+	} else if _, ok := f.keys[key]; ok {
+		count = 1
+	}
+	return &clientv3.GetResponse{Count: count}, nil
+}
+
+func (f *fakeEtcd) Txn(context.Context) clientv3.Txn {
+	return &fakeTxn{f: f}
+}
+
+func (f *fakeEtcd) Grant(_ context.Context, ttl int64) (*clientv3.LeaseGrantResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.lastID++
+	f.grants[f.lastID] = ttl
+	return &clientv3.LeaseGrantResponse{ID: f.lastID, TTL: ttl}, nil
+}
+
+func (f *fakeEtcd) Revoke(_ context.Context, id clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error) {
+	f.revoked = append(f.revoked, id)
+	f.expire(id)
+	return &clientv3.LeaseRevokeResponse{}, nil
+}
+
+// fakeTxn only understands the "key does not exist" comparison of markOnce.
+type fakeTxn struct {
+	f    *fakeEtcd
+	cmps []clientv3.Cmp
+	ops  []clientv3.Op
+}
+
+func (t *fakeTxn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	t.cmps = append(t.cmps, cs...)
+	return t
+}
+
+func (t *fakeTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.ops = append(t.ops, ops...)
+	return t
+}
+
+func (t *fakeTxn) Else(...clientv3.Op) clientv3.Txn {
+	return t
+}
+
+func (t *fakeTxn) Commit() (*clientv3.TxnResponse, error) {
+	for i := range t.cmps {
+		if _, ok := t.f.keys[string(t.cmps[i].KeyBytes())]; ok {
+			return &clientv3.TxnResponse{Succeeded: false}, nil
+		}
+	}
+	for _, op := range t.ops {
+		if op.IsPut() {
+			t.f.keys[string(op.KeyBytes())] = t.f.lastID
+		}
+	}
+	return &clientv3.TxnResponse{Succeeded: true}, nil
+}
+
+func newTestRepository(t *testing.T) (*Repository, *fakeEtcd) {
+	t.Helper()
+	f := newFakeEtcd()
+	repo, err := NewRepository(f.client(), "")
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	return repo, f
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	repo, f := newTestRepository(t)
+
+	if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, "a", time.Hour); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if _, ok := f.keys[DefaultPrefix+"access/a"]; !ok {
+		t.Errorf("expected key %q, got %v", DefaultPrefix+"access/a", f.keys)
+	}
+	if revoked, err := repo.IsTokenRevoked(ctx, jwt.AccessToken, "a"); err != nil || !revoked {
+		t.Errorf("expected token to be revoked, got %v, %v", revoked, err)
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.RefreshToken, "a"); revoked {
+		t.Error("expected revocation to be scoped to the token type")
+	}
+	if err := repo.MarkTokenRevoke(ctx, "bogus", "a", time.Hour); err == nil {
+		t.Error("expected an unknown token type to be rejected")
+	}
+	if err := repo.Ping(ctx); err != nil {
+		t.Errorf("ping: %v", err)
+	}
+
+	f.expire(f.keys[DefaultPrefix+"access/a"])
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.AccessToken, "a"); revoked {
+		t.Error("expected the revocation to end with its lease")
+	}
+
+	f.err = errors.New("unavailable")
+	if _, err := repo.IsTokenRevoked(ctx, jwt.AccessToken, "a"); err == nil {
+		t.Error("expected a client error to be returned")
+	}
+}
+
+func TestRepository_LeaseTTL(t *testing.T) {
+	ctx := context.Background()
+	repo, f := newTestRepository(t)
+
+	for _, tc := range []struct {
+		ttl  time.Duration
+		want int64
+	}{
+		{time.Second, 5},
+		{0, 5},
+		{90 * time.Second, 90},
+		{90*time.Second + time.Millisecond, 91},
+	} {
+		if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, "t", tc.ttl); err != nil {
+			t.Fatalf("mark: %v", err)
+		}
+		if got := f.grants[f.lastID]; got != tc.want {
+			t.Errorf("ttl %v: granted %ds, want %ds", tc.ttl, got, tc.want)
+		}
+	}
+}
+
+func TestRepository_MarkTokenRotated(t *testing.T) {
+	ctx := context.Background()
+	repo, f := newTestRepository(t)
+
+	if rotated, err := repo.MarkTokenRotated(ctx, "r", time.Hour); err != nil || !rotated {
+		t.Fatalf("first rotation = %v, %v, want true", rotated, err)
+	}
+	if rotated, _ := repo.MarkTokenRotated(ctx, "r", time.Hour); rotated {
+		t.Error("expected second rotation to fail")
+	}
+	if len(f.revoked) != 1 || f.revoked[0] != f.lastID {
+		t.Errorf("expected the failed rotation's lease to be revoked, got %v", f.revoked)
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.RefreshToken, "r"); !revoked {
+		t.Error("expected the rotated token to be revoked")
+	}
+
+	if consumed, _ := repo.MarkTokenConsumed(ctx, jwt.ActionToken, "r", time.Hour); !consumed {
+		t.Error("expected consumption to be independent of rotation")
+	}
+	if consumed, _ := repo.MarkTokenConsumed(ctx, jwt.ActionToken, "r", time.Hour); consumed {
+		t.Error("expected a second consumption to fail")
+	}
+	if _, err := repo.MarkTokenConsumed(ctx, "bogus", "r", time.Hour); err == nil {
+		t.Error("expected an unknown token type to be rejected")
+	}
+}
+
+func TestNewRepository(t *testing.T) {
+	if _, err := NewRepository(nil, ""); err == nil {
+		t.Error("expected a nil client to be rejected")
+	}
+	repo, err := NewRepository(newFakeEtcd().client(), "/custom/")
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	if key, _ := repo.key(jwt.RefreshToken, "x"); key != "/custom/refresh/x" {
+		t.Errorf("key = %q, want /custom/refresh/x", key)
+	}
+}
@@ -0,0 +1,298 @@
+// Package failover chains a primary jwt.RevocationRepository with one or more
+// secondaries so that revocation state survives the loss of a single store,
+// e.g. Redis in front of a durable Postgres copy.
+//
+// Writes go to every store and succeed when at least one store accepted them;
+// failures on the others are logged. Reads try the stores in order and return
+// the first answer. A store that missed a write while it was down does not
+// learn about it afterwards, so pair a volatile primary with a durable
+// secondary and keep its outages short.
+//
+// MarkTokenRotated and MarkTokenConsumed are decided by the first store that
+// answers, atomically when that store implements jwt.AtomicRotationRepository
+// or jwt.AtomicConsumeRepository, and then mirrored to the remaining stores as
+// a plain revocation.
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// Repository is a jwt.RevocationRepository over an ordered list of stores.
+type Repository struct {
+	repos []jwt.RevocationRepository
+}
+
+// NewRepository returns a repository that prefers primary and falls back to
+// secondaries in the given order.
+func NewRepository(primary jwt.RevocationRepository, secondaries ...jwt.RevocationRepository) (*Repository, error) {
+	if primary == nil {
+		return nil, fmt.Errorf("primary repository cannot be nil")
+	}
+	if len(secondaries) == 0 {
+		return nil, fmt.Errorf("at least one secondary repository is required")
+	}
+	repos := []jwt.RevocationRepository{primary}
+	for _, repo := range secondaries {
+		if repo == nil {
+			return nil, fmt.Errorf("secondary repository cannot be nil")
+		}
+		repos = append(repos, repo)
+	}
+
+	return &Repository{repos: repos}, nil
+}
+
+func (r *Repository) MarkTokenRevoke(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) error {
+	return r.writeAll(ctx, "mark token revoked", func(repo jwt.RevocationRepository) error {
+		return repo.MarkTokenRevoke(ctx, tokenType, token, ttl)
+	})
+}
+
+func (r *Repository) IsTokenRevoked(ctx context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	return readFirst(ctx, r, "check revocation", func(repo jwt.RevocationRepository) (bool, error) {
+		return repo.IsTokenRevoked(ctx, tokenType, token)
+	})
+}
+
+// MarkTokenRotated implements jwt.AtomicRotationRepository. Rotation is only
+// race-free while the deciding store stays the same for every instance.
+func (r *Repository) MarkTokenRotated(ctx context.Context, token string, ttl time.Duration) (bool, error) {
+	return r.markOnce(ctx, "mark token rotated", jwt.RefreshToken, token, ttl, func(repo jwt.RevocationRepository) (bool, error) {
+		return rotate(ctx, repo, token, ttl)
+	})
+}
+
+// MarkTokenConsumed implements jwt.AtomicConsumeRepository. Like rotation,
+// it is only race-free while the deciding store stays the same for every
+// instance.
+func (r *Repository) MarkTokenConsumed(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	return r.markOnce(ctx, "mark token consumed", tokenType, token, ttl, func(repo jwt.RevocationRepository) (bool, error) {
+		return jwt.MarkTokenConsumed(ctx, repo, tokenType, token, ttl)
+	})
+}
+
+// markOnce lets the first store that answers decide with mark and mirrors
+// the outcome to the other stores as a plain revocation.
+func (r *Repository) markOnce(ctx context.Context, op string, tokenType jwt.TokenType, token string, ttl time.Duration, mark func(jwt.RevocationRepository) (bool, error)) (bool, error) {
+	var errs []error
+	for i, repo := range r.repos {
+		marked, err := mark(repo)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false, fmt.Errorf("%s: %w", op, err)
+			}
+			logx.WithContext(ctx).Errorf("failover: %s on store %d: %v", op, i, err)
+			errs = append(errs, err)
+			continue
+		}
+		for j, other := range r.repos {
+			if j == i {
+				continue
+			}
+			if err := other.MarkTokenRevoke(ctx, tokenType, token, ttl); err != nil {
+				logx.WithContext(ctx).Errorf("failover: mirror %s to store %d: %v", op, j, err)
+			}
+		}
+		return marked, nil
+	}
+	return false, fmt.Errorf("%s: %w", op, errors.Join(errs...))
+}
+
+// MarkSessionRevoked implements jwt.SessionRevocationRepository on the stores
+// that support it.
+func (r *Repository) MarkSessionRevoked(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error {
+	return r.writeAll(ctx, "mark session revoked", func(repo jwt.RevocationRepository) error {
+		sessions, ok := repo.(jwt.SessionRevocationRepository)
+		if !ok {
+			return errors.ErrUnsupported
+		}
+		return sessions.MarkSessionRevoked(ctx, sessionID, ttl)
+	})
+}
+
+// IsSessionRevoked implements jwt.SessionRevocationRepository on the stores
+// that support it.
+func (r *Repository) IsSessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	return readFirst(ctx, r, "check session revocation", func(repo jwt.RevocationRepository) (bool, error) {
+		sessions, ok := repo.(jwt.SessionRevocationRepository)
+		if !ok {
+			return false, errors.ErrUnsupported
+		}
+		return sessions.IsSessionRevoked(ctx, sessionID)
+	})
+}
+
+// MarkFamilyRevoked implements jwt.FamilyRevocationRepository on the stores
+// that support it.
+func (r *Repository) MarkFamilyRevoked(ctx context.Context, familyID uuid.UUID, ttl time.Duration) error {
+	return r.writeAll(ctx, "mark family revoked", func(repo jwt.RevocationRepository) error {
+		families, ok := repo.(jwt.FamilyRevocationRepository)
+		if !ok {
+			return errors.ErrUnsupported
+		}
+		return families.MarkFamilyRevoked(ctx, familyID, ttl)
+	})
+}
+
+// IsFamilyRevoked implements jwt.FamilyRevocationRepository on the stores
+// that support it.
+func (r *Repository) IsFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error) {
+	return readFirst(ctx, r, "check family revocation", func(repo jwt.RevocationRepository) (bool, error) {
+		families, ok := repo.(jwt.FamilyRevocationRepository)
+		if !ok {
+			return false, errors.ErrUnsupported
+		}
+		return families.IsFamilyRevoked(ctx, familyID)
+	})
+}
+
+// MarkUserRevoked implements jwt.UserRevocationRepository on the stores that
+// support it.
+func (r *Repository) MarkUserRevoked(ctx context.Context, userID uuid.UUID, before time.Time, ttl time.Duration) error {
+	return r.writeAll(ctx, "mark user revoked", func(repo jwt.RevocationRepository) error {
+		users, ok := repo.(jwt.UserRevocationRepository)
+		if !ok {
+			return errors.ErrUnsupported
+		}
+		return users.MarkUserRevoked(ctx, userID, before, ttl)
+	})
+}
+
+// UserRevokedBefore implements jwt.UserRevocationRepository on the stores that
+// support it.
+func (r *Repository) UserRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	return readFirst(ctx, r, "check user revocation", func(repo jwt.RevocationRepository) (time.Time, error) {
+		users, ok := repo.(jwt.UserRevocationRepository)
+		if !ok {
+			return time.Time{}, errors.ErrUnsupported
+		}
+		return users.UserRevokedBefore(ctx, userID)
+	})
+}
+
+// CleanupExpired implements jwt.Cleaner by cleaning up every store. It
+// returns the total removed and the errors of the stores that failed.
+func (r *Repository) CleanupExpired(ctx context.Context) (int64, error) {
+	var (
+		total int64
+		errs  []error
+	)
+	for _, repo := range r.repos {
+		n, err := jwt.CleanupExpired(ctx, repo)
+		total += n
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// Ping implements jwt.Pinger. It fails only when no store is reachable, since
+// reads fall over to any of them.
+func (r *Repository) Ping(ctx context.Context) error {
+	var errs []error
+	for _, repo := range r.repos {
+		err := jwt.Ping(ctx, repo)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// CountRevoked implements jwt.RevocationCounter by counting the first store
+// that can be counted, preferring the primary like reads do.
+func (r *Repository) CountRevoked(ctx context.Context) (map[jwt.TokenType]int64, error) {
+	var errs []error
+	for _, repo := range r.repos {
+		counts, err := jwt.CountRevoked(ctx, repo)
+		if err == nil {
+			return counts, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// writeAll applies fn to every store. It fails only when no store accepted the
+// write; stores returning errors.ErrUnsupported are skipped.
+func (r *Repository) writeAll(ctx context.Context, op string, fn func(jwt.RevocationRepository) error) error {
+	var (
+		errs    []error
+		written bool
+	)
+	for i, repo := range r.repos {
+		err := fn(repo)
+		switch {
+		case err == nil:
+			written = true
+		case errors.Is(err, errors.ErrUnsupported):
+		default:
+			logx.WithContext(ctx).Errorf("failover: %s on store %d: %v", op, i, err)
+			errs = append(errs, err)
+		}
+	}
+	if written {
+		return nil
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("%s: %w", op, errors.ErrUnsupported)
+	}
+	return fmt.Errorf("%s: %w", op, errors.Join(errs...))
+}
+
+// readFirst returns the answer of the first store that gives one. Stores
+// returning errors.ErrUnsupported are skipped; a cancelled ctx stops the
+// fallback.
+func readFirst[T any](ctx context.Context, r *Repository, op string, fn func(jwt.RevocationRepository) (T, error)) (T, error) {
+	var (
+		zero T
+		errs []error
+	)
+	for i, repo := range r.repos {
+		v, err := fn(repo)
+		switch {
+		case err == nil:
+			return v, nil
+		case errors.Is(err, errors.ErrUnsupported):
+			continue
+		case ctx.Err() != nil:
+			return zero, fmt.Errorf("%s: %w", op, err)
+		}
+		logx.WithContext(ctx).Errorf("failover: %s on store %d: %v", op, i, err)
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return zero, fmt.Errorf("%s: %w", op, errors.ErrUnsupported)
+	}
+	return zero, fmt.Errorf("%s: %w", op, errors.Join(errs...))
+}
+
+// rotate rotates token on a single store, falling back to a lookup followed
+// by a revoke, which is not race-free, when the store is not atomic.
+func rotate(ctx context.Context, repo jwt.RevocationRepository, token string, ttl time.Duration) (bool, error) {
+	if atomic, ok := repo.(jwt.AtomicRotationRepository); ok {
+		return atomic.MarkTokenRotated(ctx, token, ttl)
+	}
+
+	revoked, err := repo.IsTokenRevoked(ctx, jwt.RefreshToken, token)
+	if err != nil {
+		return false, err
+	}
+	if revoked {
+		return false, nil
+	}
+	if err := repo.MarkTokenRevoke(ctx, jwt.RefreshToken, token, ttl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,80 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/memory"
+)
+
+// flakyRepo fails every call while down is set.
+type flakyRepo struct {
+	*memory.Repository
+	down bool
+}
+
+var errDown = errors.New("store down")
+
+func (r *flakyRepo) MarkTokenRevoke(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) error {
+	if r.down {
+		return errDown
+	}
+	return r.Repository.MarkTokenRevoke(ctx, tokenType, token, ttl)
+}
+
+func (r *flakyRepo) IsTokenRevoked(ctx context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	if r.down {
+		return false, errDown
+	}
+	return r.Repository.IsTokenRevoked(ctx, tokenType, token)
+}
+
+func (r *flakyRepo) MarkTokenRotated(ctx context.Context, token string, ttl time.Duration) (bool, error) {
+	if r.down {
+		return false, errDown
+	}
+	return r.Repository.MarkTokenRotated(ctx, token, ttl)
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	primary := &flakyRepo{Repository: memory.NewRepository(0)}
+	secondary := &flakyRepo{Repository: memory.NewRepository(0)}
+	r, err := NewRepository(primary, secondary)
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+
+	if err := r.MarkTokenRevoke(ctx, jwt.AccessToken, "a", time.Hour); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if revoked, _ := secondary.Repository.IsTokenRevoked(ctx, jwt.AccessToken, "a"); !revoked {
+		t.Error("expected write to reach the secondary")
+	}
+
+	// Primary lost: reads and writes fall over to the secondary.
+	primary.down = true
+	if revoked, err := r.IsTokenRevoked(ctx, jwt.AccessToken, "a"); err != nil || !revoked {
+		t.Errorf("expected revocation from the secondary, got %v, %v", revoked, err)
+	}
+	if err := r.MarkTokenRevoke(ctx, jwt.AccessToken, "b", time.Hour); err != nil {
+		t.Errorf("expected write to succeed on the secondary, got %v", err)
+	}
+	if rotated, err := r.MarkTokenRotated(ctx, "r", time.Hour); err != nil || !rotated {
+		t.Errorf("expected rotation on the secondary, got %v, %v", rotated, err)
+	}
+	if rotated, _ := r.MarkTokenRotated(ctx, "r", time.Hour); rotated {
+		t.Error("expected second rotation to fail")
+	}
+
+	secondary.down = true
+	if _, err := r.IsTokenRevoked(ctx, jwt.AccessToken, "a"); !errors.Is(err, errDown) {
+		t.Errorf("expected error when every store is down, got %v", err)
+	}
+	if err := r.MarkTokenRevoke(ctx, jwt.AccessToken, "c", time.Hour); !errors.Is(err, errDown) {
+		t.Errorf("expected write error when every store is down, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+// Package firestore implements jwt.RevocationRepository on Google Cloud
+// Firestore, for serverless GCP deployments without a Redis instance.
+//
+// Each revocation is a document in one collection holding its expiry in the
+// expires_at field. Configure a TTL policy on that field so Firestore deletes
+// expired documents:
+//
+//	gcloud firestore fields ttls update expires_at --collection-group=revoked_tokens --enable-ttl
+//
+// TTL deletion runs up to a day late, so reads also compare expires_at with
+// the current time. Refresh token rotation runs in a transaction, making
+// RotateRefreshToken race-free (see jwt.AtomicRotationRepository).
+//
+// Document IDs are derived from the token type and the SHA-256 of the stored
+// key, since Firestore restricts ID characters and length.
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultCollection is the collection used when none is configured.
+const DefaultCollection = "revoked_tokens"
+
+type record struct {
+	ExpiresAt time.Time `firestore:"expires_at"`
+}
+
+// Repository is a Firestore-backed jwt.RevocationRepository.
+type Repository struct {
+	client     *firestore.Client
+	collection string
+	clock      jwt.Clock
+}
+
+// Option configures a Repository.
+type Option func(*Repository)
+
+// WithClock uses c for the current time when writing expires_at and when
+// comparing it on reads. Firestore's TTL deletion still follows real time.
+func WithClock(c jwt.Clock) Option {
+	return func(r *Repository) { r.clock = c }
+}
+
+// NewRepository returns a repository storing revocations in collection. An
+// empty collection defaults to DefaultCollection. The caller owns client and
+// must close it.
+func NewRepository(client *firestore.Client, collection string, opts ...Option) (*Repository, error) {
+	if client == nil {
+		return nil, fmt.Errorf("firestore client cannot be nil")
+	}
+	if collection == "" {
+		collection = DefaultCollection
+	}
+
+	r := &Repository{
+		client:     client,
+		collection: collection,
+		clock:      jwt.SystemClock{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+func (r *Repository) MarkTokenRevoke(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) error {
+	ref, err := r.doc(tokenType, token)
+	if err != nil {
+		return err
+	}
+
+	if _, err := ref.Set(ctx, record{ExpiresAt: r.clock.Now().Add(ttl)}); err != nil {
+		return fmt.Errorf("mark token revoked: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) IsTokenRevoked(ctx context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	ref, err := r.doc(tokenType, token)
+	if err != nil {
+		return false, err
+	}
+
+	snap, err := ref.Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("check revocation: %w", err)
+	}
+	return live(snap, r.clock.Now())
+}
+
+// MarkTokenRotated atomically revokes a refresh token if it is not revoked yet
+// using a Firestore transaction.
+func (r *Repository) MarkTokenRotated(ctx context.Context, token string, ttl time.Duration) (bool, error) {
+	rotated, err := r.markOnce(ctx, jwt.RefreshToken, token, ttl)
+	if err != nil {
+		return false, fmt.Errorf("mark token rotated: %w", err)
+	}
+	return rotated, nil
+}
+
+// MarkTokenConsumed implements jwt.AtomicConsumeRepository.
+func (r *Repository) MarkTokenConsumed(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	consumed, err := r.markOnce(ctx, tokenType, token, ttl)
+	if err != nil {
+		return false, fmt.Errorf("mark token consumed: %w", err)
+	}
+	return consumed, nil
+}
+
+// markOnce writes the document of token unless a live one exists and
+// reports whether it did.
+func (r *Repository) markOnce(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	ref, err := r.doc(tokenType, token)
+	if err != nil {
+		return false, err
+	}
+
+	var marked bool
+	err = r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		// The function is retried on contention; reset state each attempt.
+		marked = false
+		now := r.clock.Now()
+		snap, err := tx.Get(ref)
+		switch {
+		case status.Code(err) == codes.NotFound:
+		case err != nil:
+			return err
+		default:
+			revoked, err := live(snap, now)
+			if err != nil {
+				return err
+			}
+			if revoked {
+				return nil
+			}
+		}
+		marked = true
+		return tx.Set(ref, record{ExpiresAt: now.Add(ttl)})
+	})
+	return marked, err
+}
+
+// Ping implements jwt.Pinger.
+func (r *Repository) Ping(ctx context.Context) error {
+	iter := r.client.Collection(r.collection).Limit(1).Documents(ctx)
+	defer iter.Stop()
+	if _, err := iter.Next(); err != nil && err != iterator.Done {
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) doc(tokenType jwt.TokenType, token string) (*firestore.DocumentRef, error) {
+	switch tokenType {
+	case jwt.AccessToken, jwt.RefreshToken, jwt.ActionToken:
+	default:
+		return nil, fmt.Errorf("invalid token type: %v", tokenType)
+	}
+	return r.client.Collection(r.collection).Doc(string(tokenType) + "_" + jwt.HashToken(token)), nil
+}
+
+// live reports whether the revocation in snap is still in force at now.
+func live(snap *firestore.DocumentSnapshot, now time.Time) (bool, error) {
+	var rec record
+	if err := snap.DataTo(&rec); err != nil {
+		return false, fmt.Errorf("decode revocation: %w", err)
+	}
+	return now.Before(rec.ExpiresAt), nil
+}
@@ -0,0 +1,112 @@
+//go:build integration
+
+// Integration tests against the Firestore emulator. They are skipped unless
+// FIRESTORE_EMULATOR_HOST points at one:
+//
+//	gcloud emulators firestore start --host-port=localhost:8086
+//	FIRESTORE_EMULATOR_HOST=localhost:8086 go test -tags=integration ./pkg/auth/revocation/firestore/...
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/internal/clocktest"
+)
+
+func newIntegrationRepository(t *testing.T, opts ...Option) (*Repository, *firestore.Client) {
+	t.Helper()
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+	client, err := firestore.NewClient(context.Background(), "revocation-test")
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	// A collection per run keeps reruns against one emulator independent.
+	repo, err := NewRepository(client, fmt.Sprintf("revoked_tokens_%d", time.Now().UnixNano()), opts...)
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	return repo, client
+}
+
+func TestIntegration_Repository(t *testing.T) {
+	clock := clocktest.New(time.Now().Truncate(time.Second))
+	repo, _ := newIntegrationRepository(t, WithClock(clock))
+	ctx := context.Background()
+
+	if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, "a", time.Minute); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if revoked, err := repo.IsTokenRevoked(ctx, jwt.AccessToken, "a"); err != nil || !revoked {
+		t.Errorf("expected token to be revoked, got %v, %v", revoked, err)
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.RefreshToken, "a"); revoked {
+		t.Error("expected revocation to be scoped to the token type")
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.AccessToken, "b"); revoked {
+		t.Error("expected an unknown token not to be revoked")
+	}
+
+	ref, _ := repo.doc(jwt.AccessToken, "a")
+	snap, err := ref.Get(ctx)
+	if err != nil {
+		t.Fatalf("read document: %v", err)
+	}
+	var rec record
+	if err := snap.DataTo(&rec); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	if want := clock.Now().Add(time.Minute); !rec.ExpiresAt.Equal(want) {
+		t.Errorf("expires_at = %v, want %v", rec.ExpiresAt, want)
+	}
+
+	// TTL deletion runs late, so reads must ignore an expired document.
+	clock.Advance(time.Minute)
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.AccessToken, "a"); revoked {
+		t.Error("expected an expired revocation to be ignored")
+	}
+
+	if err := repo.Ping(ctx); err != nil {
+		t.Errorf("ping: %v", err)
+	}
+}
+
+func TestIntegration_MarkTokenRotated(t *testing.T) {
+	clock := clocktest.New(time.Now().Truncate(time.Second))
+	repo, _ := newIntegrationRepository(t, WithClock(clock))
+	ctx := context.Background()
+
+	if rotated, err := repo.MarkTokenRotated(ctx, "r", time.Minute); err != nil || !rotated {
+		t.Fatalf("first rotation = %v, %v, want true", rotated, err)
+	}
+	if rotated, err := repo.MarkTokenRotated(ctx, "r", time.Minute); err != nil || rotated {
+		t.Errorf("second rotation = %v, %v, want false", rotated, err)
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.RefreshToken, "r"); !revoked {
+		t.Error("expected the rotated token to be revoked")
+	}
+
+	if rotated, _ := repo.MarkTokenRotated(ctx, "expired", time.Second); !rotated {
+		t.Fatal("expected rotation to succeed")
+	}
+	clock.Advance(time.Second)
+	if rotated, _ := repo.MarkTokenRotated(ctx, "expired", time.Minute); !rotated {
+		t.Error("expected an expired marker to be reclaimed")
+	}
+
+	if consumed, _ := repo.MarkTokenConsumed(ctx, jwt.ActionToken, "r", time.Minute); !consumed {
+		t.Error("expected consumption to be independent of rotation")
+	}
+	if consumed, _ := repo.MarkTokenConsumed(ctx, jwt.ActionToken, "r", time.Minute); consumed {
+		t.Error("expected a second consumption to fail")
+	}
+}
@@ -0,0 +1,47 @@
+package firestore
+
+import (
+	"testing"
+
+	"cloud.google.com/go/firestore"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// References are built locally, so a zero client is enough to test them.
+
+func TestRepository_Doc(t *testing.T) {
+	repo, err := NewRepository(&firestore.Client{}, "")
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+
+	ref, err := repo.doc(jwt.AccessToken, "a")
+	if err != nil {
+		t.Fatalf("doc: %v", err)
+	}
+	if want := "access_" + jwt.HashToken("a"); ref.ID != want {
+		t.Errorf("document ID = %q, want %q", ref.ID, want)
+	}
+	if ref.Parent.ID != DefaultCollection {
+		t.Errorf("collection = %q, want %q", ref.Parent.ID, DefaultCollection)
+	}
+	if other, _ := repo.doc(jwt.RefreshToken, "a"); other.ID == ref.ID {
+		t.Error("expected documents to be scoped to the token type")
+	}
+	if _, err := repo.doc("bogus", "a"); err == nil {
+		t.Error("expected an unknown token type to be rejected")
+	}
+}
+
+func TestNewRepository(t *testing.T) {
+	if _, err := NewRepository(nil, ""); err == nil {
+		t.Error("expected a nil client to be rejected")
+	}
+	repo, err := NewRepository(&firestore.Client{}, "revocations")
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	if ref, _ := repo.doc(jwt.AccessToken, "a"); ref.Parent.ID != "revocations" {
+		t.Errorf("collection = %q, want revocations", ref.Parent.ID)
+	}
+}
@@ -0,0 +1,61 @@
+// Package benchmark holds the benchmarks shared by the revocation
+// repositories, so backends are measured on the same workload.
+package benchmark
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// Entries is the number of revocations stored before measuring.
+const Entries = 10_000
+
+// Repository benchmarks repo: revoking tokens, and looking up revoked
+// ("hit") and unrevoked ("miss") tokens, the common case of verification.
+// Run it from a Benchmark function of the repository's package.
+func Repository(b *testing.B, repo jwt.RevocationRepository) {
+	ctx := context.Background()
+	for i := 0; i < Entries; i++ {
+		if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, token("seed", i), time.Hour); err != nil {
+			b.Fatalf("seed: %v", err)
+		}
+	}
+
+	b.Run("MarkTokenRevoke", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, token("mark", i), time.Hour); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	for name, prefix := range map[string]string{"IsTokenRevoked/hit": "seed", "IsTokenRevoked/miss": "miss"} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.IsTokenRevoked(ctx, jwt.AccessToken, token(prefix, i%Entries)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+	b.Run("IsTokenRevoked/parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				if _, err := repo.IsTokenRevoked(ctx, jwt.AccessToken, token("seed", i%Entries)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
+
+// token returns a token-sized key; repositories store SHA-256 hex digests.
+func token(prefix string, i int) string {
+	return prefix + "-" + strconv.Itoa(i) + "-0123456789abcdef0123456789abcdef0123456789abcdef0123456789"
+}
@@ -0,0 +1,34 @@
+// Package clocktest provides a fake jwt.Clock for testing how the revocation
+// repositories expire entries without sleeping.
+package clocktest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a jwt.Clock that stands still until advanced. It is safe for
+// concurrent use.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// New returns a Clock set to now.
+func New(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
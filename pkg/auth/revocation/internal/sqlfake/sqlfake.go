@@ -0,0 +1,357 @@
+// Package sqlfake is an in-memory database/sql driver for testing the SQL
+// revocation repositories without a database server or a cgo driver.
+//
+// It does not parse SQL. It understands the statement shapes the sqlite and
+// sqldb packages issue against a revoked_tokens style table, telling them
+// apart by their verb and argument count, and records every statement so
+// tests can check the SQL each dialect sends:
+//
+//   - CREATE TABLE and CREATE INDEX create the named table or index;
+//   - INSERT with 3 arguments (type, token, expires_at) revokes, keeping the
+//     later expiry;
+//   - INSERT with 4 arguments (type, token, expires_at, now) claims the row
+//     only if it is absent or expired at now;
+//   - SELECT (type, token, now) returns one row if the token is revoked at now;
+//   - DELETE (now) removes the rows expired at now;
+//   - PRAGMA is recorded and otherwise ignored.
+//
+// Statements against a table that was never created fail, like they would on
+// a real database.
+package sqlfake
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	tableRe = regexp.MustCompile(`(?i)(?:INTO|FROM|TABLE IF NOT EXISTS|TABLE)\s+([A-Za-z0-9_.]+)`)
+	indexRe = regexp.MustCompile(`(?i)INDEX\s+(?:IF NOT EXISTS\s+)?([A-Za-z0-9_]+)`)
+	spaceRe = regexp.MustCompile(`\s+`)
+)
+
+// Option configures a Driver.
+type Option func(*Driver)
+
+// WithMySQLRowsAffected reports 2 affected rows when a claiming INSERT
+// replaces an expired row, as MySQL does for ON DUPLICATE KEY UPDATE.
+func WithMySQLRowsAffected() Option {
+	return func(d *Driver) { d.mysql = true }
+}
+
+type rowKey struct {
+	table, tokenType, token string
+}
+
+// Driver is the in-memory database. It implements driver.Driver and
+// driver.Connector, so it can be registered or passed to sql.OpenDB.
+type Driver struct {
+	mysql bool
+
+	mu         sync.Mutex
+	rows       map[rowKey]int64
+	tables     map[string]bool
+	indexes    []string
+	statements []string
+	conns      []*conn
+}
+
+// New returns an empty database.
+func New(opts ...Option) *Driver {
+	d := &Driver{rows: make(map[rowKey]int64), tables: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+var registered atomic.Int64
+
+// Register registers d under a new driver name and returns the name.
+func Register(d *Driver) string {
+	name := fmt.Sprintf("sqlfake%d", registered.Add(1))
+	sql.Register(name, d)
+	return name
+}
+
+// Open opens d with sql.OpenDB.
+func Open(d *Driver) *sql.DB {
+	return sql.OpenDB(d)
+}
+
+// Open implements driver.Driver; the name is ignored.
+func (d *Driver) Open(string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := &conn{d: d}
+	d.conns = append(d.conns, c)
+	return c, nil
+}
+
+// Connect implements driver.Connector.
+func (d *Driver) Connect(context.Context) (driver.Conn, error) {
+	return d.Open("")
+}
+
+// Driver implements driver.Connector.
+func (d *Driver) Driver() driver.Driver {
+	return d
+}
+
+// Statements returns every statement executed so far, with runs of white
+// space collapsed to one space.
+func (d *Driver) Statements() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.statements...)
+}
+
+// Indexes returns the names of the indexes created so far.
+func (d *Driver) Indexes() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.indexes...)
+}
+
+// HasTable reports whether table was created.
+func (d *Driver) HasTable(table string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.tables[table]
+}
+
+// Pragmas returns the PRAGMA statements run on each connection opened so
+// far, in the order the connections were opened.
+func (d *Driver) Pragmas() [][]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pragmas := make([][]string, len(d.conns))
+	for i, c := range d.conns {
+		pragmas[i] = append([]string(nil), c.pragmas...)
+	}
+	return pragmas
+}
+
+// Len returns the number of stored rows, expired or not.
+func (d *Driver) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.rows)
+}
+
+func (d *Driver) exec(c *conn, query string, args []driver.Value) (int64, [][]driver.Value, error) {
+	query = strings.TrimSpace(spaceRe.ReplaceAllString(query, " "))
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements = append(d.statements, query)
+
+	verb, _, _ := strings.Cut(query, " ")
+	verb = strings.ToUpper(verb)
+	if verb == "PRAGMA" {
+		c.pragmas = append(c.pragmas, query)
+		return 0, nil, nil
+	}
+	if verb == "CREATE" {
+		for _, stmt := range strings.Split(query, ";") {
+			stmt = strings.TrimSpace(stmt)
+			if m := tableRe.FindStringSubmatch(stmt); m != nil && strings.HasPrefix(strings.ToUpper(stmt), "CREATE TABLE") {
+				d.tables[m[1]] = true
+			}
+			for _, m := range indexRe.FindAllStringSubmatch(stmt, -1) {
+				d.indexes = append(d.indexes, m[1])
+			}
+		}
+		return 0, nil, nil
+	}
+
+	m := tableRe.FindStringSubmatch(query)
+	if m == nil {
+		return 0, nil, fmt.Errorf("sqlfake: no table in %q", query)
+	}
+	table := m[1]
+	if !d.tables[table] {
+		return 0, nil, fmt.Errorf("sqlfake: no such table: %s", table)
+	}
+
+	switch {
+	case verb == "INSERT" && len(args) == 3:
+		key, err := rowKeyOf(table, args)
+		if err != nil {
+			return 0, nil, err
+		}
+		expiresAt, err := int64Arg(args[2])
+		if err != nil {
+			return 0, nil, err
+		}
+		d.rows[key] = max(d.rows[key], expiresAt)
+		return 1, nil, nil
+
+	case verb == "INSERT" && len(args) == 4:
+		key, err := rowKeyOf(table, args)
+		if err != nil {
+			return 0, nil, err
+		}
+		expiresAt, err := int64Arg(args[2])
+		if err != nil {
+			return 0, nil, err
+		}
+		now, err := int64Arg(args[3])
+		if err != nil {
+			return 0, nil, err
+		}
+		existing, ok := d.rows[key]
+		if ok && existing > now {
+			return 0, nil, nil
+		}
+		d.rows[key] = expiresAt
+		if ok && d.mysql {
+			return 2, nil, nil
+		}
+		return 1, nil, nil
+
+	case verb == "SELECT" && len(args) == 3:
+		key, err := rowKeyOf(table, args)
+		if err != nil {
+			return 0, nil, err
+		}
+		now, err := int64Arg(args[2])
+		if err != nil {
+			return 0, nil, err
+		}
+		if expiresAt, ok := d.rows[key]; ok && expiresAt > now {
+			return 0, [][]driver.Value{{int64(1)}}, nil
+		}
+		return 0, nil, nil
+
+	case verb == "DELETE" && len(args) == 1:
+		now, err := int64Arg(args[0])
+		if err != nil {
+			return 0, nil, err
+		}
+		var n int64
+		for key, expiresAt := range d.rows {
+			if key.table == table && expiresAt <= now {
+				delete(d.rows, key)
+				n++
+			}
+		}
+		return n, nil, nil
+	}
+	return 0, nil, fmt.Errorf("sqlfake: unsupported statement %q with %d arguments", query, len(args))
+}
+
+func rowKeyOf(table string, args []driver.Value) (rowKey, error) {
+	tokenType, ok1 := args[0].(string)
+	token, ok2 := args[1].(string)
+	if !ok1 || !ok2 {
+		return rowKey{}, fmt.Errorf("sqlfake: token type and token must be strings, got %T and %T", args[0], args[1])
+	}
+	return rowKey{table, tokenType, token}, nil
+}
+
+func int64Arg(v driver.Value) (int64, error) {
+	n, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("sqlfake: expected an int64 argument, got %T", v)
+	}
+	return n, nil
+}
+
+type conn struct {
+	d       *Driver
+	pragmas []string
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, errors.New("sqlfake: transactions are not supported")
+}
+
+func (c *conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	n, _, err := c.d.exec(c, query, values(args))
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func (c *conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	_, data, err := c.d.exec(c, query, values(args))
+	if err != nil {
+		return nil, err
+	}
+	return &rows{data: data}, nil
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vals := make([]driver.Value, len(args))
+	for i, arg := range args {
+		vals[i] = arg.Value
+	}
+	return vals
+}
+
+type stmt struct {
+	c     *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	n, _, err := s.c.d.exec(s.c, s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	_, data, err := s.c.d.exec(s.c, s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{data: data}, nil
+}
+
+type rows struct {
+	data [][]driver.Value
+}
+
+func (r *rows) Columns() []string {
+	return []string{"one"}
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.data) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.data[0])
+	r.data = r.data[1:]
+	return nil
+}
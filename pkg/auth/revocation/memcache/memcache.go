@@ -0,0 +1,164 @@
+// Package memcache implements jwt.RevocationRepository on Memcached, for
+// deployments standardized on Memcached rather than Redis.
+//
+// Memcached keys are limited to 250 bytes without whitespace, which a JWT
+// easily exceeds, so entries are keyed by the SHA-256 of the token. Expiry is
+// delegated to Memcached item expiration; refresh token rotation uses ADD,
+// which only stores an item if the key is absent, making RotateRefreshToken
+// race-free (see jwt.AtomicRotationRepository).
+//
+// Memcached may evict items under memory pressure before they expire. Size the
+// cache so revocations are not evicted, or front it with a durable store via a
+// failover chain.
+package memcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	gomemcache "github.com/bradfitz/gomemcache/memcache"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+const (
+	// DefaultPrefix is the key prefix used when none is configured.
+	DefaultPrefix = "revoked:"
+
+	// maxRelativeExpiry is the largest expiration Memcached interprets as
+	// relative seconds; larger values are treated as Unix timestamps.
+	maxRelativeExpiry = 30 * 24 * time.Hour
+)
+
+// Repository is a Memcached-backed jwt.RevocationRepository.
+type Repository struct {
+	client *gomemcache.Client
+	prefix string
+	clock  jwt.Clock
+}
+
+// Option configures a Repository.
+type Option func(*Repository)
+
+// WithClock uses c for the timestamps of expirations past 30 days; shorter
+// ones are relative and expire on Memcached's own clock.
+func WithClock(c jwt.Clock) Option {
+	return func(r *Repository) { r.clock = c }
+}
+
+// NewRepository returns a repository storing keys under prefix. An empty
+// prefix defaults to DefaultPrefix.
+func NewRepository(client *gomemcache.Client, prefix string, opts ...Option) (*Repository, error) {
+	if client == nil {
+		return nil, fmt.Errorf("memcache client cannot be nil")
+	}
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+
+	r := &Repository{
+		client: client,
+		prefix: prefix,
+		clock:  jwt.SystemClock{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+// MarkTokenRevoke stores the revocation. The gomemcache client has no context
+// support; deadlines come from the client's Timeout.
+func (r *Repository) MarkTokenRevoke(_ context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) error {
+	key, err := r.key(tokenType, token)
+	if err != nil {
+		return err
+	}
+
+	if err := r.client.Set(&gomemcache.Item{Key: key, Value: []byte("1"), Expiration: r.expiration(ttl)}); err != nil {
+		return fmt.Errorf("mark token revoked: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) IsTokenRevoked(_ context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	key, err := r.key(tokenType, token)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = r.client.Get(key)
+	if errors.Is(err, gomemcache.ErrCacheMiss) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("check revocation: %w", err)
+	}
+	return true, nil
+}
+
+// MarkTokenRotated atomically revokes a refresh token if it is not revoked yet.
+func (r *Repository) MarkTokenRotated(_ context.Context, token string, ttl time.Duration) (bool, error) {
+	rotated, err := r.markOnce(jwt.RefreshToken, token, ttl)
+	if err != nil {
+		return false, fmt.Errorf("mark token rotated: %w", err)
+	}
+	return rotated, nil
+}
+
+// MarkTokenConsumed implements jwt.AtomicConsumeRepository.
+func (r *Repository) MarkTokenConsumed(_ context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	consumed, err := r.markOnce(tokenType, token, ttl)
+	if err != nil {
+		return false, fmt.Errorf("mark token consumed: %w", err)
+	}
+	return consumed, nil
+}
+
+// markOnce adds the item of token unless it exists and reports whether it
+// did.
+func (r *Repository) markOnce(tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	key, err := r.key(tokenType, token)
+	if err != nil {
+		return false, err
+	}
+
+	err = r.client.Add(&gomemcache.Item{Key: key, Value: []byte("1"), Expiration: r.expiration(ttl)})
+	if errors.Is(err, gomemcache.ErrNotStored) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *Repository) key(tokenType jwt.TokenType, token string) (string, error) {
+	switch tokenType {
+	case jwt.AccessToken, jwt.RefreshToken, jwt.ActionToken:
+		sum := sha256.Sum256([]byte(token))
+		return r.prefix + string(tokenType) + ":" + hex.EncodeToString(sum[:]), nil
+	default:
+		return "", fmt.Errorf("invalid token type: %v", tokenType)
+	}
+}
+
+// expiration converts ttl to Memcached's expiration format, rounding up so an
+// item never expires before the token.
+func (r *Repository) expiration(ttl time.Duration) int32 {
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	if ttl > maxRelativeExpiry {
+		return int32(r.clock.Now().Add(ttl).Unix())
+	}
+	return int32((ttl + time.Second - 1) / time.Second)
+}
+
+// Ping implements jwt.Pinger. gomemcache has no context support.
+func (r *Repository) Ping(_ context.Context) error {
+	return r.client.Ping()
+}
@@ -0,0 +1,286 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gomemcache "github.com/bradfitz/gomemcache/memcache"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/internal/clocktest"
+)
+
+// server is an in-process Memcached speaking the subset of the text protocol
+// the repository uses: set, add, gets and version. It records each item's
+// expiration as sent and never expires items itself; tests call expire.
+type server struct {
+	ln net.Listener
+
+	mu    sync.Mutex
+	items map[string]int64
+	conns []net.Conn
+}
+
+func newServer(t *testing.T) *server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &server{ln: ln, items: make(map[string]int64)}
+	go s.serve()
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *server) close() {
+	_ = s.ln.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+func (s *server) handle(conn net.Conn) {
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if err := s.command(rw, strings.Fields(line)); err != nil {
+			return
+		}
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *server) command(rw *bufio.ReadWriter, fields []string) error {
+	if len(fields) == 0 {
+		_, err := rw.WriteString("ERROR\r\n")
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch cmd := fields[0]; {
+	case (cmd == "set" || cmd == "add") && len(fields) == 5:
+		exp, err1 := strconv.ParseInt(fields[3], 10, 64)
+		size, err2 := strconv.Atoi(fields[4])
+		if err1 != nil || err2 != nil {
+			_, err := rw.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return err
+		}
+		if _, err := io.CopyN(io.Discard, rw, int64(size)+2); err != nil {
+			return err
+		}
+		if _, ok := s.items[fields[1]]; ok && cmd == "add" {
+			_, err := rw.WriteString("NOT_STORED\r\n")
+			return err
+		}
+		s.items[fields[1]] = exp
+		_, err := rw.WriteString("STORED\r\n")
+		return err
+
+	case cmd == "gets":
+		for _, key := range fields[1:] {
+			if _, ok := s.items[key]; ok {
+				if _, err := fmt.Fprintf(rw, "VALUE %s 0 1 1\r\n1\r\n", key); err != nil {
+					return err
+				}
+			}
+		}
+		_, err := rw.WriteString("END\r\n")
+		return err
+
+	case cmd == "version":
+		_, err := rw.WriteString("VERSION 1.6.0\r\n")
+		return err
+	}
+	_, err := rw.WriteString("ERROR\r\n")
+	return err
+}
+
+func (s *server) expiration(key string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exp, ok := s.items[key]
+	return exp, ok
+}
+
+func (s *server) expire(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+}
+
+func newTestRepository(t *testing.T, opts ...Option) (*Repository, *server) {
+	t.Helper()
+	s := newServer(t)
+	repo, err := NewRepository(gomemcache.New(s.ln.Addr().String()), "", opts...)
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	return repo, s
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	repo, s := newTestRepository(t)
+
+	if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, "a", time.Hour); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if revoked, err := repo.IsTokenRevoked(ctx, jwt.AccessToken, "a"); err != nil || !revoked {
+		t.Errorf("expected token to be revoked, got %v, %v", revoked, err)
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.RefreshToken, "a"); revoked {
+		t.Error("expected revocation to be scoped to the token type")
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.AccessToken, "b"); revoked {
+		t.Error("expected an unknown token not to be revoked")
+	}
+	if err := repo.MarkTokenRevoke(ctx, "bogus", "a", time.Hour); err == nil {
+		t.Error("expected an unknown token type to be rejected")
+	}
+	if err := repo.Ping(ctx); err != nil {
+		t.Errorf("ping: %v", err)
+	}
+
+	key, _ := repo.key(jwt.AccessToken, "a")
+	s.expire(key)
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.AccessToken, "a"); revoked {
+		t.Error("expected the revocation to end with its item")
+	}
+}
+
+func TestRepository_Key(t *testing.T) {
+	repo, _ := newTestRepository(t)
+
+	long := strings.Repeat("x", 4096) + " with spaces"
+	key, err := repo.key(jwt.RefreshToken, long)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	if len(key) > 250 || strings.ContainsAny(key, " \r\n") {
+		t.Errorf("expected a valid Memcached key, got %q", key)
+	}
+	if !strings.HasPrefix(key, DefaultPrefix+"refresh:") {
+		t.Errorf("expected key %q to start with %q", key, DefaultPrefix+"refresh:")
+	}
+	if other, _ := repo.key(jwt.AccessToken, long); other == key {
+		t.Error("expected keys to be scoped to the token type")
+	}
+	if err := repo.MarkTokenRevoke(context.Background(), jwt.RefreshToken, long, time.Hour); err != nil {
+		t.Errorf("mark long token: %v", err)
+	}
+}
+
+func TestRepository_Expiration(t *testing.T) {
+	ctx := context.Background()
+	clock := clocktest.New(time.Unix(1_700_000_000, 0))
+	repo, s := newTestRepository(t, WithClock(clock))
+
+	for _, tc := range []struct {
+		ttl  time.Duration
+		want int64
+	}{
+		{0, 1},
+		{time.Millisecond, 1},
+		{90 * time.Second, 90},
+		{90*time.Second + time.Millisecond, 91},
+		{maxRelativeExpiry, int64(maxRelativeExpiry / time.Second)},
+	} {
+		if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, "t", tc.ttl); err != nil {
+			t.Fatalf("mark: %v", err)
+		}
+		key, _ := repo.key(jwt.AccessToken, "t")
+		if got, _ := s.expiration(key); got != tc.want {
+			t.Errorf("ttl %v: expiration %d, want %d", tc.ttl, got, tc.want)
+		}
+	}
+
+	// Past 30 days Memcached reads the expiration as a Unix timestamp.
+	ttl := 60 * 24 * time.Hour
+	if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, "long", ttl); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	key, _ := repo.key(jwt.AccessToken, "long")
+	want := clock.Now().Add(ttl).Unix()
+	if got, _ := s.expiration(key); got != want {
+		t.Errorf("expiration %d, want %d", got, want)
+	}
+}
+
+func TestRepository_MarkTokenRotated(t *testing.T) {
+	ctx := context.Background()
+	repo, s := newTestRepository(t)
+
+	if rotated, err := repo.MarkTokenRotated(ctx, "r", time.Hour); err != nil || !rotated {
+		t.Fatalf("first rotation = %v, %v, want true", rotated, err)
+	}
+	if rotated, err := repo.MarkTokenRotated(ctx, "r", time.Hour); err != nil || rotated {
+		t.Errorf("second rotation = %v, %v, want false", rotated, err)
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.RefreshToken, "r"); !revoked {
+		t.Error("expected the rotated token to be revoked")
+	}
+
+	key, _ := repo.key(jwt.RefreshToken, "r")
+	s.expire(key)
+	if rotated, _ := repo.MarkTokenRotated(ctx, "r", time.Hour); !rotated {
+		t.Error("expected an expired marker to be reclaimed")
+	}
+
+	if consumed, _ := repo.MarkTokenConsumed(ctx, jwt.ActionToken, "r", time.Hour); !consumed {
+		t.Error("expected consumption to be independent of rotation")
+	}
+	if consumed, _ := repo.MarkTokenConsumed(ctx, jwt.ActionToken, "r", time.Hour); consumed {
+		t.Error("expected a second consumption to fail")
+	}
+	if _, err := repo.MarkTokenConsumed(ctx, "bogus", "r", time.Hour); err == nil {
+		t.Error("expected an unknown token type to be rejected")
+	}
+}
+
+func TestRepository_ServerDown(t *testing.T) {
+	ctx := context.Background()
+	repo, s := newTestRepository(t)
+	s.close()
+
+	if _, err := repo.IsTokenRevoked(ctx, jwt.AccessToken, "a"); err == nil {
+		t.Error("expected a connection error to be returned")
+	}
+	if _, err := repo.MarkTokenRotated(ctx, "r", time.Hour); err == nil {
+		t.Error("expected a connection error to be returned")
+	}
+	if err := repo.Ping(ctx); err == nil {
+		t.Error("expected ping to fail")
+	}
+	if _, err := NewRepository(nil, ""); err == nil {
+		t.Error("expected a nil client to be rejected")
+	}
+}
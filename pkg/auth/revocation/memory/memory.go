@@ -0,0 +1,472 @@
+// Package memory implements jwt.RevocationRepository in process memory. It
+// suits tests, single-instance deployments and the local tier of
+// pkg/auth/revocation/cached; revocations are lost on restart and are not
+// shared between instances.
+//
+// Entries are spread over independently locked shards by key hash, so
+// concurrent verifications rarely contend on the same lock.
+//
+// Expired entries are ignored by reads and removed by CleanupExpired.
+// MaxEntries bounds memory use and is split evenly between shards: when a
+// shard is full, its expired entries are dropped first and then the entries
+// closest to expiry. Per-user cutoffs are rare and kept apart from that limit,
+// as are opaque token claims, which cannot be evicted without signing their
+// users out.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"hash/maphash"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// DefaultMaxEntries is used when NewRepository is given a non-positive limit.
+const DefaultMaxEntries = 100_000
+
+// DefaultShards is the shard count used by NewRepository.
+const DefaultShards = 32
+
+// sessionKeyType marks session revocations in entries; it is never a valid
+// jwt.TokenType, so they cannot collide with token revocations.
+const sessionKeyType jwt.TokenType = "session"
+
+// familyKeyType marks refresh token family revocations in entries, like
+// sessionKeyType.
+const familyKeyType jwt.TokenType = "family"
+
+type userCutoff struct {
+	before    time.Time
+	expiresAt time.Time
+}
+
+// Option configures a Repository, SessionStore or PairingStore.
+type Option func(*options)
+
+type options struct {
+	clock jwt.Clock
+}
+
+// WithClock makes expiry follow c instead of the system clock, e.g. the
+// TokenMaker's clock or a fake one in tests.
+func WithClock(c jwt.Clock) Option {
+	return func(o *options) { o.clock = c }
+}
+
+func resolve(opts []Option) options {
+	o := options{clock: jwt.SystemClock{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+type entryKey struct {
+	tokenType jwt.TokenType
+	token     string
+}
+
+type opaqueEntry struct {
+	claims    string
+	expiresAt time.Time
+}
+
+type shard struct {
+	mu         sync.RWMutex
+	entries    map[entryKey]time.Time
+	users      map[uuid.UUID]userCutoff
+	opaque     map[entryKey]opaqueEntry
+	maxEntries int
+}
+
+// Repository is an in-memory jwt.RevocationRepository. It is safe for
+// concurrent use.
+type Repository struct {
+	shards []*shard
+	seed   maphash.Seed
+	now    func() time.Time
+}
+
+// NewRepository returns an empty repository with DefaultShards shards holding
+// at most maxEntries revocations.
+func NewRepository(maxEntries int, opts ...Option) *Repository {
+	return NewShardedRepository(DefaultShards, maxEntries, opts...)
+}
+
+// NewShardedRepository returns an empty repository with the given number of
+// shards holding at most maxEntries revocations. The shard count is capped at
+// maxEntries so that every shard can hold at least one entry.
+func NewShardedRepository(shards, maxEntries int, opts ...Option) *Repository {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	if shards <= 0 {
+		shards = DefaultShards
+	}
+	shards = min(shards, maxEntries)
+
+	r := &Repository{
+		shards: make([]*shard, shards),
+		seed:   maphash.MakeSeed(),
+		now:    resolve(opts).clock.Now,
+	}
+	for i := range r.shards {
+		r.shards[i] = &shard{
+			entries:    make(map[entryKey]time.Time),
+			users:      make(map[uuid.UUID]userCutoff),
+			opaque:     make(map[entryKey]opaqueEntry),
+			maxEntries: maxEntries / shards,
+		}
+	}
+	return r
+}
+
+func (r *Repository) MarkTokenRevoke(_ context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) error {
+	if err := validateTokenType(tokenType); err != nil {
+		return err
+	}
+
+	now := r.now()
+	s := r.shardFor(token)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(entryKey{tokenType, token}, now.Add(ttl), now)
+	return nil
+}
+
+func (r *Repository) IsTokenRevoked(_ context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	if err := validateTokenType(tokenType); err != nil {
+		return false, err
+	}
+
+	s := r.shardFor(token)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	expiresAt, ok := s.entries[entryKey{tokenType, token}]
+	return ok && r.now().Before(expiresAt), nil
+}
+
+// MarkTokenRotated atomically revokes a refresh token if it is not revoked yet.
+func (r *Repository) MarkTokenRotated(_ context.Context, token string, ttl time.Duration) (bool, error) {
+	return r.markOnce(jwt.RefreshToken, token, ttl), nil
+}
+
+// MarkTokenConsumed implements jwt.AtomicConsumeRepository.
+func (r *Repository) MarkTokenConsumed(_ context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	if err := validateTokenType(tokenType); err != nil {
+		return false, err
+	}
+	return r.markOnce(tokenType, token, ttl), nil
+}
+
+// markOnce records token unless it is already recorded and reports whether
+// it did.
+func (r *Repository) markOnce(tokenType jwt.TokenType, token string, ttl time.Duration) bool {
+	key := entryKey{tokenType, token}
+	now := r.now()
+	s := r.shardFor(token)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if expiresAt, ok := s.entries[key]; ok && now.Before(expiresAt) {
+		return false
+	}
+	s.put(key, now.Add(ttl), now)
+	return true
+}
+
+// MarkSessionRevoked implements jwt.SessionRevocationRepository.
+func (r *Repository) MarkSessionRevoked(_ context.Context, sessionID uuid.UUID, ttl time.Duration) error {
+	id := sessionID.String()
+	now := r.now()
+	s := r.shardFor(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(entryKey{sessionKeyType, id}, now.Add(ttl), now)
+	return nil
+}
+
+// IsSessionRevoked implements jwt.SessionRevocationRepository.
+func (r *Repository) IsSessionRevoked(_ context.Context, sessionID uuid.UUID) (bool, error) {
+	id := sessionID.String()
+	s := r.shardFor(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	expiresAt, ok := s.entries[entryKey{sessionKeyType, id}]
+	return ok && r.now().Before(expiresAt), nil
+}
+
+// MarkFamilyRevoked implements jwt.FamilyRevocationRepository.
+func (r *Repository) MarkFamilyRevoked(_ context.Context, familyID uuid.UUID, ttl time.Duration) error {
+	id := familyID.String()
+	now := r.now()
+	s := r.shardFor(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(entryKey{familyKeyType, id}, now.Add(ttl), now)
+	return nil
+}
+
+// IsFamilyRevoked implements jwt.FamilyRevocationRepository.
+func (r *Repository) IsFamilyRevoked(_ context.Context, familyID uuid.UUID) (bool, error) {
+	id := familyID.String()
+	s := r.shardFor(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	expiresAt, ok := s.entries[entryKey{familyKeyType, id}]
+	return ok && r.now().Before(expiresAt), nil
+}
+
+// MarkUserRevoked implements jwt.UserRevocationRepository. A later cutoff
+// replaces an earlier one; an earlier one is ignored.
+func (r *Repository) MarkUserRevoked(_ context.Context, userID uuid.UUID, before time.Time, ttl time.Duration) error {
+	s := r.shardFor(userID.String())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.users[userID]; ok && !before.After(existing.before) {
+		return nil
+	}
+	s.users[userID] = userCutoff{before: before, expiresAt: r.now().Add(ttl)}
+	return nil
+}
+
+// UserRevokedBefore implements jwt.UserRevocationRepository.
+func (r *Repository) UserRevokedBefore(_ context.Context, userID uuid.UUID) (time.Time, error) {
+	s := r.shardFor(userID.String())
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cutoff, ok := s.users[userID]
+	if !ok || !r.now().Before(cutoff.expiresAt) {
+		return time.Time{}, nil
+	}
+	return cutoff.before, nil
+}
+
+// RevocationStatus implements jwt.RevocationStatusRepository.
+func (r *Repository) RevocationStatus(ctx context.Context, q jwt.RevocationQuery) (jwt.RevocationStatus, error) {
+	var status jwt.RevocationStatus
+	var err error
+	if q.Token != "" {
+		if status.TokenRevoked, err = r.IsTokenRevoked(ctx, q.TokenType, q.Token); err != nil {
+			return jwt.RevocationStatus{}, err
+		}
+	}
+	if q.RotationKey != "" {
+		status.TokenRotated, _ = r.IsTokenRevoked(ctx, jwt.RefreshToken, q.RotationKey)
+	}
+	if q.SessionID != uuid.Nil {
+		status.SessionRevoked, _ = r.IsSessionRevoked(ctx, q.SessionID)
+	}
+	if q.FamilyID != uuid.Nil {
+		status.FamilyRevoked, _ = r.IsFamilyRevoked(ctx, q.FamilyID)
+	}
+	if q.UserID != uuid.Nil {
+		status.UserRevokedBefore, _ = r.UserRevokedBefore(ctx, q.UserID)
+	}
+	return status, nil
+}
+
+// SaveOpaqueToken implements jwt.OpaqueTokenRepository.
+func (r *Repository) SaveOpaqueToken(_ context.Context, tokenType jwt.TokenType, key, claims string, ttl time.Duration) error {
+	if err := validateTokenType(tokenType); err != nil {
+		return err
+	}
+
+	s := r.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opaque[entryKey{tokenType, key}] = opaqueEntry{claims: claims, expiresAt: r.now().Add(ttl)}
+	return nil
+}
+
+// LoadOpaqueToken implements jwt.OpaqueTokenRepository.
+func (r *Repository) LoadOpaqueToken(_ context.Context, tokenType jwt.TokenType, key string) (string, error) {
+	if err := validateTokenType(tokenType); err != nil {
+		return "", err
+	}
+
+	s := r.shardFor(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.opaque[entryKey{tokenType, key}]
+	if !ok || !r.now().Before(e.expiresAt) {
+		return "", nil
+	}
+	return e.claims, nil
+}
+
+// DeleteOpaqueToken implements jwt.OpaqueTokenRepository.
+func (r *Repository) DeleteOpaqueToken(_ context.Context, tokenType jwt.TokenType, key string) error {
+	s := r.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.opaque, entryKey{tokenType, key})
+	return nil
+}
+
+// CleanupExpired deletes expired revocations and returns how many were removed.
+func (r *Repository) CleanupExpired(_ context.Context) (int64, error) {
+	now := r.now()
+	var n int64
+	for _, s := range r.shards {
+		s.mu.Lock()
+		n += s.deleteExpired(now)
+		for id, cutoff := range s.users {
+			if !now.Before(cutoff.expiresAt) {
+				delete(s.users, id)
+				n++
+			}
+		}
+		for k, e := range s.opaque {
+			if !now.Before(e.expiresAt) {
+				delete(s.opaque, k)
+				n++
+			}
+		}
+		s.mu.Unlock()
+	}
+	return n, nil
+}
+
+// ListRevoked calls fn for every unexpired revocation of tokenType. It
+// implements bloom.Lister.
+func (r *Repository) ListRevoked(_ context.Context, tokenType jwt.TokenType, fn func(token string) error) error {
+	now := r.now()
+	var tokens []string
+	for _, s := range r.shards {
+		s.mu.RLock()
+		for k, exp := range s.entries {
+			if k.tokenType == tokenType && now.Before(exp) {
+				tokens = append(tokens, k.token)
+			}
+		}
+		s.mu.RUnlock()
+	}
+
+	for _, token := range tokens {
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanRevoked calls fn for every unexpired token revocation with its
+// remaining lifetime. It implements migrate.Scanner.
+func (r *Repository) ScanRevoked(_ context.Context, fn func(tokenType jwt.TokenType, token string, ttl time.Duration) error) error {
+	type entry struct {
+		key       entryKey
+		expiresAt time.Time
+	}
+
+	now := r.now()
+	var live []entry
+	for _, s := range r.shards {
+		s.mu.RLock()
+		for k, exp := range s.entries {
+			if k.tokenType != sessionKeyType && k.tokenType != familyKeyType && now.Before(exp) {
+				live = append(live, entry{k, exp})
+			}
+		}
+		s.mu.RUnlock()
+	}
+
+	for _, e := range live {
+		if err := fn(e.key.tokenType, e.key.token, e.expiresAt.Sub(now)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CountRevoked implements jwt.RevocationCounter.
+func (r *Repository) CountRevoked(context.Context) (map[jwt.TokenType]int64, error) {
+	now := r.now()
+	counts := make(map[jwt.TokenType]int64)
+	for _, s := range r.shards {
+		s.mu.RLock()
+		for k, exp := range s.entries {
+			if k.tokenType != sessionKeyType && k.tokenType != familyKeyType && now.Before(exp) {
+				counts[k.tokenType]++
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return counts, nil
+}
+
+// Len returns the number of stored revocations and opaque tokens, including
+// expired ones not yet cleaned up.
+func (r *Repository) Len() int {
+	var n int
+	for _, s := range r.shards {
+		s.mu.RLock()
+		n += len(s.entries) + len(s.users) + len(s.opaque)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+func (r *Repository) shardFor(key string) *shard {
+	if len(r.shards) == 1 {
+		return r.shards[0]
+	}
+	return r.shards[maphash.String(r.seed, key)%uint64(len(r.shards))]
+}
+
+// put stores key, never shortening an existing revocation. s.mu must be held.
+func (s *shard) put(key entryKey, expiresAt, now time.Time) {
+	if existing, ok := s.entries[key]; ok {
+		if expiresAt.After(existing) {
+			s.entries[key] = expiresAt
+		}
+		return
+	}
+
+	if len(s.entries) >= s.maxEntries {
+		s.evict(now)
+	}
+	s.entries[key] = expiresAt
+}
+
+// evict makes room for one entry. s.mu must be held.
+func (s *shard) evict(now time.Time) {
+	if s.deleteExpired(now) > 0 {
+		return
+	}
+
+	var (
+		oldest    entryKey
+		oldestExp time.Time
+	)
+	for k, exp := range s.entries {
+		if oldestExp.IsZero() || exp.Before(oldestExp) {
+			oldest, oldestExp = k, exp
+		}
+	}
+	delete(s.entries, oldest)
+}
+
+// deleteExpired removes entries expired at now. s.mu must be held.
+func (s *shard) deleteExpired(now time.Time) int64 {
+	var n int64
+	for k, exp := range s.entries {
+		if !now.Before(exp) {
+			delete(s.entries, k)
+			n++
+		}
+	}
+	return n
+}
+
+func validateTokenType(tokenType jwt.TokenType) error {
+	switch tokenType {
+	case jwt.AccessToken, jwt.RefreshToken, jwt.ActionToken:
+		return nil
+	default:
+		return fmt.Errorf("invalid token type: %v", tokenType)
+	}
+}
@@ -0,0 +1,17 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/internal/benchmark"
+)
+
+func BenchmarkRepository(b *testing.B) {
+	benchmark.Repository(b, NewRepository(0))
+}
+
+// BenchmarkRepository_SingleShard shows what sharding saves under
+// parallel lookups.
+func BenchmarkRepository_SingleShard(b *testing.B) {
+	benchmark.Repository(b, NewShardedRepository(1, 0))
+}
@@ -0,0 +1,269 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/internal/clocktest"
+)
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	clock := clocktest.New(time.Unix(1_700_000_000, 0))
+	// A single shard keeps eviction order deterministic.
+	r := NewShardedRepository(1, 2, WithClock(clock))
+
+	if err := r.MarkTokenRevoke(ctx, jwt.AccessToken, "a", time.Minute); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if revoked, _ := r.IsTokenRevoked(ctx, jwt.AccessToken, "a"); !revoked {
+		t.Error("expected token to be revoked")
+	}
+	if revoked, _ := r.IsTokenRevoked(ctx, jwt.RefreshToken, "a"); revoked {
+		t.Error("expected revocation to be scoped to the token type")
+	}
+
+	if rotated, _ := r.MarkTokenRotated(ctx, "r", time.Hour); !rotated {
+		t.Error("expected first rotation to succeed")
+	}
+	if rotated, _ := r.MarkTokenRotated(ctx, "r", time.Hour); rotated {
+		t.Error("expected second rotation to fail")
+	}
+
+	// Full: the entry closest to expiry is evicted.
+	if err := r.MarkTokenRevoke(ctx, jwt.AccessToken, "b", time.Hour); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if revoked, _ := r.IsTokenRevoked(ctx, jwt.AccessToken, "a"); revoked {
+		t.Error("expected the soonest-expiring entry to be evicted")
+	}
+	if r.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", r.Len())
+	}
+	counts, err := r.CountRevoked(ctx)
+	if err != nil || counts[jwt.AccessToken] != 1 || counts[jwt.RefreshToken] != 1 {
+		t.Errorf("expected one access and one refresh revocation, got %v, %v", counts, err)
+	}
+
+	clock.Advance(2 * time.Hour)
+	if revoked, _ := r.IsTokenRevoked(ctx, jwt.AccessToken, "b"); revoked {
+		t.Error("expected expired revocation to be ignored")
+	}
+	if n, _ := r.CleanupExpired(ctx); n != 2 {
+		t.Errorf("expected 2 expired entries removed, got %d", n)
+	}
+}
+
+func TestRepository_MarkTokenConsumed(t *testing.T) {
+	ctx := context.Background()
+	r := NewRepository(0)
+
+	if consumed, err := r.MarkTokenConsumed(ctx, jwt.ActionToken, "x", time.Hour); err != nil || !consumed {
+		t.Fatalf("first consumption = %v, %v, want true", consumed, err)
+	}
+	if consumed, _ := r.MarkTokenConsumed(ctx, jwt.ActionToken, "x", time.Hour); consumed {
+		t.Error("expected a second consumption to fail")
+	}
+	if revoked, _ := r.IsTokenRevoked(ctx, jwt.RefreshToken, "x"); revoked {
+		t.Error("expected a consumed action token not to mark the refresh token")
+	}
+	if rotated, _ := r.MarkTokenRotated(ctx, "x", time.Hour); !rotated {
+		t.Error("expected rotation to be independent of action consumption")
+	}
+	if _, err := r.MarkTokenConsumed(ctx, jwt.TokenType("bogus"), "x", time.Hour); err == nil {
+		t.Error("expected an unknown token type to be rejected")
+	}
+}
+
+func TestRepository_RevocationStatus(t *testing.T) {
+	ctx := context.Background()
+	r := NewRepository(0)
+	sessionID, familyID, userID := uuid.New(), uuid.New(), uuid.New()
+	before := time.Unix(1_700_000_000, 0)
+	q := jwt.RevocationQuery{
+		TokenType:   jwt.RefreshToken,
+		Token:       "t",
+		RotationKey: "t:rotated",
+		SessionID:   sessionID,
+		FamilyID:    familyID,
+		UserID:      userID,
+	}
+
+	if status, err := r.RevocationStatus(ctx, q); err != nil || status != (jwt.RevocationStatus{}) {
+		t.Fatalf("expected an empty status, got %+v, %v", status, err)
+	}
+
+	_ = r.MarkTokenRevoke(ctx, jwt.RefreshToken, "t", time.Hour)
+	_ = r.MarkTokenRevoke(ctx, jwt.RefreshToken, "t:rotated", time.Hour)
+	_ = r.MarkSessionRevoked(ctx, sessionID, time.Hour)
+	_ = r.MarkFamilyRevoked(ctx, familyID, time.Hour)
+	_ = r.MarkUserRevoked(ctx, userID, before, time.Hour)
+	want := jwt.RevocationStatus{
+		TokenRevoked:      true,
+		TokenRotated:      true,
+		SessionRevoked:    true,
+		FamilyRevoked:     true,
+		UserRevokedBefore: before,
+	}
+	if status, err := r.RevocationStatus(ctx, q); err != nil || status != want {
+		t.Errorf("status = %+v, %v, want %+v", status, err, want)
+	}
+	if status, _ := r.RevocationStatus(ctx, jwt.RevocationQuery{TokenType: jwt.AccessToken}); status != (jwt.RevocationStatus{}) {
+		t.Errorf("expected an empty query to look nothing up, got %+v", status)
+	}
+}
+
+func TestRepository_OpaqueTokens(t *testing.T) {
+	ctx := context.Background()
+	clock := clocktest.New(time.Unix(1_700_000_000, 0))
+	// Opaque tokens do not count against MaxEntries and are never evicted.
+	r := NewShardedRepository(1, 1, WithClock(clock))
+
+	for _, key := range []string{"a", "b"} {
+		if err := r.SaveOpaqueToken(ctx, jwt.AccessToken, key, "claims-"+key, time.Minute); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	if err := r.MarkTokenRevoke(ctx, jwt.AccessToken, "x", time.Minute); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if got, _ := r.LoadOpaqueToken(ctx, jwt.AccessToken, "a"); got != "claims-a" {
+		t.Errorf("load a = %q, want claims-a", got)
+	}
+	if got, _ := r.LoadOpaqueToken(ctx, jwt.RefreshToken, "a"); got != "" {
+		t.Errorf("expected opaque tokens to be scoped to the token type, got %q", got)
+	}
+
+	if err := r.DeleteOpaqueToken(ctx, jwt.AccessToken, "a"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got, _ := r.LoadOpaqueToken(ctx, jwt.AccessToken, "a"); got != "" {
+		t.Errorf("load after delete = %q", got)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if got, _ := r.LoadOpaqueToken(ctx, jwt.AccessToken, "b"); got != "" {
+		t.Errorf("expected expired opaque token to be ignored, got %q", got)
+	}
+	if n, _ := r.CleanupExpired(ctx); n != 2 {
+		t.Errorf("expected 2 expired entries removed, got %d", n)
+	}
+}
+
+func TestRepository_Sharded(t *testing.T) {
+	ctx := context.Background()
+	r := NewShardedRepository(8, 10_000)
+
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				token := strconv.Itoa(g*100 + i)
+				if err := r.MarkTokenRevoke(ctx, jwt.AccessToken, token, time.Hour); err != nil {
+					t.Errorf("mark: %v", err)
+					return
+				}
+				if revoked, _ := r.IsTokenRevoked(ctx, jwt.AccessToken, token); !revoked {
+					t.Errorf("expected token %s to be revoked", token)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if r.Len() != 800 {
+		t.Errorf("expected 800 entries, got %d", r.Len())
+	}
+}
+
+func TestPersonalAccessTokenStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewPersonalAccessTokenStore()
+	maker, err := jwt.NewTokenMaker(jwt.Config{
+		Secret:                "test-secret-must-be-at-least-32-bytes",
+		Issuer:                "test-issuer",
+		Audience:              "test-audience",
+		AccessExpiryDuration:  time.Hour,
+		RefreshExpiryDuration: 24 * time.Hour,
+	}, nil, jwt.WithPersonalAccessTokens(s))
+	if err != nil {
+		t.Fatalf("create token maker: %v", err)
+	}
+
+	userID := uuid.New()
+	secret, pat, err := maker.CreatePersonalAccessToken(ctx, jwt.PersonalAccessTokenRequest{UserID: userID, Name: "ci", Scopes: []string{"repo:read"}})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := maker.VerifyAccessToken(ctx, secret); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if list, _ := maker.ListPersonalAccessTokens(ctx, userID); len(list) != 1 || list[0].ID != pat.ID {
+		t.Errorf("list = %+v", list)
+	}
+
+	if ok, _ := s.DeletePersonalAccessToken(ctx, uuid.New(), pat.ID); ok {
+		t.Error("deleted another user's token")
+	}
+	if err := maker.RevokePersonalAccessToken(ctx, userID, pat.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if got, _ := s.LoadPersonalAccessToken(ctx, jwt.HashToken(secret)); got != nil {
+		t.Errorf("load after revoke = %+v", got)
+	}
+	if len(s.users) != 0 {
+		t.Errorf("user index not cleaned up: %v", s.users)
+	}
+}
+
+func TestPairingStore(t *testing.T) {
+	ctx := context.Background()
+	clock := clocktest.New(time.Unix(1700000000, 0))
+	s := NewPairingStore(WithClock(clock))
+
+	id := uuid.New()
+	if err := s.SavePairing(ctx, id, time.Minute); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := s.TakePairing(ctx, id); !errors.Is(err, jwt.ErrPairingPending) {
+		t.Errorf("take pending: err = %v, want ErrPairingPending", err)
+	}
+	userID := uuid.New()
+	if err := s.ClaimPairing(ctx, id, jwt.PairingClaim{UserID: userID}); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if err := s.ClaimPairing(ctx, id, jwt.PairingClaim{UserID: uuid.New()}); !errors.Is(err, jwt.ErrPairingClaimed) {
+		t.Errorf("second claim: err = %v, want ErrPairingClaimed", err)
+	}
+	claim, err := s.TakePairing(ctx, id)
+	if err != nil || claim.UserID != userID {
+		t.Fatalf("take = %+v, %v", claim, err)
+	}
+	if _, err := s.TakePairing(ctx, id); !errors.Is(err, jwt.ErrPairingNotFound) {
+		t.Errorf("take twice: err = %v, want ErrPairingNotFound", err)
+	}
+
+	expired := uuid.New()
+	if err := s.SavePairing(ctx, expired, time.Minute); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	clock.Advance(time.Minute)
+	if err := s.ClaimPairing(ctx, expired, jwt.PairingClaim{UserID: userID}); !errors.Is(err, jwt.ErrPairingNotFound) {
+		t.Errorf("claim expired: err = %v, want ErrPairingNotFound", err)
+	}
+	if err := s.SavePairing(ctx, uuid.New(), time.Minute); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, ok := s.pairings[expired]; ok {
+		t.Error("expired pairing not dropped")
+	}
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+type pairingRecord struct {
+	claim     *jwt.PairingClaim
+	expiresAt time.Time
+}
+
+// PairingStore is an in-memory jwt.PairingStore. It is safe for concurrent
+// use. Expired pairings are dropped when the next one is saved.
+type PairingStore struct {
+	mu       sync.Mutex
+	pairings map[uuid.UUID]pairingRecord
+	now      func() time.Time
+}
+
+// NewPairingStore returns an empty pairing store.
+func NewPairingStore(opts ...Option) *PairingStore {
+	return &PairingStore{
+		pairings: make(map[uuid.UUID]pairingRecord),
+		now:      resolve(opts).clock.Now,
+	}
+}
+
+// SavePairing implements jwt.PairingStore.
+func (s *PairingStore) SavePairing(_ context.Context, id uuid.UUID, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for other, rec := range s.pairings {
+		if !now.Before(rec.expiresAt) {
+			delete(s.pairings, other)
+		}
+	}
+	s.pairings[id] = pairingRecord{expiresAt: now.Add(ttl)}
+	return nil
+}
+
+// ClaimPairing implements jwt.PairingStore.
+func (s *PairingStore) ClaimPairing(_ context.Context, id uuid.UUID, claim jwt.PairingClaim) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.lookup(id)
+	if !ok {
+		return jwt.ErrPairingNotFound
+	}
+	if rec.claim != nil {
+		return jwt.ErrPairingClaimed
+	}
+	claim.Roles = slices.Clone(claim.Roles)
+	rec.claim = &claim
+	s.pairings[id] = rec
+	return nil
+}
+
+// TakePairing implements jwt.PairingStore.
+func (s *PairingStore) TakePairing(_ context.Context, id uuid.UUID) (*jwt.PairingClaim, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.lookup(id)
+	if !ok {
+		return nil, jwt.ErrPairingNotFound
+	}
+	if rec.claim == nil {
+		return nil, jwt.ErrPairingPending
+	}
+	delete(s.pairings, id)
+	return rec.claim, nil
+}
+
+// lookup returns the unexpired pairing id. s.mu must be held.
+func (s *PairingStore) lookup(id uuid.UUID) (pairingRecord, bool) {
+	rec, ok := s.pairings[id]
+	if !ok || !s.now().Before(rec.expiresAt) {
+		return pairingRecord{}, false
+	}
+	return rec, true
+}
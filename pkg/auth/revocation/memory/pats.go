@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// PersonalAccessTokenStore is an in-memory jwt.PersonalAccessTokenStore. It
+// is safe for concurrent use. Tokens are kept until deleted.
+type PersonalAccessTokenStore struct {
+	mu    sync.Mutex
+	byKey map[string]jwt.PersonalAccessToken
+	// users maps a user and token ID to the token's key.
+	users map[uuid.UUID]map[uuid.UUID]string
+}
+
+// NewPersonalAccessTokenStore returns an empty store.
+func NewPersonalAccessTokenStore() *PersonalAccessTokenStore {
+	return &PersonalAccessTokenStore{
+		byKey: make(map[string]jwt.PersonalAccessToken),
+		users: make(map[uuid.UUID]map[uuid.UUID]string),
+	}
+}
+
+// SavePersonalAccessToken implements jwt.PersonalAccessTokenStore.
+func (s *PersonalAccessTokenStore) SavePersonalAccessToken(_ context.Context, key string, pat jwt.PersonalAccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, ok := s.users[pat.UserID]
+	if !ok {
+		tokens = make(map[uuid.UUID]string)
+		s.users[pat.UserID] = tokens
+	}
+	tokens[pat.ID] = key
+	s.byKey[key] = pat
+	return nil
+}
+
+// LoadPersonalAccessToken implements jwt.PersonalAccessTokenStore.
+func (s *PersonalAccessTokenStore) LoadPersonalAccessToken(_ context.Context, key string) (*jwt.PersonalAccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pat, ok := s.byKey[key]
+	if !ok {
+		return nil, nil
+	}
+	pat.Scopes = slices.Clone(pat.Scopes)
+	pat.Roles = slices.Clone(pat.Roles)
+	return &pat, nil
+}
+
+// ListPersonalAccessTokens implements jwt.PersonalAccessTokenStore.
+func (s *PersonalAccessTokenStore) ListPersonalAccessTokens(_ context.Context, userID uuid.UUID) ([]jwt.PersonalAccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []jwt.PersonalAccessToken
+	for _, key := range s.users[userID] {
+		out = append(out, s.byKey[key])
+	}
+	return out, nil
+}
+
+// DeletePersonalAccessToken implements jwt.PersonalAccessTokenStore.
+func (s *PersonalAccessTokenStore) DeletePersonalAccessToken(_ context.Context, userID, id uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.users[userID][id]
+	if !ok {
+		return false, nil
+	}
+	delete(s.byKey, key)
+	delete(s.users[userID], id)
+	if len(s.users[userID]) == 0 {
+		delete(s.users, userID)
+	}
+	return true, nil
+}
@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+type sessionRecord struct {
+	info      jwt.SessionInfo
+	expiresAt time.Time
+}
+
+// SessionStore is an in-memory jwt.SessionStore. It is safe for concurrent
+// use. Expired sessions are dropped when their user's sessions are listed.
+type SessionStore struct {
+	mu    sync.Mutex
+	users map[uuid.UUID]map[uuid.UUID]sessionRecord
+	now   func() time.Time
+}
+
+// NewSessionStore returns an empty session store.
+func NewSessionStore(opts ...Option) *SessionStore {
+	return &SessionStore{
+		users: make(map[uuid.UUID]map[uuid.UUID]sessionRecord),
+		now:   resolve(opts).clock.Now,
+	}
+}
+
+// SaveSession implements jwt.SessionStore.
+func (s *SessionStore) SaveSession(_ context.Context, info jwt.SessionInfo, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions, ok := s.users[info.UserID]
+	if !ok {
+		sessions = make(map[uuid.UUID]sessionRecord)
+		s.users[info.UserID] = sessions
+	}
+	sessions[info.ID] = sessionRecord{info: info, expiresAt: s.now().Add(ttl)}
+	return nil
+}
+
+// TouchSession implements jwt.SessionStore.
+func (s *SessionStore) TouchSession(_ context.Context, userID, sessionID uuid.UUID, seenAt time.Time, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.users[userID][sessionID]
+	if !ok {
+		return nil
+	}
+	rec.info.LastSeenAt = seenAt
+	rec.expiresAt = s.now().Add(ttl)
+	s.users[userID][sessionID] = rec
+	return nil
+}
+
+// ListSessions implements jwt.SessionStore.
+func (s *SessionStore) ListSessions(_ context.Context, userID uuid.UUID) ([]jwt.SessionInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var out []jwt.SessionInfo
+	for id, rec := range s.users[userID] {
+		if !now.Before(rec.expiresAt) {
+			delete(s.users[userID], id)
+			continue
+		}
+		out = append(out, rec.info)
+	}
+	if len(s.users[userID]) == 0 {
+		delete(s.users, userID)
+	}
+	return out, nil
+}
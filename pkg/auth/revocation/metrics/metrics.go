@@ -0,0 +1,306 @@
+// Package metrics instruments a jwt.RevocationRepository with operation
+// counts, latencies and error rates, so storage problems surface on
+// dashboards before they become auth outages.
+//
+// Every call is recorded in the revocation_repository_* Prometheus metrics,
+// labeled by backend name and operation, and optionally reported to an
+// Observer for other telemetry systems.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+const metricNamespace = "revocation_repository"
+
+// Operation names used as the "operation" label.
+const (
+	OpMarkRevoked      = "mark_revoked"
+	OpIsRevoked        = "is_revoked"
+	OpMarkRotated      = "mark_rotated"
+	OpMarkConsumed     = "mark_consumed"
+	OpMarkRevokedBatch = "mark_revoked_batch"
+	OpAreRevokedBatch  = "are_revoked_batch"
+
+	OpMarkSessionRevoked = "mark_session_revoked"
+	OpIsSessionRevoked   = "is_session_revoked"
+	OpMarkFamilyRevoked  = "mark_family_revoked"
+	OpIsFamilyRevoked    = "is_family_revoked"
+	OpMarkUserRevoked    = "mark_user_revoked"
+	OpUserRevokedBefore  = "user_revoked_before"
+	OpRevocationStatus   = "revocation_status"
+)
+
+const (
+	statusOK        = "ok"
+	statusError     = "error"
+	statusCancelled = "cancelled"
+)
+
+var (
+	operationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "operations_total",
+			Help:      "Total number of revocation repository operations.",
+		},
+		[]string{"backend", "operation", "status"},
+	)
+
+	operationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Revocation repository operation duration in seconds.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		[]string{"backend", "operation"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(operationsTotal, operationDuration)
+}
+
+// Observer receives every instrumented call. err is the operation's error,
+// nil on success.
+type Observer func(ctx context.Context, backend, operation string, tokenType jwt.TokenType, d time.Duration, err error)
+
+// Option configures a Repository.
+type Option func(*Repository)
+
+// WithObserver reports every call to o in addition to Prometheus.
+func WithObserver(o Observer) Option {
+	return func(r *Repository) { r.observer = o }
+}
+
+// WithSlowOperationWarnings reports calls that take longer than threshold
+// to h, or logs them if h is nil; see jwt.ReportSlow.
+func WithSlowOperationWarnings(threshold time.Duration, h jwt.SlowOperationHandler) Option {
+	return func(r *Repository) { r.slowThreshold, r.slowHandler = threshold, h }
+}
+
+// Repository is an instrumented jwt.RevocationRepository.
+type Repository struct {
+	repo          jwt.RevocationRepository
+	backend       string
+	observer      Observer
+	slowThreshold time.Duration
+	slowHandler   jwt.SlowOperationHandler
+}
+
+// NewRepository instruments repo, labeling its metrics with backend
+// (e.g. "redis").
+func NewRepository(repo jwt.RevocationRepository, backend string, opts ...Option) *Repository {
+	r := &Repository{
+		repo:    repo,
+		backend: backend,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *Repository) MarkTokenRevoke(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) error {
+	start := time.Now()
+	err := r.repo.MarkTokenRevoke(ctx, tokenType, token, ttl)
+	r.record(ctx, OpMarkRevoked, tokenType, start, err)
+	return err
+}
+
+func (r *Repository) IsTokenRevoked(ctx context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	start := time.Now()
+	revoked, err := r.repo.IsTokenRevoked(ctx, tokenType, token)
+	r.record(ctx, OpIsRevoked, tokenType, start, err)
+	return revoked, err
+}
+
+// MarkTokenRotated delegates to the wrapped repository, atomically when it
+// implements jwt.AtomicRotationRepository. Otherwise it falls back to a lookup
+// followed by a revoke, as the TokenMaker itself would.
+func (r *Repository) MarkTokenRotated(ctx context.Context, token string, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	var (
+		rotated bool
+		err     error
+	)
+	if atomic, ok := r.repo.(jwt.AtomicRotationRepository); ok {
+		rotated, err = atomic.MarkTokenRotated(ctx, token, ttl)
+	} else {
+		var revoked bool
+		if revoked, err = r.repo.IsTokenRevoked(ctx, jwt.RefreshToken, token); err == nil && !revoked {
+			err = r.repo.MarkTokenRevoke(ctx, jwt.RefreshToken, token, ttl)
+		}
+		rotated = err == nil && !revoked
+	}
+	r.record(ctx, OpMarkRotated, jwt.RefreshToken, start, err)
+	return rotated, err
+}
+
+// MarkTokenConsumed delegates to the wrapped repository; see
+// jwt.MarkTokenConsumed.
+func (r *Repository) MarkTokenConsumed(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	consumed, err := jwt.MarkTokenConsumed(ctx, r.repo, tokenType, token, ttl)
+	r.record(ctx, OpMarkConsumed, tokenType, start, err)
+	return consumed, err
+}
+
+// MarkTokensRevoked implements jwt.BatchRevocationRepository, falling back to
+// single writes when the wrapped repository has no batch support.
+func (r *Repository) MarkTokensRevoked(ctx context.Context, tokenType jwt.TokenType, tokens []string, ttl time.Duration) error {
+	start := time.Now()
+	err := jwt.MarkTokensRevoked(ctx, r.repo, tokenType, tokens, ttl)
+	r.record(ctx, OpMarkRevokedBatch, tokenType, start, err)
+	return err
+}
+
+// AreTokensRevoked implements jwt.BatchRevocationRepository, falling back to
+// single lookups when the wrapped repository has no batch support.
+func (r *Repository) AreTokensRevoked(ctx context.Context, tokenType jwt.TokenType, tokens []string) ([]bool, error) {
+	start := time.Now()
+	revoked, err := jwt.AreTokensRevoked(ctx, r.repo, tokenType, tokens)
+	r.record(ctx, OpAreRevokedBatch, tokenType, start, err)
+	return revoked, err
+}
+
+// MarkSessionRevoked forwards to the wrapped repository. Unsupported calls are
+// not recorded.
+func (r *Repository) MarkSessionRevoked(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error {
+	sessions, ok := r.repo.(jwt.SessionRevocationRepository)
+	if !ok {
+		return fmt.Errorf("mark session revoked: %w", errors.ErrUnsupported)
+	}
+	start := time.Now()
+	err := sessions.MarkSessionRevoked(ctx, sessionID, ttl)
+	r.record(ctx, OpMarkSessionRevoked, "", start, err)
+	return err
+}
+
+// IsSessionRevoked forwards to the wrapped repository. Unsupported calls are
+// not recorded.
+func (r *Repository) IsSessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	sessions, ok := r.repo.(jwt.SessionRevocationRepository)
+	if !ok {
+		return false, fmt.Errorf("check session revocation: %w", errors.ErrUnsupported)
+	}
+	start := time.Now()
+	revoked, err := sessions.IsSessionRevoked(ctx, sessionID)
+	r.record(ctx, OpIsSessionRevoked, "", start, err)
+	return revoked, err
+}
+
+// MarkFamilyRevoked forwards to the wrapped repository. Unsupported calls are
+// not recorded.
+func (r *Repository) MarkFamilyRevoked(ctx context.Context, familyID uuid.UUID, ttl time.Duration) error {
+	families, ok := r.repo.(jwt.FamilyRevocationRepository)
+	if !ok {
+		return fmt.Errorf("mark family revoked: %w", errors.ErrUnsupported)
+	}
+	start := time.Now()
+	err := families.MarkFamilyRevoked(ctx, familyID, ttl)
+	r.record(ctx, OpMarkFamilyRevoked, "", start, err)
+	return err
+}
+
+// IsFamilyRevoked forwards to the wrapped repository. Unsupported calls are
+// not recorded.
+func (r *Repository) IsFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error) {
+	families, ok := r.repo.(jwt.FamilyRevocationRepository)
+	if !ok {
+		return false, fmt.Errorf("check family revocation: %w", errors.ErrUnsupported)
+	}
+	start := time.Now()
+	revoked, err := families.IsFamilyRevoked(ctx, familyID)
+	r.record(ctx, OpIsFamilyRevoked, "", start, err)
+	return revoked, err
+}
+
+// MarkUserRevoked forwards to the wrapped repository. Unsupported calls are
+// not recorded.
+func (r *Repository) MarkUserRevoked(ctx context.Context, userID uuid.UUID, before time.Time, ttl time.Duration) error {
+	users, ok := r.repo.(jwt.UserRevocationRepository)
+	if !ok {
+		return fmt.Errorf("mark user revoked: %w", errors.ErrUnsupported)
+	}
+	start := time.Now()
+	err := users.MarkUserRevoked(ctx, userID, before, ttl)
+	r.record(ctx, OpMarkUserRevoked, "", start, err)
+	return err
+}
+
+// UserRevokedBefore forwards to the wrapped repository. Unsupported calls are
+// not recorded.
+func (r *Repository) UserRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	users, ok := r.repo.(jwt.UserRevocationRepository)
+	if !ok {
+		return time.Time{}, fmt.Errorf("check user revocation: %w", errors.ErrUnsupported)
+	}
+	start := time.Now()
+	before, err := users.UserRevokedBefore(ctx, userID)
+	r.record(ctx, OpUserRevokedBefore, "", start, err)
+	return before, err
+}
+
+// RevocationStatus forwards to the wrapped repository. Unsupported calls are
+// not recorded.
+func (r *Repository) RevocationStatus(ctx context.Context, q jwt.RevocationQuery) (jwt.RevocationStatus, error) {
+	statuses, ok := r.repo.(jwt.RevocationStatusRepository)
+	if !ok {
+		return jwt.RevocationStatus{}, fmt.Errorf("check revocation status: %w", errors.ErrUnsupported)
+	}
+	start := time.Now()
+	status, err := statuses.RevocationStatus(ctx, q)
+	r.record(ctx, OpRevocationStatus, q.TokenType, start, err)
+	return status, err
+}
+
+// CleanupExpired implements jwt.Cleaner by cleaning up the wrapped repository.
+func (r *Repository) CleanupExpired(ctx context.Context) (int64, error) {
+	return jwt.CleanupExpired(ctx, r.repo)
+}
+
+// Ping implements jwt.Pinger by pinging the wrapped repository.
+func (r *Repository) Ping(ctx context.Context) error {
+	return jwt.Ping(ctx, r.repo)
+}
+
+// CountRevoked implements jwt.RevocationCounter by counting the wrapped
+// repository.
+func (r *Repository) CountRevoked(ctx context.Context) (map[jwt.TokenType]int64, error) {
+	return jwt.CountRevoked(ctx, r.repo)
+}
+
+func (r *Repository) record(ctx context.Context, op string, tokenType jwt.TokenType, start time.Time, err error) {
+	d := time.Since(start)
+	status := statusOK
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		// The caller gave up; not a storage fault.
+		status = statusCancelled
+	default:
+		status = statusError
+	}
+
+	operationsTotal.WithLabelValues(r.backend, op, status).Inc()
+	operationDuration.WithLabelValues(r.backend, op).Observe(d.Seconds())
+	if r.observer != nil {
+		r.observer(ctx, r.backend, op, tokenType, d, err)
+	}
+	jwt.ReportSlow(ctx, r.slowHandler, jwt.SlowOperation{
+		Operation: op,
+		Backend:   r.backend,
+		TokenType: tokenType,
+		Duration:  d,
+		Threshold: r.slowThreshold,
+	})
+}
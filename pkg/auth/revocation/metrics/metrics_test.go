@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/memory"
+)
+
+// failingRepo fails every call.
+type failingRepo struct{}
+
+func (failingRepo) MarkTokenRevoke(context.Context, jwt.TokenType, string, time.Duration) error {
+	return errors.New("unavailable")
+}
+
+func (failingRepo) IsTokenRevoked(context.Context, jwt.TokenType, string) (bool, error) {
+	return false, errors.New("unavailable")
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	var ops []string
+	observer := func(_ context.Context, _, op string, _ jwt.TokenType, _ time.Duration, err error) {
+		if err != nil {
+			op += ":error"
+		}
+		ops = append(ops, op)
+	}
+
+	r := NewRepository(memory.NewRepository(0), "memory_test", WithObserver(observer))
+	if err := r.MarkTokenRevoke(ctx, jwt.AccessToken, "a", time.Minute); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if revoked, _ := r.IsTokenRevoked(ctx, jwt.AccessToken, "a"); !revoked {
+		t.Error("expected token to be revoked")
+	}
+	if rotated, _ := r.MarkTokenRotated(ctx, "r", time.Minute); !rotated {
+		t.Error("expected rotation to succeed")
+	}
+
+	failing := NewRepository(failingRepo{}, "failing_test", WithObserver(observer))
+	if rotated, err := failing.MarkTokenRotated(ctx, "r", time.Minute); err == nil || rotated {
+		t.Errorf("expected rotation to fail, got %v, %v", rotated, err)
+	}
+
+	want := []string{OpMarkRevoked, OpIsRevoked, OpMarkRotated, OpMarkRotated + ":error"}
+	if len(ops) != len(want) {
+		t.Fatalf("expected observed ops %v, got %v", want, ops)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Errorf("expected observed ops %v, got %v", want, ops)
+			break
+		}
+	}
+
+	if got := testutil.ToFloat64(operationsTotal.WithLabelValues("failing_test", OpMarkRotated, statusError)); got != 1 {
+		t.Errorf("expected 1 failed rotation counted, got %v", got)
+	}
+}
+
+func TestWithSlowOperationWarnings(t *testing.T) {
+	var slow []jwt.SlowOperation
+	r := NewRepository(memory.NewRepository(0), "slow_test", WithSlowOperationWarnings(time.Nanosecond, func(_ context.Context, op jwt.SlowOperation) {
+		slow = append(slow, op)
+	}))
+	if _, err := r.IsTokenRevoked(context.Background(), jwt.AccessToken, "a"); err != nil {
+		t.Fatalf("is revoked: %v", err)
+	}
+	if len(slow) != 1 || slow[0].Operation != OpIsRevoked || slow[0].Backend != "slow_test" {
+		t.Errorf("expected one slow %s on slow_test, got %+v", OpIsRevoked, slow)
+	}
+}
@@ -0,0 +1,89 @@
+// Package migrate copies live revocations between jwt.RevocationRepository
+// backends, e.g. from memory to Redis or from Redis to Postgres, keeping each
+// entry's remaining lifetime.
+//
+// For a zero-downtime move, first switch writes to both stores (for example
+// with pkg/auth/revocation/failover, new store as primary), then run
+// MigrateRepository, then drop the old store. Revocations made during the
+// copy are thereby never lost. Rotated refresh tokens are ordinary refresh
+// revocations and are copied as such; session and user revocations are not
+// copied.
+//
+// Export and Import write and read the same entries as JSON lines, for backups
+// that let revocation state survive the loss of a store.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// Scanner enumerates unexpired token revocations with their remaining
+// lifetime. The memory, bolt and Redis repositories implement it.
+type Scanner interface {
+	ScanRevoked(ctx context.Context, fn func(tokenType jwt.TokenType, token string, ttl time.Duration) error) error
+}
+
+// Options configures MigrateRepository.
+type Options struct {
+	// MinTTL skips entries expiring sooner than this; zero copies every
+	// unexpired entry.
+	MinTTL time.Duration
+	// DryRun counts the entries that would be copied without writing them.
+	DryRun bool
+	// Progress, if set, is called after every entry with the running totals.
+	Progress func(stats Stats)
+	// Clock supplies the current time snapshots are exported and imported
+	// at; nil means the system clock.
+	Clock jwt.Clock
+}
+
+func (o Options) now() time.Time {
+	if o.Clock == nil {
+		return time.Now()
+	}
+	return o.Clock.Now()
+}
+
+// Stats reports the outcome of a migration.
+type Stats struct {
+	Copied  int64
+	Skipped int64
+}
+
+// MigrateRepository copies every live revocation of src into dst. It stops at
+// the first write error, returning the totals so far; the copy is idempotent,
+// so it can simply be run again.
+func MigrateRepository(ctx context.Context, src Scanner, dst jwt.RevocationRepository, opts Options) (Stats, error) {
+	var stats Stats
+	if src == nil || dst == nil {
+		return stats, fmt.Errorf("source and destination repositories are required")
+	}
+
+	err := src.ScanRevoked(ctx, func(tokenType jwt.TokenType, token string, ttl time.Duration) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if ttl <= 0 || ttl < opts.MinTTL {
+			stats.Skipped++
+		} else {
+			if !opts.DryRun {
+				if err := dst.MarkTokenRevoke(ctx, tokenType, token, ttl); err != nil {
+					return fmt.Errorf("copy %s revocation: %w", tokenType, err)
+				}
+			}
+			stats.Copied++
+		}
+		if opts.Progress != nil {
+			opts.Progress(stats)
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("migrate revocations: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,95 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/internal/clocktest"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/memory"
+)
+
+func TestMigrateRepository(t *testing.T) {
+	ctx := context.Background()
+	src := memory.NewRepository(0)
+	dst := memory.NewRepository(0)
+
+	if err := src.MarkTokenRevoke(ctx, jwt.AccessToken, "a", time.Hour); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if _, err := src.MarkTokenRotated(ctx, "r", time.Hour); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if err := src.MarkTokenRevoke(ctx, jwt.AccessToken, "short", time.Second); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+
+	stats, err := MigrateRepository(ctx, src, dst, Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if stats.Copied != 3 || dst.Len() != 0 {
+		t.Errorf("expected dry run to count 3 entries and write none, got %+v, %d written", stats, dst.Len())
+	}
+
+	stats, err = MigrateRepository(ctx, src, dst, Options{MinTTL: time.Minute})
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if stats.Copied != 2 || stats.Skipped != 1 {
+		t.Errorf("expected 2 copied and 1 skipped, got %+v", stats)
+	}
+	if revoked, _ := dst.IsTokenRevoked(ctx, jwt.AccessToken, "a"); !revoked {
+		t.Error("expected revocation to be copied")
+	}
+	if rotated, _ := dst.MarkTokenRotated(ctx, "r", time.Hour); rotated {
+		t.Error("expected rotation to be copied")
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	clock := clocktest.New(time.Unix(1_700_000_000, 0))
+	src := memory.NewRepository(0, memory.WithClock(clock))
+	if err := src.MarkTokenRevoke(ctx, jwt.AccessToken, "a", time.Hour); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if err := src.MarkTokenRevoke(ctx, jwt.RefreshToken, "r", time.Hour); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+
+	var buf bytes.Buffer
+	n, err := Export(ctx, src, &buf, Options{Clock: clock})
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 records exported, got %d, %v", n, err)
+	}
+	if want := `"expires_at":1700003600000`; !strings.Contains(buf.String(), want) {
+		t.Errorf("expected the snapshot to contain %s, got %s", want, buf.String())
+	}
+	buf.WriteString(`{"type":"access","token":"expired","expires_at":1}` + "\n")
+
+	// Entries are restored for what is left of their lifetime.
+	clock.Advance(30 * time.Minute)
+	dst := memory.NewRepository(0, memory.WithClock(clock))
+	stats, err := Import(ctx, &buf, dst, Options{Clock: clock})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if stats.Copied != 2 || stats.Skipped != 1 {
+		t.Errorf("expected 2 imported and 1 skipped, got %+v", stats)
+	}
+	if revoked, _ := dst.IsTokenRevoked(ctx, jwt.RefreshToken, "r"); !revoked {
+		t.Error("expected revocation to be restored")
+	}
+	clock.Advance(30 * time.Minute)
+	if revoked, _ := dst.IsTokenRevoked(ctx, jwt.RefreshToken, "r"); revoked {
+		t.Error("expected the restored revocation to expire with the original")
+	}
+
+	if _, err := Import(ctx, strings.NewReader("not json\n"), dst, Options{}); err == nil {
+		t.Error("expected malformed snapshot to fail")
+	}
+}
@@ -0,0 +1,98 @@
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// maxSnapshotLine bounds a single snapshot record; raw tokens stay well below.
+const maxSnapshotLine = 64 << 10
+
+// Record is one line of a snapshot. Token is the repository key, which is the
+// token's SHA-256 hash unless jwt.Config.StoreRawTokens is set.
+type Record struct {
+	Type      jwt.TokenType `json:"type"`
+	Token     string        `json:"token"`
+	ExpiresAt int64         `json:"expires_at"` // Unix milliseconds
+}
+
+// Export writes every live revocation of src to w as JSON lines, so that
+// revocation state can be backed up and restored with Import. It returns the
+// number of records written. Only the Clock of opts is used.
+func Export(ctx context.Context, src Scanner, w io.Writer, opts Options) (int64, error) {
+	if src == nil {
+		return 0, fmt.Errorf("source repository is required")
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	var n int64
+	now := opts.now()
+	err := src.ScanRevoked(ctx, func(tokenType jwt.TokenType, token string, ttl time.Duration) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := enc.Encode(Record{Type: tokenType, Token: token, ExpiresAt: now.Add(ttl).UnixMilli()}); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		return n, fmt.Errorf("export revocations: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return n, fmt.Errorf("export revocations: %w", err)
+	}
+	return n, nil
+}
+
+// Import reads a snapshot written by Export and revokes every entry that has
+// not expired since, for its remaining lifetime. Options apply as in
+// MigrateRepository.
+func Import(ctx context.Context, r io.Reader, dst jwt.RevocationRepository, opts Options) (Stats, error) {
+	var stats Stats
+	if dst == nil {
+		return stats, fmt.Errorf("destination repository is required")
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 4096), maxSnapshotLine)
+	for line := 1; sc.Scan(); line++ {
+		if err := ctx.Err(); err != nil {
+			return stats, fmt.Errorf("import revocations: %w", err)
+		}
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return stats, fmt.Errorf("import revocations: line %d: %w", line, err)
+		}
+		ttl := time.UnixMilli(rec.ExpiresAt).Sub(opts.now())
+		if ttl <= 0 || ttl < opts.MinTTL {
+			stats.Skipped++
+		} else {
+			if !opts.DryRun {
+				if err := dst.MarkTokenRevoke(ctx, rec.Type, rec.Token, ttl); err != nil {
+					return stats, fmt.Errorf("import revocations: line %d: %w", line, err)
+				}
+			}
+			stats.Copied++
+		}
+		if opts.Progress != nil {
+			opts.Progress(stats)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return stats, fmt.Errorf("import revocations: %w", err)
+	}
+	return stats, nil
+}
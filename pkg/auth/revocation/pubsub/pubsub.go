@@ -0,0 +1,106 @@
+// Package pubsub broadcasts revocations over a Redis pub/sub channel so that
+// every instance running pkg/auth/revocation/cached evicts its stale "not
+// revoked" answers within milliseconds instead of waiting out the cache TTL.
+//
+// Messages carry the token type and the repository key, which is the token's
+// SHA-256 hash unless jwt.Config.StoreRawTokens is set. Pub/sub is fire and
+// forget: messages published while a subscriber is disconnected are lost, and
+// that instance falls back to TTL-bounded staleness until it reconnects.
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// DefaultChannel is used when an empty channel name is given.
+const DefaultChannel = "revocation:invalidate"
+
+// Evicter drops cached answers for a token. *cached.Repository implements it.
+type Evicter interface {
+	Evict(tokenType jwt.TokenType, token string)
+}
+
+// Publisher publishes revocations. It implements cached.Notifier.
+type Publisher struct {
+	client  redis.Cmdable
+	channel string
+}
+
+// NewPublisher returns a Publisher writing to channel.
+func NewPublisher(client redis.Cmdable, channel string) (*Publisher, error) {
+	if client == nil {
+		return nil, fmt.Errorf("redis client cannot be nil")
+	}
+	if channel == "" {
+		channel = DefaultChannel
+	}
+
+	return &Publisher{client: client, channel: channel}, nil
+}
+
+// NotifyRevoked publishes a revocation of token.
+func (p *Publisher) NotifyRevoked(ctx context.Context, tokenType jwt.TokenType, token string) error {
+	if err := p.client.Publish(ctx, p.channel, encode(tokenType, token)).Err(); err != nil {
+		return fmt.Errorf("publish revocation: %w", err)
+	}
+	return nil
+}
+
+// Subscribe evicts every token published on channel from e until ctx is done.
+// It returns an error only if the initial subscription fails; go-redis
+// resubscribes on its own after connection loss.
+func Subscribe(ctx context.Context, client redis.UniversalClient, channel string, e Evicter) error {
+	if client == nil || e == nil {
+		return fmt.Errorf("redis client and evicter are required")
+	}
+	if channel == "" {
+		channel = DefaultChannel
+	}
+
+	sub := client.Subscribe(ctx, channel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("subscribe %s: %w", channel, err)
+	}
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			tokenType, token, ok := decode(msg.Payload)
+			if !ok {
+				logx.WithContext(ctx).Errorf("pubsub: malformed revocation message on %s", channel)
+				continue
+			}
+			e.Evict(tokenType, token)
+		}
+	}
+}
+
+func encode(tokenType jwt.TokenType, token string) string {
+	return string(tokenType) + ":" + token
+}
+
+func decode(payload string) (jwt.TokenType, string, bool) {
+	tokenType, token, ok := strings.Cut(payload, ":")
+	if !ok || token == "" {
+		return "", "", false
+	}
+	switch jwt.TokenType(tokenType) {
+	case jwt.AccessToken, jwt.RefreshToken, jwt.ActionToken:
+		return jwt.TokenType(tokenType), token, true
+	default:
+		return "", "", false
+	}
+}
@@ -0,0 +1,296 @@
+package pubsub
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// server is an in-process Redis speaking the RESP2 subset pub/sub needs:
+// PUBLISH, SUBSCRIBE, UNSUBSCRIBE and PING. Everything else, including the
+// HELLO handshake, gets an error reply, which go-redis tolerates.
+type server struct {
+	ln         net.Listener
+	subscribed chan string
+
+	mu    sync.Mutex
+	conns []net.Conn
+	subs  map[string][]*serverConn
+}
+
+type serverConn struct {
+	mu   sync.Mutex
+	w    *bufio.Writer
+	subs int
+}
+
+func (c *serverConn) write(reply string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.w.WriteString(reply); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+func newServer(t *testing.T) *server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &server{ln: ln, subscribed: make(chan string, 8), subs: make(map[string][]*serverConn)}
+	go s.serve()
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *server) client(t *testing.T) *redis.Client {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: s.ln.Addr().String(), Protocol: 2, DisableIdentity: true, MaxRetries: -1})
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func (s *server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *server) close() {
+	_ = s.ln.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+func (s *server) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	c := &serverConn{w: bufio.NewWriter(conn)}
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if err := s.command(c, args); err != nil {
+			return
+		}
+	}
+}
+
+func (s *server) command(c *serverConn, args []string) error {
+	switch strings.ToUpper(args[0]) {
+	case "PUBLISH":
+		if len(args) != 3 {
+			break
+		}
+		s.mu.Lock()
+		subs := append([]*serverConn(nil), s.subs[args[1]]...)
+		s.mu.Unlock()
+		for _, sub := range subs {
+			_ = sub.write(array("message", args[1], args[2]))
+		}
+		return c.write(":" + strconv.Itoa(len(subs)) + "\r\n")
+
+	case "SUBSCRIBE":
+		for _, channel := range args[1:] {
+			s.mu.Lock()
+			s.subs[channel] = append(s.subs[channel], c)
+			s.mu.Unlock()
+			c.subs++
+			if err := c.write(fmt.Sprintf("*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:%d\r\n", len(channel), channel, c.subs)); err != nil {
+				return err
+			}
+			s.subscribed <- channel
+		}
+		return nil
+
+	case "UNSUBSCRIBE":
+		s.mu.Lock()
+		for channel, subs := range s.subs {
+			for i, sub := range subs {
+				if sub == c {
+					s.subs[channel] = append(subs[:i], subs[i+1:]...)
+					break
+				}
+			}
+		}
+		s.mu.Unlock()
+		return nil
+
+	case "PING":
+		if c.subs > 0 {
+			return c.write(array("pong", ""))
+		}
+		return c.write("+PONG\r\n")
+	}
+	return c.write("-ERR unknown command '" + args[0] + "'\r\n")
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("malformed command %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("malformed argument %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func array(items ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(items))
+	for _, item := range items {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(item), item)
+	}
+	return b.String()
+}
+
+type eviction struct {
+	tokenType jwt.TokenType
+	token     string
+}
+
+type evicter chan eviction
+
+func (e evicter) Evict(tokenType jwt.TokenType, token string) {
+	e <- eviction{tokenType, token}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	s := newServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	evicted := make(evicter, 8)
+	done := make(chan error, 1)
+	go func() { done <- Subscribe(ctx, s.client(t), "", evicted) }()
+
+	select {
+	case channel := <-s.subscribed:
+		if channel != DefaultChannel {
+			t.Fatalf("subscribed to %q, want %q", channel, DefaultChannel)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the subscription")
+	}
+
+	client := s.client(t)
+	pub, err := NewPublisher(client, "")
+	if err != nil {
+		t.Fatalf("new publisher: %v", err)
+	}
+	// Malformed messages are skipped without ending the subscription.
+	for _, payload := range []string{"no-separator", "bogus:hash", "access:"} {
+		if err := client.Publish(ctx, DefaultChannel, payload).Err(); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+	for _, want := range []eviction{{jwt.AccessToken, "a:b"}, {jwt.ActionToken, "c"}} {
+		if err := pub.NotifyRevoked(ctx, want.tokenType, want.token); err != nil {
+			t.Fatalf("notify: %v", err)
+		}
+		select {
+		case got := <-evicted:
+			if got != want {
+				t.Errorf("evicted %+v, want %+v", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for the eviction of %+v", want)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("subscribe returned %v after cancellation", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscribe did not return after cancellation")
+	}
+}
+
+func TestSubscribe_Errors(t *testing.T) {
+	ctx := context.Background()
+	s := newServer(t)
+	client := s.client(t)
+
+	if err := Subscribe(ctx, nil, "", make(evicter)); err == nil {
+		t.Error("expected a nil client to be rejected")
+	}
+	if err := Subscribe(ctx, client, "", nil); err == nil {
+		t.Error("expected a nil evicter to be rejected")
+	}
+
+	s.close()
+	if err := Subscribe(ctx, client, "", make(evicter)); err == nil {
+		t.Error("expected a failed subscription to be returned")
+	}
+	pub, err := NewPublisher(client, "custom")
+	if err != nil {
+		t.Fatalf("new publisher: %v", err)
+	}
+	if err := pub.NotifyRevoked(ctx, jwt.AccessToken, "a"); err == nil {
+		t.Error("expected a failed publish to be returned")
+	}
+	if _, err := NewPublisher(nil, ""); err == nil {
+		t.Error("expected a nil client to be rejected")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for payload, want := range map[string]eviction{
+		encode(jwt.AccessToken, "a"):   {jwt.AccessToken, "a"},
+		encode(jwt.RefreshToken, "r"):  {jwt.RefreshToken, "r"},
+		encode(jwt.ActionToken, "x:y"): {jwt.ActionToken, "x:y"},
+	} {
+		tokenType, token, ok := decode(payload)
+		if !ok || (eviction{tokenType, token}) != want {
+			t.Errorf("decode(%q) = %v, %q, %v, want %+v", payload, tokenType, token, ok, want)
+		}
+	}
+	for _, payload := range []string{"", "access", "access:", "bogus:a", ":a"} {
+		if _, _, ok := decode(payload); ok {
+			t.Errorf("expected decode(%q) to fail", payload)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Package retry wraps a jwt.RevocationRepository with jittered exponential
+// backoff, so a single network blip to Redis or SQL does not fail user
+// verification.
+//
+// Only transient errors are retried (see IsTransient). MarkTokenRotated is not
+// idempotent — a lost reply to a successful rotation would make the retry
+// report the token as already rotated — so it is retried only on errors that
+// guarantee the write never reached the store (see IsNotApplied).
+package retry
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/cenkalti/backoff/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// Defaults keep the worst case well under typical RPC deadlines; verification
+// sits on every authenticated request.
+const (
+	DefaultMaxTries        = 3
+	DefaultInitialInterval = 20 * time.Millisecond
+	DefaultMaxInterval     = 200 * time.Millisecond
+)
+
+// Config configures retries. Zero values use the defaults.
+type Config struct {
+	MaxTries        uint
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	// Retryable classifies errors; defaults to IsTransient.
+	Retryable func(error) bool
+}
+
+// Repository is a jwt.RevocationRepository that retries transient failures.
+type Repository struct {
+	repo jwt.RevocationRepository
+	cfg  Config
+}
+
+// NewRepository wraps repo with retries configured by cfg.
+func NewRepository(repo jwt.RevocationRepository, cfg Config) *Repository {
+	if cfg.MaxTries == 0 {
+		cfg.MaxTries = DefaultMaxTries
+	}
+	if cfg.InitialInterval <= 0 {
+		cfg.InitialInterval = DefaultInitialInterval
+	}
+	if cfg.MaxInterval <= 0 {
+		cfg.MaxInterval = DefaultMaxInterval
+	}
+	if cfg.Retryable == nil {
+		cfg.Retryable = IsTransient
+	}
+
+	return &Repository{repo: repo, cfg: cfg}
+}
+
+func (r *Repository) MarkTokenRevoke(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) error {
+	_, err := do(ctx, r, r.cfg.Retryable, func() (struct{}, error) {
+		return struct{}{}, r.repo.MarkTokenRevoke(ctx, tokenType, token, ttl)
+	})
+	return err
+}
+
+func (r *Repository) IsTokenRevoked(ctx context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	return do(ctx, r, r.cfg.Retryable, func() (bool, error) {
+		return r.repo.IsTokenRevoked(ctx, tokenType, token)
+	})
+}
+
+// MarkTokenRotated delegates to the wrapped repository, atomically when it
+// implements jwt.AtomicRotationRepository. Otherwise it falls back to a lookup
+// followed by a revoke, as the TokenMaker itself would.
+func (r *Repository) MarkTokenRotated(ctx context.Context, token string, ttl time.Duration) (bool, error) {
+	atomic, ok := r.repo.(jwt.AtomicRotationRepository)
+	if !ok {
+		revoked, err := r.IsTokenRevoked(ctx, jwt.RefreshToken, token)
+		if err != nil || revoked {
+			return false, err
+		}
+		return true, r.MarkTokenRevoke(ctx, jwt.RefreshToken, token, ttl)
+	}
+
+	return do(ctx, r, IsNotApplied, func() (bool, error) {
+		return atomic.MarkTokenRotated(ctx, token, ttl)
+	})
+}
+
+// MarkTokenConsumed delegates to the wrapped repository, atomically when it
+// implements jwt.AtomicConsumeRepository, retrying only like
+// MarkTokenRotated. Otherwise it falls back to a lookup followed by a
+// revoke.
+func (r *Repository) MarkTokenConsumed(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	atomic, ok := r.repo.(jwt.AtomicConsumeRepository)
+	if !ok {
+		return jwt.MarkTokenConsumed(ctx, r, tokenType, token, ttl)
+	}
+
+	return do(ctx, r, IsNotApplied, func() (bool, error) {
+		return atomic.MarkTokenConsumed(ctx, tokenType, token, ttl)
+	})
+}
+
+// MarkSessionRevoked forwards to the wrapped repository with retries.
+func (r *Repository) MarkSessionRevoked(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error {
+	sessions, ok := r.repo.(jwt.SessionRevocationRepository)
+	if !ok {
+		return fmt.Errorf("mark session revoked: %w", errors.ErrUnsupported)
+	}
+	_, err := do(ctx, r, r.cfg.Retryable, func() (struct{}, error) {
+		return struct{}{}, sessions.MarkSessionRevoked(ctx, sessionID, ttl)
+	})
+	return err
+}
+
+// IsSessionRevoked forwards to the wrapped repository with retries.
+func (r *Repository) IsSessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	sessions, ok := r.repo.(jwt.SessionRevocationRepository)
+	if !ok {
+		return false, fmt.Errorf("check session revocation: %w", errors.ErrUnsupported)
+	}
+	return do(ctx, r, r.cfg.Retryable, func() (bool, error) {
+		return sessions.IsSessionRevoked(ctx, sessionID)
+	})
+}
+
+// MarkFamilyRevoked forwards to the wrapped repository with retries.
+func (r *Repository) MarkFamilyRevoked(ctx context.Context, familyID uuid.UUID, ttl time.Duration) error {
+	families, ok := r.repo.(jwt.FamilyRevocationRepository)
+	if !ok {
+		return fmt.Errorf("mark family revoked: %w", errors.ErrUnsupported)
+	}
+	_, err := do(ctx, r, r.cfg.Retryable, func() (struct{}, error) {
+		return struct{}{}, families.MarkFamilyRevoked(ctx, familyID, ttl)
+	})
+	return err
+}
+
+// IsFamilyRevoked forwards to the wrapped repository with retries.
+func (r *Repository) IsFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error) {
+	families, ok := r.repo.(jwt.FamilyRevocationRepository)
+	if !ok {
+		return false, fmt.Errorf("check family revocation: %w", errors.ErrUnsupported)
+	}
+	return do(ctx, r, r.cfg.Retryable, func() (bool, error) {
+		return families.IsFamilyRevoked(ctx, familyID)
+	})
+}
+
+// MarkUserRevoked forwards to the wrapped repository with retries.
+func (r *Repository) MarkUserRevoked(ctx context.Context, userID uuid.UUID, before time.Time, ttl time.Duration) error {
+	users, ok := r.repo.(jwt.UserRevocationRepository)
+	if !ok {
+		return fmt.Errorf("mark user revoked: %w", errors.ErrUnsupported)
+	}
+	_, err := do(ctx, r, r.cfg.Retryable, func() (struct{}, error) {
+		return struct{}{}, users.MarkUserRevoked(ctx, userID, before, ttl)
+	})
+	return err
+}
+
+// UserRevokedBefore forwards to the wrapped repository with retries.
+func (r *Repository) UserRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	users, ok := r.repo.(jwt.UserRevocationRepository)
+	if !ok {
+		return time.Time{}, fmt.Errorf("check user revocation: %w", errors.ErrUnsupported)
+	}
+	return do(ctx, r, r.cfg.Retryable, func() (time.Time, error) {
+		return users.UserRevokedBefore(ctx, userID)
+	})
+}
+
+// RevocationStatus forwards to the wrapped repository with retries.
+func (r *Repository) RevocationStatus(ctx context.Context, q jwt.RevocationQuery) (jwt.RevocationStatus, error) {
+	statuses, ok := r.repo.(jwt.RevocationStatusRepository)
+	if !ok {
+		return jwt.RevocationStatus{}, fmt.Errorf("check revocation status: %w", errors.ErrUnsupported)
+	}
+	return do(ctx, r, r.cfg.Retryable, func() (jwt.RevocationStatus, error) {
+		return statuses.RevocationStatus(ctx, q)
+	})
+}
+
+// CleanupExpired implements jwt.Cleaner by cleaning up the wrapped repository.
+func (r *Repository) CleanupExpired(ctx context.Context) (int64, error) {
+	return jwt.CleanupExpired(ctx, r.repo)
+}
+
+// Ping implements jwt.Pinger by pinging the wrapped repository.
+func (r *Repository) Ping(ctx context.Context) error {
+	return jwt.Ping(ctx, r.repo)
+}
+
+// CountRevoked implements jwt.RevocationCounter by counting the wrapped
+// repository.
+func (r *Repository) CountRevoked(ctx context.Context) (map[jwt.TokenType]int64, error) {
+	return jwt.CountRevoked(ctx, r.repo)
+}
+
+func do[T any](ctx context.Context, r *Repository, retryable func(error) bool, fn func() (T, error)) (T, error) {
+	eb := backoff.NewExponentialBackOff()
+	eb.InitialInterval = r.cfg.InitialInterval
+	eb.MaxInterval = r.cfg.MaxInterval
+
+	return backoff.Retry(ctx, func() (T, error) {
+		res, err := fn()
+		if err != nil && !retryable(err) {
+			return res, backoff.Permanent(err)
+		}
+		return res, err
+	},
+		backoff.WithBackOff(eb),
+		backoff.WithMaxTries(r.cfg.MaxTries),
+		backoff.WithMaxElapsedTime(0),
+	)
+}
+
+// IsTransient reports whether err is a connection-level or server-busy
+// failure worth retrying. Context errors and application errors (such as an
+// invalid token type) are not.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if IsNotApplied(err) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	for _, prefix := range []string{"LOADING", "READONLY", "TRYAGAIN", "CLUSTERDOWN", "MASTERDOWN"} {
+		if redis.HasErrorPrefix(err, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNotApplied reports whether err guarantees the operation never reached the
+// store, making even non-idempotent operations safe to retry.
+func IsNotApplied(err error) bool {
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.ECONNREFUSED)
+}
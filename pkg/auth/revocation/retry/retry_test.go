@@ -0,0 +1,90 @@
+package retry
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// flakyRepo fails the first failures calls with err.
+type flakyRepo struct {
+	err      error
+	failures int
+	calls    int
+}
+
+func (r *flakyRepo) fail() error {
+	r.calls++
+	if r.calls <= r.failures {
+		return r.err
+	}
+	return nil
+}
+
+func (r *flakyRepo) MarkTokenRevoke(context.Context, jwt.TokenType, string, time.Duration) error {
+	return r.fail()
+}
+
+func (r *flakyRepo) IsTokenRevoked(context.Context, jwt.TokenType, string) (bool, error) {
+	if err := r.fail(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *flakyRepo) MarkTokenRotated(context.Context, string, time.Duration) (bool, error) {
+	if err := r.fail(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	cfg := Config{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond}
+
+	for _, tc := range []struct {
+		name      string
+		err       error
+		rotate    bool
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "transient recovers", err: io.EOF, wantCalls: 3},
+		{name: "permanent not retried", err: errors.New("invalid token type"), wantCalls: 1, wantErr: true},
+		{name: "rotation not retried after ambiguous failure", err: io.EOF, rotate: true, wantCalls: 1, wantErr: true},
+		{name: "rotation retried when not applied", err: driver.ErrBadConn, rotate: true, wantCalls: 3},
+	} {
+		repo := &flakyRepo{err: tc.err, failures: 2}
+		r := NewRepository(repo, cfg)
+
+		var err error
+		if tc.rotate {
+			_, err = r.MarkTokenRotated(ctx, "t", time.Minute)
+		} else {
+			_, err = r.IsTokenRevoked(ctx, jwt.AccessToken, "t")
+		}
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if repo.calls != tc.wantCalls {
+			t.Errorf("%s: expected %d calls, got %d", tc.name, tc.wantCalls, repo.calls)
+		}
+	}
+}
+
+func TestRepository_GivesUp(t *testing.T) {
+	repo := &flakyRepo{err: io.EOF, failures: 10}
+	r := NewRepository(repo, Config{MaxTries: 2, InitialInterval: time.Millisecond})
+	if err := r.MarkTokenRevoke(context.Background(), jwt.AccessToken, "t", time.Minute); !errors.Is(err, io.EOF) {
+		t.Errorf("expected the last error after giving up, got %v", err)
+	}
+	if repo.calls != 2 {
+		t.Errorf("expected 2 calls, got %d", repo.calls)
+	}
+}
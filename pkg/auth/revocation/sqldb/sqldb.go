@@ -0,0 +1,346 @@
+// Package sqldb implements jwt.RevocationRepository on plain database/sql for
+// Postgres and MySQL, using prepared statements and no ORM.
+//
+// The schema matches the SQLite repository (pkg/auth/revocation/sqlite), so
+// rows can be moved between backends without conversion:
+//
+//	revoked_tokens(token_type, token, expires_at) -- expires_at in Unix milliseconds
+//
+// Register a driver (e.g. github.com/jackc/pgx/v5/stdlib or
+// github.com/go-sql-driver/mysql) in your main package and pass the opened
+// *sql.DB. NewRepository creates the schema if it does not exist; pass
+// WithoutMigration for locked-down databases and apply Schema with your
+// migration tool instead. Expired rows are ignored by reads; call
+// CleanupExpired periodically.
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// Dialect selects the SQL flavor.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+)
+
+// DefaultTable is the table used when WithTable is not given.
+const DefaultTable = "revoked_tokens"
+
+// tableRe restricts table names to plain SQL identifiers, optionally
+// schema-qualified, and identifierRe schema and index names to plain ones,
+// since they are interpolated into statements.
+var (
+	tableRe      = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*\.)?[A-Za-z_][A-Za-z0-9_]*$`)
+	identifierRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Schema templates take the table name and the index name.
+const (
+	postgresSchema = `
+CREATE TABLE IF NOT EXISTS %[1]s (
+	token_type TEXT   NOT NULL,
+	token      TEXT   NOT NULL,
+	expires_at BIGINT NOT NULL,
+	PRIMARY KEY (token_type, token)
+);
+CREATE INDEX IF NOT EXISTS %[2]s ON %[1]s (expires_at);
+`
+	// Tokens are ASCII, so an ascii column keeps the primary key within
+	// InnoDB's index size limit.
+	mysqlSchema = `
+CREATE TABLE IF NOT EXISTS %[1]s (
+	token_type VARCHAR(16)                       NOT NULL,
+	token      VARCHAR(2048) CHARACTER SET ascii NOT NULL,
+	expires_at BIGINT                            NOT NULL,
+	PRIMARY KEY (token_type, token),
+	INDEX %[2]s (expires_at)
+)
+`
+)
+
+type queries struct {
+	schema  string
+	mark    string
+	rotate  string
+	check   string
+	cleanup string
+}
+
+var dialectQueries = map[Dialect]queries{
+	Postgres: {
+		schema: postgresSchema,
+		mark: `INSERT INTO %[1]s (token_type, token, expires_at) VALUES ($1, $2, $3)
+			ON CONFLICT (token_type, token) DO UPDATE SET expires_at = GREATEST(%[1]s.expires_at, EXCLUDED.expires_at)`,
+		// Only claims the row if it is absent or expired; RowsAffected is 0
+		// when another rotation already holds it.
+		rotate: `INSERT INTO %[1]s (token_type, token, expires_at) VALUES ($1, $2, $3)
+			ON CONFLICT (token_type, token) DO UPDATE SET expires_at = EXCLUDED.expires_at
+			WHERE %[1]s.expires_at <= $4`,
+		check:   `SELECT 1 FROM %[1]s WHERE token_type = $1 AND token = $2 AND expires_at > $3`,
+		cleanup: `DELETE FROM %[1]s WHERE expires_at <= $1`,
+	},
+	MySQL: {
+		schema: mysqlSchema,
+		mark: `INSERT INTO %[1]s (token_type, token, expires_at) VALUES (?, ?, ?)
+			ON DUPLICATE KEY UPDATE expires_at = GREATEST(expires_at, VALUES(expires_at))`,
+		// RowsAffected is 1 on insert, 2 on update and 0 when the existing,
+		// unexpired row was left unchanged.
+		rotate: `INSERT INTO %[1]s (token_type, token, expires_at) VALUES (?, ?, ?)
+			ON DUPLICATE KEY UPDATE expires_at = IF(expires_at <= ?, VALUES(expires_at), expires_at)`,
+		check:   `SELECT 1 FROM %[1]s WHERE token_type = ? AND token = ? AND expires_at > ?`,
+		cleanup: `DELETE FROM %[1]s WHERE expires_at <= ?`,
+	},
+}
+
+// Option configures a Repository and its schema.
+type Option func(*options)
+
+type options struct {
+	table   string
+	schema  string
+	index   string
+	migrate bool
+	clock   jwt.Clock
+}
+
+// WithTable stores revocations in table instead of DefaultTable. It may be
+// schema-qualified, e.g. "auth.revoked_tokens".
+func WithTable(table string) Option {
+	return func(o *options) { o.table = table }
+}
+
+// WithSchema places the table in schema, a Postgres schema or a MySQL
+// database, e.g. WithSchema("auth") for "auth.revoked_tokens". It cannot be
+// combined with a schema-qualified WithTable.
+func WithSchema(schema string) Option {
+	return func(o *options) { o.schema = schema }
+}
+
+// WithIndexName names the expiry index used by CleanupExpired instead of
+// "<table>_expires_at_idx".
+func WithIndexName(index string) Option {
+	return func(o *options) { o.index = index }
+}
+
+// WithClock takes the current time from c rather than the system clock, for
+// expiry that follows a TokenMaker's clock or a fake clock in tests.
+func WithClock(c jwt.Clock) Option {
+	return func(o *options) { o.clock = c }
+}
+
+// WithoutMigration skips schema creation, for locked-down databases whose
+// schema is applied with a migration tool; see Schema.
+func WithoutMigration() Option {
+	return func(o *options) { o.migrate = false }
+}
+
+func resolve(dialect Dialect, opts []Option) (queries, options, error) {
+	q, ok := dialectQueries[dialect]
+	if !ok {
+		return queries{}, options{}, fmt.Errorf("unsupported sql dialect: %q", dialect)
+	}
+	o := options{table: DefaultTable, migrate: true, clock: jwt.SystemClock{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !tableRe.MatchString(o.table) {
+		return queries{}, options{}, fmt.Errorf("invalid sql table name: %q", o.table)
+	}
+	if o.schema != "" {
+		if !identifierRe.MatchString(o.schema) {
+			return queries{}, options{}, fmt.Errorf("invalid sql schema name: %q", o.schema)
+		}
+		if strings.Contains(o.table, ".") {
+			return queries{}, options{}, fmt.Errorf("sql table %q is already schema-qualified", o.table)
+		}
+		o.table = o.schema + "." + o.table
+	}
+	if o.index == "" {
+		o.index = o.table[strings.LastIndex(o.table, ".")+1:] + "_expires_at_idx"
+	}
+	if !identifierRe.MatchString(o.index) {
+		return queries{}, options{}, fmt.Errorf("invalid sql index name: %q", o.index)
+	}
+	return q, o, nil
+}
+
+// Schema returns the DDL creating the revocation table for dialect. Only
+// WithTable, WithSchema and WithIndexName are relevant among opts.
+func Schema(dialect Dialect, opts ...Option) (string, error) {
+	q, o, err := resolve(dialect, opts)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(q.schema, o.table, o.index), nil
+}
+
+// CreateSchema creates the revocation table if it does not exist.
+func CreateSchema(ctx context.Context, db *sql.DB, dialect Dialect, opts ...Option) error {
+	schema, err := Schema(dialect, opts...)
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create schema: %w", err)
+	}
+	return nil
+}
+
+// Repository is a database/sql-backed jwt.RevocationRepository.
+type Repository struct {
+	db      *sql.DB
+	dialect Dialect
+	clock   jwt.Clock
+	mark    *sql.Stmt
+	rotate  *sql.Stmt
+	check   *sql.Stmt
+	cleanup *sql.Stmt
+}
+
+// NewRepository creates the schema if needed and prepares all statements.
+// With WithoutMigration the table must already exist; see Schema. The caller
+// owns db; call Close to release the statements.
+func NewRepository(ctx context.Context, db *sql.DB, dialect Dialect, opts ...Option) (*Repository, error) {
+	if db == nil {
+		return nil, fmt.Errorf("sql db cannot be nil")
+	}
+	q, o, err := resolve(dialect, opts)
+	if err != nil {
+		return nil, err
+	}
+	if o.migrate {
+		if err := CreateSchema(ctx, db, dialect, opts...); err != nil {
+			return nil, err
+		}
+	}
+
+	r := &Repository{db: db, dialect: dialect, clock: o.clock}
+	for _, p := range []struct {
+		dst   **sql.Stmt
+		query string
+	}{
+		{&r.mark, q.mark},
+		{&r.rotate, q.rotate},
+		{&r.check, q.check},
+		{&r.cleanup, q.cleanup},
+	} {
+		stmt, err := db.PrepareContext(ctx, fmt.Sprintf(p.query, o.table))
+		if err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("prepare statement: %w", err)
+		}
+		*p.dst = stmt
+	}
+
+	return r, nil
+}
+
+// Close releases the prepared statements. It does not close the database.
+func (r *Repository) Close() error {
+	var firstErr error
+	for _, stmt := range []*sql.Stmt{r.mark, r.rotate, r.check, r.cleanup} {
+		if stmt == nil {
+			continue
+		}
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (r *Repository) MarkTokenRevoke(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) error {
+	if err := validateTokenType(tokenType); err != nil {
+		return err
+	}
+
+	if _, err := r.mark.ExecContext(ctx, string(tokenType), token, r.clock.Now().Add(ttl).UnixMilli()); err != nil {
+		return fmt.Errorf("mark token revoked: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) IsTokenRevoked(ctx context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	if err := validateTokenType(tokenType); err != nil {
+		return false, err
+	}
+
+	var one int
+	err := r.check.QueryRowContext(ctx, string(tokenType), token, r.clock.Now().UnixMilli()).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("check revocation: %w", err)
+	}
+	return true, nil
+}
+
+// MarkTokenRotated atomically revokes a refresh token if it is not revoked yet.
+func (r *Repository) MarkTokenRotated(ctx context.Context, token string, ttl time.Duration) (bool, error) {
+	rotated, err := r.markOnce(ctx, jwt.RefreshToken, token, ttl)
+	if err != nil {
+		return false, fmt.Errorf("mark token rotated: %w", err)
+	}
+	return rotated, nil
+}
+
+// MarkTokenConsumed implements jwt.AtomicConsumeRepository.
+func (r *Repository) MarkTokenConsumed(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	if err := validateTokenType(tokenType); err != nil {
+		return false, err
+	}
+	consumed, err := r.markOnce(ctx, tokenType, token, ttl)
+	if err != nil {
+		return false, fmt.Errorf("mark token consumed: %w", err)
+	}
+	return consumed, nil
+}
+
+// markOnce claims the row of token unless an unexpired one exists and
+// reports whether it did.
+func (r *Repository) markOnce(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	now := r.clock.Now()
+	res, err := r.rotate.ExecContext(ctx, string(tokenType), token, now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// CleanupExpired deletes expired revocations and returns how many were removed.
+func (r *Repository) CleanupExpired(ctx context.Context) (int64, error) {
+	res, err := r.cleanup.ExecContext(ctx, r.clock.Now().UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("cleanup expired revocations: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Ping implements jwt.Pinger.
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+func validateTokenType(tokenType jwt.TokenType) error {
+	switch tokenType {
+	case jwt.AccessToken, jwt.RefreshToken, jwt.ActionToken:
+		return nil
+	default:
+		return fmt.Errorf("invalid token type: %v", tokenType)
+	}
+}
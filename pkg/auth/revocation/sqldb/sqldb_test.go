@@ -0,0 +1,221 @@
+package sqldb
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/internal/clocktest"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/internal/sqlfake"
+)
+
+func TestSchema(t *testing.T) {
+	schema, err := Schema(Postgres, WithTable("auth.revocations"))
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	for _, want := range []string{
+		"CREATE TABLE IF NOT EXISTS auth.revocations (",
+		"CREATE INDEX IF NOT EXISTS revocations_expires_at_idx ON auth.revocations (expires_at)",
+	} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("expected schema to contain %q, got:\n%s", want, schema)
+		}
+	}
+
+	schema, err = Schema(MySQL)
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	if !strings.Contains(schema, "INDEX revoked_tokens_expires_at_idx (expires_at)") {
+		t.Errorf("expected default table index, got:\n%s", schema)
+	}
+
+	schema, err = Schema(Postgres, WithSchema("auth"), WithIndexName("revocations_expiry"))
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	for _, want := range []string{
+		"CREATE TABLE IF NOT EXISTS auth.revoked_tokens (",
+		"CREATE INDEX IF NOT EXISTS revocations_expiry ON auth.revoked_tokens (expires_at)",
+	} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("expected schema to contain %q, got:\n%s", want, schema)
+		}
+	}
+	schema, err = Schema(MySQL, WithSchema("auth"), WithIndexName("revocations_expiry"))
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	if !strings.Contains(schema, "CREATE TABLE IF NOT EXISTS auth.revoked_tokens (") || !strings.Contains(schema, "INDEX revocations_expiry (expires_at)") {
+		t.Errorf("expected the schema and index options in the MySQL schema, got:\n%s", schema)
+	}
+
+	for _, table := range []string{"", "revoked tokens", "a.b.c", "x; DROP TABLE users"} {
+		if _, err := Schema(Postgres, WithTable(table)); err == nil {
+			t.Errorf("expected table name %q to be rejected", table)
+		}
+	}
+	for name, opts := range map[string][]Option{
+		"invalid schema":         {WithSchema("auth; DROP")},
+		"schema and qualified":   {WithSchema("auth"), WithTable("other.revocations")},
+		"invalid index":          {WithIndexName("idx; DROP")},
+		"schema-qualified index": {WithIndexName("auth.idx")},
+	} {
+		if _, err := Schema(Postgres, opts...); err == nil {
+			t.Errorf("%s: expected the options to be rejected", name)
+		}
+	}
+	if _, err := Schema("oracle"); err == nil {
+		t.Error("expected unsupported dialect to be rejected")
+	}
+}
+
+func newTestRepository(t *testing.T, dialect Dialect, d *sqlfake.Driver, opts ...Option) *Repository {
+	t.Helper()
+	db := sqlfake.Open(d)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := CreateSchema(context.Background(), db, dialect, opts...); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	repo, err := NewRepository(context.Background(), db, dialect, opts...)
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	t.Cleanup(func() { _ = repo.Close() })
+	return repo
+}
+
+func TestRepository_Dialects(t *testing.T) {
+	for _, tc := range []struct {
+		dialect Dialect
+		opts    []sqlfake.Option
+		// statements are fragments of the statements the dialect must send.
+		statements []string
+	}{
+		{
+			dialect: Postgres,
+			statements: []string{
+				"INSERT INTO revoked_tokens (token_type, token, expires_at) VALUES ($1, $2, $3) ON CONFLICT (token_type, token) DO UPDATE SET expires_at = GREATEST(revoked_tokens.expires_at, EXCLUDED.expires_at)",
+				"ON CONFLICT (token_type, token) DO UPDATE SET expires_at = EXCLUDED.expires_at WHERE revoked_tokens.expires_at <= $4",
+				"SELECT 1 FROM revoked_tokens WHERE token_type = $1 AND token = $2 AND expires_at > $3",
+				"DELETE FROM revoked_tokens WHERE expires_at <= $1",
+			},
+		},
+		{
+			dialect: MySQL,
+			opts:    []sqlfake.Option{sqlfake.WithMySQLRowsAffected()},
+			statements: []string{
+				"INSERT INTO revoked_tokens (token_type, token, expires_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE expires_at = GREATEST(expires_at, VALUES(expires_at))",
+				"ON DUPLICATE KEY UPDATE expires_at = IF(expires_at <= ?, VALUES(expires_at), expires_at)",
+				"SELECT 1 FROM revoked_tokens WHERE token_type = ? AND token = ? AND expires_at > ?",
+				"DELETE FROM revoked_tokens WHERE expires_at <= ?",
+			},
+		},
+	} {
+		t.Run(string(tc.dialect), func(t *testing.T) {
+			ctx := context.Background()
+			d := sqlfake.New(tc.opts...)
+			clock := clocktest.New(time.Unix(1_700_000_000, 0))
+			repo := newTestRepository(t, tc.dialect, d, WithClock(clock))
+
+			if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, "a", time.Hour); err != nil {
+				t.Fatalf("mark: %v", err)
+			}
+			if revoked, err := repo.IsTokenRevoked(ctx, jwt.AccessToken, "a"); err != nil || !revoked {
+				t.Errorf("expected token to be revoked, got %v, %v", revoked, err)
+			}
+			if revoked, _ := repo.IsTokenRevoked(ctx, jwt.RefreshToken, "a"); revoked {
+				t.Error("expected revocation to be scoped to the token type")
+			}
+
+			if rotated, err := repo.MarkTokenRotated(ctx, "r", time.Hour); err != nil || !rotated {
+				t.Fatalf("first rotation = %v, %v, want true", rotated, err)
+			}
+			if rotated, _ := repo.MarkTokenRotated(ctx, "r", time.Hour); rotated {
+				t.Error("expected second rotation to fail")
+			}
+			// An expired row is reclaimed, which MySQL reports as 2 rows.
+			if _, err := repo.MarkTokenRotated(ctx, "old", time.Minute); err != nil {
+				t.Fatalf("rotate: %v", err)
+			}
+			clock.Advance(time.Minute)
+			if rotated, _ := repo.MarkTokenRotated(ctx, "old", time.Hour); !rotated {
+				t.Error("expected an expired row to be reclaimed")
+			}
+			if consumed, _ := repo.MarkTokenConsumed(ctx, jwt.ActionToken, "r", time.Hour); !consumed {
+				t.Error("expected consumption to be independent of rotation")
+			}
+
+			if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, "expired", time.Minute); err != nil {
+				t.Fatalf("mark: %v", err)
+			}
+			clock.Advance(time.Minute)
+			if revoked, _ := repo.IsTokenRevoked(ctx, jwt.AccessToken, "expired"); revoked {
+				t.Error("expected an expired revocation to be ignored")
+			}
+			if n, err := repo.CleanupExpired(ctx); err != nil || n != 1 {
+				t.Errorf("cleanup = %d, %v, want 1", n, err)
+			}
+
+			sent := strings.Join(d.Statements(), "\n")
+			for _, want := range tc.statements {
+				if !strings.Contains(sent, want) {
+					t.Errorf("expected a statement containing %q, got:\n%s", want, sent)
+				}
+			}
+		})
+	}
+}
+
+func TestNewRepository_WithoutMigration(t *testing.T) {
+	ctx := context.Background()
+	d := sqlfake.New()
+	db := sqlfake.Open(d)
+	defer db.Close()
+
+	repo, err := NewRepository(ctx, db, Postgres, WithoutMigration())
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	defer repo.Close()
+	if d.HasTable(DefaultTable) {
+		t.Error("expected WithoutMigration to skip schema creation")
+	}
+	if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, "a", time.Hour); err == nil {
+		t.Error("expected statements to fail without the table")
+	}
+	if _, err := NewRepository(ctx, nil, Postgres); err == nil {
+		t.Error("expected a nil db to be rejected")
+	}
+	if _, err := NewRepository(ctx, db, "oracle"); err == nil {
+		t.Error("expected an unsupported dialect to be rejected")
+	}
+}
+
+func TestNewRepository_Migrates(t *testing.T) {
+	ctx := context.Background()
+	d := sqlfake.New()
+	db := sqlfake.Open(d)
+	defer db.Close()
+
+	repo, err := NewRepository(ctx, db, Postgres, WithSchema("auth"), WithTable("revocations"), WithIndexName("revocations_expiry"))
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	defer repo.Close()
+	if !d.HasTable("auth.revocations") {
+		t.Fatal("expected NewRepository to create the table by default")
+	}
+	if got := d.Indexes(); len(got) != 1 || got[0] != "revocations_expiry" {
+		t.Errorf("expected the revocations_expiry index, got %v", got)
+	}
+	if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, "a", time.Hour); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.AccessToken, "a"); !revoked {
+		t.Error("expected token to be revoked in the qualified table")
+	}
+}
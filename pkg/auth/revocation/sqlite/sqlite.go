@@ -0,0 +1,322 @@
+// Package sqlite implements jwt.RevocationRepository on SQLite for
+// single-binary deployments and CI environments where Redis and Postgres are
+// unavailable.
+//
+// The package only depends on database/sql. Register a pure-Go driver such as
+// modernc.org/sqlite in your main package and open the database with Open,
+// which configures every pooled connection:
+//
+//	import _ "modernc.org/sqlite"
+//
+//	db, err := sqlite.Open("sqlite", "file:revocations.db")
+//	repo, err := sqlite.NewRepository(ctx, db)
+//
+// NewRepository creates the schema if it does not exist; pass
+// WithoutMigration when the schema is managed elsewhere and apply Schema
+// with your migration tool instead. WithTable and WithIndexName change the
+// table and index names. Expired rows are ignored by reads; call CleanupExpired
+// periodically to reclaim space.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// DefaultTable is the table used when WithTable is not given.
+const DefaultTable = "revoked_tokens"
+
+// identifierRe restricts table and index names to plain SQL identifiers,
+// since they are interpolated into statements.
+var identifierRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+const schemaTmpl = `
+CREATE TABLE IF NOT EXISTS %[1]s (
+	token_type TEXT    NOT NULL,
+	token      TEXT    NOT NULL,
+	expires_at INTEGER NOT NULL,
+	PRIMARY KEY (token_type, token)
+);
+CREATE INDEX IF NOT EXISTS %[2]s ON %[1]s (expires_at);
+`
+
+// pragmas are run by Open on every new connection. WAL lets readers proceed
+// while a revocation is being written; the busy timeout makes concurrent
+// writers wait instead of failing with SQLITE_BUSY. Both are per-connection
+// settings, so running them once through a pool only configures one of its
+// connections.
+var pragmas = []string{
+	"PRAGMA journal_mode=WAL",
+	"PRAGMA busy_timeout=5000",
+}
+
+// Open opens dsn with the registered driver driverName and enables WAL mode
+// and a 5s busy timeout on every connection the pool opens. Drivers that accept pragmas in the DSN
+// can be opened with sql.Open instead, e.g. for modernc.org/sqlite:
+//
+//	sql.Open("sqlite", "file:revocations.db?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+func Open(driverName, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	if err := db.Close(); err != nil {
+		return nil, err
+	}
+
+	var base driver.Connector = dsnConnector{dsn: dsn, drv: drv}
+	if dc, ok := drv.(driver.DriverContext); ok {
+		if base, err = dc.OpenConnector(dsn); err != nil {
+			return nil, fmt.Errorf("sqlite open connector: %w", err)
+		}
+	}
+	return sql.OpenDB(pragmaConnector{base}), nil
+}
+
+// dsnConnector adapts a driver without driver.DriverContext.
+type dsnConnector struct {
+	dsn string
+	drv driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.drv.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.drv
+}
+
+// pragmaConnector runs pragmas on every connection it opens.
+type pragmaConnector struct {
+	driver.Connector
+}
+
+func (c pragmaConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, pragma := range pragmas {
+		if err := execConn(ctx, conn, pragma); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("sqlite %s: %w", pragma, err)
+		}
+	}
+	return conn, nil
+}
+
+func execConn(ctx context.Context, conn driver.Conn, query string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, query, nil)
+		if !errors.Is(err, driver.ErrSkip) {
+			return err
+		}
+	}
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(nil)
+	return err
+}
+
+// Option configures a Repository and its schema.
+type Option func(*options)
+
+type options struct {
+	table   string
+	index   string
+	migrate bool
+	clock   jwt.Clock
+}
+
+// WithTable stores revocations in table instead of DefaultTable.
+func WithTable(table string) Option {
+	return func(o *options) { o.table = table }
+}
+
+// WithIndexName names the expiry index used by CleanupExpired instead of
+// "<table>_expires_at_idx".
+func WithIndexName(index string) Option {
+	return func(o *options) { o.index = index }
+}
+
+// WithClock reads the current time from c instead of the system clock, so
+// the repository can share a TokenMaker's clock or a fake one in tests.
+func WithClock(c jwt.Clock) Option {
+	return func(o *options) { o.clock = c }
+}
+
+// WithoutMigration skips schema creation, for databases whose schema is
+// applied with a migration tool; see Schema.
+func WithoutMigration() Option {
+	return func(o *options) { o.migrate = false }
+}
+
+func resolve(opts []Option) (options, error) {
+	o := options{table: DefaultTable, migrate: true, clock: jwt.SystemClock{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !identifierRe.MatchString(o.table) {
+		return options{}, fmt.Errorf("invalid sqlite table name: %q", o.table)
+	}
+	if o.index == "" {
+		o.index = o.table + "_expires_at_idx"
+	}
+	if !identifierRe.MatchString(o.index) {
+		return options{}, fmt.Errorf("invalid sqlite index name: %q", o.index)
+	}
+	return o, nil
+}
+
+// Schema returns the DDL NewRepository runs to create the revocation table.
+// Only WithTable and WithIndexName are relevant among opts.
+func Schema(opts ...Option) (string, error) {
+	o, err := resolve(opts)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(schemaTmpl, o.table, o.index), nil
+}
+
+// Repository is a SQLite-backed jwt.RevocationRepository. Expiry timestamps
+// are stored as Unix milliseconds.
+type Repository struct {
+	db           *sql.DB
+	clock        jwt.Clock
+	markQuery    string
+	rotateQuery  string
+	checkQuery   string
+	cleanupQuery string
+}
+
+// NewRepository creates the schema if needed and returns the repository. The
+// caller owns db and must close it; see Open for the connection settings.
+func NewRepository(ctx context.Context, db *sql.DB, opts ...Option) (*Repository, error) {
+	if db == nil {
+		return nil, fmt.Errorf("sqlite db cannot be nil")
+	}
+
+	o, err := resolve(opts)
+	if err != nil {
+		return nil, err
+	}
+	if o.migrate {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(schemaTmpl, o.table, o.index)); err != nil {
+			return nil, fmt.Errorf("sqlite create schema: %w", err)
+		}
+	}
+
+	return &Repository{
+		db:    db,
+		clock: o.clock,
+		markQuery: `INSERT INTO ` + o.table + ` (token_type, token, expires_at) VALUES (?, ?, ?)
+			ON CONFLICT (token_type, token) DO UPDATE SET expires_at = MAX(expires_at, excluded.expires_at)`,
+		// Only claims the row if it is absent or expired; RowsAffected is 0
+		// when another caller already holds it.
+		rotateQuery: `INSERT INTO ` + o.table + ` (token_type, token, expires_at) VALUES (?, ?, ?)
+			ON CONFLICT (token_type, token) DO UPDATE SET expires_at = excluded.expires_at
+			WHERE expires_at <= ?`,
+		checkQuery:   `SELECT 1 FROM ` + o.table + ` WHERE token_type = ? AND token = ? AND expires_at > ?`,
+		cleanupQuery: `DELETE FROM ` + o.table + ` WHERE expires_at <= ?`,
+	}, nil
+}
+
+func (r *Repository) MarkTokenRevoke(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) error {
+	if err := validateTokenType(tokenType); err != nil {
+		return err
+	}
+
+	expiresAt := r.clock.Now().Add(ttl).UnixMilli()
+	_, err := r.db.ExecContext(ctx, r.markQuery, string(tokenType), token, expiresAt)
+	if err != nil {
+		return fmt.Errorf("mark token revoked: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) IsTokenRevoked(ctx context.Context, tokenType jwt.TokenType, token string) (bool, error) {
+	if err := validateTokenType(tokenType); err != nil {
+		return false, err
+	}
+
+	var one int
+	err := r.db.QueryRowContext(ctx, r.checkQuery, string(tokenType), token, r.clock.Now().UnixMilli()).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("check revocation: %w", err)
+	}
+	return true, nil
+}
+
+// MarkTokenRotated atomically revokes a refresh token if it is not revoked yet.
+func (r *Repository) MarkTokenRotated(ctx context.Context, token string, ttl time.Duration) (bool, error) {
+	rotated, err := r.markOnce(ctx, jwt.RefreshToken, token, ttl)
+	if err != nil {
+		return false, fmt.Errorf("mark token rotated: %w", err)
+	}
+	return rotated, nil
+}
+
+// MarkTokenConsumed implements jwt.AtomicConsumeRepository.
+func (r *Repository) MarkTokenConsumed(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	if err := validateTokenType(tokenType); err != nil {
+		return false, err
+	}
+	consumed, err := r.markOnce(ctx, tokenType, token, ttl)
+	if err != nil {
+		return false, fmt.Errorf("mark token consumed: %w", err)
+	}
+	return consumed, nil
+}
+
+// markOnce claims the row of token unless an unexpired one exists and
+// reports whether it did.
+func (r *Repository) markOnce(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	now := r.clock.Now()
+	res, err := r.db.ExecContext(ctx, r.rotateQuery, string(tokenType), token, now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// CleanupExpired deletes expired revocations and returns how many were removed.
+func (r *Repository) CleanupExpired(ctx context.Context) (int64, error) {
+	res, err := r.db.ExecContext(ctx, r.cleanupQuery, r.clock.Now().UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("cleanup expired revocations: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Ping implements jwt.Pinger.
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+func validateTokenType(tokenType jwt.TokenType) error {
+	switch tokenType {
+	case jwt.AccessToken, jwt.RefreshToken, jwt.ActionToken:
+		return nil
+	default:
+		return fmt.Errorf("invalid token type: %v", tokenType)
+	}
+}
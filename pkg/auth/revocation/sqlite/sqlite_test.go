@@ -0,0 +1,206 @@
+package sqlite
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/internal/clocktest"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/revocation/internal/sqlfake"
+)
+
+func newTestRepository(t *testing.T, opts ...Option) (*Repository, *sqlfake.Driver) {
+	t.Helper()
+	d := sqlfake.New()
+	db := sqlfake.Open(d)
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := NewRepository(context.Background(), db, opts...)
+	if err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	return repo, d
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	repo, d := newTestRepository(t)
+
+	if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, "a", time.Hour); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if revoked, err := repo.IsTokenRevoked(ctx, jwt.AccessToken, "a"); err != nil || !revoked {
+		t.Errorf("expected token to be revoked, got %v, %v", revoked, err)
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.RefreshToken, "a"); revoked {
+		t.Error("expected revocation to be scoped to the token type")
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.AccessToken, "b"); revoked {
+		t.Error("expected an unknown token not to be revoked")
+	}
+	if err := repo.MarkTokenRevoke(ctx, "bogus", "a", time.Hour); err == nil {
+		t.Error("expected an unknown token type to be rejected")
+	}
+
+	for _, stmt := range d.Statements() {
+		if strings.Contains(stmt, "$1") {
+			t.Errorf("expected ? placeholders, got %q", stmt)
+		}
+	}
+}
+
+func TestRepository_MarkTokenRotated(t *testing.T) {
+	ctx := context.Background()
+	clock := clocktest.New(time.Unix(1_700_000_000, 0))
+	repo, _ := newTestRepository(t, WithClock(clock))
+
+	if rotated, err := repo.MarkTokenRotated(ctx, "r", time.Hour); err != nil || !rotated {
+		t.Fatalf("first rotation = %v, %v, want true", rotated, err)
+	}
+	if rotated, _ := repo.MarkTokenRotated(ctx, "r", time.Hour); rotated {
+		t.Error("expected second rotation to fail")
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.RefreshToken, "r"); !revoked {
+		t.Error("expected the rotated token to be revoked")
+	}
+
+	// An expired marker no longer blocks a rotation.
+	if rotated, _ := repo.MarkTokenRotated(ctx, "expired", time.Minute); !rotated {
+		t.Fatal("expected rotation to succeed")
+	}
+	clock.Advance(time.Minute)
+	if rotated, _ := repo.MarkTokenRotated(ctx, "expired", time.Hour); !rotated {
+		t.Error("expected an expired marker to be reclaimed")
+	}
+
+	if consumed, _ := repo.MarkTokenConsumed(ctx, jwt.ActionToken, "r", time.Hour); !consumed {
+		t.Error("expected consumption to be independent of rotation")
+	}
+	if consumed, _ := repo.MarkTokenConsumed(ctx, jwt.ActionToken, "r", time.Hour); consumed {
+		t.Error("expected a second consumption to fail")
+	}
+	if _, err := repo.MarkTokenConsumed(ctx, "bogus", "r", time.Hour); err == nil {
+		t.Error("expected an unknown token type to be rejected")
+	}
+}
+
+func TestRepository_CleanupExpired(t *testing.T) {
+	ctx := context.Background()
+	clock := clocktest.New(time.Unix(1_700_000_000, 0))
+	repo, d := newTestRepository(t, WithClock(clock))
+
+	if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, "expired", time.Minute); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, "live", time.Hour); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+	clock.Advance(time.Minute)
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.AccessToken, "expired"); revoked {
+		t.Error("expected an expired revocation to be ignored")
+	}
+	if n, err := repo.CleanupExpired(ctx); err != nil || n != 1 {
+		t.Errorf("cleanup = %d, %v, want 1", n, err)
+	}
+	if d.Len() != 1 {
+		t.Errorf("expected 1 row left, got %d", d.Len())
+	}
+	if revoked, _ := repo.IsTokenRevoked(ctx, jwt.AccessToken, "live"); !revoked {
+		t.Error("expected the live revocation to survive cleanup")
+	}
+}
+
+func TestOpen_PragmasOnEveryConnection(t *testing.T) {
+	ctx := context.Background()
+	d := sqlfake.New()
+	db, err := Open(sqlfake.Register(d), "file:revocations.db")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	// Hold two connections at once so the pool has to open both.
+	for i := 0; i < 2; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("conn: %v", err)
+		}
+		defer conn.Close()
+	}
+	conns := d.Pragmas()
+	if len(conns) != 2 {
+		t.Fatalf("expected 2 connections, got %d", len(conns))
+	}
+	for i, got := range conns {
+		if !slices.Equal(got, pragmas) {
+			t.Errorf("connection %d ran %q, want %q", i, got, pragmas)
+		}
+	}
+
+	// Migration leaves the connection settings to Open.
+	if _, err := NewRepository(ctx, db); err != nil {
+		t.Fatalf("new repository: %v", err)
+	}
+	for i, got := range d.Pragmas() {
+		if !slices.Equal(got, pragmas) {
+			t.Errorf("after migration, connection %d ran %q, want %q", i, got, pragmas)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	schema, err := Schema(WithTable("revocations"), WithIndexName("revocations_expiry"))
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	for _, want := range []string{
+		"CREATE TABLE IF NOT EXISTS revocations (",
+		"CREATE INDEX IF NOT EXISTS revocations_expiry ON revocations (expires_at)",
+	} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("expected schema to contain %q, got:\n%s", want, schema)
+		}
+	}
+	schema, err = Schema()
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	if !strings.Contains(schema, "CREATE INDEX IF NOT EXISTS revoked_tokens_expires_at_idx ON revoked_tokens") {
+		t.Errorf("expected the default table and index, got:\n%s", schema)
+	}
+
+	for name, opts := range map[string][]Option{
+		"empty table":     {WithTable("")},
+		"injected table":  {WithTable("x; DROP TABLE users")},
+		"qualified table": {WithTable("main.revocations")},
+		"injected index":  {WithIndexName("idx; DROP")},
+	} {
+		if _, err := Schema(opts...); err == nil {
+			t.Errorf("%s: expected the options to be rejected", name)
+		}
+	}
+}
+
+func TestNewRepository_Migration(t *testing.T) {
+	ctx := context.Background()
+	_, d := newTestRepository(t, WithTable("revocations"), WithIndexName("revocations_expiry"))
+	if !d.HasTable("revocations") {
+		t.Error("expected NewRepository to create the table by default")
+	}
+	if got := d.Indexes(); !slices.Equal(got, []string{"revocations_expiry"}) {
+		t.Errorf("expected the revocations_expiry index, got %v", got)
+	}
+
+	repo, d := newTestRepository(t, WithoutMigration())
+	if d.HasTable(DefaultTable) {
+		t.Error("expected WithoutMigration to skip schema creation")
+	}
+	if err := repo.MarkTokenRevoke(ctx, jwt.AccessToken, "a", time.Hour); err == nil {
+		t.Error("expected statements to fail without the table")
+	}
+	if _, err := NewRepository(ctx, nil); err == nil {
+		t.Error("expected a nil db to be rejected")
+	}
+}
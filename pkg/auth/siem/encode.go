@@ -0,0 +1,120 @@
+package siem
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONLines encodes each event as one JSON object followed by a newline,
+// with snake_case keys and an RFC 3339 "timestamp". Extra fields are merged
+// into the object unless they collide with a standard key.
+type JSONLines struct{}
+
+func (JSONLines) Encode(w io.Writer, ev Event) error {
+	record := make(map[string]any, 12+len(ev.Extra))
+	for k, v := range ev.Extra {
+		record[k] = v
+	}
+	// Standard keys are written last so Extra cannot override them.
+	record["timestamp"] = ev.Time.UTC().Format(time.RFC3339Nano)
+	record["class"] = ev.Class
+	record["name"] = ev.Name
+	record["severity"] = ev.Severity
+	record["outcome"] = ev.Outcome
+	for k, v := range map[string]string{
+		"reason":     ev.Reason,
+		"user_id":    ev.UserID,
+		"session_id": ev.SessionID,
+		"token_id":   ev.TokenID,
+		"source_ip":  ev.SourceIP,
+		"method":     ev.Method,
+		"path":       ev.Path,
+	} {
+		if v != "" {
+			record[k] = v
+		}
+	}
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// CEF encodes each event as one ArcSight Common Event Format line:
+//
+//	CEF:0|Vendor|Product|Version|class:name|name|severity|extension
+//
+// Standard fields use the CEF dictionary keys (rt, outcome, reason, suid,
+// src, requestMethod, request); session and token IDs go to the cs1 and cs2
+// custom strings, and Extra fields are appended under their own keys.
+type CEF struct {
+	Vendor  string
+	Product string
+	Version string
+}
+
+func (c CEF) Encode(w io.Writer, ev Event) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		cefHeader(c.Vendor), cefHeader(c.Product), cefHeader(c.Version),
+		cefHeader(ev.Class+":"+ev.Name), cefHeader(ev.Name), ev.Severity)
+
+	ext := []string{"rt=" + strconv.FormatInt(ev.Time.UnixMilli(), 10)}
+	add := func(key, value string) {
+		if value != "" {
+			ext = append(ext, key+"="+cefValue(value))
+		}
+	}
+	add("outcome", ev.Outcome)
+	add("reason", ev.Reason)
+	add("suid", ev.UserID)
+	add("src", ev.SourceIP)
+	add("requestMethod", ev.Method)
+	add("request", ev.Path)
+	if ev.SessionID != "" {
+		add("cs1Label", "sessionId")
+		add("cs1", ev.SessionID)
+	}
+	if ev.TokenID != "" {
+		add("cs2Label", "tokenId")
+		add("cs2", ev.TokenID)
+	}
+	for _, k := range slices.Sorted(maps.Keys(ev.Extra)) {
+		add(cefKey(k), ev.Extra[k])
+	}
+	b.WriteString(strings.Join(ext, " "))
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// cefHeader escapes a header field.
+func cefHeader(s string) string { return cefHeaderEscaper.Replace(s) }
+
+// cefValue escapes an extension value.
+func cefValue(s string) string { return cefValueEscaper.Replace(s) }
+
+// cefKey strips the characters extension keys may not contain.
+func cefKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,226 @@
+// Package siem turns the audit events of pkg/auth into records SIEMs ingest
+// directly: JSON Lines for Elastic and Splunk HEC style pipelines, and
+// ArcSight Common Event Format (CEF) for syslog based collectors.
+//
+// A Writer encodes events to any io.Writer and provides adapters for every
+// event hook of the auth packages:
+//
+//	w := siem.NewWriter(os.Stdout, siem.CEF{Vendor: "Growth", Product: "gateway", Version: "1"})
+//	telemetry := &httpauth.Telemetry{Name: "gateway", Auditor: w.Auditor()}
+//	maker, err := jwt.NewTokenMaker(cfg, repo, jwt.WithSecurityEvents(w.SecurityEvents()))
+package siem
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/httpauth"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+// Event classes, used as the CEF signature ID prefix.
+const (
+	ClassDecision = "decision"
+	ClassSecurity = "security"
+	ClassApproval = "approval"
+	ClassLockout  = "lockout"
+)
+
+// Outcomes.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Event is an audit event in the shape common to SIEM formats.
+type Event struct {
+	Time time.Time
+	// Class is one of the Class constants and Name the specific event, e.g.
+	// ClassSecurity and "token_reuse".
+	Class string
+	Name  string
+	// Severity is on the CEF scale from 0 (lowest) to 10.
+	Severity int
+	Outcome  string
+	// Reason is a low-cardinality cause such as httpauth.Reason or a jwt
+	// error code.
+	Reason    string
+	UserID    string
+	SessionID string
+	TokenID   string
+	SourceIP  string
+	Method    string
+	Path      string
+	// Extra holds event specific fields.
+	Extra map[string]string
+}
+
+// FromDecision converts an httpauth decision.
+func FromDecision(d httpauth.Decision) Event {
+	ev := Event{
+		Time:      time.Now(),
+		Class:     ClassDecision,
+		Name:      d.Stage,
+		Severity:  1,
+		Outcome:   OutcomeSuccess,
+		Reason:    d.Reason,
+		UserID:    d.UserID,
+		SessionID: d.SessionID,
+		Method:    d.Method,
+		Path:      d.Path,
+	}
+	if !d.Allowed {
+		ev.Severity, ev.Outcome = 3, OutcomeFailure
+	}
+	return ev
+}
+
+// securitySeverity ranks the security event kinds.
+var securitySeverity = map[jwt.SecurityEventKind]int{
+	jwt.SecurityTokenReuse:       8,
+	jwt.SecurityRepeatedFailures: 6,
+	jwt.SecurityInvalidatedToken: 5,
+}
+
+// FromSecurityEvent converts a jwt security event.
+func FromSecurityEvent(se jwt.SecurityEvent) Event {
+	ev := Event{
+		Time:      se.At,
+		Class:     ClassSecurity,
+		Name:      string(se.Kind),
+		Severity:  securitySeverity[se.Kind],
+		Outcome:   OutcomeFailure,
+		Reason:    jwt.ErrorCode(se.Err),
+		UserID:    idString(se.UserID),
+		SessionID: idString(se.SessionID),
+		TokenID:   idString(se.TokenID),
+		Extra:     map[string]string{"token_type": string(se.TokenType)},
+	}
+	if se.Failures > 0 {
+		ev.Extra["failures"] = strconv.Itoa(se.Failures)
+	}
+	return ev
+}
+
+// FromApprovalEvent converts a jwt approval event. The requester is
+// reported as the user.
+func FromApprovalEvent(ae jwt.ApprovalEvent) Event {
+	ev := Event{
+		Time:     time.Now(),
+		Class:    ClassApproval,
+		Name:     ae.Stage,
+		Severity: 3,
+		Outcome:  OutcomeSuccess,
+		UserID:   idString(ae.Requester),
+		TokenID:  idString(ae.TokenID),
+		Extra: map[string]string{
+			"approver":  idString(ae.Approver),
+			"operation": ae.Action.Operation,
+		},
+	}
+	if ae.Action.Resource != "" {
+		ev.Extra["resource"] = ae.Action.Resource
+	}
+	if ae.Action.Amount != "" {
+		ev.Extra["amount"] = ae.Action.Amount
+	}
+	if ae.Err != nil {
+		ev.Severity, ev.Outcome, ev.Reason = 6, OutcomeFailure, jwt.ErrorCode(ae.Err)
+	}
+	return ev
+}
+
+// FromLockoutEvent converts a jwt lockout event.
+func FromLockoutEvent(le jwt.LockoutEvent) Event {
+	ev := Event{
+		Time:     time.Now(),
+		Class:    ClassLockout,
+		Name:     "threshold_reached",
+		Severity: 7,
+		Outcome:  OutcomeFailure,
+		Reason:   jwt.ErrorCode(le.Err),
+		UserID:   idString(le.UserID),
+		SourceIP: le.IP,
+		Extra: map[string]string{
+			"key":      le.Key,
+			"failures": strconv.Itoa(le.Failures),
+		},
+	}
+	if !le.Until.IsZero() {
+		ev.Extra["locked_until"] = le.Until.UTC().Format(time.RFC3339)
+	}
+	return ev
+}
+
+// Encoder formats one event as a single record.
+type Encoder interface {
+	Encode(w io.Writer, ev Event) error
+}
+
+// Writer encodes events to an io.Writer, one record per event. It is safe
+// for concurrent use. Write errors are logged, since the event hooks cannot
+// return them.
+type Writer struct {
+	enc Encoder
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriter returns a Writer encoding events with enc to w.
+func NewWriter(w io.Writer, enc Encoder) *Writer {
+	return &Writer{w: w, enc: enc}
+}
+
+// Write encodes ev, filling in SourceIP from the jwt.ClientInfo in ctx when
+// the event has none.
+func (w *Writer) Write(ctx context.Context, ev Event) error {
+	if ev.SourceIP == "" {
+		if info, ok := jwt.ClientInfoFrom(ctx); ok {
+			ev.SourceIP = info.IP
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(w.w, ev)
+}
+
+func (w *Writer) write(ctx context.Context, ev Event) {
+	if err := w.Write(ctx, ev); err != nil {
+		logx.WithContext(ctx).Errorf("siem: write %s event: %v", ev.Class, err)
+	}
+}
+
+// Auditor returns an httpauth.Auditor writing every decision.
+func (w *Writer) Auditor() httpauth.Auditor {
+	return func(ctx context.Context, d httpauth.Decision) { w.write(ctx, FromDecision(d)) }
+}
+
+// SecurityEvents returns a jwt.SecurityEventHandler writing every event.
+func (w *Writer) SecurityEvents() jwt.SecurityEventHandler {
+	return func(ctx context.Context, ev jwt.SecurityEvent) { w.write(ctx, FromSecurityEvent(ev)) }
+}
+
+// ApprovalAuditor returns a jwt.ApprovalAuditor writing every event.
+func (w *Writer) ApprovalAuditor() jwt.ApprovalAuditor {
+	return func(ctx context.Context, ev jwt.ApprovalEvent) { w.write(ctx, FromApprovalEvent(ev)) }
+}
+
+// OnLockout returns a jwt.LockoutPolicy.OnLockout hook writing every event.
+func (w *Writer) OnLockout() func(context.Context, jwt.LockoutEvent) {
+	return func(ctx context.Context, ev jwt.LockoutEvent) { w.write(ctx, FromLockoutEvent(ev)) }
+}
+
+// idString returns id as a string, or "" for uuid.Nil.
+func idString(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
@@ -0,0 +1,111 @@
+package siem
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/suleymanmyradov/growth-server/pkg/auth/httpauth"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+)
+
+var testEvent = Event{
+	Time:      time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	Class:     ClassSecurity,
+	Name:      "token_reuse",
+	Severity:  8,
+	Outcome:   OutcomeFailure,
+	Reason:    "token_revoked",
+	UserID:    "user-1",
+	SessionID: "session-1",
+	SourceIP:  "203.0.113.7",
+	Extra:     map[string]string{"token_type": "refresh", "note": "a=b|c\nd"},
+}
+
+func TestJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (JSONLines{}).Encode(&buf, testEvent); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if !strings.HasSuffix(buf.String(), "}\n") || strings.Count(buf.String(), "\n") != 1 {
+		t.Fatalf("record = %q, want one line", buf.String())
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for key, want := range map[string]any{
+		"timestamp":  "2024-05-01T12:00:00Z",
+		"class":      ClassSecurity,
+		"name":       "token_reuse",
+		"severity":   float64(8),
+		"outcome":    OutcomeFailure,
+		"reason":     "token_revoked",
+		"user_id":    "user-1",
+		"session_id": "session-1",
+		"source_ip":  "203.0.113.7",
+		"token_type": "refresh",
+	} {
+		if record[key] != want {
+			t.Errorf("%s = %v, want %v", key, record[key], want)
+		}
+	}
+	if _, ok := record["token_id"]; ok {
+		t.Error("empty token_id was encoded")
+	}
+}
+
+func TestCEF(t *testing.T) {
+	var buf bytes.Buffer
+	enc := CEF{Vendor: "Growth", Product: "auth|gw", Version: "1"}
+	if err := enc.Encode(&buf, testEvent); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	want := `CEF:0|Growth|auth\|gw|1|security:token_reuse|token_reuse|8|` +
+		`rt=1714564800000 outcome=failure reason=token_revoked suid=user-1 src=203.0.113.7 ` +
+		`cs1Label=sessionId cs1=session-1 note=a\=b|c\nd tokentype=refresh` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("record =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, JSONLines{})
+	ctx := jwt.WithClientInfo(context.Background(), jwt.ClientInfo{IP: "198.51.100.1"})
+	userID := uuid.New()
+
+	w.Auditor()(ctx, httpauth.Decision{Stage: httpauth.StageAuthenticate, Path: "/v1/me", Reason: "expired"})
+	w.SecurityEvents()(ctx, jwt.SecurityEvent{Kind: jwt.SecurityTokenReuse, UserID: userID, Err: jwt.ErrTokenRevoked})
+	w.OnLockout()(ctx, jwt.LockoutEvent{Key: "ip:192.0.2.1", IP: "192.0.2.1", Failures: 10, Err: jwt.ErrInvalidToken})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d records, want 3", len(lines))
+	}
+	tests := []struct {
+		class, outcome, reason, ip string
+	}{
+		{ClassDecision, OutcomeFailure, "expired", "198.51.100.1"},
+		{ClassSecurity, OutcomeFailure, jwt.CodeTokenRevoked, "198.51.100.1"},
+		{ClassLockout, OutcomeFailure, jwt.CodeInvalidToken, "192.0.2.1"},
+	}
+	for i, tt := range tests {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(lines[i]), &record); err != nil {
+			t.Fatalf("decode record %d: %v", i, err)
+		}
+		if record["class"] != tt.class || record["outcome"] != tt.outcome ||
+			record["reason"] != tt.reason || record["source_ip"] != tt.ip {
+			t.Errorf("record %d = %v, want class %s, outcome %s, reason %s, source_ip %s",
+				i, record, tt.class, tt.outcome, tt.reason, tt.ip)
+		}
+	}
+}
@@ -3,11 +3,9 @@ package middleware
 import (
 	"fmt"
 	"net/http"
-	"strings"
 
+	"github.com/suleymanmyradov/growth-server/pkg/auth/httpauth"
 	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
-	"github.com/suleymanmyradov/growth-server/pkg/auth/principal"
-	"github.com/suleymanmyradov/growth-server/pkg/httpx/errors"
 	"github.com/zeromicro/go-zero/rest"
 )
 
@@ -27,37 +25,8 @@ func JWTMiddleware(cfg JWTVerifierConfig) rest.Middleware {
 		panic(fmt.Sprintf("failed to create token verifier: %v", err))
 	}
 
+	auth := httpauth.New(maker)
 	return func(next http.HandlerFunc) http.HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				errors.WriteUnauthorized(w, "missing authorization header")
-				return
-			}
-
-			parts := strings.SplitN(authHeader, " ", 2)
-			if len(parts) != 2 || parts[0] != "Bearer" {
-				errors.WriteUnauthorized(w, "invalid authorization format")
-				return
-			}
-
-			tokenString := parts[1]
-
-			claims, err := maker.VerifyAccessToken(r.Context(), tokenString)
-			if err != nil {
-				errors.WriteUnauthorized(w, "invalid or expired token")
-				return
-			}
-
-			p := principal.Principal{
-				UserID:    claims.Subject.String(),
-				Username:  claims.Username,
-				Roles:     claims.Roles,
-				SessionID: claims.SessionID.String(),
-			}
-			ctx := principal.WithPrincipal(r.Context(), p)
-			ctx = principal.WithToken(ctx, tokenString)
-			next(w, r.WithContext(ctx))
-		}
+		return auth.Middleware(next).ServeHTTP
 	}
 }
@@ -28,6 +28,19 @@ type Config struct {
 		Audience              string        `json:",optional"`
 		AccessExpiryDuration  time.Duration `json:",optional"`
 		RefreshExpiryDuration time.Duration `json:",optional"`
+		// RefreshMaxLifetimeExpiry and RefreshIdleTimeout enable sliding
+		// sessions; see jwt.Config.
+		RefreshMaxLifetimeExpiry time.Duration `json:",optional"`
+		RefreshIdleTimeout       time.Duration `json:",optional"`
+		// RememberMeExpiryDuration and RememberMeMaxLifetimeExpiry apply to
+		// "keep me signed in" sessions; see jwt.WithRememberMe.
+		RememberMeExpiryDuration    time.Duration `json:",optional"`
+		RememberMeMaxLifetimeExpiry time.Duration `json:",optional"`
+		NotIssuedBefore             int64         `json:",optional"`
+		// IssuanceRateLimit (tokens per second) and IssuanceBurst cap token
+		// issuance per user; see jwt.Config.
+		IssuanceRateLimit float64 `json:",optional"`
+		IssuanceBurst     int     `json:",optional"`
 	}
 	Email struct {
 		// Provider is "resend" by default. An empty APIKey enables a noop sender
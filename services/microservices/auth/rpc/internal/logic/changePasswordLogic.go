@@ -74,6 +74,11 @@ func (l *ChangePasswordLogic) ChangePassword(in *auth.ChangePasswordRequest) (*a
 		return nil, status.Error(codes.Internal, "failed to update password")
 	}
 
+	// Sign out every device; the password may have been compromised.
+	if err := l.svcCtx.TokenMaker.RevokeAllUserTokens(ctx, user.ID); err != nil {
+		l.Errorf("ChangePassword failed to revoke tokens for user %s: %v", user.ID, err)
+	}
+
 	l.Infof("ChangePassword successful for user %s", userID)
 
 	return &auth.EmptyResponse{}, nil
@@ -0,0 +1,49 @@
+package logic
+
+import (
+	"context"
+	"net"
+
+	"github.com/google/uuid"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// withClientInfo attaches the caller's address and user agent to ctx so the
+// session created by CreateRefreshToken records them. Gateways forward the
+// original values in x-forwarded-for and x-device-name.
+func withClientInfo(ctx context.Context) context.Context {
+	var info jwt.ClientInfo
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("x-forwarded-for"); len(v) > 0 {
+		info.IP = v[0]
+	} else if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		info.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(info.IP); err == nil {
+			info.IP = host
+		}
+	}
+	if v := md.Get("user-agent"); len(v) > 0 {
+		info.UserAgent = v[0]
+	}
+	if v := md.Get("x-device-name"); len(v) > 0 {
+		info.DeviceName = v[0]
+	}
+	return jwt.WithClientInfo(ctx, info)
+}
+
+// deviceOptions binds the new session to the device the gateway forwards in
+// x-device-id. A missing or malformed id starts an unbound session.
+func deviceOptions(ctx context.Context) []jwt.RefreshOption {
+	md, _ := metadata.FromIncomingContext(ctx)
+	v := md.Get("x-device-id")
+	if len(v) == 0 {
+		return nil
+	}
+	id, err := uuid.Parse(v[0])
+	if err != nil || id == uuid.Nil {
+		return nil
+	}
+	return []jwt.RefreshOption{jwt.WithDevice(id)}
+}
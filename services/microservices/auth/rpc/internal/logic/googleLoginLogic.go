@@ -139,13 +139,13 @@ func (l *GoogleLoginLogic) GoogleLogin(in *auth.GoogleLoginRequest) (*auth.AuthR
 	accessToken, err := l.svcCtx.TokenMaker.CreateAccessToken(ctx, user.ID, user.Username, []string{"user"}, sessionID)
 	if err != nil {
 		l.Errorf("GoogleLogin: access token failed: %v", err)
-		return nil, status.Error(codes.Internal, "failed to generate access token")
+		return nil, issueTokenError(err, "failed to generate access token")
 	}
 
-	refreshToken, err := l.svcCtx.TokenMaker.CreateRefreshToken(ctx, user.ID, user.Username, []string{"user"}, sessionID)
+	refreshToken, err := l.svcCtx.TokenMaker.CreateRefreshToken(withClientInfo(ctx), user.ID, user.Username, []string{"user"}, sessionID, deviceOptions(ctx)...)
 	if err != nil {
 		l.Errorf("GoogleLogin: refresh token failed: %v", err)
-		return nil, status.Error(codes.Internal, "failed to generate refresh token")
+		return nil, issueTokenError(err, "failed to generate refresh token")
 	}
 
 	l.Infof("GoogleLogin successful for user %s", user.ID)
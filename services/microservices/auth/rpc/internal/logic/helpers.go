@@ -1,11 +1,15 @@
 package logic
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
 	"github.com/suleymanmyradov/growth-server/services/microservices/auth/rpc/internal/repository/db"
 	"github.com/suleymanmyradov/growth-server/services/microservices/auth/rpc/pb/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func toNullString(s string) *string {
@@ -63,3 +67,12 @@ func nonNilStrings(s []string) []string {
 	}
 	return s
 }
+
+// issueTokenError maps a token issuing failure to a gRPC status, reporting
+// per-user issuance throttling as ResourceExhausted.
+func issueTokenError(err error, msg string) error {
+	if errors.Is(err, jwt.ErrRateLimited) {
+		return status.Error(codes.ResourceExhausted, "too many token requests")
+	}
+	return status.Error(codes.Internal, msg)
+}
@@ -2,7 +2,9 @@ package logic
 
 import (
 	"context"
+	"errors"
 
+	"github.com/google/uuid"
 	"github.com/suleymanmyradov/growth-server/services/microservices/auth/rpc/internal/svc"
 	"github.com/suleymanmyradov/growth-server/services/microservices/auth/rpc/pb/auth"
 	"github.com/zeromicro/go-zero/core/logx"
@@ -36,13 +38,20 @@ func (l *LogoutLogic) Logout(in *auth.LogoutRequest) (*auth.EmptyResponse, error
 		return nil, status.Error(codes.InvalidArgument, "access token is required")
 	}
 
-	_, err := l.svcCtx.TokenMaker.VerifyAccessToken(ctx, in.AccessToken)
+	claims, err := l.svcCtx.TokenMaker.VerifyAccessToken(ctx, in.AccessToken)
 	if err != nil {
 		l.Errorf("Logout failed to verify token: %v", err)
 		return nil, status.Error(codes.Unauthenticated, "invalid token")
 	}
 
-	err = l.svcCtx.TokenMaker.RevokeAccessToken(ctx, in.AccessToken)
+	// Revoking the session also ends the device's refresh token, which the
+	// client does not send on logout.
+	if claims.SessionID != uuid.Nil {
+		err = l.svcCtx.TokenMaker.RevokeSession(ctx, claims.SessionID)
+	}
+	if claims.SessionID == uuid.Nil || errors.Is(err, errors.ErrUnsupported) {
+		err = l.svcCtx.TokenMaker.RevokeAccessToken(ctx, in.AccessToken)
+	}
 	if err != nil {
 		l.Errorf("Logout failed to revoke token: %v", err)
 	}
@@ -2,7 +2,9 @@ package logic
 
 import (
 	"context"
+	"errors"
 
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
 	"github.com/suleymanmyradov/growth-server/services/microservices/auth/rpc/internal/svc"
 	"github.com/suleymanmyradov/growth-server/services/microservices/auth/rpc/pb/auth"
 	"github.com/zeromicro/go-zero/core/logx"
@@ -36,10 +38,22 @@ func (l *RefreshTokenLogic) RefreshToken(in *auth.RefreshRequest) (*auth.AuthRes
 		return nil, status.Error(codes.InvalidArgument, "refresh token is required")
 	}
 
-	refreshClaims, err := l.svcCtx.TokenMaker.VerifyRefreshToken(ctx, in.RefreshToken)
+	// Rotate before anything else: RotateRefreshToken is where a replayed
+	// token is detected and its family and session revoked.
+	newRefreshToken, err := l.svcCtx.TokenMaker.RotateRefreshToken(ctx, in.RefreshToken)
 	if err != nil {
-		l.Errorf("RefreshToken failed to verify refresh token: %v", err)
-		return nil, status.Error(codes.Unauthenticated, "invalid or expired refresh token")
+		l.Errorf("RefreshToken failed to rotate refresh token: %v", err)
+		if errors.Is(err, jwt.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid or expired refresh token")
+		}
+		return nil, issueTokenError(err, "failed to generate refresh token")
+	}
+
+	// The new token was just issued, so its claims need no repository checks.
+	refreshClaims, err := l.svcCtx.TokenMaker.VerifyRefreshToken(jwt.WithOfflineVerification(ctx), newRefreshToken.Token)
+	if err != nil {
+		l.Errorf("RefreshToken failed to read rotated refresh token: %v", err)
+		return nil, status.Error(codes.Internal, "failed to generate refresh token")
 	}
 
 	userID := refreshClaims.Subject
@@ -53,13 +67,7 @@ func (l *RefreshTokenLogic) RefreshToken(in *auth.RefreshRequest) (*auth.AuthRes
 	accessToken, err := l.svcCtx.TokenMaker.CreateAccessToken(ctx, user.ID, user.Username, []string{"user"}, sessionID)
 	if err != nil {
 		l.Errorf("RefreshToken failed to create access token for user %s: %v", user.ID, err)
-		return nil, status.Error(codes.Internal, "failed to generate access token")
-	}
-
-	newRefreshToken, err := l.svcCtx.TokenMaker.RotateRefreshToken(ctx, in.RefreshToken)
-	if err != nil {
-		l.Errorf("RefreshToken failed to rotate refresh token for user %s: %v", user.ID, err)
-		return nil, status.Error(codes.Internal, "failed to generate refresh token")
+		return nil, issueTokenError(err, "failed to generate access token")
 	}
 
 	l.Infof("RefreshToken successful for user %s", user.ID)
@@ -68,6 +68,11 @@ func (l *ResetPasswordLogic) ResetPassword(in *auth.ResetPasswordRequest) (*auth
 		return nil, status.Error(codes.Internal, "failed to update password")
 	}
 
+	// Sign out every device; the password may have been compromised.
+	if err := l.svcCtx.TokenMaker.RevokeAllUserTokens(ctx, user.ID); err != nil {
+		l.Errorf("ResetPassword failed to revoke tokens for user %s: %v", user.ID, err)
+	}
+
 	if err := resetRepo.Delete(ctx, in.Token); err != nil {
 		l.Errorf("ResetPassword failed to delete reset token: %v", err)
 	}
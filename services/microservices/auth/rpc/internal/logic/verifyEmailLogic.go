@@ -70,13 +70,13 @@ func (l *VerifyEmailLogic) VerifyEmail(in *auth.VerifyEmailRequest) (*auth.AuthR
 	accessToken, err := l.svcCtx.TokenMaker.CreateAccessToken(ctx, user.ID, user.Username, []string{"user"}, sessionID)
 	if err != nil {
 		l.Errorf("VerifyEmail failed to create access token for user %s: %v", user.ID, err)
-		return nil, status.Error(codes.Internal, "failed to generate access token")
+		return nil, issueTokenError(err, "failed to generate access token")
 	}
 
-	refreshToken, err := l.svcCtx.TokenMaker.CreateRefreshToken(ctx, user.ID, user.Username, []string{"user"}, sessionID)
+	refreshToken, err := l.svcCtx.TokenMaker.CreateRefreshToken(withClientInfo(ctx), user.ID, user.Username, []string{"user"}, sessionID, deviceOptions(ctx)...)
 	if err != nil {
 		l.Errorf("VerifyEmail failed to create refresh token for user %s: %v", user.ID, err)
-		return nil, status.Error(codes.Internal, "failed to generate refresh token")
+		return nil, issueTokenError(err, "failed to generate refresh token")
 	}
 
 	l.Infof("VerifyEmail successful for user %s", user.ID)
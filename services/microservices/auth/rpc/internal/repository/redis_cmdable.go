@@ -2,9 +2,13 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
 	"github.com/suleymanmyradov/growth-server/pkg/redisutil"
@@ -13,9 +17,27 @@ import (
 const (
 	revokedAccessPrefix  = "revoked:access:"
 	revokedRefreshPrefix = "revoked:refresh:"
+	revokedActionPrefix  = "revoked:action:"
+	revokedSessionPrefix = "revoked:session:"
+	revokedFamilyPrefix  = "revoked:family:"
+	revokedUserPrefix    = "revoked:user:"
 	minRedisTTL          = 100 * time.Millisecond
+	revokedScanCount     = 1000
 )
 
+// rotateScript revokes a refresh token unless it is already revoked, in one
+// round trip. The value is the rotation time in Unix milliseconds, so a replay
+// can tell how long ago the token was exchanged. It returns {1, now} when this
+// call rotated the token and {0, previous value} otherwise.
+var rotateScript = redis.NewScript(`
+local prev = redis.call('GET', KEYS[1])
+if prev then
+	return {0, prev}
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return {1, ARGV[1]}
+`)
+
 type CmdableRedisRepository struct {
 	client redis.Cmdable
 }
@@ -35,30 +57,20 @@ func (r *CmdableRedisRepository) MarkTokenRevoke(ctx context.Context, tokenType
 		ttl = minRedisTTL
 	}
 
-	var key string
-	switch tokenType {
-	case jwt.AccessToken:
-		key = revokedAccessPrefix + token
-	case jwt.RefreshToken:
-		key = revokedRefreshPrefix + token
-	default:
-		return fmt.Errorf("invalid token type: %v", tokenType)
+	key, err := revokedKey(tokenType, token)
+	if err != nil {
+		return err
 	}
 
 	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
 	defer cancel()
-	return r.client.Set(ctx, key, "1", ttl).Err()
+	return r.client.Set(ctx, key, time.Now().UnixMilli(), ttl).Err()
 }
 
 func (r *CmdableRedisRepository) IsTokenRevoked(ctx context.Context, tokenType jwt.TokenType, token string) (bool, error) {
-	var key string
-	switch tokenType {
-	case jwt.AccessToken:
-		key = revokedAccessPrefix + token
-	case jwt.RefreshToken:
-		key = revokedRefreshPrefix + token
-	default:
-		return false, fmt.Errorf("invalid token type: %v", tokenType)
+	key, err := revokedKey(tokenType, token)
+	if err != nil {
+		return false, err
 	}
 
 	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
@@ -70,3 +82,318 @@ func (r *CmdableRedisRepository) IsTokenRevoked(ctx context.Context, tokenType j
 
 	return exists > 0, nil
 }
+
+// MarkTokenRotated implements jwt.AtomicRotationRepository.
+func (r *CmdableRedisRepository) MarkTokenRotated(ctx context.Context, token string, ttl time.Duration) (bool, error) {
+	rotated, _, err := r.RotateToken(ctx, token, ttl)
+	return rotated, err
+}
+
+// MarkTokenConsumed implements jwt.AtomicConsumeRepository with a single
+// SET NX, so only one caller consumes a token.
+func (r *CmdableRedisRepository) MarkTokenConsumed(ctx context.Context, tokenType jwt.TokenType, token string, ttl time.Duration) (bool, error) {
+	if ttl < minRedisTTL {
+		ttl = minRedisTTL
+	}
+
+	key, err := revokedKey(tokenType, token)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	consumed, err := r.client.SetNX(ctx, key, time.Now().UnixMilli(), ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("mark token consumed: %w", err)
+	}
+	return consumed, nil
+}
+
+// RotateToken atomically revokes a refresh token and records when it was
+// rotated. If the token was already rotated it reports false together with the
+// earlier rotation time, which callers can use for reuse detection.
+// Markers that predate rotation timestamps report a zero time.
+func (r *CmdableRedisRepository) RotateToken(ctx context.Context, token string, ttl time.Duration) (bool, time.Time, error) {
+	if ttl < minRedisTTL {
+		ttl = minRedisTTL
+	}
+
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	now := time.Now().UnixMilli()
+	res, err := rotateScript.Run(ctx, r.client, []string{revokedRefreshPrefix + token}, now, ttl.Milliseconds()).Slice()
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("mark token rotated: %w", err)
+	}
+	if len(res) != 2 {
+		return false, time.Time{}, fmt.Errorf("mark token rotated: unexpected script reply %v", res)
+	}
+
+	rotated, _ := res[0].(int64)
+	var rotatedAt time.Time
+	if v, ok := res[1].(string); ok {
+		// Older markers hold "1" instead of a timestamp.
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil && ms > 1 {
+			rotatedAt = time.UnixMilli(ms)
+		}
+	}
+	return rotated == 1, rotatedAt, nil
+}
+
+// MarkTokensRevoked implements jwt.BatchRevocationRepository with a single
+// pipelined round trip.
+func (r *CmdableRedisRepository) MarkTokensRevoked(ctx context.Context, tokenType jwt.TokenType, tokens []string, ttl time.Duration) error {
+	if ttl < minRedisTTL {
+		ttl = minRedisTTL
+	}
+
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	now := time.Now().UnixMilli()
+	pipe := r.client.Pipeline()
+	for _, token := range tokens {
+		key, err := revokedKey(tokenType, token)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, key, now, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("mark tokens revoked: %w", err)
+	}
+	return nil
+}
+
+// AreTokensRevoked implements jwt.BatchRevocationRepository with a single
+// pipelined round trip.
+func (r *CmdableRedisRepository) AreTokensRevoked(ctx context.Context, tokenType jwt.TokenType, tokens []string) ([]bool, error) {
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.IntCmd, len(tokens))
+	for i, token := range tokens {
+		key, err := revokedKey(tokenType, token)
+		if err != nil {
+			return nil, err
+		}
+		cmds[i] = pipe.Exists(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("check revocation: %w", err)
+	}
+
+	revoked := make([]bool, len(tokens))
+	for i, cmd := range cmds {
+		revoked[i] = cmd.Val() > 0
+	}
+	return revoked, nil
+}
+
+// ListRevoked calls fn for every revoked token of tokenType, using SCAN so the
+// server is never blocked. It implements bloom.Lister.
+func (r *CmdableRedisRepository) ListRevoked(ctx context.Context, tokenType jwt.TokenType, fn func(token string) error) error {
+	prefix, err := revokedKey(tokenType, "")
+	if err != nil {
+		return err
+	}
+
+	iter := r.client.Scan(ctx, 0, prefix+"*", revokedScanCount).Iterator()
+	for iter.Next(ctx) {
+		if err := fn(strings.TrimPrefix(iter.Val(), prefix)); err != nil {
+			return err
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("list revocations: %w", err)
+	}
+	return nil
+}
+
+// ScanRevoked calls fn for every revoked token with its remaining lifetime,
+// reading TTLs one SCAN page at a time in a pipeline. It implements
+// migrate.Scanner.
+func (r *CmdableRedisRepository) ScanRevoked(ctx context.Context, fn func(tokenType jwt.TokenType, token string, ttl time.Duration) error) error {
+	for _, tokenType := range []jwt.TokenType{jwt.AccessToken, jwt.RefreshToken, jwt.ActionToken} {
+		prefix, err := revokedKey(tokenType, "")
+		if err != nil {
+			return err
+		}
+
+		var cursor uint64
+		for {
+			keys, next, err := r.client.Scan(ctx, cursor, prefix+"*", revokedScanCount).Result()
+			if err != nil {
+				return fmt.Errorf("scan revocations: %w", err)
+			}
+
+			pipe := r.client.Pipeline()
+			cmds := make([]*redis.DurationCmd, len(keys))
+			for i, key := range keys {
+				cmds[i] = pipe.PTTL(ctx, key)
+			}
+			if len(keys) > 0 {
+				if _, err := pipe.Exec(ctx); err != nil {
+					return fmt.Errorf("scan revocations: %w", err)
+				}
+			}
+			for i, cmd := range cmds {
+				// Expired keys report a negative TTL; keys without an expiry
+				// are never written by this repository.
+				if ttl := cmd.Val(); ttl > 0 {
+					if err := fn(tokenType, strings.TrimPrefix(keys[i], prefix), ttl); err != nil {
+						return err
+					}
+				}
+			}
+
+			if next == 0 {
+				break
+			}
+			cursor = next
+		}
+	}
+	return nil
+}
+
+// MarkSessionRevoked implements jwt.SessionRevocationRepository.
+func (r *CmdableRedisRepository) MarkSessionRevoked(ctx context.Context, sessionID uuid.UUID, ttl time.Duration) error {
+	if ttl < minRedisTTL {
+		ttl = minRedisTTL
+	}
+
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	return r.client.Set(ctx, revokedSessionPrefix+sessionID.String(), time.Now().UnixMilli(), ttl).Err()
+}
+
+// IsSessionRevoked implements jwt.SessionRevocationRepository.
+func (r *CmdableRedisRepository) IsSessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	exists, err := r.client.Exists(ctx, revokedSessionPrefix+sessionID.String()).Result()
+	if err != nil {
+		return false, fmt.Errorf("check session revocation: %w", err)
+	}
+	return exists > 0, nil
+}
+
+// MarkFamilyRevoked implements jwt.FamilyRevocationRepository.
+func (r *CmdableRedisRepository) MarkFamilyRevoked(ctx context.Context, familyID uuid.UUID, ttl time.Duration) error {
+	if ttl < minRedisTTL {
+		ttl = minRedisTTL
+	}
+
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	return r.client.Set(ctx, revokedFamilyPrefix+familyID.String(), time.Now().UnixMilli(), ttl).Err()
+}
+
+// IsFamilyRevoked implements jwt.FamilyRevocationRepository.
+func (r *CmdableRedisRepository) IsFamilyRevoked(ctx context.Context, familyID uuid.UUID) (bool, error) {
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	exists, err := r.client.Exists(ctx, revokedFamilyPrefix+familyID.String()).Result()
+	if err != nil {
+		return false, fmt.Errorf("check family revocation: %w", err)
+	}
+	return exists > 0, nil
+}
+
+// MarkUserRevoked implements jwt.UserRevocationRepository. The cutoff is
+// stored as Unix milliseconds.
+func (r *CmdableRedisRepository) MarkUserRevoked(ctx context.Context, userID uuid.UUID, before time.Time, ttl time.Duration) error {
+	if ttl < minRedisTTL {
+		ttl = minRedisTTL
+	}
+
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	return r.client.Set(ctx, revokedUserPrefix+userID.String(), before.UnixMilli(), ttl).Err()
+}
+
+// UserRevokedBefore implements jwt.UserRevocationRepository.
+func (r *CmdableRedisRepository) UserRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	val, err := r.client.Get(ctx, revokedUserPrefix+userID.String()).Result()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("check user revocation: %w", err)
+	}
+
+	ms, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse user revocation: %w", err)
+	}
+	return time.UnixMilli(ms), nil
+}
+
+// RevocationStatus implements jwt.RevocationStatusRepository with a single
+// pipelined round trip.
+func (r *CmdableRedisRepository) RevocationStatus(ctx context.Context, q jwt.RevocationQuery) (jwt.RevocationStatus, error) {
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	pipe := r.client.Pipeline()
+	var token, rotated, session, family *redis.IntCmd
+	var user *redis.StringCmd
+	if q.Token != "" {
+		key, err := revokedKey(q.TokenType, q.Token)
+		if err != nil {
+			return jwt.RevocationStatus{}, err
+		}
+		token = pipe.Exists(ctx, key)
+	}
+	if q.RotationKey != "" {
+		rotated = pipe.Exists(ctx, revokedRefreshPrefix+q.RotationKey)
+	}
+	if q.SessionID != uuid.Nil {
+		session = pipe.Exists(ctx, revokedSessionPrefix+q.SessionID.String())
+	}
+	if q.FamilyID != uuid.Nil {
+		family = pipe.Exists(ctx, revokedFamilyPrefix+q.FamilyID.String())
+	}
+	if q.UserID != uuid.Nil {
+		user = pipe.Get(ctx, revokedUserPrefix+q.UserID.String())
+	}
+	// A missing user cutoff fails its GET with redis.Nil.
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return jwt.RevocationStatus{}, fmt.Errorf("check revocation status: %w", err)
+	}
+
+	var status jwt.RevocationStatus
+	status.TokenRevoked = token != nil && token.Val() > 0
+	status.TokenRotated = rotated != nil && rotated.Val() > 0
+	status.SessionRevoked = session != nil && session.Val() > 0
+	status.FamilyRevoked = family != nil && family.Val() > 0
+	if user != nil && user.Err() == nil {
+		ms, err := strconv.ParseInt(user.Val(), 10, 64)
+		if err != nil {
+			return jwt.RevocationStatus{}, fmt.Errorf("parse user revocation: %w", err)
+		}
+		status.UserRevokedBefore = time.UnixMilli(ms)
+	}
+	return status, nil
+}
+
+// Ping implements jwt.Pinger.
+func (r *CmdableRedisRepository) Ping(ctx context.Context) error {
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	return r.client.Ping(ctx).Err()
+}
+
+func revokedKey(tokenType jwt.TokenType, token string) (string, error) {
+	switch tokenType {
+	case jwt.AccessToken:
+		return revokedAccessPrefix + token, nil
+	case jwt.RefreshToken:
+		return revokedRefreshPrefix + token, nil
+	case jwt.ActionToken:
+		return revokedActionPrefix + token, nil
+	default:
+		return "", fmt.Errorf("invalid token type: %v", tokenType)
+	}
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/suleymanmyradov/growth-server/pkg/redisutil"
+)
+
+const (
+	notIssuedBeforeGlobalKey  = "invalidated:global"
+	notIssuedBeforeUserPrefix = "invalidated:user:"
+)
+
+// RedisInvalidationStore persists "not issued before" cutoffs in Redis as Unix
+// seconds. It implements jwt.InvalidationSource.
+type RedisInvalidationStore struct {
+	client redis.Cmdable
+	// ttl bounds how long a per-user cutoff is kept; it only needs to outlive
+	// the longest-lived token issued before it.
+	ttl time.Duration
+}
+
+func NewRedisInvalidationStore(client redis.Cmdable, ttl time.Duration) (*RedisInvalidationStore, error) {
+	if client == nil {
+		return nil, fmt.Errorf("redis client cannot be nil")
+	}
+
+	return &RedisInvalidationStore{
+		client: client,
+		ttl:    ttl,
+	}, nil
+}
+
+func (r *RedisInvalidationStore) GlobalNotIssuedBefore(ctx context.Context) (time.Time, error) {
+	return r.get(ctx, notIssuedBeforeGlobalKey)
+}
+
+func (r *RedisInvalidationStore) UserNotIssuedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	return r.get(ctx, notIssuedBeforeUserPrefix+userID.String())
+}
+
+// SetGlobalNotIssuedBefore invalidates every token issued before t.
+func (r *RedisInvalidationStore) SetGlobalNotIssuedBefore(ctx context.Context, t time.Time) error {
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	return r.client.Set(ctx, notIssuedBeforeGlobalKey, t.Unix(), 0).Err()
+}
+
+// SetUserNotIssuedBefore invalidates every token of userID issued before t.
+func (r *RedisInvalidationStore) SetUserNotIssuedBefore(ctx context.Context, userID uuid.UUID, t time.Time) error {
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	return r.client.Set(ctx, notIssuedBeforeUserPrefix+userID.String(), t.Unix(), r.ttl).Err()
+}
+
+// Ping implements jwt.Pinger.
+func (r *RedisInvalidationStore) Ping(ctx context.Context) error {
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *RedisInvalidationStore) get(ctx context.Context, key string) (time.Time, error) {
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	val, err := r.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get invalidation cutoff: %w", err)
+	}
+
+	sec, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse invalidation cutoff: %w", err)
+	}
+	return time.Unix(sec, 0), nil
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/suleymanmyradov/growth-server/pkg/auth/jwt"
+	"github.com/suleymanmyradov/growth-server/pkg/redisutil"
+)
+
+const (
+	sessionInfoPrefix  = "session:info:"
+	userSessionsPrefix = "session:user:"
+)
+
+// RedisSessionStore keeps session metadata as JSON under session:info:<sid>
+// and indexes it per user in the set session:user:<uid>. It implements
+// jwt.SessionStore.
+type RedisSessionStore struct {
+	client redis.Cmdable
+}
+
+func NewRedisSessionStore(client redis.Cmdable) (*RedisSessionStore, error) {
+	if client == nil {
+		return nil, fmt.Errorf("redis client cannot be nil")
+	}
+
+	return &RedisSessionStore{client: client}, nil
+}
+
+func (r *RedisSessionStore) SaveSession(ctx context.Context, info jwt.SessionInfo, ttl time.Duration) error {
+	if ttl < minRedisTTL {
+		ttl = minRedisTTL
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("encode session: %w", err)
+	}
+
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	userKey := userSessionsPrefix + info.UserID.String()
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, sessionInfoPrefix+info.ID.String(), data, ttl)
+	pipe.SAdd(ctx, userKey, info.ID.String())
+	// The index lives as long as the user's newest session.
+	pipe.ExpireGT(ctx, userKey, ttl)
+	pipe.ExpireNX(ctx, userKey, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisSessionStore) TouchSession(ctx context.Context, userID, sessionID uuid.UUID, seenAt time.Time, ttl time.Duration) error {
+	info, ok, err := r.get(ctx, sessionID)
+	if err != nil || !ok {
+		return err
+	}
+	info.LastSeenAt = seenAt
+	return r.SaveSession(ctx, info, ttl)
+}
+
+// ListSessions returns the user's sessions and prunes index entries whose
+// session has expired.
+func (r *RedisSessionStore) ListSessions(ctx context.Context, userID uuid.UUID) ([]jwt.SessionInfo, error) {
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	userKey := userSessionsPrefix + userID.String()
+	ids, err := r.client.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = sessionInfoPrefix + id
+	}
+	vals, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+
+	var (
+		sessions []jwt.SessionInfo
+		expired  []any
+	)
+	for i, v := range vals {
+		data, ok := v.(string)
+		if !ok {
+			expired = append(expired, ids[i])
+			continue
+		}
+		var info jwt.SessionInfo
+		if err := json.Unmarshal([]byte(data), &info); err != nil {
+			return nil, fmt.Errorf("decode session: %w", err)
+		}
+		sessions = append(sessions, info)
+	}
+	if len(expired) > 0 {
+		if err := r.client.SRem(ctx, userKey, expired...).Err(); err != nil {
+			return nil, fmt.Errorf("prune sessions: %w", err)
+		}
+	}
+	return sessions, nil
+}
+
+// Ping implements jwt.Pinger.
+func (r *RedisSessionStore) Ping(ctx context.Context) error {
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *RedisSessionStore) get(ctx context.Context, sessionID uuid.UUID) (jwt.SessionInfo, bool, error) {
+	ctx, cancel := redisutil.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	data, err := r.client.Get(ctx, sessionInfoPrefix+sessionID.String()).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return jwt.SessionInfo{}, false, nil
+	}
+	if err != nil {
+		return jwt.SessionInfo{}, false, fmt.Errorf("get session: %w", err)
+	}
+
+	var info jwt.SessionInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return jwt.SessionInfo{}, false, fmt.Errorf("decode session: %w", err)
+	}
+	return info, true, nil
+}
@@ -20,6 +20,7 @@ type ServiceContext struct {
 	Config       config.Config
 	Repo         *repository.Repository
 	TokenMaker   *jwt.TokenMaker
+	Invalidation *repository.RedisInvalidationStore
 	TxRunner     *postgres.PgxTxRunner
 	RedisClient  *redis.Client
 	EmailSender  email.Sender
@@ -35,6 +36,8 @@ func NewServiceContext(c config.Config) *ServiceContext {
 	txRunner := postgres.NewPgxTxRunner(pool)
 
 	var tokenRepo jwt.RevocationRepository
+	var invalidation *repository.RedisInvalidationStore
+	var sessions *repository.RedisSessionStore
 	var redisClient *redis.Client
 	if c.Cache.Redis.Addr != "" {
 		client, err := redisutil.NewClient(c.Cache.Redis.Addr, c.Cache.Redis.Password, c.Cache.Redis.DB)
@@ -47,6 +50,18 @@ func NewServiceContext(c config.Config) *ServiceContext {
 				logx.Errorf("redis revocation repository init failed: %v", err)
 				tokenRepo = nil
 			}
+			// Cutoffs must outlive remember-me refresh tokens too.
+			invalidation, err = repository.NewRedisInvalidationStore(client,
+				max(c.JWT.RefreshExpiryDuration, c.JWT.RememberMeExpiryDuration))
+			if err != nil {
+				logx.Errorf("redis invalidation store init failed: %v", err)
+				invalidation = nil
+			}
+			sessions, err = repository.NewRedisSessionStore(client)
+			if err != nil {
+				logx.Errorf("redis session store init failed: %v", err)
+				sessions = nil
+			}
 		}
 	}
 
@@ -56,14 +71,33 @@ func NewServiceContext(c config.Config) *ServiceContext {
 
 	cancel := func() {}
 	tokenConfig := jwt.Config{
-		Secret:                c.JWT.Secret,
-		Issuer:                c.JWT.Issuer,
-		Audience:              c.JWT.Audience,
-		AccessExpiryDuration:  c.JWT.AccessExpiryDuration,
-		RefreshExpiryDuration: c.JWT.RefreshExpiryDuration,
+		Secret:                      c.JWT.Secret,
+		Issuer:                      c.JWT.Issuer,
+		Audience:                    c.JWT.Audience,
+		AccessExpiryDuration:        c.JWT.AccessExpiryDuration,
+		RefreshExpiryDuration:       c.JWT.RefreshExpiryDuration,
+		RefreshMaxLifetimeExpiry:    c.JWT.RefreshMaxLifetimeExpiry,
+		RefreshIdleTimeout:          c.JWT.RefreshIdleTimeout,
+		RememberMeExpiryDuration:    c.JWT.RememberMeExpiryDuration,
+		RememberMeMaxLifetimeExpiry: c.JWT.RememberMeMaxLifetimeExpiry,
+		NotIssuedBefore:             c.JWT.NotIssuedBefore,
+		IssuanceRateLimit:           c.JWT.IssuanceRateLimit,
+		IssuanceBurst:               c.JWT.IssuanceBurst,
 	}
 
-	tokenMaker, err := jwt.NewTokenMaker(tokenConfig, tokenRepo)
+	var tokenOpts []jwt.Option
+	if invalidation != nil {
+		tokenOpts = append(tokenOpts, jwt.WithInvalidationSource(invalidation))
+	}
+	if sessions != nil {
+		tokenOpts = append(tokenOpts, jwt.WithSessionStore(sessions))
+	}
+	tokenOpts = append(tokenOpts, jwt.WithReuseHandler(func(ctx context.Context, ev jwt.ReuseEvent) {
+		logx.WithContext(ctx).Errorf("refresh token reuse detected: user=%s session=%s family=%s token=%s revoke_err=%v",
+			ev.UserID, ev.SessionID, ev.FamilyID, ev.TokenID, ev.Err)
+	}))
+
+	tokenMaker, err := jwt.NewTokenMaker(tokenConfig, tokenRepo, tokenOpts...)
 	if err != nil {
 		logx.Must(err)
 	}
@@ -78,14 +112,15 @@ func NewServiceContext(c config.Config) *ServiceContext {
 	}
 
 	return &ServiceContext{
-		Config:      c,
-		Repo:        repo,
-		TokenMaker:  tokenMaker,
-		TxRunner:    txRunner,
-		RedisClient: redisClient,
-		EmailSender: emailSender,
-		cancel:      cancel,
-		pool:        pool,
+		Config:       c,
+		Repo:         repo,
+		TokenMaker:   tokenMaker,
+		Invalidation: invalidation,
+		TxRunner:     txRunner,
+		RedisClient:  redisClient,
+		EmailSender:  emailSender,
+		cancel:       cancel,
+		pool:         pool,
 	}
 }
 
@@ -97,6 +132,9 @@ func (s *ServiceContext) Close() {
 	if s.cancel != nil {
 		s.cancel()
 	}
+	if s.TokenMaker != nil {
+		_ = s.TokenMaker.Close()
+	}
 	if s.RedisClient != nil {
 		_ = s.RedisClient.Close()
 	}